@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/confdb"
@@ -34,8 +35,17 @@ import (
 type ConfdbSchema struct {
 	assertionBase
 
-	schema    *confdb.Schema
-	timestamp time.Time
+	schema     *confdb.Schema
+	migrations []ConfdbMigration
+	timestamp  time.Time
+}
+
+// ConfdbMigration is a declarative rule for migrating the data stored under
+// an earlier revision of a confdb-schema. The value stored at the From
+// storage path is moved to the To storage path or, if To is empty, removed.
+type ConfdbMigration struct {
+	From string
+	To   string
 }
 
 // AccountID returns the identifier of the account that signed this assertion.
@@ -54,6 +64,12 @@ func (ar *ConfdbSchema) Schema() *confdb.Schema {
 	return ar.schema
 }
 
+// Migrations returns the rules to apply, in order, to data stored under an
+// earlier revision of the confdb-schema.
+func (ar *ConfdbSchema) Migrations() []ConfdbMigration {
+	return ar.migrations
+}
+
 func assembleConfdbSchema(assert assertionBase) (Assertion, error) {
 	authorityID := assert.AuthorityID()
 	accountID := assert.HeaderString("account-id")
@@ -102,6 +118,11 @@ func assembleConfdbSchema(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	migrations, err := checkConfdbMigrations(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
 	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
 	if err != nil {
 		return nil, err
@@ -110,10 +131,55 @@ func assembleConfdbSchema(assert assertionBase) (Assertion, error) {
 	return &ConfdbSchema{
 		assertionBase: assert,
 		schema:        confdbSchema,
+		migrations:    migrations,
 		timestamp:     timestamp,
 	}, nil
 }
 
+func checkConfdbMigrations(headers map[string]any) ([]ConfdbMigration, error) {
+	rawMigrations, err := checkList(headers, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []ConfdbMigration
+	for i, raw := range rawMigrations {
+		what := fmt.Sprintf("of migration %d", i+1)
+		rawMap, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("migration %d must be a map", i+1)
+		}
+
+		from, err := checkNotEmptyStringWhat(rawMap, "from", what)
+		if err != nil {
+			return nil, err
+		}
+		to, err := checkOptionalStringWhat(rawMap, "to", what)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range []string{from, to} {
+			if path == "" {
+				continue
+			}
+			if _, err := confdb.ParsePathIntoAccessors(path, confdb.ParseOptions{}); err != nil {
+				return nil, fmt.Errorf("invalid path %q %s: %v", path, what, err)
+			}
+		}
+		if from == to {
+			return nil, fmt.Errorf("migration %d cannot move %q onto itself", i+1, from)
+		}
+		if to != "" && (strings.HasPrefix(to, from+".") || strings.HasPrefix(from, to+".")) {
+			return nil, fmt.Errorf("migration %d cannot move %q to nested path %q", i+1, from, to)
+		}
+
+		migrations = append(migrations, ConfdbMigration{From: from, To: to})
+	}
+
+	return migrations, nil
+}
+
 // ConfdbControl holds a confdb-control assertion, which holds lists of
 // views delegated by the device to operators.
 type ConfdbControl struct {
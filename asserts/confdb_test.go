@@ -128,9 +128,30 @@ func (s *confdbSuite) TestDecodeOK(c *C) {
 		schema := ar.Schema()
 		c.Assert(schema, NotNil, cmt)
 		c.Check(schema.View("wifi-setup"), NotNil, cmt)
+		c.Check(ar.Migrations(), IsNil, cmt)
 	}
 }
 
+func (s *confdbSuite) TestDecodeMigrations(c *C) {
+	encoded := strings.Replace(confdbExample, "TSLINE", s.tsLine, 1)
+	encoded = strings.Replace(encoded, "views:\n", `migrations:
+  -
+    from: wifi.name
+    to: wifi.ssid
+  -
+    from: wifi.legacy
+views:
+`, 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	ar := a.(*asserts.ConfdbSchema)
+	c.Check(ar.Migrations(), DeepEquals, []asserts.ConfdbMigration{
+		{From: "wifi.name", To: "wifi.ssid"},
+		{From: "wifi.legacy"},
+	})
+}
+
 func (s *confdbSuite) TestBuiltinConfdbSchemas(c *C) {
 	tests := []struct {
 		name  string
@@ -194,6 +215,15 @@ func (s *confdbSuite) TestDecodeInvalid(c *C) {
 		{s.tsLine, "", `"timestamp" header is mandatory`},
 		{viewsStanza, "views: foo\n", `"views" header must be a map`},
 		{viewsStanza, "", `"views" stanza is mandatory`},
+		{"views:\n", "migrations: foo\nviews:\n", `"migrations" header must be a list`},
+		{"views:\n", "migrations:\n  - foo\nviews:\n", `migration 1 must be a map`},
+		{"views:\n", "migrations:\n  -\n    to: a\nviews:\n", `"from" of migration 1 is mandatory`},
+		{"views:\n", "migrations:\n  -\n    from: a\n    to:\n      - b\nviews:\n", `"to" of migration 1 must be a string`},
+		{"views:\n", "migrations:\n  -\n    from: a.{b}\nviews:\n", `invalid path "a.{b}" of migration 1: .*`},
+		{"views:\n", "migrations:\n  -\n    from: a\n    to: a..b\nviews:\n", `invalid path "a..b" of migration 1: .*`},
+		{"views:\n", "migrations:\n  -\n    from: a\n    to: a\nviews:\n", `migration 1 cannot move "a" onto itself`},
+		{"views:\n", "migrations:\n  -\n    from: a\n    to: a.b\nviews:\n", `migration 1 cannot move "a" to nested path "a.b"`},
+		{"views:\n", "migrations:\n  -\n    from: a.b\n    to: a\nviews:\n", `migration 1 cannot move "a.b" to nested path "a"`},
 		{"read-write", "update", `cannot define view "wifi-setup": cannot create view rule:.*`},
 		{body, "body-length: 0", `body must contain JSON`},
 		{body, "body-length: 8\n\n  - foo\n", `invalid JSON in body: invalid character ' ' in numeric literal`},
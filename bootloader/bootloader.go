@@ -363,6 +363,7 @@ var (
 		newUbootPart,
 		newUboot,
 		newGrub,
+		newSdboot,
 		newAndroidBoot,
 		newLk,
 		newPiboot,
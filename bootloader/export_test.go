@@ -74,6 +74,23 @@ func MockGrubFiles(c *C, rootdir string) {
 	c.Assert(err, IsNil)
 }
 
+func NewSdboot(rootdir string, opts *Options) RecoveryAwareBootloader {
+	return newSdboot(rootdir, opts).(RecoveryAwareBootloader)
+}
+
+func MockSdbootOpenSnapFile(f func(path string) (snap.Container, error)) (restore func()) {
+	old := sdbootOpenSnapFile
+	sdbootOpenSnapFile = f
+	return func() {
+		sdbootOpenSnapFile = old
+	}
+}
+
+func MockSdbootFiles(c *C, rootdir string, opts *Options) {
+	err := newSdboot(rootdir, opts).InstallBootConfig("", opts)
+	c.Assert(err, IsNil)
+}
+
 func NewLk(rootdir string, opts *Options) ExtractedRecoveryKernelImageBootloader {
 	if opts == nil {
 		opts = &Options{
@@ -373,14 +373,20 @@ func (g *grub) ManagedAssets() []string {
 }
 
 func (g *grub) commandLineForEdition(edition uint, pieces CommandLineComponents) (string, error) {
+	return composeCommandLine(g.defaultCommandLineForEdition(edition), pieces)
+}
+
+// composeCommandLine returns the kernel command line made of the mode and
+// system arguments followed by either the static arguments, with the ones
+// matching the patterns to remove filtered out, and the extra arguments, or
+// the full arguments of the components.
+func composeCommandLine(staticCmdline string, pieces CommandLineComponents) (string, error) {
 	if err := pieces.Validate(); err != nil {
 		return "", err
 	}
 
 	var nonSnapdCmdline string
 	if pieces.FullArgs == "" {
-		staticCmdline := g.defaultCommandLineForEdition(edition)
-
 		keepDefaultArgs := kcmdline.RemoveMatchingFilter(staticCmdline, pieces.RemoveArgs)
 
 		nonSnapdCmdline = strutil.JoinNonEmpty(append(keepDefaultArgs, pieces.ExtraArgs), " ")
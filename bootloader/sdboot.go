@@ -0,0 +1,826 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/bootloader/efi"
+	"github.com/snapcore/snapd/bootloader/grubenv"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
+)
+
+var sdbootOpenSnapFile = snapfile.Open
+
+// sdboot implements the required interfaces
+var (
+	_ Bootloader                        = (*sdboot)(nil)
+	_ RecoveryAwareBootloader           = (*sdboot)(nil)
+	_ ExtractedRunKernelImageBootloader = (*sdboot)(nil)
+	_ TrustedAssetsBootloader           = (*sdboot)(nil)
+	_ UefiBootloader                    = (*sdboot)(nil)
+)
+
+// systemd-boot cannot run scripts nor modify variables, so snapd keeps the
+// boot variables in an environment file of its own and writes out the loader
+// entries, as described by the Boot Loader Specification, matching them:
+//
+// On ubuntu-seed, the ESP, loader/loader.conf selects the first entry with an
+// ID starting with "ubuntu-core-". The ubuntu-core-seed entry boots the kernel
+// of the recovery system selected by snapd_recovery_system in the mode set by
+// snapd_recovery_mode, unless the mode is "run". Each recovery system also has
+// entries to manually recover, install or factory reset using it.
+//
+// On ubuntu-boot, which systemd-boot reads as the XBOOTLDR partition, the
+// ubuntu-core-run entry boots the enabled kernel. When kernel_status is "try",
+// the ubuntu-core-run-try entry boots the try-kernel, using boot counting so
+// that systemd-boot falls back to the ubuntu-core-run entry if the try-kernel
+// does not boot successfully.
+const (
+	sdbootEnvFile    = "sdbootenv"
+	sdbootLoaderConf = "loader/loader.conf"
+	sdbootEntriesDir = "loader/entries"
+
+	sdbootSeedEntry   = "ubuntu-core-seed"
+	sdbootTryEntry    = "ubuntu-core-run-try"
+	sdbootRunEntry    = "ubuntu-core-run"
+	sdbootEntryPrefix = "ubuntu-core-"
+
+	// sdbootStaticCommandLine is the built-in set of kernel command line
+	// arguments.
+	sdbootStaticCommandLine = "console=ttyS0 console=tty1 panic=-1"
+
+	// sdbootUbuntuBootDir is where ubuntu-boot is mounted on a running
+	// system, relative to the root directory.
+	sdbootUbuntuBootDir = "run/mnt/ubuntu-boot"
+
+	// loaderEntrySelected is the EFI variable holding the ID of the entry
+	// booted by systemd-boot. The vendor ID
+	// 4a67b082-0a4c-41cf-b6c7-440b29bb8c4f is systemd.
+	loaderEntrySelected = "LoaderEntrySelected-4a67b082-0a4c-41cf-b6c7-440b29bb8c4f"
+)
+
+const sdbootGeneratedHeader = "# generated by snapd, do not edit\n"
+
+// sdbootRecoveryModes are the modes with a boot entry for each recovery
+// system, along with the title of the entries.
+var sdbootRecoveryModes = []struct {
+	mode  string
+	title string
+}{
+	{"recover", "Recover using %s"},
+	{"install", "Install using %s"},
+	{"factory-reset", "Factory reset using %s"},
+}
+
+type sdboot struct {
+	rootdir string
+
+	recovery         bool
+	prepareImageTime bool
+}
+
+// newSdboot creates a new systemd-boot bootloader object
+func newSdboot(rootdir string, opts *Options) Bootloader {
+	s := &sdboot{rootdir: rootdir}
+	if opts != nil {
+		s.recovery = opts.Role == RoleRecovery
+		s.prepareImageTime = opts.PrepareImageTime
+		if opts.Role == RoleRunMode && !opts.NoSlashBoot {
+			// ubuntu-boot is not mounted under /boot on systems
+			// booting with systemd-boot
+			s.rootdir = filepath.Join(rootdir, sdbootUbuntuBootDir)
+		}
+	}
+	return s
+}
+
+func (s *sdboot) Name() string {
+	return "systemd-boot"
+}
+
+func (s *sdboot) dir() string {
+	if s.rootdir == "" {
+		panic("internal error: unset rootdir")
+	}
+	return filepath.Join(s.rootdir, "EFI/ubuntu")
+}
+
+func (s *sdboot) envFile() string {
+	return filepath.Join(s.dir(), sdbootEnvFile)
+}
+
+func (s *sdboot) entriesDir() string {
+	return filepath.Join(s.rootdir, sdbootEntriesDir)
+}
+
+func (s *sdboot) Present() (bool, error) {
+	return osutil.FileExists(s.envFile()), nil
+}
+
+func (s *sdboot) RequiredByGadget(gadgetDir string) bool {
+	return checkForBlMarker(s, gadgetDir)
+}
+
+func (s *sdboot) InstallBootConfig(gadgetDir string, opts *Options) error {
+	if opts == nil || opts.Role == RoleSole {
+		return fmt.Errorf("cannot use %s without recovery and run modes", s.Name())
+	}
+	if err := os.MkdirAll(s.entriesDir(), 0755); err != nil {
+		return err
+	}
+	if opts.Role == RoleRecovery {
+		loaderConf := []byte(sdbootGeneratedHeader +
+			"timeout 3\n" +
+			"default " + sdbootEntryPrefix + "*\n")
+		if err := osutil.AtomicWriteFile(filepath.Join(s.rootdir, sdbootLoaderConf), loaderConf, 0644, 0); err != nil {
+			return err
+		}
+	}
+	if osutil.FileExists(s.envFile()) {
+		return nil
+	}
+	return s.saveEnv(grubenv.NewEnv(s.envFile()))
+}
+
+func loadSdbootEnv(path string) (*grubenv.Env, error) {
+	env := grubenv.NewEnv(path)
+	if err := env.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return env, nil
+}
+
+func (s *sdboot) saveEnv(env *grubenv.Env) error {
+	if err := os.MkdirAll(s.dir(), 0755); err != nil {
+		return err
+	}
+	return env.Save()
+}
+
+func (s *sdboot) GetBootVars(names ...string) (map[string]string, error) {
+	env := grubenv.NewEnv(s.envFile())
+	if err := env.Load(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if name == "kernel_status" && !s.recovery {
+			status, err := s.kernelStatus(env)
+			if err != nil {
+				return nil, err
+			}
+			out[name] = status
+			continue
+		}
+		out[name] = env.Get(name)
+	}
+	return out, nil
+}
+
+// kernelStatus returns the status of the try-kernel, changing "try" to
+// "trying" once systemd-boot booted the try-kernel, or to "" once it fell
+// back to the enabled kernel, as the boot script of grub would have done.
+func (s *sdboot) kernelStatus(env *grubenv.Env) (string, error) {
+	status := env.Get("kernel_status")
+	if status != "try" {
+		return status, nil
+	}
+	entry, err := s.findEntry(sdbootTryEntry)
+	if err != nil {
+		return "", err
+	}
+	if entry == nil {
+		// the try-kernel cannot be booted
+		return "", nil
+	}
+	if entry.triesLeft != 0 {
+		return status, nil
+	}
+
+	selected, _, err := efi.ReadVarString(loaderEntrySelected)
+	if err != nil {
+		// assume the fallback to the enabled kernel, which is known
+		// to boot
+		logger.Noticef("cannot identify the boot entry selected by %s: %v", s.Name(), err)
+		return "", nil
+	}
+	if id, _ := splitBootCounter(selected); id == sdbootTryEntry {
+		return "trying", nil
+	}
+	return "", nil
+}
+
+// SetBootVars sets the given variables and updates the loader entries
+// depending on them. Setting kernel_status to "try" or setting
+// snapd_recovery_mode also resets the boot counter of the corresponding
+// entry.
+func (s *sdboot) SetBootVars(values map[string]string) error {
+	env, err := loadSdbootEnv(s.envFile())
+	if err != nil {
+		return err
+	}
+	dirty := false
+	for k, v := range values {
+		if env.Get(k) == v {
+			continue
+		}
+		env.Set(k, v)
+		dirty = true
+	}
+	if dirty {
+		if err := s.saveEnv(env); err != nil {
+			return err
+		}
+	}
+
+	if s.recovery {
+		_, setMode := values["snapd_recovery_mode"]
+		_, setSystem := values["snapd_recovery_system"]
+		if setMode || setSystem {
+			return s.updateSeedEntry(env, setMode)
+		}
+		return nil
+	}
+
+	for _, k := range []string{"kernel_status", "snapd_extra_cmdline_args", "snapd_full_cmdline_args"} {
+		if _, ok := values[k]; ok {
+			return s.updateRunEntries(env, values["kernel_status"] == "try")
+		}
+	}
+	return nil
+}
+
+func (s *sdboot) recoverySystemEnvFile(recoverySystemDir string) string {
+	return filepath.Join(s.rootdir, recoverySystemDir, sdbootEnvFile)
+}
+
+// SetRecoverySystemEnv sets the given variables for the recovery system and
+// writes out its loader entries. The kernel image of the recovery system is
+// extracted from the kernel snap set with snapd_recovery_kernel, as
+// systemd-boot cannot load it from the snap.
+func (s *sdboot) SetRecoverySystemEnv(recoverySystemDir string, values map[string]string) error {
+	if recoverySystemDir == "" {
+		return fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	if err := os.MkdirAll(filepath.Join(s.rootdir, recoverySystemDir), 0755); err != nil {
+		return err
+	}
+	env, err := loadSdbootEnv(s.recoverySystemEnvFile(recoverySystemDir))
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		env.Set(k, v)
+	}
+	if err := env.Save(); err != nil {
+		return err
+	}
+
+	if kernel := values["snapd_recovery_kernel"]; kernel != "" {
+		kernelf, err := sdbootOpenSnapFile(filepath.Join(s.rootdir, kernel))
+		if err != nil {
+			return err
+		}
+		if err := extractKernelAssetsToBootDir(filepath.Join(s.rootdir, recoverySystemDir), kernelf, []string{"kernel.efi"}); err != nil {
+			return fmt.Errorf("cannot extract recovery system kernel: %v", err)
+		}
+	}
+
+	label := filepath.Base(recoverySystemDir)
+	for _, m := range sdbootRecoveryModes {
+		content, err := s.recoverySystemEntry(m.mode, label, fmt.Sprintf(m.title, label), "")
+		if err != nil {
+			return err
+		}
+		if err := s.writeEntry(m.mode+"-"+label, content, false, false); err != nil {
+			return err
+		}
+	}
+
+	// the entry booting the selected recovery system may use this one
+	seedEnv, err := loadSdbootEnv(s.envFile())
+	if err != nil {
+		return err
+	}
+	return s.updateSeedEntry(seedEnv, false)
+}
+
+func (s *sdboot) GetRecoverySystemEnv(recoverySystemDir string, key string) (string, error) {
+	if recoverySystemDir == "" {
+		return "", fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	env := grubenv.NewEnv(s.recoverySystemEnvFile(recoverySystemDir))
+	if err := env.Load(); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return env.Get(key), nil
+}
+
+// latestRecoverySystem returns the label of the latest recovery system with
+// an environment, as labels are derived from the date they were created at.
+func (s *sdboot) latestRecoverySystem() (string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.rootdir, "systems/*", sdbootEnvFile))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+	sort.Strings(matches)
+	return filepath.Base(filepath.Dir(matches[len(matches)-1])), nil
+}
+
+func (s *sdboot) recoverySystemEntry(mode, label, title, sortKey string) ([]byte, error) {
+	recoverySystemDir := filepath.Join("systems", label)
+	env, err := loadSdbootEnv(s.recoverySystemEnvFile(recoverySystemDir))
+	if err != nil {
+		return nil, err
+	}
+	options, err := composeCommandLine(sdbootStaticCommandLine, CommandLineComponents{
+		ModeArg:   "snapd_recovery_mode=" + mode,
+		SystemArg: "snapd_recovery_system=" + label,
+		ExtraArgs: env.Get("snapd_extra_cmdline_args"),
+		FullArgs:  env.Get("snapd_full_cmdline_args"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sdbootEntry(title, sortKey, filepath.Join("/", recoverySystemDir, "kernel.efi"), options), nil
+}
+
+// updateSeedEntry writes out the entry booting the recovery system in the
+// mode selected by the environment, or removes it in run mode. The entry is
+// booted only once in recover mode, so that the following boots go back to
+// run mode. The boot counter is reset when rearm is set.
+func (s *sdboot) updateSeedEntry(env *grubenv.Env, rearm bool) error {
+	mode := env.Get("snapd_recovery_mode")
+	if mode == "" {
+		mode = "install"
+	}
+	if mode == "run" {
+		return s.removeEntry(sdbootSeedEntry)
+	}
+
+	label := env.Get("snapd_recovery_system")
+	if label == "" {
+		var err error
+		label, err = s.latestRecoverySystem()
+		if err != nil {
+			return err
+		}
+		if label == "" {
+			return s.removeEntry(sdbootSeedEntry)
+		}
+	}
+
+	content, err := s.recoverySystemEntry(mode, label, "Ubuntu Core "+label, sdbootEntryPrefix+"0")
+	if err != nil {
+		return err
+	}
+	return s.writeEntry(sdbootSeedEntry, content, mode == "recover", rearm)
+}
+
+// updateRunEntries writes out the entries booting the enabled kernel and,
+// when kernel_status is "try", the try-kernel. The boot counter of the try
+// entry is reset when rearm is set.
+func (s *sdboot) updateRunEntries(env *grubenv.Env, rearm bool) error {
+	options, err := composeCommandLine(sdbootStaticCommandLine, CommandLineComponents{
+		ModeArg:   "snapd_recovery_mode=run",
+		ExtraArgs: env.Get("snapd_extra_cmdline_args"),
+		FullArgs:  env.Get("snapd_full_cmdline_args"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if kernel := env.Get("snap_kernel"); kernel != "" {
+		content := sdbootEntry("Ubuntu Core", sdbootEntryPrefix+"2", s.kernelEfiPath(kernel), options)
+		if err := s.writeEntry(sdbootRunEntry, content, false, false); err != nil {
+			return err
+		}
+	}
+
+	tryKernel := env.Get("snap_try_kernel")
+	if env.Get("kernel_status") != "try" || tryKernel == "" {
+		return s.removeEntry(sdbootTryEntry)
+	}
+	content := sdbootEntry("Ubuntu Core (try)", sdbootEntryPrefix+"1", s.kernelEfiPath(tryKernel), options)
+	return s.writeEntry(sdbootTryEntry, content, true, rearm)
+}
+
+// kernelEfiPath returns the path of the extracted kernel image of the kernel
+// snap with the given file name, as used in loader entries.
+func (s *sdboot) kernelEfiPath(snapFilename string) string {
+	return filepath.Join("/EFI/ubuntu", snapFilename, "kernel.efi")
+}
+
+func sdbootEntry(title, sortKey, efiPath, options string) []byte {
+	buf := bytes.NewBufferString(sdbootGeneratedHeader)
+	fmt.Fprintf(buf, "title %s\n", title)
+	if sortKey != "" {
+		fmt.Fprintf(buf, "sort-key %s\n", sortKey)
+	}
+	fmt.Fprintf(buf, "efi %s\n", efiPath)
+	fmt.Fprintf(buf, "options %s\n", options)
+	return buf.Bytes()
+}
+
+type sdbootEntryFile struct {
+	path string
+	// triesLeft is the number of boots left according to the boot
+	// counter, or -1 if the entry is not using boot counting.
+	triesLeft int
+}
+
+// splitBootCounter splits the ID of an entry from its boot counter, as in
+// <id>+<tries-left>[-<tries-done>][.conf]. It returns -1 as the number of
+// tries left when there is no boot counter.
+func splitBootCounter(name string) (id string, triesLeft int) {
+	id = strings.TrimSuffix(name, ".conf")
+	idx := strings.LastIndexByte(id, '+')
+	if idx < 0 {
+		return id, -1
+	}
+	left, _, _ := strings.Cut(id[idx+1:], "-")
+	n, err := strconv.Atoi(left)
+	if err != nil || n < 0 {
+		return id, -1
+	}
+	return id[:idx], n
+}
+
+// findEntry returns the file of the entry with the given ID, or nil if there
+// is none.
+func (s *sdboot) findEntry(id string) (*sdbootEntryFile, error) {
+	matches, err := filepath.Glob(filepath.Join(s.entriesDir(), id+"*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range matches {
+		entryID, triesLeft := splitBootCounter(filepath.Base(m))
+		if entryID == id {
+			return &sdbootEntryFile{path: m, triesLeft: triesLeft}, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeEntry writes out the entry with the given ID, with a boot counter
+// allowing a single boot attempt if bootCounting is set. The boot counter of
+// an existing entry is kept unless rearm is set.
+func (s *sdboot) writeEntry(id string, content []byte, bootCounting, rearm bool) error {
+	existing, err := s.findEntry(id)
+	if err != nil {
+		return err
+	}
+	keepCounter := existing != nil && (existing.triesLeft >= 0) == bootCounting && !(rearm && bootCounting)
+	if keepCounter {
+		// update the content in place
+		current, err := os.ReadFile(existing.path)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(current, content) {
+			return nil
+		}
+		return osutil.AtomicWriteFile(existing.path, content, 0644, 0)
+	}
+
+	name := id + ".conf"
+	if bootCounting {
+		name = id + "+1.conf"
+	}
+	if err := os.MkdirAll(s.entriesDir(), 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.entriesDir(), name)
+	if err := osutil.AtomicWriteFile(path, content, 0644, 0); err != nil {
+		return err
+	}
+	if existing != nil && existing.path != path {
+		return os.Remove(existing.path)
+	}
+	return nil
+}
+
+func (s *sdboot) removeEntry(id string) error {
+	existing, err := s.findEntry(id)
+	if err != nil || existing == nil {
+		return err
+	}
+	return os.Remove(existing.path)
+}
+
+func (s *sdboot) ExtractKernelAssets(sn snap.PlaceInfo, snapf snap.Container) error {
+	return extractKernelAssetsToBootDir(filepath.Join(s.dir(), sn.Filename()), snapf, []string{"kernel.efi"})
+}
+
+func (s *sdboot) RemoveKernelAssets(sn snap.PlaceInfo) error {
+	return removeKernelAssetsFromBootDir(s.dir(), sn)
+}
+
+// ExtractedRunKernelImageBootloader methods
+
+func (s *sdboot) setKernel(name string, sn snap.PlaceInfo) error {
+	kernelEfi := filepath.Join(s.dir(), sn.Filename(), "kernel.efi")
+	if !osutil.FileExists(kernelEfi) {
+		return fmt.Errorf("cannot enable %s at %s: %v", name, kernelEfi, os.ErrNotExist)
+	}
+	env, err := loadSdbootEnv(s.envFile())
+	if err != nil {
+		return err
+	}
+	env.Set(name, sn.Filename())
+	if err := s.saveEnv(env); err != nil {
+		return err
+	}
+	return s.updateRunEntries(env, false)
+}
+
+// readKernel returns the kernel snap referenced by the given variable, or
+// nil if it is unset.
+func (s *sdboot) readKernel(name string) (snap.PlaceInfo, error) {
+	env := grubenv.NewEnv(s.envFile())
+	if err := env.Load(); err != nil {
+		return nil, err
+	}
+	kernel := env.Get(name)
+	if kernel == "" {
+		return nil, nil
+	}
+	sn, err := snap.ParsePlaceInfoFromSnapFileName(kernel)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse kernel snap file name %q: %v", kernel, err)
+	}
+	return sn, nil
+}
+
+// EnableKernel makes the ubuntu-core-run entry boot the referenced kernel
+// snap, which must have been extracted already.
+func (s *sdboot) EnableKernel(sn snap.PlaceInfo) error {
+	return s.setKernel("snap_kernel", sn)
+}
+
+// EnableTryKernel makes the ubuntu-core-run-try entry boot the referenced
+// kernel snap, which must have been extracted already. The entry is only
+// written out once kernel_status is set to "try".
+func (s *sdboot) EnableTryKernel(sn snap.PlaceInfo) error {
+	return s.setKernel("snap_try_kernel", sn)
+}
+
+// DisableTryKernel removes the ubuntu-core-run-try entry and the reference to
+// the try-kernel, also resetting a "try" kernel_status as there is nothing
+// left to try.
+func (s *sdboot) DisableTryKernel() error {
+	env, err := loadSdbootEnv(s.envFile())
+	if err != nil {
+		return err
+	}
+	env.Set("snap_try_kernel", "")
+	if env.Get("kernel_status") == "try" {
+		env.Set("kernel_status", "")
+	}
+	if err := s.saveEnv(env); err != nil {
+		return err
+	}
+	return s.updateRunEntries(env, false)
+}
+
+// Kernel returns the kernel snap booted by the ubuntu-core-run entry.
+func (s *sdboot) Kernel() (snap.PlaceInfo, error) {
+	sn, err := s.readKernel("snap_kernel")
+	if err != nil {
+		return nil, err
+	}
+	if sn == nil {
+		return nil, fmt.Errorf("cannot find enabled kernel")
+	}
+	return sn, nil
+}
+
+// TryKernel returns the kernel snap enabled to be tried, or
+// ErrNoTryKernelRef if there is none.
+func (s *sdboot) TryKernel() (snap.PlaceInfo, error) {
+	sn, err := s.readKernel("snap_try_kernel")
+	if err != nil {
+		return nil, err
+	}
+	if sn == nil {
+		return nil, ErrNoTryKernelRef
+	}
+	return sn, nil
+}
+
+// TrustedAssetsBootloader methods
+
+// UpdateBootConfig does nothing as the boot config of systemd-boot is
+// generated from the boot variables rather than from an edition of a
+// built-in asset.
+func (s *sdboot) UpdateBootConfig() (bool, error) {
+	return false, nil
+}
+
+// ManagedAssets returns the relative paths of the configuration written out
+// by snapd.
+func (s *sdboot) ManagedAssets() []string {
+	if s.recovery {
+		return []string{sdbootLoaderConf}
+	}
+	return nil
+}
+
+func (s *sdboot) CommandLine(pieces CommandLineComponents) (string, error) {
+	return composeCommandLine(sdbootStaticCommandLine, pieces)
+}
+
+func (s *sdboot) CandidateCommandLine(pieces CommandLineComponents) (string, error) {
+	return composeCommandLine(sdbootStaticCommandLine, pieces)
+}
+
+func (s *sdboot) DefaultCommandLine(candidate bool) (string, error) {
+	return sdbootStaticCommandLine, nil
+}
+
+// sdbootBootAssetPath contains the paths for assets in the boot chain.
+type sdbootBootAssetPath struct {
+	// sdbootBinary is the path under which systemd-boot is
+	// installed.
+	sdbootBinary taggedPath
+	// defaultBinary is systemd-boot at the default path for
+	// removable media.
+	defaultBinary taggedPath
+}
+
+var sdbootBootAssetsForArch = map[string]sdbootBootAssetPath{
+	"amd64": {
+		sdbootBinary: taggedPath{
+			tag:  "systemd",
+			path: "EFI/systemd/systemd-bootx64.efi",
+		},
+		defaultBinary: taggedPath{
+			tag:  "boot",
+			path: "EFI/boot/bootx64.efi",
+		},
+	},
+	"arm64": {
+		sdbootBinary: taggedPath{
+			tag:  "systemd",
+			path: "EFI/systemd/systemd-bootaa64.efi",
+		},
+		defaultBinary: taggedPath{
+			tag:  "boot",
+			path: "EFI/boot/bootaa64.efi",
+		},
+	},
+}
+
+func (s *sdboot) bootAssetsForArch() (*sdbootBootAssetPath, error) {
+	if s.prepareImageTime {
+		return nil, fmt.Errorf("internal error: retrieving boot assets at prepare image time")
+	}
+	archi := arch.DpkgArchitecture()
+	assets, ok := sdbootBootAssetsForArch[archi]
+	if !ok {
+		return nil, fmt.Errorf("cannot find %s assets for %q", s.Name(), archi)
+	}
+	return &assets, nil
+}
+
+// TrustedAssets returns the map of relative paths to asset identifiers.
+// systemd-boot on ubuntu-seed loads the kernels of both recovery and run
+// modes, so there are no trusted assets on ubuntu-boot.
+func (s *sdboot) TrustedAssets() (map[string]string, error) {
+	ret := make(map[string]string)
+	if !s.recovery {
+		return ret, nil
+	}
+	assets, err := s.bootAssetsForArch()
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range []taggedPath{assets.sdbootBinary, assets.defaultBinary} {
+		ret[asset.path] = asset.Id()
+	}
+	return ret, nil
+}
+
+// RecoveryBootChains returns the list of load chains for recovery modes.
+// It should be called on a RoleRecovery bootloader.
+func (s *sdboot) RecoveryBootChains(kernelPath string) ([][]BootFile, error) {
+	if !s.recovery {
+		return nil, fmt.Errorf("not a recovery bootloader")
+	}
+	assets, err := s.bootAssetsForArch()
+	if err != nil {
+		return nil, err
+	}
+	var chains [][]BootFile
+	for _, asset := range []taggedPath{assets.sdbootBinary, assets.defaultBinary} {
+		chains = append(chains, []BootFile{
+			NewBootFile("", asset.path, RoleRecovery),
+			NewBootFile(kernelPath, "kernel.efi", RoleRecovery),
+		})
+	}
+	return chains, nil
+}
+
+// BootChains returns the list of load chains for run mode.
+// It should be called on a RoleRecovery bootloader passing the
+// RoleRunMode bootloader.
+func (s *sdboot) BootChains(runBl Bootloader, kernelPath string) ([][]BootFile, error) {
+	if !s.recovery {
+		return nil, fmt.Errorf("not a recovery bootloader")
+	}
+	if runBl.Name() != s.Name() {
+		return nil, fmt.Errorf("run mode bootloader must be %s", s.Name())
+	}
+	assets, err := s.bootAssetsForArch()
+	if err != nil {
+		return nil, err
+	}
+	var chains [][]BootFile
+	for _, asset := range []taggedPath{assets.sdbootBinary, assets.defaultBinary} {
+		chains = append(chains, []BootFile{
+			NewBootFile("", asset.path, RoleRecovery),
+			NewBootFile(kernelPath, "kernel.efi", RoleRunMode),
+		})
+	}
+	return chains, nil
+}
+
+func (s *sdboot) RevocationTriggeringAssets() ([]string, error) {
+	if !s.recovery {
+		return nil, nil
+	}
+	assets, err := s.bootAssetsForArch()
+	if err != nil {
+		return nil, err
+	}
+	return []string{assets.sdbootBinary.Id(), assets.defaultBinary.Id()}, nil
+}
+
+// ParametersForEfiLoadOption returns the parameters of a load option for
+// systemd-boot. It should be called on a RoleRecovery bootloader.
+// updatedAssets is a list of trusted assets that were installed or updated.
+func (s *sdboot) ParametersForEfiLoadOption(updatedAssets []string) (description string, assetPath string, optionalData []byte, err error) {
+	if !s.recovery {
+		return "", "", nil, fmt.Errorf("internal error: run mode %s does not provide a boot entry", s.Name())
+	}
+	assets, err := s.bootAssetsForArch()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	foundBinary := false
+	foundDefault := false
+	for _, updated := range updatedAssets {
+		switch updated {
+		case assets.sdbootBinary.Id():
+			foundBinary = true
+		case assets.defaultBinary.Id():
+			foundDefault = true
+		}
+	}
+
+	switch {
+	case foundBinary:
+		assetPath = filepath.Join(s.rootdir, assets.sdbootBinary.path)
+	case foundDefault:
+		assetPath = filepath.Join(s.rootdir, assets.defaultBinary.path)
+	default:
+		return "", "", nil, ErrNoBootChainFound
+	}
+	return "ubuntu", assetPath, nil, nil
+}
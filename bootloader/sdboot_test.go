@@ -0,0 +1,597 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch/archtest"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/bootloader/efi"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sdbootTestSuite struct {
+	baseBootenvTestSuite
+
+	// seedDir and bootDir are fake ubuntu-seed (the ESP) and ubuntu-boot
+	// partitions
+	seedDir string
+	bootDir string
+}
+
+var _ = Suite(&sdbootTestSuite{})
+
+var (
+	sdbootRunOpts      = &bootloader.Options{Role: bootloader.RoleRunMode, NoSlashBoot: true}
+	sdbootRecoveryOpts = &bootloader.Options{Role: bootloader.RoleRecovery}
+)
+
+const loaderEntrySelected = "LoaderEntrySelected-4a67b082-0a4c-41cf-b6c7-440b29bb8c4f"
+
+func (s *sdbootTestSuite) SetUpTest(c *C) {
+	s.baseBootenvTestSuite.SetUpTest(c)
+	s.AddCleanup(archtest.MockArchitecture("amd64"))
+
+	s.seedDir = filepath.Join(s.rootdir, "run/mnt/ubuntu-seed")
+	s.bootDir = filepath.Join(s.rootdir, "run/mnt/ubuntu-boot")
+}
+
+func (s *sdbootTestSuite) runBootloader(c *C) bootloader.ExtractedRunKernelImageBootloader {
+	bootloader.MockSdbootFiles(c, s.bootDir, sdbootRunOpts)
+	return bootloader.NewSdboot(s.bootDir, sdbootRunOpts).(bootloader.ExtractedRunKernelImageBootloader)
+}
+
+func (s *sdbootTestSuite) recoveryBootloader(c *C) bootloader.RecoveryAwareBootloader {
+	bootloader.MockSdbootFiles(c, s.seedDir, sdbootRecoveryOpts)
+	return bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts)
+}
+
+// unpackableSnapDir is a snap directory which can be unpacked from, like a
+// squashfs snap.
+type unpackableSnapDir struct {
+	*snapdir.SnapDir
+	dir string
+}
+
+func (d *unpackableSnapDir) Unpack(src, dstDir string) error {
+	return osutil.CopyFile(filepath.Join(d.dir, src), filepath.Join(dstDir, src), 0)
+}
+
+func mockKernelSnap(c *C, dir, kernelEfi string) snap.Container {
+	snaptest.PopulateDir(dir, [][]string{
+		{"meta/snap.yaml", "name: pc-kernel\nversion: 1\ntype: kernel\n"},
+		{"kernel.efi", kernelEfi},
+		{"initrd.img", "initrd"},
+	})
+	return &unpackableSnapDir{SnapDir: snapdir.New(dir), dir: dir}
+}
+
+func (s *sdbootTestSuite) extractKernel(c *C, bl bootloader.Bootloader, rev int) snap.PlaceInfo {
+	kernel := snap.MinimalPlaceInfo("pc-kernel", snap.R(rev))
+	snapf := mockKernelSnap(c, c.MkDir(), "kernel")
+	c.Assert(bl.ExtractKernelAssets(kernel, snapf), IsNil)
+	return kernel
+}
+
+func (s *sdbootTestSuite) checkEntries(c *C, dir string, expected map[string]string) {
+	entries, err := os.ReadDir(filepath.Join(dir, "loader/entries"))
+	c.Assert(err, IsNil)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	expectedNames := make([]string, 0, len(expected))
+	for name, content := range expected {
+		expectedNames = append(expectedNames, name)
+		c.Check(filepath.Join(dir, "loader/entries", name), testutil.FileEquals, content)
+	}
+	c.Check(names, testutil.DeepUnsortedMatches, expectedNames)
+}
+
+func runEntry(kernel, options string) string {
+	return `# generated by snapd, do not edit
+title Ubuntu Core
+sort-key ubuntu-core-2
+efi /EFI/ubuntu/` + kernel + `/kernel.efi
+options ` + options + "\n"
+}
+
+func tryEntry(kernel, options string) string {
+	return `# generated by snapd, do not edit
+title Ubuntu Core (try)
+sort-key ubuntu-core-1
+efi /EFI/ubuntu/` + kernel + `/kernel.efi
+options ` + options + "\n"
+}
+
+const defaultRunOptions = "snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1"
+
+// bootTryEntry does what systemd-boot does when booting the try entry, that
+// is decrementing its boot counter.
+func (s *sdbootTestSuite) bootTryEntry(c *C) {
+	entriesDir := filepath.Join(s.bootDir, "loader/entries")
+	err := os.Rename(filepath.Join(entriesDir, "ubuntu-core-run-try+1.conf"), filepath.Join(entriesDir, "ubuntu-core-run-try+0-1.conf"))
+	c.Assert(err, IsNil)
+}
+
+func (s *sdbootTestSuite) kernelStatus(c *C, bl bootloader.Bootloader) string {
+	m, err := bl.GetBootVars("kernel_status")
+	c.Assert(err, IsNil)
+	return m["kernel_status"]
+}
+
+func (s *sdbootTestSuite) TestNewSdboot(c *C) {
+	bl := bootloader.NewSdboot(s.bootDir, sdbootRunOpts)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+
+	present, err := bl.Present()
+	c.Assert(err, IsNil)
+	c.Check(present, Equals, false)
+
+	bootloader.MockSdbootFiles(c, s.bootDir, sdbootRunOpts)
+	present, err = bl.Present()
+	c.Assert(err, IsNil)
+	c.Check(present, Equals, true)
+}
+
+func (s *sdbootTestSuite) TestFind(c *C) {
+	bootloader.MockSdbootFiles(c, s.seedDir, sdbootRecoveryOpts)
+	bootloader.MockSdbootFiles(c, s.bootDir, sdbootRunOpts)
+
+	bl, err := bootloader.Find(s.seedDir, sdbootRecoveryOpts)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+
+	bl, err = bootloader.Find(s.bootDir, sdbootRunOpts)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+
+	// from the running system ubuntu-boot is found at its mount point
+	bl, err = bootloader.Find("", &bootloader.Options{Role: bootloader.RoleRunMode})
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "systemd-boot")
+	c.Assert(bl.SetBootVars(map[string]string{"k": "v"}), IsNil)
+	c.Check(filepath.Join(s.bootDir, "EFI/ubuntu/sdbootenv"), testutil.FileContains, "k=v\n")
+}
+
+func (s *sdbootTestSuite) TestRequiredByGadget(c *C) {
+	gadgetDir := c.MkDir()
+	bl := bootloader.NewSdboot(s.bootDir, sdbootRunOpts)
+	c.Check(bl.RequiredByGadget(gadgetDir), Equals, false)
+
+	c.Assert(os.WriteFile(filepath.Join(gadgetDir, "systemd-boot.conf"), nil, 0644), IsNil)
+	c.Check(bl.RequiredByGadget(gadgetDir), Equals, true)
+
+	found, err := bootloader.ForGadget(gadgetDir, s.bootDir, sdbootRunOpts)
+	c.Assert(err, IsNil)
+	c.Check(found.Name(), Equals, "systemd-boot")
+}
+
+func (s *sdbootTestSuite) TestInstallBootConfig(c *C) {
+	seed := bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts)
+	c.Assert(seed.InstallBootConfig("", sdbootRecoveryOpts), IsNil)
+	c.Check(filepath.Join(s.seedDir, "loader/loader.conf"), testutil.FileEquals, `# generated by snapd, do not edit
+timeout 3
+default ubuntu-core-*
+`)
+	c.Check(filepath.Join(s.seedDir, "loader/entries"), testutil.FilePresent)
+	c.Check(filepath.Join(s.seedDir, "EFI/ubuntu/sdbootenv"), testutil.FilePresent)
+
+	run := bootloader.NewSdboot(s.bootDir, sdbootRunOpts)
+	c.Assert(run.SetBootVars(map[string]string{"k": "v"}), IsNil)
+	c.Assert(run.InstallBootConfig("", sdbootRunOpts), IsNil)
+	c.Check(filepath.Join(s.bootDir, "loader/loader.conf"), testutil.FileAbsent)
+	c.Check(filepath.Join(s.bootDir, "loader/entries"), testutil.FilePresent)
+	// the existing environment is kept
+	m, err := run.GetBootVars("k")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"k": "v"})
+
+	sole := bootloader.NewSdboot(s.rootdir, nil)
+	err = sole.InstallBootConfig("", &bootloader.Options{})
+	c.Check(err, ErrorMatches, "cannot use systemd-boot without recovery and run modes")
+}
+
+func (s *sdbootTestSuite) TestEnableKernel(c *C) {
+	bl := s.runBootloader(c)
+
+	_, err := bl.Kernel()
+	c.Check(err, ErrorMatches, "cannot find enabled kernel")
+
+	kernel := snap.MinimalPlaceInfo("pc-kernel", snap.R(1))
+	err = bl.EnableKernel(kernel)
+	c.Check(err, ErrorMatches, "cannot enable snap_kernel at .*/EFI/ubuntu/pc-kernel_1.snap/kernel.efi: file does not exist")
+
+	kernel = s.extractKernel(c, bl, 1)
+	c.Check(filepath.Join(s.bootDir, "EFI/ubuntu/pc-kernel_1.snap/kernel.efi"), testutil.FileEquals, "kernel")
+	c.Check(filepath.Join(s.bootDir, "EFI/ubuntu/pc-kernel_1.snap/initrd.img"), testutil.FileAbsent)
+
+	c.Assert(bl.EnableKernel(kernel), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_1.snap", defaultRunOptions),
+	})
+	current, err := bl.Kernel()
+	c.Assert(err, IsNil)
+	c.Check(current.Filename(), Equals, "pc-kernel_1.snap")
+	_, err = bl.TryKernel()
+	c.Check(err, Equals, bootloader.ErrNoTryKernelRef)
+
+	c.Assert(bl.RemoveKernelAssets(kernel), IsNil)
+	c.Check(filepath.Join(s.bootDir, "EFI/ubuntu/pc-kernel_1.snap"), testutil.FileAbsent)
+}
+
+func (s *sdbootTestSuite) TestCommandLineArgsInEntries(c *C) {
+	bl := s.runBootloader(c)
+	c.Assert(bl.EnableKernel(s.extractKernel(c, bl, 1)), IsNil)
+
+	err := bl.SetBootVars(map[string]string{
+		"snapd_extra_cmdline_args": "foo bar",
+		"snapd_full_cmdline_args":  "",
+	})
+	c.Assert(err, IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_1.snap", defaultRunOptions+" foo bar"),
+	})
+
+	err = bl.SetBootVars(map[string]string{
+		"snapd_extra_cmdline_args": "",
+		"snapd_full_cmdline_args":  "baz=1",
+	})
+	c.Assert(err, IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_1.snap", "snapd_recovery_mode=run baz=1"),
+	})
+}
+
+func (s *sdbootTestSuite) TestTryKernelBoots(c *C) {
+	bl := s.runBootloader(c)
+	c.Assert(bl.EnableKernel(s.extractKernel(c, bl, 1)), IsNil)
+	newKernel := s.extractKernel(c, bl, 2)
+
+	// as done by snapd to try a new kernel
+	c.Assert(bl.EnableTryKernel(newKernel), IsNil)
+	// not tried before kernel_status is set
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_1.snap", defaultRunOptions),
+	})
+	c.Assert(bl.SetBootVars(map[string]string{"kernel_status": "try"}), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf":       runEntry("pc-kernel_1.snap", defaultRunOptions),
+		"ubuntu-core-run-try+1.conf": tryEntry("pc-kernel_2.snap", defaultRunOptions),
+	})
+	c.Check(s.kernelStatus(c, bl), Equals, "try")
+	tryKernel, err := bl.TryKernel()
+	c.Assert(err, IsNil)
+	c.Check(tryKernel.Filename(), Equals, "pc-kernel_2.snap")
+
+	// systemd-boot boots the try entry
+	s.bootTryEntry(c)
+	s.AddCleanup(efi.MockVars(map[string][]byte{
+		loaderEntrySelected: bootloadertest.UTF16Bytes("ubuntu-core-run-try"),
+	}, nil))
+	c.Check(s.kernelStatus(c, bl), Equals, "trying")
+
+	// as done by snapd to mark the new kernel successful
+	c.Assert(bl.SetBootVars(map[string]string{"kernel_status": ""}), IsNil)
+	c.Assert(bl.EnableKernel(newKernel), IsNil)
+	c.Assert(bl.DisableTryKernel(), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_2.snap", defaultRunOptions),
+	})
+	c.Check(s.kernelStatus(c, bl), Equals, "")
+	current, err := bl.Kernel()
+	c.Assert(err, IsNil)
+	c.Check(current.Filename(), Equals, "pc-kernel_2.snap")
+	_, err = bl.TryKernel()
+	c.Check(err, Equals, bootloader.ErrNoTryKernelRef)
+}
+
+func (s *sdbootTestSuite) TestTryKernelFallback(c *C) {
+	bl := s.runBootloader(c)
+	c.Assert(bl.EnableKernel(s.extractKernel(c, bl, 1)), IsNil)
+	c.Assert(bl.EnableTryKernel(s.extractKernel(c, bl, 2)), IsNil)
+	c.Assert(bl.SetBootVars(map[string]string{"kernel_status": "try"}), IsNil)
+
+	// the try entry was booted but did not make it, systemd-boot falls
+	// back to the run entry
+	s.bootTryEntry(c)
+	s.AddCleanup(efi.MockVars(map[string][]byte{
+		loaderEntrySelected: bootloadertest.UTF16Bytes("ubuntu-core-run"),
+	}, nil))
+	c.Check(s.kernelStatus(c, bl), Equals, "")
+
+	// the fallback is assumed without EFI variables
+	s.AddCleanup(efi.MockVars(nil, nil))
+	c.Check(s.kernelStatus(c, bl), Equals, "")
+
+	// changing the command line does not allow another attempt
+	c.Assert(bl.SetBootVars(map[string]string{"snapd_extra_cmdline_args": "foo"}), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf":         runEntry("pc-kernel_1.snap", defaultRunOptions+" foo"),
+		"ubuntu-core-run-try+0-1.conf": tryEntry("pc-kernel_2.snap", defaultRunOptions+" foo"),
+	})
+
+	// but setting kernel_status again does
+	c.Assert(bl.SetBootVars(map[string]string{"kernel_status": "try"}), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf":       runEntry("pc-kernel_1.snap", defaultRunOptions+" foo"),
+		"ubuntu-core-run-try+1.conf": tryEntry("pc-kernel_2.snap", defaultRunOptions+" foo"),
+	})
+	c.Check(s.kernelStatus(c, bl), Equals, "try")
+
+	// disabling the try-kernel also resets kernel_status
+	c.Assert(bl.DisableTryKernel(), IsNil)
+	s.checkEntries(c, s.bootDir, map[string]string{
+		"ubuntu-core-run.conf": runEntry("pc-kernel_1.snap", defaultRunOptions+" foo"),
+	})
+	m, err := bl.GetBootVars("kernel_status", "snap_try_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"kernel_status": "", "snap_try_kernel": ""})
+}
+
+func (s *sdbootTestSuite) makeRecoverySystem(c *C, bl bootloader.RecoveryAwareBootloader, label string) {
+	restore := bootloader.MockSdbootOpenSnapFile(func(path string) (snap.Container, error) {
+		c.Check(path, Equals, filepath.Join(s.seedDir, "snaps/pc-kernel_1.snap"))
+		return mockKernelSnap(c, c.MkDir(), "recovery kernel"), nil
+	})
+	defer restore()
+	err := bl.SetRecoverySystemEnv("systems/"+label, map[string]string{
+		"snapd_recovery_kernel":    "/snaps/pc-kernel_1.snap",
+		"snapd_extra_cmdline_args": "foo",
+		"snapd_full_cmdline_args":  "",
+	})
+	c.Assert(err, IsNil)
+}
+
+func recoverySystemEntry(title, sortKey, mode, label string) string {
+	content := "# generated by snapd, do not edit\ntitle " + title + "\n"
+	if sortKey != "" {
+		content += "sort-key " + sortKey + "\n"
+	}
+	return content + `efi /systems/` + label + `/kernel.efi
+options snapd_recovery_mode=` + mode + ` snapd_recovery_system=` + label + ` console=ttyS0 console=tty1 panic=-1 foo
+`
+}
+
+func recoverySystemEntries(label string) map[string]string {
+	return map[string]string{
+		"recover-" + label + ".conf":       recoverySystemEntry("Recover using "+label, "", "recover", label),
+		"install-" + label + ".conf":       recoverySystemEntry("Install using "+label, "", "install", label),
+		"factory-reset-" + label + ".conf": recoverySystemEntry("Factory reset using "+label, "", "factory-reset", label),
+	}
+}
+
+func (s *sdbootTestSuite) TestRecoverySystemEnv(c *C) {
+	bl := s.recoveryBootloader(c)
+
+	err := bl.SetRecoverySystemEnv("", nil)
+	c.Check(err, ErrorMatches, "internal error: recoverySystemDir unset")
+
+	s.makeRecoverySystem(c, bl, "20260101")
+	c.Check(filepath.Join(s.seedDir, "systems/20260101/kernel.efi"), testutil.FileEquals, "recovery kernel")
+
+	value, err := bl.GetRecoverySystemEnv("systems/20260101", "snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, "/snaps/pc-kernel_1.snap")
+	value, err = bl.GetRecoverySystemEnv("systems/20250101", "snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, "")
+
+	// no mode selected means install, using the latest system
+	expected := recoverySystemEntries("20260101")
+	expected["ubuntu-core-seed.conf"] = recoverySystemEntry("Ubuntu Core 20260101", "ubuntu-core-0", "install", "20260101")
+	s.checkEntries(c, s.seedDir, expected)
+}
+
+func (s *sdbootTestSuite) TestSeedEntry(c *C) {
+	bl := s.recoveryBootloader(c)
+
+	// the system is not there yet at prepare-image time
+	err := bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "install",
+		"snapd_recovery_system": "20260101",
+	})
+	c.Assert(err, IsNil)
+	s.checkEntries(c, s.seedDir, map[string]string{
+		"ubuntu-core-seed.conf": "# generated by snapd, do not edit\n" +
+			"title Ubuntu Core 20260101\n" +
+			"sort-key ubuntu-core-0\n" +
+			"efi /systems/20260101/kernel.efi\n" +
+			"options snapd_recovery_mode=install snapd_recovery_system=20260101 console=ttyS0 console=tty1 panic=-1\n",
+	})
+
+	s.makeRecoverySystem(c, bl, "20260101")
+	s.makeRecoverySystem(c, bl, "20260201")
+	expected := recoverySystemEntries("20260101")
+	for name, content := range recoverySystemEntries("20260201") {
+		expected[name] = content
+	}
+	expected["ubuntu-core-seed.conf"] = recoverySystemEntry("Ubuntu Core 20260101", "ubuntu-core-0", "install", "20260101")
+	s.checkEntries(c, s.seedDir, expected)
+
+	// run mode does not use the seed entry
+	c.Assert(bl.SetBootVars(map[string]string{"snapd_recovery_mode": "run"}), IsNil)
+	delete(expected, "ubuntu-core-seed.conf")
+	s.checkEntries(c, s.seedDir, expected)
+
+	// the recover mode is only booted once
+	err = bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "recover",
+		"snapd_recovery_system": "20260201",
+	})
+	c.Assert(err, IsNil)
+	expected["ubuntu-core-seed+1.conf"] = recoverySystemEntry("Ubuntu Core 20260201", "ubuntu-core-0", "recover", "20260201")
+	s.checkEntries(c, s.seedDir, expected)
+
+	entriesDir := filepath.Join(s.seedDir, "loader/entries")
+	err = os.Rename(filepath.Join(entriesDir, "ubuntu-core-seed+1.conf"), filepath.Join(entriesDir, "ubuntu-core-seed+0-1.conf"))
+	c.Assert(err, IsNil)
+
+	// and can be requested again
+	c.Assert(bl.SetBootVars(map[string]string{"snapd_recovery_mode": "recover"}), IsNil)
+	s.checkEntries(c, s.seedDir, expected)
+
+	// other variables do not change the entries
+	c.Assert(bl.SetBootVars(map[string]string{"k": "v"}), IsNil)
+	s.checkEntries(c, s.seedDir, expected)
+}
+
+func (s *sdbootTestSuite) TestCommandLine(c *C) {
+	bl := bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts).(bootloader.TrustedAssetsBootloader)
+
+	cmdline, err := bl.CommandLine(bootloader.CommandLineComponents{
+		ModeArg:   "snapd_recovery_mode=recover",
+		SystemArg: "snapd_recovery_system=20260101",
+		ExtraArgs: "foo",
+	})
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=recover snapd_recovery_system=20260101 console=ttyS0 console=tty1 panic=-1 foo")
+
+	cmdline, err = bl.CandidateCommandLine(bootloader.CommandLineComponents{
+		ModeArg:  "snapd_recovery_mode=run",
+		FullArgs: "bar",
+	})
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "snapd_recovery_mode=run bar")
+
+	_, err = bl.CommandLine(bootloader.CommandLineComponents{
+		ExtraArgs: "foo",
+		FullArgs:  "bar",
+	})
+	c.Check(err, ErrorMatches, "cannot use both full and extra components of command line")
+
+	cmdline, err = bl.DefaultCommandLine(true)
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "console=ttyS0 console=tty1 panic=-1")
+
+	updated, err := bl.UpdateBootConfig()
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+}
+
+func (s *sdbootTestSuite) TestTrustedAssets(c *C) {
+	seed := bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts).(bootloader.TrustedAssetsBootloader)
+	run := bootloader.NewSdboot(s.bootDir, sdbootRunOpts).(bootloader.TrustedAssetsBootloader)
+
+	c.Check(seed.ManagedAssets(), DeepEquals, []string{"loader/loader.conf"})
+	c.Check(run.ManagedAssets(), HasLen, 0)
+
+	assets, err := seed.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(assets, DeepEquals, map[string]string{
+		"EFI/systemd/systemd-bootx64.efi": "systemd:systemd-bootx64.efi",
+		"EFI/boot/bootx64.efi":            "boot:bootx64.efi",
+	})
+	assets, err = run.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(assets, HasLen, 0)
+
+	revoking, err := seed.RevocationTriggeringAssets()
+	c.Assert(err, IsNil)
+	c.Check(revoking, DeepEquals, []string{"systemd:systemd-bootx64.efi", "boot:bootx64.efi"})
+	revoking, err = run.RevocationTriggeringAssets()
+	c.Assert(err, IsNil)
+	c.Check(revoking, HasLen, 0)
+
+	s.AddCleanup(archtest.MockArchitecture("arm64"))
+	assets, err = seed.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(assets, DeepEquals, map[string]string{
+		"EFI/systemd/systemd-bootaa64.efi": "systemd:systemd-bootaa64.efi",
+		"EFI/boot/bootaa64.efi":            "boot:bootaa64.efi",
+	})
+
+	s.AddCleanup(archtest.MockArchitecture("riscv64"))
+	_, err = seed.TrustedAssets()
+	c.Check(err, ErrorMatches, `cannot find systemd-boot assets for "riscv64"`)
+
+	prepareImageOpts := &bootloader.Options{Role: bootloader.RoleRecovery, PrepareImageTime: true}
+	_, err = bootloader.NewSdboot(s.seedDir, prepareImageOpts).(bootloader.TrustedAssetsBootloader).TrustedAssets()
+	c.Check(err, ErrorMatches, "internal error: retrieving boot assets at prepare image time")
+}
+
+func (s *sdbootTestSuite) TestBootChains(c *C) {
+	seed := bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts).(bootloader.TrustedAssetsBootloader)
+	run := bootloader.NewSdboot(s.bootDir, sdbootRunOpts).(bootloader.TrustedAssetsBootloader)
+
+	chains, err := seed.RecoveryBootChains("/snaps/pc-kernel_1.snap")
+	c.Assert(err, IsNil)
+	c.Check(chains, DeepEquals, [][]bootloader.BootFile{
+		{
+			bootloader.NewBootFile("", "EFI/systemd/systemd-bootx64.efi", bootloader.RoleRecovery),
+			bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRecovery),
+		}, {
+			bootloader.NewBootFile("", "EFI/boot/bootx64.efi", bootloader.RoleRecovery),
+			bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRecovery),
+		},
+	})
+
+	chains, err = seed.BootChains(run, "/var/lib/snapd/snaps/pc-kernel_2.snap")
+	c.Assert(err, IsNil)
+	c.Check(chains, DeepEquals, [][]bootloader.BootFile{
+		{
+			bootloader.NewBootFile("", "EFI/systemd/systemd-bootx64.efi", bootloader.RoleRecovery),
+			bootloader.NewBootFile("/var/lib/snapd/snaps/pc-kernel_2.snap", "kernel.efi", bootloader.RoleRunMode),
+		}, {
+			bootloader.NewBootFile("", "EFI/boot/bootx64.efi", bootloader.RoleRecovery),
+			bootloader.NewBootFile("/var/lib/snapd/snaps/pc-kernel_2.snap", "kernel.efi", bootloader.RoleRunMode),
+		},
+	})
+
+	_, err = run.RecoveryBootChains("/snaps/pc-kernel_1.snap")
+	c.Check(err, ErrorMatches, "not a recovery bootloader")
+	_, err = run.BootChains(run, "/snaps/pc-kernel_1.snap")
+	c.Check(err, ErrorMatches, "not a recovery bootloader")
+	_, err = seed.BootChains(bootloader.NewGrub(s.bootDir, sdbootRunOpts), "/snaps/pc-kernel_1.snap")
+	c.Check(err, ErrorMatches, "run mode bootloader must be systemd-boot")
+}
+
+func (s *sdbootTestSuite) TestParametersForEfiLoadOption(c *C) {
+	seed := bootloader.NewSdboot(s.seedDir, sdbootRecoveryOpts).(bootloader.UefiBootloader)
+
+	description, assetPath, optionalData, err := seed.ParametersForEfiLoadOption([]string{
+		"boot:bootx64.efi",
+		"systemd:systemd-bootx64.efi",
+	})
+	c.Assert(err, IsNil)
+	c.Check(description, Equals, "ubuntu")
+	c.Check(assetPath, Equals, filepath.Join(s.seedDir, "EFI/systemd/systemd-bootx64.efi"))
+	c.Check(optionalData, IsNil)
+
+	_, assetPath, _, err = seed.ParametersForEfiLoadOption([]string{"boot:bootx64.efi"})
+	c.Assert(err, IsNil)
+	c.Check(assetPath, Equals, filepath.Join(s.seedDir, "EFI/boot/bootx64.efi"))
+
+	_, _, _, err = seed.ParametersForEfiLoadOption([]string{"other"})
+	c.Check(err, Equals, bootloader.ErrNoBootChainFound)
+
+	run := bootloader.NewSdboot(s.bootDir, sdbootRunOpts).(bootloader.UefiBootloader)
+	_, _, _, err = run.ParametersForEfiLoadOption(nil)
+	c.Check(err, ErrorMatches, "internal error: run mode systemd-boot does not provide a boot entry")
+}
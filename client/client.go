@@ -669,6 +669,8 @@ type RefreshInfo struct {
 	Timer string `json:"timer,omitempty"`
 	// Schedule contains the legacy refresh.schedule setting.
 	Schedule string `json:"schedule,omitempty"`
+	// Blackout contains the refresh.blackout setting.
+	Blackout string `json:"blackout,omitempty"`
 	Last     string `json:"last,omitempty"`
 	Hold     string `json:"hold,omitempty"`
 	Next     string `json:"next,omitempty"`
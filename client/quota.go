@@ -45,6 +45,18 @@ type QuotaGroupResult struct {
 	Services    []string     `json:"services,omitempty"`
 	Constraints *QuotaValues `json:"constraints,omitempty"`
 	Current     *QuotaValues `json:"current,omitempty"`
+	// UsageHistory holds the sampled resource usage of the group, oldest
+	// first, when sampling is enabled.
+	UsageHistory []QuotaUsageSample `json:"usage-history,omitempty"`
+}
+
+// QuotaUsageSample is the resource usage of a quota group at a given time.
+type QuotaUsageSample struct {
+	Time    time.Time     `json:"time"`
+	Memory  quantity.Size `json:"memory"`
+	CPUTime time.Duration `json:"cpu-time"`
+	Threads int           `json:"threads"`
+	Journal quantity.Size `json:"journal"`
 }
 
 type QuotaCPUValues struct {
@@ -66,12 +78,35 @@ type QuotaJournalValues struct {
 	*QuotaJournalRate
 }
 
+// QuotaIOValues are the block IO limits for a device. Setting all the
+// limits of a device to zero removes them.
+type QuotaIOValues struct {
+	Device         string        `json:"device"`
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       int           `json:"read-iops,omitempty"`
+	WriteIOPS      int           `json:"write-iops,omitempty"`
+}
+
+// QuotaNetworkValues are the network limits of a quota group, or its network
+// usage when reported as the current usage of the group.
+type QuotaNetworkValues struct {
+	// EgressRate is the limit of the outgoing traffic in bytes per second.
+	EgressRate quantity.Size `json:"egress-rate,omitempty"`
+	// IngressBytes and EgressBytes are the bytes received and sent by the
+	// group, they are only part of the current usage.
+	IngressBytes quantity.Size `json:"ingress-bytes,omitempty"`
+	EgressBytes  quantity.Size `json:"egress-bytes,omitempty"`
+}
+
 type QuotaValues struct {
 	Memory  quantity.Size       `json:"memory,omitempty"`
 	CPU     *QuotaCPUValues     `json:"cpu,omitempty"`
 	CPUSet  *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
 	Threads int                 `json:"threads,omitempty"`
 	Journal *QuotaJournalValues `json:"journal,omitempty"`
+	IO      []QuotaIOValues     `json:"io,omitempty"`
+	Network *QuotaNetworkValues `json:"network,omitempty"`
 }
 
 type EnsureQuotaOptions struct {
@@ -155,6 +155,30 @@ func (cs *clientSuite) TestGetQuotaGroup(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestGetQuotaGroupUsageHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 999 },
+			"current": { "memory": 450 },
+			"usage-history": [
+				{"time": "2026-03-18T12:00:00Z", "memory": 400, "cpu-time": 1000000000, "threads": 3, "journal": 0},
+				{"time": "2026-03-18T12:01:00Z", "memory": 450, "cpu-time": 2000000000, "threads": 4, "journal": 1024}
+			]
+		}
+	}`
+
+	grp, err := cs.cli.GetQuotaGroup("foo")
+	c.Assert(err, check.IsNil)
+	t0 := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	c.Check(grp.UsageHistory, check.DeepEquals, []client.QuotaUsageSample{
+		{Time: t0, Memory: 400, CPUTime: time.Second, Threads: 3},
+		{Time: t0.Add(time.Minute), Memory: 450, CPUTime: 2 * time.Second, Threads: 4, Journal: 1024},
+	})
+}
+
 func (cs *clientSuite) TestGetQuotaGroupError(c *check.C) {
 	cs.status = 500
 	cs.rsp = `{"type": "error"}`
@@ -64,6 +64,9 @@ type SnapOptions struct {
 	Time             string          `json:"time,omitempty"`
 	HoldLevel        string          `json:"hold-level,omitempty"`
 	Users            []string        `json:"users,omitempty"`
+	// SnapshotKey is the passphrase or the content of the key file
+	// to encrypt snapshots with
+	SnapshotKey []byte `json:"snapshot-key,omitempty"`
 }
 
 func writeFieldBool(mw *multipart.Writer, key string, val bool) error {
@@ -136,6 +139,7 @@ type multiActionData struct {
 	Time           string              `json:"time,omitempty"`
 	HoldLevel      string              `json:"hold-level,omitempty"`
 	Components     map[string][]string `json:"components,omitempty"`
+	SnapshotKey    []byte              `json:"snapshot-key,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -205,8 +209,9 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 }
 
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
-func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, nil, &SnapOptions{Users: users})
+// If key is not nil, the snapshots are encrypted with it.
+func (client *Client) SnapshotMany(names []string, users []string, key []byte) (setID uint64, changeID string, err error) {
+	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, nil, &SnapOptions{Users: users, SnapshotKey: key})
 	if err != nil {
 		return 0, "", err
 	}
@@ -269,6 +274,7 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, c
 		action.ValidationSets = options.ValidationSets
 		action.Time = options.Time
 		action.HoldLevel = options.HoldLevel
+		action.SnapshotKey = options.SnapshotKey
 	}
 
 	data, err := json.Marshal(&action)
@@ -20,6 +20,7 @@
 package client_test
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -108,7 +109,7 @@ func (cs *clientSuite) TestClientMultiOpSnapServerError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*fail`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*fail`)
 }
 
@@ -128,7 +129,7 @@ func (cs *clientSuite) TestClientMultiOpSnapResponseError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*server error: "Internal Server Error"`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*server error: "Internal Server Error"`)
 }
 
@@ -292,7 +293,7 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 		"status-code": 202,
 		"type": "async"
 	}`
-	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil)
+	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
 
@@ -309,6 +310,26 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientMultiSnapshotWithKey(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"result": {"set-id": 42},
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	_, _, err := cs.cli.SnapshotMany([]string{pkgName}, nil, []byte("secret"))
+	c.Assert(err, check.IsNil)
+
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]any)
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody["action"], check.Equals, "snapshot")
+	c.Check(jsonBody["snapshot-key"], check.Equals, base64.StdEncoding.EncodeToString([]byte("secret")))
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
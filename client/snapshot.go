@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,6 +40,10 @@ import (
 // SnapshotExportMediaType is the media type used to identify snapshot exports in the API.
 const SnapshotExportMediaType = "application/x.snapd.snapshot"
 
+// SnapshotKeyHeader is the header carrying the base64 encoded passphrase or
+// key file content for snapshot exports and imports.
+const SnapshotKeyHeader = "X-Snapd-Snapshot-Key"
+
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
@@ -50,6 +55,7 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	Key    []byte   `json:"key,omitempty"`
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -77,6 +83,13 @@ type Snapshot struct {
 	SHA3_384 map[string]string `json:"sha3-384"`
 	// the sum of the archive sizes
 	Size int64 `json:"size,omitempty"`
+	// set if the archives' data is stored as chunks shared with other
+	// snapshots, in which case SHA3_384 and Size are those of the
+	// uncompressed archives
+	Incremental bool `json:"incremental,omitempty"`
+	// set if the archives are encrypted, in which case SHA3_384 and
+	// Size are those of the decrypted archives
+	Encrypted bool `json:"encrypted,omitempty"`
 
 	// dynamic snapshot options
 	Options *snap.SnapshotOptions `json:"options,omitempty"`
@@ -195,26 +208,28 @@ func (client *Client) ForgetSnapshots(setID uint64, snaps []string) (changeID st
 // CheckSnapshots verifies the archive checksums in the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The key is needed for encrypted snapshots.
+func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string, key []byte) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "check",
 		Snaps:  snaps,
 		Users:  users,
+		Key:    key,
 	})
 }
 
 // RestoreSnapshots extracts the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The key is needed for encrypted snapshots.
+func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string, key []byte) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "restore",
 		Snaps:  snaps,
 		Users:  users,
+		Key:    key,
 	})
 }
 
@@ -231,11 +246,24 @@ func (client *Client) snapshotAction(action *snapshotAction) (changeID string, e
 	return client.doAsync("POST", "/v2/snapshots", nil, headers, bytes.NewBuffer(data))
 }
 
-// SnapshotExport streams the requested snapshot set.
+// snapshotKeyHeaders returns the headers passing the given passphrase or key
+// file content along with snapshot exports and imports.
+func snapshotKeyHeaders(headers map[string]string, key []byte) map[string]string {
+	if key != nil {
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[SnapshotKeyHeader] = base64.StdEncoding.EncodeToString(key)
+	}
+	return headers
+}
+
+// SnapshotExport streams the requested snapshot set. If key is not nil the
+// export is encrypted with it.
 //
 // The return value includes the length of the returned stream.
-func (client *Client) SnapshotExport(setID uint64) (stream io.ReadCloser, contentLength int64, err error) {
-	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, nil, nil)
+func (client *Client) SnapshotExport(setID uint64, key []byte) (stream io.ReadCloser, contentLength int64, err error) {
+	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, snapshotKeyHeaders(nil, key), nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -266,12 +294,13 @@ type SnapshotImportSet struct {
 	Snaps []string `json:"snaps"`
 }
 
-// SnapshotImport imports an exported snapshot set.
-func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (SnapshotImportSet, error) {
-	headers := map[string]string{
+// SnapshotImport imports an exported snapshot set. The key is needed for
+// encrypted exports or snapshots.
+func (client *Client) SnapshotImport(exportStream io.Reader, size int64, key []byte) (SnapshotImportSet, error) {
+	headers := snapshotKeyHeaders(map[string]string{
 		"Content-Type":   SnapshotExportMediaType,
 		"Content-Length": strconv.FormatInt(size, 10),
-	}
+	}, key)
 
 	var importSet SnapshotImportSet
 	if _, err := client.doSync("POST", "/v2/snapshots", nil, headers, exportStream, &importSet); err != nil {
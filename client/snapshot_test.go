@@ -21,6 +21,7 @@ package client_test
 
 import (
 	"crypto/sha256"
+	"encoding/base64"
 	"io"
 	"net/http"
 	"net/url"
@@ -98,7 +99,7 @@ func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
 	})
 }
 
-func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, users []string, f func() (string, error)) {
+func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, users []string, key []byte, f func() (string, error)) {
 	cs.status = 202
 	cs.rsp = `{
 		"status-code": 202,
@@ -117,6 +118,7 @@ func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, u
 	c.Check(act.Action, check.Equals, action)
 	c.Check(act.Snaps, check.DeepEquals, []string{"asnap", "bsnap"})
 	c.Check(act.Users, check.DeepEquals, users)
+	c.Check(act.Key, check.DeepEquals, key)
 
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
@@ -124,23 +126,31 @@ func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, u
 }
 
 func (cs *clientSuite) TestClientForgetSnapshot(c *check.C) {
-	cs.testClientSnapshotActionFull(c, "forget", nil, func() (string, error) {
+	cs.testClientSnapshotActionFull(c, "forget", nil, nil, func() (string, error) {
 		return cs.cli.ForgetSnapshots(42, []string{"asnap", "bsnap"})
 	})
 }
 
-func (cs *clientSuite) testClientSnapshotAction(c *check.C, action string, f func(uint64, []string, []string) (string, error)) {
-	cs.testClientSnapshotActionFull(c, action, []string{"auser", "buser"}, func() (string, error) {
-		return f(42, []string{"asnap", "bsnap"}, []string{"auser", "buser"})
+func (cs *clientSuite) testClientSnapshotAction(c *check.C, action string, key []byte, f func(uint64, []string, []string, []byte) (string, error)) {
+	cs.testClientSnapshotActionFull(c, action, []string{"auser", "buser"}, key, func() (string, error) {
+		return f(42, []string{"asnap", "bsnap"}, []string{"auser", "buser"}, key)
 	})
 }
 
 func (cs *clientSuite) TestClientCheckSnapshots(c *check.C) {
-	cs.testClientSnapshotAction(c, "check", cs.cli.CheckSnapshots)
+	cs.testClientSnapshotAction(c, "check", nil, cs.cli.CheckSnapshots)
+}
+
+func (cs *clientSuite) TestClientCheckSnapshotsWithKey(c *check.C) {
+	cs.testClientSnapshotAction(c, "check", []byte("secret"), cs.cli.CheckSnapshots)
 }
 
 func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
-	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
+	cs.testClientSnapshotAction(c, "restore", nil, cs.cli.RestoreSnapshots)
+}
+
+func (cs *clientSuite) TestClientRestoreSnapshotsWithKey(c *check.C) {
+	cs.testClientSnapshotAction(c, "restore", []byte("secret"), cs.cli.RestoreSnapshots)
 }
 
 func (cs *clientSuite) TestClientExportSnapshotSpecificErr(c *check.C) {
@@ -149,10 +159,21 @@ func (cs *clientSuite) TestClientExportSnapshotSpecificErr(c *check.C) {
 	cs.rsp = content
 	cs.status = 400
 	cs.header = http.Header{"Content-Type": []string{"application/json"}}
-	_, _, err := cs.cli.SnapshotExport(42)
+	_, _, err := cs.cli.SnapshotExport(42, nil)
 	c.Check(err, check.ErrorMatches, "boom")
 }
 
+func (cs *clientSuite) TestClientExportSnapshotWithKey(c *check.C) {
+	cs.contentLength = int64(len("test-export"))
+	cs.header = http.Header{"Content-Type": []string{client.SnapshotExportMediaType}}
+	cs.rsp = "test-export"
+	cs.status = 200
+
+	_, _, err := cs.cli.SnapshotExport(42, []byte("secret"))
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Header.Get(client.SnapshotKeyHeader), check.Equals, base64.StdEncoding.EncodeToString([]byte("secret")))
+}
+
 func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 	type tableT struct {
 		content     string
@@ -174,7 +195,7 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 		cs.rsp = t.content
 		cs.status = t.status
 
-		r, size, err := cs.cli.SnapshotExport(42)
+		r, size, err := cs.cli.SnapshotExport(42, nil)
 		if t.status == 200 {
 			c.Assert(err, check.IsNil, comm)
 			c.Assert(cs.countingCloser.closeCalled, check.Equals, 0)
@@ -212,7 +233,7 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 
 		fakeSnapshotData := "fake"
 		r := strings.NewReader(fakeSnapshotData)
-		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)))
+		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)), nil)
 		if t.error != "" {
 			c.Assert(err, check.NotNil, comm)
 			c.Check(err.Error(), check.Equals, t.error, comm)
@@ -221,6 +242,7 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 		c.Assert(err, check.IsNil, comm)
 		c.Assert(cs.req.Header.Get("Content-Type"), check.Equals, client.SnapshotExportMediaType)
 		c.Assert(cs.req.Header.Get("Content-Length"), check.Equals, strconv.Itoa(len(fakeSnapshotData)))
+		c.Check(cs.req.Header.Get(client.SnapshotKeyHeader), check.Equals, "")
 		c.Check(importSet.ID, check.Equals, t.setID, comm)
 		c.Check(importSet.Snaps, check.DeepEquals, []string{"baz", "bar", "foo"}, comm)
 		d, err := io.ReadAll(cs.req.Body)
@@ -229,6 +251,15 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientSnapshotImportWithKey(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["foo"]}}`
+
+	_, err := cs.cli.SnapshotImport(strings.NewReader("fake"), 4, []byte("secret"))
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, client.SnapshotExportMediaType)
+	c.Check(cs.req.Header.Get(client.SnapshotKeyHeader), check.Equals, base64.StdEncoding.EncodeToString([]byte("secret")))
+}
+
 func (cs *clientSuite) TestClientSnapshotContentHash(c *check.C) {
 	now := time.Now()
 	revno := snap.R(1)
@@ -0,0 +1,452 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assemblestate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+)
+
+// DefaultMemberPort is the TCP port on which the devices of an assembled
+// cluster exchange messages with each other.
+const DefaultMemberPort = 7417
+
+// maxMemberIdentitySize bounds the size of the identity sent by a peer
+// before it is authenticated.
+const maxMemberIdentitySize = 64 * 1024
+
+// MemberIdentity proves that a TLS certificate is used by a device of an
+// assembled cluster. The devices of a cluster assertion are identified by
+// their serial, so the fingerprint of the certificate is signed with the
+// device key from the serial assertion.
+type MemberIdentity struct {
+	// SerialBundle is the bundle of assertions required to validate this
+	// device's serial assertion, including the serial assertion itself.
+	SerialBundle string `json:"serial-bundle"`
+
+	// Proof is the fingerprint of the TLS certificate used by this device,
+	// signed by this device's private key.
+	Proof Proof `json:"proof"`
+}
+
+func memberProofData(fp Fingerprint) []byte {
+	// prefix the fingerprint so that the signature cannot be mistaken for
+	// the serial proof of the assemble protocol
+	return append([]byte("snapd-cluster-member:"), fp[:]...)
+}
+
+// NewMemberIdentity returns the identity of a device which uses the given TLS
+// certificate. The signer must use the private key that matches the public
+// key embedded in the serial assertion of the bundle.
+func NewMemberIdentity(cert tls.Certificate, serialBundle string, signer func([]byte) ([]byte, error)) (MemberIdentity, error) {
+	if len(cert.Certificate) == 0 {
+		return MemberIdentity{}, errors.New("certificate is empty")
+	}
+	proof, err := signer(memberProofData(CalculateFP(cert.Certificate[0])))
+	if err != nil {
+		return MemberIdentity{}, fmt.Errorf("cannot sign certificate fingerprint: %v", err)
+	}
+	return MemberIdentity{
+		SerialBundle: serialBundle,
+		Proof:        proof,
+	}, nil
+}
+
+// VerifyMemberIdentity checks the serial assertion of the identity against the
+// database, and that the identity proves that the TLS certificate with the
+// given fingerprint is used by the device of that serial assertion.
+func VerifyMemberIdentity(id MemberIdentity, fp Fingerprint, db asserts.RODatabase) (*asserts.Serial, error) {
+	serial, err := verifySerialBundle(id.SerialBundle, db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid serial bundle: %w", err)
+	}
+	if len(id.Proof) == 0 {
+		return nil, errors.New("empty proof")
+	}
+	if err := asserts.RawVerifyWithKey(memberProofData(fp), id.Proof, serial.DeviceKey()); err != nil {
+		return nil, fmt.Errorf("proof verification failed: %w", err)
+	}
+	return serial, nil
+}
+
+// SerialBundle returns the given serial assertion along with the assertions
+// from the database that are required to validate it, as expected in
+// [MemberIdentity].
+func SerialBundle(serial *asserts.Serial, db asserts.RODatabase) (string, error) {
+	return buildSerialBundle(serial, db)
+}
+
+// NewMemberCertificate generates a self-signed TLS certificate for exchanging
+// messages with the other devices of a cluster. Peers never rely on the
+// certificate itself, only on the [MemberIdentity] proving who uses it.
+func NewMemberCertificate() (tls.Certificate, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "snapd cluster member"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(100, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, pub, priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  priv,
+	}, nil
+}
+
+// MemberAuthenticator enables the devices of a cluster to authenticate each
+// other.
+type MemberAuthenticator interface {
+	// AuthenticateMember checks that the given identity proves that the TLS
+	// certificate with the given fingerprint is used by a device of the
+	// cluster, see [VerifyMemberIdentity]. It returns the ID of that device
+	// in the cluster assertion.
+	AuthenticateMember(id MemberIdentity, fp Fingerprint) (int, error)
+}
+
+// MemberHandler handles a message sent by the device of the cluster with the
+// given ID in the cluster assertion.
+type MemberHandler func(w http.ResponseWriter, r *http.Request, device int)
+
+// MemberServer serves the messages sent by the other devices of an assembled
+// cluster, over HTTPS with mutual TLS authentication like [HTTPSTransport].
+//
+// Peers first post their [MemberIdentity] to /cluster/hello, and get the
+// identity of this device in return. Other messages are only handled once
+// the certificate of the peer was proven to belong to a device of the
+// cluster, which is checked again for every message so that devices that
+// leave the cluster are no longer served.
+type MemberServer struct {
+	listener net.Listener
+	http     *http.Server
+	done     chan struct{}
+
+	self    MemberIdentity
+	members MemberAuthenticator
+
+	// lock protects the fields below.
+	lock sync.Mutex
+	// identities keeps track of the identities that the peers proved to
+	// have, by certificate fingerprint.
+	identities map[Fingerprint]MemberIdentity
+}
+
+// StartMemberServer starts serving the messages of the other devices of the
+// cluster on the given address, using the given certificate and identity.
+// Each handler serves the messages posted to /cluster/<kind>, by kind.
+func StartMemberServer(addr string, cert tls.Certificate, self MemberIdentity, members MemberAuthenticator, handlers map[string]MemberHandler) (*MemberServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MemberServer{
+		listener:   ln,
+		done:       make(chan struct{}),
+		self:       self,
+		members:    members,
+		identities: make(map[Fingerprint]MemberIdentity),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cluster/hello", s.handleHello)
+	for kind, h := range handlers {
+		mux.Handle("/cluster/"+kind, s.memberHandler(h))
+	}
+
+	s.http = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          log.New(io.Discard, "", 0),
+	}
+
+	listener := tls.NewListener(ln, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+
+		// we support TLS 1.2 as the minimum version. this aligns with the
+		// configuration set in httputil.NewHTTPClient.
+		MinVersion: tls.VersionTLS12,
+	})
+
+	go func() {
+		defer close(s.done)
+		// serve always returns a non-nil error, nothing to handle here
+		_ = s.http.Serve(listener)
+	}()
+
+	return s, nil
+}
+
+// Addr returns the address on which messages are served.
+func (s *MemberServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop stops serving messages.
+func (s *MemberServer) Stop() error {
+	err := s.http.Close()
+	<-s.done
+	return err
+}
+
+func peerFingerprint(r *http.Request) (Fingerprint, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) != 1 {
+		return Fingerprint{}, false
+	}
+	return CalculateFP(r.TLS.PeerCertificates[0].Raw), true
+}
+
+func (s *MemberServer) handleHello(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		return
+	}
+
+	fp, ok := peerFingerprint(r)
+	if !ok {
+		w.WriteHeader(403)
+		return
+	}
+
+	var id MemberIdentity
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMemberIdentitySize)).Decode(&id); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	if _, err := s.members.AuthenticateMember(id, fp); err != nil {
+		logger.Debugf("cannot authenticate cluster member: %v", err)
+		w.WriteHeader(403)
+		return
+	}
+
+	s.lock.Lock()
+	s.identities[fp] = id
+	s.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.self)
+}
+
+func (s *MemberServer) memberHandler(next MemberHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fp, ok := peerFingerprint(r)
+		if !ok {
+			w.WriteHeader(403)
+			return
+		}
+
+		s.lock.Lock()
+		id, ok := s.identities[fp]
+		s.lock.Unlock()
+		if !ok {
+			logger.Debug("dropping message from unknown peer")
+			w.WriteHeader(403)
+			return
+		}
+
+		device, err := s.members.AuthenticateMember(id, fp)
+		if err != nil {
+			logger.Debugf("dropping message from peer that is no longer a cluster member: %v", err)
+			s.lock.Lock()
+			delete(s.identities, fp)
+			s.lock.Unlock()
+			w.WriteHeader(403)
+			return
+		}
+
+		next(w, r, device)
+	}
+}
+
+// MemberClient sends messages to the other devices of an assembled cluster,
+// see [MemberServer].
+type MemberClient struct {
+	cert    tls.Certificate
+	self    MemberIdentity
+	members MemberAuthenticator
+}
+
+// NewMemberClient returns a client which uses the given certificate and
+// identity to send messages to the other devices of the cluster.
+func NewMemberClient(cert tls.Certificate, self MemberIdentity, members MemberAuthenticator) *MemberClient {
+	return &MemberClient{
+		cert:    cert,
+		self:    self,
+		members: members,
+	}
+}
+
+// Do sends a message of the given kind to the device with the given ID in
+// the cluster assertion, at the given "host:port" address. The message is
+// only sent once the peer proved to be that device. The body may be nil.
+//
+// The caller must close the body of the returned response, whatever its
+// status code.
+func (c *MemberClient) Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error) {
+	var pinned *Fingerprint
+	verify := func(certs [][]byte, chains [][]*x509.Certificate) error {
+		if len(certs) != 1 {
+			return fmt.Errorf("exactly one peer certificate expected, got %d", len(certs))
+		}
+		if pinned != nil && CalculateFP(certs[0]) != *pinned {
+			return errors.New("refusing to communicate with unexpected peer certificate")
+		}
+		return nil
+	}
+
+	// the content of messages, such as blobs, can be large. callers bound
+	// the time to send them with the given context.
+	client := httputil.NewHTTPClient(&httputil.ClientOptions{
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: verify,
+			Certificates:          []tls.Certificate{c.cert},
+		},
+	})
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return errors.New("redirects are not expected")
+	}
+
+	fp, err := c.hello(ctx, client, addr, device)
+	if err != nil {
+		client.CloseIdleConnections()
+		return nil, err
+	}
+	pinned = &fp
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("https://%s/cluster/%s", addr, kind), r)
+	if err != nil {
+		client.CloseIdleConnections()
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		client.CloseIdleConnections()
+		return nil, err
+	}
+	res.Body = &closeIdleBody{ReadCloser: res.Body, client: client}
+	return res, nil
+}
+
+// hello exchanges identities with the peer at the given address, and returns
+// the fingerprint of its certificate once it proved to be the expected
+// device.
+func (c *MemberClient) hello(ctx context.Context, client *http.Client, addr string, device int) (Fingerprint, error) {
+	payload, err := json.Marshal(c.self)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://%s/cluster/hello", addr), bytes.NewReader(payload))
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return Fingerprint{}, fmt.Errorf("got non-200 status code in response to hello message: %d", res.StatusCode)
+	}
+
+	// this should not be possible since we specify https in the URL and disable
+	// redirects
+	if res.TLS == nil {
+		return Fingerprint{}, errors.New("peer attempting to communicate over unencrypted connection")
+	}
+
+	if len(res.TLS.PeerCertificates) != 1 {
+		return Fingerprint{}, fmt.Errorf("exactly one peer certificate expected, got %d", len(res.TLS.PeerCertificates))
+	}
+	fp := CalculateFP(res.TLS.PeerCertificates[0].Raw)
+
+	var id MemberIdentity
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxMemberIdentitySize)).Decode(&id); err != nil {
+		return Fingerprint{}, fmt.Errorf("cannot decode peer identity: %v", err)
+	}
+
+	got, err := c.members.AuthenticateMember(id, fp)
+	if err != nil {
+		return Fingerprint{}, fmt.Errorf("cannot authenticate peer: %w", err)
+	}
+	if got != device {
+		return Fingerprint{}, fmt.Errorf("peer is device %d, expected device %d", got, device)
+	}
+
+	return fp, nil
+}
+
+type closeIdleBody struct {
+	io.ReadCloser
+	client *http.Client
+}
+
+func (b *closeIdleBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.client.CloseIdleConnections()
+	return err
+}
@@ -0,0 +1,300 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assemblestate_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+)
+
+type membersSuite struct{}
+
+var _ = check.Suite(&membersSuite{})
+
+// fakeMembers authenticates the devices whose serial is listed, by device ID.
+type fakeMembers struct {
+	db asserts.RODatabase
+
+	lock    sync.Mutex
+	serials map[string]int
+}
+
+func (m *fakeMembers) AuthenticateMember(id assemblestate.MemberIdentity, fp assemblestate.Fingerprint) (int, error) {
+	serial, err := assemblestate.VerifyMemberIdentity(id, fp, m.db)
+	if err != nil {
+		return 0, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	device, ok := m.serials[serial.Serial()]
+	if !ok {
+		return 0, fmt.Errorf("device %s is not a member", serial.Serial())
+	}
+	return device, nil
+}
+
+func (m *fakeMembers) remove(serial string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.serials, serial)
+}
+
+type testMember struct {
+	serial *asserts.Serial
+	cert   tls.Certificate
+	id     assemblestate.MemberIdentity
+}
+
+func createTestMember(c *check.C, signing asserts.RODatabase, serial *asserts.Serial, key asserts.PrivateKey) testMember {
+	cert, err := assemblestate.NewMemberCertificate()
+	c.Assert(err, check.IsNil)
+	bundle, err := assemblestate.SerialBundle(serial, signing)
+	c.Assert(err, check.IsNil)
+	id, err := assemblestate.NewMemberIdentity(cert, bundle, func(data []byte) ([]byte, error) {
+		return asserts.RawSignWithKey(data, key)
+	})
+	c.Assert(err, check.IsNil)
+	return testMember{serial: serial, cert: cert, id: id}
+}
+
+func (s *membersSuite) TestMemberIdentity(c *check.C) {
+	db, signing := mockAssertDB(c)
+	serial, key := createTestSerial(c, signing)
+	m := createTestMember(c, signing.Database, serial, key)
+	fp := assemblestate.CalculateFP(m.cert.Certificate[0])
+
+	verified, err := assemblestate.VerifyMemberIdentity(m.id, fp, db)
+	c.Assert(err, check.IsNil)
+	c.Check(verified.Serial(), check.Equals, serial.Serial())
+
+	// the identity only proves the use of its own certificate
+	other, err := assemblestate.NewMemberCertificate()
+	c.Assert(err, check.IsNil)
+	_, err = assemblestate.VerifyMemberIdentity(m.id, assemblestate.CalculateFP(other.Certificate[0]), db)
+	c.Check(err, check.ErrorMatches, "proof verification failed: .*")
+
+	// the certificate must be signed with the device key of the serial
+	otherSerial, _ := createTestSerial(c, signing)
+	bundle, err := assemblestate.SerialBundle(otherSerial, signing.Database)
+	c.Assert(err, check.IsNil)
+	_, err = assemblestate.VerifyMemberIdentity(assemblestate.MemberIdentity{
+		SerialBundle: bundle,
+		Proof:        m.id.Proof,
+	}, fp, db)
+	c.Check(err, check.ErrorMatches, "proof verification failed: .*")
+
+	_, err = assemblestate.VerifyMemberIdentity(assemblestate.MemberIdentity{
+		SerialBundle: m.id.SerialBundle,
+	}, fp, db)
+	c.Check(err, check.ErrorMatches, "empty proof")
+
+	_, err = assemblestate.VerifyMemberIdentity(assemblestate.MemberIdentity{
+		Proof: m.id.Proof,
+	}, fp, db)
+	c.Check(err, check.ErrorMatches, "invalid serial bundle: serial bundle is empty")
+
+	_, err = assemblestate.NewMemberIdentity(m.cert, m.id.SerialBundle, func([]byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	c.Check(err, check.ErrorMatches, "cannot sign certificate fingerprint: boom")
+}
+
+type membersFixture struct {
+	members *fakeMembers
+	server  testMember
+	client  testMember
+	other   testMember
+}
+
+func newMembersFixture(c *check.C) *membersFixture {
+	db, signing := mockAssertDB(c)
+	var ms []testMember
+	for i := 0; i < 3; i++ {
+		serial, key := createTestSerial(c, signing)
+		ms = append(ms, createTestMember(c, signing.Database, serial, key))
+	}
+	return &membersFixture{
+		members: &fakeMembers{
+			db: db,
+			serials: map[string]int{
+				ms[0].serial.Serial(): 1,
+				ms[1].serial.Serial(): 2,
+			},
+		},
+		server: ms[0],
+		client: ms[1],
+		// not a member of the cluster
+		other: ms[2],
+	}
+}
+
+func (f *membersFixture) start(c *check.C) *assemblestate.MemberServer {
+	echo := func(w http.ResponseWriter, r *http.Request, device int) {
+		body, err := io.ReadAll(r.Body)
+		c.Assert(err, check.IsNil)
+		fmt.Fprintf(w, "%s from device %d: %s", r.Method, device, body)
+	}
+	server, err := assemblestate.StartMemberServer("127.0.0.1:0", f.server.cert, f.server.id, f.members, map[string]assemblestate.MemberHandler{
+		"echo": echo,
+	})
+	c.Assert(err, check.IsNil)
+	return server
+}
+
+func readResponse(c *check.C, res *http.Response) (int, string) {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	c.Assert(err, check.IsNil)
+	return res.StatusCode, string(body)
+}
+
+func (s *membersSuite) TestMemberClientServer(c *check.C) {
+	f := newMembersFixture(c)
+	server := f.start(c)
+	defer func() {
+		c.Check(server.Stop(), check.IsNil)
+	}()
+
+	client := assemblestate.NewMemberClient(f.client.cert, f.client.id, f.members)
+	res, err := client.Do(context.Background(), server.Addr().String(), 1, "POST", "echo", []byte(`{"a":1}`))
+	c.Assert(err, check.IsNil)
+	status, body := readResponse(c, res)
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.Equals, `POST from device 2: {"a":1}`)
+
+	res, err = client.Do(context.Background(), server.Addr().String(), 1, "GET", "echo", nil)
+	c.Assert(err, check.IsNil)
+	status, body = readResponse(c, res)
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.Equals, "GET from device 2: ")
+
+	res, err = client.Do(context.Background(), server.Addr().String(), 1, "GET", "unknown", nil)
+	c.Assert(err, check.IsNil)
+	status, _ = readResponse(c, res)
+	c.Check(status, check.Equals, 404)
+}
+
+func (s *membersSuite) TestMemberClientUnexpectedDevice(c *check.C) {
+	f := newMembersFixture(c)
+	server := f.start(c)
+	defer server.Stop()
+
+	client := assemblestate.NewMemberClient(f.client.cert, f.client.id, f.members)
+	_, err := client.Do(context.Background(), server.Addr().String(), 3, "GET", "echo", nil)
+	c.Check(err, check.ErrorMatches, "peer is device 1, expected device 3")
+
+	// the server must be a member of the cluster as well
+	f.members.remove(f.server.serial.Serial())
+	_, err = client.Do(context.Background(), server.Addr().String(), 1, "GET", "echo", nil)
+	c.Check(err, check.ErrorMatches, "cannot authenticate peer: device .* is not a member")
+}
+
+func (s *membersSuite) TestMemberServerRejectsNonMembers(c *check.C) {
+	f := newMembersFixture(c)
+	server := f.start(c)
+	defer server.Stop()
+
+	client := assemblestate.NewMemberClient(f.other.cert, f.other.id, f.members)
+	_, err := client.Do(context.Background(), server.Addr().String(), 1, "GET", "echo", nil)
+	c.Check(err, check.ErrorMatches, "got non-200 status code in response to hello message: 403")
+
+	// a member cannot lend its identity to another certificate
+	client = assemblestate.NewMemberClient(f.other.cert, f.client.id, f.members)
+	_, err = client.Do(context.Background(), server.Addr().String(), 1, "GET", "echo", nil)
+	c.Check(err, check.ErrorMatches, "got non-200 status code in response to hello message: 403")
+}
+
+func (s *membersSuite) TestMemberServerRequiresHello(c *check.C) {
+	f := newMembersFixture(c)
+	server := f.start(c)
+	defer server.Stop()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				Certificates:       []tls.Certificate{f.client.cert},
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	res, err := client.Post(fmt.Sprintf("https://%s/cluster/echo", server.Addr()), "application/json", bytes.NewReader(nil))
+	c.Assert(err, check.IsNil)
+	status, _ := readResponse(c, res)
+	c.Check(status, check.Equals, 403)
+
+	res, err = client.Post(fmt.Sprintf("https://%s/cluster/hello", server.Addr()), "application/json", bytes.NewReader([]byte("garbage")))
+	c.Assert(err, check.IsNil)
+	status, _ = readResponse(c, res)
+	c.Check(status, check.Equals, 400)
+
+	res, err = client.Get(fmt.Sprintf("https://%s/cluster/hello", server.Addr()))
+	c.Assert(err, check.IsNil)
+	status, _ = readResponse(c, res)
+	c.Check(status, check.Equals, 405)
+}
+
+func (s *membersSuite) TestMemberServerDropsFormerMembers(c *check.C) {
+	f := newMembersFixture(c)
+	server := f.start(c)
+	defer server.Stop()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				Certificates:       []tls.Certificate{f.client.cert},
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	hello, err := json.Marshal(f.client.id)
+	c.Assert(err, check.IsNil)
+	res, err := client.Post(fmt.Sprintf("https://%s/cluster/hello", server.Addr()), "application/json", bytes.NewReader(hello))
+	c.Assert(err, check.IsNil)
+	status, _ := readResponse(c, res)
+	c.Check(status, check.Equals, 200)
+
+	res, err = client.Post(fmt.Sprintf("https://%s/cluster/echo", server.Addr()), "application/json", nil)
+	c.Assert(err, check.IsNil)
+	status, _ = readResponse(c, res)
+	c.Check(status, check.Equals, 200)
+
+	// membership is checked for every message, not only when saying hello
+	f.members.remove(f.client.serial.Serial())
+	res, err = client.Post(fmt.Sprintf("https://%s/cluster/echo", server.Addr()), "application/json", nil)
+	c.Assert(err, check.IsNil)
+	status, _ = readResponse(c, res)
+	c.Check(status, check.Equals, 403)
+}
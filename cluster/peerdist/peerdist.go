@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package peerdist implements the distribution of snap and component blobs
+// between the devices of a cluster.
+//
+// Devices serve the blobs in their downloads cache, keyed by sha3-384 digest,
+// to the other devices of the cluster over the authenticated transport of
+// cluster/assemblestate, so that blobs are only ever exchanged between devices
+// listed in the cluster assertion. Blobs fetched from peers are never
+// trusted: they are verified by the store against the digest from the
+// snap-revision assertion, and the store is used as a fallback when no peer
+// has a blob.
+package peerdist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/logger"
+)
+
+// BlobsKind is the kind of the messages, as handled by an
+// assemblestate.MemberServer, which request blobs by digest.
+const BlobsKind = "blobs/"
+
+const blobsPath = "/cluster/" + BlobsKind
+
+var validDigest = regexp.MustCompile("^[0-9a-f]{96}$")
+
+// BlobSource provides the blobs which are served to peers.
+type BlobSource interface {
+	// OpenCachedBlob opens the blob with the given sha3-384 digest. Returns
+	// an error satisfying errors.Is(err, fs.ErrNotExist) if there is no such
+	// blob.
+	OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error)
+}
+
+// NewHandler returns a handler which serves the blobs from the given source
+// to the other devices of the cluster.
+func NewHandler(src BlobSource) assemblestate.MemberHandler {
+	return func(w http.ResponseWriter, r *http.Request, device int) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, blobsPath) {
+			http.NotFound(w, r)
+			return
+		}
+		digest := strings.TrimPrefix(r.URL.Path, blobsPath)
+		if !validDigest.MatchString(digest) {
+			http.Error(w, "invalid sha3-384 digest", http.StatusBadRequest)
+			return
+		}
+
+		f, _, err := src.OpenCachedBlob(digest)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logger.Noticef("cannot open blob %s for device %d: %v", digest, device, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		// the content of a blob never changes, so the modification time is
+		// irrelevant
+		http.ServeContent(w, r, "", time.Time{}, f)
+	}
+}
+
+// Client sends messages to the other devices of the cluster, see
+// assemblestate.MemberClient.
+type Client interface {
+	Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error)
+}
+
+var _ Client = (*assemblestate.MemberClient)(nil)
+
+// Peer is another device of the cluster which blobs can be fetched from.
+type Peer struct {
+	// Device is the ID of the device in the cluster assertion.
+	Device int
+	// Addresses are the "host:port" addresses the device can be reached at.
+	Addresses []string
+}
+
+// Source fetches blobs from the other devices of the cluster, and is meant to
+// be used by the store as its source of blobs from peers.
+type Source struct {
+	client Client
+
+	mu    sync.Mutex
+	peers []Peer
+}
+
+// NewSource returns a source which fetches blobs from peers with the given
+// client.
+func NewSource(client Client) *Source {
+	return &Source{client: client}
+}
+
+// SetPeers sets the devices which blobs are fetched from, in order of
+// preference.
+func (s *Source) SetPeers(peers []Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = peers
+}
+
+func (s *Source) currentPeers() []Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peers
+}
+
+// OpenBlob returns the content of the blob with the given sha3-384 digest
+// from the first peer which has it, along with its size. The content is not
+// verified, and must be checked against the digest by the caller.
+func (s *Source) OpenBlob(ctx context.Context, sha3_384 string) (io.ReadCloser, int64, error) {
+	if !validDigest.MatchString(sha3_384) {
+		return nil, 0, fmt.Errorf("invalid sha3-384 digest %q", sha3_384)
+	}
+	for _, peer := range s.currentPeers() {
+		for _, addr := range peer.Addresses {
+			r, size, err := s.openBlobFrom(ctx, addr, peer.Device, sha3_384)
+			if err != nil {
+				logger.Debugf("cannot fetch blob %s from device %d at %s: %v", sha3_384, peer.Device, addr, err)
+				continue
+			}
+			return r, size, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("no peer has blob %s", sha3_384)
+}
+
+func (s *Source) openBlobFrom(ctx context.Context, addr string, device int, sha3_384 string) (io.ReadCloser, int64, error) {
+	resp, err := s.client.Do(ctx, addr, device, "GET", BlobsKind+sha3_384, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("missing content length")
+	}
+	return resp.Body, resp.ContentLength, nil
+}
@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peerdist_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cluster/peerdist"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type peerdistSuite struct{}
+
+var _ = check.Suite(&peerdistSuite{})
+
+var (
+	digest1 = strings.Repeat("a", 96)
+	digest2 = strings.Repeat("b", 96)
+)
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+type fakeBlobSource map[string]string
+
+func (f fakeBlobSource) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	content, ok := f[sha3_384]
+	if !ok {
+		return nil, 0, fs.ErrNotExist
+	}
+	if content == "error" {
+		return nil, 0, errors.New("boom")
+	}
+	return nopSeekCloser{strings.NewReader(content)}, int64(len(content)), nil
+}
+
+func serve(h func(http.ResponseWriter, *http.Request, int), method, path string) *http.Response {
+	req := httptest.NewRequest(method, path, nil)
+	rec := httptest.NewRecorder()
+	h(rec, req, 2)
+	return rec.Result()
+}
+
+func (s *peerdistSuite) TestHandler(c *check.C) {
+	h := peerdist.NewHandler(fakeBlobSource{digest1: "blob content", digest2: "error"})
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{"GET", "/cluster/blobs/" + digest1, 200, "blob content"},
+		{"HEAD", "/cluster/blobs/" + digest1, 200, ""},
+		{"GET", "/cluster/blobs/" + strings.Repeat("c", 96), 404, "404 page not found\n"},
+		{"GET", "/cluster/blobs/" + digest2, 500, "internal error\n"},
+		{"GET", "/cluster/blobs/abc", 400, "invalid sha3-384 digest\n"},
+		{"GET", "/cluster/blobs/" + strings.Repeat("A", 96), 400, "invalid sha3-384 digest\n"},
+		{"GET", "/cluster/other", 404, "404 page not found\n"},
+		{"POST", "/cluster/blobs/" + digest1, 405, "method not allowed\n"},
+	} {
+		cmt := check.Commentf("%s %s", tc.method, tc.path)
+		resp := serve(h, tc.method, tc.path)
+		body, err := io.ReadAll(resp.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(resp.StatusCode, check.Equals, tc.status, cmt)
+		if tc.method != "HEAD" {
+			c.Check(string(body), check.Equals, tc.body, cmt)
+		}
+		if tc.status == 200 {
+			c.Check(resp.Header.Get("Content-Length"), check.Equals, fmt.Sprint(len("blob content")), cmt)
+		}
+	}
+}
+
+// fakeClient serves the messages sent to each address with the handler of
+// the device found there.
+type fakeClient struct {
+	devices  map[string]int
+	handlers map[string]func(http.ResponseWriter, *http.Request, int)
+	calls    []string
+}
+
+func (f *fakeClient) Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error) {
+	f.calls = append(f.calls, fmt.Sprintf("%s %d %s", addr, device, kind))
+	if f.devices[addr] != device {
+		return nil, fmt.Errorf("peer is device %d, expected device %d", f.devices[addr], device)
+	}
+	h, ok := f.handlers[addr]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	resp := serve(h, method, "/cluster/"+kind)
+	resp.ContentLength = -1
+	if l := resp.Header.Get("Content-Length"); l != "" {
+		fmt.Sscan(l, &resp.ContentLength)
+	}
+	return resp, nil
+}
+
+func (s *peerdistSuite) TestSourceOpenBlob(c *check.C) {
+	client := &fakeClient{
+		devices: map[string]int{
+			"10.0.0.1:7417": 1,
+			"10.0.0.2:7417": 2,
+			"10.0.1.2:7417": 2,
+		},
+		handlers: map[string]func(http.ResponseWriter, *http.Request, int){
+			"10.0.0.1:7417": peerdist.NewHandler(fakeBlobSource{}),
+			"10.0.1.2:7417": peerdist.NewHandler(fakeBlobSource{digest1: "blob content"}),
+		},
+	}
+	src := peerdist.NewSource(client)
+
+	_, _, err := src.OpenBlob(context.Background(), digest1)
+	c.Check(err, check.ErrorMatches, "no peer has blob "+digest1)
+	c.Check(client.calls, check.HasLen, 0)
+
+	src.SetPeers([]peerdist.Peer{
+		{Device: 1, Addresses: []string{"10.0.0.1:7417"}},
+		{Device: 2, Addresses: []string{"10.0.0.2:7417", "10.0.1.2:7417"}},
+	})
+
+	r, size, err := src.OpenBlob(context.Background(), digest1)
+	c.Assert(err, check.IsNil)
+	defer r.Close()
+	c.Check(size, check.Equals, int64(len("blob content")))
+	data, err := io.ReadAll(r)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Equals, "blob content")
+	c.Check(client.calls, check.DeepEquals, []string{
+		"10.0.0.1:7417 1 blobs/" + digest1,
+		"10.0.0.2:7417 2 blobs/" + digest1,
+		"10.0.1.2:7417 2 blobs/" + digest1,
+	})
+
+	_, _, err = src.OpenBlob(context.Background(), digest2)
+	c.Check(err, check.ErrorMatches, "no peer has blob "+digest2)
+}
+
+func (s *peerdistSuite) TestSourceOpenBlobUnexpectedDevice(c *check.C) {
+	client := &fakeClient{
+		devices: map[string]int{"10.0.0.1:7417": 3},
+		handlers: map[string]func(http.ResponseWriter, *http.Request, int){
+			"10.0.0.1:7417": peerdist.NewHandler(fakeBlobSource{digest1: "blob content"}),
+		},
+	}
+	src := peerdist.NewSource(client)
+	src.SetPeers([]peerdist.Peer{{Device: 1, Addresses: []string{"10.0.0.1:7417"}}})
+
+	_, _, err := src.OpenBlob(context.Background(), digest1)
+	c.Check(err, check.ErrorMatches, "no peer has blob "+digest1)
+}
+
+func (s *peerdistSuite) TestSourceOpenBlobInvalidDigest(c *check.C) {
+	client := &fakeClient{}
+	src := peerdist.NewSource(client)
+	src.SetPeers([]peerdist.Peer{{Device: 1, Addresses: []string{"10.0.0.1:7417"}}})
+
+	_, _, err := src.OpenBlob(context.Background(), "../../etc/passwd")
+	c.Check(err, check.ErrorMatches, `invalid sha3-384 digest "../../etc/passwd"`)
+	c.Check(client.calls, check.HasLen, 0)
+}
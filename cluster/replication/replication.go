@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package replication implements the propagation of confdb databags between
+// the devices of a cluster.
+//
+// Each device pushes the databags of the replicated confdb-schemas to the
+// other devices, at the addresses listed for them in the cluster assertion,
+// whenever they are not known to hold its latest version. Updates are sent
+// over the authenticated transport of cluster/assemblestate, so that the
+// receiving device knows which device of the cluster they come from.
+//
+// Conflicting updates are resolved by keeping the databag with the newest
+// version, see Version.Newer.
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/logger"
+)
+
+// UpdatesKind is the kind of the messages, as handled by an
+// assemblestate.MemberServer, which carry updates of databags.
+const UpdatesKind = "confdb/updates"
+
+const updatesPath = "/cluster/" + UpdatesKind
+
+// maxMessageSize bounds the size of the messages exchanged between devices.
+const maxMessageSize = 4 * 1024 * 1024
+
+var (
+	// ErrBusy is returned when the databag cannot be updated right now, the
+	// update should be sent again later.
+	ErrBusy = errors.New("databag is busy")
+	// ErrNotAuthorized is returned when the device sending the update is not
+	// allowed to update the databag.
+	ErrNotAuthorized = errors.New("device is not authorized")
+	// ErrRejected is returned when the device an update was pushed to
+	// refused it.
+	ErrRejected = errors.New("device rejected update")
+)
+
+// Version orders the versions of a databag across the devices of a cluster.
+// Each device increments the counter past the one of the latest version it
+// knows when it commits a change to the databag, so versions are ordered by
+// their counter first and by the ID of the device which made the change
+// second.
+type Version struct {
+	Counter uint64 `json:"counter"`
+	// Device is the ID, in the cluster assertion, of the device which made
+	// the change.
+	Device int `json:"device"`
+}
+
+// IsZero returns whether the version is unset.
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Newer returns whether v supersedes the other version.
+func (v Version) Newer(other Version) bool {
+	if v.Counter != other.Counter {
+		return v.Counter > other.Counter
+	}
+	return v.Device > other.Device
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d/%d", v.Counter, v.Device)
+}
+
+// Update carries the data of a databag at a given version.
+type Update struct {
+	ClusterID string  `json:"cluster-id"`
+	Account   string  `json:"account"`
+	Schema    string  `json:"schema"`
+	Version   Version `json:"version"`
+	// Data is the JSON encoded databag.
+	Data json.RawMessage `json:"data"`
+}
+
+// Ack is the answer of a device to an update.
+type Ack struct {
+	// Version is the version of the databag held by the device after
+	// handling the update, which is newer than the update if the device
+	// already held a newer one.
+	Version Version `json:"version"`
+}
+
+// Receiver applies the updates received from other devices.
+type Receiver interface {
+	// Receive applies the update sent by the given device, identified by
+	// its ID in the cluster assertion. It returns ErrNotAuthorized if the
+	// device is not allowed to send the update and ErrBusy if it should be
+	// sent again later.
+	Receive(device int, u *Update) (Ack, error)
+}
+
+// NewHandler returns a handler which passes the updates sent by the other
+// devices of the cluster to the given receiver.
+func NewHandler(recv Receiver) assemblestate.MemberHandler {
+	return func(w http.ResponseWriter, r *http.Request, device int) {
+		if r.URL.Path != updatesPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var u Update
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize))
+		if err := dec.Decode(&u); err != nil {
+			http.Error(w, "cannot decode update", http.StatusBadRequest)
+			return
+		}
+
+		ack, err := recv.Receive(device, &u)
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, ErrNotAuthorized):
+				status = http.StatusForbidden
+			case errors.Is(err, ErrBusy):
+				status = http.StatusServiceUnavailable
+			}
+			logger.Debugf("cannot apply replicated databag from device %d: %v", device, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ack)
+	}
+}
+
+// Client sends messages to the other devices of the cluster, see
+// assemblestate.MemberClient.
+type Client interface {
+	Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error)
+}
+
+var _ Client = (*assemblestate.MemberClient)(nil)
+
+// Push sends the update to the given device, at the given "host:port"
+// address. It returns ErrBusy if the device asked for the update to be sent
+// again later, and an error wrapping ErrRejected if the device refused it.
+func Push(ctx context.Context, client Client, addr string, device int, u *Update) (Ack, error) {
+	body, err := json.Marshal(u)
+	if err != nil {
+		return Ack{}, err
+	}
+	resp, err := client.Do(ctx, addr, device, "POST", UpdatesKind, body)
+	if err != nil {
+		return Ack{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return Ack{}, ErrBusy
+		}
+		return Ack{}, fmt.Errorf("%w: %s", ErrRejected, strings.TrimSpace(string(msg)))
+	}
+
+	var ack Ack
+	dec := json.NewDecoder(io.LimitReader(resp.Body, maxMessageSize))
+	if err := dec.Decode(&ack); err != nil {
+		return Ack{}, fmt.Errorf("cannot decode acknowledgement: %w", err)
+	}
+	return ack, nil
+}
@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package replication_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cluster/replication"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type replicationSuite struct{}
+
+var _ = check.Suite(&replicationSuite{})
+
+var testUpdate = replication.Update{
+	ClusterID: "cluster-id",
+	Account:   "acc",
+	Schema:    "network",
+	Version:   replication.Version{Counter: 3, Device: 2},
+	Data:      []byte(`{"wifi":{"ssid":"foo"}}`),
+}
+
+func (s *replicationSuite) TestVersionNewer(c *check.C) {
+	v := func(counter uint64, device int) replication.Version {
+		return replication.Version{Counter: counter, Device: device}
+	}
+
+	c.Check(v(2, 1).Newer(v(1, 3)), check.Equals, true)
+	c.Check(v(1, 3).Newer(v(2, 1)), check.Equals, false)
+	// concurrent changes are ordered by device ID
+	c.Check(v(2, 3).Newer(v(2, 1)), check.Equals, true)
+	c.Check(v(2, 1).Newer(v(2, 3)), check.Equals, false)
+	c.Check(v(2, 1).Newer(v(2, 1)), check.Equals, false)
+	c.Check(v(1, 1).Newer(replication.Version{}), check.Equals, true)
+
+	c.Check(replication.Version{}.IsZero(), check.Equals, true)
+	c.Check(v(0, 1).IsZero(), check.Equals, false)
+	c.Check(v(3, 2).String(), check.Equals, "3/2")
+}
+
+type fakeReceiver struct {
+	devices  []int
+	received []*replication.Update
+	ack      replication.Ack
+	err      error
+}
+
+func (r *fakeReceiver) Receive(device int, u *replication.Update) (replication.Ack, error) {
+	r.devices = append(r.devices, device)
+	r.received = append(r.received, u)
+	return r.ack, r.err
+}
+
+func serve(h func(http.ResponseWriter, *http.Request, int), method, path string, body []byte) *http.Response {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	h(rec, req, 2)
+	return rec.Result()
+}
+
+func (s *replicationSuite) TestHandler(c *check.C) {
+	recv := &fakeReceiver{ack: replication.Ack{Version: replication.Version{Counter: 3, Device: 2}}}
+	h := replication.NewHandler(recv)
+
+	update := `{"cluster-id":"cluster-id","account":"acc","schema":"network","version":{"counter":3,"device":2},"data":{"wifi":{"ssid":"foo"}}}`
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		err    error
+		status int
+		resp   string
+	}{
+		{"POST", "/cluster/confdb/updates", update, nil, 200, `{"version":{"counter":3,"device":2}}` + "\n"},
+		{"POST", "/cluster/confdb/updates", update, replication.ErrNotAuthorized, 403, "device is not authorized\n"},
+		{"POST", "/cluster/confdb/updates", update, replication.ErrBusy, 503, "databag is busy\n"},
+		{"POST", "/cluster/confdb/updates", update, errors.New("invalid data"), 400, "invalid data\n"},
+		{"POST", "/cluster/confdb/updates", "not json", nil, 400, "cannot decode update\n"},
+		{"GET", "/cluster/confdb/updates", "", nil, 405, "method not allowed\n"},
+		{"POST", "/cluster/other", update, nil, 404, "404 page not found\n"},
+	} {
+		cmt := check.Commentf("%s %s %v", tc.method, tc.path, tc.err)
+		recv.err = tc.err
+		resp := serve(h, tc.method, tc.path, []byte(tc.body))
+		body, err := io.ReadAll(resp.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(resp.StatusCode, check.Equals, tc.status, cmt)
+		c.Check(string(body), check.Equals, tc.resp, cmt)
+	}
+
+	c.Assert(recv.received, check.HasLen, 4)
+	c.Check(recv.received[0], check.DeepEquals, &testUpdate)
+	// the update is attributed to the authenticated device
+	c.Check(recv.devices, check.DeepEquals, []int{2, 2, 2, 2})
+}
+
+// fakeClient serves the messages sent to the device at a single address with
+// the given handler.
+type fakeClient struct {
+	addr    string
+	device  int
+	handler func(http.ResponseWriter, *http.Request, int)
+	calls   []string
+}
+
+func (f *fakeClient) Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error) {
+	f.calls = append(f.calls, fmt.Sprintf("%s %s %d %s", method, addr, device, kind))
+	if addr != f.addr {
+		return nil, errors.New("connection refused")
+	}
+	if device != f.device {
+		return nil, fmt.Errorf("peer is device %d, expected device %d", f.device, device)
+	}
+	return serve(f.handler, method, "/cluster/"+kind, body), nil
+}
+
+func (s *replicationSuite) TestPush(c *check.C) {
+	recv := &fakeReceiver{ack: replication.Ack{Version: replication.Version{Counter: 4, Device: 1}}}
+	client := &fakeClient{
+		addr:    "10.0.0.1:7417",
+		device:  1,
+		handler: replication.NewHandler(recv),
+	}
+
+	u := testUpdate
+	ack, err := replication.Push(context.Background(), client, "10.0.0.1:7417", 1, &u)
+	c.Assert(err, check.IsNil)
+	c.Check(ack, check.Equals, recv.ack)
+	c.Check(recv.received, check.DeepEquals, []*replication.Update{&testUpdate})
+	c.Check(client.calls, check.DeepEquals, []string{"POST 10.0.0.1:7417 1 confdb/updates"})
+
+	recv.err = replication.ErrBusy
+	_, err = replication.Push(context.Background(), client, "10.0.0.1:7417", 1, &u)
+	c.Check(err, check.Equals, replication.ErrBusy)
+
+	recv.err = errors.New("cannot validate data")
+	_, err = replication.Push(context.Background(), client, "10.0.0.1:7417", 1, &u)
+	c.Check(err, check.ErrorMatches, "device rejected update: cannot validate data")
+	c.Check(errors.Is(err, replication.ErrRejected), check.Equals, true)
+
+	_, err = replication.Push(context.Background(), client, "10.0.0.1:7417", 2, &u)
+	c.Check(err, check.ErrorMatches, "peer is device 1, expected device 2")
+	c.Check(errors.Is(err, replication.ErrRejected), check.Equals, false)
+}
+
+func (s *replicationSuite) TestPushErrors(c *check.C) {
+	client := &fakeClient{
+		addr:   "10.0.0.1:7417",
+		device: 1,
+		handler: func(w http.ResponseWriter, r *http.Request, device int) {
+			io.WriteString(w, "not json")
+		},
+	}
+	u := testUpdate
+	_, err := replication.Push(context.Background(), client, "10.0.0.1:7417", 1, &u)
+	c.Check(err, check.ErrorMatches, "cannot decode acknowledgement: .*")
+	c.Check(errors.Is(err, replication.ErrRejected), check.Equals, false)
+
+	_, err = replication.Push(context.Background(), client, "10.0.0.2:7417", 1, &u)
+	c.Check(err, check.ErrorMatches, "connection refused")
+}
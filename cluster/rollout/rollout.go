@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package rollout implements the exchange of the progress of staged rollouts
+// of the state of a cluster between its devices.
+//
+// Each device serves reports of the progress of applying the state of its
+// subclusters to the other devices of the cluster, over the authenticated
+// transport of cluster/assemblestate. Devices which wait for others to apply
+// a new state first fetch the reports of those devices, and only trust the
+// reports of a device about itself.
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/logger"
+)
+
+// ReportsKind is the kind of the messages, as handled by an
+// assemblestate.MemberServer, which request the reports of a device.
+const ReportsKind = "rollout/reports"
+
+const reportsPath = "/cluster/" + ReportsKind
+
+// maxReportsSize bounds the size of the reports fetched from a device.
+const maxReportsSize = 1024 * 1024
+
+// Status is the progress of applying the state of a subcluster on a device.
+type Status string
+
+const (
+	// StatusWaiting is used while a device waits for other devices to
+	// apply the state first.
+	StatusWaiting Status = "waiting"
+	// StatusApplying is used while the state is being applied, up until
+	// the health of the snaps is known.
+	StatusApplying Status = "applying"
+	// StatusHealthy is used once the state was applied and the snaps
+	// reported being healthy.
+	StatusHealthy Status = "healthy"
+	// StatusFailed is used when the state could not be applied, or when
+	// the snaps reported being unhealthy after applying it.
+	StatusFailed Status = "failed"
+)
+
+// Report is the progress of applying the state of a subcluster, as described
+// by a specific cluster assertion, on a device.
+type Report struct {
+	ClusterID  string `json:"cluster-id"`
+	Sequence   int    `json:"sequence"`
+	Subcluster string `json:"subcluster"`
+	// Device is the ID of the device in the cluster assertion.
+	Device  int    `json:"device"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// ReportSource provides the reports which are served to other devices.
+type ReportSource interface {
+	Reports() ([]Report, error)
+}
+
+// NewHandler returns a handler which serves the reports from the given source
+// to the other devices of the cluster.
+func NewHandler(src ReportSource) assemblestate.MemberHandler {
+	return func(w http.ResponseWriter, r *http.Request, device int) {
+		if r.URL.Path != reportsPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		reports, err := src.Reports()
+		if err != nil {
+			logger.Noticef("cannot get rollout reports for device %d: %v", device, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if reports == nil {
+			reports = []Report{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+	}
+}
+
+// Client sends messages to the other devices of the cluster, see
+// assemblestate.MemberClient.
+type Client interface {
+	Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error)
+}
+
+var _ Client = (*assemblestate.MemberClient)(nil)
+
+// Fetch fetches the reports of the given device, at the given "host:port"
+// address. Only the reports of the device about itself are returned.
+func Fetch(ctx context.Context, client Client, addr string, device int) ([]Report, error) {
+	resp, err := client.Do(ctx, addr, device, "GET", ReportsKind, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+
+	var reports []Report
+	dec := json.NewDecoder(io.LimitReader(resp.Body, maxReportsSize))
+	if err := dec.Decode(&reports); err != nil {
+		return nil, fmt.Errorf("cannot decode rollout reports: %w", err)
+	}
+	own := reports[:0]
+	for _, r := range reports {
+		if r.Device == device {
+			own = append(own, r)
+		}
+	}
+	return own, nil
+}
+
+// Find returns the report about the given device in reports, for the given
+// cluster assertion and subcluster, or nil if there is none.
+func Find(reports []Report, clusterID string, sequence int, subcluster string, device int) *Report {
+	for i := range reports {
+		r := &reports[i]
+		if r.ClusterID == clusterID && r.Sequence == sequence && r.Subcluster == subcluster && r.Device == device {
+			return r
+		}
+	}
+	return nil
+}
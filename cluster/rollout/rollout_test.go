@@ -0,0 +1,187 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package rollout_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cluster/rollout"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type rolloutSuite struct{}
+
+var _ = check.Suite(&rolloutSuite{})
+
+type fakeReportSource struct {
+	reports []rollout.Report
+	err     error
+}
+
+func (f *fakeReportSource) Reports() ([]rollout.Report, error) {
+	return f.reports, f.err
+}
+
+var testReports = []rollout.Report{{
+	ClusterID:  "cluster-id",
+	Sequence:   2,
+	Subcluster: "default",
+	Device:     1,
+	Status:     rollout.StatusHealthy,
+}, {
+	ClusterID:  "cluster-id",
+	Sequence:   2,
+	Subcluster: "other",
+	Device:     1,
+	Status:     rollout.StatusFailed,
+	Message:    "change 12 failed",
+}}
+
+func serve(h func(http.ResponseWriter, *http.Request, int), method, path string) *http.Response {
+	req := httptest.NewRequest(method, path, nil)
+	rec := httptest.NewRecorder()
+	h(rec, req, 2)
+	return rec.Result()
+}
+
+func (s *rolloutSuite) TestHandler(c *check.C) {
+	src := &fakeReportSource{reports: testReports}
+	h := rollout.NewHandler(src)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{"GET", "/cluster/rollout/reports", 200, `[{"cluster-id":"cluster-id","sequence":2,"subcluster":"default","device":1,"status":"healthy"},{"cluster-id":"cluster-id","sequence":2,"subcluster":"other","device":1,"status":"failed","message":"change 12 failed"}]` + "\n"},
+		{"POST", "/cluster/rollout/reports", 405, "method not allowed\n"},
+		{"GET", "/cluster/other", 404, "404 page not found\n"},
+	} {
+		cmt := check.Commentf("%s %s", tc.method, tc.path)
+		resp := serve(h, tc.method, tc.path)
+		body, err := io.ReadAll(resp.Body)
+		c.Assert(err, check.IsNil)
+		c.Check(resp.StatusCode, check.Equals, tc.status, cmt)
+		c.Check(string(body), check.Equals, tc.body, cmt)
+	}
+
+	src.reports = nil
+	body, err := io.ReadAll(serve(h, "GET", "/cluster/rollout/reports").Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, "[]\n")
+
+	src.err = errors.New("boom")
+	c.Check(serve(h, "GET", "/cluster/rollout/reports").StatusCode, check.Equals, 500)
+}
+
+// fakeClient serves the messages sent to the device at a single address with
+// the given handler.
+type fakeClient struct {
+	addr    string
+	device  int
+	handler func(http.ResponseWriter, *http.Request, int)
+	calls   []string
+}
+
+func (f *fakeClient) Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error) {
+	f.calls = append(f.calls, fmt.Sprintf("%s %s %d %s", method, addr, device, kind))
+	if addr != f.addr {
+		return nil, errors.New("connection refused")
+	}
+	if device != f.device {
+		return nil, fmt.Errorf("peer is device %d, expected device %d", f.device, device)
+	}
+	return serve(f.handler, method, "/cluster/"+kind), nil
+}
+
+func (s *rolloutSuite) TestFetch(c *check.C) {
+	client := &fakeClient{
+		addr:    "10.0.0.1:7417",
+		device:  1,
+		handler: rollout.NewHandler(&fakeReportSource{reports: testReports}),
+	}
+
+	reports, err := rollout.Fetch(context.Background(), client, "10.0.0.1:7417", 1)
+	c.Assert(err, check.IsNil)
+	c.Check(reports, check.DeepEquals, testReports)
+	c.Check(client.calls, check.DeepEquals, []string{"GET 10.0.0.1:7417 1 rollout/reports"})
+
+	_, err = rollout.Fetch(context.Background(), client, "10.0.0.1:7417", 2)
+	c.Check(err, check.ErrorMatches, "peer is device 1, expected device 2")
+}
+
+func (s *rolloutSuite) TestFetchOnlyOwnReports(c *check.C) {
+	reports := append([]rollout.Report{{
+		ClusterID:  "cluster-id",
+		Sequence:   2,
+		Subcluster: "default",
+		Device:     3,
+		Status:     rollout.StatusHealthy,
+	}}, testReports...)
+	client := &fakeClient{
+		addr:    "10.0.0.1:7417",
+		device:  1,
+		handler: rollout.NewHandler(&fakeReportSource{reports: reports}),
+	}
+
+	// a device cannot report about other devices
+	fetched, err := rollout.Fetch(context.Background(), client, "10.0.0.1:7417", 1)
+	c.Assert(err, check.IsNil)
+	c.Check(fetched, check.DeepEquals, testReports)
+}
+
+func (s *rolloutSuite) TestFetchErrors(c *check.C) {
+	client := &fakeClient{
+		addr:   "10.0.0.1:7417",
+		device: 1,
+		handler: func(w http.ResponseWriter, r *http.Request, device int) {
+			io.WriteString(w, "not json")
+		},
+	}
+	_, err := rollout.Fetch(context.Background(), client, "10.0.0.1:7417", 1)
+	c.Check(err, check.ErrorMatches, "cannot decode rollout reports: .*")
+
+	client.handler = func(w http.ResponseWriter, r *http.Request, device int) {
+		http.NotFound(w, r)
+	}
+	_, err = rollout.Fetch(context.Background(), client, "10.0.0.1:7417", 1)
+	c.Check(err, check.ErrorMatches, `unexpected status "404 Not Found"`)
+
+	_, err = rollout.Fetch(context.Background(), client, "10.0.0.2:7417", 1)
+	c.Check(err, check.ErrorMatches, "connection refused")
+}
+
+func (s *rolloutSuite) TestFind(c *check.C) {
+	c.Check(rollout.Find(testReports, "cluster-id", 2, "other", 1), check.DeepEquals, &testReports[1])
+	c.Check(rollout.Find(testReports, "cluster-id", 1, "other", 1), check.IsNil)
+	c.Check(rollout.Find(testReports, "cluster-id", 2, "other", 2), check.IsNil)
+	c.Check(rollout.Find(testReports, "other-id", 2, "default", 1), check.IsNil)
+	c.Check(rollout.Find(nil, "cluster-id", 2, "default", 1), check.IsNil)
+}
@@ -103,6 +103,19 @@ The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
+The network egress rate limit caps the outgoing traffic of the group, in bytes
+per second. It can be increased and decreased after being set on a group, but a
+sub-group cannot have a higher limit than its parent groups. The limit is
+enforced with nftables rules and requires cgroup v2. The network traffic of
+all groups is reported as their current usage when the system supports it.
+
+The IO limits are set per block device, as <device>=<value>, and the options can
+be repeated to limit several devices. The bandwidth limits are expressed in bytes
+per second and the IOPS limits in IO operations per second. IO limits can be
+increased and decreased after being set on a group, and setting all IO limits of
+a device to 0 removes them. The IO limits of a sub-group cannot be higher than
+those of its parent groups for the same device. IO limits require cgroup v2.
+
 New quotas can be set on existing quota groups, but existing quotas cannot be removed
 from a quota group, without removing and recreating the entire group.
 
@@ -116,13 +129,18 @@ func init() {
 	addCommand("set-quota", shortSetQuotaHelp, longSetQuotaHelp,
 		func() flags.Commander { return &cmdSetQuota{} },
 		waitDescs.also(map[string]string{
-			"memory":             i18n.G("Memory quota as <number><unit> (e.g. 64MB, 1GB)"),
-			"cpu":                i18n.G("CPU quota as <percentage>% or <count>x<percentage>% (e.g. 50%, 2x100%)"),
-			"cpu-set":            i18n.G("CPU set quota as comma-separated list of CPU core indices (e.g. 0,1,3)"),
-			"threads":            i18n.G("Threads quota as a positive integer (e.g. 512)"),
-			"journal-size":       i18n.G("Journal size quota as <number><unit> (e.g. 16MB)"),
-			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period> (e.g. 100/1s, 1000/1m)"),
-			"parent":             i18n.G("Parent quota group"),
+			"memory":              i18n.G("Memory quota as <number><unit> (e.g. 64MB, 1GB)"),
+			"cpu":                 i18n.G("CPU quota as <percentage>% or <count>x<percentage>% (e.g. 50%, 2x100%)"),
+			"cpu-set":             i18n.G("CPU set quota as comma-separated list of CPU core indices (e.g. 0,1,3)"),
+			"threads":             i18n.G("Threads quota as a positive integer (e.g. 512)"),
+			"journal-size":        i18n.G("Journal size quota as <number><unit> (e.g. 16MB)"),
+			"journal-rate-limit":  i18n.G("Journal rate limit as <message count>/<message period> (e.g. 100/1s, 1000/1m)"),
+			"network-egress-rate": i18n.G("Network egress rate quota per second as <number><unit> (e.g. 1MB)"),
+			"io-read-bandwidth":   i18n.G("IO read bandwidth quota per second as <device>=<number><unit> (e.g. /dev/sda=10MB)"),
+			"io-write-bandwidth":  i18n.G("IO write bandwidth quota per second as <device>=<number><unit> (e.g. /dev/sda=10MB)"),
+			"io-read-iops":        i18n.G("IO read operations per second quota as <device>=<number> (e.g. /dev/sda=1000)"),
+			"io-write-iops":       i18n.G("IO write operations per second quota as <device>=<number> (e.g. /dev/sda=1000)"),
+			"parent":              i18n.G("Parent quota group"),
 		}), nil)
 	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
 	addCommand("quotas", shortQuotasHelp, longQuotasHelp, func() flags.Commander { return &cmdQuotas{} }, nil, nil)
@@ -132,13 +150,18 @@ func init() {
 type cmdSetQuota struct {
 	waitMixin
 
-	MemoryMax        string `long:"memory" optional:"true"`
-	CPUMax           string `long:"cpu" optional:"true"`
-	CPUSet           string `long:"cpu-set" optional:"true"`
-	ThreadsMax       string `long:"threads" optional:"true"`
-	JournalSizeMax   string `long:"journal-size" optional:"true"`
-	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
-	Parent           string `long:"parent" optional:"true"`
+	MemoryMax        string   `long:"memory" optional:"true"`
+	CPUMax           string   `long:"cpu" optional:"true"`
+	CPUSet           string   `long:"cpu-set" optional:"true"`
+	ThreadsMax       string   `long:"threads" optional:"true"`
+	JournalSizeMax   string   `long:"journal-size" optional:"true"`
+	JournalRateLimit string   `long:"journal-rate-limit" optional:"true"`
+	IOReadBandwidth  []string `long:"io-read-bandwidth" optional:"true"`
+	IOWriteBandwidth []string `long:"io-write-bandwidth" optional:"true"`
+	IOReadIOPS       []string `long:"io-read-iops" optional:"true"`
+	IOWriteIOPS      []string `long:"io-write-iops" optional:"true"`
+	NetworkEgress    string   `long:"network-egress-rate" optional:"true"`
+	Parent           string   `long:"parent" optional:"true"`
 	Positional       struct {
 		GroupName string        `positional-arg-name:"<group-name>" required:"true"`
 		Snaps     []serviceName `positional-arg-name:"<snap-or-service>" optional:"true"`
@@ -194,6 +217,66 @@ func parseJournalRateQuota(journalRateLimit string) (count int, period time.Dura
 	return count, period, nil
 }
 
+// splitIOQuota splits an io quota string of the form <device>=<value>
+func splitIOQuota(ioQuota string) (device, value string, err error) {
+	idx := strings.LastIndex(ioQuota, "=")
+	if idx <= 0 || idx == len(ioQuota)-1 {
+		return "", "", fmt.Errorf("io quota must be of the form <device>=<value>")
+	}
+	return ioQuota[:idx], ioQuota[idx+1:], nil
+}
+
+func (x *cmdSetQuota) parseIOQuotas() ([]client.QuotaIOValues, error) {
+	var devices []client.QuotaIOValues
+	deviceValues := func(device string) *client.QuotaIOValues {
+		for i := range devices {
+			if devices[i].Device == device {
+				return &devices[i]
+			}
+		}
+		devices = append(devices, client.QuotaIOValues{Device: device})
+		return &devices[len(devices)-1]
+	}
+
+	for _, opt := range []struct {
+		name   string
+		values []string
+		set    func(dev *client.QuotaIOValues, value string) error
+	}{
+		{"read bandwidth", x.IOReadBandwidth, func(dev *client.QuotaIOValues, value string) error {
+			size, err := strutil.ParseByteSize(value)
+			dev.ReadBandwidth = quantity.Size(size)
+			return err
+		}},
+		{"write bandwidth", x.IOWriteBandwidth, func(dev *client.QuotaIOValues, value string) error {
+			size, err := strutil.ParseByteSize(value)
+			dev.WriteBandwidth = quantity.Size(size)
+			return err
+		}},
+		{"read iops", x.IOReadIOPS, func(dev *client.QuotaIOValues, value string) error {
+			iops, err := strconv.ParseUint(value, 10, 32)
+			dev.ReadIOPS = int(iops)
+			return err
+		}},
+		{"write iops", x.IOWriteIOPS, func(dev *client.QuotaIOValues, value string) error {
+			iops, err := strconv.ParseUint(value, 10, 32)
+			dev.WriteIOPS = int(iops)
+			return err
+		}},
+	} {
+		for _, ioQuota := range opt.values {
+			device, value, err := splitIOQuota(ioQuota)
+			if err == nil {
+				err = opt.set(deviceValues(device), value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse io %s quota %q: %v", opt.name, ioQuota, err)
+			}
+		}
+	}
+	return devices, nil
+}
+
 func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 	var quotaValues client.QuotaValues
 
@@ -266,12 +349,30 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
+	if x.NetworkEgress != "" {
+		value, err := strutil.ParseByteSize(x.NetworkEgress)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse network egress rate %q: %v", x.NetworkEgress, err)
+		}
+		quotaValues.Network = &client.QuotaNetworkValues{
+			EgressRate: quantity.Size(value),
+		}
+	}
+
+	ioValues, err := x.parseIOQuotas()
+	if err != nil {
+		return nil, err
+	}
+	quotaValues.IO = ioValues
+
 	return &quotaValues, nil
 }
 
 func (x *cmdSetQuota) hasQuotaSet() bool {
 	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		len(x.IOReadBandwidth) != 0 || len(x.IOWriteBandwidth) != 0 ||
+		len(x.IOReadIOPS) != 0 || len(x.IOWriteIOPS) != 0 || x.NetworkEgress != ""
 }
 
 func (x *cmdSetQuota) splitSnapsAndServices() (snaps []string, services []string) {
@@ -438,6 +539,19 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 				group.Constraints.Journal.RatePeriod)
 		}
 	}
+	if group.Constraints.Network != nil {
+		val := strings.TrimSpace(fmtSize(int64(group.Constraints.Network.EgressRate)))
+		fmt.Fprintf(w, "  network-egress-rate:\t%s\n", val)
+	}
+	if len(group.Constraints.IO) > 0 {
+		fmt.Fprintf(w, "  io:\n")
+		for _, dev := range group.Constraints.IO {
+			fmt.Fprintf(w, "    - device:\t%s\n", dev.Device)
+			for _, limit := range formatIOLimits(dev) {
+				fmt.Fprintf(w, "      %s:\t%s\n", limit.name, limit.value)
+			}
+		}
+	}
 
 	memoryUsage := "0B"
 	currentThreads := 0
@@ -453,6 +567,10 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 	if group.Constraints.Threads != 0 {
 		fmt.Fprintf(w, "  threads:\t%d\n", currentThreads)
 	}
+	if group.Current != nil && group.Current.Network != nil {
+		fmt.Fprintf(w, "  network-ingress:\t%s\n", strings.TrimSpace(fmtSize(int64(group.Current.Network.IngressBytes))))
+		fmt.Fprintf(w, "  network-egress:\t%s\n", strings.TrimSpace(fmtSize(int64(group.Current.Network.EgressBytes))))
+	}
 
 	if len(group.Subgroups) > 0 {
 		fmt.Fprint(w, "subgroups:\n")
@@ -560,7 +678,19 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			}
 		}
 
-		// format current resource values as memory=N,threads=N
+		// format network constraint as network-egress-rate=xMB
+		if q.Constraints.Network != nil {
+			grpConstraints = append(grpConstraints, "network-egress-rate="+strings.TrimSpace(fmtSize(int64(q.Constraints.Network.EgressRate))))
+		}
+
+		// format io constraints as io-read-bandwidth=<device>=xMB,...
+		for _, dev := range q.Constraints.IO {
+			for _, limit := range formatIOLimits(dev) {
+				grpConstraints = append(grpConstraints, fmt.Sprintf("io-%s=%s=%s", limit.name, dev.Device, limit.value))
+			}
+		}
+
+		// format current resource values as memory=N,threads=N,network-ingress=N,network-egress=N
 		var grpCurrent []string
 		if q.Current != nil {
 			if q.Constraints.Memory != 0 && q.Current.Memory != 0 {
@@ -569,6 +699,11 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			if q.Constraints.Threads != 0 && q.Current.Threads != 0 {
 				grpCurrent = append(grpCurrent, "threads="+fmt.Sprintf("%d", q.Current.Threads))
 			}
+			if q.Current.Network != nil && (q.Current.Network.IngressBytes != 0 || q.Current.Network.EgressBytes != 0) {
+				grpCurrent = append(grpCurrent,
+					"network-ingress="+strings.TrimSpace(fmtSize(int64(q.Current.Network.IngressBytes))),
+					"network-egress="+strings.TrimSpace(fmtSize(int64(q.Current.Network.EgressBytes))))
+			}
 		}
 
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.GroupName, q.Parent, strings.Join(grpConstraints, ","), strings.Join(grpCurrent, ","))
@@ -582,6 +717,28 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 	return nil
 }
 
+type ioLimit struct {
+	name, value string
+}
+
+// formatIOLimits returns the io limits set for the device
+func formatIOLimits(dev client.QuotaIOValues) []ioLimit {
+	var limits []ioLimit
+	if dev.ReadBandwidth != 0 {
+		limits = append(limits, ioLimit{"read-bandwidth", strings.TrimSpace(fmtSize(int64(dev.ReadBandwidth)))})
+	}
+	if dev.WriteBandwidth != 0 {
+		limits = append(limits, ioLimit{"write-bandwidth", strings.TrimSpace(fmtSize(int64(dev.WriteBandwidth)))})
+	}
+	if dev.ReadIOPS != 0 {
+		limits = append(limits, ioLimit{"read-iops", strconv.Itoa(dev.ReadIOPS)})
+	}
+	if dev.WriteIOPS != 0 {
+		limits = append(limits, ioLimit{"write-iops", strconv.Itoa(dev.WriteIOPS)})
+	}
+	return limits
+}
+
 type quotaGroup struct {
 	res       *client.QuotaGroupResult
 	subGroups []*quotaGroup
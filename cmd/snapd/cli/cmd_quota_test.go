@@ -259,6 +259,59 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 	}
 }
 
+func (s *quotaSuite) TestParseIOQuotas(c *check.C) {
+	for _, testData := range []struct {
+		readBandwidth  []string
+		writeBandwidth []string
+		readIOPS       []string
+		writeIOPS      []string
+
+		quotas string
+		err    string
+	}{
+		{readBandwidth: []string{"/dev/sda=10MB"}, quotas: `{"io":[{"device":"/dev/sda","read-bandwidth":10000000}]}`},
+		{
+			readBandwidth:  []string{"/dev/sda=1KB"},
+			writeBandwidth: []string{"/dev/mmcblk0=2KB", "/dev/sda=3KB"},
+			readIOPS:       []string{"/dev/sda=100"},
+			writeIOPS:      []string{"/dev/mmcblk0=200"},
+			quotas:         `{"io":[{"device":"/dev/sda","read-bandwidth":1000,"write-bandwidth":3000,"read-iops":100},{"device":"/dev/mmcblk0","write-bandwidth":2000,"write-iops":200}]}`,
+		},
+		// clearing the limits of a device
+		{readIOPS: []string{"/dev/sda=0"}, quotas: `{"io":[{"device":"/dev/sda"}]}`},
+
+		// Error cases
+		{readBandwidth: []string{"/dev/sda"}, err: `cannot parse io read bandwidth quota "/dev/sda": io quota must be of the form <device>=<value>`},
+		{writeBandwidth: []string{"=1MB"}, err: `cannot parse io write bandwidth quota "=1MB": io quota must be of the form <device>=<value>`},
+		{readBandwidth: []string{"/dev/sda=xx"}, err: `cannot parse io read bandwidth quota "/dev/sda=xx": .*`},
+		{writeIOPS: []string{"/dev/sda=-1"}, err: `cannot parse io write iops quota "/dev/sda=-1": .*invalid syntax`},
+	} {
+		quotas, err := main.ParseIOQuotaValues(testData.readBandwidth, testData.writeBandwidth, testData.readIOPS, testData.writeIOPS)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
+func (s *quotaSuite) TestParseNetworkQuotas(c *check.C) {
+	quotas, err := main.ParseNetworkQuotaValues("2MB")
+	c.Assert(err, check.IsNil)
+	var jsonQuota bytes.Buffer
+	err = json.NewEncoder(&jsonQuota).Encode(quotas)
+	c.Assert(err, check.IsNil)
+	c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, `{"network":{"egress-rate":2000000}}`)
+
+	_, err = main.ParseNetworkQuotaValues("fast")
+	c.Check(err, check.ErrorMatches, `cannot parse network egress rate "fast": .*`)
+}
+
 func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 	const json = `{
 		"type": "sync",
@@ -517,6 +570,68 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestIOQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"io":[{"device":"/dev/sda","read-bandwidth":10000000,"write-iops":100},{"device":"/dev/mmcblk0","read-iops":50}]}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  io:
+    - device:          /dev/sda
+      read-bandwidth:  10.0MB
+      write-iops:      100
+    - device:          /dev/mmcblk0
+      read-iops:       50
+current:
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestNetworkQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"network":{"egress-rate":1000000}},
+			"current": {"network":{"ingress-bytes":2500000,"egress-bytes":12000}}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  network-egress-rate:  1.00MB
+current:
+  network-ingress:  2.50MB
+  network-egress:   12.0kB
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -747,6 +862,30 @@ bbb      zzz     memory=1000B                              memory=400B
 	c.Check(s.quotaGetGroupsHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestGetAllIOAndNetworkQuotaGroups(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupsHandler(c,
+		`{"type": "sync", "status-code": 200, "result": [
+			{"group-name":"io0","subgroups":["io1"],"constraints":{"io":[{"device":"/dev/sda","read-bandwidth":10000000,"write-iops":100}]}},
+			{"group-name":"io1","parent":"io0","constraints":{"threads":10,"io":[{"device":"/dev/sda","write-iops":50}]}},
+			{"group-name":"net0","constraints":{"network":{"egress-rate":1000000}},"current":{"network":{"ingress-bytes":3000,"egress-bytes":2000}}}
+			]}`))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Quota  Parent  Constraints                                                   Current
+io0            io-read-bandwidth=/dev/sda=10.0MB,io-write-iops=/dev/sda=100  
+io1    io0     threads=10,io-write-iops=/dev/sda=50                          
+net0           network-egress-rate=1.00MB                                    network-ingress=3000B,network-egress=2000B
+`[1:])
+	c.Check(s.quotaGetGroupsHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestGetAllQuotaGroupsInconsistencyError(c *check.C) {
 	restore := main.MockIsStdinTTY(true)
 	defer restore()
@@ -1077,6 +1077,9 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		return errors.New("internal error: both refresh.timer and refresh.schedule are empty")
 	}
+	if sysinfo.Refresh.Blackout != "" {
+		fmt.Fprintf(Stdout, "blackout: %s\n", sysinfo.Refresh.Blackout)
+	}
 	last := parseSysinfoTime(sysinfo.Refresh.Last)
 	hold := parseSysinfoTime(sysinfo.Refresh.Hold)
 	next := parseSysinfoTime(sysinfo.Refresh.Next)
@@ -20,6 +20,8 @@
 package cli
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -64,6 +66,10 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+With --passphrase or --key-file the snapshot is encrypted, and the same
+passphrase or key file is then needed to check, restore or import it.
+Losing them means losing access to the data in the snapshot.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -106,13 +112,82 @@ restriction may be lifted in the future.
 
 var longExportSnapshotHelp = i18n.G(`
 Export a snapshot to the given filename.
+
+With --passphrase or --key-file the whole export is encrypted, and the
+same passphrase or key file is then needed to import it.
 `)
 
 var longImportSnapshotHelp = i18n.G(`
 Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
+
+Importing an encrypted export, or an export of encrypted snapshots,
+needs their passphrase or key file.
 `)
 
+type snapshotKeyMixin struct {
+	Passphrase bool           `long:"passphrase"`
+	KeyFile    flags.Filename `long:"key-file"`
+}
+
+var snapshotKeyDescs = mixinDescs{
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"passphrase": i18n.G("Prompt for the passphrase of encrypted snapshots"),
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"key-file": i18n.G("Use the content of the given file as the key of encrypted snapshots"),
+}
+
+// key returns the passphrase or the key file content given by the user, if
+// any. When confirm is true the passphrase is asked for twice, as it's going
+// to be used to encrypt data.
+func (mx snapshotKeyMixin) key(confirm bool) ([]byte, error) {
+	if mx.Passphrase && mx.KeyFile != "" {
+		return nil, errors.New(i18n.G("cannot use --passphrase and --key-file together"))
+	}
+	if mx.KeyFile != "" {
+		key, err := os.ReadFile(string(mx.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot read key file: %v"), err)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf(i18n.G("key file %q is empty"), mx.KeyFile)
+		}
+		return key, nil
+	}
+	if !mx.Passphrase {
+		return nil, nil
+	}
+
+	passphrase, err := readPassphrase(i18n.G("Passphrase: "))
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New(i18n.G("passphrase cannot be empty"))
+	}
+	if confirm {
+		again, err := readPassphrase(i18n.G("Repeat passphrase: "))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New(i18n.G("passphrases do not match"))
+		}
+	}
+	return passphrase, nil
+}
+
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(Stdout, prompt)
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return nil, err
+	}
+	// bytes.TrimSpace needed because we get \r from the pty in the tests
+	return bytes.TrimSpace(passphrase), nil
+}
+
 type savedCmd struct {
 	clientMixin
 	durationMixin
@@ -162,6 +237,9 @@ func (x *savedCmd) Execute([]string) error {
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Encrypted {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -180,6 +258,7 @@ func (x *savedCmd) Execute([]string) error {
 type saveCmd struct {
 	waitMixin
 	durationMixin
+	snapshotKeyMixin
 	Users      string `long:"users"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -189,7 +268,11 @@ type saveCmd struct {
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	key, err := x.key(true)
+	if err != nil {
+		return err
+	}
+	setID, changeID, err := x.client.SnapshotMany(snaps, users, key)
 	if err != nil {
 		return err
 	}
@@ -245,6 +328,7 @@ func (x *forgetCmd) Execute([]string) error {
 
 type checkSnapshotCmd struct {
 	waitMixin
+	snapshotKeyMixin
 	Users      string `long:"users"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
@@ -259,7 +343,11 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.CheckSnapshots(setID, snaps, users)
+	key, err := x.key(false)
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.CheckSnapshots(setID, snaps, users, key)
 	if err != nil {
 		return err
 	}
@@ -284,6 +372,7 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 
 type restoreCmd struct {
 	waitMixin
+	snapshotKeyMixin
 	Users      string `long:"users"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
@@ -298,7 +387,11 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
+	key, err := x.key(false)
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.RestoreSnapshots(setID, snaps, users, key)
 	if err != nil {
 		return err
 	}
@@ -339,7 +432,7 @@ func init() {
 		longSaveHelp,
 		func() flags.Commander {
 			return &saveCmd{}
-		}, durationDescs.also(waitDescs).also(map[string]string{
+		}, durationDescs.also(waitDescs).also(snapshotKeyDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
 		}), nil)
@@ -349,7 +442,7 @@ func init() {
 		longRestoreHelp,
 		func() flags.Commander {
 			return &restoreCmd{}
-		}, waitDescs.also(map[string]string{
+		}, waitDescs.also(snapshotKeyDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
 		}), []argDesc{
@@ -386,7 +479,7 @@ func init() {
 		longCheckHelp,
 		func() flags.Commander {
 			return &checkSnapshotCmd{}
-		}, waitDescs.also(map[string]string{
+		}, waitDescs.also(snapshotKeyDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Check data of only specific users (comma-separated) (default: all users)"),
 		}), []argDesc{
@@ -406,7 +499,7 @@ func init() {
 		longExportSnapshotHelp,
 		func() flags.Commander {
 			return &exportSnapshotCmd{}
-		}, nil, []argDesc{
+		}, snapshotKeyDescs, []argDesc{
 			{
 				name: "<id>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -425,7 +518,7 @@ func init() {
 		longImportSnapshotHelp,
 		func() flags.Commander {
 			return &importSnapshotCmd{}
-		}, nil, []argDesc{
+		}, durationDescs.also(snapshotKeyDescs), []argDesc{
 			{
 				name: "<filename>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -436,6 +529,7 @@ func init() {
 
 type exportSnapshotCmd struct {
 	clientMixin
+	snapshotKeyMixin
 	Positional struct {
 		ID       snapshotID `positional-arg-name:"<id>"`
 		Filename string     `long:"filename"`
@@ -448,7 +542,11 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 		return err
 	}
 
-	r, expectedSize, err := x.client.SnapshotExport(setID)
+	key, err := x.key(true)
+	if err != nil {
+		return err
+	}
+	r, expectedSize, err := x.client.SnapshotExport(setID, key)
 	if err != nil {
 		return err
 	}
@@ -490,6 +588,7 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 type importSnapshotCmd struct {
 	clientMixin
 	durationMixin
+	snapshotKeyMixin
 	Positional struct {
 		Filename string `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
@@ -507,7 +606,11 @@ func (x *importSnapshotCmd) Execute([]string) error {
 		return fmt.Errorf("cannot stat file: %v", err)
 	}
 
-	importSet, err := x.client.SnapshotImport(f, st.Size())
+	key, err := x.key(false)
+	if err != nil {
+		return err
+	}
+	importSet, err := x.client.SnapshotImport(f, st.Size(), key)
 	if err != nil {
 		return err
 	}
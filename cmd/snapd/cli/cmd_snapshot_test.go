@@ -20,6 +20,8 @@
 package cli_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -167,3 +169,82 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) TestSnapshotKeyOptions(c *C) {
+	var gotKeys []string
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps", "/v2/snapshots":
+			var body map[string]any
+			c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+			if key, ok := body["snapshot-key"]; ok {
+				gotKeys = append(gotKeys, key.(string))
+			} else {
+				gotKeys = append(gotKeys, body["key"].(string))
+			}
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 1}}`)
+		case "/v2/snapshots/1/export":
+			gotKeys = append(gotKeys, r.Header.Get(client.SnapshotKeyHeader))
+			w.Header().Set("Content-Type", client.SnapshotExportMediaType)
+			fmt.Fprint(w, "Hello World!")
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	keyFile := filepath.Join(c.MkDir(), "key")
+	c.Assert(os.WriteFile(keyFile, []byte("key file content"), 0600), IsNil)
+	s.password = "passphrase\r\n"
+
+	for _, args := range [][]string{
+		{"save", "--no-wait", "--passphrase"},
+		{"check-snapshot", "--key-file", keyFile, "1"},
+		{"restore", "--passphrase", "1"},
+		{"export-snapshot", "--key-file", keyFile, "1", filepath.Join(c.MkDir(), "export")},
+	} {
+		_, err := main.Parser(main.Client()).ParseArgs(args)
+		c.Assert(err, IsNil, Commentf("%v", args))
+	}
+	c.Check(gotKeys, DeepEquals, []string{
+		base64.StdEncoding.EncodeToString([]byte("passphrase")),
+		base64.StdEncoding.EncodeToString([]byte("key file content")),
+		base64.StdEncoding.EncodeToString([]byte("passphrase")),
+		base64.StdEncoding.EncodeToString([]byte("key file content")),
+	})
+	// the passphrase is confirmed when used to encrypt
+	c.Check(s.Stdout(), testutil.Contains, "Passphrase: \nRepeat passphrase: \n")
+}
+
+func (s *SnapSuite) TestSnapshotKeyOptionsErrors(c *C) {
+	emptyKeyFile := filepath.Join(c.MkDir(), "key")
+	c.Assert(os.WriteFile(emptyKeyFile, nil, 0600), IsNil)
+
+	for _, t := range []struct {
+		args     []string
+		password string
+		err      string
+	}{
+		{[]string{"restore", "--passphrase", "--key-file", emptyKeyFile, "1"}, "", "cannot use --passphrase and --key-file together"},
+		{[]string{"restore", "--key-file", emptyKeyFile, "1"}, "", `key file ".*/key" is empty`},
+		{[]string{"restore", "--key-file", emptyKeyFile + ".missing", "1"}, "", "cannot read key file: .*"},
+		{[]string{"save", "--passphrase"}, "", "passphrase cannot be empty"},
+	} {
+		s.password = t.password
+		_, err := main.Parser(main.Client()).ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
+
+func (s *SnapSuite) TestSnapshotSavedEncrypted(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snapshots")
+		fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":1,"snapshots":[{"set":1,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"encrypted":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, time.Now().Format(time.RFC3339))
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"saved"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  auto, encrypted\n")
+}
@@ -489,6 +489,25 @@ func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, jou
 	return quotas.parseQuotas()
 }
 
+func ParseIOQuotaValues(readBandwidth, writeBandwidth, readIOPS, writeIOPS []string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.IOReadBandwidth = readBandwidth
+	quotas.IOWriteBandwidth = writeBandwidth
+	quotas.IOReadIOPS = readIOPS
+	quotas.IOWriteIOPS = writeIOPS
+
+	return quotas.parseQuotas()
+}
+
+func ParseNetworkQuotaValues(egressRate string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.NetworkEgress = egressRate
+
+	return quotas.parseQuotas()
+}
+
 func MockSeedWriterReadManifest(f func(manifestFile string) (*seedwriter.Manifest, error)) (restore func()) {
 	restore = testutil.Backup(&seedwriterReadManifest)
 	seedwriterReadManifest = f
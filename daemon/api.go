@@ -86,6 +86,7 @@ var api = []*Command{
 	quotaGroupInfoCmd,
 	confdbCmd,
 	confdbControlCmd,
+	confdbRevisionsCmd,
 	noticesCmd,
 	noticeCmd,
 	interfacesRequestsCmd,
@@ -93,8 +94,10 @@ var api = []*Command{
 	requestsPromptCmd,
 	requestsRulesCmd,
 	requestsRuleCmd,
+	requestsEvaluateCmd,
 	systemSecurebootCmd,
 	systemVolumesCmd,
+	refreshPlanCmd,
 }
 
 type featureEndpoint struct {
@@ -195,6 +198,7 @@ var (
 	snapstateInstallComponentPath           = snapstate.InstallComponentPath
 	snapstateInstallComponents              = snapstate.InstallComponents
 	snapstateRefreshCandidates              = snapstate.RefreshCandidates
+	snapstateRefreshPlanPreview             = snapstate.RefreshPlanPreview
 	snapstateTryPath                        = snapstate.TryPath
 	snapstateStoreUpdateGoal                = snapstate.StoreUpdateGoal
 	snapstateUpdateWithGoal                 = snapstate.UpdateWithGoal
@@ -217,9 +221,12 @@ var (
 	assertstateRestoreValidationSetsTracking = assertstate.RestoreValidationSetsTracking
 	assertstateFetchAllValidationSets        = assertstate.FetchAllValidationSets
 
-	confdbstateGetView     = confdbstate.GetView
-	confdbstateWriteConfdb = confdbstate.WriteConfdb
-	confdbstateReadConfdb  = confdbstate.ReadConfdb
+	confdbstateGetView           = confdbstate.GetView
+	confdbstateWriteConfdbAsUser = confdbstate.WriteConfdbAsUser
+	confdbstateReadConfdb        = confdbstate.ReadConfdb
+	confdbstateDatabagRevisions  = confdbstate.DatabagRevisions
+	confdbstateRollbackDatabag   = confdbstate.RollbackDatabag
+	confdbstateConnectedViewIDs  = confdbstate.ConnectedViewIDs
 
 	devicestateSignConfdbControl = (*devicestate.DeviceManager).SignConfdbControl
 )
@@ -53,6 +53,14 @@ var (
 		Actions:     []string{"delegate", "undelegate"},
 		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
 	}
+	confdbRevisionsCmd = &Command{
+		Path:        "/v2/confdb-revisions/{account}/{confdb-schema}",
+		GET:         getConfdbRevisions,
+		POST:        postConfdbRevisions,
+		Actions:     []string{"rollback"},
+		ReadAccess:  authenticatedAccess{Polkit: polkitActionManage},
+		WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+	}
 )
 
 func getView(c *Command, r *http.Request, _ *auth.UserState) Response {
@@ -137,7 +145,7 @@ func validateConstraints(cstrs map[string]any) error {
 	return nil
 }
 
-func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
+func setView(c *Command, r *http.Request, user *auth.UserState) Response {
 	st := c.d.state
 	st.Lock()
 	defer st.Unlock()
@@ -189,7 +197,87 @@ func setView(c *Command, r *http.Request, _ *auth.UserState) Response {
 		return toAPIError(err)
 	}
 
-	changeID, err := confdbstateWriteConfdb(ctx, st, view, action.Values)
+	changeID, err := confdbstateWriteConfdbAsUser(ctx, st, view, action.Values, userID(user))
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	ensureStateSoon(st)
+	return AsyncResponse(nil, changeID)
+}
+
+func userID(user *auth.UserState) int {
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
+func getConfdbRevisions(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.state
+	st.Lock()
+	defer st.Unlock()
+
+	if err := validateFeatureFlag(st, features.Confdb); err != nil {
+		return err
+	}
+
+	vars := muxVars(r)
+	account, schemaName := vars["account"], vars["confdb-schema"]
+
+	revisions, err := confdbstateDatabagRevisions(st, account, schemaName)
+	if err != nil {
+		return InternalError("cannot get revisions of confdb %s/%s: %v", account, schemaName, err)
+	}
+	if revisions == nil {
+		revisions = []*confdbstate.DatabagRevision{}
+	}
+
+	return SyncResponse(revisions)
+}
+
+type confdbRevisionsAction struct {
+	Action string `json:"action"`
+	// View is the view through which the databag is rolled back, whose
+	// hooks are run as for any other write.
+	View     string `json:"view"`
+	Revision int    `json:"revision"`
+}
+
+func postConfdbRevisions(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.state
+	st.Lock()
+	defer st.Unlock()
+
+	if err := validateFeatureFlag(st, features.Confdb); err != nil {
+		return err
+	}
+
+	vars := muxVars(r)
+	account, schemaName := vars["account"], vars["confdb-schema"]
+
+	var a confdbRevisionsAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body: %v", err)
+	}
+
+	if a.Action != "rollback" {
+		return BadRequest("unknown action %q", a.Action)
+	}
+	if a.View == "" {
+		return BadRequest("cannot roll back confdb: view is required")
+	}
+	if a.Revision <= 0 {
+		return BadRequest("cannot roll back confdb: invalid revision %d", a.Revision)
+	}
+
+	view, err := confdbstateGetView(st, account, schemaName, a.View)
+	if err != nil {
+		return toAPIError(err)
+	}
+
+	changeID, err := confdbstateRollbackDatabag(r.Context(), st, view, a.Revision, userID(user))
 	if err != nil {
 		return toAPIError(err)
 	}
@@ -200,6 +200,30 @@ func (s *confdbSuite) TestViewSetMany(c *C) {
 	c.Check(rspe.Change, Equals, "123")
 }
 
+func (s *confdbSuite) TestViewSetPassesUser(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(st *state.State, account, confdbName, viewName string) (*confdb.View, error) {
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	var userID int
+	restore = daemon.MockConfdbstateWriteConfdbAsUser(func(_ context.Context, _ *state.State, _ *confdb.View, _ map[string]any, id int) (string, error) {
+		userID = id
+		return "123", nil
+	})
+	defer restore()
+
+	buf := bytes.NewBufferString(`{"values":{"ssid": "foo"}}`)
+	req, err := http.NewRequest("PUT", "/v2/confdb/system/network/wifi-setup", buf)
+	c.Assert(err, IsNil)
+
+	rspe := s.asyncReq(c, req, &auth.UserState{ID: 42}, actionIsExpected)
+	c.Check(rspe.Status, Equals, 202)
+	c.Check(userID, Equals, 42)
+}
+
 func (s *confdbSuite) TestGetViewError(c *C) {
 	s.setFeatureFlag(c)
 
@@ -797,6 +821,141 @@ func (s *confdbSuite) TestWriteAccessTimeout(c *C) {
 	}
 }
 
+func (s *confdbSuite) TestGetRevisions(c *C) {
+	s.setFeatureFlag(c)
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := daemon.MockConfdbstateDatabagRevisions(func(_ *state.State, account, schemaName string) ([]*confdbstate.DatabagRevision, error) {
+		c.Check(account, Equals, "acc")
+		c.Check(schemaName, Equals, "network")
+		return []*confdbstate.DatabagRevision{{
+			Revision: 1,
+			Time:     now,
+			View:     "wifi-setup",
+			UserID:   42,
+			ChangeID: "12",
+			Diff:     []confdbstate.DiffEntry{{Path: "wifi.ssid", New: "foo"}},
+		}}, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb-revisions/acc/network", nil)
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, DeepEquals, []*confdbstate.DatabagRevision{{
+		Revision: 1,
+		Time:     now,
+		View:     "wifi-setup",
+		UserID:   42,
+		ChangeID: "12",
+		Diff:     []confdbstate.DiffEntry{{Path: "wifi.ssid", New: "foo"}},
+	}})
+}
+
+func (s *confdbSuite) TestGetRevisionsNone(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateDatabagRevisions(func(*state.State, string, string) ([]*confdbstate.DatabagRevision, error) {
+		return nil, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/confdb-revisions/acc/network", nil)
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Result, DeepEquals, []*confdbstate.DatabagRevision{})
+}
+
+func (s *confdbSuite) TestGetRevisionsErrors(c *C) {
+	req, err := http.NewRequest("GET", "/v2/confdb-revisions/acc/network", nil)
+	c.Assert(err, IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Message, Equals, `feature flag "confdb" is disabled: set 'experimental.confdb' to true`)
+
+	s.setFeatureFlag(c)
+	restore := daemon.MockConfdbstateDatabagRevisions(func(*state.State, string, string) ([]*confdbstate.DatabagRevision, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	rspe = s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 500)
+	c.Check(rspe.Message, Equals, "cannot get revisions of confdb acc/network: boom")
+}
+
+func (s *confdbSuite) TestRollback(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, account, schemaName, viewName string) (*confdb.View, error) {
+		c.Check(account, Equals, "system")
+		c.Check(schemaName, Equals, "network")
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	var called bool
+	restore = daemon.MockConfdbstateRollbackDatabag(func(_ context.Context, _ *state.State, view *confdb.View, revision, userID int) (string, error) {
+		called = true
+		c.Check(view.Name, Equals, "wifi-setup")
+		c.Check(revision, Equals, 3)
+		c.Check(userID, Equals, 42)
+		return "123", nil
+	})
+	defer restore()
+
+	buf := bytes.NewBufferString(`{"action": "rollback", "view": "wifi-setup", "revision": 3}`)
+	req, err := http.NewRequest("POST", "/v2/confdb-revisions/system/network", buf)
+	c.Assert(err, IsNil)
+
+	rspe := s.asyncReq(c, req, &auth.UserState{ID: 42}, actionIsExpected)
+	c.Check(rspe.Status, Equals, 202)
+	c.Check(rspe.Change, Equals, "123")
+	c.Check(called, Equals, true)
+}
+
+func (s *confdbSuite) TestRollbackErrors(c *C) {
+	s.setFeatureFlag(c)
+
+	restore := daemon.MockConfdbstateGetView(func(_ *state.State, _, _, viewName string) (*confdb.View, error) {
+		if viewName != "wifi-setup" {
+			return nil, &confdbstate.NoViewError{}
+		}
+		return s.schema.View(viewName), nil
+	})
+	defer restore()
+
+	restore = daemon.MockConfdbstateRollbackDatabag(func(context.Context, *state.State, *confdb.View, int, int) (string, error) {
+		return "", errors.New("cannot roll back confdb system/network: revision 3 is not in the history")
+	})
+	defer restore()
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		errMsg string
+	}{
+		{``, 400, "cannot decode request body: EOF"},
+		{`{"action": "foo"}`, 400, `unknown action "foo"`},
+		{`{"action": "rollback", "revision": 3}`, 400, "cannot roll back confdb: view is required"},
+		{`{"action": "rollback", "view": "wifi-setup"}`, 400, "cannot roll back confdb: invalid revision 0"},
+		{`{"action": "rollback", "view": "other", "revision": 3}`, 400, ".*"},
+		{`{"action": "rollback", "view": "wifi-setup", "revision": 3}`, 500, "cannot roll back confdb system/network: revision 3 is not in the history"},
+	} {
+		cmt := Commentf("%s", tc.body)
+		req, err := http.NewRequest("POST", "/v2/confdb-revisions/system/network", bytes.NewBufferString(tc.body))
+		c.Assert(err, IsNil)
+
+		rspe := s.errorReq(c, req, nil, actionExpectedBool(!strings.Contains(tc.errMsg, "unknown action")))
+		c.Check(rspe.Status, Equals, tc.status, cmt)
+		c.Check(rspe.Message, Matches, tc.errMsg, cmt)
+	}
+}
+
 type confdbControlSuite struct {
 	apiBaseSuite
 
@@ -149,6 +149,10 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return InternalError("cannot get refresh schedule: %s", err)
 	}
+	refreshBlackoutStr, err := snapMgr.RefreshBlackout()
+	if err != nil {
+		return InternalError("cannot get refresh blackout: %s", err)
+	}
 	users, err := auth.Users(st)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
 		return InternalError("cannot get user auth data: %s", err)
@@ -165,9 +169,10 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 
 	refreshInfo := client.RefreshInfo{
-		Last: formatRefreshTime(lastRefresh),
-		Hold: formatRefreshTime(refreshHold),
-		Next: formatRefreshTime(nextRefresh),
+		Blackout: refreshBlackoutStr,
+		Last:     formatRefreshTime(lastRefresh),
+		Hold:     formatRefreshTime(refreshHold),
+		Next:     formatRefreshTime(nextRefresh),
 	}
 	if !legacySchedule {
 		refreshInfo.Timer = refreshScheduleStr
@@ -142,6 +142,7 @@ func (s *generalSuite) TestSysInfo(c *check.C) {
 	tr := config.NewTransaction(st)
 	tr.Set("core", "refresh.schedule", "00:00-9:00/12:00-13:00")
 	tr.Set("core", "refresh.timer", "8:00~9:00/2")
+	tr.Set("core", "refresh.blackout", "last-week")
 	tr.Set("core", "experimental.parallel-instances", "false")
 	tr.Set("core", "experimental.quota-groups", "true")
 	tr.Commit()
@@ -169,8 +170,9 @@ func (s *generalSuite) TestSysInfo(c *check.C) {
 			"snap-bin-dir":   dirs.SnapBinariesDir,
 		},
 		"refresh": map[string]any{
-			// only the "timer" field
-			"timer": "8:00~9:00/2",
+			// the "timer" field, not the legacy "schedule" one
+			"timer":    "8:00~9:00/2",
+			"blackout": "last-week",
 		},
 		"confinement":      "partial",
 		"sandbox-features": map[string]any{"confinement-options": []any{"classic", "devmode"}},
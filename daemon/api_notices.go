@@ -19,13 +19,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap/naming"
@@ -36,8 +39,10 @@ var noticeReadInterfaces = map[state.NoticeType][]string{
 	state.ChangeUpdateNotice:                 {"snap-refresh-observe"},
 	state.RefreshInhibitNotice:               {"snap-refresh-observe"},
 	state.SnapRunInhibitNotice:               {"snap-refresh-observe"},
+	state.SnapHealthNotice:                   {"snap-refresh-observe"},
 	state.InterfacesRequestsPromptNotice:     {"snap-interfaces-requests-control"},
 	state.InterfacesRequestsRuleUpdateNotice: {"snap-interfaces-requests-control"},
+	state.ConfdbChangeNotice:                 {"confdb"},
 }
 
 var (
@@ -46,14 +51,14 @@ var (
 		GET:         getNotices,
 		POST:        postNotices,
 		Actions:     []string{"add"},
-		ReadAccess:  interfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control"}},
+		ReadAccess:  interfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control", "confdb"}},
 		WriteAccess: openAccess{},
 	}
 
 	noticeCmd = &Command{
 		Path:       "/v2/notices/{id}",
 		GET:        getNotice,
-		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control"}},
+		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control", "confdb"}},
 	}
 )
 
@@ -66,61 +71,20 @@ type addedNotice struct {
 func getNotices(c *Command, r *http.Request, user *auth.UserState) Response {
 	query := r.URL.Query()
 
-	requestUID, err := uidFromRequest(r)
-	if err != nil {
-		return Forbidden("cannot determine UID of request, so cannot retrieve notices")
+	filter, rsp := noticeFilterFromRequest(c.d.overlord.State(), r)
+	if rsp != nil {
+		return rsp
 	}
 
-	// By default, return notices with the request UID and public notices.
-	userID := &requestUID
-
-	if len(query["user-id"]) > 0 {
-		if requestUID != 0 {
-			return Forbidden(`only admins may use the "user-id" filter`)
-		}
-		userID, err = sanitizeNoticeUserIDFilter(query["user-id"])
-		if err != nil {
-			return BadRequest(`invalid "user-id" filter: %v`, err)
-		}
-	}
-
-	if len(query["users"]) > 0 {
-		if requestUID != 0 {
-			return Forbidden(`only admins may use the "users" filter`)
-		}
-		if len(query["user-id"]) > 0 {
-			return BadRequest(`cannot use both "users" and "user-id" parameters`)
-		}
-		if query.Get("users") != "all" {
-			return BadRequest(`invalid "users" filter: must be "all"`)
-		}
-		// Clear the userID filter so all notices will be returned.
-		userID = nil
+	if format := noticesStreamFormatFromRequest(r); format != "" {
+		return streamNotices(c, r, filter, format)
 	}
 
-	types, err := sanitizeNoticeTypesFilter(query["types"], r)
-	if err != nil {
+	if filter == nil {
 		// Caller did provide a types filter, but they're all invalid notice types.
 		// Return no notices, rather than the default of all notices.
 		return SyncResponse([]*state.Notice{})
 	}
-	if !noticeTypesViewableBySnap(types, r) {
-		return Forbidden("snap cannot access specified notice types")
-	}
-
-	keys := strutil.MultiCommaSeparatedList(query["keys"])
-
-	after, err := parseOptionalTime(query.Get("after"))
-	if err != nil {
-		return BadRequest(`invalid "after" timestamp: %v`, err)
-	}
-
-	filter := &state.NoticeFilter{
-		UserID: userID,
-		Types:  types,
-		Keys:   keys,
-		After:  after,
-	}
 
 	timeout, err := parseOptionalDuration(query.Get("timeout"))
 	if err != nil {
@@ -161,6 +125,76 @@ func getNotices(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(notices)
 }
 
+// noticeFilterFromRequest builds the notice filter from the query parameters
+// of the given request, restricted to the notices visible to the requesting
+// user and snap. If an error response is returned, the request should be
+// rejected with it. If both are nil, the caller requested only invalid notice
+// types, so no notices can match.
+func noticeFilterFromRequest(st *state.State, r *http.Request) (*state.NoticeFilter, Response) {
+	query := r.URL.Query()
+
+	requestUID, err := uidFromRequest(r)
+	if err != nil {
+		return nil, Forbidden("cannot determine UID of request, so cannot retrieve notices")
+	}
+
+	// By default, return notices with the request UID and public notices.
+	userID := &requestUID
+
+	if len(query["user-id"]) > 0 {
+		if requestUID != 0 {
+			return nil, Forbidden(`only admins may use the "user-id" filter`)
+		}
+		userID, err = sanitizeNoticeUserIDFilter(query["user-id"])
+		if err != nil {
+			return nil, BadRequest(`invalid "user-id" filter: %v`, err)
+		}
+	}
+
+	if len(query["users"]) > 0 {
+		if requestUID != 0 {
+			return nil, Forbidden(`only admins may use the "users" filter`)
+		}
+		if len(query["user-id"]) > 0 {
+			return nil, BadRequest(`cannot use both "users" and "user-id" parameters`)
+		}
+		if query.Get("users") != "all" {
+			return nil, BadRequest(`invalid "users" filter: must be "all"`)
+		}
+		// Clear the userID filter so all notices will be returned.
+		userID = nil
+	}
+
+	types, err := sanitizeNoticeTypesFilter(query["types"], r)
+	if err != nil {
+		return nil, nil
+	}
+	if !noticeTypesViewableBySnap(types, r) {
+		return nil, Forbidden("snap cannot access specified notice types")
+	}
+
+	typeKeys, err := noticeTypeKeysViewableBySnap(st, r)
+	if err != nil {
+		return nil, Forbidden("cannot determine notices viewable by snap: %v", err)
+	}
+
+	keys := strutil.MultiCommaSeparatedList(query["keys"])
+
+	after, err := parseOptionalTime(query.Get("after"))
+	if err != nil {
+		return nil, BadRequest(`invalid "after" timestamp: %v`, err)
+	}
+
+	filter := &state.NoticeFilter{
+		UserID:   userID,
+		Types:    types,
+		Keys:     keys,
+		TypeKeys: typeKeys,
+		After:    after,
+	}
+	return filter, nil
+}
+
 // Get the UID of the request. If the UID is not known, return an error.
 func uidFromRequest(r *http.Request) (uint32, error) {
 	cred, err := ucrednetGet(r.RemoteAddr)
@@ -377,6 +411,14 @@ func getNotice(c *Command, r *http.Request, user *auth.UserState) Response {
 	if !noticeTypesViewableBySnap([]state.NoticeType{notice.Type()}, r) {
 		return Forbidden("not allowed to access notice with id %q", noticeID)
 	}
+	typeKeys, err := noticeTypeKeysViewableBySnap(c.d.overlord.State(), r)
+	if err != nil {
+		return Forbidden("cannot determine notices viewable by snap: %v", err)
+	}
+	filter := &state.NoticeFilter{TypeKeys: typeKeys}
+	if !filter.Matches(notice) {
+		return Forbidden("not allowed to access notice with id %q", noticeID)
+	}
 	return SyncResponse(notice)
 }
 
@@ -425,3 +467,179 @@ InterfaceTypeLoop:
 	}
 	return true
 }
+
+// noticeTypeKeysViewableBySnap returns, for the notice types whose keys
+// restrict which snaps may read them, the keys that the requesting snap may
+// read. Confdb-change notices are keyed by view and can only be read by snaps
+// with a connected confdb plug for that view. Requests which don't come from a
+// snap are not restricted.
+func noticeTypeKeysViewableBySnap(st *state.State, r *http.Request) (map[state.NoticeType][]string, error) {
+	ucred, ifaces, err := ucrednetGetWithInterfaces(r.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	if ucred.Socket == dirs.SnapdSocket {
+		// Not connecting through snapd-snap.socket, can read all notices.
+		return nil, nil
+	}
+	if !strutil.ListContains(ifaces, "confdb") {
+		// Confdb-change notices are filtered out by type already.
+		return nil, nil
+	}
+
+	snapName, err := cgroupSnapNameFromPid(int(ucred.Pid))
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine snap name for pid: %v", err)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	viewIDs, err := confdbstateConnectedViewIDs(st, snapName)
+	if err != nil {
+		return nil, err
+	}
+	return map[state.NoticeType][]string{state.ConfdbChangeNotice: viewIDs}, nil
+}
+
+const (
+	// noticesStreamSSE streams notices as Server-Sent Events.
+	noticesStreamSSE = "text/event-stream"
+	// noticesStreamNDJSON streams notices as newline-delimited JSON.
+	noticesStreamNDJSON = "application/x-ndjson"
+)
+
+// noticesStreamKeepalive is the interval at which a keepalive is written to
+// an idle notices stream, so that disconnected clients are detected.
+var noticesStreamKeepalive = 30 * time.Second
+
+// noticesStreamFormatFromRequest returns the notices stream media type
+// accepted by the client, or "" if the client did not ask for a stream.
+func noticesStreamFormatFromRequest(r *http.Request) string {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			switch mediaType = strings.TrimSpace(mediaType); mediaType {
+			case noticesStreamSSE, noticesStreamNDJSON:
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+func streamNotices(c *Command, r *http.Request, filter *state.NoticeFilter, format string) Response {
+	if filter == nil {
+		return BadRequest("cannot stream notices: all requested notice types are invalid")
+	}
+	if r.URL.Query().Get("timeout") != "" {
+		return BadRequest(`cannot use "timeout" when streaming notices`)
+	}
+	// A reconnecting client resumes from the last notice it received, which
+	// is identified by its last-repeated timestamp.
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		after, err := time.Parse(time.RFC3339Nano, lastEventID)
+		if err != nil {
+			return BadRequest(`invalid "Last-Event-ID" header: %v`, err)
+		}
+		filter.After = after
+	}
+	return &noticesStreamResponse{
+		d:      c.d,
+		filter: filter,
+		format: format,
+	}
+}
+
+// noticesStreamResponse streams notices matching its filter as they occur,
+// until the client disconnects or the daemon shuts down.
+type noticesStreamResponse struct {
+	d      *Daemon
+	filter *state.NoticeFilter
+	format string
+}
+
+func (s *noticesStreamResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", s.format)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, hasFlusher := w.(http.Flusher)
+	flush := func() {
+		if hasFlusher {
+			flusher.Flush()
+		}
+	}
+	flush()
+
+	// Use daemon's tomb context so that the stream is closed when the daemon
+	// shuts down.
+	ctx := s.d.tomb.Context(r.Context())
+	noticeMgr := s.d.overlord.NoticeManager()
+	filter := *s.filter
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, noticesStreamKeepalive)
+		notices, err := noticeMgr.WaitNotices(waitCtx, &filter)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			logger.Noticef("cannot stream notices: %v", err)
+			return
+		}
+
+		// The confdb views the snap may read change as its plugs are
+		// connected and disconnected while the stream is open.
+		typeKeys, err := noticeTypeKeysViewableBySnap(s.d.overlord.State(), r)
+		if err != nil {
+			logger.Noticef("cannot stream notices: cannot determine notices viewable by snap: %v", err)
+			return
+		}
+		filter.TypeKeys = typeKeys
+
+		if len(notices) == 0 {
+			if err := s.writeKeepalive(w); err != nil {
+				return
+			}
+			flush()
+			continue
+		}
+		for _, notice := range notices {
+			visible := filter.Matches(notice)
+			filter.After = notice.LastRepeated()
+			if !visible {
+				continue
+			}
+			if err := s.writeNotice(w, notice); err != nil {
+				logger.Debugf("cannot stream notice: %v", err)
+				return
+			}
+		}
+		flush()
+	}
+}
+
+func (s *noticesStreamResponse) writeNotice(w io.Writer, notice *state.Notice) error {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	if s.format == noticesStreamNDJSON {
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	eventID := notice.LastRepeated().Format(time.RFC3339Nano)
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventID, notice.Type(), data)
+	return err
+}
+
+func (s *noticesStreamResponse) writeKeepalive(w io.Writer) error {
+	var err error
+	if s.format == noticesStreamNDJSON {
+		_, err = io.WriteString(w, "\n")
+	} else {
+		// Lines starting with a colon are comments, ignored by SSE clients.
+		_, err = io.WriteString(w, ": keepalive\n\n")
+	}
+	return err
+}
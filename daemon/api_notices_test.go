@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -50,7 +51,7 @@ func (s *noticesSuite) SetUpTest(c *C) {
 	dirstest.MustMockDefaultLibExecDir(dirs.GlobalRootDir)
 	dirs.SetRootDir(dirs.GlobalRootDir)
 
-	s.expectReadAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control"}})
+	s.expectReadAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-refresh-observe", "snap-interfaces-requests-control", "confdb"}})
 	s.expectWriteAccess(daemon.OpenAccess{})
 }
 
@@ -341,6 +342,109 @@ func (s *noticesSuite) TestNoticesFilterTypesForSnap(c *C) {
 	c.Check(seenNoticeType["snap-run-inhibit"], Equals, 1)
 }
 
+func (s *noticesSuite) mockConfdbViewsForSnap(c *C, snapName string, viewIDs []string) {
+	s.AddCleanup(daemon.MockCgroupSnapNameFromPid(func(pid int) (string, error) {
+		c.Check(pid, Equals, 100)
+		return snapName, nil
+	}))
+	s.AddCleanup(daemon.MockConfdbstateConnectedViewIDs(func(st *state.State, name string) ([]string, error) {
+		c.Check(name, Equals, snapName)
+		return viewIDs, nil
+	}))
+}
+
+func (s *noticesSuite) TestNoticesConfdbChangeForSnap(c *C) {
+	s.daemon(c)
+	s.mockConfdbViewsForSnap(c, "test-snap", []string{"acc/network/wifi-setup"})
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	addNotice(c, st, nil, state.ChangeUpdateNotice, "123", nil)
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-setup", nil)
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-admin", nil)
+	st.Unlock()
+
+	// the confdb interface allows waiting on changes to specific views
+	req, err := http.NewRequest("GET", "/v2/notices?types=confdb-change&keys=acc/network/wifi-setup", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	notices, ok := rsp.Result.([]*state.Notice)
+	c.Assert(ok, Equals, true)
+	c.Assert(notices, HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["type"], Equals, "confdb-change")
+	c.Check(n["key"], Equals, "acc/network/wifi-setup")
+
+	// without a types filter, only confdb-change notices of the views the
+	// snap has connected plugs for are visible
+	req, err = http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	notices, ok = rsp.Result.([]*state.Notice)
+	c.Assert(ok, Equals, true)
+	c.Assert(notices, HasLen, 1)
+	n = noticeToMap(c, notices[0])
+	c.Check(n["key"], Equals, "acc/network/wifi-setup")
+
+	// changes to other views are not visible to the snap
+	req, err = http.NewRequest("GET", "/v2/notices?types=confdb-change&keys=acc/network/wifi-admin", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, HasLen, 0)
+
+	// but the confdb interface doesn't give access to other notices
+	req, err = http.NewRequest("GET", "/v2/notices?types=change-update", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 403)
+}
+
+func (s *noticesSuite) TestNoticesConfdbChangeForSnapWithoutViews(c *C) {
+	s.daemon(c)
+	s.mockConfdbViewsForSnap(c, "test-snap", nil)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-setup", nil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/notices?types=confdb-change", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, HasLen, 0)
+
+	// requests not made by snaps see all confdb-change notices
+	req, err = http.NewRequest("GET", "/v2/notices?types=confdb-change", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	rsp = s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(rsp.Result, HasLen, 1)
+}
+
+func (s *noticesSuite) TestNoticesConfdbChangeForUnknownSnap(c *C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockCgroupSnapNameFromPid(func(pid int) (string, error) {
+		return "", errors.New("boom")
+	}))
+
+	req, err := http.NewRequest("GET", "/v2/notices?types=confdb-change", nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 403)
+	c.Check(rsp.Message, Equals, "cannot determine notices viewable by snap: cannot determine snap name for pid: boom")
+}
+
 func (s *noticesSuite) TestNoticesFilterTypesForSnapForbidden(c *C) {
 	s.daemon(c)
 
@@ -671,6 +775,251 @@ func (s *noticesSuite) TestNoticesRequestCancelled(c *C) {
 	c.Check(elapsed < reqTimeout, Equals, true)
 }
 
+// streamWriter is an http.ResponseWriter which passes each write on over a
+// channel, so that streamed responses can be checked as they are written.
+type streamWriter struct {
+	header http.Header
+	writes chan string
+}
+
+func newStreamWriter() *streamWriter {
+	return &streamWriter{
+		header: make(http.Header),
+		writes: make(chan string, 100),
+	}
+}
+
+func (w *streamWriter) Header() http.Header { return w.header }
+
+func (w *streamWriter) WriteHeader(statusCode int) {}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	w.writes <- string(data)
+	return len(data), nil
+}
+
+func (w *streamWriter) next(c *C) string {
+	select {
+	case data := <-w.writes:
+		return data
+	case <-time.After(testutil.HostScaledTimeout(5 * time.Second)):
+		c.Fatal("timed out waiting for streamed data")
+	}
+	return ""
+}
+
+func (s *noticesSuite) serveNoticesStream(c *C, req *http.Request) (w *streamWriter, stop func()) {
+	rsp := s.req(c, req, nil, actionIsExpected)
+	w = newStreamWriter()
+	ctx, cancel := context.WithCancel(req.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp.ServeHTTP(w, req.WithContext(ctx))
+	}()
+	stop = func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(testutil.HostScaledTimeout(5 * time.Second)):
+			c.Fatal("notices stream did not stop")
+		}
+	}
+	return w, stop
+}
+
+func (s *noticesSuite) TestNoticesStreamSSE(c *C) {
+	s.daemon(c)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	addNotice(c, st, nil, state.WarningNotice, "danger", nil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/notices?types=warning,change-update", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	w, stop := s.serveNoticesStream(c, req)
+	defer stop()
+
+	checkEvent := func(event, typ, key string) {
+		lines := strings.Split(event, "\n")
+		c.Assert(lines, HasLen, 5, Commentf("%q", event))
+		c.Check(lines[1], Equals, "event: "+typ)
+		c.Check(lines[3], Equals, "")
+		c.Check(lines[4], Equals, "")
+		var n map[string]any
+		c.Assert(json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &n), IsNil)
+		c.Check(n["type"], Equals, typ)
+		c.Check(n["key"], Equals, key)
+		c.Check(lines[0], Equals, "id: "+n["last-repeated"].(string))
+	}
+
+	checkEvent(w.next(c), "warning", "danger")
+	c.Check(w.header.Get("Content-Type"), Equals, "text/event-stream")
+
+	// New notices are sent over the same connection
+	st.Lock()
+	addNotice(c, st, nil, state.ChangeUpdateNotice, "123", nil)
+	// Notices not matching the filter are not sent
+	addNotice(c, st, nil, state.RefreshInhibitNotice, "-", nil)
+	addNotice(c, st, nil, state.WarningNotice, "more danger", nil)
+	st.Unlock()
+
+	checkEvent(w.next(c), "change-update", "123")
+	checkEvent(w.next(c), "warning", "more danger")
+}
+
+func (s *noticesSuite) TestNoticesStreamNDJSONLastEventID(c *C) {
+	s.daemon(c)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	addNotice(c, st, nil, state.WarningNotice, "first", nil)
+	addNotice(c, st, nil, state.WarningNotice, "second", nil)
+	addNotice(c, st, nil, state.WarningNotice, "third", nil)
+	notices := st.Notices(nil)
+	st.Unlock()
+	c.Assert(notices, HasLen, 3)
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "application/json, application/x-ndjson")
+	// Resume after the first notice
+	req.Header.Set("Last-Event-ID", notices[0].LastRepeated().Format(time.RFC3339Nano))
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	w, stop := s.serveNoticesStream(c, req)
+	defer stop()
+
+	for _, key := range []string{"second", "third"} {
+		line := w.next(c)
+		c.Assert(strings.HasSuffix(line, "\n"), Equals, true)
+		var n map[string]any
+		c.Assert(json.Unmarshal([]byte(line), &n), IsNil)
+		c.Check(n["key"], Equals, key)
+	}
+	c.Check(w.header.Get("Content-Type"), Equals, "application/x-ndjson")
+}
+
+func (s *noticesSuite) TestNoticesStreamUserVisibility(c *C) {
+	s.daemon(c)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	otherUID := uint32(1001)
+	addNotice(c, st, &otherUID, state.WarningNotice, "other", nil)
+	uid := uint32(1000)
+	addNotice(c, st, &uid, state.WarningNotice, "mine", nil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	w, stop := s.serveNoticesStream(c, req)
+	defer stop()
+
+	var n map[string]any
+	c.Assert(json.Unmarshal([]byte(w.next(c)), &n), IsNil)
+	c.Check(n["key"], Equals, "mine")
+
+	// Only admins may stream notices of other users
+	req, err = http.NewRequest("GET", "/v2/notices?user-id=1001", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	rsp := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, Equals, 403)
+}
+
+func (s *noticesSuite) TestNoticesStreamConfdbChangeViewsDisconnected(c *C) {
+	s.daemon(c)
+	var viewIDs []string
+	s.AddCleanup(daemon.MockCgroupSnapNameFromPid(func(pid int) (string, error) {
+		return "test-snap", nil
+	}))
+	s.AddCleanup(daemon.MockConfdbstateConnectedViewIDs(func(st *state.State, name string) ([]string, error) {
+		c.Check(name, Equals, "test-snap")
+		// viewIDs is only changed with the state locked
+		return viewIDs, nil
+	}))
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	viewIDs = []string{"acc/network/wifi-setup", "acc/network/wifi-admin"}
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-setup", nil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/notices?types=confdb-change", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	w, stop := s.serveNoticesStream(c, req)
+	defer stop()
+
+	var n map[string]any
+	c.Assert(json.Unmarshal([]byte(w.next(c)), &n), IsNil)
+	c.Check(n["key"], Equals, "acc/network/wifi-setup")
+
+	// the plug of the wifi-admin view is disconnected while the stream is
+	// open, so changes to that view are no longer sent
+	st.Lock()
+	viewIDs = []string{"acc/network/wifi-setup"}
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-admin", nil)
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/network/wifi-setup", nil)
+	st.Unlock()
+
+	c.Assert(json.Unmarshal([]byte(w.next(c)), &n), IsNil)
+	c.Check(n["key"], Equals, "acc/network/wifi-setup")
+	select {
+	case data := <-w.writes:
+		c.Errorf("unexpected streamed data: %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *noticesSuite) TestNoticesStreamKeepalive(c *C) {
+	restore := daemon.MockNoticesStreamKeepalive(time.Millisecond)
+	defer restore()
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;", dirs.SnapdSocket)
+	w, stop := s.serveNoticesStream(c, req)
+	defer stop()
+
+	c.Check(w.next(c), Equals, ": keepalive\n\n")
+}
+
+func (s *noticesSuite) TestNoticesStreamBadRequest(c *C) {
+	s.daemon(c)
+
+	for _, tc := range []struct {
+		query       string
+		lastEventID string
+		errorMatch  string
+	}{
+		{"timeout=10s", "", `cannot use "timeout" when streaming notices`},
+		{"types=foo", "", `cannot stream notices: all requested notice types are invalid`},
+		{"", "yesterday", `invalid "Last-Event-ID" header: .*`},
+		{"after=foo", "", `invalid "after" timestamp.*`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/notices?"+tc.query, nil)
+		c.Assert(err, IsNil)
+		req.Header.Set("Accept", "text/event-stream")
+		if tc.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", tc.lastEventID)
+		}
+		req.RemoteAddr = fmt.Sprintf("pid=100;uid=0;socket=%s;", dirs.SnapdSocket)
+		rsp := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rsp.Status, Equals, 400)
+		c.Check(rsp.Message, Matches, tc.errorMatch)
+	}
+}
+
 func (s *noticesSuite) TestNoticesInvalidUserID(c *C) {
 	s.testNoticesBadRequest(c, "user-id=foo", `invalid "user-id" filter:.*`)
 }
@@ -1235,6 +1584,36 @@ func (s *noticesSuite) TestNoticeSnapNotAllowed(c *C) {
 	c.Check(rsp.Status, Equals, 403)
 }
 
+func (s *noticesSuite) TestNoticeConfdbChangeForSnap(c *C) {
+	s.daemon(c)
+	s.mockConfdbViewsForSnap(c, "test-snap", []string{"acc/network/wifi-setup"})
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	setupNoticeID, err := st.AddNotice(nil, state.ConfdbChangeNotice, "acc/network/wifi-setup", nil)
+	c.Assert(err, IsNil)
+	adminNoticeID, err := st.AddNotice(nil, state.ConfdbChangeNotice, "acc/network/wifi-admin", nil)
+	c.Assert(err, IsNil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/notices/"+setupNoticeID, nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, Equals, 200)
+	notice, ok := rsp.Result.(*state.Notice)
+	c.Assert(ok, Equals, true)
+	n := noticeToMap(c, notice)
+	c.Check(n["key"], Equals, "acc/network/wifi-setup")
+
+	// the snap has no connected plug for this view
+	req, err = http.NewRequest("GET", "/v2/notices/"+adminNoticeID, nil)
+	c.Assert(err, IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=1000;socket=%s;iface=confdb;", dirs.SnapSocket)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 403)
+}
+
 func noticeToMap(c *C, notice *state.Notice) map[string]any {
 	buf, err := json.Marshal(notice)
 	c.Assert(err, IsNil)
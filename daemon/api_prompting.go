@@ -71,7 +71,7 @@ var (
 		Path:       "/v2/interfaces/requests/rules",
 		GET:        getRules,
 		POST:       postRules,
-		Actions:    []string{"add", "remove"},
+		Actions:    []string{"add", "remove", "export", "import"},
 		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
 		// postRules can only operate on rules associated with the user making
 		// the API request, so there is no need for polkit authentication.
@@ -89,6 +89,12 @@ var (
 		// authentication.
 		WriteAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
 	}
+
+	requestsEvaluateCmd = &Command{
+		Path:       "/v2/interfaces/requests/evaluate",
+		GET:        getEvaluateRequest,
+		ReadAccess: interfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}},
+	}
 )
 
 var (
@@ -235,6 +241,32 @@ func (v *promptingRuleConflictError) MarshalJSON() ([]byte, error) {
 	})
 }
 
+type promptingRuleImportConflict struct {
+	Index     int                     `json:"index"`
+	Conflicts []promptingRuleConflict `json:"conflicts"`
+}
+
+type promptingRuleImportConflictError prompting_errors.RuleImportConflictError
+
+func (v *promptingRuleImportConflictError) MarshalJSON() ([]byte, error) {
+	importConflictsJSON := make([]promptingRuleImportConflict, len(v.Conflicts))
+	for i, importConflict := range v.Conflicts {
+		conflictsJSON := make([]promptingRuleConflict, len(importConflict.Conflicts))
+		for j, conflict := range importConflict.Conflicts {
+			conflictsJSON[j] = promptingRuleConflict(conflict)
+		}
+		importConflictsJSON[i] = promptingRuleImportConflict{
+			Index:     importConflict.Index,
+			Conflicts: conflictsJSON,
+		}
+	}
+	return json.Marshal(&struct {
+		Rules []promptingRuleImportConflict `json:"rules"`
+	}{
+		Rules: importConflictsJSON,
+	})
+}
+
 func promptingNotRunningError() *apiError {
 	return &apiError{
 		Status:  500, // Internal error
@@ -296,8 +328,11 @@ func promptingError(err error) *apiError {
 		apiErr.Status = 409
 		apiErr.Kind = client.ErrorKindInterfacesRequestsRuleConflict
 		var conflictErr *prompting_errors.RuleConflictError
+		var importConflictErr *prompting_errors.RuleImportConflictError
 		if errors.As(err, &conflictErr) {
 			apiErr.Value = (*promptingRuleConflictError)(conflictErr)
+		} else if errors.As(err, &importConflictErr) {
+			apiErr.Value = (*promptingRuleImportConflictError)(importConflictErr)
 		}
 	case errors.Is(err, prompting_errors.ErrPromptingClosed):
 		apiErr.Status = 503 // Service Unavailable (down for maintenance)
@@ -345,7 +380,7 @@ type addRuleContents struct {
 	Constraints prompting.ConstraintsJSON `json:"constraints"`
 }
 
-type removeRulesSelector struct {
+type rulesSelector struct {
 	Snap      string `json:"snap,omitempty"`
 	Interface string `json:"interface,omitempty"`
 }
@@ -356,9 +391,10 @@ type patchRuleContents struct {
 }
 
 type postRulesRequestBody struct {
-	Action         string               `json:"action"`
-	AddRule        *addRuleContents     `json:"rule,omitempty"`
-	RemoveSelector *removeRulesSelector `json:"selector,omitempty"`
+	Action      string                       `json:"action"`
+	AddRule     *addRuleContents             `json:"rule,omitempty"`
+	Selector    *rulesSelector               `json:"selector,omitempty"`
+	ImportRules []*requestrules.ExportedRule `json:"rules,omitempty"`
 }
 
 type postRuleRequestBody struct {
@@ -622,26 +658,53 @@ func postRules(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 		return SyncResponse(newRule)
 	case "remove":
-		if postBody.RemoveSelector == nil {
+		if postBody.Selector == nil {
 			return promptingError(prompting_errors.NewMissingFieldError("selector", `must include "selector" field in request body when action is "remove"`))
 		}
-		if postBody.RemoveSelector.Snap == "" && postBody.RemoveSelector.Interface == "" {
+		if postBody.Selector.Snap == "" && postBody.Selector.Interface == "" {
 			// XXX: ideally we'd marshal a map[string]invalidFieldValue with
 			// more than one field field name, but don't yet have the setup to
 			// do so.
 			return BadRequest(`must include "snap" and/or "interface" field in "selector"`)
 		}
-		removedRules, err := getInterfaceManager(c).InterfacesRequestsManager().RemoveRules(userID, postBody.RemoveSelector.Snap, postBody.RemoveSelector.Interface)
+		removedRules, err := getInterfaceManager(c).InterfacesRequestsManager().RemoveRules(userID, postBody.Selector.Snap, postBody.Selector.Interface)
 		if err != nil {
 			return promptingError(err)
 		}
 		return SyncResponse(removedRules)
+	case "export":
+		// The selector is optional when exporting rules
+		var snap, iface string
+		if postBody.Selector != nil {
+			snap = postBody.Selector.Snap
+			iface = postBody.Selector.Interface
+		}
+		exportedRules, err := getInterfaceManager(c).InterfacesRequestsManager().ExportRules(userID, snap, iface)
+		if err != nil {
+			return promptingError(err)
+		}
+		if len(exportedRules) == 0 {
+			exportedRules = []*requestrules.ExportedRule{}
+		}
+		return SyncResponse(exportedRules)
+	case "import":
+		if postBody.ImportRules == nil {
+			return promptingError(prompting_errors.NewMissingFieldError("rules", `must include "rules" field in request body when action is "import"`))
+		}
+		importedRules, err := getInterfaceManager(c).InterfacesRequestsManager().ImportRules(userID, postBody.ImportRules)
+		if err != nil {
+			return promptingError(err)
+		}
+		if len(importedRules) == 0 {
+			importedRules = []*requestrules.Rule{}
+		}
+		return SyncResponse(importedRules)
 	default:
 		return promptingError(&prompting_errors.UnsupportedValueError{
 			Field:     "action",
-			Msg:       `"action" field must be "add" or "remove"`,
+			Msg:       `"action" field must be "add", "remove", "export", or "import"`,
 			Value:     []string{postBody.Action},
-			Supported: []string{"add", "remove"},
+			Supported: []string{"add", "remove", "export", "import"},
 		})
 	}
 }
@@ -721,3 +784,39 @@ func postRule(c *Command, r *http.Request, user *auth.UserState) Response {
 		})
 	}
 }
+
+// getEvaluateRequest checks whether a hypothetical request with the snap,
+// interface, path, and permissions given in the query would be allowed or
+// denied by the existing rules, without creating a prompt.
+func getEvaluateRequest(c *Command, r *http.Request, user *auth.UserState) Response {
+	userID, errorResp := getUserID(r)
+	if errorResp != nil {
+		return errorResp
+	}
+
+	if !getInterfaceManager(c).AppArmorPromptingRunning() {
+		return promptingNotRunningError()
+	}
+
+	query := r.URL.Query()
+	snap := query.Get("snap")
+	if snap == "" {
+		return promptingError(prompting_errors.NewMissingFieldError("snap", `must have non-empty "snap" parameter`))
+	}
+	iface := query.Get("interface")
+	if iface == "" {
+		return promptingError(prompting_errors.NewMissingFieldError("interface", `must have non-empty "interface" parameter`))
+	}
+	path := query.Get("path")
+	if path == "" {
+		return promptingError(prompting_errors.NewMissingFieldError("path", `must have non-empty "path" parameter`))
+	}
+	permissions := strutil.MultiCommaSeparatedList(query["permissions"])
+
+	decision, err := getInterfaceManager(c).InterfacesRequestsManager().EvaluateRequest(userID, snap, iface, path, permissions)
+	if err != nil {
+		return promptingError(err)
+	}
+
+	return SyncResponse(decision)
+}
@@ -50,6 +50,8 @@ type fakeInterfacesRequestsManager struct {
 	prompt       *requestprompts.Prompt
 	rule         *requestrules.Rule
 	satisfiedIDs []prompting.IDType
+	decision     *requestrules.RequestDecision
+	exported     []*requestrules.ExportedRule
 	err          error
 
 	// Store most recent received values
@@ -58,6 +60,9 @@ type fakeInterfacesRequestsManager struct {
 	iface                string
 	pid                  int32
 	cgroup               string
+	path                 string
+	permissions          []string
+	imported             []*requestrules.ExportedRule
 	id                   prompting.IDType // used for prompt ID or rule ID
 	ruleConstraintsJSON  prompting.ConstraintsJSON
 	constraintsPatchJSON prompting.ConstraintsJSON
@@ -142,6 +147,28 @@ func (m *fakeInterfacesRequestsManager) RemoveRule(userID uint32, ruleID prompti
 	return m.rule, m.err
 }
 
+func (m *fakeInterfacesRequestsManager) ExportRules(userID uint32, snap string, iface string) ([]*requestrules.ExportedRule, error) {
+	m.userID = userID
+	m.snap = snap
+	m.iface = iface
+	return m.exported, m.err
+}
+
+func (m *fakeInterfacesRequestsManager) ImportRules(userID uint32, rules []*requestrules.ExportedRule) ([]*requestrules.Rule, error) {
+	m.userID = userID
+	m.imported = rules
+	return m.rules, m.err
+}
+
+func (m *fakeInterfacesRequestsManager) EvaluateRequest(userID uint32, snap string, iface string, path string, permissions []string) (*requestrules.RequestDecision, error) {
+	m.userID = userID
+	m.snap = snap
+	m.iface = iface
+	m.path = path
+	m.permissions = permissions
+	return m.decision, m.err
+}
+
 type promptingSuite struct {
 	apiBaseSuite
 
@@ -644,6 +671,45 @@ func (s *promptingSuite) TestPromptingError(c *C) {
 				"type":        "error",
 			},
 		},
+		{
+			err: &prompting_errors.RuleImportConflictError{
+				Conflicts: []prompting_errors.RuleImportConflict{
+					{
+						Index: 2,
+						Conflicts: []prompting_errors.RuleConflict{
+							{
+								Permission:    "foo",
+								Variant:       "variant 1",
+								ConflictingID: "conflicting rule 1",
+							},
+						},
+					},
+				},
+			},
+			body: map[string]any{
+				"result": map[string]any{
+					"message": "cannot import rules: 1 rules conflict with existing rules",
+					"kind":    "interfaces-requests-rule-conflict",
+					"value": map[string]any{
+						"rules": []any{
+							map[string]any{
+								"index": 2.0,
+								"conflicts": []any{
+									map[string]any{
+										"permission":     "foo",
+										"variant":        "variant 1",
+										"conflicting-id": "conflicting rule 1",
+									},
+								},
+							},
+						},
+					},
+				},
+				"status":      "Conflict",
+				"status-code": 409.0,
+				"type":        "error",
+			},
+		},
 		{
 			err: fmt.Errorf("some arbitrary error"),
 			body: map[string]any{
@@ -1564,6 +1630,75 @@ func (s *promptingSuite) TestGetRulesErrors(c *C) {
 	s.manager.err = nil
 }
 
+func (s *promptingSuite) TestGetEvaluateRequestHappy(c *C) {
+	s.daemon(c)
+
+	s.manager.decision = &requestrules.RequestDecision{
+		AllowedPermissions:     []string{"read"},
+		DeniedPermissions:      []string{"write"},
+		OutstandingPermissions: []string{},
+		MatchedRules: map[string][]prompting.IDType{
+			"read":  {prompting.IDType(0x1234)},
+			"write": {prompting.IDType(0x5678)},
+		},
+	}
+
+	rsp := s.makeSyncReq(c, "GET", "/v2/interfaces/requests/evaluate?snap=firefox&interface=home&path=/home/test/foo&permissions=read,write", 1000, nil)
+
+	c.Check(s.manager.userID, Equals, uint32(1000))
+	c.Check(s.manager.snap, Equals, "firefox")
+	c.Check(s.manager.iface, Equals, "home")
+	c.Check(s.manager.path, Equals, "/home/test/foo")
+	c.Check(s.manager.permissions, DeepEquals, []string{"read", "write"})
+
+	c.Check(rsp.Result, DeepEquals, s.manager.decision)
+
+	// Check the JSON encoding of the decision
+	data, err := json.Marshal(rsp.Result)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"allowed-permissions":["read"],"denied-permissions":["write"],"outstanding-permissions":[],"matched-rules":{"read":["0000000000001234"],"write":["0000000000005678"]}}`)
+}
+
+func (s *promptingSuite) TestGetEvaluateRequestErrors(c *C) {
+	s.daemon(c)
+
+	makeErrorReq := func(path string) *daemon.APIError {
+		req, err := http.NewRequest("GET", path, nil)
+		c.Assert(err, IsNil)
+		req.RemoteAddr = "pid=100;uid=1000;socket=;"
+		return s.errorReq(c, req, nil, actionIsExpected)
+	}
+
+	// Prompting not running
+	s.appArmorPromptingRunning = false
+	rspe := makeErrorReq("/v2/interfaces/requests/evaluate?snap=firefox&interface=home&path=/foo&permissions=read")
+	c.Check(rspe.Status, Equals, 500)
+	c.Check(rspe.Kind, Equals, client.ErrorKindAppArmorPromptingNotRunning)
+	s.appArmorPromptingRunning = true
+
+	for _, testCase := range []struct {
+		query  string
+		errStr string
+	}{
+		{"interface=home&path=/foo&permissions=read", `must have non-empty "snap" parameter`},
+		{"snap=firefox&path=/foo&permissions=read", `must have non-empty "interface" parameter`},
+		{"snap=firefox&interface=home&permissions=read", `must have non-empty "path" parameter`},
+	} {
+		rspe := makeErrorReq("/v2/interfaces/requests/evaluate?" + testCase.query)
+		c.Check(rspe.Status, Equals, 400)
+		c.Check(rspe.Kind, Equals, client.ErrorKindInterfacesRequestsInvalidFields)
+		c.Check(rspe.Message, Equals, testCase.errStr)
+	}
+
+	// Error from manager
+	s.manager.err = prompting_errors.NewInvalidPathError("foo", "path must be absolute")
+	rspe = makeErrorReq("/v2/interfaces/requests/evaluate?snap=firefox&interface=home&path=foo&permissions=read")
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Kind, Equals, client.ErrorKindInterfacesRequestsInvalidFields)
+	c.Check(rspe.Message, Equals, `invalid path: path must be absolute: "foo"`)
+	s.manager.err = nil
+}
+
 func (s *promptingSuite) TestPostRulesAddHappy(c *C) {
 	s.expectWriteAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}})
 
@@ -1687,13 +1822,13 @@ func (s *promptingSuite) TestPostRulesRemoveHappy(c *C) {
 			},
 		}
 
-		contents := &daemon.RemoveRulesSelector{
+		contents := &daemon.RulesSelector{
 			Snap:      testCase.snap,
 			Interface: testCase.iface,
 		}
 		postBody := &daemon.PostRulesRequestBody{
-			Action:         "remove",
-			RemoveSelector: contents,
+			Action:   "remove",
+			Selector: contents,
 		}
 
 		marshalled, err := json.Marshal(postBody)
@@ -1713,6 +1848,97 @@ func (s *promptingSuite) TestPostRulesRemoveHappy(c *C) {
 	}
 }
 
+func (s *promptingSuite) TestPostRulesExportHappy(c *C) {
+	s.expectWriteAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}})
+	s.daemon(c)
+
+	exported := []*requestrules.ExportedRule{
+		{
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/foo"`),
+				"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+			},
+		},
+	}
+
+	for _, testCase := range []struct {
+		body  string
+		snap  string
+		iface string
+	}{
+		{`{"action":"export"}`, "", ""},
+		{`{"action":"export","selector":{"snap":"firefox"}}`, "firefox", ""},
+		{`{"action":"export","selector":{"snap":"firefox","interface":"home"}}`, "firefox", "home"},
+	} {
+		s.manager = &fakeInterfacesRequestsManager{
+			exported: exported,
+		}
+
+		rsp := s.makeSyncReq(c, "POST", "/v2/interfaces/requests/rules", 1234, []byte(testCase.body))
+
+		c.Check(s.manager.userID, Equals, uint32(1234))
+		c.Check(s.manager.snap, Equals, testCase.snap)
+		c.Check(s.manager.iface, Equals, testCase.iface)
+		c.Check(rsp.Result, DeepEquals, exported)
+	}
+
+	// Daemon remaps nil to empty slice
+	s.manager.exported = nil
+	rsp := s.makeSyncReq(c, "POST", "/v2/interfaces/requests/rules", 1234, []byte(`{"action":"export"}`))
+	c.Check(rsp.Result, DeepEquals, []*requestrules.ExportedRule{})
+}
+
+func (s *promptingSuite) TestPostRulesImportHappy(c *C) {
+	s.expectWriteAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}})
+	s.daemon(c)
+
+	s.manager.rules = []*requestrules.Rule{
+		{
+			ID:        prompting.IDType(1234),
+			Timestamp: time.Now(),
+			User:      1234,
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: &prompting.RuleConstraints{
+				InterfaceSpecific: &prompting.InterfaceSpecificConstraintsHome{
+					Pattern: mustParsePathPattern(c, "/home/test/foo"),
+				},
+				Permissions: prompting.RulePermissionMap{
+					"read": &prompting.RulePermissionEntry{
+						Outcome:  prompting.OutcomeAllow,
+						Lifespan: prompting.LifespanForever,
+					},
+				},
+			},
+		},
+	}
+
+	toImport := []*requestrules.ExportedRule{
+		{
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/foo"`),
+				"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+			},
+		},
+	}
+	postBody := &daemon.PostRulesRequestBody{
+		Action:      "import",
+		ImportRules: toImport,
+	}
+	marshalled, err := json.Marshal(postBody)
+	c.Assert(err, IsNil)
+
+	rsp := s.makeSyncReq(c, "POST", "/v2/interfaces/requests/rules", 1234, marshalled)
+
+	c.Check(s.manager.userID, Equals, uint32(1234))
+	c.Check(s.manager.imported, DeepEquals, toImport)
+	c.Check(rsp.Result, DeepEquals, s.manager.rules)
+}
+
 func (s *promptingSuite) TestPostRulesErrors(c *C) {
 	s.expectWriteAccess(daemon.InterfaceOpenAccess{Interfaces: []string{"snap-interfaces-requests-control"}})
 	s.daemon(c)
@@ -1801,6 +2027,26 @@ func (s *promptingSuite) TestPostRulesErrors(c *C) {
 	c.Check(rspe.Kind, Equals, client.ErrorKind(""))
 	c.Check(rspe.Message, Equals, `must include "snap" and/or "interface" field in "selector"`)
 
+	// Missing "rules"
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/rules", bytes.NewReader([]byte(`{"action":"import"}`)))
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rspe = s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Kind, Equals, client.ErrorKindInterfacesRequestsInvalidFields)
+	c.Check(rspe.Message, Equals, `must include "rules" field in request body when action is "import"`)
+
+	// Invalid action
+	req, err = http.NewRequest("POST", "/v2/interfaces/requests/rules", bytes.NewReader([]byte(`{"action":"foo"}`)))
+	c.Assert(err, IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rspe = s.errorReq(c, req, nil, actionIsUnexpected)
+	c.Check(rspe.Status, Equals, 400)
+	c.Check(rspe.Kind, Equals, client.ErrorKindInterfacesRequestsInvalidFields)
+	c.Check(rspe.Message, Equals, `"action" field must be "add", "remove", "export", or "import"`)
+
+	validImportBody := []byte(`{"action":"import","rules":[{"snap":"thunderbird","interface":"home","constraints":{"path-pattern":"/home/test/foo","permissions":{"read":{"outcome":"allow","lifespan":"forever"}}}}]}`)
+
 	// Errors from manager
 	for _, testCase := range []struct {
 		body         []byte
@@ -1809,6 +2055,22 @@ func (s *promptingSuite) TestPostRulesErrors(c *C) {
 		expectedKind client.ErrorKind
 		expectedMsg  string
 	}{
+		{
+			body: validImportBody,
+			err: &prompting_errors.RuleImportConflictError{Conflicts: []prompting_errors.RuleImportConflict{
+				{Index: 0, Conflicts: []prompting_errors.RuleConflict{{Permission: "read", Variant: "/home/test/foo", ConflictingID: "abcd"}}},
+			}},
+			expectedCode: 409,
+			expectedKind: client.ErrorKindInterfacesRequestsRuleConflict,
+			expectedMsg:  "cannot import rules: 1 rules conflict with existing rules",
+		},
+		{
+			body:         validImportBody,
+			err:          fmt.Errorf("cannot import rule 0: %w", prompting_errors.NewInvalidInterfaceError("foo", []string{"home"})),
+			expectedCode: 400,
+			expectedKind: client.ErrorKindInterfacesRequestsInvalidFields,
+			expectedMsg:  `cannot import rule 0: invalid interface: "foo"`,
+		},
 		{
 			body:         validAddBody,
 			err:          prompting_errors.NewInvalidPathPatternError("foo", "must start with '/'"),
@@ -25,12 +25,14 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var (
@@ -86,9 +88,44 @@ var getQuotaUsage = func(grp *quota.Group) (*client.QuotaValues, error) {
 		currentUsage.Threads = threads
 	}
 
+	// the network traffic is accounted for all groups, but the accounting
+	// is not available on all systems, in which case it is left out
+	ingress, egress, err := grp.CurrentNetworkUsage()
+	switch {
+	case err == systemd.ErrNetworkAccountingUnavailable:
+		logger.Debugf("cannot get network usage of quota group %q: %v", grp.Name, err)
+	case err != nil:
+		return nil, err
+	default:
+		currentUsage.Network = &client.QuotaNetworkValues{
+			IngressBytes: ingress,
+			EgressBytes:  egress,
+		}
+	}
+
 	return &currentUsage, nil
 }
 
+var servicestateQuotaUsageHistory = servicestate.QuotaUsageHistory
+
+func quotaUsageHistory(st *state.State, groupName string) []client.QuotaUsageSample {
+	samples := servicestateQuotaUsageHistory(st, groupName)
+	if len(samples) == 0 {
+		return nil
+	}
+	history := make([]client.QuotaUsageSample, len(samples))
+	for i, sample := range samples {
+		history[i] = client.QuotaUsageSample{
+			Time:    sample.Time,
+			Memory:  sample.Memory,
+			CPUTime: sample.CPUTime,
+			Threads: sample.Threads,
+			Journal: sample.Journal,
+		}
+	}
+	return history
+}
+
 func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 	var constraints client.QuotaValues
 	constraints.Memory = grp.MemoryLimit
@@ -114,6 +151,14 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			}
 		}
 	}
+	for _, dev := range grp.IOLimit {
+		constraints.IO = append(constraints.IO, client.QuotaIOValues(dev))
+	}
+	if grp.NetworkLimit != nil {
+		constraints.Network = &client.QuotaNetworkValues{
+			EgressRate: grp.NetworkLimit.EgressRate,
+		}
+	}
 	return &constraints
 }
 
@@ -184,13 +229,14 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 	}
 
 	res := client.QuotaGroupResult{
-		GroupName:   group.Name,
-		Parent:      group.ParentGroup,
-		Snaps:       group.Snaps,
-		Services:    group.Services,
-		Subgroups:   group.SubGroups,
-		Constraints: createQuotaValues(group),
-		Current:     currentUsage,
+		GroupName:    group.Name,
+		Parent:       group.ParentGroup,
+		Snaps:        group.Snaps,
+		Services:     group.Services,
+		Subgroups:    group.SubGroups,
+		Constraints:  createQuotaValues(group),
+		Current:      currentUsage,
+		UsageHistory: quotaUsageHistory(st, group.Name),
 	}
 	return SyncResponse(res)
 }
@@ -223,6 +269,12 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
 	}
+	for _, dev := range values.IO {
+		resourcesBuilder.WithIODeviceLimit(quota.ResourceIODevice(dev))
+	}
+	if values.Network != nil {
+		resourcesBuilder.WithNetworkEgressRate(values.Network.EgressRate)
+	}
 	return resourcesBuilder.Build()
 }
 
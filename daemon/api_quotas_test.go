@@ -90,6 +90,8 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			WithCPUSet([]int{0, 1}).
 			WithJournalRate(150, time.Second).
 			WithJournalSize(quantity.SizeMiB).
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB, WriteIOPS: 100}).
+			WithNetworkEgressRate(quantity.SizeMiB).
 			Build())
 	allGroups, err2 := servicestate.AllQuotas(st)
 	st.Unlock()
@@ -118,6 +120,47 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			RatePeriod: time.Second,
 		},
 	})
+	c.Check(quotaValues.IO, check.DeepEquals, []client.QuotaIOValues{
+		{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB, WriteIOPS: 100},
+	})
+	c.Check(quotaValues.Network, check.DeepEquals, &client.QuotaNetworkValues{
+		EgressRate: quantity.SizeMiB,
+	})
+}
+
+func (s *apiQuotaSuite) TestGetQuotaUsageNetwork(c *check.C) {
+	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build())
+	c.Assert(err, check.IsNil)
+
+	accounting := true
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		switch {
+		case args[0] == "is-active":
+			return []byte("active"), nil
+		case !accounting:
+			return []byte(fmt.Sprintf("%s=[no data]", args[2])), nil
+		case args[2] == "IPIngressBytes":
+			return []byte("IPIngressBytes=4096"), nil
+		case args[2] == "IPEgressBytes":
+			return []byte("IPEgressBytes=1024"), nil
+		}
+		c.Errorf("unexpected systemctl call %v", args)
+		return nil, fmt.Errorf("unexpected")
+	})
+	defer r()
+
+	usage, err := daemon.GetQuotaUsage(grp)
+	c.Assert(err, check.IsNil)
+	c.Check(usage.Network, check.DeepEquals, &client.QuotaNetworkValues{
+		IngressBytes: 4096,
+		EgressBytes:  1024,
+	})
+
+	// the network usage is left out if ip accounting is not available
+	accounting = false
+	usage, err = daemon.GetQuotaUsage(grp)
+	c.Assert(err, check.IsNil)
+	c.Check(usage.Network, check.IsNil)
 }
 
 func (s *apiQuotaSuite) TestPostQuotaUnknownAction(c *check.C) {
@@ -328,6 +371,45 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaCreateJournalRateZeroHappy(c *check.C
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateIOAndNetworkHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/mmcblk0", WriteBandwidth: 5 * quantity.SizeMiB}).
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 500}).
+			WithNetworkEgressRate(quantity.SizeMiB).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			IO: []client.QuotaIOValues{
+				{Device: "/dev/mmcblk0", WriteBandwidth: 5 * quantity.SizeMiB},
+				{Device: "/dev/sda", ReadIOPS: 500},
+			},
+			Network: &client.QuotaNetworkValues{
+				EgressRate: quantity.SizeMiB,
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+	c.Assert(s.ensureSoonCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateCpuHappy(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -846,6 +928,35 @@ func (s *apiQuotaSuite) TestGetQuota(c *check.C) {
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
 
+func (s *apiQuotaSuite) TestGetQuotaUsageHistory(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	mockQuotas(st, c)
+	st.Unlock()
+
+	defer daemon.MockGetQuotaUsage(func(grp *quota.Group) (*client.QuotaValues, error) {
+		return &client.QuotaValues{Memory: quantity.Size(500)}, nil
+	})()
+	t0 := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	defer daemon.MockServicestateQuotaUsageHistory(func(_ *state.State, groupName string) []servicestate.QuotaUsageSample {
+		c.Check(groupName, check.Equals, "bar")
+		return []servicestate.QuotaUsageSample{
+			{Time: t0, Memory: 400, CPUTime: time.Second, Threads: 3},
+			{Time: t0.Add(time.Minute), Memory: 500, CPUTime: 2 * time.Second, Threads: 4, Journal: 1024},
+		}
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res.UsageHistory, check.DeepEquals, []client.QuotaUsageSample{
+		{Time: t0, Memory: 400, CPUTime: time.Second, Threads: 3},
+		{Time: t0.Add(time.Minute), Memory: 500, CPUTime: 2 * time.Second, Threads: 4, Journal: 1024},
+	})
+}
+
 func (s *apiQuotaSuite) TestGetQuotaInvalidName(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
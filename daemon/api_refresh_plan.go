@@ -0,0 +1,46 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+)
+
+var refreshPlanCmd = &Command{
+	Path:       "/v2/refresh-plan",
+	GET:        getRefreshPlan,
+	ReadAccess: openAccess{},
+}
+
+// getRefreshPlan returns what an auto-refresh would do if it ran now,
+// without creating any change.
+func getRefreshPlan(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	plan, err := snapstateRefreshPlanPreview(r.Context(), st)
+	if err != nil {
+		return errToResponse(err, nil, InternalError, "cannot compute refresh plan: %v")
+	}
+	return SyncResponse(plan)
+}
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&refreshPlanSuite{})
+
+type refreshPlanSuite struct {
+	apiBaseSuite
+}
+
+func (s *refreshPlanSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.daemon(c)
+}
+
+func (s *refreshPlanSuite) TestGetRefreshPlan(c *check.C) {
+	plan := &snapstate.RefreshPlan{
+		Snaps: []*snapstate.RefreshPlanSnap{{
+			Name:            "foo",
+			CurrentRevision: snap.R(1),
+			Revision:        snap.R(2),
+			Channel:         "stable",
+			DownloadSize:    1000,
+			HeldBy:          []string{"bar"},
+		}},
+	}
+	called := 0
+	restore := daemon.MockSnapstateRefreshPlanPreview(func(ctx context.Context, st *state.State) (*snapstate.RefreshPlan, error) {
+		called++
+		// the state is locked
+		st.Unlock()
+		st.Lock()
+		return plan, nil
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/refresh-plan", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, plan)
+	c.Check(called, check.Equals, 1)
+}
+
+func (s *refreshPlanSuite) TestGetRefreshPlanError(c *check.C) {
+	restore := daemon.MockSnapstateRefreshPlanPreview(func(ctx context.Context, st *state.State) (*snapstate.RefreshPlan, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/refresh-plan", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot compute refresh plan: boom")
+}
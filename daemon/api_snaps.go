@@ -284,6 +284,7 @@ type snapInstruction struct {
 	Snaps                  []string                         `json:"snaps"`
 	Users                  []string                         `json:"users"`
 	SnapshotOptions        map[string]*snap.SnapshotOptions `json:"snapshot-options"`
+	SnapshotKey            []byte                           `json:"snapshot-key,omitempty"`
 	ValidationSets         []string                         `json:"validation-sets"`
 	QuotaGroupName         string                           `json:"quota-group"`
 	Time                   string                           `json:"time"`
@@ -422,6 +423,9 @@ func (inst *snapInstruction) validate() error {
 	if inst.QuotaGroupName != "" && inst.Action != installCmdAction {
 		return fmt.Errorf("quota-group can only be specified on install")
 	}
+	if inst.SnapshotKey != nil && inst.Action != "snapshot" {
+		return fmt.Errorf("snapshot-key can only be specified for snapshot action")
+	}
 
 	if inst.Action == holdCmdAction {
 		if inst.Time == "" {
@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -97,6 +98,9 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+	// Key is the passphrase or key file content needed to check or
+	// restore an encrypted snapshot
+	Key []byte `json:"key,omitempty"`
 }
 
 func (action snapshotAction) String() string {
@@ -146,15 +150,18 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	var changeKind string
 	switch action.Action {
 	case "check":
-		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users)
+		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users, action.Key)
 		changeKind = checkSnapshotChangeKind
 	case "restore":
-		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users, action.Key)
 		changeKind = restoreSnapshotChangeKind
 	case "forget":
 		if len(action.Users) != 0 {
 			return BadRequest(`snapshot "forget" operation cannot specify users`)
 		}
+		if action.Key != nil {
+			return BadRequest(`snapshot "forget" operation cannot specify a key`)
+		}
 		affected, ts, err = snapshotForget(st, action.SetID, action.Snaps)
 		changeKind = forgetSnapshotChangeKind
 	default:
@@ -193,7 +200,12 @@ func getSnapshotExport(c *Command, r *http.Request, user *auth.UserState) Respon
 		return BadRequest("'id' must be a positive base 10 number; got %q", sid)
 	}
 
-	export, err := snapshotExport(r.Context(), st, setID)
+	key, err := snapshotKeyFromHeader(r)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	export, err := snapshotExport(r.Context(), st, setID, key)
 	if err != nil {
 		return BadRequest("cannot export %v: %v", setID, err)
 	}
@@ -208,6 +220,20 @@ func getSnapshotExport(c *Command, r *http.Request, user *auth.UserState) Respon
 	return &snapshotExportResponse{SnapshotExport: export, setID: setID, st: st}
 }
 
+// snapshotKeyFromHeader returns the passphrase or key file content for an
+// encrypted snapshot given with the request, if any.
+func snapshotKeyFromHeader(r *http.Request) ([]byte, error) {
+	encoded := r.Header.Get(client.SnapshotKeyHeader)
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot key: %v", err)
+	}
+	return key, nil
+}
+
 func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Response {
 	defer r.Body.Close()
 
@@ -215,12 +241,16 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	if err != nil {
 		return BadRequest("cannot parse Content-Length: %v", err)
 	}
+	key, err := snapshotKeyFromHeader(r)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
 	// ensure we don't read more than we expect
 	limitedBodyReader := io.LimitReader(r.Body, expectedSize)
 
 	// XXX: check that we have enough space to import the compressed snapshots
 	st := c.d.overlord.State()
-	setID, snapNames, err := snapshotImport(r.Context(), st, limitedBodyReader)
+	setID, snapNames, err := snapshotImport(r.Context(), st, limitedBodyReader, key)
 	if err != nil {
 		return BadRequest(err.Error())
 	}
@@ -230,7 +260,7 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 }
 
 func snapshotMany(_ context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions, inst.SnapshotKey)
 	if err != nil {
 		return nil, err
 	}
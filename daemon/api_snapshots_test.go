@@ -53,7 +53,7 @@ func (s *snapshotSuite) SetUpTest(c *check.C) {
 
 func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key []byte) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.IsNil)
 		t := s.NewTask("fake-snapshot-2", "Snapshot two")
@@ -74,7 +74,7 @@ func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key []byte) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.HasLen, 2)
@@ -99,9 +99,29 @@ func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	c.Check(snapshotSaveCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestSnapshotManyWithKey(c *check.C) {
+	var snapshotSaveCalled int
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
+		options map[string]*snap.SnapshotOptions, key []byte) (uint64, []string, *state.TaskSet, error) {
+		snapshotSaveCalled++
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		t := s.NewTask("fake-snapshot-2", "Snapshot two")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	inst := daemon.MustUnmarshalSnapInstruction(c, `{"action": "snapshot", "snaps": ["foo", "bar"], "snapshot-key": "c2VjcmV0"}`)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(context.Background(), inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(snapshotSaveCalled, check.Equals, 1)
+}
+
 func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, key []byte) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		return 0, nil, nil, &snap.NotInstalledError{Snap: "foo"}
 	})()
@@ -253,11 +273,11 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -284,11 +304,11 @@ func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -311,11 +331,11 @@ func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 
 func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	var done string
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
@@ -355,10 +375,45 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotWithKey(c *check.C) {
+	var done string
+	defer daemon.MockSnapshotCheck(func(_ *state.State, _ uint64, _, _ []string, key []byte) ([]string, *state.TaskSet, error) {
+		done = "check"
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+	defer daemon.MockSnapshotRestore(func(_ *state.State, _ uint64, _, _ []string, key []byte) ([]string, *state.TaskSet, error) {
+		done = "restore"
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	for _, action := range []string{"check", "restore"} {
+		done = ""
+		comm := check.Commentf("%s", action)
+		body := fmt.Sprintf(`{"set": 42, "action": "%s", "key": "c2VjcmV0"}`, action)
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil, comm)
+
+		rsp := s.asyncReq(c, req, nil, actionIsExpected)
+		c.Check(rsp.Status, check.Equals, 202, comm)
+		c.Check(done, check.Equals, action, comm)
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotForgetWithKey(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"set": 42, "action": "forget", "key": "c2VjcmV0"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `snapshot "forget" operation cannot specify a key`)
+}
+
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, key []byte) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		c.Check(setID, check.Equals, uint64(1))
 		return &snapshotstate.SnapshotExport{}, nil
@@ -372,6 +427,34 @@ func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	c.Check(snapshotExportCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestExportSnapshotsWithKey(c *check.C) {
+	var snapshotExportCalled int
+
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, key []byte) (*snapshotstate.SnapshotExport, error) {
+		snapshotExportCalled++
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		return &snapshotstate.SnapshotExport{}, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(client.SnapshotKeyHeader, "c2VjcmV0")
+
+	rsp := s.req(c, req, nil, actionIsExpected)
+	c.Check(rsp, check.FitsTypeOf, &daemon.SnapshotExportResponse{})
+	c.Check(snapshotExportCalled, check.Equals, 1)
+}
+
+func (s *snapshotSuite) TestExportSnapshotsBadKey(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(client.SnapshotKeyHeader, "not base64!")
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot decode snapshot key: .*`)
+}
+
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/snapshots/xxx/export", nil)
 	c.Assert(err, check.IsNil)
@@ -384,7 +467,7 @@ func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C)
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnError(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, key []byte) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		return nil, fmt.Errorf("boom")
 	})()
@@ -403,7 +486,7 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 
 	setID := uint64(3)
 	snapNames := []string{"baz", "bar", "foo"}
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, []byte) (uint64, []string, error) {
 		return setID, snapNames, nil
 	})()
 
@@ -417,8 +500,26 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, map[string]any{"set-id": setID, "snaps": snapNames})
 }
 
+func (s *snapshotSuite) TestImportSnapshotWithKey(c *check.C) {
+	data := []byte("mocked snapshot export data file")
+
+	defer daemon.MockSnapshotImport(func(_ context.Context, _ *state.State, _ io.Reader, key []byte) (uint64, []string, error) {
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		return 3, []string{"foo"}, nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewReader(data))
+	req.Header.Add("Content-Length", strconv.Itoa(len(data)))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", client.SnapshotExportMediaType)
+	req.Header.Set(client.SnapshotKeyHeader, "c2VjcmV0")
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+}
+
 func (s *snapshotSuite) TestImportSnapshotError(c *check.C) {
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, []byte) (uint64, []string, error) {
 		return uint64(0), nil, errors.New("no")
 	})()
 
@@ -447,7 +548,7 @@ func (s *snapshotSuite) TestImportSnapshotNoContentLengthError(c *check.C) {
 func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	var dataRead int
 
-	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, key []byte) (uint64, []string, error) {
 		data, err := io.ReadAll(r)
 		c.Assert(err, check.IsNil)
 		dataRead = len(data)
@@ -20,7 +20,10 @@
 package daemon
 
 import (
+	"time"
+
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var (
@@ -35,3 +38,7 @@ func MockNoticeReadInterfaces(newMap map[state.NoticeType][]string) (restore fun
 		noticeReadInterfaces = old
 	}
 }
+
+func MockNoticesStreamKeepalive(interval time.Duration) (restore func()) {
+	return testutil.Mock(&noticesStreamKeepalive, interval)
+}
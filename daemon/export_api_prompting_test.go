@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -35,16 +36,17 @@ var (
 type PostInterfacesRequestsRequestBody postInterfacesRequestsRequestBody
 type PostPromptRequestBody postPromptRequestBody
 type AddRuleContents addRuleContents
-type RemoveRulesSelector removeRulesSelector
+type RulesSelector rulesSelector
 type PatchRuleContents patchRuleContents
 
 type PostInterfacesRequestsResponse = postInterfacesRequestsResponse
 
 // When the types have nested contents, must redefine with exported types.
 type PostRulesRequestBody struct {
-	Action         string               `json:"action"`
-	AddRule        *AddRuleContents     `json:"rule,omitempty"`
-	RemoveSelector *RemoveRulesSelector `json:"selector,omitempty"`
+	Action      string                       `json:"action"`
+	AddRule     *AddRuleContents             `json:"rule,omitempty"`
+	Selector    *RulesSelector               `json:"selector,omitempty"`
+	ImportRules []*requestrules.ExportedRule `json:"rules,omitempty"`
 }
 
 type PostRuleRequestBody struct {
@@ -30,6 +30,8 @@ type (
 	PostQuotaGroupData = postQuotaGroupData
 )
 
+var GetQuotaUsage = getQuotaUsage
+
 func MockServicestateCreateQuota(f func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error)) func() {
 	old := servicestateCreateQuota
 	servicestateCreateQuota = f
@@ -61,3 +63,11 @@ func MockGetQuotaUsage(f func(grp *quota.Group) (*client.QuotaValues, error)) (r
 		getQuotaUsage = old
 	}
 }
+
+func MockServicestateQuotaUsageHistory(f func(st *state.State, groupName string) []servicestate.QuotaUsageSample) (restore func()) {
+	old := servicestateQuotaUsageHistory
+	servicestateQuotaUsageHistory = f
+	return func() {
+		servicestateQuotaUsageHistory = old
+	}
+}
@@ -32,7 +32,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions, []byte) (uint64, []string, *state.TaskSet, error)) (restore func()) {
 	oldSave := snapshotSave
 	snapshotSave = newSave
 	return func() {
@@ -48,7 +48,7 @@ func MockSnapshotList(newList func(context.Context, *state.State, uint64, []stri
 	}
 }
 
-func MockSnapshotExport(newExport func(context.Context, *state.State, uint64) (*snapshotstate.SnapshotExport, error)) (restore func()) {
+func MockSnapshotExport(newExport func(context.Context, *state.State, uint64, []byte) (*snapshotstate.SnapshotExport, error)) (restore func()) {
 	oldExport := snapshotExport
 	snapshotExport = newExport
 	return func() {
@@ -56,7 +56,7 @@ func MockSnapshotExport(newExport func(context.Context, *state.State, uint64) (*
 	}
 }
 
-func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldCheck := snapshotCheck
 	snapshotCheck = newCheck
 	return func() {
@@ -64,7 +64,7 @@ func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) (
 	}
 }
 
-func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string, []byte) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldRestore := snapshotRestore
 	snapshotRestore = newRestore
 	return func() {
@@ -80,7 +80,7 @@ func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string
 	}
 }
 
-func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader) (uint64, []string, error)) (restore func()) {
+func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader, []byte) (uint64, []string, error)) (restore func()) {
 	oldImport := snapshotImport
 	snapshotImport = newImport
 	return func() {
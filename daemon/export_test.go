@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/osutil/user"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/confdbstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -299,6 +300,14 @@ func MockSnapstateProceedWithRefresh(f func(st *state.State, gatingSnap string,
 	}
 }
 
+func MockSnapstateRefreshPlanPreview(f func(ctx context.Context, st *state.State) (*snapstate.RefreshPlan, error)) (restore func()) {
+	old := snapstateRefreshPlanPreview
+	snapstateRefreshPlanPreview = f
+	return func() {
+		snapstateRefreshPlanPreview = old
+	}
+}
+
 func MockSnapstateHoldRefreshesBySystem(f func(st *state.State, level snapstate.HoldLevel, time string, snaps []string) error) (restore func()) {
 	old := snapstateHoldRefreshesBySystem
 	snapstateHoldRefreshesBySystem = f
@@ -437,7 +446,25 @@ func MockAssertstateFetchAllValidationSets(f func(*state.State, int, *assertstat
 }
 
 func MockConfdbstateWriteConfdb(f func(context.Context, *state.State, *confdb.View, map[string]any) (string, error)) (restore func()) {
-	return testutil.Mock(&confdbstateWriteConfdb, f)
+	return MockConfdbstateWriteConfdbAsUser(func(ctx context.Context, st *state.State, view *confdb.View, values map[string]any, _ int) (string, error) {
+		return f(ctx, st, view, values)
+	})
+}
+
+func MockConfdbstateWriteConfdbAsUser(f func(context.Context, *state.State, *confdb.View, map[string]any, int) (string, error)) (restore func()) {
+	return testutil.Mock(&confdbstateWriteConfdbAsUser, f)
+}
+
+func MockConfdbstateDatabagRevisions(f func(*state.State, string, string) ([]*confdbstate.DatabagRevision, error)) (restore func()) {
+	return testutil.Mock(&confdbstateDatabagRevisions, f)
+}
+
+func MockConfdbstateConnectedViewIDs(f func(*state.State, string) ([]string, error)) (restore func()) {
+	return testutil.Mock(&confdbstateConnectedViewIDs, f)
+}
+
+func MockConfdbstateRollbackDatabag(f func(context.Context, *state.State, *confdb.View, int, int) (string, error)) (restore func()) {
+	return testutil.Mock(&confdbstateRollbackDatabag, f)
 }
 
 func MockConfdbstateReadConfdb(f func(context.Context, *state.State, *confdb.View, []string, map[string]any, confdb.Access) (string, error)) (restore func()) {
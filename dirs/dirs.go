@@ -67,8 +67,11 @@ var (
 	SnapVoidDir          string
 	SnapPrivateTmpDir    string
 
-	SnapInterfacesRequestsRunDir   string
-	SnapInterfacesRequestsStateDir string
+	SnapInterfacesRequestsRunDir         string
+	SnapInterfacesRequestsStateDir       string
+	SnapInterfacesRequestsSystemRulesDir string
+
+	SnapNoticesArchiveDir string
 
 	SnapdMaintenanceFile string
 
@@ -118,6 +121,8 @@ var (
 	SnapPolkitRuleDir      string
 	SnapSystemdDir         string
 	SnapSystemdRunDir      string
+	SnapJournalDir         string
+	SnapNetworkQuotaDir    string
 
 	SnapDBusSessionPolicyDir   string
 	SnapDBusSystemPolicyDir    string
@@ -609,6 +614,9 @@ func SetRootDir(rootdir string) {
 
 	SnapInterfacesRequestsRunDir = filepath.Join(SnapRunDir, "interfaces-requests")
 	SnapInterfacesRequestsStateDir = filepath.Join(rootdir, snappyDir, "interfaces-requests")
+	SnapInterfacesRequestsSystemRulesDir = filepath.Join(rootdir, "/etc/snapd/interfaces-requests/system-rules.d")
+
+	SnapNoticesArchiveDir = filepath.Join(rootdir, snappyDir, "notices-archive")
 
 	SnapdStoreSSLCertsDir = filepath.Join(rootdir, snappyDir, "ssl/store-certs")
 	SnapdPKIV1Dir = filepath.Join(rootdir, snappyDir, "pki", "v1")
@@ -662,6 +670,8 @@ func SetRootDir(rootdir string) {
 	SnapSystemdConfDir = SnapSystemdConfDirUnder(rootdir)
 	SnapSystemdDir = filepath.Join(rootdir, "/etc/systemd")
 	SnapSystemdRunDir = filepath.Join(rootdir, "/run/systemd")
+	SnapJournalDir = filepath.Join(rootdir, "/var/log/journal")
+	SnapNetworkQuotaDir = filepath.Join(rootdir, snappyDir, "quota", "network")
 
 	SnapDBusSystemPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
 	SnapDBusSessionPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/session.d")
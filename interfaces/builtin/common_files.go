@@ -36,6 +36,9 @@ type commonFilesInterface struct {
 
 	apparmorHeader    string
 	extraPathValidate func(string) error
+	// promptPrefix marks the generated rules with the prompt prefix, so
+	// that accesses can be mediated by AppArmor prompting when enabled.
+	promptPrefix bool
 }
 
 // filesAAPerm can either be files{Read,Write} and converted to a string
@@ -76,12 +79,15 @@ func formatPath(ip any) (string, error) {
 	return fmt.Sprintf("%s%q", prefix, p), nil
 }
 
-func allowPathAccess(buf *bytes.Buffer, perm filesAAPerm, paths []any) error {
+func allowPathAccess(buf *bytes.Buffer, perm filesAAPerm, paths []any, promptPrefix bool) error {
 	for _, rawPath := range paths {
 		p, err := formatPath(rawPath)
 		if err != nil {
 			return err
 		}
+		if promptPrefix {
+			buf.WriteString("###PROMPT### ")
+		}
 		fmt.Fprintf(buf, "%s %s,\n", p, perm)
 	}
 	return nil
@@ -162,10 +168,10 @@ func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specific
 
 	errPrefix := fmt.Sprintf(`cannot connect plug %s: `, plug.Name())
 	buf := bytes.NewBufferString(iface.apparmorHeader)
-	if err := allowPathAccess(buf, filesRead, reads); err != nil {
+	if err := allowPathAccess(buf, filesRead, reads, iface.promptPrefix); err != nil {
 		return fmt.Errorf("%s%v", errPrefix, err)
 	}
-	if err := allowPathAccess(buf, filesWrite, writes); err != nil {
+	if err := allowPathAccess(buf, filesWrite, writes, iface.promptPrefix); err != nil {
 		return fmt.Errorf("%s%v", errPrefix, err)
 	}
 	spec.AddSnippet(buf.String())
@@ -65,6 +65,33 @@ func validateSinglePathHome(np string) error {
 	return nil
 }
 
+// DetectPersonalFilesFromPath returns true if the given path corresponds to
+// an AppArmor rule with the prompt prefix from the personal-files interface.
+//
+// Since the home interface does not grant access to top-level hidden files
+// and directories in the user's home directory, accesses to those are
+// attributed to personal-files.
+//
+// XXX: this is only necessary until metadata tags are fully supported by the
+// AppArmor parser and kernel. Then, this function should be removed.
+func DetectPersonalFilesFromPath(path string) bool {
+	var rest string
+	switch {
+	case strings.HasPrefix(path, "/root/"):
+		rest = strings.TrimPrefix(path, "/root/")
+	case strings.HasPrefix(path, "/home/"):
+		_, afterUser, ok := strings.Cut(strings.TrimPrefix(path, "/home/"), "/")
+		if !ok {
+			return false
+		}
+		rest = afterUser
+	default:
+		return false
+	}
+	return strings.HasPrefix(rest, ".") && rest != "." && rest != ".." &&
+		!strings.HasPrefix(rest, "./") && !strings.HasPrefix(rest, "../")
+}
+
 func init() {
 	registerIface(&personalFilesInterface{
 		commonFilesInterface{
@@ -78,6 +105,7 @@ func init() {
 			},
 			apparmorHeader:    personalFilesConnectedPlugAppArmor,
 			extraPathValidate: validateSinglePathHome,
+			promptPrefix:      true,
 		},
 	})
 }
@@ -82,13 +82,13 @@ func (s *personalFilesInterfaceSuite) TestConnectedPlugAppArmorHappy(c *C) {
 # Description: Can access specific personal files or directories in the 
 # users's home directory.
 # This is restricted because it gives file access to arbitrary locations.
-owner "@{HOME}/.read-dir{,/,/**}" rk,
-owner "@{HOME}/.read-file{,/,/**}" rk,
-owner "@{HOME}/.local/share/target{,/,/**}" rk,
-owner "@{HOME}/.write-dir{,/,/**}" rwkl,
-owner "@{HOME}/.write-file{,/,/**}" rwkl,
-owner "@{HOME}/.local/share/target{,/,/**}" rwkl,
-owner "@{HOME}/.local/share/dir1/dir2/target{,/,/**}" rwkl,
+###PROMPT### owner "@{HOME}/.read-dir{,/,/**}" rk,
+###PROMPT### owner "@{HOME}/.read-file{,/,/**}" rk,
+###PROMPT### owner "@{HOME}/.local/share/target{,/,/**}" rk,
+###PROMPT### owner "@{HOME}/.write-dir{,/,/**}" rwkl,
+###PROMPT### owner "@{HOME}/.write-file{,/,/**}" rwkl,
+###PROMPT### owner "@{HOME}/.local/share/target{,/,/**}" rwkl,
+###PROMPT### owner "@{HOME}/.local/share/dir1/dir2/target{,/,/**}" rwkl,
 `)
 
 	c.Check("\n"+strings.Join(apparmorSpec.UpdateNS(), "\n"), Equals, `
@@ -262,3 +262,27 @@ plugs:
 func (s *personalFilesInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *personalFilesInterfaceSuite) TestDetectPersonalFilesFromPath(c *C) {
+	for _, path := range []string{
+		"/home/ubuntu/.config",
+		"/home/ubuntu/.config/foo/bar",
+		"/home/ubuntu/.bashrc",
+		"/root/.local/share/foo",
+	} {
+		c.Check(builtin.DetectPersonalFilesFromPath(path), Equals, true, Commentf("%q should be detected as personal-files path", path))
+	}
+
+	for _, path := range []string{
+		"/home/ubuntu",
+		"/home/ubuntu/",
+		"/home/ubuntu/Documents/.hidden",
+		"/home/.hidden",
+		"/home/ubuntu/./foo",
+		"/root/foo",
+		"/etc/.hidden",
+		"/dev/video0",
+	} {
+		c.Check(builtin.DetectPersonalFilesFromPath(path), Equals, false, Commentf("%q should not be detected as personal-files path", path))
+	}
+}
@@ -19,6 +19,10 @@
 
 package builtin
 
+import (
+	"strings"
+)
+
 const removableMediaSummary = `allows access to mounted removable storage`
 
 const removableMediaBaseDeclarationSlots = `
@@ -41,14 +45,33 @@ const removableMediaConnectedPlugAppArmor = `
 
 # Mount points could be in /run/media/<user>/* or /media/<user>/*
 /{,run/}media/*/ r,
-/{,run/}media/*/** mrwklix,
+###PROMPT### /{,run/}media/*/** mrwklix,
 
 # Allow read-only access to /mnt to enumerate items.
 /mnt/ r,
 # Allow write access to anything under /mnt
-/mnt/** mrwklix,
+###PROMPT### /mnt/** mrwklix,
 `
 
+// DetectRemovableMediaFromPath returns true if the given path corresponds to
+// an AppArmor rule with the prompt prefix from the removable-media interface.
+//
+// XXX: this is only necessary until metadata tags are fully supported by the
+// AppArmor parser and kernel. Then, this function should be removed.
+func DetectRemovableMediaFromPath(path string) bool {
+	for _, prefix := range []string{"/media/", "/run/media/"} {
+		// Mount points are in /media/<user>/* or /run/media/<user>/*
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		user, mountPath, ok := strings.Cut(strings.TrimPrefix(path, prefix), "/")
+		if ok && user != "" && mountPath != "" {
+			return true
+		}
+	}
+	return strings.HasPrefix(path, "/mnt/") && len(path) > len("/mnt/")
+}
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "removable-media",
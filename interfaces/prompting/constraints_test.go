@@ -147,6 +147,28 @@ func (s *constraintsSuite) TestParseInterfaceSpecificConstraintsHappy(c *C) {
 			},
 			expectedPathPattern: mustParsePathPattern(c, "/home/you/**/*.pdf"),
 		},
+		{
+			iface: "removable-media",
+			constraintsJSON: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/media/test/*/**"`),
+			},
+			isPatch: false,
+			expected: &prompting.InterfaceSpecificConstraintsHome{
+				Pattern: mustParsePathPattern(c, "/media/test/*/**"),
+			},
+			expectedPathPattern: mustParsePathPattern(c, "/media/test/*/**"),
+		},
+		{
+			iface: "personal-files",
+			constraintsJSON: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/.config/foo/**"`),
+			},
+			isPatch: true,
+			expected: &prompting.InterfaceSpecificConstraintsHome{
+				Pattern: mustParsePathPattern(c, "/home/test/.config/foo/**"),
+			},
+			expectedPathPattern: mustParsePathPattern(c, "/home/test/.config/foo/**"),
+		},
 		{
 			iface:               "camera",
 			constraintsJSON:     prompting.ConstraintsJSON{},
@@ -2408,6 +2430,19 @@ func (s *constraintsSuite) TestAvailablePermissions(c *C) {
 	c.Check(available, IsNil)
 }
 
+func (s *constraintsSuite) TestValidatePermissions(c *C) {
+	c.Check(prompting.ValidatePermissions("home", []string{"read", "execute"}), IsNil)
+	c.Check(prompting.ValidatePermissions("camera", []string{"access"}), IsNil)
+
+	err := prompting.ValidatePermissions("foo", []string{"read"})
+	c.Check(err, ErrorMatches, `invalid interface: "foo"`)
+	c.Check(err, testutil.ErrorIs, prompting_errors.ErrUnsupportedValue)
+	err = prompting.ValidatePermissions("home", nil)
+	c.Check(err, ErrorMatches, `invalid permissions for home interface: permissions empty`)
+	err = prompting.ValidatePermissions("camera", []string{"access", "read", "write"})
+	c.Check(err, ErrorMatches, `invalid permissions for camera interface: "read", "write"`)
+}
+
 func (s *constraintsSuite) TestAbstractPermissionsFromAppArmorPermissionsHappy(c *C) {
 	cases := []struct {
 		iface string
@@ -2444,6 +2479,21 @@ func (s *constraintsSuite) TestAbstractPermissionsFromAppArmorPermissionsHappy(c
 			notify.AA_MAY_EXEC | notify.AA_MAY_WRITE | notify.AA_MAY_READ,
 			[]string{"read", "write", "execute"},
 		},
+		{
+			"removable-media",
+			notify.AA_MAY_EXEC | notify.AA_MAY_WRITE | notify.AA_MAY_READ,
+			[]string{"read", "write", "execute"},
+		},
+		{
+			"personal-files",
+			notify.AA_MAY_OPEN | notify.AA_MAY_GETATTR,
+			[]string{"read"},
+		},
+		{
+			"personal-files",
+			notify.AA_MAY_CREATE | notify.AA_MAY_LOCK | notify.AA_MAY_READ,
+			[]string{"read", "write"},
+		},
 		{
 			"camera",
 			notify.AA_MAY_OPEN,
@@ -191,6 +191,14 @@ func NewInvalidPathPatternError(invalid string, reason string) *ParseError {
 	}
 }
 
+func NewInvalidPathError(invalid string, reason string) *ParseError {
+	return &ParseError{
+		Field:   "path",
+		Msg:     fmt.Sprintf("invalid path: %s: %q", reason, invalid),
+		Invalid: invalid,
+	}
+}
+
 // Validation errors, which are all uniquely defined here
 
 // RequestedPathNotMatchedError stores a path pattern from a reply which doesn't
@@ -244,3 +252,24 @@ func (e *RuleConflictError) Error() string {
 func (e *RuleConflictError) Unwrap() error {
 	return ErrRuleConflict
 }
+
+// RuleImportConflict stores the conflicts with existing rules of the rule at
+// the given index in a list of rules to be imported.
+type RuleImportConflict struct {
+	Index     int
+	Conflicts []RuleConflict
+}
+
+// RuleImportConflictError stores a list of conflicts with existing rules which
+// occurred when attempting to import rules.
+type RuleImportConflictError struct {
+	Conflicts []RuleImportConflict
+}
+
+func (e *RuleImportConflictError) Error() string {
+	return fmt.Sprintf("cannot import rules: %d rules conflict with existing rules", len(e.Conflicts))
+}
+
+func (e *RuleImportConflictError) Unwrap() error {
+	return ErrRuleConflict
+}
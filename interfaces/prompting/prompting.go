@@ -98,12 +98,17 @@ func NewRequestFromListener(msg notify.MsgNotificationGeneric, sendResponse list
 			return nil, fmt.Errorf("cannot select interface from metadata tags: %w", err)
 		}
 		// There were no tags registered with a snapd interface, so we
-		// look at the path to decide whether it's "home" or "camera".
+		// look at the path to decide which interface it's associated with.
 		// XXX: this is a temporary workaround until metadata tags are
 		// supported by the AppArmor parser and kernel.
-		if builtin.DetectCameraFromPath(path) {
+		switch {
+		case builtin.DetectCameraFromPath(path):
 			iface = "camera"
-		} else {
+		case builtin.DetectRemovableMediaFromPath(path):
+			iface = "removable-media"
+		case builtin.DetectPersonalFilesFromPath(path):
+			iface = "personal-files"
+		default:
 			iface = "home"
 		}
 	}
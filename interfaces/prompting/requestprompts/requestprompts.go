@@ -40,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/prompting/patterns"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/seclog"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -142,6 +143,18 @@ func slicesContainsFunc(s []*prompting.Request, f func(r *prompting.Request) boo
 	return false
 }
 
+// auditRequest returns a description of the request which led to the prompt,
+// for recording prompting decisions in the security log.
+func (p *Prompt) auditRequest(user uint32) seclog.PromptingRequest {
+	return seclog.PromptingRequest{
+		UserID:      user,
+		Snap:        p.Snap,
+		Interface:   p.Interface,
+		Path:        p.Constraints.Path(),
+		Permissions: p.Constraints.originalPermissions,
+	}
+}
+
 func (p *Prompt) sendReply(outcome prompting.OutcomeType) error {
 	allow, err := outcome.AsBool()
 	if err != nil {
@@ -201,7 +214,8 @@ type promptConstraints struct {
 }
 
 // promptConstraintsJSONHome defines the marshalled json structure of
-// promptConstraints for the home interface.
+// promptConstraints for the home interface, as well as for other interfaces
+// which mediate access to files, such as removable-media and personal-files.
 type promptConstraintsJSONHome struct {
 	Path                 string   `json:"path"`
 	RequestedPermissions []string `json:"requested-permissions"`
@@ -224,7 +238,7 @@ func (pc *promptConstraints) MarshalJSON() ([]byte, error) {
 // corresponding to the given interface.
 func (pc *promptConstraints) marshalForInterface(iface string) ([]byte, error) {
 	switch iface {
-	case "home":
+	case "home", "removable-media", "personal-files":
 		constraintsJSON := &promptConstraintsJSONHome{
 			Path:                 pc.EscapedPath(),
 			RequestedPermissions: pc.outstandingPermissions,
@@ -472,6 +486,7 @@ func (udb *userPromptDB) timeoutCallback(pdb *PromptDB, user uint32) {
 	expiredPromptIDs := make([]string, 0, len(expiredPrompts))
 	for _, p := range expiredPrompts {
 		expiredPromptIDs = append(expiredPromptIDs, p.ID.String())
+		seclog.LogPromptTimedOut(p.auditRequest(user), p.ID.String())
 		pdb.notifyPrompt(user, p.ID, data)
 		p.sendReply(prompting.OutcomeDeny) // ignore any error, should not occur
 	}
@@ -911,6 +926,7 @@ func (pdb *PromptDB) AddOrMerge(metadata *prompting.Metadata, path string, reque
 	userEntry.add(prompt)
 	logger.Debugf("created new prompt for request %q: %q", request.Key, promptID)
 	pdb.notifyPrompt(metadata.User, promptID, nil)
+	seclog.LogPromptCreated(prompt.auditRequest(metadata.User), promptID.String())
 	return prompt, false, nil
 }
 
@@ -1083,6 +1099,7 @@ func (pdb *PromptDB) Reply(user uint32, id prompting.IDType, outcome prompting.O
 	data := map[string]string{"resolved": "replied"}
 	logger.Debugf("received reply for prompt: %q", id)
 	pdb.notifyPrompt(user, id, data)
+	seclog.LogPromptReplied(prompt.auditRequest(user), id.String(), seclog.PromptingOutcome(outcome))
 	return prompt, nil
 }
 
@@ -1100,13 +1117,16 @@ func (pdb *PromptDB) Reply(user uint32, id prompting.IDType, outcome prompting.O
 // outstanding unsatisfied permissions of a partially-satisfied prompt must be
 // satisfied for the prompt as a whole to be satisfied.
 //
+// Records an event in the security log for each prompt which was satisfied,
+// including the ID of the given rule.
+//
 // Returns the IDs of any prompts which were fully satisfied by the given rule
 // contents.
 //
 // Since rule is new, we don't check the expiration timestamps for any
 // permissions, since any permissions with lifespan timespan were validated to
 // have a non-zero duration, and we handle this rule as it was at its creation.
-func (pdb *PromptDB) HandleNewRule(metadata *prompting.Metadata, constraints *prompting.RuleConstraints) ([]prompting.IDType, error) {
+func (pdb *PromptDB) HandleNewRule(metadata *prompting.Metadata, ruleID prompting.IDType, constraints *prompting.RuleConstraints) ([]prompting.IDType, error) {
 	pdb.mutex.Lock()
 	defer pdb.mutex.Unlock()
 
@@ -1186,6 +1206,11 @@ func (pdb *PromptDB) HandleNewRule(metadata *prompting.Metadata, constraints *pr
 		logger.Debugf("new rule satisfied prompt: %q", prompt.ID)
 		data := map[string]string{"resolved": "satisfied"}
 		pdb.notifyPrompt(metadata.User, prompt.ID, data)
+		outcome := seclog.PromptingOutcomeAllow
+		if len(deniedPermissions) > 0 {
+			outcome = seclog.PromptingOutcomeDeny
+		}
+		seclog.LogPromptingRuleDecision(prompt.auditRequest(metadata.User), prompt.ID.String(), outcome, []string{ruleID.String()})
 	}
 	return satisfiedPromptIDs, nil
 }
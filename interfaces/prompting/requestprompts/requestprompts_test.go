@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 	"unsafe"
@@ -40,6 +41,8 @@ import (
 	"github.com/snapcore/snapd/interfaces/prompting/requestprompts"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/seclog"
+	"github.com/snapcore/snapd/seclog/seclogtest"
 	"github.com/snapcore/snapd/testtime"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timeutil"
@@ -57,6 +60,8 @@ type requestpromptsSuite struct {
 	defaultUser         uint32
 	promptNotices       []*noticeInfo
 
+	seclogBuf *bytes.Buffer
+
 	tmpdir             string
 	legacyMaxIDPath    string
 	maxIDPath          string
@@ -94,6 +99,13 @@ func (s *requestpromptsSuite) SetUpTest(c *C) {
 	s.legacyMaxIDPath = filepath.Join(dirs.SnapRunDir, "request-prompt-max-id")
 	s.maxIDPath = filepath.Join(dirs.SnapInterfacesRequestsRunDir, "request-prompt-max-id")
 	s.requestMapFilepath = filepath.Join(dirs.SnapInterfacesRequestsRunDir, "request-key-mapping.json")
+
+	s.seclogBuf = &bytes.Buffer{}
+	seclog.Setup(seclogtest.MockSecurityLogger(s.seclogBuf))
+}
+
+func (s *requestpromptsSuite) TearDownTest(c *C) {
+	seclog.Setup(seclog.NewNopLogger())
 }
 
 func (s *requestpromptsSuite) TestNew(c *C) {
@@ -1342,6 +1354,7 @@ func (s *requestpromptsSuite) TestReply(c *C) {
 		s.checkNewNoticesSimple(c, []prompting.IDType{prompt1.ID}, nil)
 		expectedMap := map[string]requestprompts.RequestMapEntry{"fake:1": {PromptID: promptID, UserID: s.defaultUser}}
 		s.checkWrittenRequestMap(c, expectedMap)
+		c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_created Created prompt %s for request 1000:nextcloud:home:/home/test/Documents/foo.txt", prompt1.ID))
 
 		prompt2, merged, err := pdb.AddOrMerge(metadata, path, permissions, permissions, req2)
 		c.Assert(err, IsNil)
@@ -1401,6 +1414,10 @@ func (s *requestpromptsSuite) TestReply(c *C) {
 
 		expectedData := map[string]string{"resolved": "replied"}
 		s.checkNewNoticesSimple(c, []prompting.IDType{repliedPrompt.ID}, expectedData)
+		c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_replied Prompt %s for request 1000:nextcloud:home:/home/test/Documents/foo.txt replied with outcome %s", repliedPrompt.ID, outcome))
+		// Merged requests do not create new prompts
+		c.Check(strings.Count(s.seclogBuf.String(), "prompt_created"), Equals, 1)
+		s.seclogBuf.Reset()
 		// Reply should have cleared mappings for request keys associated with replied prompt
 		expectedMap = map[string]requestprompts.RequestMapEntry{}
 		s.checkWrittenRequestMap(c, expectedMap)
@@ -1564,7 +1581,9 @@ func (s *requestpromptsSuite) testHandleNewRule(c *C, requestedPath, replyPatter
 	metadata.PID = 0
 	metadata.Cgroup = ""
 
-	satisfied, err := pdb.HandleNewRule(metadata, constraints)
+	s.seclogBuf.Reset()
+	ruleID := prompting.IDType(0x1234)
+	satisfied, err := pdb.HandleNewRule(metadata, ruleID, constraints)
 	c.Assert(err, IsNil)
 	c.Check(satisfied, HasLen, 2, Commentf("requestedPath: %q, replyPattern: %q", requestedPath, replyPattern))
 	c.Check(promptIDListContains(satisfied, prompt1.ID), Equals, true)
@@ -1583,6 +1602,10 @@ func (s *requestpromptsSuite) testHandleNewRule(c *C, requestedPath, replyPatter
 	delete(expectedMap, "fake:56")
 	s.checkWrittenRequestMap(c, expectedMap)
 
+	c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_rule_decision Prompt %s for request 1000:nextcloud:home:%s decided with outcome deny by rules 0000000000001234", prompt1.ID, prompt1.Constraints.Path()))
+	c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_rule_decision Prompt %s for request 1000:nextcloud:home:%s decided with outcome allow by rules 0000000000001234", prompt3.ID, prompt3.Constraints.Path()))
+	c.Check(s.seclogBuf.String(), Not(testutil.Contains), prompt2.ID.String())
+
 	reply1, reply3 := waitForReplies(c, replyChan1, replyChan3)
 	// Only "read" permission was allowed for either prompt.
 	// prompt1 had requested "write" and "execute" as well, but because
@@ -1607,11 +1630,13 @@ func (s *requestpromptsSuite) testHandleNewRule(c *C, requestedPath, replyPatter
 			"write": &prompting.RulePermissionEntry{Outcome: prompting.OutcomeAllow},
 		},
 	}
-	satisfied, err = pdb.HandleNewRule(metadata, constraints)
+	s.seclogBuf.Reset()
+	satisfied, err = pdb.HandleNewRule(metadata, ruleID+1, constraints)
 
 	c.Assert(err, IsNil)
 	c.Check(satisfied, HasLen, 1)
 	c.Check(satisfied[0], Equals, prompt2.ID)
+	c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_rule_decision Prompt %s for request 1000:nextcloud:home:%s decided with outcome allow by rules 0000000000001235", prompt2.ID, prompt2.Constraints.Path()))
 
 	expectedData := map[string]string{"resolved": "satisfied"}
 	s.checkNewNoticesSimple(c, []prompting.IDType{prompt2.ID}, expectedData)
@@ -1728,7 +1753,9 @@ func (s *requestpromptsSuite) testHandleNewRuleNonMatches(c *C, requestedPath, m
 	c.Assert(stored, HasLen, 1)
 	c.Assert(stored[0], Equals, prompt)
 
-	satisfied, err := pdb.HandleNewRule(metadata, badOutcomeConstraints)
+	s.seclogBuf.Reset()
+	ruleID := prompting.IDType(42)
+	satisfied, err := pdb.HandleNewRule(metadata, ruleID, badOutcomeConstraints)
 	c.Check(err, ErrorMatches, `invalid outcome: "foo"`)
 	c.Check(satisfied, IsNil)
 
@@ -1739,7 +1766,7 @@ func (s *requestpromptsSuite) testHandleNewRuleNonMatches(c *C, requestedPath, m
 		Snap:      snap,
 		Interface: iface,
 	}
-	satisfied, err = pdb.HandleNewRule(otherUserMetadata, constraints)
+	satisfied, err = pdb.HandleNewRule(otherUserMetadata, ruleID, constraints)
 	c.Check(err, IsNil)
 	c.Check(satisfied, IsNil)
 
@@ -1750,7 +1777,7 @@ func (s *requestpromptsSuite) testHandleNewRuleNonMatches(c *C, requestedPath, m
 		Snap:      otherSnap,
 		Interface: iface,
 	}
-	satisfied, err = pdb.HandleNewRule(otherSnapMetadata, constraints)
+	satisfied, err = pdb.HandleNewRule(otherSnapMetadata, ruleID, constraints)
 	c.Check(err, IsNil)
 	c.Check(satisfied, IsNil)
 
@@ -1761,21 +1788,26 @@ func (s *requestpromptsSuite) testHandleNewRuleNonMatches(c *C, requestedPath, m
 		Snap:      snap,
 		Interface: otherInterface,
 	}
-	satisfied, err = pdb.HandleNewRule(otherInterfaceMetadata, constraints)
+	satisfied, err = pdb.HandleNewRule(otherInterfaceMetadata, ruleID, constraints)
 	c.Check(err, IsNil)
 	c.Check(satisfied, IsNil)
 
 	s.checkNewNoticesSimple(c, []prompting.IDType{}, nil)
 
-	satisfied, err = pdb.HandleNewRule(metadata, otherConstraints)
+	satisfied, err = pdb.HandleNewRule(metadata, ruleID, otherConstraints)
 	c.Check(err, IsNil)
 	c.Check(satisfied, IsNil)
 
 	s.checkNewNoticesSimple(c, []prompting.IDType{}, nil)
 
-	satisfied, err = pdb.HandleNewRule(metadata, constraints)
+	// Only a rule which satisfies the prompt results in a decision
+	c.Check(s.seclogBuf.String(), Equals, "")
+
+	satisfied, err = pdb.HandleNewRule(metadata, ruleID, constraints)
 	c.Check(err, IsNil)
 	c.Assert(satisfied, HasLen, 1)
+	c.Check(s.seclogBuf.String(), testutil.Contains, "prompt_rule_decision")
+	c.Check(s.seclogBuf.String(), testutil.Contains, "by rules 000000000000002A")
 
 	expectedData := map[string]string{"resolved": "satisfied"}
 	s.checkNewNoticesSimple(c, []prompting.IDType{prompt.ID}, expectedData)
@@ -1954,7 +1986,7 @@ func (s *requestpromptsSuite) TestCloseThenOperate(c *C) {
 	c.Check(err, Equals, prompting_errors.ErrPromptingClosed)
 	c.Check(result, IsNil)
 
-	promptIDs, err := pdb.HandleNewRule(nil, nil)
+	promptIDs, err := pdb.HandleNewRule(nil, 0, nil)
 	c.Check(err, Equals, prompting_errors.ErrPromptingClosed)
 	c.Check(promptIDs, IsNil)
 
@@ -2359,6 +2391,32 @@ func (s *requestpromptsSuite) TestPromptMarshalJSON(c *C) {
 			outstandingPerms: []string{"write"},
 			expected:         `{"id":"0000000000000004","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"firefox","pid":1234,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"home","constraints":{"path":"/home/test/foo\\*\\?()\\[\\]\\{\\}'\",\\\\","requested-permissions":["write"],"available-permissions":["read","write","execute"]}}`,
 		},
+		{
+			metadata: &prompting.Metadata{
+				User:      s.defaultUser,
+				Snap:      "nautilus",
+				PID:       4321,
+				Cgroup:    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope",
+				Interface: "removable-media",
+			},
+			path:             "/media/test/usb-stick/foo",
+			requestedPerms:   []string{"read"},
+			outstandingPerms: []string{"read"},
+			expected:         `{"id":"0000000000000005","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"nautilus","pid":4321,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"removable-media","constraints":{"path":"/media/test/usb-stick/foo","requested-permissions":["read"],"available-permissions":["read","write","execute"]}}`,
+		},
+		{
+			metadata: &prompting.Metadata{
+				User:      s.defaultUser,
+				Snap:      "gedit",
+				PID:       5432,
+				Cgroup:    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope",
+				Interface: "personal-files",
+			},
+			path:             "/home/test/.bashrc",
+			requestedPerms:   []string{"read", "write"},
+			outstandingPerms: []string{"write"},
+			expected:         `{"id":"0000000000000006","timestamp":"2024-08-14T09:47:03.350324989-05:00","snap":"gedit","pid":5432,"cgroup":"0::/user.slice/user-1000.slice/user@1000.service/app.slice/some-cgroup.scope","interface":"personal-files","constraints":{"path":"/home/test/.bashrc","requested-permissions":["write"],"available-permissions":["read","write"]}}`,
+		},
 	} {
 		fakeRequest := &prompting.Request{Key: fmt.Sprintf("fake:%d", reqCount)}
 		reqCount++
@@ -2447,6 +2505,9 @@ func (s *requestpromptsSuite) TestPromptExpiration(c *C) {
 	c.Check(allowedPerms, DeepEquals, []string{"read"})
 	allowedPerms = waitForReply(c, replyChan2)
 	c.Check(allowedPerms, DeepEquals, []string{"read"})
+	for _, p := range []*requestprompts.Prompt{prompt, prompt2} {
+		c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_timeout Prompt %s for request 1000:firefox:home:%s timed out and was denied", p.ID, p.Constraints.Path()))
+	}
 	c.Assert(timer.FireCount(), Equals, 1)
 	// ID mappings should have been cleaned up for requests associated with expired prompt
 	expectedMap = map[string]requestprompts.RequestMapEntry{}
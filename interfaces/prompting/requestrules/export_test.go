@@ -58,6 +58,26 @@ func (rdb *RuleDB) ReadOrAssignUserSessionID(user uint32) (userSessionID prompti
 	return rdb.readOrAssignUserSessionID(user)
 }
 
-func MockIsPathPermAllowed(f func(rdb *RuleDB, user uint32, snap string, iface string, path string, permission string, at prompting.At) (bool, error)) func() {
-	return testutil.Mock(&isPathPermAllowed, f)
+func MockReadUserSessionID(f func(rdb *RuleDB, user uint32) (prompting.IDType, error)) (restore func()) {
+	return testutil.Mock(&ReadUserSessionID, f)
+}
+
+func (rdb *RuleDB) ReadUserSessionID(user uint32) (prompting.IDType, error) {
+	return rdb.readUserSessionID(user)
+}
+
+func MockPathPermDecision(f func(rdb *RuleDB, user uint32, snap string, iface string, path string, permission string, at prompting.At) (bool, []prompting.IDType, error)) func() {
+	return testutil.Mock(&pathPermDecision, f)
+}
+
+func MockSystemRulesOwnerUID(uid uint32) (restore func()) {
+	return testutil.Mock(&systemRulesOwnerUID, uid)
+}
+
+func MockUserGroupIDs(f func(uid uint32) ([]string, error)) (restore func()) {
+	return testutil.Mock(&userGroupIDs, f)
+}
+
+func MockLookupGroupID(f func(name string) (string, error)) (restore func()) {
+	return testutil.Mock(&lookupGroupID, f)
 }
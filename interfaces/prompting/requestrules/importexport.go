@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+// ExportedRule holds the contents of a rule which are preserved when it is
+// exported from one rule database and imported into another, potentially on
+// a different machine.
+//
+// Rule IDs, timestamps, and users are not preserved, and neither are any
+// permissions with a lifespan of "session", since the session to which they
+// are tied is specific to the machine on which they were created.
+type ExportedRule struct {
+	Snap        string                    `json:"snap"`
+	Interface   string                    `json:"interface"`
+	Constraints prompting.ConstraintsJSON `json:"constraints"`
+}
+
+// ExportRules converts the given rules to a form which can be imported into
+// another rule database using ImportRules.
+//
+// Permissions which have expired at the given time or which have a lifespan
+// of "session" are omitted, and rules with no remaining permissions are not
+// exported.
+func ExportRules(rules []*Rule, now time.Time) ([]*ExportedRule, error) {
+	at := prompting.At{
+		Time: now,
+		// Session permissions are never exported, so no session ID is needed
+	}
+	exported := make([]*ExportedRule, 0, len(rules))
+	for _, rule := range rules {
+		permissions := make(prompting.RulePermissionMap, len(rule.Constraints.Permissions))
+		for perm, entry := range rule.Constraints.Permissions {
+			if entry.Lifespan == prompting.LifespanSession {
+				continue
+			}
+			if entry.Expired(at) {
+				continue
+			}
+			permissions[perm] = entry
+		}
+		if len(permissions) == 0 {
+			continue
+		}
+		constraints := &prompting.RuleConstraints{
+			InterfaceSpecific: rule.Constraints.InterfaceSpecific,
+			Permissions:       permissions,
+		}
+		data, err := json.Marshal(constraints)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export rule %s: %w", rule.ID, err)
+		}
+		var constraintsJSON prompting.ConstraintsJSON
+		if err := json.Unmarshal(data, &constraintsJSON); err != nil {
+			return nil, fmt.Errorf("cannot export rule %s: %w", rule.ID, err)
+		}
+		exported = append(exported, &ExportedRule{
+			Snap:        rule.Snap,
+			Interface:   rule.Interface,
+			Constraints: constraintsJSON,
+		})
+	}
+	return exported, nil
+}
+
+// parseExportedRule validates the given exported rule against the interfaces
+// and permissions supported by this system, and converts it into a rule for
+// the given user.
+//
+// Permissions which have expired at the given point in time are dropped. If
+// all permissions have expired, returns a nil rule.
+func parseExportedRule(user uint32, exported *ExportedRule, at prompting.At) (*Rule, error) {
+	if exported == nil {
+		return nil, prompting_errors.NewMissingFieldError("rules", "rule must not be null")
+	}
+	if err := naming.ValidateSnap(exported.Snap); err != nil {
+		return nil, err
+	}
+	constraints, err := prompting.UnmarshalRuleConstraints(exported.Interface, exported.Constraints)
+	if err != nil {
+		return nil, err
+	}
+	for perm, entry := range constraints.Permissions {
+		if entry.Lifespan == prompting.LifespanSession {
+			return nil, prompting_errors.NewInvalidLifespanError(string(entry.Lifespan), []string{string(prompting.LifespanForever), string(prompting.LifespanTimespan)})
+		}
+		if entry.Expired(at) {
+			delete(constraints.Permissions, perm)
+		}
+	}
+	if len(constraints.Permissions) == 0 {
+		return nil, nil
+	}
+	rule := &Rule{
+		Timestamp:   at.Time,
+		User:        user,
+		Snap:        exported.Snap,
+		Interface:   exported.Interface,
+		Constraints: constraints,
+	}
+	return rule, nil
+}
+
+// ImportRules adds the given exported rules to the rule database as rules for
+// the given user, and returns the newly-added rules.
+//
+// All of the given rules are validated against the interfaces and permissions
+// supported by this system before any is added. Permissions which have
+// already expired are dropped, as are rules with no remaining permissions.
+//
+// Unlike AddRule, imported rules are never merged with existing rules. If any
+// imported rule has the same path pattern as an existing rule, or has a path
+// pattern and permission whose outcome conflicts with an existing rule, then
+// no rules are imported, and a RuleImportConflictError is returned with the
+// conflicts of every such imported rule.
+func (rdb *RuleDB) ImportRules(user uint32, exportedRules []*ExportedRule) ([]*Rule, error) {
+	rdb.mutex.Lock()
+	defer rdb.mutex.Unlock()
+
+	if rdb.maxIDMmap.IsClosed() {
+		return nil, prompting_errors.ErrPromptingClosed
+	}
+
+	currSession, err := ReadOrAssignUserSessionID(rdb, user)
+	if err != nil && !errors.Is(err, errNoUserSession) {
+		return nil, err
+	}
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: currSession,
+	}
+
+	// Map from index in the list of rules to import to the parsed rule
+	rules := make(map[int]*Rule, len(exportedRules))
+	for i, exported := range exportedRules {
+		rule, err := parseExportedRule(user, exported, at)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import rule %d: %w", i, err)
+		}
+		if rule != nil {
+			rules[i] = rule
+		}
+	}
+
+	imported := make([]*Rule, 0, len(rules))
+	importedIndexByID := make(map[prompting.IDType]int, len(rules))
+	rollback := func() {
+		for _, rule := range imported {
+			rdb.removeRuleByID(rule.ID)
+		}
+	}
+	var conflicts []prompting_errors.RuleImportConflict
+	for i := range exportedRules {
+		rule, ok := rules[i]
+		if !ok {
+			continue
+		}
+		existingRule, exists, err := rdb.lookupRuleByPathPattern(user, rule.Snap, rule.Interface, rule.Constraints)
+		if err != nil {
+			// Database was left inconsistent, should not occur
+			rollback()
+			return nil, err
+		}
+		if exists {
+			if j, ok := importedIndexByID[existingRule.ID]; ok {
+				rollback()
+				return nil, fmt.Errorf("cannot import rule %d: %w: rule %d has the same path pattern", i, prompting_errors.ErrRuleConflict, j)
+			}
+			conflicts = append(conflicts, prompting_errors.RuleImportConflict{
+				Index:     i,
+				Conflicts: samePathPatternConflicts(rule, existingRule),
+			})
+			continue
+		}
+		const save = false
+		err = rdb.addNewRule(rule, at, save)
+		var conflictErr *prompting_errors.RuleConflictError
+		if errors.As(err, &conflictErr) {
+			for _, conflict := range conflictErr.Conflicts {
+				id, err := prompting.IDFromString(conflict.ConflictingID)
+				if err != nil {
+					continue
+				}
+				if j, ok := importedIndexByID[id]; ok {
+					rollback()
+					return nil, fmt.Errorf("cannot import rule %d: %w: rule %d has a conflicting outcome for permission %q", i, prompting_errors.ErrRuleConflict, j, conflict.Permission)
+				}
+			}
+			conflicts = append(conflicts, prompting_errors.RuleImportConflict{
+				Index:     i,
+				Conflicts: conflictErr.Conflicts,
+			})
+			continue
+		}
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		imported = append(imported, rule)
+		importedIndexByID[rule.ID] = i
+	}
+
+	if len(conflicts) > 0 {
+		rollback()
+		return nil, &prompting_errors.RuleImportConflictError{
+			Conflicts: conflicts,
+		}
+	}
+
+	if err := rdb.save(); err != nil {
+		rollback()
+		return nil, err
+	}
+
+	for _, rule := range imported {
+		logger.Debugf("new rule imported: %q", rule.ID)
+		rdb.notifyRule(user, rule.ID, nil)
+	}
+	return imported, nil
+}
+
+// samePathPatternConflicts returns a conflict for each permission of the
+// given rule, all of which conflict with the given existing rule, since it has
+// an identical path pattern.
+func samePathPatternConflicts(rule *Rule, existingRule *Rule) []prompting_errors.RuleConflict {
+	conflicts := make([]prompting_errors.RuleConflict, 0, len(rule.Constraints.Permissions))
+	for perm := range rule.Constraints.Permissions {
+		conflicts = append(conflicts, prompting_errors.RuleConflict{
+			Permission:    perm,
+			Variant:       rule.Constraints.PathPattern().String(), // XXX: we're mis-using the full path pattern in place of the variant
+			ConflictingID: existingRule.ID.String(),
+		})
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Permission < conflicts[j].Permission
+	})
+	return conflicts
+}
@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/prompting"
+	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
+)
+
+// checkSameConstraints checks that the given constraints are equivalent by
+// comparing their JSON encoding, since expiration timestamps lose their
+// monotonic clock reading when round-tripped through JSON.
+func checkSameConstraints(c *C, obtained, expected *prompting.RuleConstraints) {
+	obtainedJSON, err := json.Marshal(obtained)
+	c.Assert(err, IsNil)
+	expectedJSON, err := json.Marshal(expected)
+	c.Assert(err, IsNil)
+	c.Check(string(obtainedJSON), Equals, string(expectedJSON))
+}
+
+func (s *requestrulesSuite) addExportTestRules(c *C, rdb *requestrules.RuleDB) (forever, timespan *requestrules.Rule) {
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/test/Documents/**",
+		Permissions: []string{"read", "write"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	forever, err := addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+	timespan, err = addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/.ssh/**",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeDeny,
+		Lifespan:    prompting.LifespanTimespan,
+		Duration:    "1h",
+	})
+	c.Assert(err, IsNil)
+	// Session rules are never exported
+	_, err = addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/Downloads/**",
+		Lifespan:    prompting.LifespanSession,
+	})
+	c.Assert(err, IsNil)
+	return forever, timespan
+}
+
+func (s *requestrulesSuite) TestExportRules(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	forever, timespan := s.addExportTestRules(c, rdb)
+
+	exported, err := requestrules.ExportRules(rdb.Rules(s.defaultUser), time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(exported, HasLen, 2)
+
+	for i, rule := range []*requestrules.Rule{forever, timespan} {
+		c.Check(exported[i].Snap, Equals, rule.Snap)
+		c.Check(exported[i].Interface, Equals, rule.Interface)
+		constraints, err := prompting.UnmarshalRuleConstraints(exported[i].Interface, exported[i].Constraints)
+		c.Assert(err, IsNil)
+		checkSameConstraints(c, constraints, rule.Constraints)
+	}
+
+	// Expired permissions are not exported
+	exported, err = requestrules.ExportRules(rdb.Rules(s.defaultUser), time.Now().Add(2*time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(exported, HasLen, 1)
+	c.Check(string(exported[0].Constraints["path-pattern"]), Equals, `"/home/test/Documents/**"`)
+}
+
+func (s *requestrulesSuite) TestImportRules(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	forever, timespan := s.addExportTestRules(c, rdb)
+
+	exported, err := requestrules.ExportRules(rdb.Rules(s.defaultUser), time.Now())
+	c.Assert(err, IsNil)
+
+	// Round-trip the exported rules through JSON
+	data, err := json.Marshal(exported)
+	c.Assert(err, IsNil)
+	var toImport []*requestrules.ExportedRule
+	c.Assert(json.Unmarshal(data, &toImport), IsNil)
+
+	s.checkNewNoticesSimple(c, nil, rdb.Rules(s.defaultUser)...)
+
+	otherUser := s.defaultUser + 1
+	imported, err := rdb.ImportRules(otherUser, toImport)
+	c.Assert(err, IsNil)
+	c.Assert(imported, HasLen, 2)
+	for i, rule := range []*requestrules.Rule{forever, timespan} {
+		c.Check(imported[i].ID, Not(Equals), rule.ID)
+		c.Check(imported[i].User, Equals, otherUser)
+		c.Check(imported[i].Snap, Equals, rule.Snap)
+		c.Check(imported[i].Interface, Equals, rule.Interface)
+		checkSameConstraints(c, imported[i].Constraints, rule.Constraints)
+	}
+	c.Check(rdb.Rules(otherUser), DeepEquals, imported)
+	s.checkNewNoticesSimple(c, nil, imported...)
+
+	// Imported rules are applied
+	allowedPerms, anyDenied, outstandingPerms, err := rdb.IsRequestAllowed(otherUser, "firefox", "home", "/home/test/.ssh/id_rsa", []string{"read", "write"})
+	c.Check(err, IsNil)
+	c.Check(allowedPerms, HasLen, 0)
+	c.Check(anyDenied, Equals, true)
+	c.Check(outstandingPerms, DeepEquals, []string{"write"})
+
+	// Imported rules are saved to disk
+	c.Assert(rdb.Close(), IsNil)
+	rdb, err = requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	c.Check(rdb.Rules(otherUser), HasLen, 2)
+}
+
+func (s *requestrulesSuite) TestImportRulesExpired(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	expiration, err := json.Marshal(time.Now().Add(-time.Hour))
+	c.Assert(err, IsNil)
+	toImport := []*requestrules.ExportedRule{
+		{
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/foo"`),
+				"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"timespan","expiration":` + string(expiration) + `}}`),
+			},
+		},
+	}
+	imported, err := rdb.ImportRules(s.defaultUser, toImport)
+	c.Assert(err, IsNil)
+	c.Check(imported, HasLen, 0)
+	c.Check(rdb.Rules(s.defaultUser), HasLen, 0)
+	s.checkNewNoticesSimple(c, nil)
+}
+
+func (s *requestrulesSuite) TestImportRulesConflicts(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/test/foo",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	existing, err := addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+	s.checkNewNoticesSimple(c, nil, existing)
+
+	toImport := []*requestrules.ExportedRule{
+		{
+			// Does not conflict
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/bar"`),
+				"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+			},
+		},
+		{
+			// Same path pattern, would otherwise be merged
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/foo"`),
+				"permissions":  json.RawMessage(`{"write":{"outcome":"allow","lifespan":"forever"}}`),
+			},
+		},
+		{
+			// Variant with conflicting outcome
+			Snap:      "firefox",
+			Interface: "home",
+			Constraints: prompting.ConstraintsJSON{
+				"path-pattern": json.RawMessage(`"/home/test/{foo,baz}"`),
+				"permissions":  json.RawMessage(`{"read":{"outcome":"deny","lifespan":"forever"}}`),
+			},
+		},
+	}
+	imported, err := rdb.ImportRules(s.defaultUser, toImport)
+	c.Check(imported, IsNil)
+	c.Check(err, ErrorMatches, "cannot import rules: 2 rules conflict with existing rules")
+	c.Check(errors.Is(err, prompting_errors.ErrRuleConflict), Equals, true)
+	var importErr *prompting_errors.RuleImportConflictError
+	c.Assert(errors.As(err, &importErr), Equals, true)
+	c.Check(importErr.Conflicts, DeepEquals, []prompting_errors.RuleImportConflict{
+		{
+			Index: 1,
+			Conflicts: []prompting_errors.RuleConflict{{
+				Permission:    "write",
+				Variant:       "/home/test/foo",
+				ConflictingID: existing.ID.String(),
+			}},
+		},
+		{
+			Index: 2,
+			Conflicts: []prompting_errors.RuleConflict{{
+				Permission:    "read",
+				Variant:       "/home/test/foo",
+				ConflictingID: existing.ID.String(),
+			}},
+		},
+	})
+
+	// Nothing was imported
+	c.Check(rdb.Rules(s.defaultUser), DeepEquals, []*requestrules.Rule{existing})
+	s.checkWrittenRuleDB(c, []*requestrules.Rule{existing})
+	s.checkNewNoticesSimple(c, nil)
+
+	// Rules being imported may not conflict with each other either
+	imported, err = rdb.ImportRules(s.defaultUser, []*requestrules.ExportedRule{toImport[0], toImport[0]})
+	c.Check(imported, IsNil)
+	c.Check(err, ErrorMatches, "cannot import rule 1: a rule with conflicting path pattern and permission already exists in the rule database: rule 0 has the same path pattern")
+	c.Check(rdb.Rules(s.defaultUser), DeepEquals, []*requestrules.Rule{existing})
+	s.checkNewNoticesSimple(c, nil)
+}
+
+func (s *requestrulesSuite) TestImportRulesInvalid(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	valid := &requestrules.ExportedRule{
+		Snap:      "firefox",
+		Interface: "home",
+		Constraints: prompting.ConstraintsJSON{
+			"path-pattern": json.RawMessage(`"/home/test/foo"`),
+			"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+		},
+	}
+	for _, testCase := range []struct {
+		rule   *requestrules.ExportedRule
+		errStr string
+	}{
+		{
+			nil,
+			`cannot import rule 1: rule must not be null`,
+		},
+		{
+			&requestrules.ExportedRule{
+				Snap:        "-invalid-",
+				Interface:   "home",
+				Constraints: valid.Constraints,
+			},
+			`cannot import rule 1: invalid snap name: "-invalid-"`,
+		},
+		{
+			&requestrules.ExportedRule{
+				Snap:        "firefox",
+				Interface:   "foo",
+				Constraints: valid.Constraints,
+			},
+			`cannot import rule 1: invalid interface: "foo"`,
+		},
+		{
+			&requestrules.ExportedRule{
+				Snap:      "firefox",
+				Interface: "camera",
+				Constraints: prompting.ConstraintsJSON{
+					"permissions": json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+				},
+			},
+			`cannot import rule 1: invalid permissions for camera interface: "read"`,
+		},
+		{
+			&requestrules.ExportedRule{
+				Snap:      "firefox",
+				Interface: "home",
+				Constraints: prompting.ConstraintsJSON{
+					"path-pattern": json.RawMessage(`"/home/test/bar"`),
+					"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"session","session-id":"0000000000012345"}}`),
+				},
+			},
+			`cannot import rule 1: invalid lifespan: "session"`,
+		},
+	} {
+		imported, err := rdb.ImportRules(s.defaultUser, []*requestrules.ExportedRule{valid, testCase.rule})
+		c.Check(imported, IsNil)
+		c.Check(err, ErrorMatches, testCase.errStr, Commentf("rule: %+v", testCase.rule))
+	}
+
+	// Nothing was imported
+	c.Check(rdb.Rules(s.defaultUser), HasLen, 0)
+	s.checkNewNoticesSimple(c, nil)
+}
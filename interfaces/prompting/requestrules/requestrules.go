@@ -28,6 +28,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -42,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/randutil"
+	"github.com/snapcore/snapd/seclog"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -174,6 +176,10 @@ type RuleDB struct {
 	// is matched by existing rules, and which of those rules has precedence.
 	perUser map[uint32]*userDB
 
+	// systemRules are the rules defined by the system administrator, which
+	// take precedence over the rules in the per-user rules tree.
+	systemRules []*SystemRule
+
 	dbPath string
 	// notifyRule is a closure which will be called to record a notice when a
 	// rule is added, patched, or removed.
@@ -212,6 +218,9 @@ func New(notifyRule func(userID uint32, ruleID prompting.IDType, data map[string
 		notifyRule: notifyRule,
 		dbPath:     rulesFilepath,
 	}
+	// Load system rules first, so that user rules which conflict with them
+	// can be dropped while loading the rest of the database.
+	rdb.loadSystemRules()
 	if err = rdb.load(); err != nil {
 		logger.Noticef("cannot load rule database: %v; using new empty rule database", err)
 	}
@@ -227,8 +236,9 @@ type rulesDBJSON struct {
 // load resets the receiving rule database to empty and then reads the stored
 // rules from the database file and populates the database.
 //
-// Removes any expired rules while loading the database. If any rules expired,
-// saves the database to disk.
+// Removes any expired rules while loading the database, as well as any rules
+// which conflict with system rules. If any rules were removed, saves the
+// database to disk.
 //
 // Returns an error if an existing rule DB cannot be loaded, if any rules are
 // invalid or in conflict, or if there is an error while saving the database to
@@ -242,6 +252,7 @@ func (rdb *RuleDB) load() (retErr error) {
 	rdb.perUser = make(map[uint32]*userDB)
 
 	expiredRules := make(map[prompting.IDType]bool)
+	systemConflictRules := make(map[prompting.IDType]bool)
 	partiallyExpiredRules := make(map[prompting.IDType]bool)
 	// Store map of merged rules, where the original merged (removed) rule ID
 	// maps to the ID of the rule into which it was merged.
@@ -285,6 +296,19 @@ func (rdb *RuleDB) load() (retErr error) {
 			continue
 		}
 
+		systemConflicts, err := rdb.systemRuleConflicts(rule)
+		if err != nil {
+			errInvalid = fmt.Errorf("cannot add rule: %w", err)
+			break
+		}
+		if len(systemConflicts) > 0 {
+			// System rules may have been added after this rule was created,
+			// so drop this rule rather than the whole database.
+			logger.Noticef("dropping rule %s which conflicts with system rules", rule.ID)
+			systemConflictRules[rule.ID] = true
+			continue
+		}
+
 		const save = false
 		mergedRule, merged, conflictErr := rdb.addOrMergeRule(rule, at, save)
 		if conflictErr != nil {
@@ -325,6 +349,8 @@ func (rdb *RuleDB) load() (retErr error) {
 		var data map[string]string
 		if expiredRules[rule.ID] {
 			data = expiredData
+		} else if systemConflictRules[rule.ID] {
+			data = map[string]string{"removed": "dropped"}
 		} else if newID, exists := mergedRules[rule.ID]; exists {
 			data = map[string]string{
 				"removed":     "merged",
@@ -339,7 +365,7 @@ func (rdb *RuleDB) load() (retErr error) {
 		rdb.notifyRule(rule.User, rule.ID, data)
 	}
 
-	if len(expiredRules) > 0 || len(partiallyExpiredRules) > 0 || len(mergedRules) > 0 {
+	if len(expiredRules) > 0 || len(systemConflictRules) > 0 || len(partiallyExpiredRules) > 0 || len(mergedRules) > 0 {
 		return rdb.save()
 	}
 
@@ -628,15 +654,27 @@ func (rdb *RuleDB) removeRuleByID(id prompting.IDType) (*Rule, error) {
 // addRuleToTree adds the given rule to the rule tree.
 //
 // If there are other rules which have a conflicting path pattern and
-// permission which is not expired at the given point in time, returns an
-// error with information about the conflicting rules.
+// permission which is not expired at the given point in time, or system rules
+// which apply to the user of the rule and have a conflicting path pattern and
+// permission, returns an error with information about the conflicting rules.
 //
 // Assumes that the rule has already been internally validated. No additional
 // validation is done in this function, nor is it checked whether it has
 // expired.
 //
 // The caller must ensure that the database lock is held for writing.
-func (rdb *RuleDB) addRuleToTree(rule *Rule, at prompting.At) *prompting_errors.RuleConflictError {
+func (rdb *RuleDB) addRuleToTree(rule *Rule, at prompting.At) error {
+	// Users cannot override system rules, so check those first
+	systemConflicts, err := rdb.systemRuleConflicts(rule)
+	if err != nil {
+		return err
+	}
+	if len(systemConflicts) > 0 {
+		return &prompting_errors.RuleConflictError{
+			Conflicts: systemConflicts,
+		}
+	}
+
 	addedPermissions := make([]string, 0, len(rule.Constraints.Permissions))
 	var conflicts []prompting_errors.RuleConflict
 	for permission, entry := range rule.Constraints.Permissions {
@@ -982,30 +1020,17 @@ func (rdb *RuleDB) readOrAssignUserSessionID(user uint32) (userSessionID prompti
 	rdb.userSessionIDMu.Lock()
 	defer rdb.userSessionIDMu.Unlock()
 
-	path := userSessionPath(user)
-
 	// It's important to check for an existing session ID xattr before trying
 	// to write a new session ID, as the /run/user/$UID tmpfs may be removed,
 	// but snapd is the only process which should ever write a session ID xattr.
-
-	userSessionIDXattrLen := 16 // 64-bit number as hex string
-	sessionIDBuf := make([]byte, userSessionIDXattrLen)
-	_, err = unix.Getxattr(path, userSessionIDXattr, sessionIDBuf)
-	if err == nil {
-		if e := userSessionID.UnmarshalText(sessionIDBuf); e == nil {
-			return userSessionID, nil
-		}
-		// Xattr present, but couldn't parse it, so ignore and overwrite it
-	} else if errors.Is(err, unix.ENOENT) {
-		// User session tmpfs does not exist
-		return 0, fmt.Errorf("%w: %d", errNoUserSession, user)
-	} else if !errors.Is(err, unix.ENODATA) {
-		// Something else went wrong
-		return 0, fmt.Errorf("cannot get user session ID xattr: %w", err)
+	userSessionID, err = readUserSessionIDXattr(user)
+	if err != nil || userSessionID != 0 {
+		return userSessionID, err
 	}
 
 	// No existing ID
 
+	path := userSessionPath(user)
 	newID := newUserSessionID()
 	data, _ := newID.MarshalText() // error is always nil
 	err = unix.Setxattr(path, userSessionIDXattr, data, 0)
@@ -1022,6 +1047,46 @@ func (rdb *RuleDB) readOrAssignUserSessionID(user uint32) (userSessionID prompti
 	return newID, nil
 }
 
+// Allow readUserSessionID to be mocked in tests.
+var ReadUserSessionID = (*RuleDB).readUserSessionID
+
+// readUserSessionID returns the existing user session ID for the given user,
+// or 0 if no ID has been assigned to the session yet, in which case no rule
+// with lifespan "session" can apply to it. Unlike readOrAssignUserSessionID,
+// it never assigns a new ID, so it can be used when merely inspecting rules.
+//
+// If the user session does not exist for the given user, returns an error
+// which wraps errNoUserSession.
+func (rdb *RuleDB) readUserSessionID(user uint32) (prompting.IDType, error) {
+	rdb.userSessionIDMu.Lock()
+	defer rdb.userSessionIDMu.Unlock()
+	return readUserSessionIDXattr(user)
+}
+
+// readUserSessionIDXattr reads the user session ID xattr of the given user,
+// returning 0 if it is missing or invalid.
+//
+// The caller must ensure that the user session ID lock is held.
+func readUserSessionIDXattr(user uint32) (userSessionID prompting.IDType, err error) {
+	userSessionIDXattrLen := 16 // 64-bit number as hex string
+	sessionIDBuf := make([]byte, userSessionIDXattrLen)
+	_, err = unix.Getxattr(userSessionPath(user), userSessionIDXattr, sessionIDBuf)
+	if err == nil {
+		if e := userSessionID.UnmarshalText(sessionIDBuf); e == nil {
+			return userSessionID, nil
+		}
+		// Xattr present, but couldn't parse it, so ignore it
+		return 0, nil
+	} else if errors.Is(err, unix.ENOENT) {
+		// User session tmpfs does not exist
+		return 0, fmt.Errorf("%w: %d", errNoUserSession, user)
+	} else if !errors.Is(err, unix.ENODATA) {
+		// Something else went wrong
+		return 0, fmt.Errorf("cannot get user session ID xattr: %w", err)
+	}
+	return 0, nil
+}
+
 // Creates a rule with the given information and adds it to the rule database.
 // If any of the given parameters are invalid, returns an error. Otherwise,
 // returns the newly-added rule, and saves the database to disk.
@@ -1078,6 +1143,23 @@ func (rdb *RuleDB) makeNewRule(user uint32, snap string, iface string, constrain
 	return &newRule
 }
 
+// RequestDecision holds the result of evaluating a request against the rules
+// in the rule database.
+type RequestDecision struct {
+	// AllowedPermissions are the requested permissions which are allowed by
+	// existing rules.
+	AllowedPermissions []string `json:"allowed-permissions"`
+	// DeniedPermissions are the requested permissions which are denied by
+	// existing rules.
+	DeniedPermissions []string `json:"denied-permissions"`
+	// OutstandingPermissions are the requested permissions which are not
+	// matched by any existing rule, and thus would result in a prompt.
+	OutstandingPermissions []string `json:"outstanding-permissions"`
+	// MatchedRules maps from each allowed or denied permission to the IDs of
+	// the rules which determined its outcome.
+	MatchedRules map[string][]prompting.IDType `json:"matched-rules"`
+}
+
 // IsRequestAllowed checks whether a request with the given parameters is
 // allowed or denied by existing rules.
 //
@@ -1085,50 +1167,135 @@ func (rdb *RuleDB) makeNewRule(user uint32, snap string, iface string, constrain
 // allowedPerms. If any permissions are denied, then returns anyDenied as true.
 // If any of the given permissions were not matched by an existing rule, then
 // they are returned as outstandingPerms. If an error occurred, returns it.
+//
+// If the request is decided by existing rules, that is, if any permission is
+// denied or no permissions are outstanding, records an event in the security
+// log with the IDs of the rules which determined the outcome.
 func (rdb *RuleDB) IsRequestAllowed(user uint32, snap string, iface string, path string, permissions []string) (allowedPerms []string, anyDenied bool, outstandingPerms []string, err error) {
-	allowedPerms = make([]string, 0, len(permissions))
-	outstandingPerms = make([]string, 0, len(permissions))
-	currSession, err := ReadOrAssignUserSessionID(rdb, user)
-	if err != nil && !errors.Is(err, errNoUserSession) {
+	decision, err := rdb.EvaluateRequest(user, snap, iface, path, permissions)
+	if decision == nil {
 		return nil, false, nil, err
 	}
+	anyDenied = len(decision.DeniedPermissions) > 0
+	if err == nil && (anyDenied || len(decision.OutstandingPermissions) == 0) {
+		request := seclog.PromptingRequest{
+			UserID:      user,
+			Snap:        snap,
+			Interface:   iface,
+			Path:        path,
+			Permissions: permissions,
+		}
+		outcome := seclog.PromptingOutcomeAllow
+		decidingPerms := decision.AllowedPermissions
+		if anyDenied {
+			outcome = seclog.PromptingOutcomeDeny
+			decidingPerms = decision.DeniedPermissions
+		}
+		const noPrompt = ""
+		seclog.LogPromptingRuleDecision(request, noPrompt, outcome, decision.ruleIDsForPermissions(decidingPerms))
+	}
+	return decision.AllowedPermissions, anyDenied, decision.OutstandingPermissions, err
+}
+
+// ruleIDsForPermissions returns the IDs of the rules which determined the
+// outcome of any of the given permissions, de-duplicated and sorted in
+// ascending order.
+func (d *RequestDecision) ruleIDsForPermissions(permissions []string) []string {
+	seen := make(map[prompting.IDType]bool)
+	var ids []prompting.IDType
+	for _, perm := range permissions {
+		for _, id := range d.MatchedRules[perm] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sortRuleIDs(ids)
+	ruleIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		ruleIDs = append(ruleIDs, id.String())
+	}
+	return ruleIDs
+}
+
+// EvaluateRequest checks whether a request with the given parameters would be
+// allowed or denied by existing rules, and which rules determine the outcome
+// of each of the given permissions.
+//
+// If an error occurs while checking some of the permissions, returns the
+// decision for the remaining permissions along with the error. If the request
+// cannot be evaluated at all, returns a nil decision.
+func (rdb *RuleDB) EvaluateRequest(user uint32, snap string, iface string, path string, permissions []string) (*RequestDecision, error) {
+	decision := &RequestDecision{
+		AllowedPermissions:     make([]string, 0, len(permissions)),
+		DeniedPermissions:      make([]string, 0, len(permissions)),
+		OutstandingPermissions: make([]string, 0, len(permissions)),
+		MatchedRules:           make(map[string][]prompting.IDType, len(permissions)),
+	}
+	// Evaluating a request must not have side effects, so do not assign a
+	// session ID if there is none yet: session rules cannot apply then.
+	currSession, err := ReadUserSessionID(rdb, user)
+	if err != nil && !errors.Is(err, errNoUserSession) {
+		return nil, err
+	}
 	at := prompting.At{
 		Time:      time.Now(),
 		SessionID: currSession,
 	}
 	var errs []error
 	for _, perm := range permissions {
-		allowed, err := isPathPermAllowed(rdb, user, snap, iface, path, perm, at)
+		allowed, ruleIDs, err := pathPermDecision(rdb, user, snap, iface, path, perm, at)
 		switch {
 		case err == nil:
 			if allowed {
-				allowedPerms = append(allowedPerms, perm)
+				decision.AllowedPermissions = append(decision.AllowedPermissions, perm)
 			} else {
-				anyDenied = true
+				decision.DeniedPermissions = append(decision.DeniedPermissions, perm)
 			}
+			decision.MatchedRules[perm] = ruleIDs
 		case errors.Is(err, prompting_errors.ErrNoMatchingRule):
-			outstandingPerms = append(outstandingPerms, perm)
+			decision.OutstandingPermissions = append(decision.OutstandingPermissions, perm)
 		default:
 			errs = append(errs, err)
 		}
 	}
-	return allowedPerms, anyDenied, outstandingPerms, strutil.JoinErrors(errs...)
+	return decision, strutil.JoinErrors(errs...)
 }
 
-// Allow isPathPermAllowed to be mocked in tests.
-var isPathPermAllowed = (*RuleDB).isPathPermAllowed
-
 // isPathPermAllowed checks whether the given path with the given permission is
 // allowed or denied by existing rules for the given user, snap, and interface,
 // at the given point in time.
 //
 // If no rule applies, returns prompting_errors.ErrNoMatchingRule.
 func (rdb *RuleDB) isPathPermAllowed(user uint32, snap string, iface string, path string, permission string, at prompting.At) (bool, error) {
+	allowed, _, err := rdb.pathPermDecision(user, snap, iface, path, permission, at)
+	return allowed, err
+}
+
+// Allow pathPermDecision to be mocked in tests.
+var pathPermDecision = (*RuleDB).pathPermDecision
+
+// pathPermDecision checks whether the given path with the given permission is
+// allowed or denied by existing rules for the given user, snap, and interface,
+// at the given point in time, and returns the IDs of the rules which determine
+// that outcome, sorted in ascending order.
+//
+// System rules which apply to the given user take precedence over the rules
+// of that user, regardless of the precedence of their path patterns.
+//
+// If no rule applies, returns prompting_errors.ErrNoMatchingRule.
+func (rdb *RuleDB) pathPermDecision(user uint32, snap string, iface string, path string, permission string, at prompting.At) (allowed bool, ruleIDs []prompting.IDType, err error) {
 	rdb.mutex.RLock()
 	defer rdb.mutex.RUnlock()
+	allowed, ruleIDs, err = rdb.pathPermDecisionBySystemRules(user, snap, iface, path, permission)
+	if !errors.Is(err, prompting_errors.ErrNoMatchingRule) {
+		return allowed, ruleIDs, err
+	}
 	permissionMap := rdb.permissionDBForUserSnapInterfacePermission(user, snap, iface, permission)
 	if permissionMap == nil {
-		return false, prompting_errors.ErrNoMatchingRule
+		return false, nil, prompting_errors.ErrNoMatchingRule
 	}
 	variantMap := permissionMap.VariantEntries
 	var matchingVariants []patterns.PatternVariant
@@ -1143,21 +1310,34 @@ func (rdb *RuleDB) isPathPermAllowed(user uint32, snap string, iface string, pat
 		matched, err := patterns.PathPatternMatches(variantStr, path)
 		if err != nil {
 			// Only possible error is ErrBadPattern, which should not occur
-			return false, fmt.Errorf("internal error: while matching path pattern: %w", err)
+			return false, nil, fmt.Errorf("internal error: while matching path pattern: %w", err)
 		}
 		if matched {
 			matchingVariants = append(matchingVariants, variantEntry.Variant)
 		}
 	}
 	if len(matchingVariants) == 0 {
-		return false, prompting_errors.ErrNoMatchingRule
+		return false, nil, prompting_errors.ErrNoMatchingRule
 	}
 	highestPrecedenceVariant, err := patterns.HighestPrecedencePattern(matchingVariants, path)
 	if err != nil {
-		return false, err
+		return false, nil, err
 	}
 	matchingEntry := variantMap[highestPrecedenceVariant.String()]
-	return matchingEntry.Outcome.AsBool()
+	for id, entry := range matchingEntry.RuleEntries {
+		if entry.Expired(at) {
+			continue
+		}
+		ruleIDs = append(ruleIDs, id)
+	}
+	sortRuleIDs(ruleIDs)
+	allowed, err = matchingEntry.Outcome.AsBool()
+	return allowed, ruleIDs, err
+}
+
+// sortRuleIDs sorts the given rule IDs in ascending order.
+func sortRuleIDs(ids []prompting.IDType) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
 }
 
 // RuleWithID returns the rule with the given ID.
@@ -44,6 +44,8 @@ import (
 	"github.com/snapcore/snapd/interfaces/prompting/patterns"
 	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/seclog"
+	"github.com/snapcore/snapd/seclog/seclogtest"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -66,6 +68,7 @@ type requestrulesSuite struct {
 	defaultUser       uint32
 	ruleNotices       []*noticeInfo
 	currSession       prompting.IDType
+	seclogBuf         *bytes.Buffer
 }
 
 var _ = Suite(&requestrulesSuite{})
@@ -91,6 +94,14 @@ func (s *requestrulesSuite) SetUpTest(c *C) {
 		return s.currSession, nil
 	})
 	s.AddCleanup(restore)
+	restore = requestrules.MockReadUserSessionID(func(rdb *requestrules.RuleDB, user uint32) (prompting.IDType, error) {
+		return s.currSession, nil
+	})
+	s.AddCleanup(restore)
+
+	s.seclogBuf = &bytes.Buffer{}
+	seclog.Setup(seclogtest.MockSecurityLogger(s.seclogBuf))
+	s.AddCleanup(func() { seclog.Setup(seclog.NewNopLogger()) })
 }
 
 func mustParsePathPattern(c *C, patternStr string) *patterns.PathPattern {
@@ -899,6 +910,41 @@ func (s *requestrulesSuite) TestUserSessionPath(c *C) {
 	}
 }
 
+func (s *requestrulesSuite) TestReadUserSessionID(c *C) {
+	userSessionIDXattr, restore := requestrules.MockUserSessionIDXattr()
+	defer restore()
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	// If there is no user session dir, expect errNoUserSession
+	sessionID, err := rdb.ReadUserSessionID(1000)
+	c.Assert(err, ErrorMatches, "cannot find systemd user session tmpfs for user: 1000")
+	c.Assert(sessionID, Equals, prompting.IDType(0))
+
+	// If the session has no ID yet, none is assigned
+	sessionDir := filepath.Join(dirs.GlobalRootDir, "run/user/1000")
+	c.Assert(os.MkdirAll(sessionDir, 0o700), IsNil)
+	sessionID, err = rdb.ReadUserSessionID(1000)
+	c.Assert(err, IsNil)
+	c.Assert(sessionID, Equals, prompting.IDType(0))
+	_, err = unix.Getxattr(sessionDir, userSessionIDXattr, make([]byte, 16))
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		c.Skip("xattrs are not supported on this system")
+	}
+	c.Assert(errors.Is(err, unix.ENODATA), Equals, true, Commentf("%v", err))
+
+	// Once assigned, the ID is returned
+	assignedID, err := rdb.ReadOrAssignUserSessionID(1000)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		c.Skip("xattrs are not supported on this system")
+	}
+	c.Assert(err, IsNil)
+	sessionID, err = rdb.ReadUserSessionID(1000)
+	c.Assert(err, IsNil)
+	c.Assert(sessionID, Equals, assignedID)
+}
+
 func (s *requestrulesSuite) TestReadOrAssignUserSessionID(c *C) {
 	userSessionIDXattr, restore := requestrules.MockUserSessionIDXattr()
 	defer restore()
@@ -2013,7 +2059,7 @@ func (s *requestrulesSuite) TestIsRequestAllowed(c *C) {
 	} {
 		before := time.Now()
 
-		restore := requestrules.MockIsPathPermAllowed(func(r *requestrules.RuleDB, u uint32, s string, i string, p string, perm string, at prompting.At) (bool, error) {
+		restore := requestrules.MockPathPermDecision(func(r *requestrules.RuleDB, u uint32, s string, i string, p string, perm string, at prompting.At) (bool, []prompting.IDType, error) {
 			c.Assert(r, Equals, rdb)
 			c.Assert(u, Equals, user)
 			c.Assert(s, Equals, snap)
@@ -2023,7 +2069,7 @@ func (s *requestrulesSuite) TestIsRequestAllowed(c *C) {
 			c.Assert(at.Time.After(before), Equals, true)
 			c.Assert(at.Time.Before(time.Now()), Equals, true)
 			result := testCase.permReturns[perm]
-			return result.allowed, result.err
+			return result.allowed, nil, result.err
 		})
 		defer restore()
 
@@ -2039,6 +2085,151 @@ func (s *requestrulesSuite) TestIsRequestAllowed(c *C) {
 	}
 }
 
+func (s *requestrulesSuite) TestIsRequestAllowedSecurityLog(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	user := s.defaultUser
+	snap := "firefox"
+	iface := "home"
+
+	template := &addRuleContents{
+		User:        user,
+		Snap:        snap,
+		Interface:   iface,
+		PathPattern: "/home/test/path/to/file.txt",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	readRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{})
+	c.Assert(err, IsNil)
+	writeRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/path/to/*",
+		Permissions: []string{"write"},
+	})
+	c.Assert(err, IsNil)
+	denyRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/path/**",
+		Permissions: []string{"execute"},
+		Outcome:     prompting.OutcomeDeny,
+	})
+	c.Assert(err, IsNil)
+
+	// Request allowed by existing rules
+	_, anyDenied, outstanding, err := rdb.IsRequestAllowed(user, snap, iface, "/home/test/path/to/file.txt", []string{"read", "write"})
+	c.Assert(err, IsNil)
+	c.Check(anyDenied, Equals, false)
+	c.Check(outstanding, HasLen, 0)
+	c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_rule_decision Request 1000:firefox:home:/home/test/path/to/file.txt decided with outcome allow by rules %s,%s", readRule.ID, writeRule.ID))
+	c.Check(s.seclogBuf.String(), testutil.Contains, `[prompt_id=""]`)
+	s.seclogBuf.Reset()
+
+	// Request denied by existing rules, only the deny rule determines the outcome
+	_, anyDenied, _, err = rdb.IsRequestAllowed(user, snap, iface, "/home/test/path/to/file.txt", []string{"read", "execute"})
+	c.Assert(err, IsNil)
+	c.Check(anyDenied, Equals, true)
+	c.Check(s.seclogBuf.String(), testutil.Contains, fmt.Sprintf("prompt_rule_decision Request 1000:firefox:home:/home/test/path/to/file.txt decided with outcome deny by rules %s [", denyRule.ID))
+	s.seclogBuf.Reset()
+
+	// Request which would result in a prompt is not decided by rules
+	_, anyDenied, outstanding, err = rdb.IsRequestAllowed(user, snap, iface, "/home/test/path/to/file.txt", []string{"read", "create"})
+	c.Assert(err, IsNil)
+	c.Check(anyDenied, Equals, false)
+	c.Check(outstanding, DeepEquals, []string{"create"})
+	c.Check(s.seclogBuf.String(), Equals, "")
+
+	// Evaluating a hypothetical request is not recorded either
+	_, err = rdb.EvaluateRequest(user, snap, iface, "/home/test/path/to/file.txt", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(s.seclogBuf.String(), Equals, "")
+}
+
+func (s *requestrulesSuite) TestEvaluateRequest(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	user := s.defaultUser
+	snap := "firefox"
+	iface := "home"
+
+	template := &addRuleContents{
+		User:        user,
+		Snap:        snap,
+		Interface:   iface,
+		PathPattern: "/home/test/path/to/file.txt",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	exactRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{})
+	c.Assert(err, IsNil)
+	globRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/path/**",
+		Permissions: []string{"read", "write"},
+		Outcome:     prompting.OutcomeDeny,
+	})
+	c.Assert(err, IsNil)
+
+	decision, err := rdb.EvaluateRequest(user, snap, iface, "/home/test/path/to/file.txt", []string{"read", "write", "execute"})
+	c.Assert(err, IsNil)
+	c.Check(decision, DeepEquals, &requestrules.RequestDecision{
+		AllowedPermissions:     []string{"read"},
+		DeniedPermissions:      []string{"write"},
+		OutstandingPermissions: []string{"execute"},
+		MatchedRules: map[string][]prompting.IDType{
+			"read":  {exactRule.ID},
+			"write": {globRule.ID},
+		},
+	})
+
+	decision, err = rdb.EvaluateRequest(user, snap, iface, "/home/test/other.txt", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(decision, DeepEquals, &requestrules.RequestDecision{
+		AllowedPermissions:     []string{},
+		DeniedPermissions:      []string{},
+		OutstandingPermissions: []string{"read"},
+		MatchedRules:           map[string][]prompting.IDType{},
+	})
+
+	// Evaluating a request does not record any notices
+	s.checkNewNoticesSimple(c, nil, exactRule, globRule)
+	s.checkNewNoticesSimple(c, nil)
+}
+
+func (s *requestrulesSuite) TestEvaluateRequestWithoutSessionID(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/test/path/**",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanSession,
+	}
+	_, err = addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+
+	// The session has no ID yet, so evaluating must not assign one, and
+	// session rules do not apply
+	restore := requestrules.MockReadOrAssignUserSessionID(func(rdb *requestrules.RuleDB, user uint32) (prompting.IDType, error) {
+		c.Fatalf("unexpected session ID assignment")
+		return 0, nil
+	})
+	defer restore()
+	restore = requestrules.MockReadUserSessionID(func(rdb *requestrules.RuleDB, user uint32) (prompting.IDType, error) {
+		return 0, nil
+	})
+	defer restore()
+
+	decision, err := rdb.EvaluateRequest(s.defaultUser, "firefox", "home", "/home/test/path/file.txt", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(decision.OutstandingPermissions, DeepEquals, []string{"read"})
+}
+
 func (s *requestrulesSuite) TestIsPathPermAllowedSimple(c *C) {
 	// Target
 	user := s.defaultUser
@@ -2150,6 +2341,63 @@ func (s *requestrulesSuite) TestIsPathPermAllowedSimple(c *C) {
 	}
 }
 
+func (s *requestrulesSuite) TestIsPathPermAllowedFileInterfaces(c *C) {
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	c.Assert(rdb, NotNil)
+
+	user := s.defaultUser
+	snap := "nautilus"
+
+	template := &addRuleContents{
+		User:     user,
+		Snap:     snap,
+		Outcome:  prompting.OutcomeAllow,
+		Lifespan: prompting.LifespanForever,
+	}
+
+	removableRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		Interface:   "removable-media",
+		PathPattern: "/media/test/*/Photos/**",
+		Permissions: []string{"read", "write"},
+	})
+	c.Assert(err, IsNil)
+	personalRule, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		Interface:   "personal-files",
+		PathPattern: "/home/test/.config/nautilus/**",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeDeny,
+	})
+	c.Assert(err, IsNil)
+	s.checkWrittenRuleDB(c, []*requestrules.Rule{removableRule, personalRule})
+
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: s.currSession,
+	}
+	for _, testCase := range []struct {
+		iface      string
+		path       string
+		permission string
+		allowed    bool
+		err        error
+	}{
+		{"removable-media", "/media/test/usb-stick/Photos/foo.jpg", "read", true, nil},
+		{"removable-media", "/media/test/usb-stick/Photos/foo.jpg", "write", true, nil},
+		{"removable-media", "/media/test/usb-stick/Photos/foo.jpg", "execute", false, prompting_errors.ErrNoMatchingRule},
+		{"removable-media", "/media/test/usb-stick/Music/foo.mp3", "read", false, prompting_errors.ErrNoMatchingRule},
+		{"personal-files", "/home/test/.config/nautilus/settings", "read", false, nil},
+		{"personal-files", "/home/test/.config/nautilus/settings", "write", false, prompting_errors.ErrNoMatchingRule},
+		// Rules for one interface do not apply to requests for another
+		{"home", "/home/test/.config/nautilus/settings", "read", false, prompting_errors.ErrNoMatchingRule},
+		{"personal-files", "/media/test/usb-stick/Photos/foo.jpg", "read", false, prompting_errors.ErrNoMatchingRule},
+	} {
+		allowed, err := rdb.IsPathPermAllowed(user, snap, testCase.iface, testCase.path, testCase.permission, at)
+		c.Check(err, Equals, testCase.err, Commentf("testCase: %+v", testCase))
+		c.Check(allowed, Equals, testCase.allowed, Commentf("testCase: %+v", testCase))
+	}
+}
+
 func (s *requestrulesSuite) TestIsPathPermAllowedPrecedence(c *C) {
 	// Target
 	user := s.defaultUser
@@ -0,0 +1,452 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/patterns"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/user"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	// systemRulesOwnerUID is the UID which must own system rules files.
+	systemRulesOwnerUID = uint32(0)
+
+	// userGroupIDs returns the IDs of the groups of which the given user is
+	// a member. A user which does not exist is not a member of any group.
+	userGroupIDs = func(uid uint32) ([]string, error) {
+		u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+		if err != nil {
+			var unknownErr user.UnknownUserIdError
+			if errors.As(err, &unknownErr) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return u.GroupIds()
+	}
+
+	// lookupGroupID returns the ID of the group with the given name.
+	lookupGroupID = func(name string) (string, error) {
+		group, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return group.Gid, nil
+	}
+)
+
+// SystemRule stores the contents of a rule defined by the system
+// administrator. A system rule applies to every user, or only to the given
+// users and members of the given groups, and takes precedence over any rule
+// created by those users.
+type SystemRule struct {
+	ID          prompting.IDType           `json:"id"`
+	Snap        string                     `json:"snap"`
+	Interface   string                     `json:"interface"`
+	Users       []uint32                   `json:"users,omitempty"`
+	Groups      []string                   `json:"groups,omitempty"`
+	Constraints *prompting.RuleConstraints `json:"constraints"`
+
+	// groupIDs holds the IDs of the groups in Groups, resolved when the
+	// rule was loaded.
+	groupIDs []string
+}
+
+// appliesToUser returns true if the system rule applies to the given user.
+// The given groupIDs function is only called if the rule is restricted to
+// particular groups, and the user is not otherwise matched by the rule.
+func (rule *SystemRule) appliesToUser(uid uint32, groupIDs func() ([]string, error)) (bool, error) {
+	if len(rule.Users) == 0 && len(rule.groupIDs) == 0 {
+		return true, nil
+	}
+	for _, u := range rule.Users {
+		if u == uid {
+			return true, nil
+		}
+	}
+	if len(rule.groupIDs) == 0 {
+		return false, nil
+	}
+	gids, err := groupIDs()
+	if err != nil {
+		return false, err
+	}
+	for _, gid := range gids {
+		if strutil.ListContains(rule.groupIDs, gid) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// systemRulesFileJSON defines the structure of a system rules file. Rule
+// constraints are given in the same form as when adding a rule through the
+// API, and every permission entry must have lifespan "forever".
+type systemRulesFileJSON struct {
+	Rules []struct {
+		Snap        string                    `json:"snap"`
+		Interface   string                    `json:"interface"`
+		Users       []uint32                  `json:"users,omitempty"`
+		Groups      []string                  `json:"groups,omitempty"`
+		Constraints prompting.ConstraintsJSON `json:"constraints"`
+	} `json:"rules"`
+}
+
+// loadSystemRules reads the system rules files from the system rules
+// directory and populates the system rules of the receiving rule database.
+//
+// The system rules directory and files must be owned by root and must not be
+// writable by other users. If the directory does not satisfy this, no system
+// rules are loaded. Files which do not satisfy this, or which contain an
+// invalid rule, are ignored in their entirety. A rule which conflicts with a
+// system rule from a previously loaded file is ignored.
+//
+// TODO: support loading system rules from a signed assertion.
+//
+// The caller must ensure that the database lock is held for writing.
+func (rdb *RuleDB) loadSystemRules() {
+	rdb.systemRules = nil
+
+	fi, err := os.Stat(dirs.SnapInterfacesRequestsSystemRulesDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Noticef("cannot load system rules: %v", err)
+		}
+		return
+	}
+	if err := checkSystemRulesOwnership(fi, "directory"); err != nil {
+		logger.Noticef("cannot load system rules from %s: %v", dirs.SnapInterfacesRequestsSystemRulesDir, err)
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapInterfacesRequestsSystemRulesDir, "*.json"))
+	if err != nil {
+		// Only possible error is ErrBadPattern, which should not occur
+		logger.Noticef("cannot list system rules files: %v", err)
+		return
+	}
+	sort.Strings(matches)
+
+	for _, path := range matches {
+		rules, err := readSystemRulesFile(path)
+		if err != nil {
+			logger.Noticef("cannot load system rules from %s: %v", path, err)
+			continue
+		}
+		for i, rule := range rules {
+			if conflicts := rdb.systemRuleConflictsWithSystemRules(rule); len(conflicts) > 0 {
+				err := &prompting_errors.RuleConflictError{Conflicts: conflicts}
+				logger.Noticef("cannot add system rule for snap %q and interface %q from %s: %v", rule.Snap, rule.Interface, path, err)
+				continue
+			}
+			rule.ID = systemRuleID(filepath.Base(path), i)
+			rdb.systemRules = append(rdb.systemRules, rule)
+		}
+	}
+}
+
+// systemRuleID returns the ID of the rule at the given index in the system
+// rules file with the given name. System rule IDs are derived from where the
+// rule is defined so that they are stable across reloads, and have the most
+// significant bit set so that they cannot clash with the IDs of user rules,
+// which are allocated sequentially.
+func systemRuleID(filename string, index int) prompting.IDType {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%d", filename, index)
+	return prompting.IDType(h.Sum64() | 1<<63)
+}
+
+// readSystemRulesFile reads and validates the system rules in the file at the
+// given path.
+func readSystemRulesFile(path string) ([]*SystemRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSystemRulesOwnership(fi, "file"); err != nil {
+		return nil, err
+	}
+
+	var wrapped systemRulesFileJSON
+	if err := json.NewDecoder(f).Decode(&wrapped); err != nil {
+		return nil, fmt.Errorf("cannot decode system rules: %w", err)
+	}
+
+	// Only forever permissions are allowed, so the point in time has no
+	// effect on the resulting rule constraints.
+	at := prompting.At{Time: time.Now()}
+	rules := make([]*SystemRule, 0, len(wrapped.Rules))
+	for i, ruleJSON := range wrapped.Rules {
+		if err := naming.ValidateSnap(ruleJSON.Snap); err != nil {
+			return nil, fmt.Errorf("invalid system rule %d: %w", i, err)
+		}
+		constraints, err := prompting.UnmarshalConstraints(ruleJSON.Interface, ruleJSON.Constraints)
+		if err != nil {
+			return nil, fmt.Errorf("invalid system rule %d: %w", i, err)
+		}
+		for perm, entry := range constraints.Permissions {
+			if entry.Lifespan != prompting.LifespanForever {
+				return nil, fmt.Errorf("invalid system rule %d: permission %q must have lifespan %q", i, perm, prompting.LifespanForever)
+			}
+		}
+		groupIDs := make([]string, 0, len(ruleJSON.Groups))
+		for _, group := range ruleJSON.Groups {
+			gid, err := lookupGroupID(group)
+			if err != nil {
+				return nil, fmt.Errorf("invalid system rule %d: cannot find group %q: %w", i, group, err)
+			}
+			groupIDs = append(groupIDs, gid)
+		}
+		rules = append(rules, &SystemRule{
+			Snap:        ruleJSON.Snap,
+			Interface:   ruleJSON.Interface,
+			Users:       ruleJSON.Users,
+			Groups:      ruleJSON.Groups,
+			Constraints: constraints.ToRuleConstraints(at),
+			groupIDs:    groupIDs,
+		})
+	}
+	return rules, nil
+}
+
+// checkSystemRulesOwnership returns an error if the system rules file or
+// directory with the given info could have been modified by an unprivileged
+// user. The given kind is used in error messages.
+func checkSystemRulesOwnership(fi fs.FileInfo, kind string) error {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot get %s ownership", kind)
+	}
+	if stat.Uid != systemRulesOwnerUID {
+		return fmt.Errorf("%s must be owned by uid %d", kind, systemRulesOwnerUID)
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s must not be writable by group or others", kind)
+	}
+	return nil
+}
+
+// systemRulesForSnapInterface returns the system rules for the given snap and
+// interface.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) systemRulesForSnapInterface(snap string, iface string) []*SystemRule {
+	var rules []*SystemRule
+	for _, rule := range rdb.systemRules {
+		if rule.Snap == snap && rule.Interface == iface {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// systemRuleConflictsWithSystemRules returns the conflicts between the given
+// system rule and the existing system rules. Two system rules conflict if
+// they have an identical path pattern variant for the same permission with
+// differing outcomes, regardless of which users they apply to.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) systemRuleConflictsWithSystemRules(rule *SystemRule) []prompting_errors.RuleConflict {
+	var conflicts []prompting_errors.RuleConflict
+	for _, existing := range rdb.systemRulesForSnapInterface(rule.Snap, rule.Interface) {
+		conflicts = append(conflicts, constraintsConflicts(rule.Constraints, existing.Constraints, existing.ID)...)
+	}
+	return conflicts
+}
+
+// systemRuleConflicts returns the conflicts between the given user rule and
+// the system rules which apply to the user of that rule. A user rule
+// conflicts with a system rule if they have an identical path pattern variant
+// for the same permission with differing outcomes.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) systemRuleConflicts(rule *Rule) ([]prompting_errors.RuleConflict, error) {
+	groupIDs := userGroupIDsOnce(rule.User)
+	var conflicts []prompting_errors.RuleConflict
+	for _, systemRule := range rdb.systemRulesForSnapInterface(rule.Snap, rule.Interface) {
+		applies, err := systemRule.appliesToUser(rule.User, groupIDs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check whether system rule applies to user %d: %w", rule.User, err)
+		}
+		if !applies {
+			continue
+		}
+		conflicts = append(conflicts, constraintsConflicts(rule.Constraints, systemRule.Constraints, systemRule.ID)...)
+	}
+	return conflicts, nil
+}
+
+// constraintsConflicts returns a conflict for every permission and path
+// pattern variant which occurs in both of the given rule constraints with
+// differing outcomes. The given ID is reported as the conflicting rule ID.
+func constraintsConflicts(constraints, existing *prompting.RuleConstraints, existingID prompting.IDType) []prompting_errors.RuleConflict {
+	var conflicts []prompting_errors.RuleConflict
+	existingVariants := make(map[string]bool, existing.PathPattern().NumVariants())
+	existing.PathPattern().RenderAllVariants(func(index int, variant patterns.PatternVariant) {
+		existingVariants[variant.String()] = true
+	})
+	for perm, entry := range constraints.Permissions {
+		existingEntry, ok := existing.Permissions[perm]
+		if !ok || existingEntry.Outcome == entry.Outcome {
+			continue
+		}
+		seen := make(map[string]bool)
+		constraints.PathPattern().RenderAllVariants(func(index int, variant patterns.PatternVariant) {
+			variantStr := variant.String()
+			if seen[variantStr] || !existingVariants[variantStr] {
+				return
+			}
+			seen[variantStr] = true
+			conflicts = append(conflicts, prompting_errors.RuleConflict{
+				Permission:    perm,
+				Variant:       variantStr,
+				ConflictingID: existingID.String(),
+			})
+		})
+	}
+	return conflicts
+}
+
+// userGroupIDsOnce returns a function which looks up the group IDs of the
+// given user the first time it is called, and returns the same result on
+// subsequent calls.
+func userGroupIDsOnce(uid uint32) func() ([]string, error) {
+	var (
+		done bool
+		gids []string
+		err  error
+	)
+	return func() ([]string, error) {
+		if !done {
+			gids, err = userGroupIDs(uid)
+			done = true
+		}
+		return gids, err
+	}
+}
+
+// pathPermDecisionBySystemRules checks whether the given path with the given
+// permission is allowed or denied by system rules which apply to the given
+// user, snap, and interface, and returns the IDs of the system rules which
+// determine that outcome.
+//
+// If no system rule applies, returns prompting_errors.ErrNoMatchingRule.
+//
+// The caller must ensure that the database lock is held.
+func (rdb *RuleDB) pathPermDecisionBySystemRules(user uint32, snap string, iface string, path string, permission string) (bool, []prompting.IDType, error) {
+	groupIDs := userGroupIDsOnce(user)
+	var matchingVariants []patterns.PatternVariant
+	outcomes := make(map[string]prompting.OutcomeType)
+	variantRuleIDs := make(map[string][]prompting.IDType)
+	var matchErr error
+	for _, rule := range rdb.systemRulesForSnapInterface(snap, iface) {
+		entry, ok := rule.Constraints.Permissions[permission]
+		if !ok {
+			continue
+		}
+		applies, err := rule.appliesToUser(user, groupIDs)
+		if err != nil {
+			return false, nil, fmt.Errorf("cannot check whether system rule applies to user %d: %w", user, err)
+		}
+		if !applies {
+			continue
+		}
+		seen := make(map[string]bool)
+		rule.Constraints.PathPattern().RenderAllVariants(func(index int, variant patterns.PatternVariant) {
+			variantStr := variant.String()
+			if seen[variantStr] || matchErr != nil {
+				return
+			}
+			seen[variantStr] = true
+			if _, exists := outcomes[variantStr]; exists {
+				// System rules cannot conflict, so the outcome is the same
+				variantRuleIDs[variantStr] = append(variantRuleIDs[variantStr], rule.ID)
+				return
+			}
+			matched, err := patterns.PathPatternMatches(variantStr, path)
+			if err != nil {
+				// Only possible error is ErrBadPattern, which should not occur
+				matchErr = fmt.Errorf("internal error: while matching path pattern: %w", err)
+				return
+			}
+			if matched {
+				matchingVariants = append(matchingVariants, variant)
+				outcomes[variantStr] = entry.Outcome
+				variantRuleIDs[variantStr] = []prompting.IDType{rule.ID}
+			}
+		})
+		if matchErr != nil {
+			return false, nil, matchErr
+		}
+	}
+	if len(matchingVariants) == 0 {
+		return false, nil, prompting_errors.ErrNoMatchingRule
+	}
+	highestPrecedenceVariant, err := patterns.HighestPrecedencePattern(matchingVariants, path)
+	if err != nil {
+		return false, nil, err
+	}
+	ruleIDs := variantRuleIDs[highestPrecedenceVariant.String()]
+	sortRuleIDs(ruleIDs)
+	allowed, err := outcomes[highestPrecedenceVariant.String()].AsBool()
+	return allowed, ruleIDs, err
+}
+
+// SystemRules returns all system rules which apply to the given user.
+func (rdb *RuleDB) SystemRules(user uint32) ([]*SystemRule, error) {
+	rdb.mutex.RLock()
+	defer rdb.mutex.RUnlock()
+	groupIDs := userGroupIDsOnce(user)
+	rules := make([]*SystemRule, 0)
+	for _, rule := range rdb.systemRules {
+		applies, err := rule.appliesToUser(user, groupIDs)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check whether system rule applies to user %d: %w", user, err)
+		}
+		if applies {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,388 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package requestrules_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces/prompting"
+	prompting_errors "github.com/snapcore/snapd/interfaces/prompting/errors"
+	"github.com/snapcore/snapd/interfaces/prompting/requestrules"
+	"github.com/snapcore/snapd/logger"
+)
+
+const systemRulesSSH = `{"rules": [
+	{
+		"snap": "firefox",
+		"interface": "home",
+		"constraints": {
+			"path-pattern": "/home/*/.ssh/**",
+			"permissions": {
+				"read": {"outcome": "deny", "lifespan": "forever"},
+				"write": {"outcome": "deny", "lifespan": "forever"}
+			}
+		}
+	},
+	{
+		"snap": "firefox",
+		"interface": "home",
+		"groups": ["staff"],
+		"constraints": {
+			"path-pattern": "/home/*/Downloads/**",
+			"permissions": {
+				"write": {"outcome": "allow", "lifespan": "forever"}
+			}
+		}
+	}
+]}`
+
+func (s *requestrulesSuite) writeSystemRules(c *C, name string, content string) {
+	c.Assert(os.MkdirAll(dirs.SnapInterfacesRequestsSystemRulesDir, 0o755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dirs.SnapInterfacesRequestsSystemRulesDir, name), []byte(content), 0o644), IsNil)
+}
+
+func (s *requestrulesSuite) mockSystemRulesEnv(c *C) {
+	s.AddCleanup(requestrules.MockSystemRulesOwnerUID(uint32(os.Getuid())))
+	s.AddCleanup(requestrules.MockLookupGroupID(func(name string) (string, error) {
+		switch name {
+		case "staff":
+			return "50", nil
+		}
+		return "", fmt.Errorf("unknown group %s", name)
+	}))
+	s.AddCleanup(requestrules.MockUserGroupIDs(func(uid uint32) ([]string, error) {
+		if uid == s.defaultUser {
+			return []string{"1000", "50"}, nil
+		}
+		return []string{fmt.Sprint(uid)}, nil
+	}))
+}
+
+func (s *requestrulesSuite) TestSystemRulesLoadAndMatch(c *C) {
+	s.mockSystemRulesEnv(c)
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 2)
+	c.Check(rules[0].Snap, Equals, "firefox")
+	c.Check(rules[0].Interface, Equals, "home")
+	c.Check(rules[0].ID, Not(Equals), prompting.IDType(0))
+	c.Check(rules[1].Groups, DeepEquals, []string{"staff"})
+
+	// The group-restricted rule does not apply to other users
+	rules, err = rdb.SystemRules(s.defaultUser + 1)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 1)
+
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: s.currSession,
+	}
+	for _, testCase := range []struct {
+		user       uint32
+		path       string
+		permission string
+		allowed    bool
+		err        error
+	}{
+		{s.defaultUser, "/home/test/.ssh/id_rsa", "read", false, nil},
+		{s.defaultUser, "/home/test/.ssh/authorized_keys", "write", false, nil},
+		{s.defaultUser + 1, "/home/other/.ssh/id_rsa", "read", false, nil},
+		{s.defaultUser, "/home/test/.ssh/id_rsa", "execute", false, prompting_errors.ErrNoMatchingRule},
+		{s.defaultUser, "/home/test/Downloads/foo", "write", true, nil},
+		{s.defaultUser + 1, "/home/other/Downloads/foo", "write", false, prompting_errors.ErrNoMatchingRule},
+		{s.defaultUser, "/home/test/Documents/foo", "read", false, prompting_errors.ErrNoMatchingRule},
+	} {
+		allowed, err := rdb.IsPathPermAllowed(testCase.user, "firefox", "home", testCase.path, testCase.permission, at)
+		c.Check(err, Equals, testCase.err, Commentf("testCase: %+v", testCase))
+		c.Check(allowed, Equals, testCase.allowed, Commentf("testCase: %+v", testCase))
+	}
+
+	// System rules do not apply to other snaps or interfaces
+	_, err = rdb.IsPathPermAllowed(s.defaultUser, "thunderbird", "home", "/home/test/.ssh/id_rsa", "read", at)
+	c.Check(err, Equals, prompting_errors.ErrNoMatchingRule)
+	_, err = rdb.IsPathPermAllowed(s.defaultUser, "firefox", "personal-files", "/home/test/.ssh/id_rsa", "read", at)
+	c.Check(err, Equals, prompting_errors.ErrNoMatchingRule)
+
+	// System rules are not included with user rules
+	c.Check(rdb.Rules(s.defaultUser), HasLen, 0)
+}
+
+func (s *requestrulesSuite) TestSystemRulesTakePrecedence(c *C) {
+	s.mockSystemRulesEnv(c)
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/test/.ssh/id_rsa",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	// A more specific user rule is allowed, but has no effect
+	rule, err := addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+	c.Assert(rule, NotNil)
+
+	at := prompting.At{
+		Time:      time.Now(),
+		SessionID: s.currSession,
+	}
+	allowed, err := rdb.IsPathPermAllowed(s.defaultUser, "firefox", "home", "/home/test/.ssh/id_rsa", "read", at)
+	c.Check(err, IsNil)
+	c.Check(allowed, Equals, false)
+
+	// The system rule is reported as determining the outcome
+	systemRules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	decision, err := rdb.EvaluateRequest(s.defaultUser, "firefox", "home", "/home/test/.ssh/id_rsa", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(decision.DeniedPermissions, DeepEquals, []string{"read"})
+	c.Check(decision.MatchedRules, DeepEquals, map[string][]prompting.IDType{
+		"read": {systemRules[0].ID},
+	})
+
+	// A user rule whose pattern variant is identical to that of a system rule
+	// but with a different outcome conflicts with the system rule.
+	rule, err = addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/*/.ssh/**",
+		Permissions: []string{"read", "write"},
+	})
+	c.Check(rule, IsNil)
+	c.Assert(err, ErrorMatches, fmt.Sprintf("cannot add rule: %v", prompting_errors.ErrRuleConflict))
+	var conflictErr *prompting_errors.RuleConflictError
+	c.Assert(errors.As(err, &conflictErr), Equals, true)
+	c.Check(conflictErr.Conflicts, HasLen, 2)
+	for _, conflict := range conflictErr.Conflicts {
+		c.Check(conflict.ConflictingID, Equals, systemRules[0].ID.String())
+		c.Check(conflict.Variant, Equals, "/home/*/.ssh/**")
+	}
+
+	// The same rule with a matching outcome does not conflict
+	rule, err = addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/*/.ssh/**",
+		Outcome:     prompting.OutcomeDeny,
+	})
+	c.Check(err, IsNil)
+	c.Check(rule, NotNil)
+}
+
+func (s *requestrulesSuite) TestSystemRulesDropConflictingUserRulesOnLoad(c *C) {
+	s.mockSystemRulesEnv(c)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/*/.ssh/**",
+		Permissions: []string{"write"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	conflicting, err := addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+	good, err := addRuleFromTemplate(c, rdb, template, &addRuleContents{
+		PathPattern: "/home/test/Pictures/**",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rdb.Close(), IsNil)
+
+	// The administrator adds system rules after the user rules were created
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+	s.ruleNotices = s.ruleNotices[:0]
+
+	rdb, err = requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	rules := rdb.Rules(s.defaultUser)
+	c.Assert(rules, HasLen, 1)
+	c.Check(rules[0].ID, Equals, good.ID)
+	s.checkWrittenRuleDB(c, []*requestrules.Rule{good})
+	s.checkNewNoticesSimple(c, map[string]string{"removed": "dropped"}, conflicting)
+}
+
+func (s *requestrulesSuite) TestSystemRulesLoadErrors(c *C) {
+	s.mockSystemRulesEnv(c)
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	// Files are loaded in lexical order, so the valid rule in the first file
+	// takes precedence over the conflicting rule in the last file.
+	s.writeSystemRules(c, "00-valid.json", systemRulesSSH)
+	s.writeSystemRules(c, "10-bad-json.json", `{"rules": [`)
+	s.writeSystemRules(c, "20-bad-lifespan.json", `{"rules": [{"snap": "firefox", "interface": "home", "constraints": {"path-pattern": "/home/**", "permissions": {"read": {"outcome": "deny", "lifespan": "timespan", "duration": "1h"}}}}]}`)
+	s.writeSystemRules(c, "30-bad-group.json", `{"rules": [{"snap": "firefox", "interface": "home", "groups": ["nope"], "constraints": {"path-pattern": "/home/**", "permissions": {"read": {"outcome": "deny", "lifespan": "forever"}}}}]}`)
+	s.writeSystemRules(c, "40-bad-snap.json", `{"rules": [{"snap": "-foo", "interface": "home", "constraints": {"path-pattern": "/home/**", "permissions": {"read": {"outcome": "deny", "lifespan": "forever"}}}}]}`)
+	s.writeSystemRules(c, "50-bad-iface.json", `{"rules": [{"snap": "firefox", "interface": "foo", "constraints": {"path-pattern": "/home/**", "permissions": {"read": {"outcome": "deny", "lifespan": "forever"}}}}]}`)
+	s.writeSystemRules(c, "60-conflict.json", `{"rules": [{"snap": "firefox", "interface": "home", "constraints": {"path-pattern": "/home/*/.ssh/**", "permissions": {"read": {"outcome": "allow", "lifespan": "forever"}}}}]}`)
+	s.writeSystemRules(c, "70-world-writable.json", systemRulesSSH)
+	c.Assert(os.Chmod(filepath.Join(dirs.SnapInterfacesRequestsSystemRulesDir, "70-world-writable.json"), 0o666), IsNil)
+	s.writeSystemRules(c, "80-not-json.txt", `garbage`)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 2)
+
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/10-bad-json.json: cannot decode system rules: unexpected EOF.*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/20-bad-lifespan.json: invalid system rule 0: permission "read" must have lifespan "forever".*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/30-bad-group.json: invalid system rule 0: cannot find group "nope": unknown group nope.*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/40-bad-snap.json: invalid system rule 0: invalid snap name: "-foo".*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/50-bad-iface.json: invalid system rule 0: invalid interface.*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot add system rule for snap "firefox" and interface "home" from .*/60-conflict.json: .*`)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/70-world-writable.json: file must not be writable by group or others.*`)
+	c.Check(logbuf.String(), Not(Matches), `(?s).*80-not-json.txt.*`)
+}
+
+func (s *requestrulesSuite) TestSystemRulesOwnership(c *C) {
+	s.mockSystemRulesEnv(c)
+	restore := requestrules.MockSystemRulesOwnerUID(uint32(os.Getuid()) + 1)
+	defer restore()
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+	c.Check(logbuf.String(), Matches, fmt.Sprintf(`(?s).*cannot load system rules from .*/system-rules.d: directory must be owned by uid %d.*`, os.Getuid()+1))
+}
+
+func (s *requestrulesSuite) TestSystemRulesFileOwnership(c *C) {
+	if os.Geteuid() != 0 {
+		c.Skip("this test needs to change the owner of files")
+	}
+	s.mockSystemRulesEnv(c)
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+	c.Assert(os.Chown(filepath.Join(dirs.SnapInterfacesRequestsSystemRulesDir, "10-ssh.json"), 1000, 1000), IsNil)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/10-ssh.json: file must be owned by uid 0.*`)
+}
+
+func (s *requestrulesSuite) TestSystemRulesDirectoryWritable(c *C) {
+	s.mockSystemRulesEnv(c)
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+	c.Assert(os.Chmod(dirs.SnapInterfacesRequestsSystemRulesDir, 0o777), IsNil)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Check(rules, HasLen, 0)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot load system rules from .*/system-rules.d: directory must not be writable by group or others.*`)
+}
+
+func (s *requestrulesSuite) TestSystemRuleIDsAreStable(c *C) {
+	s.mockSystemRulesEnv(c)
+	s.writeSystemRules(c, "10-ssh.json", systemRulesSSH)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 2)
+	c.Check(rules[0].ID, Not(Equals), rules[1].ID)
+	for _, rule := range rules {
+		// system rule IDs cannot clash with sequentially allocated IDs
+		c.Check(uint64(rule.ID)&(1<<63), Not(Equals), uint64(0))
+	}
+	c.Assert(rdb.Close(), IsNil)
+
+	// adding a user rule does not affect system rule IDs
+	rdb, err = requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	template := &addRuleContents{
+		User:        s.defaultUser,
+		Snap:        "firefox",
+		Interface:   "home",
+		PathPattern: "/home/test/Documents/**",
+		Permissions: []string{"read"},
+		Outcome:     prompting.OutcomeAllow,
+		Lifespan:    prompting.LifespanForever,
+	}
+	_, err = addRuleFromTemplate(c, rdb, template, nil)
+	c.Assert(err, IsNil)
+	c.Assert(rdb.Close(), IsNil)
+
+	rdb, err = requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+	reloaded, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Assert(reloaded, HasLen, 2)
+	c.Check(reloaded[0].ID, Equals, rules[0].ID)
+	c.Check(reloaded[1].ID, Equals, rules[1].ID)
+}
+
+func (s *requestrulesSuite) TestSystemRuleMarshalJSON(c *C) {
+	s.mockSystemRulesEnv(c)
+	s.writeSystemRules(c, "10-ssh.json", `{"rules": [{"snap": "firefox", "interface": "home", "users": [1000], "constraints": {"path-pattern": "/home/*/.ssh/**", "permissions": {"read": {"outcome": "deny", "lifespan": "forever"}}}}]}`)
+
+	rdb, err := requestrules.New(s.defaultNotifyRule)
+	c.Assert(err, IsNil)
+
+	rules, err := rdb.SystemRules(s.defaultUser)
+	c.Assert(err, IsNil)
+	c.Assert(rules, HasLen, 1)
+
+	marshalled, err := json.Marshal(rules[0])
+	c.Assert(err, IsNil)
+	c.Check(string(marshalled), Equals, fmt.Sprintf(`{"id":"%s","snap":"firefox","interface":"home","users":[1000],"constraints":{"path-pattern":"/home/*/.ssh/**","permissions":{"read":{"outcome":"deny","lifespan":"forever"}}}}`, rules[0].ID))
+}
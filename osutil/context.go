@@ -89,3 +89,21 @@ func RunManyWithContext(
 	// Return nil or the first error (if any) returned by the spawned go routines
 	return g.Wait()
 }
+
+// ContextAfterFunc arranges to call f in its own goroutine once ctx is done.
+// Calling the returned stop function stops the association of ctx with f.
+//
+// TODO:GOVERSION: Remove this and just use context.AfterFunc once we're on Go 1.21.
+func ContextAfterFunc(ctx context.Context, f func()) (stop func()) {
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			f()
+		case <-stopCh:
+		}
+	}()
+	return func() {
+		close(stopCh)
+	}
+}
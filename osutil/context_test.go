@@ -226,3 +226,29 @@ func (ctxSuite) TestRunManyEmpty(c *check.C) {
 	err := osutil.RunManyWithContext(context.Background(), buildExec)
 	c.Assert(err, check.IsNil)
 }
+
+func (ctxSuite) TestContextAfterFunc(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	called := make(chan struct{})
+	stop := osutil.ContextAfterFunc(ctx, func() { close(called) })
+	defer stop()
+
+	cancel()
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		c.Fatal("function not called after context was cancelled")
+	}
+}
+
+func (ctxSuite) TestContextAfterFuncStopped(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var called int32
+	stop := osutil.ContextAfterFunc(ctx, func() { atomic.StoreInt32(&called, 1) })
+
+	// the context is not done when stopping, so f is never called
+	stop()
+	time.Sleep(10 * time.Millisecond)
+	c.Check(atomic.LoadInt32(&called), check.Equals, int32(0))
+}
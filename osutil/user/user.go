@@ -25,10 +25,11 @@ import (
 )
 
 type (
-	User              = osuser.User
-	Group             = osuser.Group
-	UnknownUserError  = osuser.UnknownUserError
-	UnknownGroupError = osuser.UnknownGroupError
+	User               = osuser.User
+	Group              = osuser.Group
+	UnknownUserError   = osuser.UnknownUserError
+	UnknownUserIdError = osuser.UnknownUserIdError
+	UnknownGroupError  = osuser.UnknownGroupError
 )
 
 const GetentBased = false
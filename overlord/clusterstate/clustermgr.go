@@ -22,8 +22,10 @@ package clusterstate
 import (
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/swfeats"
@@ -33,6 +35,18 @@ var applyClusterSubclusterChangeKind = swfeats.RegisterChangeKind("apply-cluster
 
 type ClusterManager struct {
 	state *state.State
+
+	membersMu sync.Mutex
+	members   members
+
+	peersMu sync.Mutex
+	peers   peerDistribution
+
+	rolloutMu sync.Mutex
+	rollout   rolloutPeers
+
+	replicationMu sync.Mutex
+	replication   replicationPeers
 }
 
 // Manager returns a new ClusterManager.
@@ -45,6 +59,23 @@ func Manager(st *state.State) *ClusterManager {
 // Ensure ensures that the device state matches the expectations defined by the
 // cluster assertion.
 func (m *ClusterManager) Ensure() error {
+	// exchanging messages with the other devices of the cluster should not
+	// prevent the cluster state from being applied
+	if err := m.ensureMembers(); err != nil {
+		logger.Noticef("cannot set up exchanging messages with cluster devices: %v", err)
+	}
+	if err := m.ensurePeerDistribution(); err != nil {
+		logger.Noticef("cannot set up exchanging snaps with cluster devices: %v", err)
+	}
+	// without the reports of the canary devices, the state is only applied
+	// on the canaries themselves
+	if err := m.ensureRollout(); err != nil {
+		logger.Noticef("cannot exchange cluster rollout reports: %v", err)
+	}
+	if err := m.ensureConfdbReplication(); err != nil {
+		logger.Noticef("cannot replicate confdb databags: %v", err)
+	}
+
 	enabled, err := clusteringEnabled(m.state)
 	if err != nil {
 		return err
@@ -65,7 +96,19 @@ func (m *ClusterManager) Ensure() error {
 		return fmt.Errorf("cannot get cluster assertion: %w", err)
 	}
 
-	tasksets, err := applyClusterState(m.state, cluster)
+	staged, err := newStagedRollout(m.state, cluster, m.canaryReports())
+	if err != nil {
+		return err
+	}
+	if staged != nil {
+		if err := staged.update(m.state); err != nil {
+			return err
+		}
+		// the progress is recorded even if applying the state fails
+		defer staged.save(m.state)
+	}
+
+	tasksets, err := applyClusterState(m.state, cluster, staged)
 	if err != nil {
 		return err
 	}
@@ -89,6 +132,10 @@ func (m *ClusterManager) Ensure() error {
 		chg.Set("cluster-change-ref", ref)
 
 		chg.AddAll(tasks)
+
+		if staged != nil {
+			staged.started(name, chg)
+		}
 	}
 
 	return nil
@@ -231,18 +231,14 @@ func decodeClusterBundle(bundle io.Reader) (*asserts.Batch, *asserts.Cluster, er
 }
 
 // applyClusterState creates the tasks needed to apply the state described by
-// the cluster assertion on this device.
-func applyClusterState(st *state.State, cluster *asserts.Cluster) (map[string]*state.TaskSet, error) {
-	serial, err := devicestate.Serial(st)
+// the cluster assertion on this device. During a staged rollout, the
+// subclusters whose state cannot be applied yet are skipped.
+func applyClusterState(st *state.State, cluster *asserts.Cluster, staged *stagedRollout) (map[string]*state.TaskSet, error) {
+	deviceID, err := clusterDeviceID(st, cluster)
 	if err != nil {
 		return nil, err
 	}
 
-	deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial())
-	if !ok {
-		return nil, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
-	}
-
 	// mapping of subcluster name to tasks to match desired subcluster state
 	tasksets := make(map[string]*state.TaskSet)
 	for _, subcluster := range cluster.Subclusters() {
@@ -250,12 +246,25 @@ func applyClusterState(st *state.State, cluster *asserts.Cluster) (map[string]*s
 			continue
 		}
 
+		if staged != nil {
+			ok, err := staged.mayApply(st, subcluster)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
 		ts, err := applySubcluster(st, subcluster)
 		if err != nil {
 			return nil, err
 		}
 
 		if len(ts.Tasks()) == 0 {
+			if staged != nil {
+				staged.applied(subcluster.Name)
+			}
 			continue
 		}
 
@@ -378,6 +387,20 @@ func deviceInSubcluster(subcluster asserts.Subcluster, deviceID int) bool {
 	return false
 }
 
+// clusterDeviceID returns the ID of this device in the cluster assertion.
+func clusterDeviceID(st *state.State, cluster *asserts.Cluster) (int, error) {
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return 0, err
+	}
+
+	deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial())
+	if !ok {
+		return 0, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
+	}
+	return deviceID, nil
+}
+
 func clusterDeviceIDBySerial(cluster *asserts.Cluster, serial string) (int, bool) {
 	for _, dev := range cluster.Devices() {
 		if dev.Serial == serial {
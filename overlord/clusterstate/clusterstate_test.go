@@ -414,7 +414,7 @@ func (s *managerSuite) TestEnsureClusteringDisabled(c *check.C) {
 }
 
 func (s *managerSuite) TestEnsureLoopHasLogging(c *check.C) {
-	swfeatstest.CheckEnsureLoopLogging("clustermgr.go", c, false)
+	swfeatstest.CheckEnsureLoopLogging("clustermgr.go", c, true)
 }
 
 func (s *managerSuite) TestApplyClusterStateNoActions(c *check.C) {
@@ -1006,6 +1006,10 @@ func makeClusterBundleWithSigning(
 
 func makeSerialAssertion(c *check.C, stack *assertstest.StoreStack, serial string) *asserts.Serial {
 	deviceKey, _ := assertstest.GenerateKey(752)
+	return makeSerialAssertionForKey(c, stack, serial, deviceKey)
+}
+
+func makeSerialAssertionForKey(c *check.C, stack *assertstest.StoreStack, serial string, deviceKey asserts.PrivateKey) *asserts.Serial {
 	encodedKey, err := asserts.EncodePublicKey(deviceKey.PublicKey())
 	c.Assert(err, check.IsNil)
 
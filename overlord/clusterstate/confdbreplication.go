@@ -0,0 +1,524 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/replication"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+)
+
+func init() {
+	swfeats.RegisterEnsure("ClusterManager", "ensureConfdbReplication")
+	confdbstate.AddCommitObserver(confdbCommitted)
+}
+
+// replicationRetryInterval is how long to wait before pushing again a
+// databag to a device which could not receive it.
+var replicationRetryInterval = 30 * time.Second
+
+// replicationMaxLag is how long a device can go without holding the latest
+// version of a replicated databag before it's notified.
+var replicationMaxLag = 10 * time.Minute
+
+// replicationNoticeRepeatAfter bounds how often replication problems with a
+// databag are notified.
+const replicationNoticeRepeatAfter = time.Hour
+
+// replicationPushTimeout bounds pushing a databag to a device.
+const replicationPushTimeout = 10 * time.Second
+
+var replicationPush = replication.Push
+
+// replicationPeers tracks the exchange of databags with the other devices of
+// the cluster.
+type replicationPeers struct {
+	// failed holds when pushing a version of a databag to a device last
+	// failed.
+	failed map[replicationAttempt]time.Time
+	// cancel and done are set while databags are being pushed, done being
+	// closed once pushing is over
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type replicationAttempt struct {
+	key     string
+	device  int
+	version replication.Version
+}
+
+// replicatedDatabag is the replication state of the databag of a
+// confdb-schema, as kept in the state under "cluster-confdb-replication" by
+// "<account>/<confdb-schema>".
+type replicatedDatabag struct {
+	// ClusterID is the ID of the cluster the devices below belong to.
+	ClusterID string `json:"cluster-id"`
+	// Version is the version of the data held by this device.
+	Version replication.Version `json:"version"`
+	// Acked holds the latest version each other device is known to hold, by
+	// device ID.
+	Acked map[int]replication.Version `json:"acked,omitempty"`
+	// LaggingSince holds since when each other device has not held the
+	// version of this device, by device ID.
+	LaggingSince map[int]time.Time `json:"lagging-since,omitempty"`
+}
+
+func replicatedDatabags(st *state.State) (map[string]*replicatedDatabag, error) {
+	var entries map[string]*replicatedDatabag
+	if err := st.Get("cluster-confdb-replication", &entries); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if entries == nil {
+		entries = make(map[string]*replicatedDatabag)
+	}
+	return entries, nil
+}
+
+// replicatedDatabagFor returns the replication state of the databag for the
+// given cluster, forgetting about the devices of a previous cluster.
+func replicatedDatabagFor(entries map[string]*replicatedDatabag, key, clusterID string) *replicatedDatabag {
+	entry := entries[key]
+	if entry == nil {
+		entry = &replicatedDatabag{}
+		entries[key] = entry
+	}
+	if entry.ClusterID != clusterID {
+		entry.ClusterID = clusterID
+		entry.Acked = nil
+		entry.LaggingSince = nil
+	}
+	if entry.Acked == nil {
+		entry.Acked = make(map[int]replication.Version)
+	}
+	if entry.LaggingSince == nil {
+		entry.LaggingSince = make(map[int]time.Time)
+	}
+	return entry
+}
+
+// replicatedSchemas returns the confdb-schemas, as "<account>/<confdb-schema>",
+// whose databags are replicated across the devices of the cluster as set with
+// the core.cluster.confdb.replicate option.
+func replicatedSchemas(tr *config.Transaction) ([]string, error) {
+	var val string
+	if err := tr.Get("core", "cluster.confdb.replicate", &val); err != nil {
+		if config.IsNoOption(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// the value is validated by configcore when set
+	var keys []string
+	for _, key := range strings.Split(val, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func splitSchemaKey(key string) (account, schemaName string) {
+	account, schemaName, _ = strings.Cut(key, "/")
+	return account, schemaName
+}
+
+// confdbCommitted assigns a new version to the databag changed by a
+// transaction committed on this device, superseding all the versions this
+// device knows about, so that it's pushed to the other devices of the
+// cluster. Versions are assigned even if the databag isn't replicated yet, so
+// that the latest data is pushed once it is.
+func confdbCommitted(st *state.State, account, schemaName string) {
+	enabled, err := features.Flag(config.NewTransaction(st), features.Clustering)
+	if err != nil || !enabled {
+		return
+	}
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		return
+	}
+	deviceID, err := clusterDeviceID(st, cluster)
+	if err != nil {
+		return
+	}
+	entries, err := replicatedDatabags(st)
+	if err != nil {
+		logger.Noticef("cannot record version of confdb %s/%s: %v", account, schemaName, err)
+		return
+	}
+
+	entry := replicatedDatabagFor(entries, account+"/"+schemaName, cluster.ClusterID())
+	entry.Version = replication.Version{Counter: entry.Version.Counter + 1, Device: deviceID}
+	st.Set("cluster-confdb-replication", entries)
+	st.EnsureBefore(0)
+}
+
+// pendingPush is a version of a databag to push to a device.
+type pendingPush struct {
+	key       string
+	device    int
+	addresses []string
+	version   replication.Version
+	update    *replication.Update
+}
+
+// ensureConfdbReplication starts pushing the databags of the replicated
+// confdb-schemas to the other devices of the cluster which don't hold the
+// latest version known by this device. The databags are pushed in the
+// background, and the next ensure pass looks for newer versions once they
+// are. The databags pushed by the other devices are received by the
+// transport set up by ensureMembers.
+func (m *ClusterManager) ensureConfdbReplication() error {
+	logger.Trace("ensure", "manager", "ClusterManager", "func", "ensureConfdbReplication")
+	enabled, err := clusteringEnabled(m.state)
+	if err != nil {
+		return err
+	}
+
+	m.replicationMu.Lock()
+	defer m.replicationMu.Unlock()
+
+	var pushes []pendingPush
+	if enabled {
+		m.state.Lock()
+		pushes, enabled, err = m.pendingPushesLocked(m.state)
+		m.state.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !enabled {
+		m.stopReplicationLocked()
+		return nil
+	}
+	if m.replication.done != nil || len(pushes) == 0 {
+		return nil
+	}
+	client := m.memberClient()
+	if client == nil {
+		return fmt.Errorf("cannot push replicated confdb databags: not exchanging messages with cluster devices")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.replication.cancel = cancel
+	m.replication.done = done
+	go m.pushDatabags(ctx, client, pushes, done)
+	return nil
+}
+
+// pushDatabags pushes the given databags to the devices, and records which
+// versions the devices acknowledged and which pushes failed, unless pushing
+// was stopped meanwhile.
+func (m *ClusterManager) pushDatabags(ctx context.Context, client replication.Client, pushes []pendingPush, done chan struct{}) {
+	defer close(done)
+
+	acks := make([]*replication.Ack, len(pushes))
+	errs := make([]error, len(pushes))
+	for i, push := range pushes {
+		if ctx.Err() != nil {
+			return
+		}
+		acks[i], errs[i] = pushToDevice(ctx, client, push)
+	}
+
+	m.replicationMu.Lock()
+	defer m.replicationMu.Unlock()
+	if m.replication.done != done {
+		// stopped meanwhile
+		return
+	}
+	m.replication.cancel()
+	m.replication.cancel = nil
+	m.replication.done = nil
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	// look for versions committed while pushing
+	m.state.EnsureBefore(0)
+
+	entries, err := replicatedDatabags(m.state)
+	if err != nil {
+		logger.Noticef("cannot record pushed confdb databags: %v", err)
+		return
+	}
+	now := timeNow()
+	for i, push := range pushes {
+		entry := entries[push.key]
+		if entry == nil {
+			continue
+		}
+		if errs[i] != nil {
+			m.replication.failed[replicationAttempt{push.key, push.device, push.version}] = now
+			if errors.Is(errs[i], replication.ErrRejected) {
+				addReplicationNotice(m.state, push.key, push.device, errs[i])
+			}
+			continue
+		}
+		if entry.Acked == nil {
+			entry.Acked = make(map[int]replication.Version)
+		}
+		if acked, ok := entry.Acked[push.device]; !ok || acks[i].Version.Newer(acked) {
+			entry.Acked[push.device] = acks[i].Version
+		}
+		if !entry.Version.Newer(entry.Acked[push.device]) {
+			delete(entry.LaggingSince, push.device)
+		}
+	}
+	m.state.Set("cluster-confdb-replication", entries)
+}
+
+func pushToDevice(ctx context.Context, client replication.Client, push pendingPush) (*replication.Ack, error) {
+	var err error
+	for _, addr := range push.addresses {
+		pushCtx, cancel := context.WithTimeout(ctx, replicationPushTimeout)
+		var ack replication.Ack
+		ack, err = replicationPush(pushCtx, client, addr, push.device, push.update)
+		cancel()
+		if err == nil {
+			return &ack, nil
+		}
+		logger.Debugf("cannot push confdb %s version %s to device %d at %s: %v", push.key, push.version, push.device, addr, err)
+		if errors.Is(err, replication.ErrRejected) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = errors.New("no known address")
+	}
+	return nil, err
+}
+
+// pendingPushesLocked returns the databags to push to the other devices of
+// the cluster, and whether replication is enabled at all. It notifies about
+// the devices lagging behind for too long.
+func (m *ClusterManager) pendingPushesLocked(st *state.State) ([]pendingPush, bool, error) {
+	keys, err := replicatedSchemas(config.NewTransaction(st))
+	if err != nil || len(keys) == 0 {
+		return nil, false, err
+	}
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return nil, false, err
+	}
+	deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial())
+	if !ok {
+		return nil, false, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
+	}
+	entries, err := replicatedDatabags(st)
+	if err != nil {
+		return nil, false, err
+	}
+	if m.replication.failed == nil {
+		m.replication.failed = make(map[replicationAttempt]time.Time)
+	}
+
+	now := timeNow()
+	var pushes []pendingPush
+	for _, key := range keys {
+		account, schemaName := splitSchemaKey(key)
+		data, err := confdbstate.DatabagData(st, account, schemaName)
+		if err != nil {
+			logger.Noticef("cannot read confdb %s databag to replicate: %v", key, err)
+			continue
+		}
+		entry := replicatedDatabagFor(entries, key, cluster.ClusterID())
+		if entry.Version.IsZero() {
+			if string(data) == "{}" {
+				// nothing to replicate until some data is committed
+				continue
+			}
+			// the data was committed before it was replicated
+			entry.Version = replication.Version{Counter: 1, Device: deviceID}
+		}
+
+		var update *replication.Update
+		for _, dev := range cluster.Devices() {
+			if dev.ID == deviceID {
+				continue
+			}
+			if !entry.Version.Newer(entry.Acked[dev.ID]) {
+				delete(entry.LaggingSince, dev.ID)
+				continue
+			}
+
+			since, ok := entry.LaggingSince[dev.ID]
+			if !ok {
+				since = now
+				entry.LaggingSince[dev.ID] = now
+			}
+			if now.Sub(since) >= replicationMaxLag {
+				addReplicationNotice(st, key, dev.ID, fmt.Errorf("device has not received version %s since %s", entry.Version, since.Format(time.RFC3339)))
+			}
+
+			failedAt, ok := m.replication.failed[replicationAttempt{key, dev.ID, entry.Version}]
+			if ok && now.Sub(failedAt) < replicationRetryInterval {
+				// try again the device once it may receive the update
+				st.EnsureBefore(replicationRetryInterval - now.Sub(failedAt))
+				continue
+			}
+
+			if update == nil {
+				update = &replication.Update{
+					ClusterID: cluster.ClusterID(),
+					Account:   account,
+					Schema:    schemaName,
+					Version:   entry.Version,
+					Data:      data,
+				}
+			}
+			pushes = append(pushes, pendingPush{
+				key:       key,
+				device:    dev.ID,
+				addresses: memberAddresses(dev),
+				version:   entry.Version,
+				update:    update,
+			})
+		}
+	}
+	st.Set("cluster-confdb-replication", entries)
+	return pushes, true, nil
+}
+
+// stopReplicationLocked forgets about failed pushes and cancels pushing
+// databags, returning a channel closed once pushing is over, if it was going
+// on. It must be called with replicationMu held.
+func (m *ClusterManager) stopReplicationLocked() (done <-chan struct{}) {
+	if m.replication.cancel != nil {
+		m.replication.cancel()
+	}
+	done = m.replication.done
+	m.replication = replicationPeers{}
+	return done
+}
+
+func addReplicationNotice(st *state.State, key string, device int, err error) {
+	logger.Debugf("cannot replicate confdb %s with device %d: %v", key, device, err)
+	_, nerr := st.AddNotice(nil, state.ConfdbReplicationNotice, key, &state.AddNoticeOptions{
+		Data: map[string]string{
+			"device":  strconv.Itoa(device),
+			"message": err.Error(),
+		},
+		RepeatAfter: replicationNoticeRepeatAfter,
+	})
+	if nerr != nil {
+		logger.Noticef("cannot add notice about replicating confdb %s: %v", key, nerr)
+	}
+}
+
+// replicationReceiver applies the databags received from the other devices
+// of the cluster.
+type replicationReceiver struct {
+	st *state.State
+}
+
+func (r *replicationReceiver) Receive(from int, u *replication.Update) (replication.Ack, error) {
+	r.st.Lock()
+	defer r.st.Unlock()
+
+	cluster, err := CurrentCluster(r.st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return replication.Ack{}, fmt.Errorf("%w: not in a cluster", replication.ErrNotAuthorized)
+		}
+		return replication.Ack{}, err
+	}
+	if u.ClusterID != cluster.ClusterID() {
+		return replication.Ack{}, fmt.Errorf("%w: update is for cluster %q", replication.ErrNotAuthorized, u.ClusterID)
+	}
+
+	key := u.Account + "/" + u.Schema
+	keys, err := replicatedSchemas(config.NewTransaction(r.st))
+	if err != nil {
+		return replication.Ack{}, err
+	}
+	if !isReplicated(keys, key) {
+		return replication.Ack{}, fmt.Errorf("confdb %s is not replicated", key)
+	}
+
+	entries, err := replicatedDatabags(r.st)
+	if err != nil {
+		return replication.Ack{}, err
+	}
+	entry := replicatedDatabagFor(entries, key, cluster.ClusterID())
+	// the sending device holds at least this version
+	if acked, ok := entry.Acked[from]; !ok || u.Version.Newer(acked) {
+		entry.Acked[from] = u.Version
+	}
+	if u.Version.Newer(entry.Version) {
+		if err := confdbstate.WriteReplicatedDatabag(r.st, u.Account, u.Schema, u.Data); err != nil {
+			if errors.Is(err, confdbstate.ErrDatabagBusy) {
+				return replication.Ack{}, replication.ErrBusy
+			}
+			addReplicationNotice(r.st, key, from, err)
+			return replication.Ack{}, err
+		}
+		entry.Version = u.Version
+		// the version must now be pushed to the other devices
+		r.st.EnsureBefore(0)
+	}
+	if !entry.Version.Newer(entry.Acked[from]) {
+		delete(entry.LaggingSince, from)
+	}
+	r.st.Set("cluster-confdb-replication", entries)
+
+	return replication.Ack{Version: entry.Version}, nil
+}
+
+func isReplicated(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func clusterDeviceByDeviceID(cluster *asserts.Cluster, id asserts.DeviceID) (int, bool) {
+	for _, dev := range cluster.Devices() {
+		if dev.DeviceID == id {
+			return dev.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,566 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/cluster/replication"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type pushed struct {
+	addr   string
+	device int
+	update *replication.Update
+}
+
+type confdbReplicationSuite struct {
+	testutil.BaseTest
+
+	st    *state.State
+	stack *assertstest.StoreStack
+	mgr   *clusterstate.ClusterManager
+
+	servers []*fakeMemberServer
+	pushes  []pushed
+	// results of pushing to the other devices, by address
+	results map[string]error
+	// versions held by the other devices, by address
+	held map[string]replication.Version
+	now  time.Time
+}
+
+var _ = check.Suite(&confdbReplicationSuite{})
+
+func (s *confdbReplicationSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.st, s.stack = newStateWithStoreStack(c)
+	s.servers = nil
+	s.pushes = nil
+	s.results = make(map[string]error)
+	s.held = make(map[string]replication.Version)
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	s.AddCleanup(mockMemberServers(&s.servers, nil))
+
+	restore := clusterstate.MockReplicationPush(func(ctx context.Context, client replication.Client, addr string, device int, u *replication.Update) (replication.Ack, error) {
+		c.Check(client, check.NotNil)
+		s.pushes = append(s.pushes, pushed{addr: addr, device: device, update: u})
+
+		if err, ok := s.results[addr]; ok && err != nil {
+			return replication.Ack{}, err
+		}
+		if held := s.held[addr]; held.Newer(u.Version) {
+			return replication.Ack{Version: held}, nil
+		}
+		return replication.Ack{Version: u.Version}, nil
+	})
+	s.AddCleanup(restore)
+
+	restore = clusterstate.MockTimeNow(func() time.Time { return s.now })
+	s.AddCleanup(restore)
+
+	s.AddCleanup(testutil.Backup(&confdbstate.AssertstateConfdbSchema))
+	confdbstate.AssertstateConfdbSchema = assertstate.ConfdbSchema
+
+	s.mgr = clusterstate.Manager(s.st)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	schema, err := s.stack.Sign(asserts.ConfdbSchemaType, map[string]any{
+		"authority-id": "canonical",
+		"account-id":   "canonical",
+		"name":         "network",
+		"views": map[string]any{
+			"setup-wifi": map[string]any{
+				"rules": []any{
+					map[string]any{"request": "ssid", "storage": "wifi.ssid"},
+				},
+			},
+		},
+		"timestamp": "2030-11-06T09:16:26Z",
+	}, []byte(`{
+  "storage": {
+    "schema": {
+      "wifi": {
+        "schema": {
+          "ssid": "string"
+        }
+      }
+    }
+  }
+}`), "")
+	c.Assert(err, check.IsNil)
+	c.Assert(assertstate.Add(s.st, schema), check.IsNil)
+}
+
+// setUpCluster sets up a cluster of three devices, with this device being
+// the first one, and replicates the databag of the canonical/network
+// confdb-schema.
+func (s *confdbReplicationSuite) setUpCluster(c *check.C) {
+	bundle, _ := makeClusterBundle(c, s.stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10"},
+		},
+		{
+			"id":        "2",
+			"device":    "serial-2.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.11", "10.0.0.11"},
+		},
+		{
+			"id":        "3",
+			"device":    "serial-3.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.12"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1", "2", "3"},
+	}})
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	key, _ := assertstest.GenerateKey(752)
+	serial := makeSerialAssertionForKey(c, s.stack, "serial-1", key)
+	addSerialToState(c, s.st, serial)
+	c.Assert(clusterstate.InitializeNewCluster(s.st, bytes.NewReader(bundle)), check.IsNil)
+
+	s.AddCleanup(clusterstate.MockSignWithDeviceKey(func(st *state.State, data []byte) ([]byte, error) {
+		return asserts.RawSignWithKey(data, key)
+	}))
+
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.confdb.replicate", "canonical/network"), check.IsNil)
+	tr.Commit()
+}
+
+// ensure runs an ensure pass and waits for the databags it pushes.
+func (s *confdbReplicationSuite) ensure(c *check.C) {
+	s.st.Unlock()
+	defer s.st.Lock()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.mgr.WaitReplicationPush()
+}
+
+func (s *confdbReplicationSuite) setDatabag(c *check.C, data string) {
+	var bag map[string]any
+	c.Assert(json.Unmarshal([]byte(data), &bag), check.IsNil)
+	s.st.Set("confdb-databags", map[string]map[string]any{"canonical": {"network": bag}})
+}
+
+func (s *confdbReplicationSuite) checkDatabag(c *check.C, expected string) {
+	data, err := confdbstate.DatabagData(s.st, "canonical", "network")
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Equals, expected)
+}
+
+// commit changes the databag as a committed transaction would.
+func (s *confdbReplicationSuite) commit(c *check.C, data string) {
+	s.setDatabag(c, data)
+	clusterstate.ConfdbCommitted(s.st, "canonical", "network")
+}
+
+func (s *confdbReplicationSuite) replicationState(c *check.C) map[string]any {
+	var entries map[string]any
+	c.Assert(s.st.Get("cluster-confdb-replication", &entries), check.IsNil)
+	return entries
+}
+
+// handlerClient delivers the messages sent to this device by the device with
+// the given ID to the handlers of its member server.
+type handlerClient struct {
+	handlers map[string]assemblestate.MemberHandler
+	from     int
+}
+
+func (h *handlerClient) Do(ctx context.Context, addr string, device int, method, kind string, body []byte) (*http.Response, error) {
+	handler := h.handlers[kind]
+	if handler == nil {
+		return nil, fmt.Errorf("no handler for %q", kind)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, "/cluster/"+kind, bytes.NewReader(body)), h.from)
+	return rec.Result(), nil
+}
+
+// receive pushes the update from the given device to this device.
+func (s *confdbReplicationSuite) receive(c *check.C, from int, u replication.Update) (replication.Ack, error) {
+	c.Assert(s.servers, check.HasLen, 1)
+	client := &handlerClient{handlers: s.servers[0].handlers, from: from}
+
+	s.st.Unlock()
+	defer s.st.Lock()
+	return replication.Push(context.Background(), client, "192.168.0.10:7417", 1, &u)
+}
+
+func replicationNotices(st *state.State) []map[string]any {
+	var notices []map[string]any
+	for _, n := range st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.ConfdbReplicationNotice}}) {
+		buf, _ := json.Marshal(n)
+		var m map[string]any
+		json.Unmarshal(buf, &m)
+		notices = append(notices, m)
+	}
+	return notices
+}
+
+func (s *confdbReplicationSuite) TestDisabledByDefault(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.confdb.replicate", ""), check.IsNil)
+	tr.Commit()
+
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+	c.Check(s.servers, check.HasLen, 0)
+	c.Check(s.pushes, check.HasLen, 0)
+}
+
+func (s *confdbReplicationSuite) TestPushCommitted(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	// nothing to push yet, but updates are received from the other devices
+	s.ensure(c)
+	c.Assert(s.servers, check.HasLen, 1)
+	c.Check(s.servers[0].addr, check.Equals, ":7417")
+	c.Check(s.servers[0].handlers[replication.UpdatesKind], check.NotNil)
+	c.Check(s.pushes, check.HasLen, 0)
+
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+
+	update := &replication.Update{
+		ClusterID: "cluster-id",
+		Account:   "canonical",
+		Schema:    "network",
+		Version:   replication.Version{Counter: 1, Device: 1},
+		Data:      []byte(`{"wifi":{"ssid":"foo"}}`),
+	}
+	c.Check(s.pushes, check.DeepEquals, []pushed{
+		{addr: "192.168.0.11:7417", device: 2, update: update},
+		{addr: "192.168.0.12:7417", device: 3, update: update},
+	})
+	c.Check(s.replicationState(c), check.DeepEquals, map[string]any{
+		"canonical/network": map[string]any{
+			"cluster-id": "cluster-id",
+			"version":    map[string]any{"counter": 1.0, "device": 1.0},
+			"acked": map[string]any{
+				"2": map[string]any{"counter": 1.0, "device": 1.0},
+				"3": map[string]any{"counter": 1.0, "device": 1.0},
+			},
+		},
+	})
+
+	// the other devices are up to date
+	s.pushes = nil
+	s.ensure(c)
+	c.Check(s.pushes, check.HasLen, 0)
+
+	s.commit(c, `{"wifi":{"ssid":"bar"}}`)
+	s.ensure(c)
+	c.Assert(s.pushes, check.HasLen, 2)
+	c.Check(s.pushes[0].update.Version, check.Equals, replication.Version{Counter: 2, Device: 1})
+	c.Check(string(s.pushes[0].update.Data), check.Equals, `{"wifi":{"ssid":"bar"}}`)
+	c.Check(replicationNotices(s.st), check.HasLen, 0)
+}
+
+func (s *confdbReplicationSuite) TestPushInBackground(c *check.C) {
+	s.setUpCluster(c)
+
+	pushing := make(chan struct{})
+	restore := clusterstate.MockReplicationPush(func(ctx context.Context, client replication.Client, addr string, device int, u *replication.Update) (replication.Ack, error) {
+		close(pushing)
+		<-ctx.Done()
+		return replication.Ack{}, ctx.Err()
+	})
+	defer restore()
+
+	s.st.Lock()
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.st.Unlock()
+
+	// the ensure pass does not wait for the other devices
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	<-pushing
+
+	// nor does the next one while pushing is going on
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	// stopping the manager cancels pushing, nothing is recorded
+	s.mgr.Stop()
+	s.st.Lock()
+	defer s.st.Unlock()
+	c.Check(s.replicationState(c), check.DeepEquals, map[string]any{
+		"canonical/network": map[string]any{
+			"cluster-id": "cluster-id",
+			"version":    map[string]any{"counter": 1.0, "device": 1.0},
+			"lagging-since": map[string]any{
+				"2": "2026-10-01T12:00:00Z",
+				"3": "2026-10-01T12:00:00Z",
+			},
+		},
+	})
+}
+
+func (s *confdbReplicationSuite) TestPushDataCommittedBeforeReplicating(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.setDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+	c.Assert(s.pushes, check.HasLen, 2)
+	c.Check(s.pushes[0].update.Version, check.Equals, replication.Version{Counter: 1, Device: 1})
+}
+
+func (s *confdbReplicationSuite) TestPushNewerVersionHeld(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.held["192.168.0.12:7417"] = replication.Version{Counter: 4, Device: 3}
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+	c.Assert(s.pushes, check.HasLen, 2)
+
+	// the third device will push its newer version
+	entries := s.replicationState(c)
+	c.Check(entries["canonical/network"].(map[string]any)["acked"], check.DeepEquals, map[string]any{
+		"2": map[string]any{"counter": 1.0, "device": 1.0},
+		"3": map[string]any{"counter": 4.0, "device": 3.0},
+	})
+	s.pushes = nil
+	s.ensure(c)
+	c.Check(s.pushes, check.HasLen, 0)
+}
+
+func (s *confdbReplicationSuite) TestPushRetryAndLag(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.results["192.168.0.11:7417"] = errors.New("connection refused")
+	s.results["10.0.0.11:7417"] = replication.ErrBusy
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+	var addrs []string
+	for _, p := range s.pushes {
+		addrs = append(addrs, p.addr)
+	}
+	c.Check(addrs, check.DeepEquals, []string{"192.168.0.11:7417", "10.0.0.11:7417", "192.168.0.12:7417"})
+
+	// the device is not pushed to again right away
+	s.pushes = nil
+	s.now = s.now.Add(10 * time.Second)
+	s.ensure(c)
+	c.Check(s.pushes, check.HasLen, 0)
+
+	s.now = s.now.Add(30 * time.Second)
+	s.ensure(c)
+	c.Check(s.pushes, check.HasLen, 2)
+	c.Check(replicationNotices(s.st), check.HasLen, 0)
+
+	// until the device lags behind for too long
+	s.now = s.now.Add(10 * time.Minute)
+	s.ensure(c)
+	notices := replicationNotices(s.st)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0]["key"], check.Equals, "canonical/network")
+	c.Check(notices[0]["last-data"], check.DeepEquals, map[string]any{
+		"device":  "2",
+		"message": "device has not received version 1/1 since 2026-10-01T12:00:00Z",
+	})
+
+	// the device catches up
+	delete(s.results, "10.0.0.11:7417")
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	entries := s.replicationState(c)
+	c.Check(entries["canonical/network"].(map[string]any)["lagging-since"], check.IsNil)
+}
+
+func (s *confdbReplicationSuite) TestPushRejected(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.results["192.168.0.11:7417"] = fmt.Errorf("%w: cannot accept element in \"wifi.ssid\"", replication.ErrRejected)
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+
+	// the other address isn't tried
+	c.Check(s.pushes, check.HasLen, 2)
+	notices := replicationNotices(s.st)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0]["key"], check.Equals, "canonical/network")
+	c.Check(notices[0]["last-data"], check.DeepEquals, map[string]any{
+		"device":  "2",
+		"message": `device rejected update: cannot accept element in "wifi.ssid"`,
+	})
+}
+
+func (s *confdbReplicationSuite) TestReceive(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+	s.pushes = nil
+
+	update := replication.Update{
+		ClusterID: "cluster-id",
+		Account:   "canonical",
+		Schema:    "network",
+		Version:   replication.Version{Counter: 1, Device: 3},
+		Data:      []byte(`{"wifi":{"ssid":"bar"}}`),
+	}
+	// the concurrent change of the device with the higher ID wins
+	ack, err := s.receive(c, 3, update)
+	c.Assert(err, check.IsNil)
+	c.Check(ack.Version, check.Equals, replication.Version{Counter: 1, Device: 3})
+	s.checkDatabag(c, `{"wifi":{"ssid":"bar"}}`)
+
+	notices := s.st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.ConfdbChangeNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key(), check.Equals, "canonical/network/setup-wifi")
+
+	// and is relayed to the device which doesn't hold it yet
+	s.ensure(c)
+	c.Assert(s.pushes, check.HasLen, 1)
+	c.Check(s.pushes[0].addr, check.Equals, "192.168.0.11:7417")
+	c.Check(s.pushes[0].update.Version, check.Equals, update.Version)
+
+	// while the concurrent change of the device with the lower ID loses
+	update.Version = replication.Version{Counter: 1, Device: 2}
+	update.Data = []byte(`{"wifi":{"ssid":"baz"}}`)
+	ack, err = s.receive(c, 2, update)
+	c.Assert(err, check.IsNil)
+	c.Check(ack.Version, check.Equals, replication.Version{Counter: 1, Device: 3})
+	s.checkDatabag(c, `{"wifi":{"ssid":"bar"}}`)
+
+	// local changes supersede the ones received
+	s.commit(c, `{"wifi":{"ssid":"local"}}`)
+	entries := s.replicationState(c)
+	c.Check(entries["canonical/network"].(map[string]any)["version"], check.DeepEquals, map[string]any{"counter": 2.0, "device": 1.0})
+}
+
+func (s *confdbReplicationSuite) TestReceiveErrors(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.commit(c, `{"wifi":{"ssid":"foo"}}`)
+	s.ensure(c)
+
+	update := replication.Update{
+		ClusterID: "cluster-id",
+		Account:   "canonical",
+		Schema:    "network",
+		Version:   replication.Version{Counter: 2, Device: 2},
+		Data:      []byte(`{"wifi":{"ssid":"bar"}}`),
+	}
+
+	other := update
+	other.ClusterID = "other-id"
+	_, err := s.receive(c, 2, other)
+	c.Check(err, check.ErrorMatches, `device rejected update: device is not authorized: update is for cluster "other-id"`)
+	c.Check(errors.Is(err, replication.ErrRejected), check.Equals, true)
+
+	other = update
+	other.Schema = "other"
+	_, err = s.receive(c, 2, other)
+	c.Check(err, check.ErrorMatches, `device rejected update: confdb canonical/other is not replicated`)
+
+	s.st.Set("confdb-ongoing-txs", map[string]any{"canonical/network": map[string]any{"write-tx-id": "1"}})
+	_, err = s.receive(c, 2, update)
+	c.Check(err, check.Equals, replication.ErrBusy)
+	s.st.Set("confdb-ongoing-txs", nil)
+
+	c.Check(replicationNotices(s.st), check.HasLen, 0)
+
+	other = update
+	other.Data = []byte(`{"wifi":{"ssid":1}}`)
+	_, err = s.receive(c, 2, other)
+	c.Check(err, check.ErrorMatches, `device rejected update: cannot replicate confdb canonical/network: .*`)
+	notices := replicationNotices(s.st)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0]["key"], check.Equals, "canonical/network")
+	c.Check(notices[0]["last-data"].(map[string]any)["device"], check.Equals, "2")
+
+	s.checkDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+}
+
+func (s *confdbReplicationSuite) TestStopReceiving(c *check.C) {
+	s.setUpCluster(c)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+	c.Assert(s.servers, check.HasLen, 1)
+
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.confdb.replicate", ""), check.IsNil)
+	tr.Commit()
+	s.ensure(c)
+	c.Check(s.servers[0].stopped, check.Equals, 1)
+
+	tr = config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.confdb.replicate", "canonical/network"), check.IsNil)
+	tr.Commit()
+	s.ensure(c)
+	c.Assert(s.servers, check.HasLen, 2)
+
+	s.mgr.Stop()
+	c.Check(s.servers[1].stopped, check.Equals, 1)
+}
@@ -21,7 +21,12 @@ package clusterstate
 
 import (
 	"context"
+	"crypto/tls"
+	"time"
 
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/cluster/replication"
+	"github.com/snapcore/snapd/cluster/rollout"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -57,3 +62,51 @@ func MockStoreInstallGoal(f func(...snapstate.StoreSnap) snapstate.InstallGoal)
 	storeInstallGoal = f
 	return restore
 }
+
+type MemberServer = memberServer
+
+func MockMemberServerStart(f func(addr string, cert tls.Certificate, self assemblestate.MemberIdentity, auth assemblestate.MemberAuthenticator, handlers map[string]assemblestate.MemberHandler) (MemberServer, error)) func() {
+	return testutil.Mock(&memberServerStart, f)
+}
+
+var PeerDevices = peerDevices
+
+func MockRolloutFetch(f func(ctx context.Context, client rollout.Client, addr string, device int) ([]rollout.Report, error)) func() {
+	return testutil.Mock(&rolloutFetch, f)
+}
+
+// WaitRolloutFetch waits for fetching the reports of the canary devices to be
+// over, if it is going on.
+func (m *ClusterManager) WaitRolloutFetch() {
+	m.rolloutMu.Lock()
+	done := m.rollout.done
+	m.rolloutMu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// WaitReplicationPush waits for pushing databags to be over, if it is going
+// on.
+func (m *ClusterManager) WaitReplicationPush() {
+	m.replicationMu.Lock()
+	done := m.replication.done
+	m.replicationMu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func MockTimeNow(f func() time.Time) func() {
+	return testutil.Mock(&timeNow, f)
+}
+
+func MockReplicationPush(f func(ctx context.Context, client replication.Client, addr string, device int, u *replication.Update) (replication.Ack, error)) func() {
+	return testutil.Mock(&replicationPush, f)
+}
+
+func MockSignWithDeviceKey(f func(st *state.State, data []byte) ([]byte, error)) func() {
+	return testutil.Mock(&signWithDeviceKey, f)
+}
+
+var ConfdbCommitted = confdbCommitted
@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/cluster/peerdist"
+	"github.com/snapcore/snapd/cluster/replication"
+	"github.com/snapcore/snapd/cluster/rollout"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+)
+
+func init() {
+	swfeats.RegisterEnsure("ClusterManager", "ensureMembers")
+}
+
+var memberPort = assemblestate.DefaultMemberPort
+
+type memberServer interface {
+	Stop() error
+}
+
+var memberServerStart = func(addr string, cert tls.Certificate, self assemblestate.MemberIdentity, auth assemblestate.MemberAuthenticator, handlers map[string]assemblestate.MemberHandler) (memberServer, error) {
+	return assemblestate.StartMemberServer(addr, cert, self, auth, handlers)
+}
+
+var newMemberCertificate = assemblestate.NewMemberCertificate
+
+var signWithDeviceKey = devicestate.SignWithDeviceKey
+
+// members tracks the transport used to exchange messages with the other
+// devices of the cluster.
+type members struct {
+	// cert is the TLS certificate of this device, generated the first time
+	// the transport is needed.
+	cert   *tls.Certificate
+	serial string
+	server memberServer
+	client *assemblestate.MemberClient
+}
+
+// membersNeeded returns whether some exchange of messages with the other
+// devices of the cluster is enabled.
+func membersNeeded(tr *config.Transaction) (bool, error) {
+	enabled, err := peerDistributionEnabled(tr)
+	if err != nil || enabled {
+		return enabled, err
+	}
+	canaries, err := rolloutCanaryCount(tr)
+	if err != nil || canaries > 0 {
+		return canaries > 0, err
+	}
+	keys, err := replicatedSchemas(tr)
+	if err != nil {
+		return false, err
+	}
+	return len(keys) > 0, nil
+}
+
+// ensureMembers serves the messages of the other devices of the cluster, and
+// sets up a client for sending them messages, while this device is part of a
+// cluster and exchanging messages is needed.
+func (m *ClusterManager) ensureMembers() error {
+	logger.Trace("ensure", "manager", "ClusterManager", "func", "ensureMembers")
+	enabled, err := clusteringEnabled(m.state)
+	if err != nil {
+		return err
+	}
+
+	m.membersMu.Lock()
+	defer m.membersMu.Unlock()
+
+	if m.members.cert == nil && enabled {
+		cert, err := newMemberCertificate()
+		if err != nil {
+			return err
+		}
+		m.members.cert = &cert
+	}
+
+	var serial *asserts.Serial
+	if enabled {
+		m.state.Lock()
+		serial, err = localMember(m.state)
+		m.state.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if serial == nil {
+		m.stopMembersLocked()
+		return nil
+	}
+	// the identity of this device changes with its serial
+	if m.members.server != nil && m.members.serial == serial.Serial() {
+		return nil
+	}
+	m.stopMembersLocked()
+
+	cert := *m.members.cert
+	m.state.Lock()
+	self, err := memberIdentity(m.state, serial, cert)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+	auth := &memberAuthenticator{st: m.state}
+	server, err := memberServerStart(fmt.Sprintf(":%d", memberPort), cert, self, auth, m.memberHandlers())
+	if err != nil {
+		return err
+	}
+	m.members.serial = serial.Serial()
+	m.members.server = server
+	m.members.client = assemblestate.NewMemberClient(cert, self, auth)
+	logger.Noticef("Exchanging messages with cluster devices on port %d", memberPort)
+	return nil
+}
+
+// localMember returns the serial assertion of this device if it is part of a
+// cluster and needs to exchange messages with the other devices, nil
+// otherwise.
+func localMember(st *state.State) (*asserts.Serial, error) {
+	needed, err := membersNeeded(config.NewTransaction(st))
+	if err != nil || !needed {
+		return nil, err
+	}
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := clusterDeviceIDBySerial(cluster, serial.Serial()); !ok {
+		return nil, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
+	}
+	return serial, nil
+}
+
+// memberIdentity returns the identity of this device when using the given
+// certificate.
+func memberIdentity(st *state.State, serial *asserts.Serial, cert tls.Certificate) (assemblestate.MemberIdentity, error) {
+	bundle, err := assemblestate.SerialBundle(serial, assertstate.DB(st))
+	if err != nil {
+		return assemblestate.MemberIdentity{}, err
+	}
+	return assemblestate.NewMemberIdentity(cert, bundle, func(data []byte) ([]byte, error) {
+		return signWithDeviceKey(st, data)
+	})
+}
+
+func (m *ClusterManager) memberHandlers() map[string]assemblestate.MemberHandler {
+	return map[string]assemblestate.MemberHandler{
+		peerdist.BlobsKind:      peerdist.NewHandler(&cachedBlobs{m: m}),
+		rollout.ReportsKind:     rollout.NewHandler(&reportSource{st: m.state}),
+		replication.UpdatesKind: replication.NewHandler(&replicationReceiver{st: m.state}),
+	}
+}
+
+// memberClient returns the client for sending messages to the other devices
+// of the cluster, or nil if exchanging messages is not set up.
+func (m *ClusterManager) memberClient() *assemblestate.MemberClient {
+	m.membersMu.Lock()
+	defer m.membersMu.Unlock()
+	return m.members.client
+}
+
+func (m *ClusterManager) stopMembersLocked() {
+	if m.members.server != nil {
+		if err := m.members.server.Stop(); err != nil {
+			logger.Noticef("cannot stop exchanging messages with cluster devices: %v", err)
+		}
+	}
+	// the certificate is kept, so that peers see the same one
+	m.members = members{cert: m.members.cert}
+}
+
+// memberAddresses returns the "host:port" addresses at which the device
+// serves the messages of the other devices of the cluster.
+func memberAddresses(dev asserts.ClusterDevice) []string {
+	addrs := make([]string, 0, len(dev.Addresses))
+	for _, addr := range dev.Addresses {
+		addrs = append(addrs, net.JoinHostPort(addr, strconv.Itoa(memberPort)))
+	}
+	return addrs
+}
+
+// memberAuthenticator authenticates the devices listed in the current
+// cluster assertion.
+type memberAuthenticator struct {
+	st *state.State
+}
+
+func (a *memberAuthenticator) AuthenticateMember(id assemblestate.MemberIdentity, fp assemblestate.Fingerprint) (int, error) {
+	a.st.Lock()
+	defer a.st.Unlock()
+
+	cluster, err := CurrentCluster(a.st)
+	if err != nil {
+		return 0, err
+	}
+	serial, err := assemblestate.VerifyMemberIdentity(id, fp, assertstate.DB(a.st))
+	if err != nil {
+		return 0, err
+	}
+	device, ok := clusterDeviceByDeviceID(cluster, serial.DeviceID())
+	if !ok {
+		return 0, fmt.Errorf("device %s is not in the cluster", serial.DeviceID())
+	}
+	return device, nil
+}
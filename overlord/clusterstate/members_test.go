@@ -0,0 +1,282 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"sort"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fakeMemberServer struct {
+	addr     string
+	cert     tls.Certificate
+	self     assemblestate.MemberIdentity
+	auth     assemblestate.MemberAuthenticator
+	handlers map[string]assemblestate.MemberHandler
+	stopped  int
+}
+
+func (s *fakeMemberServer) Stop() error {
+	s.stopped++
+	return nil
+}
+
+// mockMemberServers records the member servers started by the manager.
+func mockMemberServers(servers *[]*fakeMemberServer, err error) (restore func()) {
+	return clusterstate.MockMemberServerStart(func(addr string, cert tls.Certificate, self assemblestate.MemberIdentity, auth assemblestate.MemberAuthenticator, handlers map[string]assemblestate.MemberHandler) (clusterstate.MemberServer, error) {
+		if err != nil {
+			return nil, err
+		}
+		server := &fakeMemberServer{
+			addr:     addr,
+			cert:     cert,
+			self:     self,
+			auth:     auth,
+			handlers: handlers,
+		}
+		*servers = append(*servers, server)
+		return server, nil
+	})
+}
+
+// setUpMembersCluster sets up a cluster of three devices, with this device
+// being the one with the given serial. It returns the device keys of the
+// devices, by serial.
+func setUpMembersCluster(c *check.C, st *state.State, stack *assertstest.StoreStack, serial string) map[string]asserts.PrivateKey {
+	bundle, _ := makeClusterBundle(c, stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10", "10.0.0.10"},
+		},
+		{
+			"id":        "2",
+			"device":    "serial-2.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.11"},
+		},
+		{
+			"id":        "3",
+			"device":    "serial-3.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"fd00::12"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1", "2", "3"},
+	}})
+
+	keys := make(map[string]asserts.PrivateKey)
+	for _, name := range []string{"serial-1", "serial-2", "serial-3", "serial-4"} {
+		keys[name], _ = assertstest.GenerateKey(752)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	addSerialToState(c, st, makeSerialAssertionForKey(c, stack, serial, keys[serial]))
+	c.Assert(clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle)), check.IsNil)
+	return keys
+}
+
+type membersSuite struct {
+	testutil.BaseTest
+
+	st    *state.State
+	stack *assertstest.StoreStack
+	keys  map[string]asserts.PrivateKey
+	mgr   *clusterstate.ClusterManager
+
+	servers []*fakeMemberServer
+}
+
+var _ = check.Suite(&membersSuite{})
+
+func (s *membersSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.st, s.stack = newStateWithStoreStack(c)
+	s.servers = nil
+	s.AddCleanup(mockMemberServers(&s.servers, nil))
+
+	s.keys = setUpMembersCluster(c, s.st, s.stack, "serial-1")
+	s.AddCleanup(clusterstate.MockSignWithDeviceKey(func(st *state.State, data []byte) ([]byte, error) {
+		return asserts.RawSignWithKey(data, s.keys["serial-1"])
+	}))
+
+	s.st.Lock()
+	snapstate.ReplaceStore(s.st, &peerStore{})
+	s.st.Unlock()
+
+	s.mgr = clusterstate.Manager(s.st)
+}
+
+func (s *membersSuite) setPeerDistribution(c *check.C, enabled bool) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "store.peer-distribution", enabled), check.IsNil)
+	tr.Commit()
+}
+
+// identity returns the identity of the device with the given serial, for a
+// new certificate.
+func (s *membersSuite) identity(c *check.C, serial string) (assemblestate.MemberIdentity, assemblestate.Fingerprint) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	a := makeSerialAssertionForKey(c, s.stack, serial, s.keys[serial])
+	bundle, err := assemblestate.SerialBundle(a, s.stack.Database)
+	c.Assert(err, check.IsNil)
+	cert, err := assemblestate.NewMemberCertificate()
+	c.Assert(err, check.IsNil)
+	id, err := assemblestate.NewMemberIdentity(cert, bundle, func(data []byte) ([]byte, error) {
+		return asserts.RawSignWithKey(data, s.keys[serial])
+	})
+	c.Assert(err, check.IsNil)
+	return id, assemblestate.CalculateFP(cert.Certificate[0])
+}
+
+func (s *membersSuite) TestEnsureNotNeeded(c *check.C) {
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 0)
+}
+
+func (s *membersSuite) TestEnsureStartStop(c *check.C) {
+	s.setPeerDistribution(c, true)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	c.Assert(s.servers, check.HasLen, 1)
+	server := s.servers[0]
+	c.Check(server.addr, check.Equals, ":7417")
+	var kinds []string
+	for kind := range server.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	c.Check(kinds, check.DeepEquals, []string{"blobs/", "confdb/updates", "rollout/reports"})
+
+	// the identity of this device proves the use of the certificate
+	s.st.Lock()
+	verified, err := assemblestate.VerifyMemberIdentity(server.self, assemblestate.CalculateFP(server.cert.Certificate[0]), assertstate.DB(s.st))
+	s.st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(verified.Serial(), check.Equals, "serial-1")
+
+	// nothing changes while the device stays in the cluster
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 1)
+	c.Check(server.stopped, check.Equals, 0)
+
+	s.setPeerDistribution(c, false)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(server.stopped, check.Equals, 1)
+
+	// the same certificate is used when starting again
+	s.setPeerDistribution(c, true)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Assert(s.servers, check.HasLen, 2)
+	c.Check(s.servers[1].cert, check.DeepEquals, server.cert)
+
+	s.mgr.Stop()
+	c.Check(s.servers[1].stopped, check.Equals, 1)
+}
+
+func (s *membersSuite) TestEnsureNeededForRollout(c *check.C) {
+	s.st.Lock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.rollout.canaries", 1), check.IsNil)
+	tr.Commit()
+	s.st.Unlock()
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 1)
+}
+
+func (s *membersSuite) TestEnsureNeededForReplication(c *check.C) {
+	s.st.Lock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.confdb.replicate", "canonical/network"), check.IsNil)
+	tr.Commit()
+	s.st.Unlock()
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 1)
+}
+
+func (s *membersSuite) TestEnsureClusteringDisabled(c *check.C) {
+	s.setPeerDistribution(c, true)
+
+	s.st.Lock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "experimental.clustering", false), check.IsNil)
+	tr.Commit()
+	s.st.Unlock()
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 0)
+}
+
+func (s *membersSuite) TestEnsureStartError(c *check.C) {
+	s.AddCleanup(mockMemberServers(&s.servers, errors.New("boom")))
+	s.setPeerDistribution(c, true)
+
+	// errors do not prevent the rest of the manager from running
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 0)
+
+	s.AddCleanup(mockMemberServers(&s.servers, nil))
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 1)
+}
+
+func (s *membersSuite) TestAuthenticateMember(c *check.C) {
+	s.setPeerDistribution(c, true)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	c.Assert(s.servers, check.HasLen, 1)
+	auth := s.servers[0].auth
+
+	id, fp := s.identity(c, "serial-3")
+	device, err := auth.AuthenticateMember(id, fp)
+	c.Assert(err, check.IsNil)
+	c.Check(device, check.Equals, 3)
+
+	// the identity must prove the use of the certificate
+	_, otherFP := s.identity(c, "serial-3")
+	_, err = auth.AuthenticateMember(id, otherFP)
+	c.Check(err, check.ErrorMatches, "proof verification failed: .*")
+
+	// and be the one of a device of the cluster
+	id, fp = s.identity(c, "serial-4")
+	_, err = auth.AuthenticateMember(id, fp)
+	c.Check(err, check.ErrorMatches, `device serial-4.ubuntu-core-24-amd64.canonical is not in the cluster`)
+}
@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+
+	"github.com/snapcore/snapd/cluster/peerdist"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/store"
+)
+
+func init() {
+	swfeats.RegisterEnsure("ClusterManager", "ensurePeerDistribution")
+}
+
+// peerStore is the subset of the store which is needed to exchange blobs with
+// peers.
+type peerStore interface {
+	peerdist.BlobSource
+	SetPeerSource(src store.PeerSource)
+}
+
+var (
+	_ peerStore        = (*store.Store)(nil)
+	_ store.PeerSource = (*peerdist.Source)(nil)
+)
+
+// peerDistribution tracks the state of the exchange of blobs with the other
+// devices of the cluster.
+type peerDistribution struct {
+	store  peerStore
+	client peerdist.Client
+	source *peerdist.Source
+}
+
+func peerDistributionEnabled(tr *config.Transaction) (bool, error) {
+	var enabled bool
+	if err := tr.GetMaybe("core", "store.peer-distribution", &enabled); err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// ensurePeerDistribution starts or stops fetching blobs from the other devices
+// of the cluster, according to the core.store.peer-distribution option. Blobs
+// are served to them by the transport set up by ensureMembers.
+func (m *ClusterManager) ensurePeerDistribution() error {
+	logger.Trace("ensure", "manager", "ClusterManager", "func", "ensurePeerDistribution")
+	client := m.memberClient()
+
+	m.state.Lock()
+	var sto peerStore
+	var peers []peerdist.Peer
+	enabled, err := peerDistributionEnabled(config.NewTransaction(m.state))
+	if err != nil {
+		m.state.Unlock()
+		return err
+	}
+	// the client is only set up while this device is part of a cluster
+	enabled = enabled && client != nil
+	if enabled {
+		var ok bool
+		// the store can be replaced, for instance when remodeling
+		sto, ok = snapstate.Store(m.state, nil).(peerStore)
+		if !ok {
+			logger.Debugf("store does not support fetching blobs from peers")
+			enabled = false
+		}
+	}
+	if enabled {
+		peers, err = peerDevices(m.state)
+		if err != nil {
+			m.state.Unlock()
+			return err
+		}
+	}
+	m.state.Unlock()
+
+	m.peersMu.Lock()
+	defer m.peersMu.Unlock()
+
+	if !enabled {
+		m.stopPeerDistributionLocked()
+		return nil
+	}
+	if m.peers.store != sto || m.peers.client != peerdist.Client(client) {
+		m.stopPeerDistributionLocked()
+		source := peerdist.NewSource(client)
+		sto.SetPeerSource(source)
+		m.peers = peerDistribution{store: sto, client: client, source: source}
+		logger.Noticef("Exchanging snaps with cluster devices")
+	}
+	// the devices of the cluster change with the cluster assertion
+	m.peers.source.SetPeers(peers)
+	return nil
+}
+
+// peerDevices returns the other devices of the cluster, which blobs are
+// fetched from.
+func peerDevices(st *state.State) ([]peerdist.Peer, error) {
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	deviceID, err := clusterDeviceID(st, cluster)
+	if err != nil {
+		return nil, err
+	}
+	var peers []peerdist.Peer
+	for _, dev := range cluster.Devices() {
+		if dev.ID == deviceID {
+			continue
+		}
+		peers = append(peers, peerdist.Peer{
+			Device:    dev.ID,
+			Addresses: memberAddresses(dev),
+		})
+	}
+	return peers, nil
+}
+
+func (m *ClusterManager) stopPeerDistributionLocked() {
+	if m.peers.store != nil {
+		m.peers.store.SetPeerSource(nil)
+	}
+	m.peers = peerDistribution{}
+}
+
+// cachedBlobs provides the blobs from the downloads cache of the store to
+// the other devices of the cluster, while peer distribution is enabled.
+type cachedBlobs struct {
+	m *ClusterManager
+}
+
+func (b *cachedBlobs) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	b.m.peersMu.Lock()
+	sto := b.m.peers.store
+	b.m.peersMu.Unlock()
+	if sto == nil {
+		return nil, 0, fs.ErrNotExist
+	}
+	return sto.OpenCachedBlob(sha3_384)
+}
+
+// Stop implements overlord.StateStopper. It stops exchanging blobs, rollout
+// reports and replicated confdb databags with the other devices of the
+// cluster.
+func (m *ClusterManager) Stop() {
+	m.rolloutMu.Lock()
+	fetching := m.stopRolloutLocked()
+	m.rolloutMu.Unlock()
+	if fetching != nil {
+		<-fetching
+	}
+
+	m.replicationMu.Lock()
+	pushing := m.stopReplicationLocked()
+	m.replicationMu.Unlock()
+	if pushing != nil {
+		<-pushing
+	}
+
+	m.membersMu.Lock()
+	m.stopMembersLocked()
+	m.membersMu.Unlock()
+
+	m.peersMu.Lock()
+	m.stopPeerDistributionLocked()
+	m.peersMu.Unlock()
+}
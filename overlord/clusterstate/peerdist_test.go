@@ -0,0 +1,223 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/peerdist"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type peerStore struct {
+	storetest.Store
+
+	peers []store.PeerSource
+	blobs map[string]string
+}
+
+func (s *peerStore) SetPeerSource(src store.PeerSource) {
+	s.peers = append(s.peers, src)
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func (s *peerStore) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	content, ok := s.blobs[sha3_384]
+	if !ok {
+		return nil, 0, fs.ErrNotExist
+	}
+	return nopSeekCloser{strings.NewReader(content)}, int64(len(content)), nil
+}
+
+type peerDistSuite struct {
+	testutil.BaseTest
+
+	st    *state.State
+	stack *assertstest.StoreStack
+	sto   *peerStore
+
+	servers []*fakeMemberServer
+}
+
+var _ = check.Suite(&peerDistSuite{})
+
+var testDigest = strings.Repeat("a", 96)
+
+func (s *peerDistSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.st, s.stack = newStateWithStoreStack(c)
+	s.sto = &peerStore{blobs: map[string]string{testDigest: "blob content"}}
+	s.servers = nil
+	s.AddCleanup(mockMemberServers(&s.servers, nil))
+
+	keys := setUpMembersCluster(c, s.st, s.stack, "serial-2")
+	s.AddCleanup(clusterstate.MockSignWithDeviceKey(func(st *state.State, data []byte) ([]byte, error) {
+		return asserts.RawSignWithKey(data, keys["serial-2"])
+	}))
+
+	s.st.Lock()
+	snapstate.ReplaceStore(s.st, s.sto)
+	s.st.Unlock()
+}
+
+func (s *peerDistSuite) setEnabled(c *check.C, enabled bool) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "store.peer-distribution", enabled), check.IsNil)
+	tr.Commit()
+}
+
+// fetch requests the blob with the given digest from the server, as the
+// device with the given ID.
+func fetch(c *check.C, server *fakeMemberServer, digest string) (int, string) {
+	h := server.handlers[peerdist.BlobsKind]
+	c.Assert(h, check.NotNil)
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/cluster/blobs/"+digest, nil), 1)
+	resp := rec.Result()
+	body, err := io.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+	return resp.StatusCode, string(body)
+}
+
+func (s *peerDistSuite) TestEnsureDisabledByDefault(c *check.C) {
+	mgr := clusterstate.Manager(s.st)
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	c.Check(s.servers, check.HasLen, 0)
+	c.Check(s.sto.peers, check.HasLen, 0)
+}
+
+func (s *peerDistSuite) TestEnsureEnableDisable(c *check.C) {
+	s.setEnabled(c, true)
+	mgr := clusterstate.Manager(s.st)
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	c.Assert(s.servers, check.HasLen, 1)
+	c.Assert(s.sto.peers, check.HasLen, 1)
+	c.Check(s.sto.peers[0], check.FitsTypeOf, &peerdist.Source{})
+
+	// blobs from the cache are served to the other devices
+	status, body := fetch(c, s.servers[0], testDigest)
+	c.Check(status, check.Equals, http.StatusOK)
+	c.Check(body, check.Equals, "blob content")
+	status, _ = fetch(c, s.servers[0], strings.Repeat("b", 96))
+	c.Check(status, check.Equals, http.StatusNotFound)
+
+	// nothing changes while the option is unchanged
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(s.servers, check.HasLen, 1)
+	c.Check(s.sto.peers, check.HasLen, 1)
+	c.Check(s.servers[0].stopped, check.Equals, 0)
+
+	s.setEnabled(c, false)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(s.servers[0].stopped, check.Equals, 1)
+	c.Assert(s.sto.peers, check.HasLen, 2)
+	c.Check(s.sto.peers[1], check.IsNil)
+
+	// blobs are no longer served, even if a request is still in flight
+	status, _ = fetch(c, s.servers[0], testDigest)
+	c.Check(status, check.Equals, http.StatusNotFound)
+}
+
+func (s *peerDistSuite) TestEnsureNotInCluster(c *check.C) {
+	s.st.Lock()
+	s.st.Set("cluster", nil)
+	s.st.Unlock()
+
+	s.setEnabled(c, true)
+	mgr := clusterstate.Manager(s.st)
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	// blobs are only exchanged with the devices of a cluster
+	c.Check(s.servers, check.HasLen, 0)
+	c.Check(s.sto.peers, check.HasLen, 0)
+}
+
+func (s *peerDistSuite) TestEnsureStoreReplaced(c *check.C) {
+	s.setEnabled(c, true)
+	mgr := clusterstate.Manager(s.st)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	oldStore := s.sto
+
+	s.sto = &peerStore{}
+	s.st.Lock()
+	snapstate.ReplaceStore(s.st, s.sto)
+	s.st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Assert(oldStore.peers, check.HasLen, 2)
+	c.Check(oldStore.peers[1], check.IsNil)
+	c.Assert(s.sto.peers, check.HasLen, 1)
+	c.Check(s.sto.peers[0], check.NotNil)
+
+	// the blobs of the new store are served
+	status, _ := fetch(c, s.servers[0], testDigest)
+	c.Check(status, check.Equals, http.StatusNotFound)
+}
+
+func (s *peerDistSuite) TestPeerDevices(c *check.C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	peers, err := clusterstate.PeerDevices(s.st)
+	c.Assert(err, check.IsNil)
+	c.Check(peers, check.DeepEquals, []peerdist.Peer{
+		{Device: 1, Addresses: []string{"192.168.0.10:7417", "10.0.0.10:7417"}},
+		{Device: 3, Addresses: []string{"[fd00::12]:7417"}},
+	})
+}
+
+func (s *peerDistSuite) TestStop(c *check.C) {
+	s.setEnabled(c, true)
+	mgr := clusterstate.Manager(s.st)
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	mgr.Stop()
+	c.Check(s.servers[0].stopped, check.Equals, 1)
+	c.Assert(s.sto.peers, check.HasLen, 2)
+	c.Check(s.sto.peers[1], check.IsNil)
+
+	// stopping again is harmless
+	mgr.Stop()
+	c.Check(s.servers[0].stopped, check.Equals, 1)
+}
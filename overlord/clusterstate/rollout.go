@@ -0,0 +1,544 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/rollout"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+)
+
+func init() {
+	swfeats.RegisterEnsure("ClusterManager", "ensureRollout")
+}
+
+var timeNow = time.Now
+
+// rolloutPollInterval is how often the reports of the canary devices are
+// fetched while waiting for them.
+var rolloutPollInterval = time.Minute
+
+// rolloutFetchTimeout bounds fetching the reports of a canary device.
+const rolloutFetchTimeout = 10 * time.Second
+
+var rolloutFetch = rollout.Fetch
+
+// rolloutPeers tracks the reports fetched from the other devices of the
+// cluster.
+type rolloutPeers struct {
+	// reports holds the reports fetched from the canary devices, by device
+	// ID.
+	reports  map[int][]rollout.Report
+	polledAt time.Time
+	// cancel and done are set while the reports are being fetched, done
+	// being closed once fetching is over
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// subclusterRollout is the progress of applying the state of a subcluster on
+// this device, as kept in the state under "cluster-rollout" by subcluster
+// name.
+type subclusterRollout struct {
+	Report rollout.Report `json:"report"`
+	// Change is the ID of the change applying the state of the subcluster.
+	Change string `json:"change,omitempty"`
+}
+
+func rolloutEntries(st *state.State) (map[string]*subclusterRollout, error) {
+	var entries map[string]*subclusterRollout
+	if err := st.Get("cluster-rollout", &entries); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if entries == nil {
+		entries = make(map[string]*subclusterRollout)
+	}
+	return entries, nil
+}
+
+// rolloutCanaryCount returns the number of devices of each subcluster which
+// apply a new state before the others, as set with the
+// core.cluster.rollout.canaries option. Staged rollouts are disabled when it
+// is 0.
+func rolloutCanaryCount(tr *config.Transaction) (int, error) {
+	var val any
+	if err := tr.Get("core", "cluster.rollout.canaries", &val); err != nil {
+		if config.IsNoOption(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	// the value is validated by configcore when set
+	n, err := strconv.Atoi(fmt.Sprint(val))
+	if err != nil || n < 0 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// rolloutCanaries returns the IDs of the canary devices of the subcluster,
+// which are the given number of devices with the lowest IDs.
+func rolloutCanaries(subcluster asserts.Subcluster, n int) []int {
+	ids := append([]int(nil), subcluster.Devices...)
+	sort.Ints(ids)
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
+
+func isCanary(canaries []int, deviceID int) bool {
+	for _, id := range canaries {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureRollout starts fetching the reports of the canary devices this device
+// is waiting for while staged rollouts are enabled. The reports are fetched in
+// the background, and used by the next ensure pass once available. The
+// reports of this device are served to the other devices along with the other
+// messages exchanged with them, see ensureMembers.
+func (m *ClusterManager) ensureRollout() error {
+	logger.Trace("ensure", "manager", "ClusterManager", "func", "ensureRollout")
+	enabled, err := clusteringEnabled(m.state)
+	if err != nil {
+		return err
+	}
+	var polls map[int][]string
+	if enabled {
+		m.state.Lock()
+		polls, enabled, err = canariesToPoll(m.state)
+		m.state.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+
+	if !enabled {
+		m.stopRolloutLocked()
+		return nil
+	}
+
+	now := timeNow()
+	if m.rollout.done != nil || len(polls) == 0 || now.Sub(m.rollout.polledAt) < rolloutPollInterval {
+		return nil
+	}
+	client := m.memberClient()
+	if client == nil {
+		return fmt.Errorf("cannot fetch the reports of canary devices: not exchanging messages with cluster devices")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.rollout.cancel = cancel
+	m.rollout.done = done
+	m.rollout.polledAt = now
+	go m.fetchCanaryReports(ctx, client, polls, done)
+	return nil
+}
+
+// fetchCanaryReports fetches the reports of the canary devices at the given
+// addresses, by device ID, and keeps them for the next ensure pass unless
+// fetching was stopped meanwhile.
+func (m *ClusterManager) fetchCanaryReports(ctx context.Context, client rollout.Client, polls map[int][]string, done chan struct{}) {
+	defer close(done)
+
+	ids := make([]int, 0, len(polls))
+	for id := range polls {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	reports := make(map[int][]rollout.Report, len(polls))
+	for _, id := range ids {
+		for _, addr := range polls[id] {
+			if ctx.Err() != nil {
+				return
+			}
+			fetchCtx, cancel := context.WithTimeout(ctx, rolloutFetchTimeout)
+			r, err := rolloutFetch(fetchCtx, client, addr, id)
+			cancel()
+			if err != nil {
+				logger.Debugf("cannot fetch rollout reports of device %d at %s: %v", id, addr, err)
+				continue
+			}
+			reports[id] = r
+			break
+		}
+	}
+
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	if m.rollout.done != done {
+		// stopped meanwhile
+		return
+	}
+	m.rollout.cancel()
+	m.rollout.reports = reports
+	m.rollout.cancel = nil
+	m.rollout.done = nil
+	m.state.EnsureBefore(0)
+}
+
+// stopRolloutLocked forgets the reports of the canary devices and cancels
+// fetching them, returning a channel closed once fetching is over, if it was
+// going on. It must be called with rolloutMu held.
+func (m *ClusterManager) stopRolloutLocked() (done <-chan struct{}) {
+	if m.rollout.cancel != nil {
+		m.rollout.cancel()
+	}
+	done = m.rollout.done
+	m.rollout = rolloutPeers{}
+	return done
+}
+
+func (m *ClusterManager) canaryReports() map[int][]rollout.Report {
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	return m.rollout.reports
+}
+
+// canariesToPoll returns the "host:port" addresses of the canary devices whose
+// reports this device is waiting for, by device ID, and whether staged
+// rollouts are enabled at all.
+func canariesToPoll(st *state.State) (map[int][]string, bool, error) {
+	n, err := rolloutCanaryCount(config.NewTransaction(st))
+	if err != nil || n == 0 {
+		return nil, false, err
+	}
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	deviceID, err := clusterDeviceID(st, cluster)
+	if err != nil {
+		return nil, false, err
+	}
+	entries, err := rolloutEntries(st)
+	if err != nil {
+		return nil, false, err
+	}
+
+	addresses := make(map[int][]string)
+	for _, dev := range cluster.Devices() {
+		addresses[dev.ID] = memberAddresses(dev)
+	}
+	polls := make(map[int][]string)
+	for _, subcluster := range cluster.Subclusters() {
+		if !deviceInSubcluster(subcluster, deviceID) {
+			continue
+		}
+		canaries := rolloutCanaries(subcluster, n)
+		if isCanary(canaries, deviceID) {
+			continue
+		}
+		entry := entries[subcluster.Name]
+		if entry != nil && isCurrent(&entry.Report, cluster) && entry.Report.Status != rollout.StatusWaiting {
+			continue
+		}
+		for _, id := range canaries {
+			polls[id] = addresses[id]
+		}
+	}
+	return polls, true, nil
+}
+
+func isCurrent(r *rollout.Report, cluster *asserts.Cluster) bool {
+	return r.ClusterID == cluster.ClusterID() && r.Sequence == cluster.Sequence()
+}
+
+// reportSource provides the rollout reports of this device for the current
+// cluster assertion.
+type reportSource struct {
+	st *state.State
+}
+
+func (s *reportSource) Reports() ([]rollout.Report, error) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	cluster, err := CurrentCluster(s.st)
+	if err != nil {
+		if errors.Is(err, ErrNoClusterAssertion) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries, err := rolloutEntries(s.st)
+	if err != nil {
+		return nil, err
+	}
+	var reports []rollout.Report
+	for _, entry := range entries {
+		if isCurrent(&entry.Report, cluster) {
+			reports = append(reports, entry.Report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Subcluster < reports[j].Subcluster
+	})
+	return reports, nil
+}
+
+// stagedRollout decides when the state of the subclusters of this device is
+// applied during a staged rollout, and tracks the progress of applying it.
+// The canary devices of a subcluster apply a new state first, and the other
+// devices only apply it once all the canaries reported being healthy. A new
+// state is never applied again on a device where it failed.
+type stagedRollout struct {
+	cluster  *asserts.Cluster
+	deviceID int
+	canaries int
+	// reports holds the reports fetched from the canary devices
+	reports map[int][]rollout.Report
+	entries map[string]*subclusterRollout
+	waiting bool
+}
+
+// newStagedRollout returns the staged rollout of the state described by the
+// cluster assertion, or nil if staged rollouts are disabled.
+func newStagedRollout(st *state.State, cluster *asserts.Cluster, reports map[int][]rollout.Report) (*stagedRollout, error) {
+	n, err := rolloutCanaryCount(config.NewTransaction(st))
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	deviceID, err := clusterDeviceID(st, cluster)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := rolloutEntries(st)
+	if err != nil {
+		return nil, err
+	}
+	return &stagedRollout{
+		cluster:  cluster,
+		deviceID: deviceID,
+		canaries: n,
+		reports:  reports,
+		entries:  entries,
+	}, nil
+}
+
+// entry returns the progress of applying the current state of the
+// subcluster, or nil if nothing happened yet.
+func (r *stagedRollout) entry(subcluster string) *subclusterRollout {
+	entry := r.entries[subcluster]
+	if entry == nil || !isCurrent(&entry.Report, r.cluster) {
+		return nil
+	}
+	return entry
+}
+
+func (r *stagedRollout) setStatus(subcluster string, status rollout.Status, msg string) *subclusterRollout {
+	entry := r.entry(subcluster)
+	if entry == nil {
+		entry = &subclusterRollout{}
+		r.entries[subcluster] = entry
+	}
+	entry.Report = rollout.Report{
+		ClusterID:  r.cluster.ClusterID(),
+		Sequence:   r.cluster.Sequence(),
+		Subcluster: subcluster,
+		Device:     r.deviceID,
+		Status:     status,
+		Message:    msg,
+	}
+	return entry
+}
+
+// update records the outcome of the changes applying the state of the
+// subclusters, once they are ready and the health of their snaps is known.
+func (r *stagedRollout) update(st *state.State) error {
+	for _, subcluster := range r.cluster.Subclusters() {
+		entry := r.entry(subcluster.Name)
+		if entry == nil || entry.Report.Status != rollout.StatusApplying {
+			continue
+		}
+		chg := st.Change(entry.Change)
+		if chg == nil {
+			// start over
+			delete(r.entries, subcluster.Name)
+			continue
+		}
+		if !chg.IsReady() {
+			continue
+		}
+		if chg.Status() != state.DoneStatus {
+			msg := fmt.Sprintf("change %s did not succeed", chg.ID())
+			if err := chg.Err(); err != nil {
+				msg += ": " + err.Error()
+			}
+			r.setStatus(subcluster.Name, rollout.StatusFailed, msg)
+			continue
+		}
+		status, msg, err := subclusterHealth(st, subcluster)
+		if err != nil {
+			return err
+		}
+		if status == rollout.StatusApplying {
+			// the snaps are not ready yet
+			r.waiting = true
+			continue
+		}
+		r.setStatus(subcluster.Name, status, msg)
+	}
+	return nil
+}
+
+// subclusterHealth returns the status of the subcluster on this device
+// according to the check-health hooks of its clustered snaps: healthy,
+// failed along with a message, or still applying while some snap is waiting
+// to be ready.
+func subclusterHealth(st *state.State, subcluster asserts.Subcluster) (rollout.Status, string, error) {
+	for _, sn := range subcluster.Snaps {
+		if sn.State != asserts.ClusterSnapStateClustered {
+			continue
+		}
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, sn.Instance, &snapst); err != nil {
+			if errors.Is(err, state.ErrNoState) {
+				return rollout.StatusFailed, fmt.Sprintf("snap %q is not installed", sn.Instance), nil
+			}
+			return "", "", err
+		}
+		health, err := healthstate.Get(st, sn.Instance)
+		if err != nil {
+			return "", "", err
+		}
+		// only the health reported by the current revision is relevant
+		if health == nil || health.Revision != snapst.Current {
+			continue
+		}
+		switch {
+		case health.Status == healthstate.WaitingStatus:
+			return rollout.StatusApplying, "", nil
+		case health.Status == healthstate.ErrorStatus || health.Status == healthstate.BlockedStatus:
+			return rollout.StatusFailed, fmt.Sprintf("snap %q reported %s health: %s", sn.Instance, health.Status, health.Message), nil
+		case health.Code == "snapd-hook-failed":
+			return rollout.StatusFailed, fmt.Sprintf("check-health hook of snap %q failed", sn.Instance), nil
+		}
+	}
+	return rollout.StatusHealthy, "", nil
+}
+
+// mayApply returns whether the state of the subcluster can be applied on this
+// device.
+func (r *stagedRollout) mayApply(st *state.State, subcluster asserts.Subcluster) (bool, error) {
+	if entry := r.entry(subcluster.Name); entry != nil && entry.Report.Status == rollout.StatusFailed {
+		return false, nil
+	}
+	canaries := rolloutCanaries(subcluster, r.canaries)
+	if isCanary(canaries, r.deviceID) {
+		return true, nil
+	}
+
+	// there is no need to wait when the state is applied already
+	installs, removals, updates, err := snapsForSubcluster(st, subcluster)
+	if err != nil {
+		return false, err
+	}
+	if len(installs) == 0 && len(removals) == 0 && len(updates) == 0 {
+		return true, nil
+	}
+
+	for _, id := range canaries {
+		report := rollout.Find(r.reports[id], r.cluster.ClusterID(), r.cluster.Sequence(), subcluster.Name, id)
+		switch {
+		case report == nil:
+			r.wait(subcluster.Name, fmt.Sprintf("waiting for canary device %d", id))
+			return false, nil
+		case report.Status == rollout.StatusFailed:
+			msg := fmt.Sprintf("halted, canary device %d failed", id)
+			if entry := r.entry(subcluster.Name); entry == nil || entry.Report.Message != msg {
+				logger.Noticef("Rollout of subcluster %q state halted, canary device %d failed: %s", subcluster.Name, id, report.Message)
+			}
+			// the canary does not retry, there is nothing to wait for
+			// until a new state is available
+			r.setStatus(subcluster.Name, rollout.StatusWaiting, msg)
+			return false, nil
+		case report.Status != rollout.StatusHealthy:
+			r.wait(subcluster.Name, fmt.Sprintf("waiting for canary device %d", id))
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r *stagedRollout) wait(subcluster, msg string) {
+	r.setStatus(subcluster, rollout.StatusWaiting, msg)
+	r.waiting = true
+}
+
+// applied records that the state of the subcluster did not need any change
+// on this device.
+func (r *stagedRollout) applied(subcluster string) {
+	entry := r.entry(subcluster)
+	if entry == nil || entry.Report.Status == rollout.StatusWaiting {
+		r.setStatus(subcluster, rollout.StatusHealthy, "")
+	}
+}
+
+// started records that the state of the subcluster is being applied on this
+// device by the given change.
+func (r *stagedRollout) started(subcluster string, chg *state.Change) {
+	entry := r.setStatus(subcluster, rollout.StatusApplying, "")
+	entry.Change = chg.ID()
+}
+
+// save writes the progress of the rollout to the state, and schedules the
+// next check of the progress when waiting for it.
+func (r *stagedRollout) save(st *state.State) {
+	// drop the subclusters this device is no longer part of
+	for name := range r.entries {
+		found := false
+		for _, subcluster := range r.cluster.Subclusters() {
+			if subcluster.Name == name && deviceInSubcluster(subcluster, r.deviceID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(r.entries, name)
+		}
+	}
+	st.Set("cluster-rollout", r.entries)
+	if r.waiting {
+		st.EnsureBefore(rolloutPollInterval)
+	}
+}
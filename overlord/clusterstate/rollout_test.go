@@ -0,0 +1,463 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/rollout"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/sequence"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type rolloutSuite struct {
+	testutil.BaseTest
+
+	st    *state.State
+	stack *assertstest.StoreStack
+	mgr   *clusterstate.ClusterManager
+
+	servers []*fakeMemberServer
+	fetches []string
+	// reports served by the other devices, by address
+	reports map[string][]rollout.Report
+	now     time.Time
+}
+
+var _ = check.Suite(&rolloutSuite{})
+
+func (s *rolloutSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.st, s.stack = newStateWithStoreStack(c)
+	s.servers = nil
+	s.fetches = nil
+	s.reports = make(map[string][]rollout.Report)
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	s.AddCleanup(mockMemberServers(&s.servers, nil))
+	s.AddCleanup(clusterstate.MockSignWithDeviceKey(func(st *state.State, data []byte) ([]byte, error) {
+		return []byte("signature"), nil
+	}))
+
+	restore := clusterstate.MockRolloutFetch(func(ctx context.Context, client rollout.Client, addr string, device int) ([]rollout.Report, error) {
+		c.Check(client, check.NotNil)
+		s.fetches = append(s.fetches, fmt.Sprintf("%d@%s", device, addr))
+		reports, ok := s.reports[addr]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		return reports, nil
+	})
+	s.AddCleanup(restore)
+
+	restore = clusterstate.MockTimeNow(func() time.Time { return s.now })
+	s.AddCleanup(restore)
+
+	restore = clusterstate.MockInstallWithGoal(func(ctx context.Context, st *state.State, goal snapstate.InstallGoal, opts snapstate.Options) ([]*snap.Info, []*state.TaskSet, error) {
+		task := st.NewTask("install", "install snaps")
+		return nil, []*state.TaskSet{state.NewTaskSet(task)}, nil
+	})
+	s.AddCleanup(restore)
+
+	s.mgr = clusterstate.Manager(s.st)
+}
+
+// setUpCluster sets up a cluster of three devices, sharing a subcluster with
+// the snap-one snap, with this device being the one with the given serial.
+func (s *rolloutSuite) setUpCluster(c *check.C, serial string, canaries int) {
+	bundle, _ := makeClusterBundle(c, s.stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10", "10.0.0.10"},
+		},
+		{
+			"id":        "2",
+			"device":    "serial-2.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.11"},
+		},
+		{
+			"id":        "3",
+			"device":    "serial-3.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.12"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"3", "2", "1"},
+		"snaps": []any{
+			map[string]any{
+				"state":    "clustered",
+				"instance": "snap-one",
+				"channel":  "latest/stable",
+			},
+		},
+	}})
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	addSerialToState(c, s.st, makeSerialAssertion(c, s.stack, serial))
+	c.Assert(clusterstate.InitializeNewCluster(s.st, bytes.NewReader(bundle)), check.IsNil)
+
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.rollout.canaries", canaries), check.IsNil)
+	tr.Commit()
+}
+
+// ensure runs an ensure pass, waits for the reports of the canary devices
+// fetched meanwhile, and runs the next ensure pass using them.
+func (s *rolloutSuite) ensure(c *check.C) {
+	s.st.Unlock()
+	defer s.st.Lock()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.mgr.WaitRolloutFetch()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+}
+
+// served returns the reports served by this device to device 2.
+func (s *rolloutSuite) served(c *check.C) []rollout.Report {
+	c.Assert(s.servers, check.HasLen, 1)
+	h := s.servers[0].handlers[rollout.ReportsKind]
+	c.Assert(h, check.NotNil)
+
+	s.st.Unlock()
+	defer s.st.Lock()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/cluster/"+rollout.ReportsKind, nil), 2)
+	c.Assert(rec.Code, check.Equals, 200)
+	var reports []rollout.Report
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &reports), check.IsNil)
+	if len(reports) == 0 {
+		return nil
+	}
+	return reports
+}
+
+func (s *rolloutSuite) report(device int, status rollout.Status, msg string) rollout.Report {
+	return rollout.Report{
+		ClusterID:  "cluster-id",
+		Sequence:   1,
+		Subcluster: "default",
+		Device:     device,
+		Status:     status,
+		Message:    msg,
+	}
+}
+
+func (s *rolloutSuite) installSnapOne(health *healthstate.HealthState) {
+	snapstate.Set(s.st, "snap-one", &snapstate.SnapState{
+		Active:          true,
+		Current:         snap.R(1),
+		TrackingChannel: "latest/stable",
+		Sequence: sequence.SnapSequence{
+			Revisions: []*sequence.RevisionSideState{
+				sequence.NewRevisionSideState(&snap.SideInfo{RealName: "snap-one", Revision: snap.R(1)}, nil),
+			},
+		},
+	})
+	if health != nil {
+		s.st.Set("health", map[string]*healthstate.HealthState{"snap-one": health})
+	}
+}
+
+func applyChanges(st *state.State) []*state.Change {
+	var changes []*state.Change
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "apply-cluster-subcluster" {
+			changes = append(changes, chg)
+		}
+	}
+	return changes
+}
+
+func (s *rolloutSuite) TestDisabledByDefault(c *check.C) {
+	s.setUpCluster(c, "serial-3", 0)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	// all devices apply the state right away
+	c.Check(applyChanges(s.st), check.HasLen, 1)
+	c.Check(s.servers, check.HasLen, 0)
+	c.Check(s.fetches, check.HasLen, 0)
+	var entries map[string]any
+	c.Check(s.st.Get("cluster-rollout", &entries), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *rolloutSuite) TestCanaryHealthy(c *check.C) {
+	s.setUpCluster(c, "serial-1", 2)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	changes := applyChanges(s.st)
+	c.Assert(changes, check.HasLen, 1)
+	c.Assert(s.servers, check.HasLen, 1)
+	// canaries do not wait for anybody
+	c.Check(s.fetches, check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(1, rollout.StatusApplying, ""),
+	})
+
+	// the snap is installed, and waits to be ready
+	changes[0].SetStatus(state.DoneStatus)
+	s.installSnapOne(&healthstate.HealthState{Revision: snap.R(1), Status: healthstate.WaitingStatus})
+	s.ensure(c)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(1, rollout.StatusApplying, ""),
+	})
+
+	s.installSnapOne(&healthstate.HealthState{Revision: snap.R(1), Status: healthstate.OkayStatus})
+	s.ensure(c)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(1, rollout.StatusHealthy, ""),
+	})
+	c.Check(applyChanges(s.st), check.HasLen, 1)
+	c.Check(s.servers, check.HasLen, 1)
+}
+
+func (s *rolloutSuite) TestCanaryChangeFailed(c *check.C) {
+	s.setUpCluster(c, "serial-2", 2)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	changes := applyChanges(s.st)
+	c.Assert(changes, check.HasLen, 1)
+	t := changes[0].Tasks()[0]
+	t.Errorf("boom")
+	t.SetStatus(state.ErrorStatus)
+	s.ensure(c)
+
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(2, rollout.StatusFailed, "change "+changes[0].ID()+" did not succeed: cannot perform the following tasks:\n- install snaps (boom)"),
+	})
+	// the state is not applied again
+	c.Check(applyChanges(s.st), check.HasLen, 1)
+}
+
+func (s *rolloutSuite) TestCanaryUnhealthy(c *check.C) {
+	s.setUpCluster(c, "serial-1", 1)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	changes := applyChanges(s.st)
+	c.Assert(changes, check.HasLen, 1)
+	changes[0].SetStatus(state.DoneStatus)
+	s.installSnapOne(&healthstate.HealthState{
+		Revision: snap.R(1),
+		Status:   healthstate.ErrorStatus,
+		Message:  "cannot reach the database",
+	})
+	s.ensure(c)
+
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(1, rollout.StatusFailed, `snap "snap-one" reported error health: cannot reach the database`),
+	})
+
+	// the state is not applied again
+	s.ensure(c)
+	c.Check(applyChanges(s.st), check.HasLen, 1)
+}
+
+func (s *rolloutSuite) TestCanaryHealthOfOtherRevision(c *check.C) {
+	s.setUpCluster(c, "serial-1", 1)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	changes := applyChanges(s.st)
+	c.Assert(changes, check.HasLen, 1)
+	changes[0].SetStatus(state.DoneStatus)
+	// only the health reported by the current revision is relevant
+	s.installSnapOne(&healthstate.HealthState{Revision: snap.R(2), Status: healthstate.ErrorStatus})
+	s.ensure(c)
+
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(1, rollout.StatusHealthy, ""),
+	})
+}
+
+func (s *rolloutSuite) TestWaitForCanaries(c *check.C) {
+	s.setUpCluster(c, "serial-3", 2)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+
+	// device 1 is unreachable on its first address
+	c.Check(s.fetches, check.DeepEquals, []string{"1@192.168.0.10:7417", "1@10.0.0.10:7417", "2@192.168.0.11:7417"})
+	c.Check(applyChanges(s.st), check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusWaiting, "waiting for canary device 1"),
+	})
+
+	s.reports["10.0.0.10:7417"] = []rollout.Report{s.report(1, rollout.StatusHealthy, "")}
+	s.reports["192.168.0.11:7417"] = []rollout.Report{
+		// a report of device 2 about device 1 is ignored
+		s.report(1, rollout.StatusHealthy, ""),
+		s.report(2, rollout.StatusApplying, ""),
+	}
+
+	// the canaries are only polled once in a while
+	s.fetches = nil
+	s.ensure(c)
+	c.Check(s.fetches, check.HasLen, 0)
+
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	c.Check(s.fetches, check.HasLen, 3)
+	c.Check(applyChanges(s.st), check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusWaiting, "waiting for canary device 2"),
+	})
+
+	s.reports["192.168.0.11:7417"] = []rollout.Report{s.report(2, rollout.StatusHealthy, "")}
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	c.Check(applyChanges(s.st), check.HasLen, 1)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusApplying, ""),
+	})
+
+	// the canaries are no longer polled
+	s.fetches = nil
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	c.Check(s.fetches, check.HasLen, 0)
+}
+
+func (s *rolloutSuite) TestFetchInBackground(c *check.C) {
+	s.setUpCluster(c, "serial-3", 1)
+
+	fetching := make(chan struct{})
+	restore := clusterstate.MockRolloutFetch(func(ctx context.Context, client rollout.Client, addr string, device int) ([]rollout.Report, error) {
+		close(fetching)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer restore()
+
+	// the ensure pass does not wait for the canary devices
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	<-fetching
+
+	s.st.Lock()
+	c.Check(applyChanges(s.st), check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusWaiting, "waiting for canary device 1"),
+	})
+	s.st.Unlock()
+
+	// nor does the next one while fetching is going on
+	s.now = s.now.Add(time.Minute)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	// stopping the manager cancels fetching
+	s.mgr.Stop()
+}
+
+func (s *rolloutSuite) TestCanaryFailureHaltsRollout(c *check.C) {
+	s.setUpCluster(c, "serial-3", 1)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.reports["192.168.0.10:7417"] = []rollout.Report{
+		s.report(1, rollout.StatusFailed, `snap "snap-one" reported error health: broken`),
+	}
+	s.ensure(c)
+
+	c.Check(applyChanges(s.st), check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusWaiting, "halted, canary device 1 failed"),
+	})
+}
+
+func (s *rolloutSuite) TestNothingToApply(c *check.C) {
+	s.setUpCluster(c, "serial-3", 1)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.installSnapOne(nil)
+	s.ensure(c)
+
+	// there is no need to wait for the canaries
+	c.Check(applyChanges(s.st), check.HasLen, 0)
+	c.Check(s.served(c), check.DeepEquals, []rollout.Report{
+		s.report(3, rollout.StatusHealthy, ""),
+	})
+
+	// nor to poll them any longer
+	s.fetches = nil
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	c.Check(s.fetches, check.HasLen, 0)
+}
+
+func (s *rolloutSuite) TestStopServing(c *check.C) {
+	s.setUpCluster(c, "serial-1", 1)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	s.ensure(c)
+	c.Assert(s.servers, check.HasLen, 1)
+	c.Check(s.servers[0].addr, check.Equals, ":7417")
+
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.rollout.canaries", 0), check.IsNil)
+	tr.Commit()
+	s.ensure(c)
+	c.Check(s.servers[0].stopped, check.Equals, 1)
+
+	tr = config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "cluster.rollout.canaries", 1), check.IsNil)
+	tr.Commit()
+	s.ensure(c)
+	c.Assert(s.servers, check.HasLen, 2)
+
+	s.st.Unlock()
+	s.mgr.Stop()
+	s.st.Lock()
+	c.Check(s.servers[1].stopped, check.Equals, 1)
+}
@@ -22,8 +22,10 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/confdb"
 	"github.com/snapcore/snapd/i18n"
@@ -31,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
 	"gopkg.in/tomb.v2"
 )
 
@@ -70,13 +73,19 @@ func RegisterConfdbHandler(c SystemConfdbHandler) {
 	systemHandlers[c.SchemaName()] = c
 }
 
-type ConfdbManager struct{}
+func init() {
+	swfeats.RegisterEnsure("ConfdbManager", "ensureSchemaMigrations")
+}
+
+type ConfdbManager struct {
+	state *state.State
+}
 
 func Manager(st *state.State, hookMgr *hookstate.HookManager, runner *state.TaskRunner) *ConfdbManager {
 	snapstate.IsConfdbHookname = IsConfdbHookname
 	hookstate.IsConfdbHookname = IsConfdbHookname
 
-	m := &ConfdbManager{}
+	m := &ConfdbManager{state: st}
 
 	// no undo since if we commit there's no rolling back
 	runner.AddHandler("commit-confdb-tx", m.doCommitTransaction, nil)
@@ -101,6 +110,9 @@ func Manager(st *state.State, hookMgr *hookstate.HookManager, runner *state.Task
 	hookMgr.Register(regexp.MustCompile("^load-view-.+$"), func(context *hookstate.Context) hookstate.Handler {
 		return &hookstate.SnapHookHandler{}
 	})
+	hookMgr.Register(regexp.MustCompile("^migrate-view-.+$"), func(context *hookstate.Context) hookstate.Handler {
+		return &hookstate.SnapHookHandler{}
+	})
 
 	return m
 }
@@ -117,7 +129,54 @@ func setupConfdbHook(st *state.State, snapName, hookName string, ignoreError boo
 	return task
 }
 
-func (m *ConfdbManager) Ensure() error { return nil }
+func (m *ConfdbManager) Ensure() error {
+	return m.ensureSchemaMigrations()
+}
+
+// ensureSchemaMigrations checks every stored databag for a newer revision of
+// its confdb-schema. If the data conforms to the new revision and there's
+// nothing to migrate, the revision takes effect right away. Otherwise, a
+// change is scheduled to migrate the data, in a transaction, using the
+// migrations declared in the assertion and the custodians' migrate-view hooks.
+// If the data cannot be migrated, the new revision is rejected and the
+// previous one stays in effect.
+func (m *ConfdbManager) ensureSchemaMigrations() error {
+	logger.Trace("ensure", "manager", "ConfdbManager", "func", "ensureSchemaMigrations")
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var databags map[string]map[string]confdb.JSONDatabag
+	if err := st.Get("confdb-databags", &databags); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+
+	accounts := make([]string, 0, len(databags))
+	for account := range databags {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		schemaNames := make([]string, 0, len(databags[account]))
+		for name := range databags[account] {
+			schemaNames = append(schemaNames, name)
+		}
+		sort.Strings(schemaNames)
+
+		for _, name := range schemaNames {
+			// a failure with one confdb shouldn't prevent migrating others
+			if err := m.ensureSchemaMigration(account, name, databags[account][name]); err != nil {
+				logger.Noticef("cannot migrate confdb %s/%s: %v", account, name, err)
+			}
+		}
+	}
+
+	return nil
+}
 
 func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err error) {
 	st := t.State()
@@ -129,12 +188,39 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 		return err
 	}
 
-	confdbAssert, err := AssertstateConfdbSchema(st, tx.ConfdbAccount, tx.ConfdbName)
-	if err != nil {
+	var migrateToRev int
+	if err := t.Get("migrate-to-revision", &migrateToRev); err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
 	}
+
+	var confdbAssert *asserts.ConfdbSchema
+	if migrateToRev != 0 {
+		// the data is being migrated to the latest revision
+		confdbAssert, err = AssertstateConfdbSchema(st, tx.ConfdbAccount, tx.ConfdbName)
+		if err != nil {
+			return err
+		}
+		if confdbAssert.Revision() != migrateToRev {
+			return fmt.Errorf("cannot commit migration of confdb %s/%s to revision %d: revision %d is now available", tx.ConfdbAccount, tx.ConfdbName, migrateToRev, confdbAssert.Revision())
+		}
+	} else {
+		confdbAssert, err = confdbSchemaAssertion(st, tx.ConfdbAccount, tx.ConfdbName)
+		if err != nil {
+			return err
+		}
+	}
 	schema := confdbAssert.Schema().DatabagSchema
 
+	// the paths must be saved before committing, which resets the transaction
+	paths := tx.AlteredPaths()
+	notifyChanges := func() {
+		// the data is committed at this point so failing to notify about it
+		// shouldn't fail the change
+		if err := addConfdbChangeNotices(st, confdbAssert.Schema(), paths, t.Change().ID()); err != nil {
+			logger.Noticef("cannot add notices for changes to confdb %s/%s: %v", tx.ConfdbAccount, tx.ConfdbName, err)
+		}
+	}
+
 	hasSaveViewHook := false
 	for _, task := range t.Change().Tasks() {
 		if task.Kind() != "run-hook" {
@@ -176,6 +262,7 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 				return err
 			}
 			saveTxChanges()
+			notifyChanges()
 			return nil
 		}
 
@@ -192,6 +279,7 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 				return err
 			}
 			saveTxChanges()
+			notifyChanges()
 			return nil
 		}
 
@@ -214,8 +302,9 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 
 	// we error early if a write may affect ephemeral data but no save-view hook
 	// is present. However, a change-view hook may have written to an ephemeral
-	// path after that so we have to check again
-	if !hasSaveViewHook {
+	// path after that so we have to check again. Migrations aren't written
+	// through a view so they're not checked
+	if !hasSaveViewHook && migrateToRev == 0 {
 		var viewName string
 		err = t.Get("view", &viewName)
 		if err != nil {
@@ -234,7 +323,43 @@ func (m *ConfdbManager) doCommitTransaction(t *state.Task, _ *tomb.Tomb) (err er
 		}
 	}
 
-	return tx.Commit(st, schema)
+	oldBag, err := readDatabag(st, tx.ConfdbAccount, tx.ConfdbName)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(st, schema); err != nil {
+		return err
+	}
+
+	// the data now conforms to this revision, so it takes effect if it wasn't
+	// already
+	revisions, err := readSchemaRevisions(st)
+	if err != nil {
+		return err
+	}
+	if rec := revisions[tx.ConfdbAccount][tx.ConfdbName]; migrateToRev != 0 || rec == nil {
+		if err := acceptSchemaRevision(st, confdbAssert); err != nil {
+			return err
+		}
+	}
+
+	notifyChanges()
+
+	// the data is committed at this point so failing to keep its history
+	// shouldn't fail the change
+	newBag, err := readDatabag(st, tx.ConfdbAccount, tx.ConfdbName)
+	if err != nil {
+		logger.Noticef("cannot record revision of confdb %s/%s: %v", tx.ConfdbAccount, tx.ConfdbName, err)
+		return nil
+	}
+	if err := recordDatabagRevision(t, tx.ConfdbAccount, tx.ConfdbName, oldBag, newBag); err != nil {
+		logger.Noticef("cannot record revision of confdb %s/%s: %v", tx.ConfdbAccount, tx.ConfdbName, err)
+	}
+	if err := notifyCommitObservers(st, tx.ConfdbAccount, tx.ConfdbName, oldBag, newBag); err != nil {
+		logger.Noticef("cannot notify about changes to confdb %s/%s: %v", tx.ConfdbAccount, tx.ConfdbName, err)
+	}
+	return nil
 }
 
 func (m *ConfdbManager) clearOngoingTransaction(t *state.Task, _ *tomb.Tomb) error {
@@ -100,7 +100,7 @@ func (e *NoViewError) Error() string {
 // name. Returns asserts.NotFoundError if no confdb-schema assertion can be
 // fetched and NoViewError if the known confdb-schema has no such view.
 func GetView(st *state.State, account, schemaName, viewName string) (*confdb.View, error) {
-	confdbSchemaAs, err := AssertstateConfdbSchema(st, account, schemaName)
+	confdbSchemaAs, err := confdbSchemaAssertion(st, account, schemaName)
 	if err != nil {
 		if !errors.Is(err, &asserts.NotFoundError{}) {
 			return nil, err
@@ -117,7 +117,7 @@ func GetView(st *state.State, account, schemaName, viewName string) (*confdb.Vie
 			return nil, fetchErr
 		}
 
-		confdbSchemaAs, err = AssertstateConfdbSchema(st, account, schemaName)
+		confdbSchemaAs, err = confdbSchemaAssertion(st, account, schemaName)
 		if err != nil {
 			return nil, err
 		}
@@ -311,11 +311,32 @@ func waitForAccess(ctx context.Context, st *state.State, view *confdb.View, accK
 // set the values in specified confdb view and run the appropriate hooks.
 // Returns a change ID.
 func WriteConfdb(ctx context.Context, st *state.State, view *confdb.View, values map[string]any) (changeID string, err error) {
-	accessID, err := waitForAccess(ctx, st, view, writeAccess)
+	return WriteConfdbAsUser(ctx, st, view, values, 0)
+}
+
+// WriteConfdbAsUser is like WriteConfdb but records the user making the
+// change in the history of the databag.
+func WriteConfdbAsUser(ctx context.Context, st *state.State, view *confdb.View, values map[string]any, userID int) (changeID string, err error) {
+	summary := fmt.Sprintf("Set confdb through %q", view.ID())
+	chg, _, err := writeConfdb(ctx, st, view, summary, userID, func(tx *Transaction) error {
+		return setViaView(tx, view, values)
+	})
 	if err != nil {
 		return "", err
 	}
 
+	return chg.ID(), nil
+}
+
+// writeConfdb waits for write access to the view's confdb and schedules a
+// change committing the writes made by the write function to a new
+// transaction. Returns the change and its commit task.
+func writeConfdb(ctx context.Context, st *state.State, view *confdb.View, summary string, userID int, write func(tx *Transaction) error) (chg *state.Change, commitTask *state.Task, err error) {
+	accessID, err := waitForAccess(ctx, st, view, writeAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	account, schema := view.Schema().Account, view.Schema().Name
 	// accessID is empty if we didn't release the lock and wait, so no state was
 	// modified and there aren't other accesses to unblock
@@ -327,31 +348,33 @@ func WriteConfdb(ctx context.Context, st *state.State, view *confdb.View, values
 	// and a change to verify its changes and commit
 	tx, err := NewTransaction(st, account, schema)
 	if err != nil {
-		return "", fmt.Errorf("cannot write confdb view %s: cannot create transaction: %v", view.ID(), err)
+		return nil, nil, fmt.Errorf("cannot write confdb view %s: cannot create transaction: %v", view.ID(), err)
 	}
 
-	err = setViaView(tx, view, values)
-	if err != nil {
-		return "", err
+	if err := write(tx); err != nil {
+		return nil, nil, err
 	}
 
 	// the hooks we schedule depend on the paths written so this must happen after writing
 	ts, commitTask, _, err := createChangeConfdbTasks(st, tx, view, "")
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	if userID != 0 {
+		commitTask.Set("user-id", userID)
 	}
 
 	err = setWriteTransaction(st, account, schema, commitTask.ID(), accessID)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	// schedule tasks after saving the tx ID so the deferred cleanup skips waking
 	// up waiters if a task will do it (txs.WriteTxID != "")
-	chg := st.NewChange(setConfdbChangeKind, fmt.Sprintf("Set confdb through %q", view.ID()))
+	chg = st.NewChange(setConfdbChangeKind, summary)
 	chg.AddAll(ts)
 
-	return chg.ID(), nil
+	return chg, commitTask, nil
 }
 
 // WriteConfdbFromSnap takes a hook context and a map of requests to values that
@@ -542,6 +565,9 @@ func createChangeConfdbTasks(st *state.State, tx *Transaction, view *confdb.View
 	commitTask = st.NewTask("commit-confdb-tx", fmt.Sprintf("Commit changes to confdb (%s)", view.ID()))
 	commitTask.Set("confdb-transaction", tx)
 	commitTask.Set("view", view.Name)
+	if callingSnap != "" {
+		commitTask.Set("calling-snap", callingSnap)
+	}
 
 	// link all previous tasks to the commit task that carries the transaction
 	for _, t := range ts.Tasks() {
@@ -630,6 +656,62 @@ func getCustodianPlugsForView(st *state.State, view *confdb.View) ([]string, map
 	return custodians, custodianPlugs, nil
 }
 
+// addConfdbChangeNotices records a confdb-change notice for each view of the
+// confdb-schema with visibility into any of the modified storage paths.
+func addConfdbChangeNotices(st *state.State, dbSchema *confdb.Schema, storagePaths [][]confdb.Accessor, changeID string) error {
+	affected := make(map[string]bool)
+	for _, path := range storagePaths {
+		for _, view := range dbSchema.GetViewsAffectedByPath(path) {
+			affected[view.ID()] = true
+		}
+	}
+
+	viewIDs := make([]string, 0, len(affected))
+	for viewID := range affected {
+		viewIDs = append(viewIDs, viewID)
+	}
+	sort.Strings(viewIDs)
+
+	opts := &state.AddNoticeOptions{}
+	if changeID != "" {
+		opts.Data = map[string]string{"change-id": changeID}
+	}
+	for _, viewID := range viewIDs {
+		if _, err := st.AddNotice(nil, state.ConfdbChangeNotice, viewID, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConnectedViewIDs returns the IDs of the views that the snap has connected
+// confdb plugs for, which are also the keys of the confdb-change notices it
+// may read. The list is sorted.
+func ConnectedViewIDs(st *state.State, snapName string) ([]string, error) {
+	repo := ifacerepo.Get(st)
+
+	var viewIDs []string
+	for _, plug := range repo.ConnectedPlugs(snapName) {
+		if plug.Interface != "confdb" {
+			continue
+		}
+
+		account, dbSchemaName, viewName, err := snap.ConfdbPlugAttrs(plug)
+		if err != nil {
+			return nil, err
+		}
+
+		viewID := account + "/" + dbSchemaName + "/" + viewName
+		if !strutil.ListContains(viewIDs, viewID) {
+			viewIDs = append(viewIDs, viewID)
+		}
+	}
+	sort.Strings(viewIDs)
+
+	return viewIDs, nil
+}
+
 func getPlugsAffectedByPaths(st *state.State, dbSchema *confdb.Schema, storagePaths [][]confdb.Accessor) (map[string][]*snap.PlugInfo, error) {
 	var viewNames []string
 	for _, path := range storagePaths {
@@ -715,17 +797,19 @@ func IsConfdbHookname(name string) bool {
 		strings.HasPrefix(name, "save-view-") ||
 		strings.HasPrefix(name, "load-view-") ||
 		strings.HasPrefix(name, "query-view-") ||
-		strings.HasPrefix(name, "observe-view-")
+		strings.HasPrefix(name, "observe-view-") ||
+		strings.HasPrefix(name, "migrate-view-")
 }
 
 // CanHookSetConfdb returns whether the hook context belongs to a confdb hook
-// that supports snapctl set (either a write hook or load-view). Returns false
-// if the context is ephemeral.
+// that supports snapctl set (either a write hook, load-view or migrate-view).
+// Returns false if the context is ephemeral.
 func CanHookSetConfdb(ctx *hookstate.Context) bool {
 	return !ctx.IsEphemeral() &&
 		(strings.HasPrefix(ctx.HookName(), "change-view-") ||
 			strings.HasPrefix(ctx.HookName(), "query-view-") ||
-			strings.HasPrefix(ctx.HookName(), "load-view-"))
+			strings.HasPrefix(ctx.HookName(), "load-view-") ||
+			strings.HasPrefix(ctx.HookName(), "migrate-view-"))
 }
 
 // ReadConfdbFromSnap gets a transaction to read the view's confdb. It schedules
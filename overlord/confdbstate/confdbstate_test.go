@@ -20,6 +20,7 @@ package confdbstate_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -65,6 +66,7 @@ type confdbTestSuite struct {
 	dbSchema    *confdb.Schema
 	otherSchema *confdb.Schema
 	devAccID    string
+	signingDB   *assertstest.SigningDB
 
 	restoreDeviceCtx func()
 }
@@ -114,6 +116,7 @@ func (s *confdbTestSuite) SetUpTest(c *C) {
 
 	signingDB := assertstest.NewSigningDB("developer1", devPrivKey)
 	c.Check(signingDB, NotNil)
+	s.signingDB = signingDB
 	c.Assert(storeSigning.Add(devAccKey), IsNil)
 
 	headers := map[string]any{
@@ -326,6 +329,44 @@ func (s *confdbTestSuite) TestSetView(c *C) {
 	c.Assert(val, DeepEquals, "foo")
 }
 
+func (s *confdbTestSuite) TestSetViewAddsChangeNotices(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+
+	view, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-wifi")
+	c.Assert(err, IsNil)
+
+	chgID, err := confdbstate.WriteConfdb(context.Background(), s.state, view, map[string]any{"ssid": "foo"})
+	c.Assert(err, IsNil)
+
+	filter := &state.NoticeFilter{Types: []state.NoticeType{state.ConfdbChangeNotice}}
+	// nothing is notified before the transaction is committed
+	c.Check(s.state.Notices(filter), HasLen, 0)
+
+	s.state.Unlock()
+	s.o.Settle(5 * time.Second)
+	s.state.Lock()
+
+	c.Assert(s.state.Change(chgID).Status(), Equals, state.DoneStatus)
+
+	notices := s.state.Notices(filter)
+	c.Assert(notices, HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], Equals, s.devAccID+"/network/setup-wifi")
+	c.Check(n["user-id"], IsNil)
+	c.Check(n["last-data"], DeepEquals, map[string]any{"change-id": chgID})
+}
+
+func noticeToMap(c *C, notice *state.Notice) map[string]any {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), IsNil)
+	return n
+}
+
 func (s *confdbTestSuite) TestSetViewDoesNotEraseOtherSchemaUnderSameAccount(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -676,6 +717,89 @@ slots:
 	c.Assert(plugNames, testutil.DeepUnsortedMatches, []string{"view-1", "view-3"})
 }
 
+func (s *confdbTestSuite) TestConnectedViewIDs(c *C) {
+	repo := interfaces.NewRepository()
+	s.state.Lock()
+	defer s.state.Unlock()
+	ifacerepo.Replace(s.state, repo)
+
+	for _, name := range []string{"confdb", "network"} {
+		err := repo.AddInterface(&ifacetest.TestInterface{InterfaceName: name})
+		c.Assert(err, IsNil)
+	}
+
+	snapYaml := fmt.Sprintf(`name: test-snap
+version: 1
+type: app
+plugs:
+  view-1:
+    interface: confdb
+    account: %[1]s
+    view: confdb/view-1
+  view-1-again:
+    interface: confdb
+    account: %[1]s
+    view: confdb/view-1
+  view-2:
+    interface: confdb
+    account: %[1]s
+    view: confdb/view-2
+  view-3:
+    interface: confdb
+    account: %[1]s
+    view: other/view-3
+  net:
+    interface: network
+`, s.devAccID)
+	info := mockInstalledSnap(c, s.state, snapYaml, nil)
+	appSet, err := interfaces.NewSnapAppSet(info, nil)
+	c.Assert(err, IsNil)
+	c.Assert(repo.AddAppSet(appSet), IsNil)
+
+	const coreYaml = `name: core
+version: 1
+type: os
+slots:
+ confdb-slot:
+  interface: confdb
+ network-slot:
+  interface: network
+`
+	info = mockInstalledSnap(c, s.state, coreYaml, nil)
+	coreSet, err := interfaces.NewSnapAppSet(info, nil)
+	c.Assert(err, IsNil)
+	c.Assert(repo.AddAppSet(coreSet), IsNil)
+
+	viewIDs, err := confdbstate.ConnectedViewIDs(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(viewIDs, HasLen, 0)
+
+	for plug, slot := range map[string]string{
+		"view-1":       "confdb-slot",
+		"view-1-again": "confdb-slot",
+		"view-3":       "confdb-slot",
+		"net":          "network-slot",
+	} {
+		ref := &interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: "test-snap", Name: plug},
+			SlotRef: interfaces.SlotRef{Snap: "core", Name: slot},
+		}
+		_, err = repo.Connect(ref, nil, nil, nil, nil, nil)
+		c.Assert(err, IsNil)
+	}
+
+	viewIDs, err = confdbstate.ConnectedViewIDs(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(viewIDs, DeepEquals, []string{
+		s.devAccID + "/confdb/view-1",
+		s.devAccID + "/other/view-3",
+	})
+
+	viewIDs, err = confdbstate.ConnectedViewIDs(s.state, "other-snap")
+	c.Assert(err, IsNil)
+	c.Check(viewIDs, HasLen, 0)
+}
+
 func (s *confdbTestSuite) TestConfdbTasksUserSetWithCustodianInstalled(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -871,13 +995,14 @@ const (
 	queryView
 	loadView
 	observeView
+	migrateView
 
 	end
 )
 
 func (c confdbHooks) toString() []string {
 	allHooks := []string{"change-view-setup", "save-view-setup", "query-view-setup",
-		"load-view-setup", "observe-view-setup"}
+		"load-view-setup", "observe-view-setup", "migrate-view-setup"}
 	if end != 1<<len(allHooks) {
 		panic("confdb hook name lsit doesn't match confdbHooks values")
 	}
@@ -2197,7 +2322,7 @@ func (s *confdbTestSuite) TestCanHookSetConfdb(c *C) {
 }
 
 func (s *confdbTestSuite) TestEnsureLoopLogging(c *C) {
-	swfeatstest.CheckEnsureLoopLogging("confdbmgr.go", c, false)
+	swfeatstest.CheckEnsureLoopLogging("confdbmgr.go", c, true)
 }
 
 func (s *confdbTestSuite) TestGetTransactionWithSecretVisibility(c *C) {
@@ -3412,6 +3537,13 @@ plugs:
 
 	// make sure the hook was called and its assertions ran
 	c.Assert(observeViewCalled, Equals, true)
+	// observers of all affected views are notified
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.ConfdbChangeNotice}})
+	keys := make([]string, 0, len(notices))
+	for _, n := range notices {
+		keys = append(keys, n.Key())
+	}
+	c.Check(keys, testutil.DeepUnsortedMatches, []string{"system/validation-sets/admin", "system/validation-sets/pinning-admin", "system/validation-sets/state"})
 }
 
 // setup an assertion DB with the builtin system/validation-sets confdb-schema
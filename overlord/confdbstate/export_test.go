@@ -94,3 +94,19 @@ func GetOngoingTxs(st *state.State, account, schemaName string) (ongoingTxs *con
 func MockFetchConfdbSchemaAssertion(f func(*state.State, int, string, string) error) func() {
 	return testutil.Mock(&AssertstateFetchConfdbSchemaAssertion, f)
 }
+
+func MockTimeNow(f func() time.Time) func() {
+	return testutil.Mock(&timeNow, f)
+}
+
+func MockMaxDatabagRevisions(n int) func() {
+	return testutil.Mock(&maxDatabagRevisions, n)
+}
+
+var DatabagAtRevision = databagAtRevision
+
+var ApplyMigrations = applyMigrations
+
+func MockCommitObservers(observers []CommitObserver) func() {
+	return testutil.Mock(&commitObservers, observers)
+}
@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	timeNow = time.Now
+
+	// maxDatabagRevisions is the number of revisions kept in the history of
+	// each databag.
+	maxDatabagRevisions = 20
+)
+
+// DatabagRevision describes a committed change to a databag.
+type DatabagRevision struct {
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// View is the view through which the change was made.
+	View string `json:"view,omitempty"`
+	// Snap is the snap which made the change, if any.
+	Snap string `json:"snap,omitempty"`
+	// UserID is the ID of the user who made the change through the API, if
+	// any.
+	UserID   int    `json:"user-id,omitempty"`
+	ChangeID string `json:"change-id,omitempty"`
+	// Rollback is the revision that the databag was rolled back to, if the
+	// change was a rollback.
+	Rollback int         `json:"rollback,omitempty"`
+	Diff     []DiffEntry `json:"diff"`
+}
+
+// DiffEntry is the change of a single value in a databag. Lists are compared
+// and recorded as a whole.
+type DiffEntry struct {
+	Path string `json:"path"`
+	// Old is the previous value or nil if the value was added.
+	Old any `json:"old,omitempty"`
+	// New is the new value or nil if the value was removed.
+	New any `json:"new,omitempty"`
+}
+
+func readDatabagRevisions(st *state.State) (map[string]map[string][]*DatabagRevision, error) {
+	var revisions map[string]map[string][]*DatabagRevision
+	err := st.Get("confdb-databag-revisions", &revisions)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if revisions == nil {
+		revisions = make(map[string]map[string][]*DatabagRevision)
+	}
+	return revisions, nil
+}
+
+// DatabagRevisions returns the recorded history of the databag of the given
+// confdb-schema, from the oldest to the latest revision. Only the latest
+// revisions are kept.
+func DatabagRevisions(st *state.State, account, schema string) ([]*DatabagRevision, error) {
+	revisions, err := readDatabagRevisions(st)
+	if err != nil {
+		return nil, err
+	}
+	return revisions[account][schema], nil
+}
+
+// recordDatabagRevision adds a revision with the differences between the old
+// and new databag to the history of the databag committed by the given task.
+func recordDatabagRevision(t *state.Task, account, schema string, oldBag, newBag confdb.JSONDatabag) error {
+	diff, err := diffDatabags(oldBag, newBag)
+	if err != nil {
+		return fmt.Errorf("cannot compute changes to databag: %v", err)
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+
+	st := t.State()
+	rev := &DatabagRevision{
+		Time:     timeNow(),
+		ChangeID: t.Change().ID(),
+		Diff:     diff,
+	}
+	for key, dest := range map[string]any{
+		"view":              &rev.View,
+		"calling-snap":      &rev.Snap,
+		"user-id":           &rev.UserID,
+		"rollback-revision": &rev.Rollback,
+	} {
+		if err := t.Get(key, dest); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+	}
+
+	revisions, err := readDatabagRevisions(st)
+	if err != nil {
+		return err
+	}
+	if revisions[account] == nil {
+		revisions[account] = make(map[string][]*DatabagRevision)
+	}
+
+	history := revisions[account][schema]
+	rev.Revision = 1
+	if len(history) > 0 {
+		rev.Revision = history[len(history)-1].Revision + 1
+	}
+	history = append(history, rev)
+	if len(history) > maxDatabagRevisions {
+		history = history[len(history)-maxDatabagRevisions:]
+	}
+
+	revisions[account][schema] = history
+	st.Set("confdb-databag-revisions", revisions)
+	return nil
+}
+
+// diffDatabags returns the values that differ between the two databags,
+// sorted by path.
+func diffDatabags(oldBag, newBag confdb.JSONDatabag) ([]DiffEntry, error) {
+	oldValues, err := flattenDatabag(oldBag)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := flattenDatabag(newBag)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff []DiffEntry
+	for path, oldVal := range oldValues {
+		newVal, ok := newValues[path]
+		if ok && reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		diff = append(diff, DiffEntry{Path: path, Old: oldVal, New: newVal})
+	}
+	for path, newVal := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			diff = append(diff, DiffEntry{Path: path, New: newVal})
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Path < diff[j].Path })
+	return diff, nil
+}
+
+// flattenDatabag maps the dotted path of each value in the databag to the
+// value. Maps are traversed while other values, including lists and empty
+// maps, are kept whole.
+func flattenDatabag(bag confdb.JSONDatabag) (map[string]any, error) {
+	values := make(map[string]any)
+	if len(bag) == 0 {
+		return values, nil
+	}
+
+	data, err := bag.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	var flatten func(prefix []string, val any)
+	flatten = func(prefix []string, val any) {
+		if m, ok := val.(map[string]any); ok && (len(m) > 0 || len(prefix) == 0) {
+			for k, v := range m {
+				flatten(append(prefix[:len(prefix):len(prefix)], k), v)
+			}
+			return
+		}
+		values[strings.Join(prefix, ".")] = val
+	}
+	flatten(nil, root)
+
+	return values, nil
+}
+
+// databagAtRevision reconstructs the databag as it was right after the given
+// revision was committed, by reverting the later revisions from the current
+// databag.
+func databagAtRevision(current confdb.JSONDatabag, history []*DatabagRevision, revision int) (confdb.JSONDatabag, error) {
+	idx := -1
+	for i, rev := range history {
+		if rev.Revision == revision {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return nil, fmt.Errorf("revision %d is not in the history", revision)
+	}
+
+	bag := current.Copy()
+	for i := len(history) - 1; i > idx; i-- {
+		const revert = true
+		if err := applyDiff(bag, history[i].Diff, revert); err != nil {
+			return nil, err
+		}
+	}
+
+	return bag, nil
+}
+
+// applyDiff writes the new values of the diff into the databag or, if revert
+// is true, restores the old ones. Values are removed before others are written
+// so that replacing a map with a value, or the other way around, works in
+// either direction.
+func applyDiff(bag confdb.Databag, diff []DiffEntry, revert bool) error {
+	value := func(entry DiffEntry) any {
+		if revert {
+			return entry.Old
+		}
+		return entry.New
+	}
+
+	for _, unset := range []bool{true, false} {
+		for _, entry := range diff {
+			val := value(entry)
+			if (val == nil) != unset {
+				continue
+			}
+
+			accs, err := confdb.ParsePathIntoAccessors(entry.Path, confdb.ParseOptions{})
+			if err != nil {
+				return fmt.Errorf("internal error: cannot parse path %q: %v", entry.Path, err)
+			}
+
+			if unset {
+				err = bag.Unset(accs)
+			} else {
+				err = bag.Set(accs, val)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RollbackDatabag schedules a change which restores the databag of the view's
+// confdb-schema to the data it held at the given revision. The rollback is
+// committed as a regular write through the view, so the custodians'
+// change-view and save-view hooks and the observers' observe-view hooks run as
+// for any other write. The rollback is recorded as a new revision. Returns the
+// change ID.
+func RollbackDatabag(ctx context.Context, st *state.State, view *confdb.View, revision int, userID int) (changeID string, err error) {
+	account, schema := view.Schema().Account, view.Schema().Name
+	if view.Schema().IsSystem() {
+		return "", fmt.Errorf("cannot roll back confdb %s/%s: system confdbs have no history", account, schema)
+	}
+
+	history, err := DatabagRevisions(st, account, schema)
+	if err != nil {
+		return "", fmt.Errorf("cannot roll back confdb %s/%s: %v", account, schema, err)
+	}
+
+	summary := fmt.Sprintf("Roll back confdb %s/%s to revision %d", account, schema, revision)
+	chg, commitTask, err := writeConfdb(ctx, st, view, summary, userID, func(tx *Transaction) error {
+		target, err := databagAtRevision(tx.previous, history, revision)
+		if err != nil {
+			return fmt.Errorf("cannot roll back confdb %s/%s: %v", account, schema, err)
+		}
+
+		diff, err := diffDatabags(tx.previous, target)
+		if err != nil {
+			return fmt.Errorf("cannot roll back confdb %s/%s: %v", account, schema, err)
+		}
+		if len(diff) == 0 {
+			return fmt.Errorf("cannot roll back confdb %s/%s: data already matches revision %d", account, schema, revision)
+		}
+
+		const revert = false
+		return applyDiff(tx, diff, revert)
+	})
+	if err != nil {
+		return "", err
+	}
+	commitTask.Set("rollback-revision", revision)
+
+	return chg.ID(), nil
+}
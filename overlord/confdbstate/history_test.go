@@ -0,0 +1,259 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *confdbTestSuite) writeAndSettle(c *C, values map[string]any, userID int) string {
+	view := s.dbSchema.View("setup-wifi")
+	chgID, err := confdbstate.WriteConfdbAsUser(context.Background(), s.state, view, values, userID)
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	s.o.Settle(5 * time.Second)
+	s.state.Lock()
+
+	c.Assert(s.state.Change(chgID).Status(), Equals, state.DoneStatus)
+	return chgID
+}
+
+func (s *confdbTestSuite) TestDatabagRevisionsRecorded(c *C) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := confdbstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+
+	revs, err := confdbstate.DatabagRevisions(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(revs, HasLen, 0)
+
+	firstChg := s.writeAndSettle(c, map[string]any{"ssid": "foo", "ssids": []any{"foo"}}, 0)
+	secondChg := s.writeAndSettle(c, map[string]any{"ssid": "bar", "ssids": nil, "password": "secret"}, 42)
+	// writes which don't change the data aren't recorded
+	s.writeAndSettle(c, map[string]any{"ssid": "bar"}, 0)
+
+	revs, err = confdbstate.DatabagRevisions(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, DeepEquals, []*confdbstate.DatabagRevision{{
+		Revision: 1,
+		Time:     now,
+		View:     "setup-wifi",
+		ChangeID: firstChg,
+		Diff: []confdbstate.DiffEntry{
+			{Path: "wifi.ssid", New: "foo"},
+			{Path: "wifi.ssids", New: []any{"foo"}},
+		},
+	}, {
+		Revision: 2,
+		Time:     now,
+		View:     "setup-wifi",
+		UserID:   42,
+		ChangeID: secondChg,
+		Diff: []confdbstate.DiffEntry{
+			{Path: "wifi.psk", New: "secret"},
+			{Path: "wifi.ssid", Old: "foo", New: "bar"},
+			{Path: "wifi.ssids", Old: []any{"foo"}},
+		},
+	}})
+
+	// other databags have their own history
+	revs, err = confdbstate.DatabagRevisions(s.state, s.devAccID, "other")
+	c.Assert(err, IsNil)
+	c.Check(revs, HasLen, 0)
+}
+
+func (s *confdbTestSuite) TestDatabagRevisionsBounded(c *C) {
+	restore := confdbstate.MockMaxDatabagRevisions(2)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+
+	for i := 1; i <= 3; i++ {
+		s.writeAndSettle(c, map[string]any{"ssid": fmt.Sprintf("ssid-%d", i)}, 0)
+	}
+
+	revs, err := confdbstate.DatabagRevisions(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 2)
+	c.Check(revs[0].Revision, Equals, 2)
+	c.Check(revs[0].Diff, DeepEquals, []confdbstate.DiffEntry{{Path: "wifi.ssid", Old: "ssid-1", New: "ssid-2"}})
+	c.Check(revs[1].Revision, Equals, 3)
+}
+
+func (s *confdbTestSuite) TestDatabagRevisionCallingSnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, []string{"test-snap"})
+
+	tx, err := confdbstate.NewTransaction(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(tx.Set(parsePath(c, "wifi.ssid"), "foo"), IsNil)
+
+	view := s.dbSchema.View("setup-wifi")
+	ts, commitTask, _, err := confdbstate.CreateChangeConfdbTasks(s.state, tx, view, "test-snap")
+	c.Assert(err, IsNil)
+
+	chg := s.state.NewChange("set-confdb", "")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.o.Settle(5 * time.Second)
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+
+	revs, err := confdbstate.DatabagRevisions(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 1)
+	c.Check(revs[0].Snap, Equals, "test-snap")
+	c.Check(revs[0].ChangeID, Equals, commitTask.Change().ID())
+}
+
+func (s *confdbTestSuite) TestRollbackDatabag(c *C) {
+	hooks, restore := s.mockConfdbHooks()
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": allHooks}, nil)
+
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+	s.writeAndSettle(c, map[string]any{"ssid": "bar", "ssids": []any{"bar"}}, 0)
+	s.writeAndSettle(c, map[string]any{"password": "secret"}, 0)
+	*hooks = nil
+
+	view := s.dbSchema.View("setup-wifi")
+	chgID, err := confdbstate.RollbackDatabag(context.Background(), s.state, view, 1, 42)
+	c.Assert(err, IsNil)
+
+	chg := s.state.Change(chgID)
+	c.Check(chg.Kind(), Equals, "set-confdb")
+	c.Check(chg.Summary(), Equals, fmt.Sprintf("Roll back confdb %s/network to revision 1", s.devAccID))
+
+	s.state.Unlock()
+	s.o.Settle(5 * time.Second)
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	// the hooks run for the rollback as for any other write
+	c.Check(*hooks, DeepEquals, []string{"change-view-setup", "save-view-setup", "observe-view-setup"})
+
+	bag, err := confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	data, err := bag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"wifi":{"ssid":"foo"}}`)
+
+	revs, err := confdbstate.DatabagRevisions(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Assert(revs, HasLen, 4)
+	c.Check(revs[3].Revision, Equals, 4)
+	c.Check(revs[3].Rollback, Equals, 1)
+	c.Check(revs[3].UserID, Equals, 42)
+	c.Check(revs[3].ChangeID, Equals, chgID)
+	c.Check(revs[3].Diff, DeepEquals, []confdbstate.DiffEntry{
+		{Path: "wifi.psk", Old: "secret"},
+		{Path: "wifi.ssid", Old: "bar", New: "foo"},
+		{Path: "wifi.ssids", Old: []any{"bar"}},
+	})
+}
+
+func (s *confdbTestSuite) TestRollbackDatabagErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	view := s.dbSchema.View("setup-wifi")
+
+	_, err := confdbstate.RollbackDatabag(context.Background(), s.state, view, 1, 0)
+	c.Check(err, ErrorMatches, fmt.Sprintf("cannot roll back confdb %s/network: revision 1 is not in the history", s.devAccID))
+
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	_, err = confdbstate.RollbackDatabag(context.Background(), s.state, view, 1, 0)
+	c.Check(err, ErrorMatches, fmt.Sprintf("cannot roll back confdb %s/network: data already matches revision 1", s.devAccID))
+
+	// nothing was scheduled and other writes can proceed
+	c.Check(s.state.Changes(), HasLen, 1)
+	s.writeAndSettle(c, map[string]any{"ssid": "bar"}, 0)
+}
+
+func (s *confdbTestSuite) TestDatabagAtRevision(c *C) {
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "a"), "scalar"), IsNil)
+	c.Assert(bag.Set(parsePath(c, "c"), []any{"x"}), IsNil)
+
+	history := []*confdbstate.DatabagRevision{{
+		Revision: 3,
+		Diff:     []confdbstate.DiffEntry{{Path: "a.b", New: "nested"}},
+	}, {
+		// a map was replaced by a value
+		Revision: 4,
+		Diff: []confdbstate.DiffEntry{
+			{Path: "a", New: "scalar"},
+			{Path: "a.b", Old: "nested"},
+		},
+	}, {
+		Revision: 5,
+		Diff:     []confdbstate.DiffEntry{{Path: "c", New: []any{"x"}}},
+	}}
+
+	for _, tc := range []struct {
+		revision int
+		data     string
+	}{
+		{5, `{"a":"scalar","c":["x"]}`},
+		{4, `{"a":"scalar"}`},
+		{3, `{"a":{"b":"nested"}}`},
+	} {
+		atRev, err := confdbstate.DatabagAtRevision(bag, history, tc.revision)
+		c.Assert(err, IsNil)
+		data, err := atRev.Data()
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, tc.data, Commentf("revision %d", tc.revision))
+	}
+
+	// the current databag isn't modified
+	data, err := bag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"a":"scalar","c":["x"]}`)
+
+	_, err = confdbstate.DatabagAtRevision(bag, history, 2)
+	c.Check(err, ErrorMatches, "revision 2 is not in the history")
+}
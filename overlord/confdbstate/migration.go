@@ -0,0 +1,363 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/snap"
+)
+
+var migrateConfdbChangeKind = swfeats.RegisterChangeKind("migrate-confdb")
+
+// schemaRevision tracks the confdb-schema revision that a databag's data
+// conforms to. Until the data is migrated to a newer revision, that revision
+// stays in effect for accessing the databag.
+type schemaRevision struct {
+	Revision int `json:"revision"`
+	// Assertion is the encoded confdb-schema assertion of the revision in
+	// effect, since the assertion database only keeps the latest one.
+	Assertion string `json:"assertion"`
+	// Migrating is the revision that the data is being migrated to by the
+	// change with ChangeID, if any.
+	Migrating int    `json:"migrating,omitempty"`
+	ChangeID  string `json:"change-id,omitempty"`
+	// Rejected is the latest revision whose migration failed. It's not retried
+	// until a newer revision is available.
+	Rejected int `json:"rejected,omitempty"`
+}
+
+func readSchemaRevisions(st *state.State) (map[string]map[string]*schemaRevision, error) {
+	var revisions map[string]map[string]*schemaRevision
+	err := st.Get("confdb-schema-revisions", &revisions)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if revisions == nil {
+		revisions = make(map[string]map[string]*schemaRevision)
+	}
+	return revisions, nil
+}
+
+func updateSchemaRevision(st *state.State, account, schemaName string, update func(rec *schemaRevision)) error {
+	revisions, err := readSchemaRevisions(st)
+	if err != nil {
+		return err
+	}
+	if revisions[account] == nil {
+		revisions[account] = make(map[string]*schemaRevision)
+	}
+	if revisions[account][schemaName] == nil {
+		revisions[account][schemaName] = &schemaRevision{}
+	}
+
+	update(revisions[account][schemaName])
+	st.Set("confdb-schema-revisions", revisions)
+	return nil
+}
+
+// acceptSchemaRevision makes the confdb-schema the one in effect for its
+// databag.
+func acceptSchemaRevision(st *state.State, as *asserts.ConfdbSchema) error {
+	return updateSchemaRevision(st, as.AccountID(), as.Name(), func(rec *schemaRevision) {
+		*rec = schemaRevision{
+			Revision:  as.Revision(),
+			Assertion: string(asserts.Encode(as)),
+		}
+	})
+}
+
+// rejectSchemaRevision records that the databag's data cannot be migrated to
+// the given revision, which keeps the current revision in effect.
+func rejectSchemaRevision(st *state.State, account, schemaName string, revision int, reason string) error {
+	st.Warnf("cannot migrate confdb %s/%s to revision %d, keeping the previous revision: %s", account, schemaName, revision, reason)
+	return updateSchemaRevision(st, account, schemaName, func(rec *schemaRevision) {
+		rec.Rejected = revision
+		rec.Migrating = 0
+		rec.ChangeID = ""
+	})
+}
+
+// migrationInProgress returns whether the change migrating the databag is
+// still running.
+func (rec *schemaRevision) migrationInProgress(st *state.State) bool {
+	if rec.Migrating == 0 {
+		return false
+	}
+	chg := st.Change(rec.ChangeID)
+	return chg != nil && !chg.IsReady()
+}
+
+// confdbSchemaAssertion returns the confdb-schema assertion in effect for
+// accessing the databag. That's the latest revision unless the databag's data
+// hasn't been migrated to it yet, in which case it's the revision the data
+// was last committed under. While a migration is in progress the revision
+// being migrated to is returned, so the migration hooks access the data
+// through its views; other accesses are blocked by the migration's
+// transaction.
+func confdbSchemaAssertion(st *state.State, account, schemaName string) (*asserts.ConfdbSchema, error) {
+	latest, err := AssertstateConfdbSchema(st, account, schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := readSchemaRevisions(st)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := revisions[account][schemaName]
+	if rec == nil || latest.Revision() <= rec.Revision ||
+		(rec.Migrating == latest.Revision() && rec.migrationInProgress(st)) {
+		return latest, nil
+	}
+
+	as, err := asserts.Decode([]byte(rec.Assertion))
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot decode confdb-schema %s/%s revision %d: %v", account, schemaName, rec.Revision, err)
+	}
+	return as.(*asserts.ConfdbSchema), nil
+}
+
+func (m *ConfdbManager) ensureSchemaMigration(account, schemaName string, bag confdb.JSONDatabag) error {
+	st := m.state
+	latest, err := AssertstateConfdbSchema(st, account, schemaName)
+	if err != nil {
+		if errors.Is(err, &asserts.NotFoundError{}) {
+			return nil
+		}
+		return err
+	}
+
+	revisions, err := readSchemaRevisions(st)
+	if err != nil {
+		return err
+	}
+
+	rec := revisions[account][schemaName]
+	if rec == nil {
+		// the data predates tracking the schema revision so it's assumed to
+		// conform to the one we have
+		return acceptSchemaRevision(st, latest)
+	}
+
+	if rec.Migrating != 0 {
+		if rec.migrationInProgress(st) {
+			return nil
+		}
+
+		// a successful migration accepts the revision on commit, so the change
+		// failed or was pruned before completing
+		reason := "migration did not complete"
+		if chg := st.Change(rec.ChangeID); chg != nil && chg.Err() != nil {
+			reason = chg.Err().Error()
+		}
+		if err := rejectSchemaRevision(st, account, schemaName, rec.Migrating, reason); err != nil {
+			return err
+		}
+		// re-read it, so a newer revision can be considered
+		return m.ensureSchemaMigration(account, schemaName, bag)
+	}
+
+	if latest.Revision() <= rec.Revision || latest.Revision() == rec.Rejected {
+		return nil
+	}
+
+	txs, _, err := getOngoingTxs(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+	if !txs.CanStartWriteTx() {
+		// try again once the databag isn't being accessed
+		return nil
+	}
+
+	tx, err := NewTransaction(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+
+	changed, err := applyMigrations(tx, latest.Migrations())
+	if err != nil {
+		return rejectSchemaRevision(st, account, schemaName, latest.Revision(), err.Error())
+	}
+
+	custodians, hooks, err := getMigrationHooks(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+
+	if !changed && len(custodians) == 0 {
+		data, err := bag.Data()
+		if err != nil {
+			return err
+		}
+
+		if err := latest.Schema().DatabagSchema.Validate(data); err != nil {
+			return rejectSchemaRevision(st, account, schemaName, latest.Revision(), fmt.Sprintf("data is incompatible and no migration is declared: %v", err))
+		}
+
+		// the data already conforms to the new revision
+		return acceptSchemaRevision(st, latest)
+	}
+
+	ts, commitTask := createMigrationTasks(st, tx, latest.Revision(), custodians, hooks)
+	if err := setWriteTransaction(st, account, schemaName, commitTask.ID(), ""); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Migrate confdb %s/%s to revision %d", account, schemaName, latest.Revision())
+	chg := st.NewChange(migrateConfdbChangeKind, summary)
+	chg.AddAll(ts)
+	ensureNow(st)
+
+	return updateSchemaRevision(st, account, schemaName, func(rec *schemaRevision) {
+		rec.Migrating = latest.Revision()
+		rec.ChangeID = chg.ID()
+	})
+}
+
+// applyMigrations moves or removes the values according to the migrations,
+// in order. Migrations of paths without data are skipped. Returns whether the
+// databag was modified.
+func applyMigrations(bag confdb.Databag, migrations []asserts.ConfdbMigration) (changed bool, err error) {
+	for _, mig := range migrations {
+		from, err := confdb.ParsePathIntoAccessors(mig.From, confdb.ParseOptions{})
+		if err != nil {
+			return false, fmt.Errorf("internal error: cannot parse path %q: %v", mig.From, err)
+		}
+
+		val, err := bag.Get(from, nil)
+		if err != nil {
+			if errors.Is(err, &confdb.NoDataError{}) {
+				continue
+			}
+			return false, fmt.Errorf("cannot migrate %q: %v", mig.From, err)
+		}
+
+		if mig.To != "" {
+			to, err := confdb.ParsePathIntoAccessors(mig.To, confdb.ParseOptions{})
+			if err != nil {
+				return false, fmt.Errorf("internal error: cannot parse path %q: %v", mig.To, err)
+			}
+
+			if err := bag.Set(to, val); err != nil {
+				return false, fmt.Errorf("cannot migrate %q to %q: %v", mig.From, mig.To, err)
+			}
+		}
+
+		if err := bag.Unset(from); err != nil {
+			return false, fmt.Errorf("cannot migrate %q: %v", mig.From, err)
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// getMigrationHooks returns the sorted list of snaps with connected custodian
+// plugs for any view of the confdb-schema which define a migrate-view hook for
+// the plug, mapped to the hook names.
+func getMigrationHooks(st *state.State, account, schemaName string) ([]string, map[string]string, error) {
+	repo := ifacerepo.Get(st)
+
+	var custodians []string
+	hooks := make(map[string]string)
+	for _, plug := range repo.AllPlugs("confdb") {
+		if role, ok := plug.Attrs["role"]; !ok || role != "custodian" {
+			continue
+		}
+
+		plugAccount, plugSchema, _, err := snap.ConfdbPlugAttrs(plug)
+		if err != nil {
+			return nil, nil, err
+		}
+		if plugAccount != account || plugSchema != schemaName {
+			continue
+		}
+
+		hookName := "migrate-view-" + plug.Name
+		if _, ok := plug.Snap.Hooks[hookName]; !ok {
+			continue
+		}
+
+		conns, err := repo.Connected(plug.Snap.InstanceName(), plug.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(conns) == 0 {
+			continue
+		}
+
+		snapName := plug.Snap.InstanceName()
+		if _, ok := hooks[snapName]; !ok {
+			custodians = append(custodians, snapName)
+		}
+		// like for other hooks, a single plug per snap is considered
+		hooks[snapName] = hookName
+	}
+	sort.Strings(custodians)
+
+	return custodians, hooks, nil
+}
+
+// createMigrationTasks returns the tasks to run the custodians' migrate-view
+// hooks on the transaction and commit it under the given revision.
+func createMigrationTasks(st *state.State, tx *Transaction, revision int, custodians []string, hooks map[string]string) (ts *state.TaskSet, commitTask *state.Task) {
+	ts = state.NewTaskSet()
+	linkTask := func(t *state.Task) {
+		tasks := ts.Tasks()
+		if len(tasks) > 0 {
+			t.WaitFor(tasks[len(tasks)-1])
+		}
+		ts.AddTask(t)
+	}
+
+	// if the migration errors, clear the tx from the state
+	clearTxOnErrTask := st.NewTask("clear-confdb-tx-on-error", "Clears the ongoing confdb transaction from state (on error)")
+	linkTask(clearTxOnErrTask)
+
+	for _, name := range custodians {
+		const ignoreError = false
+		linkTask(setupConfdbHook(st, name, hooks[name], ignoreError))
+	}
+
+	commitTask = st.NewTask("commit-confdb-tx", fmt.Sprintf("Commit migration of confdb %s/%s to revision %d", tx.ConfdbAccount, tx.ConfdbName, revision))
+	commitTask.Set("confdb-transaction", tx)
+	commitTask.Set("migrate-to-revision", revision)
+
+	for _, t := range ts.Tasks() {
+		t.Set("tx-task", commitTask.ID())
+	}
+	linkTask(commitTask)
+
+	clearTxTask := st.NewTask("clear-confdb-tx", "Clears the ongoing confdb transaction from state")
+	linkTask(clearTxTask)
+	clearTxTask.Set("tx-task", commitTask.ID())
+
+	return ts, commitTask
+}
@@ -0,0 +1,335 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// addNetworkRevision adds a revision of the "network" confdb-schema which
+// stores the "ssid" of the setup-wifi view under ssidStorage and adds a
+// setup-name view.
+func (s *confdbTestSuite) addNetworkRevision(c *C, revision int, ssidStorage, body string, migrations []any) {
+	headers := map[string]any{
+		"authority-id": s.devAccID,
+		"account-id":   s.devAccID,
+		"name":         "network",
+		"revision":     strconv.Itoa(revision),
+		"views": map[string]any{
+			"setup-wifi": map[string]any{
+				"rules": []any{
+					map[string]any{"request": "ssid", "storage": ssidStorage},
+					map[string]any{"request": "password", "storage": "wifi.psk", "access": "write"},
+				},
+			},
+			"setup-name": map[string]any{
+				"rules": []any{
+					map[string]any{"request": "name", "storage": "wifi.name"},
+				},
+			},
+		},
+		"timestamp": "2030-11-06T09:16:26Z",
+	}
+	if migrations != nil {
+		headers["migrations"] = migrations
+	}
+
+	as, err := s.signingDB.Sign(asserts.ConfdbSchemaType, headers, []byte(body), "")
+	c.Assert(err, IsNil)
+	c.Assert(assertstate.Add(s.state, as), IsNil)
+}
+
+const renamedSSIDSchema = `{
+  "storage": {
+    "schema": {
+      "wifi": {
+        "schema": {
+          "name": "string",
+          "psk": "string"
+        }
+      }
+    }
+  }
+}`
+
+var renameSSIDMigration = []any{map[string]any{"from": "wifi.ssid", "to": "wifi.name"}}
+
+func (s *confdbTestSuite) settle(c *C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+}
+
+func (s *confdbTestSuite) checkDatabag(c *C, expected string) {
+	bag, err := confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	data, err := bag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, expected)
+}
+
+func (s *confdbTestSuite) migrationChanges() []*state.Change {
+	var chgs []*state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "migrate-confdb" {
+			chgs = append(chgs, chg)
+		}
+	}
+	return chgs
+}
+
+func (s *confdbTestSuite) TestSchemaRevisionCompatible(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	s.addNetworkRevision(c, 1, "wifi.ssid", `{
+  "storage": {
+    "schema": {
+      "wifi": {
+        "schema": {
+          "name": "string",
+          "psk": "string",
+          "ssid": "string"
+        }
+      }
+    }
+  }
+}`, nil)
+
+	// the new revision isn't in effect until it's checked
+	_, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-name")
+	c.Assert(err, testutil.ErrorIs, &confdbstate.NoViewError{})
+
+	s.settle(c)
+
+	// the data conforms to the new revision so it's accepted without migrating
+	view, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-name")
+	c.Assert(err, IsNil)
+	c.Check(view.Name, Equals, "setup-name")
+	c.Check(s.migrationChanges(), HasLen, 0)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	s.checkDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+}
+
+func (s *confdbTestSuite) TestSchemaRevisionIncompatibleRejected(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	s.addNetworkRevision(c, 1, "wifi.name", renamedSSIDSchema, nil)
+	s.settle(c)
+
+	c.Check(s.migrationChanges(), HasLen, 0)
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Matches, fmt.Sprintf(`cannot migrate confdb %s/network to revision 1, keeping the previous revision: data is incompatible and no migration is declared: .*`, s.devAccID))
+
+	// the previous revision stays in effect
+	_, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-name")
+	c.Assert(err, testutil.ErrorIs, &confdbstate.NoViewError{})
+	s.checkDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+
+	// and can still be written to
+	s.writeAndSettle(c, map[string]any{"ssid": "bar"}, 0)
+	s.checkDatabag(c, `{"wifi":{"ssid":"bar"}}`)
+}
+
+func (s *confdbTestSuite) TestSchemaMigrationDeclarative(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo", "password": "secret"}, 0)
+
+	s.addNetworkRevision(c, 1, "wifi.name", renamedSSIDSchema, renameSSIDMigration)
+	s.settle(c)
+
+	chgs := s.migrationChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Summary(), Equals, fmt.Sprintf("Migrate confdb %s/network to revision 1", s.devAccID))
+	c.Check(chgs[0].Status(), Equals, state.DoneStatus)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+
+	s.checkDatabag(c, `{"wifi":{"name":"foo","psk":"secret"}}`)
+
+	view, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-wifi")
+	c.Assert(err, IsNil)
+	bag, err := confdbstate.ReadDatabag(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	val, err := confdbstate.GetViaView(bag, view, []string{"ssid"}, nil, 0)
+	c.Assert(err, IsNil)
+	c.Check(val, DeepEquals, map[string]any{"ssid": "foo"})
+
+	// the transaction was cleared so other accesses can proceed
+	txs, _, err := confdbstate.GetOngoingTxs(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(txs.CanStartWriteTx(), Equals, true)
+}
+
+func (s *confdbTestSuite) TestSchemaMigrationHook(c *C) {
+	var hooks []string
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		ctx.Lock()
+		defer ctx.Unlock()
+		hooks = append(hooks, ctx.HookName())
+
+		// the hook accesses the data through the views of the new revision,
+		// after the declarative migrations were applied
+		view, err := confdbstate.GetView(ctx.State(), s.devAccID, "network", "setup-wifi")
+		if err != nil {
+			return nil, err
+		}
+		tx, err := confdbstate.ReadConfdbFromSnap(ctx, view, []string{"ssid"}, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		val, err := confdbstate.GetViaView(tx, view, []string{"ssid"}, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		ssid := val.(map[string]any)["ssid"].(string)
+		return nil, confdbstate.WriteConfdbFromSnap(ctx, view, map[string]any{"ssid": ssid + "-migrated"}, nil)
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": migrateView}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	s.addNetworkRevision(c, 1, "wifi.name", renamedSSIDSchema, renameSSIDMigration)
+	s.settle(c)
+
+	c.Check(hooks, DeepEquals, []string{"migrate-view-setup"})
+	chgs := s.migrationChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Status(), Equals, state.DoneStatus)
+
+	s.checkDatabag(c, `{"wifi":{"name":"foo-migrated"}}`)
+}
+
+func (s *confdbTestSuite) TestSchemaMigrationHookFailsRejected(c *C) {
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": migrateView}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	s.addNetworkRevision(c, 1, "wifi.name", renamedSSIDSchema, renameSSIDMigration)
+	s.settle(c)
+	// the failure is noticed on the next ensure
+	s.settle(c)
+
+	chgs := s.migrationChanges()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Status(), Equals, state.ErrorStatus)
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Matches, fmt.Sprintf(`cannot migrate confdb %s/network to revision 1, keeping the previous revision: (?s).*boom.*`, s.devAccID))
+
+	// the data wasn't modified and the previous revision stays in effect
+	s.checkDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+	_, err := confdbstate.GetView(s.state, s.devAccID, "network", "setup-name")
+	c.Assert(err, testutil.ErrorIs, &confdbstate.NoViewError{})
+
+	txs, _, err := confdbstate.GetOngoingTxs(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(txs.CanStartWriteTx(), Equals, true)
+
+	// the rejected revision isn't retried
+	s.settle(c)
+	c.Check(s.migrationChanges(), HasLen, 1)
+
+	// but a newer one is
+	s.addNetworkRevision(c, 2, "wifi.name", renamedSSIDSchema, renameSSIDMigration)
+	s.settle(c)
+	c.Check(s.migrationChanges(), HasLen, 2)
+}
+
+func (s *confdbTestSuite) TestSchemaMigrationWaitsForOngoingTransaction(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	c.Assert(confdbstate.SetWriteTransaction(s.state, s.devAccID, "network", "123", ""), IsNil)
+
+	s.addNetworkRevision(c, 1, "wifi.name", renamedSSIDSchema, renameSSIDMigration)
+	s.settle(c)
+	c.Check(s.migrationChanges(), HasLen, 0)
+
+	c.Assert(confdbstate.UnsetOngoingTransaction(s.state, s.devAccID, "network", "123"), IsNil)
+	s.settle(c)
+	c.Check(s.migrationChanges(), HasLen, 1)
+	s.checkDatabag(c, `{"wifi":{"name":"foo"}}`)
+}
+
+func (s *confdbTestSuite) TestApplyMigrations(c *C) {
+	bag := confdb.NewJSONDatabag()
+	c.Assert(bag.Set(parsePath(c, "a.b"), "foo"), IsNil)
+	c.Assert(bag.Set(parsePath(c, "old"), "bar"), IsNil)
+
+	changed, err := confdbstate.ApplyMigrations(bag, []asserts.ConfdbMigration{
+		{From: "a.b", To: "c"},
+		// paths without data are skipped
+		{From: "missing", To: "d"},
+		{From: "old"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	data, err := bag.Data()
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"c":"foo"}`)
+
+	changed, err = confdbstate.ApplyMigrations(bag, []asserts.ConfdbMigration{{From: "missing", To: "d"}})
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+
+	_, err = confdbstate.ApplyMigrations(bag, []asserts.ConfdbMigration{{From: "c.x", To: "e"}})
+	c.Check(err, ErrorMatches, `cannot migrate "c.x": .*`)
+}
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/snapcore/snapd/confdb"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ErrDatabagBusy is returned when a databag cannot be replaced because it's
+// being accessed.
+var ErrDatabagBusy = errors.New("databag is being accessed")
+
+// CommitObserver is called with the state locked after a transaction changed
+// the data of the databag of the given confdb-schema.
+type CommitObserver func(st *state.State, account, schemaName string)
+
+var commitObservers []CommitObserver
+
+// AddCommitObserver registers a function called after each committed
+// transaction which changed the data of a databag. It isn't called when a
+// databag is replaced with WriteReplicatedDatabag.
+func AddCommitObserver(f CommitObserver) {
+	commitObservers = append(commitObservers, f)
+}
+
+func notifyCommitObservers(st *state.State, account, schemaName string, oldBag, newBag confdb.JSONDatabag) error {
+	diff, err := diffDatabags(oldBag, newBag)
+	if err != nil {
+		return err
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+
+	for _, f := range commitObservers {
+		f(st, account, schemaName)
+	}
+	return nil
+}
+
+// DatabagData returns the JSON encoded data of the databag of the given
+// confdb-schema.
+func DatabagData(st *state.State, account, schemaName string) ([]byte, error) {
+	bag, err := readDatabag(st, account, schemaName)
+	if err != nil {
+		return nil, err
+	}
+	return bag.Data()
+}
+
+// WriteReplicatedDatabag replaces the databag of the given confdb-schema with
+// the JSON encoded data, as replicated from another device. The data must be
+// valid for the confdb-schema revision in effect and no access to the databag
+// must be ongoing, otherwise ErrDatabagBusy is returned. Notices are added for
+// the views affected by the changed data, no hooks are run.
+func WriteReplicatedDatabag(st *state.State, account, schemaName string, data []byte) error {
+	if account == "system" {
+		return fmt.Errorf(`cannot replicate "system" confdb %s`, schemaName)
+	}
+
+	txs, _, err := getOngoingTxs(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+	if !txs.CanStartWriteTx() {
+		return ErrDatabagBusy
+	}
+
+	confdbAssert, err := confdbSchemaAssertion(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+	if err := confdbAssert.Schema().DatabagSchema.Validate(data); err != nil {
+		return fmt.Errorf("cannot replicate confdb %s/%s: %v", account, schemaName, err)
+	}
+
+	var newBag confdb.JSONDatabag
+	if err := json.Unmarshal(data, &newBag); err != nil {
+		return fmt.Errorf("cannot replicate confdb %s/%s: %v", account, schemaName, err)
+	}
+	if newBag == nil {
+		newBag = confdb.NewJSONDatabag()
+	}
+	oldBag, err := readDatabag(st, account, schemaName)
+	if err != nil {
+		return err
+	}
+	diff, err := diffDatabags(oldBag, newBag)
+	if err != nil {
+		return err
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+
+	if err := writeDatabag(st, newBag, account, schemaName); err != nil {
+		return err
+	}
+
+	paths := make([][]confdb.Accessor, 0, len(diff))
+	for _, entry := range diff {
+		accs, err := confdb.ParsePathIntoAccessors(entry.Path, confdb.ParseOptions{})
+		if err != nil {
+			return fmt.Errorf("internal error: cannot parse path %q: %v", entry.Path, err)
+		}
+		paths = append(paths, accs)
+	}
+	return addConfdbChangeNotices(st, confdbAssert.Schema(), paths, "")
+}
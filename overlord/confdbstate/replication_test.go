@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package confdbstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *confdbTestSuite) TestCommitObservers(c *C) {
+	var observed []string
+	restore := confdbstate.MockCommitObservers(nil)
+	defer restore()
+	confdbstate.AddCommitObserver(func(st *state.State, account, schemaName string) {
+		c.Check(st, Equals, s.state)
+		observed = append(observed, account+"/"+schemaName)
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+	c.Check(observed, DeepEquals, []string{s.devAccID + "/network"})
+
+	// committing the same data again changes nothing
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+	c.Check(observed, HasLen, 1)
+
+	// replicated data isn't observed
+	err := confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`{"wifi":{"ssid":"bar"}}`))
+	c.Assert(err, IsNil)
+	c.Check(observed, HasLen, 1)
+}
+
+func (s *confdbTestSuite) TestWriteReplicatedDatabag(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo", "password": "secret"}, 0)
+
+	filter := &state.NoticeFilter{Types: []state.NoticeType{state.ConfdbChangeNotice}}
+	before := len(s.state.Notices(filter))
+
+	err := confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`{"wifi":{"psk":"secret","ssid":"bar"}}`))
+	c.Assert(err, IsNil)
+	s.checkDatabag(c, `{"wifi":{"psk":"secret","ssid":"bar"}}`)
+
+	data, err := confdbstate.DatabagData(s.state, s.devAccID, "network")
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"wifi":{"psk":"secret","ssid":"bar"}}`)
+
+	notices := s.state.Notices(filter)
+	c.Assert(notices, HasLen, before)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], Equals, s.devAccID+"/network/setup-wifi")
+	c.Check(n["occurrences"], Equals, float64(2))
+	c.Check(n["last-data"], IsNil)
+
+	// the same data doesn't add notices
+	err = confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`{"wifi":{"psk":"secret","ssid":"bar"}}`))
+	c.Assert(err, IsNil)
+	n = noticeToMap(c, s.state.Notices(filter)[0])
+	c.Check(n["occurrences"], Equals, float64(2))
+}
+
+func (s *confdbTestSuite) TestWriteReplicatedDatabagErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupConfdbScenario(c, map[string]confdbHooks{"custodian-snap": noHooks}, nil)
+	s.writeAndSettle(c, map[string]any{"ssid": "foo"}, 0)
+
+	err := confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`{"wifi":{"ssid":1}}`))
+	c.Assert(err, ErrorMatches, `cannot replicate confdb .*/network: cannot accept element in "wifi.ssid": .*`)
+
+	err = confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`["foo"]`))
+	c.Assert(err, NotNil)
+
+	err = confdbstate.WriteReplicatedDatabag(s.state, "system", "validation-sets", []byte(`{}`))
+	c.Assert(err, ErrorMatches, `cannot replicate "system" confdb validation-sets`)
+
+	err = confdbstate.SetWriteTransaction(s.state, s.devAccID, "network", "10", "")
+	c.Assert(err, IsNil)
+	err = confdbstate.WriteReplicatedDatabag(s.state, s.devAccID, "network", []byte(`{"wifi":{"ssid":"bar"}}`))
+	c.Assert(err, Equals, confdbstate.ErrDatabagBusy)
+
+	s.checkDatabag(c, `{"wifi":{"ssid":"foo"}}`)
+}
@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	supportedConfigurations["core.cluster.rollout.canaries"] = true
+	supportedConfigurations["core.cluster.confdb.replicate"] = true
+}
+
+// validateClusterRollout validates the number of canary devices of each
+// subcluster which apply a new cluster state before the others, 0 disabling
+// staged rollouts.
+func validateClusterRollout(tr RunTransaction) error {
+	canariesStr, err := coreCfg(tr, "cluster.rollout.canaries")
+	if err != nil {
+		return err
+	}
+	if canariesStr == "" {
+		return nil
+	}
+	canaries, err := strconv.Atoi(canariesStr)
+	if err != nil || canaries < 0 {
+		return fmt.Errorf("cluster.rollout.canaries must be a non-negative number, not %q", canariesStr)
+	}
+	return nil
+}
+
+// validateClusterConfdbReplication validates the comma-separated list of
+// confdb-schemas, as "<account>/<confdb-schema>", whose databags are
+// replicated across the devices of the cluster.
+func validateClusterConfdbReplication(tr RunTransaction) error {
+	replicate, err := coreCfg(tr, "cluster.confdb.replicate")
+	if err != nil {
+		return err
+	}
+	if replicate == "" {
+		return nil
+	}
+	for _, ref := range strings.Split(replicate, ",") {
+		account, schema, ok := strings.Cut(strings.TrimSpace(ref), "/")
+		if !ok || account == "" || schema == "" || strings.Contains(schema, "/") {
+			return fmt.Errorf("cluster.confdb.replicate must be a comma-separated list of <account>/<confdb-schema>, not %q", replicate)
+		}
+		if account == "system" {
+			return fmt.Errorf("cannot replicate \"system\" confdb %s", schema)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type clusterSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&clusterSuite{})
+
+func (s *clusterSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	err := os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc/"), 0755)
+	c.Assert(err, IsNil)
+	err = os.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *clusterSuite) TestClusterRolloutCanaries(c *C) {
+	for _, value := range []any{"", "0", "2", 3} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"cluster.rollout.canaries": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%v", value))
+	}
+
+	for _, value := range []any{"-1", "two", 1.5} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"cluster.rollout.canaries": value,
+			},
+		})
+		c.Check(err, ErrorMatches, `cluster.rollout.canaries must be a non-negative number, not ".*"`, Commentf("%v", value))
+	}
+}
+
+func (s *clusterSuite) TestClusterConfdbReplicate(c *C) {
+	for _, value := range []string{"", "acc/network", "acc/network, other/wifi"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"cluster.confdb.replicate": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%v", value))
+	}
+
+	for _, value := range []string{"acc", "acc/", "/network", "acc/network/view", "acc/network,"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"cluster.confdb.replicate": value,
+			},
+		})
+		c.Check(err, ErrorMatches, `cluster.confdb.replicate must be a comma-separated list of <account>/<confdb-schema>, not ".*"`, Commentf("%v", value))
+	}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"cluster.confdb.replicate": "acc/network,system/validation-sets",
+		},
+	})
+	c.Check(err, ErrorMatches, `cannot replicate "system" confdb validation-sets`)
+}
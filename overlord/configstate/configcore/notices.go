@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.notices.archive.enabled"] = true
+	supportedConfigurations["core.notices.archive.segment-size"] = true
+	supportedConfigurations["core.notices.archive.segment-age"] = true
+	supportedConfigurations["core.notices.archive.retention"] = true
+}
+
+// validateNoticesArchiveSettings validates the notices archive options. The
+// options are only read when snapd starts, so there is nothing to apply.
+func validateNoticesArchiveSettings(tr RunTransaction) error {
+	if err := validateBoolFlag(tr, "notices.archive.enabled"); err != nil {
+		return err
+	}
+
+	sizeStr, err := coreCfg(tr, "notices.archive.segment-size")
+	if err != nil {
+		return err
+	}
+	if sizeStr != "" {
+		size, err := strutil.ParseByteSize(sizeStr)
+		if err != nil {
+			return fmt.Errorf("notices.archive.segment-size cannot be parsed: %v", err)
+		}
+		if size < 4096 {
+			return fmt.Errorf("notices.archive.segment-size must be at least 4KB")
+		}
+	}
+
+	for _, opt := range []string{"notices.archive.segment-age", "notices.archive.retention"} {
+		durStr, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if durStr == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(durStr)
+		if err != nil {
+			return fmt.Errorf("%s cannot be parsed: %v", opt, err)
+		}
+		if dur < time.Hour {
+			return fmt.Errorf("%s must be at least one hour", opt)
+		}
+	}
+	return nil
+}
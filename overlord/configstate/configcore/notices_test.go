@@ -0,0 +1,66 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2025 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type noticesSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&noticesSuite{})
+
+func (s *noticesSuite) TestConfigureNoticesArchiveHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"notices.archive.enabled":      "true",
+			"notices.archive.segment-size": "2MB",
+			"notices.archive.segment-age":  "48h",
+			"notices.archive.retention":    "2160h",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *noticesSuite) TestConfigureNoticesArchiveErrors(c *C) {
+	for _, tc := range []struct {
+		conf map[string]any
+		err  string
+	}{
+		{map[string]any{"notices.archive.enabled": "maybe"}, `notices.archive.enabled can only be set to 'true' or 'false'`},
+		{map[string]any{"notices.archive.segment-size": "lots"}, `notices.archive.segment-size cannot be parsed: .*`},
+		{map[string]any{"notices.archive.segment-size": "1KB"}, `notices.archive.segment-size must be at least 4KB`},
+		{map[string]any{"notices.archive.segment-age": "soon"}, `notices.archive.segment-age cannot be parsed: .*`},
+		{map[string]any{"notices.archive.segment-age": "10m"}, `notices.archive.segment-age must be at least one hour`},
+		{map[string]any{"notices.archive.retention": "1s"}, `notices.archive.retention must be at least one hour`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.conf))
+	}
+}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.quotas.usage.sample-interval"] = true
+	supportedConfigurations["core.quotas.usage.history-size"] = true
+	supportedConfigurations["core.quotas.usage.near-limit-duration"] = true
+}
+
+// validateQuotaUsageSettings validates the options controlling the sampling
+// of the resource usage of quota groups, which servicestate reads as it
+// samples.
+func validateQuotaUsageSettings(tr RunTransaction) error {
+	intervalStr, err := coreCfg(tr, "quotas.usage.sample-interval")
+	if err != nil {
+		return err
+	}
+	if intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return fmt.Errorf("quotas.usage.sample-interval cannot be parsed: %v", err)
+		}
+		// 0 disables sampling
+		if interval != 0 && interval < 10*time.Second {
+			return fmt.Errorf("quotas.usage.sample-interval must be at least 10s")
+		}
+	}
+
+	sizeStr, err := coreCfg(tr, "quotas.usage.history-size")
+	if err != nil {
+		return err
+	}
+	if sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 1 || size > 10000 {
+			return fmt.Errorf("quotas.usage.history-size must be a number between 1 and 10000, not %q", sizeStr)
+		}
+	}
+
+	nearLimitStr, err := coreCfg(tr, "quotas.usage.near-limit-duration")
+	if err != nil {
+		return err
+	}
+	if nearLimitStr != "" {
+		nearLimit, err := time.ParseDuration(nearLimitStr)
+		if err != nil {
+			return fmt.Errorf("quotas.usage.near-limit-duration cannot be parsed: %v", err)
+		}
+		if nearLimit < 0 {
+			return fmt.Errorf("quotas.usage.near-limit-duration cannot be negative")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type quotasSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&quotasSuite{})
+
+func (s *quotasSuite) TestConfigureQuotaUsageHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"quotas.usage.sample-interval":     "1m",
+			"quotas.usage.history-size":        1440,
+			"quotas.usage.near-limit-duration": "30m",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// sampling can be disabled again
+	err = configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"quotas.usage.sample-interval": "0",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *quotasSuite) TestConfigureQuotaUsageErrors(c *C) {
+	for _, tc := range []struct {
+		conf map[string]any
+		err  string
+	}{
+		{map[string]any{"quotas.usage.sample-interval": "often"}, `quotas.usage.sample-interval cannot be parsed: .*`},
+		{map[string]any{"quotas.usage.sample-interval": "1s"}, `quotas.usage.sample-interval must be at least 10s`},
+		{map[string]any{"quotas.usage.history-size": "0"}, `quotas.usage.history-size must be a number between 1 and 10000, not "0"`},
+		{map[string]any{"quotas.usage.history-size": "many"}, `quotas.usage.history-size must be a number between 1 and 10000, not "many"`},
+		{map[string]any{"quotas.usage.near-limit-duration": "a while"}, `quotas.usage.near-limit-duration cannot be parsed: .*`},
+		{map[string]any{"quotas.usage.near-limit-duration": "-1m"}, `quotas.usage.near-limit-duration cannot be negative`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.conf))
+	}
+}
@@ -23,9 +23,11 @@ package configcore
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -39,10 +41,13 @@ func init() {
 	supportedConfigurations["core.refresh.hold"] = true
 	supportedConfigurations["core.refresh.schedule"] = true
 	supportedConfigurations["core.refresh.timer"] = true
+	supportedConfigurations["core.refresh.blackout"] = true
 	supportedConfigurations["core.refresh.metered"] = true
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
 	supportedConfigurations["core.refresh.max-inhibition-days"] = true
+	supportedConfigurations["core.refresh.health-rollback"] = true
+	supportedConfigurations["core.refresh.health-rollback-window"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr RunTransaction, optName string) error {
@@ -149,6 +154,24 @@ func validateRefreshSchedule(tr RunTransaction) error {
 	return err
 }
 
+func validateRefreshBlackout(tr RunTransaction) error {
+	refreshBlackoutStr, err := coreCfg(tr, "refresh.blackout")
+	if err != nil {
+		return err
+	}
+	if refreshBlackoutStr == "" {
+		return nil
+	}
+	blackouts, err := timeutil.ParseBlackouts(refreshBlackoutStr)
+	if err != nil {
+		return err
+	}
+	if timeutil.BlackoutEnd(blackouts, time.Now()).IsZero() {
+		return fmt.Errorf("refresh.blackout cannot prevent refreshes for more than a year")
+	}
+	return nil
+}
+
 func validateRefreshRateLimit(tr RunTransaction) error {
 	refreshRateLimit, err := coreCfg(tr, "refresh.rate-limit")
 	if err != nil {
@@ -163,3 +186,36 @@ func validateRefreshRateLimit(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateRefreshHealthRollback(tr RunTransaction) error {
+	policy, err := coreCfg(tr, "refresh.health-rollback")
+	if err != nil {
+		return err
+	}
+	switch policy {
+	case "", "none", "all":
+		// noop
+	default:
+		for _, name := range strings.Split(policy, ",") {
+			if err := naming.ValidateInstance(name); err != nil {
+				return fmt.Errorf("refresh.health-rollback must be \"all\", \"none\" or a comma-separated list of snap names: %v", err)
+			}
+		}
+	}
+
+	windowStr, err := coreCfg(tr, "refresh.health-rollback-window")
+	if err != nil {
+		return err
+	}
+	if windowStr == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil {
+		return fmt.Errorf("refresh.health-rollback-window cannot be parsed: %v", err)
+	}
+	if window <= 0 {
+		return fmt.Errorf("refresh.health-rollback-window must be positive, not %q", windowStr)
+	}
+	return nil
+}
@@ -123,6 +123,34 @@ func (s *refreshSuite) TestConfigureLegacyRefreshScheduleRejected(c *C) {
 	c.Assert(err, ErrorMatches, `cannot parse "8:00~12:00": not a valid interval`)
 }
 
+func (s *refreshSuite) TestConfigureRefreshBlackoutHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"refresh.blackout": "last-week,12-24..01-02,2026-05-01",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshBlackoutRejected(c *C) {
+	for _, t := range []struct {
+		blackout string
+		err      string
+	}{
+		{"last-month", `cannot parse blackout "last-month": not a valid date or weekday span`},
+		{"mon-sun", `refresh.blackout cannot prevent refreshes for more than a year`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.blackout": t.blackout,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
 func (s *refreshSuite) TestConfigureRefreshHoldHappy(c *C) {
 	err := configcore.Run(classicDev, &mockConf{
 		state: s.state,
@@ -252,3 +280,35 @@ func (s *refreshSuite) TestConfigureRefreshMaxInhibitionDays(c *C) {
 		}
 	}
 }
+
+func (s *refreshSuite) TestConfigureRefreshHealthRollback(c *C) {
+	data := []struct {
+		policy any
+		window any
+		err    string
+	}{
+		{policy: "some,,snaps", err: `refresh.health-rollback must be "all", "none" or a comma-separated list of snap names: invalid snap name: ""`},
+		{policy: "Foo", err: `refresh.health-rollback must be "all", "none" or a comma-separated list of snap names: invalid snap name: "Foo"`},
+		{window: "soon", err: `refresh.health-rollback-window cannot be parsed: time: invalid duration "soon"`},
+		{window: "-1h", err: `refresh.health-rollback-window must be positive, not "-1h"`},
+		// happy cases
+		{policy: nil, window: nil},
+		{policy: "none"},
+		{policy: "all", window: "30m"},
+		{policy: "foo,bar_1", window: "2h"},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.health-rollback":        tc.policy,
+				"refresh.health-rollback-window": tc.window,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err)
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
@@ -58,8 +58,18 @@ func init() {
 
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
+	addWithStateHandler(validateRefreshBlackout, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshHealthRollback, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateIncrementalSnapshots, nil, validateOnly)
+	addWithStateHandler(validateScheduledSnapshots, nil, validateOnly)
+	addWithStateHandler(validateNoticesArchiveSettings, nil, validateOnly)
+	addWithStateHandler(validateQuotaUsageSettings, nil, validateOnly)
+	addWithStateHandler(validateStorePeerDistribution, nil, validateOnly)
+	addWithStateHandler(validateStoreCacheServer, nil, validateOnly)
+	addWithStateHandler(validateClusterRollout, nil, validateOnly)
+	addWithStateHandler(validateClusterConfdbReplication, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
@@ -22,12 +22,22 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.incremental"] = true
+	supportedConfigurations["core.snapshots.schedule.timer"] = true
+	supportedConfigurations["core.snapshots.schedule.snaps"] = true
+	supportedConfigurations["core.snapshots.schedule.keep-daily"] = true
+	supportedConfigurations["core.snapshots.schedule.keep-weekly"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
@@ -46,3 +56,59 @@ func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateIncrementalSnapshots(tr RunTransaction) error {
+	return validateBoolFlag(tr, "snapshots.incremental")
+}
+
+func validateScheduledSnapshots(tr RunTransaction) error {
+	timerStr, err := coreCfg(tr, "snapshots.schedule.timer")
+	if err != nil {
+		return err
+	}
+	if timerStr != "" {
+		if _, err := timeutil.ParseSchedule(timerStr); err != nil {
+			return fmt.Errorf("snapshots.schedule.timer cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.schedule.snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := snap.ValidateInstanceName(name); err != nil {
+			return fmt.Errorf("snapshots.schedule.snaps is not valid: %v", err)
+		}
+	}
+
+	keepDaily, err := validateScheduledSnapshotsKeep(tr, "snapshots.schedule.keep-daily")
+	if err != nil {
+		return err
+	}
+	keepWeekly, err := validateScheduledSnapshotsKeep(tr, "snapshots.schedule.keep-weekly")
+	if err != nil {
+		return err
+	}
+	if keepDaily == 0 && keepWeekly == 0 {
+		return fmt.Errorf("snapshots.schedule.keep-daily and snapshots.schedule.keep-weekly cannot both be 0")
+	}
+	return nil
+}
+
+// validateScheduledSnapshotsKeep checks one of the snapshots.schedule.keep-*
+// options, returning its value, or -1 if it is not set.
+func validateScheduledSnapshotsKeep(tr RunTransaction, option string) (int, error) {
+	keepStr, err := coreCfg(tr, option)
+	if err != nil {
+		return 0, err
+	}
+	if keepStr == "" {
+		return -1, nil
+	}
+	keep, err := strconv.ParseUint(keepStr, 10, 16)
+	if err != nil || keep > 365 {
+		return 0, fmt.Errorf("%s must be a number between 0 and 365, not %q", option, keepStr)
+	}
+	return int(keep), nil
+}
@@ -71,3 +71,57 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureIncrementalSnapshots(c *C) {
+	for _, value := range []any{true, false, "true", "false"} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"snapshots.incremental": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%v", value))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureIncrementalSnapshotsInvalid(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"snapshots.incremental": "maybe",
+		},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.incremental can only be set to 'true' or 'false'`)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"snapshots.schedule.timer":       "mon-fri,03:00",
+			"snapshots.schedule.snaps":       "foo,bar_instance",
+			"snapshots.schedule.keep-daily":  "5",
+			"snapshots.schedule.keep-weekly": 0,
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]any
+		err  string
+	}{
+		{map[string]any{"snapshots.schedule.timer": "invalid"}, `snapshots.schedule.timer cannot be parsed: .*`},
+		{map[string]any{"snapshots.schedule.snaps": "foo,Bar"}, `snapshots.schedule.snaps is not valid: invalid snap name: "Bar"`},
+		{map[string]any{"snapshots.schedule.keep-daily": "-1"}, `snapshots.schedule.keep-daily must be a number between 0 and 365, not "-1"`},
+		{map[string]any{"snapshots.schedule.keep-weekly": "366"}, `snapshots.schedule.keep-weekly must be a number between 0 and 365, not "366"`},
+		{map[string]any{"snapshots.schedule.keep-daily": 0, "snapshots.schedule.keep-weekly": 0}, `snapshots.schedule.keep-daily and snapshots.schedule.keep-weekly cannot both be 0`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.conf))
+	}
+}
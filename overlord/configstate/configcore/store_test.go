@@ -95,3 +95,82 @@ func (s *storeSuite) TestFilesystemOnlyApply(c *C) {
 
 	c.Check(repairConfig.StoreOffline, Equals, true)
 }
+
+func (s *storeSuite) TestStorePeerDistribution(c *C) {
+	for _, value := range []string{"true", "false"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.peer-distribution": value,
+			},
+		})
+		c.Check(err, IsNil)
+	}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.peer-distribution": "maybe",
+		},
+	})
+	c.Assert(err, ErrorMatches, "store.peer-distribution can only be set to 'true' or 'false'")
+}
+
+func (s *storeSuite) TestStoreCacheServerAddress(c *C) {
+	for _, value := range []string{"", ":8765", "0.0.0.0:8765", "[::1]:443"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.cache-server.address": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%q", value))
+	}
+
+	for _, tc := range []struct {
+		value string
+		err   string
+	}{
+		{"8765", `cannot parse store cache server address "8765": address 8765: missing port in address`},
+		{"localhost", `cannot parse store cache server address "localhost": address localhost: missing port in address`},
+		{":http", `invalid store cache server port "http"`},
+		{":0", `invalid store cache server port "0"`},
+		{":65536", `invalid store cache server port "65536"`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.cache-server.address": tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.value))
+	}
+}
+
+func (s *storeSuite) TestStoreCacheServerAllowedClients(c *C) {
+	for _, value := range []string{"", "192.168.1.0/24", "10.0.0.1,fd00::/8"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.cache-server.allowed-clients": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%q", value))
+	}
+
+	for _, tc := range []struct {
+		value string
+		err   string
+	}{
+		{"192.168.1.0/33", `cannot parse store cache server allowed clients: invalid network "192.168.1.0/33"`},
+		{"10.0.0.1,localhost", `cannot parse store cache server allowed clients: invalid IP address "localhost"`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.cache-server.allowed-clients": tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.value))
+	}
+}
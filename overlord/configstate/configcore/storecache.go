@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/snapcore/snapd/store/storeproxy"
+)
+
+func init() {
+	supportedConfigurations["core.store.cache-server.address"] = true
+	supportedConfigurations["core.store.cache-server.allowed-clients"] = true
+}
+
+// validateStoreCacheServer validates the address on which the downloads cache
+// is served to other devices, through a caching proxy of the store, and the
+// comma-separated list of IP addresses and networks of the devices allowed to
+// use it.
+func validateStoreCacheServer(tr RunTransaction) error {
+	allowedClients, err := coreCfg(tr, "store.cache-server.allowed-clients")
+	if err != nil {
+		return err
+	}
+	if _, err := storeproxy.ParseAllowedClients(allowedClients); err != nil {
+		return fmt.Errorf("cannot parse store cache server allowed clients: %v", err)
+	}
+
+	address, err := coreCfg(tr, "store.cache-server.address")
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		return nil
+	}
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("cannot parse store cache server address %q: %v", address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid store cache server port %q", portStr)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+func init() {
+	supportedConfigurations["core.store.peer-distribution"] = true
+}
+
+// validateStorePeerDistribution validates the option controlling whether snap
+// and component blobs are exchanged with the other devices of the cluster.
+func validateStorePeerDistribution(tr RunTransaction) error {
+	return validateBoolFlag(tr, "store.peer-distribution")
+}
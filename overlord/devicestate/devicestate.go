@@ -145,6 +145,12 @@ func Serial(st *state.State) (*asserts.Serial, error) {
 	return findSerial(st, nil)
 }
 
+// SignWithDeviceKey signs the given data with the device key, the signature
+// can be verified with the device key of the serial assertion.
+func SignWithDeviceKey(st *state.State, data []byte) ([]byte, error) {
+	return deviceMgr(st).SignWithDeviceKey(data)
+}
+
 // findKnownRevisionOfModel returns the model assertion revision if any in the
 // assertion database for the given model, otherwise it returns -1.
 func findKnownRevisionOfModel(st *state.State, mod *asserts.Model) (modRevision int, err error) {
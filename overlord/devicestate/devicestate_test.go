@@ -4424,6 +4424,28 @@ func (s *deviceMgrSuite) TestSignResponseMessageInvalid(c *C) {
 	)
 }
 
+func (s *deviceMgrSuite) TestSignWithDeviceKey(c *C) {
+	s.setPCModelInState(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	serial := s.makeSerialAssertionInState(c, "canonical", "pc", "serialserialserial")
+	s.addKeyToManagerInState(c)
+
+	sig, err := devicestate.SignWithDeviceKey(s.state, []byte("data"))
+	c.Assert(err, IsNil)
+	c.Check(asserts.RawVerifyWithKey([]byte("data"), sig, serial.DeviceKey()), IsNil)
+	c.Check(asserts.RawVerifyWithKey([]byte("other"), sig, serial.DeviceKey()), NotNil)
+}
+
+func (s *deviceMgrSuite) TestSignWithDeviceKeyNoKey(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := s.mgr.SignWithDeviceKey([]byte("data"))
+	c.Assert(err, ErrorMatches, "cannot sign without device key")
+}
+
 type myStateDeviceInitialized struct {
 	called int
 }
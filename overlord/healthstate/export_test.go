@@ -21,6 +21,9 @@ package healthstate
 
 import (
 	"time"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 func MockCheckTimeout(t time.Duration) (restore func()) {
@@ -32,3 +35,19 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockSnapstateRevert(f func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateRevert
+	snapstateRevert = f
+	return func() {
+		snapstateRevert = old
+	}
+}
@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var timeNow = time.Now
+
+// HealthManager runs the check-health hook of snaps which declare a
+// periodic health-check interval for it, and starts the rollbacks queued
+// after failed health checks.
+type HealthManager struct {
+	state *state.State
+
+	changeCallbackID int
+}
+
+// Manager returns a new HealthManager.
+func Manager(st *state.State) *HealthManager {
+	return &HealthManager{state: st}
+}
+
+// StartUp implements StateStarterUp.Startup.
+func (m *HealthManager) StartUp() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.changeCallbackID = m.state.AddChangeStatusChangedHandler(processRollbackChange)
+	return nil
+}
+
+// Stop implements StateStopper. It will unregister the change callback
+// handler from state.
+func (m *HealthManager) Stop() {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	m.state.RemoveChangeStatusChangedHandler(m.changeCallbackID)
+}
+
+// Ensure implements StateManager.Ensure. It starts a check-health change
+// for every snap whose health-check interval elapsed since it last
+// reported its health, and schedules the next ensure for when the
+// earliest pending check is due.
+func (m *HealthManager) Ensure() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	if err := st.Get("seeded", &seeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	if err := startPendingRollbacks(st); err != nil {
+		return err
+	}
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+	health, err := All(st)
+	if err != nil {
+		return err
+	}
+	inProgress := snapsWithChecksInProgress(st)
+
+	now := timeNow()
+	var next time.Time
+	for name, snapst := range snapStates {
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot schedule health check of snap %q: %v", name, err)
+			continue
+		}
+		hook := info.Hooks["check-health"]
+		if hook == nil || hook.Interval <= 0 {
+			continue
+		}
+		interval := time.Duration(hook.Interval)
+
+		var due time.Time
+		if hs := health[name]; hs != nil {
+			due = hs.Timestamp.Add(interval)
+		}
+		if now.Before(due) {
+			if next.IsZero() || due.Before(next) {
+				next = due
+			}
+			continue
+		}
+		if strutil.ListContains(inProgress, name) {
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(st, name, nil); err != nil {
+			// the snap is busy, try again on a later ensure
+			logger.Debugf("cannot run health check of snap %q yet: %v", name, err)
+			continue
+		}
+
+		chg := st.NewChange("check-health", fmt.Sprintf("Run periodic health check of %q snap", name))
+		chg.AddTask(Hook(st, name, snapst.Current))
+		chg.Set("snap-names", []string{name})
+
+		// the hook records a new timestamp once done, until then
+		// the check is tracked as in progress
+		if due = now.Add(interval); next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+
+	if !next.IsZero() {
+		st.EnsureBefore(next.Sub(now))
+	}
+
+	return nil
+}
+
+func snapsWithChecksInProgress(st *state.State) []string {
+	var snaps []string
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "check-health" || chg.IsReady() {
+			continue
+		}
+		var names []string
+		if err := chg.Get("snap-names", &names); err != nil {
+			continue
+		}
+		snaps = append(snaps, names...)
+	}
+	return snaps
+}
@@ -166,7 +166,21 @@ func (h *healthHandler) appendHealth(health *HealthState) error {
 
 func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 	st := ctx.State()
+	if err := setHealth(st, ctx.InstanceName(), health); err != nil {
+		return err
+	}
 
+	var fromChange string
+	if t, ok := ctx.Task(); ok && t.Change() != nil {
+		fromChange = t.Change().ID()
+	}
+	return maybeRollback(st, ctx.InstanceName(), health, fromChange)
+}
+
+// maxHistory is the maximum number of health transitions kept per snap.
+const maxHistory = 20
+
+func setHealth(st *state.State, snapName string, health *HealthState) error {
 	var hs map[string]*HealthState
 	if err := st.Get("health", &hs); err != nil {
 		if !errors.Is(err, state.ErrNoState) {
@@ -174,9 +188,45 @@ func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 		}
 		hs = map[string]*HealthState{}
 	}
-	hs[ctx.InstanceName()] = health
+	prev := hs[snapName]
+	hs[snapName] = health
 	st.Set("health", hs)
 
+	if prev != nil && prev.Status == health.Status {
+		return nil
+	}
+
+	// the status changed: record the transition and let anybody
+	// interested know about it
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil {
+		if !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		history = map[string][]*HealthState{}
+	}
+	entries := append(history[snapName], health)
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+	}
+	history[snapName] = entries
+	st.Set("health-history", history)
+
+	data := map[string]string{
+		"status":   health.Status.String(),
+		"revision": health.Revision.String(),
+	}
+	if prev != nil {
+		data["previous-status"] = prev.Status.String()
+	}
+	if health.Code != "" {
+		data["code"] = health.Code
+	}
+	opts := &state.AddNoticeOptions{Data: data}
+	if _, err := st.AddNotice(nil, state.SnapHealthNotice, snapName, opts); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -225,3 +275,16 @@ func Get(st *state.State, snap string) (*HealthState, error) {
 
 	return &health, nil
 }
+
+// History returns the recorded health status transitions of the given
+// snap, oldest first. At most the last 20 transitions are kept.
+func History(st *state.State, snap string) ([]*HealthState, error) {
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil {
+		if !errors.Is(err, state.ErrNoState) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return history[snap], nil
+}
@@ -20,6 +20,8 @@
 package healthstate_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -30,11 +32,13 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/confdbstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats/swfeatstest"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store/storetest"
@@ -253,3 +257,448 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), testutil.ErrorIs, state.ErrNoState)
 }
+
+func (s *healthSuite) TestSetFromHookContextRecordsTransitions(c *check.C) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "foo", Revision: snap.R(7)}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, status := range []healthstate.HealthStatus{
+		healthstate.OkayStatus,
+		healthstate.OkayStatus,
+		healthstate.BlockedStatus,
+		healthstate.OkayStatus,
+	} {
+		ctx.Set("health", &healthstate.HealthState{
+			Revision:  snap.R(7),
+			Timestamp: t0.Add(time.Duration(i) * time.Minute),
+			Status:    status,
+			Code:      "some-code",
+		})
+		c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	}
+
+	// repeated statuses are not transitions
+	history, err := healthstate.History(s.state, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 3)
+	c.Check(history[0].Status, check.Equals, healthstate.OkayStatus)
+	c.Check(history[0].Timestamp.Equal(t0), check.Equals, true)
+	c.Check(history[1].Status, check.Equals, healthstate.BlockedStatus)
+	c.Check(history[2].Status, check.Equals, healthstate.OkayStatus)
+
+	// the latest health is always updated
+	health, err := healthstate.Get(s.state, "foo")
+	c.Assert(err, check.IsNil)
+	c.Check(health.Timestamp.Equal(t0.Add(3*time.Minute)), check.Equals, true)
+
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapHealthNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	buf, err := json.Marshal(notices[0])
+	c.Assert(err, check.IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), check.IsNil)
+	c.Check(n["key"], check.Equals, "foo")
+	c.Check(n["occurrences"], check.Equals, 3.0)
+	c.Check(n["last-data"], check.DeepEquals, map[string]any{
+		"status":          "okay",
+		"previous-status": "blocked",
+		"revision":        "7",
+		"code":            "some-code",
+	})
+
+	history, err = healthstate.History(s.state, "bar")
+	c.Assert(err, check.IsNil)
+	c.Check(history, check.HasLen, 0)
+}
+
+func (s *healthSuite) TestHistoryIsBounded(c *check.C) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "foo"}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	for i := 0; i < 30; i++ {
+		status := healthstate.OkayStatus
+		if i%2 == 1 {
+			status = healthstate.ErrorStatus
+		}
+		ctx.Set("health", &healthstate.HealthState{Status: status, Message: fmt.Sprint(i)})
+		c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	}
+
+	history, err := healthstate.History(s.state, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 20)
+	c.Check(history[0].Message, check.Equals, "10")
+	c.Check(history[19].Message, check.Equals, "29")
+}
+
+const periodicSnapYaml = `name: test-snap
+version: v1
+hooks:
+  check-health:
+    interval: 10m
+`
+
+func (s *healthSuite) mockPeriodicSnap(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	snaptest.MockSnap(c, periodicSnapYaml, &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)})
+}
+
+func (s *healthSuite) checkHealthChanges() []*state.Change {
+	var chgs []*state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "check-health" {
+			chgs = append(chgs, chg)
+		}
+	}
+	return chgs
+}
+
+func (s *healthSuite) TestEnsureRunsPeriodicCheck(c *check.C) {
+	s.mockPeriodicSnap(c)
+	mgr := healthstate.Manager(s.state)
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	chgs := s.checkHealthChanges()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Run periodic health check of "test-snap" snap`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[0].Get("hook-setup", &hooksup), check.IsNil)
+	c.Check(hooksup.Snap, check.Equals, "test-snap")
+	c.Check(hooksup.Hook, check.Equals, "check-health")
+	c.Check(hooksup.Revision, check.Equals, snap.R(42))
+	s.state.Unlock()
+
+	// the check is in progress, no new one is started
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestEnsureWaitsForInterval(c *check.C) {
+	s.mockPeriodicSnap(c)
+	mgr := healthstate.Manager(s.state)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return now }))
+
+	s.state.Lock()
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: snap.R(42), Timestamp: now.Add(-5 * time.Minute), Status: healthstate.OkayStatus},
+	})
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 0)
+	s.state.Unlock()
+
+	now = now.Add(5 * time.Minute)
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestEnsureNoInterval(c *check.C) {
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+	mgr := healthstate.Manager(s.state)
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 0)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestEnsureNotSeeded(c *check.C) {
+	s.mockPeriodicSnap(c)
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+	mgr := healthstate.Manager(s.state)
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 0)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestEnsureSkipsBusySnap(c *check.C) {
+	s.mockPeriodicSnap(c)
+	mgr := healthstate.Manager(s.state)
+
+	s.state.Lock()
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap", Revision: snap.R(43)}})
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.checkHealthChanges(), check.HasLen, 0)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestEnsureLoopLogging(c *check.C) {
+	swfeatstest.CheckEnsureLoopLogging("healthmgr.go", c, false)
+}
+
+func (s *healthSuite) setupRollback(c *check.C, policy string, refreshedAgo time.Duration) (revertCalls *[]string) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return now }))
+
+	var calls []string
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		calls = append(calls, name+":"+fromChange)
+		return state.NewTaskSet(st.NewTask("fake-revert", "...")), nil
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("seeded", true)
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.health-rollback", policy), check.IsNil)
+	tr.Commit()
+
+	refreshed := now.Add(-refreshedAgo)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "test-snap", Revision: snap.R(41)},
+			{RealName: "test-snap", Revision: snap.R(42)},
+		}),
+		Current:         snap.R(42),
+		Active:          true,
+		SnapType:        "app",
+		LastRefreshTime: &refreshed,
+	})
+	return &calls
+}
+
+func (s *healthSuite) reportHealth(c *check.C, status healthstate.HealthStatus) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42)}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+	ctx.Set("health", &healthstate.HealthState{
+		Revision: snap.R(42),
+		Status:   status,
+		Message:  "service is down",
+	})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+}
+
+// ensureRollbacks runs the health manager ensure which starts the queued
+// rollbacks.
+func (s *healthSuite) ensureRollbacks(c *check.C) {
+	c.Assert(healthstate.Manager(s.state).Ensure(), check.IsNil)
+}
+
+func (s *healthSuite) TestRollbackOnErrorHealth(c *check.C) {
+	s.testRollbackOnFailedHealth(c, healthstate.ErrorStatus)
+}
+
+func (s *healthSuite) TestRollbackOnBlockedHealth(c *check.C) {
+	s.testRollbackOnFailedHealth(c, healthstate.BlockedStatus)
+}
+
+func (s *healthSuite) testRollbackOnFailedHealth(c *check.C, status healthstate.HealthStatus) {
+	calls := s.setupRollback(c, "all", 10*time.Minute)
+	s.reportHealth(c, status)
+	// the revert is only queued until the next ensure
+	c.Check(*calls, check.HasLen, 0)
+
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.DeepEquals, []string{"test-snap:"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "revert-snap")
+	c.Check(chg.Summary(), check.Equals, `Revert "test-snap" snap after failed health check`)
+	var reason string
+	c.Check(chg.Get("health-rollback-reason", &reason), check.IsNil)
+	c.Check(reason, check.Equals, fmt.Sprintf("health check of revision 42 reported %s: service is down", status))
+	c.Check(chg.Tasks()[0].Log()[0], check.Matches, `.* Reverting snap "test-snap": health check of revision 42 .*`)
+
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, `reverting snap "test-snap" to its previous revision: `+reason)
+}
+
+func (s *healthSuite) TestRollbackWaitsForOwnChange(c *check.C) {
+	calls := s.setupRollback(c, "all", 10*time.Minute)
+
+	s.state.Lock()
+	task := healthstate.Hook(s.state, "test-snap", snap.R(42))
+	chg := s.state.NewChange("refresh-snap", "...")
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	ctx, err := hookstate.NewContext(task, s.state, &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42)}, nil, "")
+	c.Assert(err, check.IsNil)
+	ctx.Lock()
+	ctx.Set("health", &healthstate.HealthState{Revision: snap.R(42), Status: healthstate.ErrorStatus})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	ctx.Unlock()
+
+	// the revert does not start while the refresh is in progress
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 0)
+
+	s.state.Lock()
+	task.SetStatus(state.DoneStatus)
+	s.state.Unlock()
+
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.DeepEquals, []string{"test-snap:"})
+
+	// and it is only started once
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 1)
+}
+
+func (s *healthSuite) TestRollbackRetriedOnConflict(c *check.C) {
+	s.setupRollback(c, "all", 10*time.Minute)
+	var calls int
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags, fromChange string) (*state.TaskSet, error) {
+		calls++
+		if calls == 1 {
+			return nil, &snapstate.ChangeConflictError{Snap: name, ChangeKind: "refresh-snap"}
+		}
+		return state.NewTaskSet(st.NewTask("fake-revert", "...")), nil
+	}))
+
+	s.reportHealth(c, healthstate.ErrorStatus)
+	s.ensureRollbacks(c)
+	c.Check(calls, check.Equals, 1)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 0)
+	s.state.Unlock()
+
+	s.ensureRollbacks(c)
+	c.Check(calls, check.Equals, 2)
+	s.state.Lock()
+	c.Check(s.state.Changes(), check.HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *healthSuite) TestRollbackDroppedWhenRevisionChanged(c *check.C) {
+	calls := s.setupRollback(c, "all", 10*time.Minute)
+	s.reportHealth(c, healthstate.ErrorStatus)
+
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Current = snap.R(41)
+	snapstate.Set(s.state, "test-snap", &snapst)
+	s.state.Unlock()
+
+	s.ensureRollbacks(c)
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 0)
+}
+
+func (s *healthSuite) TestRollbackSnapList(c *check.C) {
+	calls := s.setupRollback(c, "other-snap,test-snap", 10*time.Minute)
+	s.reportHealth(c, healthstate.ErrorStatus)
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 1)
+}
+
+func (s *healthSuite) TestNoRollbackWithSingleRevision(c *check.C) {
+	calls := s.setupRollback(c, "all", 10*time.Minute)
+
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Sequence = snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+		{RealName: "test-snap", Revision: snap.R(42)},
+	})
+	snapstate.Set(s.state, "test-snap", &snapst)
+	s.state.Unlock()
+
+	s.reportHealth(c, healthstate.ErrorStatus)
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+}
+
+func (s *healthSuite) TestNoRollback(c *check.C) {
+	for _, tc := range []struct {
+		policy       string
+		refreshedAgo time.Duration
+		status       healthstate.HealthStatus
+	}{
+		{"", 10 * time.Minute, healthstate.ErrorStatus},
+		{"none", 10 * time.Minute, healthstate.ErrorStatus},
+		{"other-snap", 10 * time.Minute, healthstate.ErrorStatus},
+		{"all", 2 * time.Hour, healthstate.ErrorStatus},
+		{"all", 10 * time.Minute, healthstate.OkayStatus},
+		{"all", 10 * time.Minute, healthstate.WaitingStatus},
+		{"all", 10 * time.Minute, healthstate.UnknownStatus},
+	} {
+		calls := s.setupRollback(c, tc.policy, tc.refreshedAgo)
+		s.reportHealth(c, tc.status)
+		s.ensureRollbacks(c)
+		c.Check(*calls, check.HasLen, 0, check.Commentf("%+v", tc))
+	}
+}
+
+func (s *healthSuite) TestNoRollbackOfRevertedRevision(c *check.C) {
+	calls := s.setupRollback(c, "all", 10*time.Minute)
+
+	s.state.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Current = snap.R(41)
+	snapstate.Set(s.state, "test-snap", &snapst)
+	s.state.Unlock()
+
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(41)}, nil, "")
+	c.Assert(err, check.IsNil)
+	ctx.Lock()
+	ctx.Set("health", &healthstate.HealthState{Revision: snap.R(41), Status: healthstate.ErrorStatus})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	ctx.Unlock()
+
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 0)
+}
+
+func (s *healthSuite) TestRollbackWindowConfigured(c *check.C) {
+	calls := s.setupRollback(c, "all", 2*time.Hour)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.health-rollback-window", "3h"), check.IsNil)
+	tr.Commit()
+	s.state.Unlock()
+
+	s.reportHealth(c, healthstate.ErrorStatus)
+	s.ensureRollbacks(c)
+	c.Check(*calls, check.HasLen, 1)
+}
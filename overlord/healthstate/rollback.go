@@ -0,0 +1,229 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// DefaultRollbackWindow is how long after a refresh a failing health
+// check triggers an automatic revert, unless refresh.health-rollback-window
+// is set.
+const DefaultRollbackWindow = time.Hour
+
+var snapstateRevert = snapstate.Revert
+
+// rollbackEnabled returns whether automatic rollback on failing health
+// checks is enabled for the given snap, and the grace window after a
+// refresh during which it applies.
+func rollbackEnabled(st *state.State, snapName string) (bool, time.Duration, error) {
+	tr := config.NewTransaction(st)
+
+	var policy string
+	if err := tr.Get("core", "refresh.health-rollback", &policy); err != nil && !config.IsNoOption(err) {
+		return false, 0, err
+	}
+	switch policy {
+	case "", "none":
+		return false, 0, nil
+	case "all":
+		// enabled for every snap
+	default:
+		if !strutil.ListContains(strings.Split(policy, ","), snapName) {
+			return false, 0, nil
+		}
+	}
+
+	window := DefaultRollbackWindow
+	var windowStr string
+	if err := tr.Get("core", "refresh.health-rollback-window", &windowStr); err != nil && !config.IsNoOption(err) {
+		return false, 0, err
+	}
+	if windowStr != "" {
+		var err error
+		if window, err = time.ParseDuration(windowStr); err != nil {
+			return false, 0, err
+		}
+	}
+
+	return true, window, nil
+}
+
+// pendingRollback is a revert queued after a failed health check. It is
+// started by the HealthManager once the change the health was reported from,
+// if any, is ready, so that it does not run concurrently with e.g. the
+// refresh that installed the failing revision.
+type pendingRollback struct {
+	Revision snap.Revision `json:"revision"`
+	Reason   string        `json:"reason"`
+	Change   string        `json:"change,omitempty"`
+}
+
+func pendingRollbacks(st *state.State) (map[string]*pendingRollback, error) {
+	var pending map[string]*pendingRollback
+	if err := st.Get("health-pending-rollbacks", &pending); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if pending == nil {
+		pending = make(map[string]*pendingRollback)
+	}
+	return pending, nil
+}
+
+// maybeRollback queues a revert of the given snap to its previous revision if
+// the health it reported is failing shortly after it was refreshed, and the
+// rollback policy applies to it. fromChange is the ID of the change the
+// health report came from, if any.
+func maybeRollback(st *state.State, snapName string, health *HealthState, fromChange string) error {
+	if health.Status != ErrorStatus && health.Status != BlockedStatus {
+		return nil
+	}
+
+	enabled, window, err := rollbackEnabled(st, snapName)
+	if err != nil || !enabled {
+		return err
+	}
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	if snapst.Current != health.Revision || snapst.LastRefreshTime == nil {
+		return nil
+	}
+	// there is nothing to revert to
+	if len(snapst.Sequence.Revisions) < 2 {
+		return nil
+	}
+	// only the most recent revision is rolled back, which also stops a
+	// revision we already reverted to from being reverted in turn
+	if snapst.LastIndex(snapst.Current) != len(snapst.Sequence.Revisions)-1 {
+		return nil
+	}
+	if timeNow().Sub(*snapst.LastRefreshTime) > window {
+		return nil
+	}
+
+	reason := fmt.Sprintf("health check of revision %s reported %s", health.Revision, health.Status)
+	if health.Message != "" {
+		reason += fmt.Sprintf(": %s", health.Message)
+	}
+
+	pending, err := pendingRollbacks(st)
+	if err != nil {
+		return err
+	}
+	pending[snapName] = &pendingRollback{
+		Revision: health.Revision,
+		Reason:   reason,
+		Change:   fromChange,
+	}
+	st.Set("health-pending-rollbacks", pending)
+	st.EnsureBefore(0)
+
+	return nil
+}
+
+// startPendingRollbacks creates the revert changes of the queued rollbacks
+// whose originating change is ready. Rollbacks of snaps which are busy are
+// kept for a later ensure, and those no longer relevant because the snap
+// changed revision meanwhile are dropped.
+func startPendingRollbacks(st *state.State) error {
+	pending, err := pendingRollbacks(st)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	for snapName, p := range pending {
+		if p.Change != "" {
+			if chg := st.Change(p.Change); chg != nil && !chg.IsReady() {
+				continue
+			}
+		}
+
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if snapst.Current != p.Revision {
+			// the snap was reverted, refreshed or removed meanwhile
+			delete(pending, snapName)
+			continue
+		}
+
+		ts, err := snapstateRevert(st, snapName, snapstate.Flags{}, "")
+		if err != nil {
+			var conflErr *snapstate.ChangeConflictError
+			if errors.As(err, &conflErr) {
+				// a change for the snap is in progress, try
+				// again on a later ensure
+				logger.Noticef("cannot roll back snap %q yet: %v", snapName, err)
+				continue
+			}
+			delete(pending, snapName)
+			st.Warnf("cannot roll back snap %q after failed health check: %v", snapName, err)
+			continue
+		}
+		delete(pending, snapName)
+
+		chg := st.NewChange("revert-snap", fmt.Sprintf("Revert %q snap after failed health check", snapName))
+		chg.AddAll(ts)
+		chg.Set("snap-names", []string{snapName})
+		chg.Set("health-rollback-reason", p.Reason)
+		if tasks := ts.Tasks(); len(tasks) > 0 {
+			tasks[0].Logf("Reverting snap %q: %s", snapName, p.Reason)
+		}
+		st.Warnf("reverting snap %q to its previous revision: %s", snapName, p.Reason)
+	}
+
+	st.Set("health-pending-rollbacks", pending)
+	return nil
+}
+
+// processRollbackChange triggers an ensure when a change that queued
+// rollbacks are waiting for is ready.
+func processRollbackChange(chg *state.Change, old, new state.Status) {
+	if !new.Ready() || old.Ready() {
+		return
+	}
+	pending, err := pendingRollbacks(chg.State())
+	if err != nil {
+		return
+	}
+	for _, p := range pending {
+		if p.Change == chg.ID() {
+			chg.State().EnsureBefore(0)
+			return
+		}
+	}
+}
@@ -94,6 +94,7 @@ func newNoticeBackends(noticeMgr *notices.NoticeManager) (*noticeBackends, error
 func (nb *noticeBackends) registerWithManager(noticeMgr *notices.NoticeManager) error {
 	const drainNotices = true
 	for _, bknd := range []*noticeTypeBackend{nb.promptBackend, nb.ruleBackend} {
+		bknd.setArchiveExpired(noticeMgr.ArchiveExpiredNotices)
 		// We don't use the validation closure, since notices are produced
 		// directly to satisfy validation.
 		_, drainedNotices, err := noticeMgr.RegisterBackend(bknd, bknd.noticeType, bknd.namespace, drainNotices)
@@ -173,6 +174,9 @@ type noticeTypeBackend struct {
 	// efficiently look up the notice associated with a particular ID, and to
 	// ensure that no two notices for different users can have the same ID.
 	idToNotice map[string]*state.Notice
+	// archiveExpired, if set, is called with the notices which are dropped
+	// from this backend on expiry, so that they may be archived.
+	archiveExpired func(expired []*state.Notice)
 }
 
 func newNoticeTypeBackend(now time.Time, nextNoticeTimestamp func() time.Time, path string, noticeType state.NoticeType, namespace string) (*noticeTypeBackend, error) {
@@ -192,6 +196,14 @@ func newNoticeTypeBackend(now time.Time, nextNoticeTimestamp func() time.Time, p
 	return ntb, nil
 }
 
+// setArchiveExpired sets the function to be called with notices which are
+// dropped from this backend on expiry.
+func (ntb *noticeTypeBackend) setArchiveExpired(archiveExpired func(expired []*state.Notice)) {
+	ntb.rwmu.Lock()
+	defer ntb.rwmu.Unlock()
+	ntb.archiveExpired = archiveExpired
+}
+
 func (ntb *noticeTypeBackend) noticeKeyToID(key string) string {
 	return fmt.Sprintf("%s-%s", ntb.namespace, key)
 }
@@ -253,6 +265,9 @@ func (ntb *noticeTypeBackend) addNotice(userID uint32, id prompting.IDType, data
 	for _, expiredNotice := range userNotices[:expiredCount] {
 		delete(ntb.idToNotice, expiredNotice.ID())
 	}
+	if ntb.archiveExpired != nil && expiredCount > 0 {
+		ntb.archiveExpired(userNotices[:expiredCount])
+	}
 
 	ntb.cond.Broadcast()
 
@@ -527,7 +542,7 @@ func (ntb *noticeTypeBackend) BackendWaitNotices(ctx context.Context, filter *st
 	// check their ctx.Err() and return if they're cancelled.
 	//
 	// TODO:GOVERSION: replace this with context.AfterFunc once we're on Go 1.21.
-	stop := contextAfterFunc(ctx, func() {
+	stop := osutil.ContextAfterFunc(ctx, func() {
 		// We need to acquire a lock mutually exclusive with the cond lock here
 		// to be sure that the Broadcast below won't occur before the call to
 		// Wait, which would result in a missed signal (and deadlock). Since
@@ -567,22 +582,6 @@ func (ntb *noticeTypeBackend) BackendWaitNotices(ctx context.Context, filter *st
 	}
 }
 
-// TODO:GOVERSION: Remove this and just use context.AfterFunc once we're on Go 1.21.
-func contextAfterFunc(ctx context.Context, f func()) func() {
-	stopCh := make(chan struct{})
-	go func() {
-		select {
-		case <-ctx.Done():
-			f()
-		case <-stopCh:
-		}
-	}()
-	stop := func() {
-		close(stopCh)
-	}
-	return stop
-}
-
 type savedNotices struct {
 	UserNotices map[uint32][]*state.Notice `json:"user-notices"`
 }
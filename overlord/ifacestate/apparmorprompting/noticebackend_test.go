@@ -410,6 +410,36 @@ func (s *noticebackendSuite) TestAddNoticeAllExpired(c *C) {
 	}
 }
 
+func (s *noticebackendSuite) TestAddNoticeArchivesExpired(c *C) {
+	archive, err := notices.NewArchiveBackend(c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	_, _, err = s.noticeMgr.RegisterBackend(archive, state.InterfacesRequestsPromptNotice, notices.ArchiveNamespace, false)
+	c.Assert(err, IsNil)
+
+	noticeBackend, err := apparmorprompting.NewNoticeBackends(s.noticeMgr)
+	c.Assert(err, IsNil)
+	c.Assert(apparmorprompting.RegisterWithManager(noticeBackend, s.noticeMgr), IsNil)
+	promptBackend := noticeBackend.PromptBackend()
+
+	userID := uint32(1000)
+	c.Check(promptBackend.AddNotice(userID, 1, nil), IsNil)
+	c.Check(promptBackend.AddNotice(userID, 2, nil), IsNil)
+	origNotices := promptBackend.BackendNotices(&state.NoticeFilter{UserID: &userID})
+	c.Assert(origNotices, HasLen, 2)
+	// Expire the first notice by re-recording it in the past
+	origNotices[0].Reoccur(origNotices[0].LastRepeated().Add(-1000*time.Hour), nil, 0)
+
+	c.Check(archive.BackendNotices(nil), HasLen, 0)
+	c.Check(promptBackend.AddNotice(userID, 3, nil), IsNil)
+
+	archived := archive.BackendNotices(nil)
+	c.Assert(archived, HasLen, 1)
+	c.Check(archived[0].ID(), Equals, "prompt-0000000000000001")
+
+	// The expired notice can still be looked up through the manager
+	c.Check(s.noticeMgr.Notice("prompt-0000000000000001"), NotNil)
+}
+
 func (s *noticebackendSuite) TestAddNoticeSaveFailureRollback(c *C) {
 	for _, id := range []prompting.IDType{1, 2, 3, 4, 5} {
 		// Need a new root dir for each test case
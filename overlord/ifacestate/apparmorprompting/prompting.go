@@ -21,7 +21,9 @@ package apparmorprompting
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
@@ -62,6 +64,9 @@ type Manager interface {
 	RuleWithID(userID uint32, ruleID prompting.IDType) (*requestrules.Rule, error)
 	PatchRule(userID uint32, ruleID prompting.IDType, constraintsPatchJSON prompting.ConstraintsJSON) (*requestrules.Rule, error)
 	RemoveRule(userID uint32, ruleID prompting.IDType) (*requestrules.Rule, error)
+	ExportRules(userID uint32, snap string, iface string) ([]*requestrules.ExportedRule, error)
+	ImportRules(userID uint32, rules []*requestrules.ExportedRule) ([]*requestrules.Rule, error)
+	EvaluateRequest(userID uint32, snap string, iface string, path string, permissions []string) (*requestrules.RequestDecision, error)
 }
 
 // verify that InterfacesRequestsManager implements Manager
@@ -542,7 +547,7 @@ func (m *InterfacesRequestsManager) applyRuleToOutstandingPrompts(rule *requestr
 		Snap:      rule.Snap,
 		Interface: rule.Interface,
 	}
-	satisfiedPromptIDs, err := m.prompts.HandleNewRule(metadata, rule.Constraints)
+	satisfiedPromptIDs, err := m.prompts.HandleNewRule(metadata, rule.ID, rule.Constraints)
 	if err != nil {
 		// The rule's constraints and outcome were already validated, so an
 		// error should not occur here unless the prompt DB was already closed.
@@ -669,3 +674,52 @@ func (m *InterfacesRequestsManager) RemoveRule(userID uint32, ruleID prompting.I
 	rule, err := m.rules.RemoveRule(userID, ruleID)
 	return rule, err
 }
+
+// ExportRules returns all rules for the user with the given user ID and,
+// optionally, only those for the given snap and/or interface, in a form which
+// can be imported on another machine.
+func (m *InterfacesRequestsManager) ExportRules(userID uint32, snap string, iface string) ([]*requestrules.ExportedRule, error) {
+	rules, err := m.Rules(userID, snap, iface)
+	if err != nil {
+		return nil, err
+	}
+	return requestrules.ExportRules(rules, time.Now())
+}
+
+// ImportRules adds the given exported rules as rules for the user with the
+// given user ID, and then checks them against outstanding prompts, resolving
+// any prompts which they satisfy.
+//
+// If any of the rules conflict with existing rules, no rules are imported.
+func (m *InterfacesRequestsManager) ImportRules(userID uint32, rules []*requestrules.ExportedRule) ([]*requestrules.Rule, error) {
+	<-m.prompts.Ready()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	imported, err := m.rules.ImportRules(userID, rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range imported {
+		m.applyRuleToOutstandingPrompts(rule)
+	}
+	return imported, nil
+}
+
+// EvaluateRequest checks whether a hypothetical request by the given snap for
+// the given path and permissions would be allowed or denied by the existing
+// rules of the user with the given user ID, without creating a prompt.
+func (m *InterfacesRequestsManager) EvaluateRequest(userID uint32, snap string, iface string, path string, permissions []string) (*requestrules.RequestDecision, error) {
+	if err := prompting.ValidatePermissions(iface, permissions); err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		return nil, prompting_errors.NewInvalidPathError(path, "path must be absolute")
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.rules.EvaluateRequest(userID, snap, iface, path, permissions)
+}
@@ -1714,6 +1714,115 @@ func (s *apparmorpromptingSuite) TestAddRuleWithIDPatchRemove(c *C) {
 	c.Assert(mgr.Stop(), IsNil)
 }
 
+func (s *apparmorpromptingSuite) TestExportImportRules(c *C) {
+	_, reqChan, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.noticeMgr)
+	c.Assert(err, IsNil)
+
+	constraints := prompting.ConstraintsJSON{
+		"path-pattern": json.RawMessage(`"/home/test/**"`),
+		"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"}}`),
+	}
+	rule, err := mgr.AddRule(s.defaultUser, "firefox", "home", constraints)
+	c.Assert(err, IsNil)
+
+	exported, err := mgr.ExportRules(s.defaultUser, "firefox", "")
+	c.Assert(err, IsNil)
+	c.Assert(exported, HasLen, 1)
+	c.Check(exported[0].Snap, Equals, "firefox")
+	c.Check(exported[0].Interface, Equals, "home")
+
+	// Rules for other snaps are not exported
+	other, err := mgr.ExportRules(s.defaultUser, "thunderbird", "")
+	c.Assert(err, IsNil)
+	c.Check(other, HasLen, 0)
+
+	// Importing a rule with the same path pattern as an existing rule fails
+	_, err = mgr.ImportRules(s.defaultUser, exported)
+	c.Check(err, testutil.ErrorIs, prompting_errors.ErrRuleConflict)
+
+	_, err = mgr.RemoveRule(s.defaultUser, rule.ID)
+	c.Assert(err, IsNil)
+
+	// Add read request which the imported rule will satisfy
+	req, replyChan := requestWithReplyChan(&prompting.Request{
+		Permissions: []string{"read"},
+	})
+	_, prompt := s.simulateRequest(c, reqChan, mgr, req, false)
+
+	whenImported := time.Now()
+	imported, err := mgr.ImportRules(s.defaultUser, exported)
+	c.Assert(err, IsNil)
+	c.Assert(imported, HasLen, 1)
+	c.Check(imported[0].ID, Not(Equals), rule.ID)
+	s.checkRecordedRuleUpdateNotices(c, whenImported, 1)
+
+	// Check that prompt has been satisfied
+	const clientActivity = false
+	_, err = mgr.PromptWithID(s.defaultUser, prompt.ID, clientActivity)
+	c.Assert(err, Equals, prompting_errors.ErrPromptNotFound)
+	allowedPermissions, err := waitForReply(replyChan)
+	c.Assert(err, IsNil)
+	c.Check(allowedPermissions, DeepEquals, []string{"read"})
+
+	c.Assert(mgr.Stop(), IsNil)
+}
+
+func (s *apparmorpromptingSuite) TestEvaluateRequest(c *C) {
+	_, _, restore := apparmorprompting.MockListener()
+	defer restore()
+
+	mgr, err := apparmorprompting.New(s.noticeMgr)
+	c.Assert(err, IsNil)
+
+	constraints := prompting.ConstraintsJSON{
+		"path-pattern": json.RawMessage(`"/home/test/**"`),
+		"permissions":  json.RawMessage(`{"read":{"outcome":"allow","lifespan":"forever"},"write":{"outcome":"deny","lifespan":"forever"}}`),
+	}
+	rule, err := mgr.AddRule(s.defaultUser, "firefox", "home", constraints)
+	c.Assert(err, IsNil)
+
+	whenEvaluated := time.Now()
+	decision, err := mgr.EvaluateRequest(s.defaultUser, "firefox", "home", "/home/test/foo", []string{"read", "write", "execute"})
+	c.Assert(err, IsNil)
+	c.Check(decision, DeepEquals, &requestrules.RequestDecision{
+		AllowedPermissions:     []string{"read"},
+		DeniedPermissions:      []string{"write"},
+		OutstandingPermissions: []string{"execute"},
+		MatchedRules: map[string][]prompting.IDType{
+			"read":  {rule.ID},
+			"write": {rule.ID},
+		},
+	})
+	// No prompts or rule notices are recorded
+	s.checkRecordedRuleUpdateNotices(c, whenEvaluated, 0)
+	s.checkRecordedPromptNotices(c, whenEvaluated, 0)
+
+	// Rules of other users do not apply
+	decision, err = mgr.EvaluateRequest(s.defaultUser+1, "firefox", "home", "/home/test/foo", []string{"read"})
+	c.Assert(err, IsNil)
+	c.Check(decision.OutstandingPermissions, DeepEquals, []string{"read"})
+
+	for _, testCase := range []struct {
+		iface       string
+		path        string
+		permissions []string
+		errStr      string
+	}{
+		{"foo", "/home/test/foo", []string{"read"}, `invalid interface: "foo"`},
+		{"home", "/home/test/foo", nil, `invalid permissions for home interface: permissions empty`},
+		{"home", "/home/test/foo", []string{"read", "lock"}, `invalid permissions for home interface: "lock"`},
+		{"home", "foo", []string{"read"}, `invalid path: path must be absolute: "foo"`},
+	} {
+		_, err := mgr.EvaluateRequest(s.defaultUser, "firefox", testCase.iface, testCase.path, testCase.permissions)
+		c.Check(err, ErrorMatches, testCase.errStr, Commentf("testCase: %+v", testCase))
+	}
+
+	c.Assert(mgr.Stop(), IsNil)
+}
+
 func (s *apparmorpromptingSuite) TestListenerReadyAfterPromptsReady(c *C) {
 	listenerReady, _, restore := apparmorprompting.MockListener()
 	defer restore()
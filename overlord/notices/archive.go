@@ -0,0 +1,406 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+// Copyright (c) 2025 Canonical Ltd
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as
+// published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package notices
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+const (
+	// ArchiveNamespace is the namespace with which the notice archive
+	// registers itself with the notice manager. Archived notices keep the
+	// IDs they had before expiring, so it never occurs in notice IDs.
+	ArchiveNamespace = "archive"
+
+	// DefaultArchiveSegmentSize is the default size in bytes after which the
+	// active archive segment is rotated.
+	DefaultArchiveSegmentSize = 1024 * 1024
+	// DefaultArchiveSegmentAge is the default age after which the active
+	// archive segment is rotated.
+	DefaultArchiveSegmentAge = 7 * 24 * time.Hour
+	// DefaultArchiveRetention is the default duration for which a rotated
+	// archive segment is kept before being removed.
+	DefaultArchiveRetention = 90 * 24 * time.Hour
+
+	archiveSegmentSuffix = ".jsonl"
+)
+
+var timeNow = time.Now
+
+// ArchiveOptions controls the rotation and retention of a notice archive.
+// Zero values are replaced by the corresponding defaults.
+type ArchiveOptions struct {
+	// SegmentSize is the size in bytes after which the active archive segment
+	// is rotated.
+	SegmentSize int64
+	// SegmentAge is the age after which the active archive segment is
+	// rotated.
+	SegmentAge time.Duration
+	// Retention is how long a segment is kept after it was rotated.
+	Retention time.Duration
+}
+
+func (opts *ArchiveOptions) withDefaults() ArchiveOptions {
+	var o ArchiveOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = DefaultArchiveSegmentSize
+	}
+	if o.SegmentAge <= 0 {
+		o.SegmentAge = DefaultArchiveSegmentAge
+	}
+	if o.Retention <= 0 {
+		o.Retention = DefaultArchiveRetention
+	}
+	return o
+}
+
+// archiveSegment is a single append-only file of archived notices, with one
+// JSON-encoded notice per line. Segments are named after the time at which
+// they were created.
+type archiveSegment struct {
+	path    string
+	created time.Time
+	size    int64
+	// notices is only kept in memory for the active segment, rotated segments
+	// are read from disk when queried.
+	notices []*state.Notice
+}
+
+// ArchiveBackend is a notice backend which retains expired notices in an
+// on-disk, append-only archive, rotated by size and age. Only the notices of
+// the active segment are kept in memory.
+type ArchiveBackend struct {
+	dir  string
+	opts ArchiveOptions
+
+	// rwmu must be held for writing when archiving notices and held for
+	// reading when reading notices.
+	rwmu sync.RWMutex
+	// cond is used to broadcast when new notices are archived.
+	cond *sync.Cond
+	// segments is the list of archive segments ordered by creation time, the
+	// last one being the active segment to which notices are appended.
+	segments []*archiveSegment
+}
+
+var _ NoticeArchiver = (*ArchiveBackend)(nil)
+
+// NewArchiveBackend returns a notice archive backed by the given directory,
+// loading any previously archived notices from it.
+func NewArchiveBackend(dir string, opts *ArchiveOptions) (*ArchiveBackend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create notices archive directory: %w", err)
+	}
+	ab := &ArchiveBackend{
+		dir:  dir,
+		opts: opts.withDefaults(),
+	}
+	// Use ab.rwmu.RLocker() as the cond locker, since that is the lock which
+	// is held during BackendWaitNotices().
+	ab.cond = sync.NewCond(ab.rwmu.RLocker())
+	if err := ab.load(); err != nil {
+		return nil, err
+	}
+	ab.rwmu.Lock()
+	defer ab.rwmu.Unlock()
+	ab.pruneSegments(timeNow())
+	return ab, nil
+}
+
+// load finds the archive segments on disk and reads the notices of the active
+// one.
+func (ab *ArchiveBackend) load() error {
+	entries, err := os.ReadDir(ab.dir)
+	if err != nil {
+		return fmt.Errorf("cannot read notices archive directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, archiveSegmentSuffix) {
+			continue
+		}
+		nsec, err := strconv.ParseInt(strings.TrimSuffix(name, archiveSegmentSuffix), 10, 64)
+		if err != nil {
+			logger.Noticef("WARNING: ignoring unexpected file in notices archive: %s", name)
+			continue
+		}
+		ab.segments = append(ab.segments, &archiveSegment{
+			path:    filepath.Join(ab.dir, name),
+			created: time.Unix(0, nsec),
+		})
+	}
+	if len(ab.segments) == 0 {
+		return nil
+	}
+	sort.Slice(ab.segments, func(i, j int) bool {
+		return ab.segments[i].created.Before(ab.segments[j].created)
+	})
+	active := ab.segments[len(ab.segments)-1]
+	notices, size, err := active.read()
+	if err != nil {
+		return err
+	}
+	active.notices = notices
+	active.size = size
+	return nil
+}
+
+// read returns the notices stored in the segment file, along with its size.
+func (seg *archiveSegment) read() (notices []*state.Notice, size int64, err error) {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot open notices archive segment: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		size += int64(len(line)) + 1
+		var n state.Notice
+		if err := json.Unmarshal(line, &n); err != nil {
+			// A partially written line may be left behind if snapd was
+			// interrupted while archiving, so skip it rather than failing.
+			logger.Noticef("WARNING: ignoring invalid entry in notices archive segment %s: %v", seg.path, err)
+			continue
+		}
+		notices = append(notices, &n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("cannot read notices archive segment: %w", err)
+	}
+	return notices, size, nil
+}
+
+// ArchiveNotices appends the given notices to the active archive segment,
+// rotating it first if it is too large or too old, and removes segments which
+// are past their retention.
+func (ab *ArchiveBackend) ArchiveNotices(notices []*state.Notice) error {
+	if len(notices) == 0 {
+		return nil
+	}
+
+	var buf []byte
+	for _, n := range notices {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("cannot marshal notice: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	ab.rwmu.Lock()
+	defer ab.rwmu.Unlock()
+
+	now := timeNow()
+	seg := ab.activeSegment(now)
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open notices archive segment: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return fmt.Errorf("cannot write to notices archive segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cannot sync notices archive segment: %w", err)
+	}
+
+	if len(ab.segments) == 0 || ab.segments[len(ab.segments)-1] != seg {
+		if len(ab.segments) > 0 {
+			// The previous segment was rotated, its notices are now read
+			// from disk when needed.
+			ab.segments[len(ab.segments)-1].notices = nil
+		}
+		ab.segments = append(ab.segments, seg)
+	}
+	seg.size += int64(len(buf))
+	seg.notices = append(seg.notices, notices...)
+
+	ab.pruneSegments(now)
+
+	ab.cond.Broadcast()
+	return nil
+}
+
+// activeSegment returns the segment to which notices should be appended,
+// which is a new segment if the current one is too large or too old. The
+// caller must hold rwmu for writing.
+func (ab *ArchiveBackend) activeSegment(now time.Time) *archiveSegment {
+	if len(ab.segments) > 0 {
+		seg := ab.segments[len(ab.segments)-1]
+		if seg.size < ab.opts.SegmentSize && now.Sub(seg.created) < ab.opts.SegmentAge {
+			return seg
+		}
+	}
+	created := now
+	if len(ab.segments) > 0 && !created.After(ab.segments[len(ab.segments)-1].created) {
+		// Segment names must be unique and ordered.
+		created = ab.segments[len(ab.segments)-1].created.Add(time.Nanosecond)
+	}
+	return &archiveSegment{
+		path:    filepath.Join(ab.dir, strconv.FormatInt(created.UnixNano(), 10)+archiveSegmentSuffix),
+		created: created,
+	}
+}
+
+// pruneSegments removes the segments which were rotated more than the
+// retention duration ago. A segment is considered rotated when the segment
+// following it was created. The caller must hold rwmu for writing.
+func (ab *ArchiveBackend) pruneSegments(now time.Time) {
+	cutoff := now.Add(-ab.opts.Retention)
+	removed := 0
+	for i := 0; i < len(ab.segments)-1; i++ {
+		if !ab.segments[i+1].created.Before(cutoff) {
+			break
+		}
+		if err := os.Remove(ab.segments[i].path); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("WARNING: cannot remove expired notices archive segment: %v", err)
+			break
+		}
+		removed++
+	}
+	ab.segments = ab.segments[removed:]
+}
+
+// BackendNotices returns the list of archived notices that match the filter
+// (if any), ordered by the last-repeated time.
+func (ab *ArchiveBackend) BackendNotices(filter *state.NoticeFilter) []*state.Notice {
+	ab.rwmu.RLock()
+	defer ab.rwmu.RUnlock()
+	return ab.doNotices(filter)
+}
+
+// doNotices returns the list of archived notices that match the filter (if
+// any), ordered by the last-repeated time. The caller must hold rwmu for
+// reading.
+func (ab *ArchiveBackend) doNotices(filter *state.NoticeFilter) []*state.Notice {
+	var notices []*state.Notice
+	for i := range ab.segments {
+		if filter != nil && !filter.After.IsZero() && i+1 < len(ab.segments) && !filter.After.Before(ab.segments[i+1].created) {
+			// Notices are archived after they were last repeated, so
+			// none of those in a segment rotated before the After
+			// timestamp can match.
+			continue
+		}
+		for _, n := range ab.segmentNotices(i) {
+			if filter.Matches(n) {
+				notices = append(notices, n)
+			}
+		}
+	}
+	state.SortNotices(notices)
+	return notices
+}
+
+// segmentNotices returns the notices of the i-th segment, reading them from
+// disk unless it is the active segment. Segments which cannot be read are
+// skipped with a warning. The caller must hold rwmu for reading.
+func (ab *ArchiveBackend) segmentNotices(i int) []*state.Notice {
+	seg := ab.segments[i]
+	if i == len(ab.segments)-1 {
+		return seg.notices
+	}
+	notices, _, err := seg.read()
+	if err != nil {
+		logger.Noticef("WARNING: %v", err)
+		return nil
+	}
+	return notices
+}
+
+// BackendNotice returns a single archived notice by ID, or nil if not found.
+func (ab *ArchiveBackend) BackendNotice(id string) *state.Notice {
+	ab.rwmu.RLock()
+	defer ab.rwmu.RUnlock()
+	// Most lookups are for recently archived notices, so start with the
+	// newest segment.
+	for i := len(ab.segments) - 1; i >= 0; i-- {
+		for _, n := range ab.segmentNotices(i) {
+			if n.ID() == id {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// BackendWaitNotices waits for archived notices that match the filter to
+// exist, returning the list of matching notices ordered by the last-repeated
+// time.
+func (ab *ArchiveBackend) BackendWaitNotices(ctx context.Context, filter *state.NoticeFilter) ([]*state.Notice, error) {
+	ab.rwmu.RLock()
+	defer ab.rwmu.RUnlock()
+
+	notices := ab.doNotices(filter)
+	if len(notices) > 0 {
+		return notices, nil
+	}
+
+	// When the context is done/cancelled, wake up the waiters so that they
+	// can check their ctx.Err() and return if they're cancelled.
+	//
+	// TODO:GOVERSION: replace this with context.AfterFunc once we're on Go 1.21.
+	stop := osutil.ContextAfterFunc(ctx, func() {
+		// Acquire the lock for writing, since the cond lock is
+		// ab.rwmu.RLocker(), so that the Broadcast below cannot occur
+		// before the call to Wait.
+		ab.rwmu.Lock()
+		defer ab.rwmu.Unlock()
+
+		ab.cond.Broadcast()
+	})
+	defer stop()
+
+	for {
+		// As with the other backends, there is no point waiting once the
+		// BeforeOrAt timestamp has passed.
+		if filter != nil && !filter.BeforeOrAt.IsZero() && filter.BeforeOrAt.Before(timeNow()) {
+			return nil, nil
+		}
+
+		ab.cond.Wait()
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		notices = ab.doNotices(filter)
+		if len(notices) > 0 {
+			return notices, nil
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+// Copyright (c) 2025 Canonical Ltd
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License version 3 as
+// published by the Free Software Foundation.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package notices_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/notices"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type archiveSuite struct {
+	testutil.BaseTest
+
+	dir string
+	now time.Time
+}
+
+var _ = Suite(&archiveSuite{})
+
+func (s *archiveSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.dir = dirs.SnapNoticesArchiveDir
+	s.now = time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(notices.MockTimeNow(func() time.Time { return s.now }))
+}
+
+func (s *archiveSuite) segments(c *C) []string {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	c.Assert(err, IsNil)
+	return matches
+}
+
+func (s *archiveSuite) TestArchiveAndReload(c *C) {
+	ab, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	c.Check(ab.BackendNotices(nil), HasLen, 0)
+
+	userID := uint32(1000)
+	t1 := s.now.Add(-10 * 24 * time.Hour)
+	n1 := state.NewNotice("1", nil, state.ChangeUpdateNotice, "12", t1, map[string]string{"kind": "install-snap"}, 0, 7*24*time.Hour)
+	n2 := state.NewNotice("2", &userID, state.WarningNotice, "danger", t1.Add(time.Hour), nil, 0, 7*24*time.Hour)
+	c.Assert(ab.ArchiveNotices([]*state.Notice{n2, n1}), IsNil)
+	c.Check(s.segments(c), HasLen, 1)
+
+	archived := ab.BackendNotices(nil)
+	c.Assert(archived, HasLen, 2)
+	c.Check(archived[0].ID(), Equals, "1")
+	c.Check(archived[1].ID(), Equals, "2")
+	c.Check(ab.BackendNotice("2"), Equals, n2)
+	c.Check(ab.BackendNotice("3"), IsNil)
+
+	// Archived notices survive a restart
+	ab2, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	reloaded := ab2.BackendNotices(nil)
+	c.Assert(reloaded, HasLen, 2)
+	c.Check(reloaded[0].String(), Equals, n1.String())
+	c.Check(reloaded[0].LastData(), DeepEquals, map[string]string{"kind": "install-snap"})
+	uid, isSet := reloaded[1].UserID()
+	c.Check(isSet, Equals, true)
+	c.Check(uid, Equals, userID)
+
+	// Filters apply to archived notices
+	filtered := ab2.BackendNotices(&state.NoticeFilter{Types: []state.NoticeType{state.WarningNotice}})
+	c.Assert(filtered, HasLen, 1)
+	c.Check(filtered[0].ID(), Equals, "2")
+	filtered = ab2.BackendNotices(&state.NoticeFilter{After: t1})
+	c.Assert(filtered, HasLen, 1)
+	c.Check(filtered[0].ID(), Equals, "2")
+	otherUser := uint32(1001)
+	filtered = ab2.BackendNotices(&state.NoticeFilter{UserID: &otherUser})
+	c.Assert(filtered, HasLen, 1)
+	c.Check(filtered[0].ID(), Equals, "1")
+}
+
+func (s *archiveSuite) TestIgnoresTruncatedEntries(c *C) {
+	ab, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	n1 := state.NewNotice("1", nil, state.ChangeUpdateNotice, "12", s.now.Add(-8*24*time.Hour), nil, 0, 0)
+	c.Assert(ab.ArchiveNotices([]*state.Notice{n1}), IsNil)
+
+	segs := s.segments(c)
+	c.Assert(segs, HasLen, 1)
+	f, err := os.OpenFile(segs[0], os.O_WRONLY|os.O_APPEND, 0o600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`{"id":"2","type":"warn`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	ab2, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	c.Check(ab2.BackendNotices(nil), HasLen, 1)
+}
+
+func (s *archiveSuite) TestRotateBySize(c *C) {
+	ab, err := notices.NewArchiveBackend(s.dir, &notices.ArchiveOptions{SegmentSize: 1})
+	c.Assert(err, IsNil)
+
+	for i, id := range []string{"1", "2", "3"} {
+		s.now = s.now.Add(time.Minute)
+		n := state.NewNotice(id, nil, state.ChangeUpdateNotice, id, s.now.Add(-8*24*time.Hour), nil, 0, 0)
+		c.Assert(ab.ArchiveNotices([]*state.Notice{n}), IsNil)
+		c.Check(s.segments(c), HasLen, i+1)
+	}
+	c.Check(ab.BackendNotices(nil), HasLen, 3)
+
+	// Notices of rotated segments are read back from disk
+	c.Check(ab.BackendNotice("1"), NotNil)
+	c.Check(ab.BackendNotice("1").Key(), Equals, "1")
+	c.Check(ab.BackendNotice("4"), IsNil)
+
+	// Segments rotated before the After timestamp are not matched
+	filtered := ab.BackendNotices(&state.NoticeFilter{After: s.now.Add(-8*24*time.Hour - time.Minute)})
+	c.Assert(filtered, HasLen, 1)
+	c.Check(filtered[0].ID(), Equals, "3")
+
+	// Rotated segments are only read when queried
+	segs := s.segments(c)
+	c.Assert(os.WriteFile(segs[0], nil, 0o600), IsNil)
+	ab2, err := notices.NewArchiveBackend(s.dir, &notices.ArchiveOptions{SegmentSize: 1})
+	c.Assert(err, IsNil)
+	c.Check(ab2.BackendNotices(nil), HasLen, 2)
+}
+
+func (s *archiveSuite) TestRotateByAgeAndRetention(c *C) {
+	opts := &notices.ArchiveOptions{
+		SegmentAge: 24 * time.Hour,
+		Retention:  48 * time.Hour,
+	}
+	ab, err := notices.NewArchiveBackend(s.dir, opts)
+	c.Assert(err, IsNil)
+
+	archive := func(id string) {
+		n := state.NewNotice(id, nil, state.ChangeUpdateNotice, id, s.now.Add(-8*24*time.Hour), nil, 0, 0)
+		c.Assert(ab.ArchiveNotices([]*state.Notice{n}), IsNil)
+	}
+
+	archive("1")
+	s.now = s.now.Add(time.Hour)
+	archive("2")
+	c.Check(s.segments(c), HasLen, 1)
+
+	// The active segment is rotated once it is too old
+	s.now = s.now.Add(24 * time.Hour)
+	archive("3")
+	c.Check(s.segments(c), HasLen, 2)
+
+	// The first segment is kept until it was rotated more than the
+	// retention duration ago
+	s.now = s.now.Add(47 * time.Hour)
+	archive("4")
+	c.Check(s.segments(c), HasLen, 3)
+	c.Check(ab.BackendNotice("1"), NotNil)
+
+	s.now = s.now.Add(2 * time.Hour)
+	archive("5")
+	c.Check(s.segments(c), HasLen, 2)
+	c.Check(ab.BackendNotice("1"), IsNil)
+	c.Check(ab.BackendNotice("2"), IsNil)
+	c.Check(ab.BackendNotices(nil), HasLen, 3)
+
+	// Retention is also applied when loading the archive, but the active
+	// segment is always kept
+	s.now = s.now.Add(30 * 24 * time.Hour)
+	ab2, err := notices.NewArchiveBackend(s.dir, opts)
+	c.Assert(err, IsNil)
+	c.Check(s.segments(c), HasLen, 1)
+	c.Check(ab2.BackendNotices(nil), HasLen, 2)
+}
+
+func (s *archiveSuite) TestWaitNotices(c *C) {
+	ab, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		n := state.NewNotice("1", nil, state.ChangeUpdateNotice, "1", s.now.Add(-8*24*time.Hour), nil, 0, 0)
+		ab.ArchiveNotices([]*state.Notice{n})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := ab.BackendWaitNotices(ctx, nil)
+	c.Assert(err, IsNil)
+	c.Assert(result, HasLen, 1)
+	c.Check(result[0].ID(), Equals, "1")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, err = ab.BackendWaitNotices(ctx, &state.NoticeFilter{Keys: []string{"2"}})
+	c.Check(err, Equals, context.DeadlineExceeded)
+	c.Check(result, HasLen, 0)
+}
+
+func (s *archiveSuite) TestNoticeManagerArchivesStateNotices(c *C) {
+	st := state.New(nil)
+	nm := notices.NewNoticeManager(st)
+
+	ab, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	_, _, err = nm.RegisterBackend(ab, state.ChangeUpdateNotice, notices.ArchiveNamespace, false)
+	c.Assert(err, IsNil)
+
+	// Both state and archive provide change-update notices
+	backends := notices.RelevantBackendsForFilter(nm, &state.NoticeFilter{Types: []state.NoticeType{state.ChangeUpdateNotice}})
+	assertBackendsEqual(c, backends, []notices.NoticeBackend{nm.StateBackend(), ab})
+
+	st.Lock()
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	oldID, err := st.AddNotice(nil, state.ChangeUpdateNotice, "1", &state.AddNoticeOptions{Time: old})
+	c.Assert(err, IsNil)
+	_, err = st.AddNotice(nil, state.WarningNotice, "old warning", &state.AddNoticeOptions{Time: old})
+	c.Assert(err, IsNil)
+	newID, err := st.AddNotice(nil, state.ChangeUpdateNotice, "2", nil)
+	c.Assert(err, IsNil)
+	st.Prune(time.Now(), time.Hour, time.Hour, 100)
+	st.Unlock()
+
+	// Only the notice of the archived type was retained
+	archived := ab.BackendNotices(nil)
+	c.Assert(archived, HasLen, 1)
+	c.Check(archived[0].ID(), Equals, oldID)
+
+	// Queries span live and archived notices
+	result := nm.Notices(&state.NoticeFilter{
+		Types: []state.NoticeType{state.ChangeUpdateNotice},
+		After: old.Add(-time.Hour),
+	})
+	c.Assert(result, HasLen, 2)
+	c.Check(result[0].ID(), Equals, oldID)
+	c.Check(result[1].ID(), Equals, newID)
+
+	c.Check(nm.Notice(oldID), NotNil)
+	c.Check(nm.Notice(newID), NotNil)
+	c.Check(nm.Notice("unknown"), IsNil)
+}
+
+func (s *archiveSuite) TestRegisterBackendAfterArchiver(c *C) {
+	st := state.New(nil)
+	nm := notices.NewNoticeManager(st)
+
+	ab, err := notices.NewArchiveBackend(s.dir, nil)
+	c.Assert(err, IsNil)
+	_, _, err = nm.RegisterBackend(ab, state.InterfacesRequestsPromptNotice, notices.ArchiveNamespace, false)
+	c.Assert(err, IsNil)
+
+	// Another backend taking over the type replaces state, but not the archive
+	bknd := newTestNoticeBackend()
+	bknd.noticesChan <- nil
+	_, _, err = nm.RegisterBackend(bknd, state.InterfacesRequestsPromptNotice, "foo", false)
+	c.Assert(err, IsNil)
+
+	backends := notices.RelevantBackendsForFilter(nm, &state.NoticeFilter{Types: []state.NoticeType{state.InterfacesRequestsPromptNotice}})
+	assertBackendsEqual(c, backends, []notices.NoticeBackend{ab, bknd})
+
+	// Notices expired from other backends can be archived through the manager
+	n := state.NewNotice("foo-1", nil, state.InterfacesRequestsPromptNotice, "1", time.Now().Add(-48*time.Hour), nil, 0, 0)
+	nm.ArchiveExpiredNotices([]*state.Notice{n})
+	c.Check(ab.BackendNotice("foo-1"), Equals, n)
+
+	// Lookups fall back to the archive when the backend lost the notice
+	bknd.noticeChan <- nil
+	c.Check(nm.Notice("foo-1"), Equals, n)
+}
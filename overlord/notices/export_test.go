@@ -16,6 +16,12 @@
 
 package notices
 
+import (
+	"time"
+
+	"github.com/snapcore/snapd/testutil"
+)
+
 var (
 	RelevantBackendsForFilter = (*NoticeManager).relevantBackendsForFilter
 	DoNotices                 = doNotices
@@ -24,3 +30,7 @@ var (
 func (nm *NoticeManager) StateBackend() NoticeBackend {
 	return nm.state
 }
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
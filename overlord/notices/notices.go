@@ -23,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -46,6 +47,20 @@ type NoticeBackend interface {
 	BackendWaitNotices(ctx context.Context, filter *state.NoticeFilter) ([]*state.Notice, error)
 }
 
+// NoticeArchiver is a notice backend which retains notices after they have
+// expired from the backend which originally provided them.
+//
+// Unlike other notice backends, registering an archiver for a notice type does
+// not take over that type from the state: both the state and the archiver are
+// queried for notices of that type, so that filters can span live and archived
+// notices alike.
+type NoticeArchiver interface {
+	NoticeBackend
+
+	// ArchiveNotices records the given expired notices in the archive.
+	ArchiveNotices(notices []*state.Notice) error
+}
+
 // NoticeManager provides an abstraction layer over multiple notice backends,
 // ensuring correctness and consistency of notices and providing functions to
 // query notices across those backends.
@@ -78,6 +93,14 @@ type NoticeManager struct {
 	// noticeTypeBackends maps from notice type to the set of notice backends
 	// which are capable of providing notices of that type.
 	noticeTypeBackends map[state.NoticeType][]NoticeBackend
+
+	// archiversMu guards the archivers map. It is separate from rwMu since
+	// expired notices are archived while state lock is held, and rwMu may
+	// be held while waiting for state lock when registering a backend.
+	archiversMu sync.RWMutex
+	// archivers maps from notice type to the set of archivers registered to
+	// retain expired notices of that type.
+	archivers map[state.NoticeType][]NoticeArchiver
 }
 
 // stateBackend wraps a state to ensure that the state lock is acquired when
@@ -107,6 +130,7 @@ func NewNoticeManager(st *state.State) *NoticeManager {
 		backends:             []NoticeBackend{wrapper},
 		idNamespaceToBackend: make(map[string]NoticeBackend),
 		noticeTypeBackends:   make(map[state.NoticeType][]NoticeBackend),
+		archivers:            make(map[state.NoticeType][]NoticeArchiver),
 	}
 
 	return nm
@@ -127,6 +151,10 @@ func NewNoticeManager(st *state.State) *NoticeManager {
 // function will acquire state lock to drain notices from state, so the caller
 // must not hold state lock.
 //
+// If the backend is a NoticeArchiver, then it is registered to retain notices
+// of the given type once they expire, and the backends which were already
+// providing notices of that type, including the state, continue to do so.
+//
 // Returns a closure which can be used by the backend to validate new notices
 // added by that backend. The backend is responsible for ensuring that the
 // notices which it serves are valid according to the closure.
@@ -149,13 +177,28 @@ func (nm *NoticeManager) RegisterBackend(bknd NoticeBackend, typ state.NoticeTyp
 
 	nm.idNamespaceToBackend[namespace] = bknd
 
+	archiver, isArchiver := bknd.(NoticeArchiver)
+
 	typeBackends, ok := nm.noticeTypeBackends[typ]
-	if !ok {
+	switch {
+	case !ok && isArchiver:
+		// The state remains the provider of live notices of this type.
+		nm.noticeTypeBackends[typ] = []NoticeBackend{nm.state, bknd}
+	case !ok:
 		nm.noticeTypeBackends[typ] = []NoticeBackend{bknd}
-	} else if !backendsContain(typeBackends, bknd) {
+	case !backendsContain(typeBackends, bknd):
+		if !isArchiver {
+			// The state may have been kept as a provider of this type when
+			// an archiver was registered, but this backend now takes over.
+			typeBackends = removeBackend(typeBackends, nm.state)
+		}
 		nm.noticeTypeBackends[typ] = append(typeBackends, bknd)
 	}
 
+	if isArchiver {
+		nm.registerArchiver(archiver, typ)
+	}
+
 	// Since each backend may only be registered to a given notice type once,
 	// we can automatically update the state's lastNoticeTimestamp according
 	// to the timestamp of the last notice of this type from this backend.
@@ -207,6 +250,85 @@ func backendsContain(backends []NoticeBackend, backend NoticeBackend) bool {
 	return false
 }
 
+// removeBackend returns a copy of the given backends without the given
+// backend.
+func removeBackend(backends []NoticeBackend, backend NoticeBackend) []NoticeBackend {
+	newBackends := make([]NoticeBackend, 0, len(backends))
+	for _, bknd := range backends {
+		if bknd != backend {
+			newBackends = append(newBackends, bknd)
+		}
+	}
+	return newBackends
+}
+
+// registerArchiver registers the given archiver to retain expired notices of
+// the given type, and ensures that notices expiring from state are passed on
+// to the registered archivers.
+func (nm *NoticeManager) registerArchiver(archiver NoticeArchiver, typ state.NoticeType) {
+	nm.archiversMu.Lock()
+	defer nm.archiversMu.Unlock()
+	if len(nm.archivers) == 0 {
+		nm.state.SetNoticesExpiredHandler(nm.ArchiveExpiredNotices)
+	}
+	for _, existing := range nm.archivers[typ] {
+		if existing == archiver {
+			return
+		}
+	}
+	nm.archivers[typ] = append(nm.archivers[typ], archiver)
+}
+
+// ArchiveExpiredNotices passes the given expired notices on to the archivers
+// registered for their types. Notices of types for which no archiver is
+// registered are discarded.
+//
+// Notice backends should call this with the notices they drop on expiry, so
+// that they are retained if archiving is enabled. Errors from archivers are
+// logged, since notices are dropped regardless.
+func (nm *NoticeManager) ArchiveExpiredNotices(expired []*state.Notice) {
+	nm.archiversMu.RLock()
+	defer nm.archiversMu.RUnlock()
+	if len(nm.archivers) == 0 {
+		return
+	}
+	byArchiver := make(map[NoticeArchiver][]*state.Notice)
+	var archivers []NoticeArchiver
+	for _, n := range expired {
+		for _, archiver := range nm.archivers[n.Type()] {
+			if _, ok := byArchiver[archiver]; !ok {
+				archivers = append(archivers, archiver)
+			}
+			byArchiver[archiver] = append(byArchiver[archiver], n)
+		}
+	}
+	for _, archiver := range archivers {
+		if err := archiver.ArchiveNotices(byArchiver[archiver]); err != nil {
+			logger.Noticef("WARNING: cannot archive expired notices: %v", err)
+		}
+	}
+}
+
+// archivedNotice looks up a notice by ID across all registered archivers,
+// returning nil if none of them has it.
+func (nm *NoticeManager) archivedNotice(id string) *state.Notice {
+	nm.archiversMu.RLock()
+	defer nm.archiversMu.RUnlock()
+	checked := make(map[NoticeArchiver]bool)
+	for _, archivers := range nm.archivers {
+		for _, archiver := range archivers {
+			if checked[archiver] {
+				continue
+			}
+			checked[archiver] = true
+			if n := archiver.BackendNotice(id); n != nil {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
 // prefixFromID returns the namespace prefix from the given ID, if it has one.
 func prefixFromID(id string) (prefix string, ok bool) {
 	prefix, _, found := strings.Cut(id, "-")
@@ -320,25 +442,29 @@ func doNotices(backendsToCheck []NoticeBackend, filter *state.NoticeFilter, befo
 //
 // If the ID has a prefix but no backend is registered with a matching namespace, returns nil.
 //
+// If the notice is not found in the backend identified by the namespace, since
+// it may have expired, the registered archivers are checked for it.
+//
 // The caller must not hold state lock, as the manager may need to take it to
 // check notices from state.
 func (nm *NoticeManager) Notice(id string) *state.Notice {
 	nm.rwMu.RLock()
 	defer nm.rwMu.RUnlock()
 
-	prefix, ok := prefixFromID(id)
-	if !ok {
-		// No namespace prefix, so must be from state
-		return nm.state.BackendNotice(id)
+	var backend NoticeBackend = nm.state
+	if prefix, ok := prefixFromID(id); ok {
+		// Namespace prefix, so must be from a registered backend
+		backend, ok = nm.idNamespaceToBackend[prefix]
+		if !ok {
+			// No backend is capable of producing notices with this namespace prefix.
+			return nil
+		}
 	}
 
-	backend, ok := nm.idNamespaceToBackend[prefix]
-	if !ok {
-		// No backend is capable of producing notices with this namespace prefix.
-		return nil
+	if n := backend.BackendNotice(id); n != nil {
+		return n
 	}
-
-	return backend.BackendNotice(id)
+	return nm.archivedNotice(id)
 }
 
 // WaitNotices waits for notices that match the filter to exist or occur,
@@ -46,6 +46,7 @@ import (
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/confdbstate"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
 	"github.com/snapcore/snapd/overlord/devicemgmtstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
@@ -66,8 +67,10 @@ import (
 	_ "github.com/snapcore/snapd/overlord/snapstate/agentnotify"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
+	"github.com/snapcore/snapd/overlord/storeproxystate"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timings"
 )
@@ -127,6 +130,7 @@ type Overlord struct {
 	confdbMgr     *confdbstate.ConfdbManager
 	deviceMgmtMgr *devicemgmtstate.DeviceMgmtManager
 	certStateMgr  *certstate.CertManager
+	storeProxyMgr *storeproxystate.StoreProxyManager
 
 	// proxyConf mediates the http proxy config
 	proxyConf func(req *http.Request) (*url.URL, error)
@@ -154,6 +158,10 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	}
 
 	o.noticeMgr = notices.NewNoticeManager(s)
+	if err := setupNoticesArchive(s, o.noticeMgr); err != nil {
+		// The archive is optional, so don't prevent snapd from starting.
+		logger.Noticef("WARNING: cannot set up notices archive: %v", err)
+	}
 
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
@@ -217,9 +225,12 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		return nil, err
 	}
 	healthstate.Init(hookMgr)
+	o.addManager(healthstate.Manager(s))
 
 	o.addManager(devicemgmtstate.Manager(s, o.runner, deviceMgr))
 
+	o.addManager(storeproxystate.Manager(s))
+
 	// the shared task runner should be added last!
 	o.stateEng.AddManager(o.runner)
 
@@ -235,6 +246,91 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	return o, nil
 }
 
+// archivedNoticeTypes are the types of notices which are retained in the
+// notices archive once they expire, if the archive is enabled.
+var archivedNoticeTypes = []state.NoticeType{
+	state.ChangeUpdateNotice,
+	state.WarningNotice,
+	state.RefreshInhibitNotice,
+	state.SnapRunInhibitNotice,
+	state.InterfacesRequestsPromptNotice,
+	state.InterfacesRequestsRuleUpdateNotice,
+	state.SnapHealthNotice,
+	state.QuotaNearLimitNotice,
+	state.ConfdbChangeNotice,
+	state.ConfdbReplicationNotice,
+}
+
+// setupNoticesArchive registers a notices archive with the given notice
+// manager if enabled via the notices.archive.enabled system option. The
+// archive options are only read at startup.
+func setupNoticesArchive(s *state.State, noticeMgr *notices.NoticeManager) error {
+	s.Lock()
+	enabled, opts, err := noticesArchiveOptions(s)
+	s.Unlock()
+	if err != nil || !enabled {
+		return err
+	}
+
+	archive, err := notices.NewArchiveBackend(dirs.SnapNoticesArchiveDir, opts)
+	if err != nil {
+		return err
+	}
+	for _, typ := range archivedNoticeTypes {
+		if _, _, err := noticeMgr.RegisterBackend(archive, typ, notices.ArchiveNamespace, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noticesArchiveOptions reads the notices archive system options. Values
+// which cannot be parsed are ignored in favour of the defaults, as they are
+// validated by configcore when set.
+func noticesArchiveOptions(s *state.State) (enabled bool, opts *notices.ArchiveOptions, err error) {
+	tr := config.NewTransaction(s)
+	var enabledStr, sizeStr, ageStr, retentionStr string
+	for _, opt := range []struct {
+		key   string
+		value *string
+	}{
+		{"notices.archive.enabled", &enabledStr},
+		{"notices.archive.segment-size", &sizeStr},
+		{"notices.archive.segment-age", &ageStr},
+		{"notices.archive.retention", &retentionStr},
+	} {
+		// Values are stored with whatever JSON type they were set with
+		// (e.g. a bool for enabled=true), so convert them to strings the
+		// same way configcore does.
+		var v any = ""
+		if err := tr.Get("core", opt.key, &v); err != nil && !config.IsNoOption(err) {
+			return false, nil, err
+		}
+		*opt.value = fmt.Sprintf("%v", v)
+	}
+	if enabledStr != "true" {
+		return false, nil, nil
+	}
+
+	opts = &notices.ArchiveOptions{}
+	if sizeStr != "" {
+		if size, err := strutil.ParseByteSize(sizeStr); err == nil {
+			opts.SegmentSize = size
+		}
+	}
+	if ageStr != "" {
+		if age, err := time.ParseDuration(ageStr); err == nil {
+			opts.SegmentAge = age
+		}
+	}
+	if retentionStr != "" {
+		if retention, err := time.ParseDuration(retentionStr); err == nil {
+			opts.Retention = retention
+		}
+	}
+	return true, opts, nil
+}
+
 func (o *Overlord) addManager(mgr StateManager) {
 	switch x := mgr.(type) {
 	case *hookstate.HookManager:
@@ -265,6 +361,8 @@ func (o *Overlord) addManager(mgr StateManager) {
 		o.deviceMgmtMgr = x
 	case *certstate.CertManager:
 		o.certStateMgr = x
+	case *storeproxystate.StoreProxyManager:
+		o.storeProxyMgr = x
 	}
 	o.stateEng.AddManager(mgr)
 }
@@ -781,6 +879,12 @@ func (o *Overlord) CertManager() *certstate.CertManager {
 	return o.certStateMgr
 }
 
+// StoreProxyManager returns the manager responsible for serving the store
+// cache to other devices.
+func (o *Overlord) StoreProxyManager() *storeproxystate.StoreProxyManager {
+	return o.storeProxyMgr
+}
+
 // Mock creates an Overlord without any managers and with a backend
 // not using disk. Managers can be added with AddManager. For testing.
 func Mock() *Overlord {
@@ -133,6 +133,7 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.ConfdbManager(), NotNil)
 	c.Check(o.DeviceMgmtManager(), NotNil)
 	c.Check(o.CertManager(), NotNil)
+	c.Check(o.StoreProxyManager(), NotNil)
 	c.Check(configstateInitCalled, Equals, true)
 
 	o.InterfaceManager().DisableUDevMonitor()
@@ -266,6 +267,38 @@ func (ovs *overlordSuite) TestNewWithStateSnapmgrUpdate(c *C) {
 	c.Check(refreshPrivacyKey, HasLen, 16)
 }
 
+func (ovs *overlordSuite) TestNewWithNoticesArchive(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"some":"data","refresh-privacy-key":"0123456789ABCDEF","config":{"core":{"notices":{"archive":{"enabled":true,"segment-size":65536}}}}},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level, patch.Sublevel))
+	err := os.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(dirs.SnapNoticesArchiveDir, testutil.FilePresent)
+
+	st := o.State()
+	st.Lock()
+	_, err = st.AddNotice(nil, state.ChangeUpdateNotice, "1", &state.AddNoticeOptions{
+		Time: time.Now().Add(-8 * 24 * time.Hour),
+	})
+	c.Assert(err, IsNil)
+	st.Prune(time.Now(), time.Hour, time.Hour, 100)
+	st.Unlock()
+
+	// The expired notice was archived and can still be queried
+	c.Check(o.State().Notices(nil), HasLen, 0)
+	archived := o.NoticeManager().Notices(&state.NoticeFilter{Types: []state.NoticeType{state.ChangeUpdateNotice}})
+	c.Assert(archived, HasLen, 1)
+	c.Check(archived[0].Key(), Equals, "1")
+}
+
+func (ovs *overlordSuite) TestNewWithoutNoticesArchive(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	c.Check(o.NoticeManager(), NotNil)
+	c.Check(dirs.SnapNoticesArchiveDir, testutil.FileAbsent)
+}
+
 func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	fakeState := []byte(``)
 	err := os.WriteFile(dirs.SnapStateFile, fakeState, 0600)
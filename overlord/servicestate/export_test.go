@@ -20,6 +20,8 @@
 package servicestate
 
 import (
+	"time"
+
 	tomb "gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/overlord/state"
@@ -63,3 +65,22 @@ func MockResourcesCheckFeatureRequirements(f func(*quota.Resources) error) (rest
 	resourcesCheckFeatureRequirements = f
 	return r
 }
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
+
+func MockSampleQuotaGroupUsage(f func(grp *quota.Group) (*QuotaUsageSample, error)) (restore func()) {
+	return testutil.Mock(&sampleQuotaGroupUsage, f)
+}
+
+// QuotaUsageRingSamples adds the given samples to a ring of the given size,
+// resized to newSize afterwards, and returns what the ring holds.
+func QuotaUsageRingSamples(size, newSize int, samples []QuotaUsageSample) []QuotaUsageSample {
+	r := newQuotaUsageRing(size)
+	for _, sample := range samples {
+		r.add(sample)
+	}
+	r.resize(newSize)
+	return r.samples()
+}
@@ -100,6 +100,8 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 	// MemoryLimit requires systemd 211, so it's covered by the initial check
 	// CPUQuota requires systemd 213, so no further checks need to be done
 	// TasksMax requires systemd 228, so no further checks need to be done
+	// IO{Read,Write}{Bandwidth,IOPS}Max require systemd 230, so they are
+	// covered as well
 
 	// AllowedCPUs requires systemd 243, so we need to verify the version here
 	if resourceLimits.CPUSet != nil {
@@ -696,6 +696,21 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 				serviceName := fmt.Sprintf("systemd-journald@%s", grp.JournalNamespaceName())
 				journalsToRestart = append(journalsToRestart, serviceName)
 			}
+
+		case "nftables":
+			// the network rules of the group are loaded when starting its
+			// services, so they need to be restarted when the rules change,
+			// new rules come together with changed service files which
+			// already trigger the restart
+			if old != "" {
+				for info := range snapSvcMap {
+					for _, app := range info.Apps {
+						if app.IsService() {
+							markAppForRestart(info, app)
+						}
+					}
+				}
+			}
 		}
 	}
 	if err := wrappers.EnsureSnapServices(snapSvcMap, ensureOpts, collectModifiedUnits, meterLocked); err != nil {
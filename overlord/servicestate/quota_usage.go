@@ -0,0 +1,318 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+var timeNow = time.Now
+
+const (
+	// a day worth of samples at a 5 minutes interval
+	defaultQuotaUsageHistorySize  = 288
+	defaultQuotaNearLimitDuration = 15 * time.Minute
+
+	// usage is considered near the limit from this fraction of it
+	quotaNearLimitRatio = 0.9
+)
+
+// QuotaUsageSample is the resource usage of a quota group at a given time.
+type QuotaUsageSample struct {
+	Time time.Time `json:"time"`
+	// Memory is the memory used by the processes of the group.
+	Memory quantity.Size `json:"memory"`
+	// CPUTime is the total CPU time consumed by the group so far.
+	CPUTime time.Duration `json:"cpu-time"`
+	// Threads is the number of tasks (processes, threads) in the group.
+	Threads int `json:"threads"`
+	// Journal is the disk space used by the journal namespace of the
+	// group, if it has one.
+	Journal quantity.Size `json:"journal"`
+}
+
+// sampleQuotaGroupUsage reads the current resource usage of the given
+// quota group from the system.
+var sampleQuotaGroupUsage = func(grp *quota.Group) (*QuotaUsageSample, error) {
+	var sample QuotaUsageSample
+	var err error
+	if sample.Memory, err = grp.CurrentMemoryUsage(); err != nil {
+		return nil, err
+	}
+	if sample.CPUTime, err = grp.CurrentCPUUsage(); err != nil {
+		return nil, err
+	}
+	if sample.Threads, err = grp.CurrentTaskUsage(); err != nil {
+		return nil, err
+	}
+	if sample.Journal, err = grp.CurrentJournalUsage(); err != nil {
+		return nil, err
+	}
+	return &sample, nil
+}
+
+// quotaUsageOptions reads the quotas.usage.* system options. Values which
+// cannot be parsed are ignored in favour of the defaults, as they are
+// validated by configcore when set. Sampling is disabled unless an interval
+// is set.
+func quotaUsageOptions(st *state.State) (interval time.Duration, historySize int, nearLimit time.Duration, err error) {
+	tr := config.NewTransaction(st)
+	var intervalStr, sizeStr, nearLimitStr string
+	for _, opt := range []struct {
+		key   string
+		value *string
+	}{
+		{"quotas.usage.sample-interval", &intervalStr},
+		{"quotas.usage.history-size", &sizeStr},
+		{"quotas.usage.near-limit-duration", &nearLimitStr},
+	} {
+		var val any
+		if err := tr.Get("core", opt.key, &val); err != nil {
+			if config.IsNoOption(err) {
+				continue
+			}
+			return 0, 0, 0, err
+		}
+		*opt.value = fmt.Sprint(val)
+	}
+
+	if intervalStr != "" {
+		if d, err := time.ParseDuration(intervalStr); err == nil && d >= 0 {
+			interval = d
+		}
+	}
+	historySize = defaultQuotaUsageHistorySize
+	if sizeStr != "" {
+		if n, err := strconv.Atoi(sizeStr); err == nil && n > 0 {
+			historySize = n
+		}
+	}
+	nearLimit = defaultQuotaNearLimitDuration
+	if nearLimitStr != "" {
+		if d, err := time.ParseDuration(nearLimitStr); err == nil && d >= 0 {
+			nearLimit = d
+		}
+	}
+	return interval, historySize, nearLimit, nil
+}
+
+// quotaUsageRing is a bounded ring of usage samples, which drops the
+// oldest sample once full.
+type quotaUsageRing struct {
+	buf  []QuotaUsageSample
+	next int
+	full bool
+}
+
+func newQuotaUsageRing(size int) *quotaUsageRing {
+	return &quotaUsageRing{buf: make([]QuotaUsageSample, size)}
+}
+
+func (r *quotaUsageRing) add(sample QuotaUsageSample) {
+	r.buf[r.next] = sample
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// samples returns the samples in the ring, oldest first.
+func (r *quotaUsageRing) samples() []QuotaUsageSample {
+	if !r.full {
+		return append([]QuotaUsageSample(nil), r.buf[:r.next]...)
+	}
+	samples := make([]QuotaUsageSample, 0, len(r.buf))
+	samples = append(samples, r.buf[r.next:]...)
+	return append(samples, r.buf[:r.next]...)
+}
+
+// last returns the newest sample in the ring, if any.
+func (r *quotaUsageRing) last() *QuotaUsageSample {
+	if !r.full && r.next == 0 {
+		return nil
+	}
+	return &r.buf[(r.next+len(r.buf)-1)%len(r.buf)]
+}
+
+// resize changes the size of the ring, keeping the newest samples.
+func (r *quotaUsageRing) resize(size int) {
+	if size == len(r.buf) {
+		return
+	}
+	samples := r.samples()
+	if len(samples) > size {
+		samples = samples[len(samples)-size:]
+	}
+	*r = *newQuotaUsageRing(size)
+	for _, sample := range samples {
+		r.add(sample)
+	}
+}
+
+// quotaGroupUsage tracks the usage history of a quota group.
+type quotaGroupUsage struct {
+	ring *quotaUsageRing
+	// nearLimitSince records since when the usage of each resource has
+	// been near its limit.
+	nearLimitSince map[string]time.Time
+	// notified records the resources a notice was emitted for since their
+	// usage got near the limit.
+	notified map[string]bool
+}
+
+type quotaUsageHistoryKey struct{}
+
+func cachedQuotaUsageHistory(st *state.State) map[string]*quotaGroupUsage {
+	history, _ := st.Cached(quotaUsageHistoryKey{}).(map[string]*quotaGroupUsage)
+	if history == nil {
+		history = make(map[string]*quotaGroupUsage)
+		st.Cache(quotaUsageHistoryKey{}, history)
+	}
+	return history
+}
+
+// QuotaUsageHistory returns the sampled resource usage of the given quota
+// group, oldest first. The history is kept in memory only, so it starts
+// anew when snapd restarts.
+func QuotaUsageHistory(st *state.State, groupName string) []QuotaUsageSample {
+	history, _ := st.Cached(quotaUsageHistoryKey{}).(map[string]*quotaGroupUsage)
+	usage := history[groupName]
+	if usage == nil {
+		return nil
+	}
+	return usage.ring.samples()
+}
+
+// quotaResourceUsage is the usage of a limited resource of a quota group.
+type quotaResourceUsage struct {
+	usage, limit string
+	near         bool
+}
+
+// limitedResourcesUsage returns the usage of the resources of the group
+// which are subject to a limit, given a new sample and the one before it.
+func limitedResourcesUsage(grp *quota.Group, sample, prev *QuotaUsageSample) map[string]quotaResourceUsage {
+	res := make(map[string]quotaResourceUsage)
+	if grp.MemoryLimit != 0 {
+		res["memory"] = quotaResourceUsage{
+			usage: fmt.Sprint(uint64(sample.Memory)),
+			limit: fmt.Sprint(uint64(grp.MemoryLimit)),
+			near:  float64(sample.Memory) >= quotaNearLimitRatio*float64(grp.MemoryLimit),
+		}
+	}
+	if grp.ThreadLimit != 0 {
+		res["threads"] = quotaResourceUsage{
+			usage: strconv.Itoa(sample.Threads),
+			limit: strconv.Itoa(grp.ThreadLimit),
+			near:  float64(sample.Threads) >= quotaNearLimitRatio*float64(grp.ThreadLimit),
+		}
+	}
+	if grp.JournalLimit != nil && grp.JournalLimit.Size != 0 {
+		res["journal"] = quotaResourceUsage{
+			usage: fmt.Sprint(uint64(sample.Journal)),
+			limit: fmt.Sprint(uint64(grp.JournalLimit.Size)),
+			near:  float64(sample.Journal) >= quotaNearLimitRatio*float64(grp.JournalLimit.Size),
+		}
+	}
+	// the cpu usage is the percentage of a cpu used between two samples,
+	// the cpu time restarts from zero if the slice was restarted
+	if grp.CPULimit != nil && grp.CPULimit.Percentage != 0 && prev != nil &&
+		sample.Time.After(prev.Time) && sample.CPUTime >= prev.CPUTime {
+		count := grp.CPULimit.Count
+		if count == 0 {
+			count = 1
+		}
+		limit := count * grp.CPULimit.Percentage
+		usage := 100 * float64(sample.CPUTime-prev.CPUTime) / float64(sample.Time.Sub(prev.Time))
+		res["cpu"] = quotaResourceUsage{
+			usage: strconv.Itoa(int(usage + 0.5)),
+			limit: strconv.Itoa(limit),
+			near:  usage >= quotaNearLimitRatio*float64(limit),
+		}
+	}
+	return res
+}
+
+// recordQuotaUsage adds the given samples to the usage history of the
+// quota groups, and emits a quota-near-limit notice for the groups whose
+// usage of a resource has been near its limit for longer than nearLimit.
+// The state needs to be locked by the caller.
+func recordQuotaUsage(st *state.State, allGrps map[string]*quota.Group, samples map[string]*QuotaUsageSample, historySize int, nearLimit time.Duration) error {
+	history := cachedQuotaUsageHistory(st)
+	for name := range history {
+		if allGrps[name] == nil {
+			delete(history, name)
+		}
+	}
+
+	for name, sample := range samples {
+		grp := allGrps[name]
+		usage := history[name]
+		if usage == nil {
+			usage = &quotaGroupUsage{
+				ring:           newQuotaUsageRing(historySize),
+				nearLimitSince: make(map[string]time.Time),
+				notified:       make(map[string]bool),
+			}
+			history[name] = usage
+		}
+		usage.ring.resize(historySize)
+		prev := usage.ring.last()
+
+		for resource, ru := range limitedResourcesUsage(grp, sample, prev) {
+			if !ru.near {
+				delete(usage.nearLimitSince, resource)
+				delete(usage.notified, resource)
+				continue
+			}
+			since, ok := usage.nearLimitSince[resource]
+			if !ok {
+				usage.nearLimitSince[resource] = sample.Time
+				since = sample.Time
+			}
+			if usage.notified[resource] || sample.Time.Sub(since) < nearLimit {
+				continue
+			}
+			logger.Noticef("Usage of %s by quota group %q has been near its limit since %s.", resource, name, since.Format(time.RFC3339))
+			_, err := st.AddNotice(nil, state.QuotaNearLimitNotice, name, &state.AddNoticeOptions{
+				Data: map[string]string{
+					"resource": resource,
+					"usage":    ru.usage,
+					"limit":    ru.limit,
+					"since":    since.UTC().Format(time.RFC3339),
+				},
+			})
+			if err != nil {
+				return err
+			}
+			usage.notified[resource] = true
+		}
+		usage.ring.add(*sample)
+	}
+	return nil
+}
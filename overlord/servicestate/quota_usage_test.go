@@ -0,0 +1,220 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type quotaUsageSuite struct {
+	testutil.BaseTest
+
+	state *state.State
+	mgr   *servicestate.ServiceManager
+
+	now   time.Time
+	usage map[string]*servicestate.QuotaUsageSample
+}
+
+var _ = Suite(&quotaUsageSuite{})
+
+func (s *quotaUsageSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.state = state.New(nil)
+	s.mgr = servicestate.Manager(s.state, state.NewTaskRunner(s.state))
+
+	s.now = time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+	s.usage = make(map[string]*servicestate.QuotaUsageSample)
+	s.AddCleanup(servicestate.MockSampleQuotaGroupUsage(func(grp *quota.Group) (*servicestate.QuotaUsageSample, error) {
+		usage := s.usage[grp.Name]
+		if usage == nil {
+			return nil, fmt.Errorf("cannot read usage of %q", grp.Name)
+		}
+		sample := *usage
+		return &sample, nil
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	err := servicestatetest.MockQuotaInState(s.state, "foo", "", nil, nil,
+		quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithThreadLimit(100).Build())
+	c.Assert(err, IsNil)
+	err = servicestatetest.MockQuotaInState(s.state, "bar", "", nil, nil,
+		quota.NewResourcesBuilder().WithCPUCount(2).WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+}
+
+func (s *quotaUsageSuite) setOption(c *C, option string, value any) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", option, value), IsNil)
+	tr.Commit()
+}
+
+func (s *quotaUsageSuite) ensureAt(c *C, t time.Time) {
+	s.now = t
+	c.Assert(s.mgr.Ensure(), IsNil)
+}
+
+func (s *quotaUsageSuite) history(name string) []servicestate.QuotaUsageSample {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return servicestate.QuotaUsageHistory(s.state, name)
+}
+
+func (s *quotaUsageSuite) nearLimitNotices() []*state.Notice {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.QuotaNearLimitNotice}})
+}
+
+func (s *quotaUsageSuite) TestQuotaUsageRing(c *C) {
+	var samples []servicestate.QuotaUsageSample
+	for i := 1; i <= 5; i++ {
+		samples = append(samples, servicestate.QuotaUsageSample{Threads: i})
+	}
+
+	c.Check(servicestate.QuotaUsageRingSamples(8, 8, nil), HasLen, 0)
+	c.Check(servicestate.QuotaUsageRingSamples(8, 8, samples), DeepEquals, samples)
+	// the oldest samples are dropped once full
+	c.Check(servicestate.QuotaUsageRingSamples(3, 3, samples), DeepEquals, samples[2:])
+	// resizing keeps the newest samples
+	c.Check(servicestate.QuotaUsageRingSamples(3, 2, samples), DeepEquals, samples[3:])
+	c.Check(servicestate.QuotaUsageRingSamples(3, 5, samples), DeepEquals, samples[2:])
+	c.Check(servicestate.QuotaUsageRingSamples(8, 4, samples), DeepEquals, samples[1:])
+}
+
+func (s *quotaUsageSuite) TestQuotaUsageNotSampledByDefault(c *C) {
+	s.usage["foo"] = &servicestate.QuotaUsageSample{Memory: quantity.SizeMiB}
+
+	s.ensureAt(c, s.now)
+	c.Check(s.history("foo"), HasLen, 0)
+}
+
+func (s *quotaUsageSuite) TestQuotaUsageSampledOnInterval(c *C) {
+	s.setOption(c, "quotas.usage.sample-interval", "1m")
+	s.setOption(c, "quotas.usage.history-size", 3)
+	start := s.now
+
+	for i := 0; i < 4; i++ {
+		s.usage["foo"] = &servicestate.QuotaUsageSample{Memory: quantity.Size(i) * quantity.SizeMiB, Threads: i}
+		s.ensureAt(c, start.Add(time.Duration(i)*time.Minute))
+		// ensuring again before the interval elapsed does not sample
+		s.ensureAt(c, start.Add(time.Duration(i)*time.Minute+30*time.Second))
+	}
+
+	c.Check(s.history("foo"), DeepEquals, []servicestate.QuotaUsageSample{
+		{Time: start.Add(1 * time.Minute), Memory: 1 * quantity.SizeMiB, Threads: 1},
+		{Time: start.Add(2 * time.Minute), Memory: 2 * quantity.SizeMiB, Threads: 2},
+		{Time: start.Add(3 * time.Minute), Memory: 3 * quantity.SizeMiB, Threads: 3},
+	})
+	// bar could not be sampled
+	c.Check(s.history("bar"), HasLen, 0)
+	c.Check(s.nearLimitNotices(), HasLen, 0)
+
+	// disabling sampling drops the history
+	s.setOption(c, "quotas.usage.sample-interval", "0")
+	s.ensureAt(c, start.Add(10*time.Minute))
+	c.Check(s.history("foo"), HasLen, 0)
+}
+
+func (s *quotaUsageSuite) TestQuotaUsageNearLimitNotice(c *C) {
+	s.setOption(c, "quotas.usage.sample-interval", "1m")
+	s.setOption(c, "quotas.usage.near-limit-duration", "2m")
+	start := s.now
+
+	// above 90% of the memory limit for two minutes
+	s.usage["foo"] = &servicestate.QuotaUsageSample{Memory: 950 * quantity.SizeMiB, Threads: 10}
+	s.ensureAt(c, start)
+	s.ensureAt(c, start.Add(time.Minute))
+	c.Check(s.nearLimitNotices(), HasLen, 0)
+	s.ensureAt(c, start.Add(2*time.Minute))
+
+	notices := s.nearLimitNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "foo")
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"resource": "memory",
+		"usage":    fmt.Sprint(uint64(950 * quantity.SizeMiB)),
+		"limit":    fmt.Sprint(uint64(quantity.SizeGiB)),
+		"since":    "2026-03-18T12:00:00Z",
+	})
+	lastRepeated := notices[0].LastRepeated()
+
+	// no new notice while the usage stays near the limit
+	s.ensureAt(c, start.Add(5*time.Minute))
+	notices = s.nearLimitNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].LastRepeated(), Equals, lastRepeated)
+
+	// but there is one again when it gets near the limit again for long
+	s.usage["foo"] = &servicestate.QuotaUsageSample{Memory: 100 * quantity.SizeMiB, Threads: 10}
+	s.ensureAt(c, start.Add(6*time.Minute))
+	s.usage["foo"] = &servicestate.QuotaUsageSample{Memory: 100 * quantity.SizeMiB, Threads: 95}
+	s.ensureAt(c, start.Add(7*time.Minute))
+	s.ensureAt(c, start.Add(9*time.Minute))
+	notices = s.nearLimitNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].LastRepeated().After(lastRepeated), Equals, true)
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"resource": "threads",
+		"usage":    "95",
+		"limit":    "100",
+		"since":    "2026-03-18T12:07:00Z",
+	})
+}
+
+func (s *quotaUsageSuite) TestQuotaUsageNearCPULimitNotice(c *C) {
+	s.setOption(c, "quotas.usage.sample-interval", "1m")
+	s.setOption(c, "quotas.usage.near-limit-duration", "1m")
+	start := s.now
+
+	// bar can use a full cpu (2 cpus at 50%), it uses 57s of cpu time
+	// every minute
+	for i := 0; i < 3; i++ {
+		s.usage["bar"] = &servicestate.QuotaUsageSample{CPUTime: time.Duration(i) * 57 * time.Second}
+		s.ensureAt(c, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	notices := s.nearLimitNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "bar")
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"resource": "cpu",
+		"usage":    "95",
+		"limit":    "100",
+		"since":    "2026-03-18T12:01:00Z",
+	})
+}
@@ -46,6 +46,7 @@ import (
 
 func init() {
 	swfeats.RegisterEnsure("ServiceManager", "ensureSnapServicesUpdated")
+	swfeats.RegisterEnsure("ServiceManager", "ensureQuotaUsageSampled")
 }
 
 // ServiceManager is responsible for starting and stopping snap services.
@@ -53,6 +54,8 @@ type ServiceManager struct {
 	state *state.State
 
 	ensuredSnapSvcs bool
+
+	lastQuotaUsageSample time.Time
 }
 
 // Manager returns a new service manager.
@@ -211,9 +214,78 @@ func (m *ServiceManager) Ensure() error {
 	if err := m.ensureSnapServicesUpdated(); err != nil {
 		return err
 	}
+	if err := m.ensureQuotaUsageSampled(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// quotaGroupsToSample returns the quota groups whose usage should be
+// sampled now along with the sampling options, or no groups if it's not
+// time to sample yet.
+func (m *ServiceManager) quotaGroupsToSample(now time.Time) (allGrps map[string]*quota.Group, historySize int, nearLimit time.Duration, err error) {
+	var seeded bool
+	err = m.state.Get("seeded", &seeded)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, 0, 0, err
+	}
+	if !seeded {
+		return nil, 0, 0, nil
+	}
+
+	interval, historySize, nearLimit, err := quotaUsageOptions(m.state)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if interval == 0 {
+		m.state.Cache(quotaUsageHistoryKey{}, nil)
+		return nil, 0, 0, nil
+	}
+	if next := m.lastQuotaUsageSample.Add(interval); now.Before(next) {
+		m.state.EnsureBefore(next.Sub(now))
+		return nil, 0, 0, nil
+	}
+	m.state.EnsureBefore(interval)
+
+	allGrps, err = AllQuotas(m.state)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(allGrps) == 0 {
+		m.state.Cache(quotaUsageHistoryKey{}, nil)
+	}
+	return allGrps, historySize, nearLimit, nil
+}
+
+func (m *ServiceManager) ensureQuotaUsageSampled() error {
+	now := timeNow()
+	m.state.Lock()
+	allGrps, historySize, nearLimit, err := m.quotaGroupsToSample(now)
+	m.state.Unlock()
+	if err != nil || len(allGrps) == 0 {
+		return err
+	}
+
+	logger.Trace("ensure", "manager", "ServiceManager", "func", "ensureQuotaUsageSampled")
+
+	// reading the usage talks to systemd, so do it without the state lock
+	samples := make(map[string]*QuotaUsageSample, len(allGrps))
+	for name, grp := range allGrps {
+		sample, err := sampleQuotaGroupUsage(grp)
+		if err != nil {
+			logger.Noticef("cannot sample resource usage of quota group %q: %v", name, err)
+			continue
+		}
+		sample.Time = now
+		samples[name] = sample
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.lastQuotaUsageSample = now
+	return recordQuotaUsage(m.state, allGrps, samples, historySize, nearLimit)
+}
+
 func delayedCrossMgrInit() {
 	// hook into conflict checks mechanisms
 	snapstate.RegisterAffectedSnapsByAttr("service-action", serviceControlAffectedSnaps)
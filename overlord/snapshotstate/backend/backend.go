@@ -22,6 +22,7 @@ package backend
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"crypto"
@@ -110,6 +111,16 @@ func LastSnapshotSetID() (uint64, error) {
 // the function returns an error, iteration is stopped (and if the error isn't
 // Stop, it's returned as the error of the iterator).
 func Iter(ctx context.Context, f func(*Reader) error) error {
+	return iter(ctx, f, func(name string, err error) error {
+		// TODO: use warnings instead
+		logger.Noticef("Cannot open snapshot %q: %v.", name, err)
+		return nil
+	})
+}
+
+// iter is Iter, calling onOpenError for the snapshots which cannot be opened
+// at all. If onOpenError returns an error, iteration is stopped.
+func iter(ctx context.Context, f func(*Reader) error, onOpenError func(name string, err error) error) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -172,8 +183,7 @@ func Iter(ctx context.Context, f func(*Reader) error) error {
 			if reader != nil {
 				err = f(reader)
 			} else {
-				// TODO: use warnings instead
-				logger.Noticef("Cannot open snapshot %q: %v.", name, openError)
+				err = onOpenError(name, openError)
 			}
 			if openError == nil {
 				// if openError was nil the snapshot was opened and needs closing
@@ -323,11 +333,40 @@ func MapSnapDataDirToSnapVar(si *snap.Info, opts *dirs.SnapDirOptions, usernames
 	return mappings, nil
 }
 
+// SaveFlags carries extra flags to drive save behavior.
+type SaveFlags struct {
+	// Incremental tells save to store the archives' data as chunks
+	// shared with other snapshots, instead of in the snapshot file.
+	Incremental bool
+	// Key, if set, is the passphrase or the content of the key file
+	// the snapshot archives are encrypted with. Encrypted snapshots
+	// cannot be incremental.
+	Key []byte
+}
+
 // Save a snapshot
-func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, flags *SaveFlags) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+	var key *snapshotKey
+	if flags.Key != nil {
+		if flags.Incremental {
+			return nil, errors.New("cannot save an encrypted snapshot incrementally")
+		}
+		var err error
+		if key, err = newSnapshotKey(flags.Key); err != nil {
+			return nil, err
+		}
+	}
+	if flags.Incremental {
+		// the chunks are not referenced until the snapshot is committed
+		chunkStoreLock.RLock()
+		defer chunkStoreLock.RUnlock()
+	}
 
 	snapshot := &client.Snapshot{
 		SetID:    id,
@@ -344,6 +383,8 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 		Size:     0,
 		Conf:     cfg,
 		// Note: Auto is no longer set in the Snapshot.
+		Incremental: flags.Incremental,
+		Encrypted:   key != nil,
 	}
 
 	snapshotOptions, err := snapReadSnapshotYaml(si)
@@ -366,9 +407,24 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 
 	w := zip.NewWriter(aw)
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
+	if key != nil {
+		paramsWriter, err := w.Create(encryptionName)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.NewEncoder(paramsWriter).Encode(key.params); err != nil {
+			return nil, err
+		}
+		// the configuration often holds secrets, so it's kept out of
+		// the metadata, which is readable without the key
+		if err := addEncryptedConfToZip(w, cfg, key); err != nil {
+			return nil, err
+		}
+		snapshot.Conf = nil
+	}
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
-	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude, key); err != nil {
 		return nil, err
 	}
 
@@ -380,7 +436,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 	savingUserData = true
 	for _, usr := range users {
 		snapDataDir := filepath.Dir(si.UserDataDir(usr.HomeDir, dirOpts))
-		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude, key); err != nil {
 			return nil, err
 		}
 	}
@@ -390,8 +446,9 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 		return nil, err
 	}
 
+	var meta bytes.Buffer
 	hasher := crypto.SHA3_384.New()
-	enc := json.NewEncoder(io.MultiWriter(metaWriter, hasher))
+	enc := json.NewEncoder(io.MultiWriter(metaWriter, hasher, &meta))
 	if err := enc.Encode(snapshot); err != nil {
 		return nil, err
 	}
@@ -401,6 +458,13 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 		return nil, err
 	}
 	fmt.Fprintf(hashWriter, "%x\n", hasher.Sum(nil))
+	if key != nil {
+		macWriter, err := w.Create(metaMACName)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(macWriter, "%s\n", key.metadataMAC(meta.Bytes()))
+	}
 	if err := w.Close(); err != nil {
 		return nil, err
 	}
@@ -418,10 +482,27 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 
 var isTesting = snapdenv.Testing()
 
+// addEncryptedConfToZip adds the given snap configuration to the snapshot,
+// encrypted with the given key.
+func addEncryptedConfToZip(w *zip.Writer, cfg map[string]any, key *snapshotKey) error {
+	confWriter, err := w.Create(encryptedConfName)
+	if err != nil {
+		return err
+	}
+	encrypted, err := key.newEncryptWriter(confWriter)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(encrypted).Encode(cfg); err != nil {
+		return err
+	}
+	return encrypted.Close()
+}
+
 // addSnapDirToZip adds the 'common' and the 'rev' revisioned dir under 'snapDir'
 // to the snapshot. If one doesn't exist, it's ignored. If none exists, the
 // operation is skipped.
-func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string, key *snapshotKey) error {
 	paths, err := pathsForSnapshot(snapDir, snapshot)
 	if err != nil {
 		return err
@@ -464,24 +545,28 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		expExcludePaths = append(expExcludePaths, expandedPath)
 	}
 
-	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths)
+	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths, key)
 }
 
 // addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
 // directory before creating the archive so that parent dirs are not added.
-func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
+// If key is not nil the archive is encrypted with it.
+func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string, key *snapshotKey) error {
 	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
 	if err != nil {
 		return err
 	}
 
-	tarArgs := []string{
-		"--create",
-		"--sparse", "--gzip",
+	tarArgs := []string{"--create", "--sparse"}
+	if !snapshot.Incremental {
+		// chunks are compressed individually instead
+		tarArgs = append(tarArgs, "--gzip")
+	}
+	tarArgs = append(tarArgs,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
-	}
+	)
 
 	for _, path := range excludePaths {
 		tarArgs = append(tarArgs, fmt.Sprintf("--exclude=%s", path))
@@ -496,8 +581,23 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 	var sz osutil.Sizer
 	hasher := crypto.SHA3_384.New()
 
+	var out io.Writer = archiveWriter
+	var chunks *chunkWriter
+	var encrypted *encryptWriter
+	switch {
+	case snapshot.Incremental:
+		chunks = &chunkWriter{}
+		out = chunks
+	case key != nil:
+		encrypted, err = key.newEncryptWriter(archiveWriter)
+		if err != nil {
+			return err
+		}
+		out = encrypted
+	}
+
 	cmd := tarAsUser(ctx, username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stdout = io.MultiWriter(out, hasher, &sz)
 
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
@@ -527,6 +627,20 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
+	if chunks != nil {
+		if err := chunks.Close(); err != nil {
+			return err
+		}
+		if err := json.NewEncoder(archiveWriter).Encode(&chunks.index); err != nil {
+			return err
+		}
+	}
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			return err
+		}
+	}
+
 	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
 	snapshot.Size += sz.Size()
 
@@ -754,6 +868,9 @@ type ImportFlags struct {
 	// noDuplicatedImportCheck tells import not to check for existing snapshot
 	// with same content hash (and not report DuplicatedSnapshotImportError).
 	NoDuplicatedImportCheck bool
+	// Key is the passphrase or the content of the key file needed to
+	// decrypt an encrypted export, and to verify encrypted snapshots.
+	Key []byte
 }
 
 // Import a snapshot from the export file format
@@ -774,11 +891,19 @@ func Import(ctx context.Context, id uint64, r io.Reader, flags *ImportFlags) (sn
 	// Cancel once Committed is a NOP
 	defer tr.Cancel()
 
+	// imported chunks are not referenced until the snapshots are in place
+	chunkStoreLock.RLock()
+	defer chunkStoreLock.RUnlock()
+
 	// Unpack and validate the streamed data
 	//
 	// XXX: this will leak snapshot IDs, i.e. we allocate a new
 	// snapshot ID before but then we error here because of e.g.
 	// duplicated import attempts
+	r, err = maybeDecryptExport(r, flags)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", errPrefix, err)
+	}
 	snapNames, err = unpackVerifySnapshotImport(ctx, r, id, flags)
 	if err != nil {
 		if _, ok := err.(DuplicatedSnapshotImportError); ok {
@@ -895,6 +1020,14 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 			continue
 		}
 
+		if strings.HasPrefix(header.Name, chunksDirName+"/") {
+			id := strings.TrimPrefix(header.Name, chunksDirName+"/")
+			if err := importChunk(id, tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		if header.Name == "export.json" {
 			// XXX: read into memory and validate once we
 			// hashes in export.json
@@ -922,7 +1055,14 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 		if err != nil {
 			return snapNames, fmt.Errorf("cannot open snapshot: %v", err)
 		}
-		err = r.Check(context.TODO(), nil)
+		if r.Encrypted && flags.Key == nil {
+			r.Close()
+			return snapNames, fmt.Errorf("cannot verify encrypted snapshot %q without its passphrase or key file", targetPath)
+		}
+		err = r.Unlock(flags.Key)
+		if err == nil {
+			err = r.Check(context.TODO(), nil)
+		}
 		r.Close()
 		snapNames = append(snapNames, r.Snap)
 		if err != nil {
@@ -944,10 +1084,53 @@ type exportMetadata struct {
 	Files  []string  `json:"files"`
 }
 
+// encryptedExportMagic starts encrypted exports, followed by the
+// encryption parameters and then the encrypted export data.
+const encryptedExportMagic = "snapd encrypted snapshot export\n"
+
+// maybeDecryptExport returns a reader of the decrypted export if r is an
+// encrypted export, or of r itself otherwise.
+func maybeDecryptExport(r io.Reader, flags *ImportFlags) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(encryptedExportMagic))
+	if err != nil || string(magic) != encryptedExportMagic {
+		// not encrypted, or too short to be; let the import
+		// deal with it
+		return br, nil
+	}
+	if flags == nil || flags.Key == nil {
+		return nil, errors.New("snapshot export is encrypted, a passphrase or key file is needed")
+	}
+	br.Discard(len(encryptedExportMagic))
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cannot read encrypted export header: %v", err)
+	}
+	params, err := readEncryptionParams(bytes.NewReader(line))
+	if err != nil {
+		return nil, err
+	}
+	key, err := openSnapshotKey(flags.Key, params)
+	if err != nil {
+		return nil, err
+	}
+	return key.newDecryptReader(br), nil
+}
+
+// ExportFlags carries extra flags to drive export behavior.
+type ExportFlags struct {
+	// Key, if set, is the passphrase or the content of the key file
+	// the export is encrypted with.
+	Key []byte
+}
+
 type SnapshotExport struct {
 	// open snapshot files
 	snapshotFiles []*os.File
 
+	// open chunk files used by incremental snapshots
+	chunkFiles []*os.File
+
 	// contentHash of the full snapshot
 	contentHash []byte
 
@@ -956,12 +1139,16 @@ type SnapshotExport struct {
 
 	// cached size, needs to be calculated with CalculateSize
 	size int64
+
+	// key the export is encrypted with, if any
+	key *snapshotKey
 }
 
 // NewSnapshotExport will return a SnapshotExport structure. It must be
 // Close()ed after use to avoid leaking file descriptors.
-func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
+func NewSnapshotExport(ctx context.Context, setID uint64, flags *ExportFlags) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
+	var chunkFiles []*os.File
 	var snapshotSet client.SnapshotSet
 
 	defer func() {
@@ -970,9 +1157,14 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 			for _, f := range snapshotFiles {
 				f.Close()
 			}
+			for _, f := range chunkFiles {
+				f.Close()
+			}
 		}
 	}()
 
+	seenChunks := make(map[string]bool)
+
 	// Open all files first and keep the file descriptors
 	// open. The caller should have locked the state so that no
 	// delete/change snapshot operations can happen while the
@@ -995,6 +1187,24 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 				return fmt.Errorf("cannot open file from descriptor %d", fd)
 			}
 			snapshotFiles = append(snapshotFiles, f)
+
+			// the data of incremental snapshots is exported
+			// along with them
+			ids, err := reader.chunkIDs()
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if seenChunks[id] {
+					continue
+				}
+				seenChunks[id] = true
+				f, err := os.Open(chunkPath(id))
+				if err != nil {
+					return fmt.Errorf("cannot open snapshot chunk: %v", err)
+				}
+				chunkFiles = append(chunkFiles, f)
+			}
 		}
 		return nil
 	})
@@ -1009,7 +1219,13 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 	if err != nil {
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
-	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	var key *snapshotKey
+	if flags != nil && flags.Key != nil {
+		if key, err = newSnapshotKey(flags.Key); err != nil {
+			return nil, fmt.Errorf("cannot export snapshot %v: %v", setID, err)
+		}
+	}
+	se = &SnapshotExport{snapshotFiles: snapshotFiles, chunkFiles: chunkFiles, setID: setID, contentHash: h, key: key}
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -1046,6 +1262,34 @@ func (se *SnapshotExport) Close() {
 		f.Close()
 	}
 	se.snapshotFiles = nil
+	for _, f := range se.chunkFiles {
+		f.Close()
+	}
+	se.chunkFiles = nil
+}
+
+func writeExportFile(tw *tar.Writer, f *os.File, name string) error {
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return fmt.Errorf("cannot seek on %v: %v", stat.Name(), err)
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     stat.Size(),
+		Mode:     0600,
+		ModTime:  stat.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write header for %v: %v", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("cannot write data for %v: %v", name, err)
+	}
+	return nil
 }
 
 type contentJSON struct {
@@ -1053,6 +1297,28 @@ type contentJSON struct {
 }
 
 func (se *SnapshotExport) StreamTo(w io.Writer) error {
+	if se.key == nil {
+		return se.streamTarTo(w)
+	}
+
+	header, err := json.Marshal(se.key.params)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%s%s\n", encryptedExportMagic, header); err != nil {
+		return err
+	}
+	ew, err := se.key.newEncryptWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := se.streamTarTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func (se *SnapshotExport) streamTarTo(w io.Writer) error {
 	// write out a tar
 	var files []string
 	tw := tar.NewWriter(w)
@@ -1077,6 +1343,14 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		return err
 	}
 
+	// write out the chunks first, so they are available when the
+	// snapshots are checked on import
+	for _, chunkFile := range se.chunkFiles {
+		if err := writeExportFile(tw, chunkFile, path.Join(chunksDirName, filepath.Base(chunkFile.Name()))); err != nil {
+			return err
+		}
+	}
+
 	// write out the individual snapshots
 	for _, snapshotFile := range se.snapshotFiles {
 		stat, err := snapshotFile.Stat()
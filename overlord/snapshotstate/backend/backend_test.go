@@ -458,7 +458,7 @@ func (s *snapshotSuite) TestIterSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]any{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -695,7 +695,7 @@ func (s *snapshotSuite) TestAddDirToZipExclusions(c *check.C) {
 }
 
 func (s *snapshotSuite) TestHappyRoundtrip(c *check.C) {
-	s.testHappyRoundtrip(c, "marker")
+	s.testHappyRoundtrip(c, "marker", nil)
 }
 
 func (s *snapshotSuite) TestHappyRoundtripNoCommon(c *check.C) {
@@ -704,7 +704,7 @@ func (s *snapshotSuite) TestHappyRoundtripNoCommon(c *check.C) {
 			c.Assert(os.RemoveAll(t.dir), check.IsNil)
 		}
 	}
-	s.testHappyRoundtrip(c, "marker")
+	s.testHappyRoundtrip(c, "marker", nil)
 }
 
 func (s *snapshotSuite) TestHappyRoundtripNoRev(c *check.C) {
@@ -713,10 +713,122 @@ func (s *snapshotSuite) TestHappyRoundtripNoRev(c *check.C) {
 			c.Assert(os.RemoveAll(t.dir), check.IsNil)
 		}
 	}
-	s.testHappyRoundtrip(c, "../common/marker")
+	s.testHappyRoundtrip(c, "../common/marker", nil)
 }
 
-func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
+func (s *snapshotSuite) TestHappyRoundtripIncremental(c *check.C) {
+	s.testHappyRoundtrip(c, "marker", &backend.SaveFlags{Incremental: true})
+}
+
+func (s *snapshotSuite) TestHappyRoundtripEncrypted(c *check.C) {
+	defer backend.MockScryptCost(1 << 4)()
+	s.testHappyRoundtrip(c, "marker", &backend.SaveFlags{Key: []byte("secret")})
+}
+
+func (s *snapshotSuite) TestSaveEncryptedIncremental(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	_, err := backend.Save(context.TODO(), 12, info, nil, nil, nil, nil, &backend.SaveFlags{Incremental: true, Key: []byte("secret")})
+	c.Check(err, check.ErrorMatches, "cannot save an encrypted snapshot incrementally")
+}
+
+// readZipMembers returns the raw content of the members of the given zip
+// file, by name.
+func readZipMembers(c *check.C, fn string) map[string][]byte {
+	zr, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	defer zr.Close()
+
+	members := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		r, err := f.Open()
+		c.Assert(err, check.IsNil)
+		data, err := io.ReadAll(r)
+		r.Close()
+		c.Assert(err, check.IsNil)
+		members[f.Name] = data
+	}
+	return members
+}
+
+// writeZipMembers replaces the given zip file with one holding the given
+// members.
+func writeZipMembers(c *check.C, fn string, members map[string][]byte) {
+	f, err := os.Create(fn)
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, data := range members {
+		mw, err := w.Create(name)
+		c.Assert(err, check.IsNil)
+		_, err = mw.Write(data)
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(w.Close(), check.IsNil)
+}
+
+func (s *snapshotSuite) TestSaveEncryptedHidesConfig(c *check.C) {
+	defer backend.MockScryptCost(1 << 4)()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]any{"password": "very-secret-value"}
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, nil, nil, nil, &backend.SaveFlags{Key: []byte("secret")})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Conf, check.IsNil)
+
+	// without the key, the configuration cannot be found in the snapshot
+	members := readZipMembers(c, backend.Filename(shw))
+	c.Check(members["conf.json"], check.NotNil)
+	c.Check(members["meta.mac"], check.NotNil)
+	for name, data := range members {
+		c.Check(bytes.Contains(data, []byte("very-secret-value")), check.Equals, false, check.Commentf("%s", name))
+		c.Check(bytes.Contains(data, []byte("password")), check.Equals, false, check.Commentf("%s", name))
+	}
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Conf, check.IsNil)
+
+	// unlocking the snapshot decrypts its configuration
+	c.Assert(shr.Unlock([]byte("secret")), check.IsNil)
+	c.Check(shr.Conf, check.DeepEquals, cfg)
+}
+
+func (s *snapshotSuite) TestEncryptedMetadataTampered(c *check.C) {
+	defer backend.MockScryptCost(1 << 4)()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, nil, nil, &backend.SaveFlags{Key: []byte("secret")})
+	c.Assert(err, check.IsNil)
+
+	// alter the metadata, keeping its hash consistent
+	fn := backend.Filename(shw)
+	members := readZipMembers(c, fn)
+	meta := bytes.Replace(members["meta.json"], []byte(`"version":"v1.33"`), []byte(`"version":"v6.66"`), 1)
+	c.Assert(meta, check.Not(check.DeepEquals), members["meta.json"])
+	members["meta.json"] = meta
+	h := crypto.SHA3_384.New()
+	h.Write(meta)
+	members["meta.sha3_384"] = []byte(fmt.Sprintf("%x\n", h.Sum(nil)))
+	writeZipMembers(c, fn, members)
+
+	shr, err := backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Version, check.Equals, "v6.66")
+	c.Check(shr.Unlock([]byte("secret")), check.Equals, backend.ErrMetadataTampered)
+
+	// or drop its MAC
+	delete(members, "meta.mac")
+	writeZipMembers(c, fn, members)
+	shr, err = backend.Open(fn, backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Unlock([]byte("secret")), check.ErrorMatches, `missing archive member "meta.mac"`)
+}
+
+func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, flags *backend.SaveFlags) {
 	if os.Geteuid() == 0 {
 		c.Skip("this test cannot run as root (runuser will fail)")
 	}
@@ -740,15 +852,25 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 		return statSnapshotOpts, nil
 	})()
 
-	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil)
+	incremental := flags != nil && flags.Incremental
+	encrypted := flags != nil && flags.Key != nil
+
+	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil, flags)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
+	c.Check(shw.Incremental, check.Equals, incremental)
+	c.Check(shw.Encrypted, check.Equals, encrypted)
 	c.Check(shw.Snap, check.Equals, info.InstanceName())
 	c.Check(shw.SnapID, check.Equals, info.SnapID)
 	c.Check(shw.Version, check.Equals, info.Version)
 	c.Check(shw.Epoch, check.DeepEquals, epoch)
 	c.Check(shw.Revision, check.Equals, info.Revision)
-	c.Check(shw.Conf, check.DeepEquals, cfg)
+	if encrypted {
+		// the configuration is only available once unlocked
+		c.Check(shw.Conf, check.IsNil)
+	} else {
+		c.Check(shw.Conf, check.DeepEquals, cfg)
+	}
 	c.Check(shw.Auto, check.Equals, false)
 	c.Check(shw.Options, check.DeepEquals, dynSnapshotOpts)
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
@@ -773,20 +895,40 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 		c.Check(sh.Version, check.Equals, info.Version, comm)
 		c.Check(sh.Epoch, check.DeepEquals, epoch)
 		c.Check(sh.Revision, check.Equals, info.Revision, comm)
-		c.Check(sh.Conf, check.DeepEquals, cfg, comm)
+		if encrypted {
+			c.Check(sh.Conf, check.IsNil, comm)
+		} else {
+			c.Check(sh.Conf, check.DeepEquals, cfg, comm)
+		}
 		c.Check(sh.SHA3_384, check.DeepEquals, shw.SHA3_384, comm)
 		c.Check(sh.Auto, check.Equals, false)
 		c.Check(sh.Options, check.DeepEquals, dynSnapshotOpts)
+		c.Check(sh.Incremental, check.Equals, incremental)
+		c.Check(sh.Encrypted, check.Equals, encrypted)
 	}
 	c.Check(shr.Name(), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
+	if encrypted {
+		c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `snapshot ".*" is encrypted, a passphrase or key file is needed`)
+		c.Check(shr.Unlock([]byte("not the secret")), check.Equals, backend.ErrWrongKey)
+		c.Assert(shr.Unlock(flags.Key), check.IsNil)
+		c.Check(shr.Conf, check.DeepEquals, cfg)
+	}
 	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
 
+	chunksDir := filepath.Join(dirs.SnapshotsDir, "chunks")
+	c.Check(osutil.IsDirectory(chunksDir), check.Equals, incremental)
+
 	newroot := c.MkDir()
 	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
 	dirs.SetRootDir(newroot)
+	if incremental {
+		// the chunks are looked up in the current root
+		c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), check.IsNil)
+		c.Assert(os.Symlink(chunksDir, filepath.Join(dirs.SnapshotsDir, "chunks")), check.IsNil)
+	}
 
 	var diff = func() *exec.Cmd {
-		cmd := exec.Command("diff", "-urN", "-x*.zip", s.root, newroot)
+		cmd := exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot)
 		// cmd.Stdout = os.Stdout
 		// cmd.Stderr = os.Stderr
 		return cmd
@@ -818,7 +960,7 @@ func (s *snapshotSuite) TestOpenSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]any{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -842,7 +984,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.Revision, check.Equals, info.Revision)
 
@@ -1116,10 +1258,10 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1150,14 +1292,14 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	cfg := map[string]any{"some-setting": false}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
 	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1186,6 +1328,171 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	c.Check(rdr.IsValid(), check.Equals, true)
 }
 
+func (s *snapshotSuite) TestImportExportRoundtripIncremental(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+
+	ctx := context.TODO()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: snap.E("42*")}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, &backend.SaveFlags{Incremental: true})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Incremental, check.Equals, true)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	export.Close()
+
+	// the export carries the chunks, import it on a "clean" system
+	c.Assert(os.Remove(filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip")), check.IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(dirs.SnapshotsDir, "chunks")), check.IsNil)
+
+	names, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"hello-snap"})
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Incremental, check.Equals, true)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestImportExportRoundtripEncrypted(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockScryptCost(1 << 4)()
+
+	ctx := context.TODO()
+	secret := []byte("secret")
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: snap.E("42*")}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, &backend.SaveFlags{Key: secret})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encrypted, check.Equals, true)
+
+	// the export is encrypted as a whole too
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, &backend.ExportFlags{Key: secret})
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	export.Close()
+	c.Check(bytes.Contains(buf.Bytes(), []byte("content.json")), check.Equals, false)
+	data := buf.Bytes()
+
+	c.Assert(os.Remove(filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip")), check.IsNil)
+
+	_, err = backend.Import(ctx, 123, bytes.NewReader(data), nil)
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: snapshot export is encrypted, a passphrase or key file is needed")
+	_, err = backend.Import(ctx, 123, bytes.NewReader(data), &backend.ImportFlags{Key: []byte("not the secret")})
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: wrong passphrase or key file for encrypted snapshot")
+
+	names, err := backend.Import(ctx, 123, bytes.NewReader(data), &backend.ImportFlags{Key: secret})
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"hello-snap"})
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Encrypted, check.Equals, true)
+	c.Assert(rdr.Unlock(secret), check.IsNil)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestCollectGarbage(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+
+	ctx := context.TODO()
+	flags := &backend.SaveFlags{Incremental: true}
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: snap.E("42*")}
+	shw1, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, flags)
+	c.Assert(err, check.IsNil)
+	// the same data is saved again, sharing all its chunks
+	shw2, err := backend.Save(ctx, 13, info, nil, []string{"snapuser"}, nil, nil, flags)
+	c.Assert(err, check.IsNil)
+
+	chunks, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "chunks/*/*"))
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.Not(check.HasLen), 0)
+
+	removed, err := backend.CollectGarbage(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	c.Assert(os.Remove(backend.Filename(shw1)), check.IsNil)
+	removed, err = backend.CollectGarbage(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	c.Check(shr.Check(ctx, nil), check.IsNil)
+	shr.Close()
+
+	c.Assert(os.Remove(backend.Filename(shw2)), check.IsNil)
+	removed, err = backend.CollectGarbage(ctx)
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, len(chunks))
+	for _, p := range chunks {
+		c.Check(p, testutil.FileAbsent)
+	}
+}
+
+func (s *snapshotSuite) TestCollectGarbageKeepsChunksOfUnreadableSnapshots(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+
+	ctx := context.TODO()
+	flags := &backend.SaveFlags{Incremental: true}
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: snap.E("42*")}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, flags)
+	c.Assert(err, check.IsNil)
+	chunks, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "chunks/*/*"))
+	c.Assert(err, check.IsNil)
+	c.Assert(chunks, check.Not(check.HasLen), 0)
+
+	// a snapshot which cannot be opened at all
+	restore := backend.MockOpen(func(string, uint64) (*backend.Reader, error) {
+		return nil, errors.New("i/o error")
+	})
+	_, err = backend.CollectGarbage(ctx)
+	c.Check(err, check.ErrorMatches, `cannot list chunks of snapshot "12_hello-snap_v1.33_42.zip": i/o error`)
+	restore()
+
+	// a snapshot which is broken
+	restore = backend.MockOpen(func(fn string, setID uint64) (*backend.Reader, error) {
+		r, err := backend.Open(fn, setID)
+		c.Assert(err, check.IsNil)
+		// as with actually broken snapshots, the file is closed
+		c.Assert(r.Close(), check.IsNil)
+		r.Broken = "invalid snapshot"
+		return r, errors.New("invalid snapshot")
+	})
+	defer restore()
+	_, err = backend.CollectGarbage(ctx)
+	c.Check(err, check.ErrorMatches, `cannot list chunks of snapshot ".*": snapshot is broken: invalid snapshot`)
+
+	for _, p := range chunks {
+		c.Check(p, testutil.FilePresent)
+	}
+	c.Check(backend.Filename(shw), testutil.FilePresent)
+}
+
 func (s *snapshotSuite) TestEstimateSnapshotSize(c *check.C) {
 
 	for _, t := range []struct {
@@ -1315,7 +1622,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	}
 	// create a snapshot
 	shID := uint64(12)
-	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	// content.json + num_files + export.json + footer
@@ -1345,7 +1652,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// export once
 	buf := bytes.NewBuffer(nil)
 	ctx := context.Background()
-	se, err := backend.NewSnapshotExport(ctx, shID)
+	se, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Assert(err, check.IsNil)
 	err = se.Init()
 	c.Assert(err, check.IsNil)
@@ -1362,7 +1669,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// change.
 	restore = backend.MockTimeNow(func() time.Time { return time.Date(2242, 1, 1, 12, 0, 0, 0, time.UTC) })
 	defer restore()
-	se2, err := backend.NewSnapshotExport(ctx, shID)
+	se2, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Assert(err, check.IsNil)
 	err = se2.Init()
 	c.Assert(err, check.IsNil)
@@ -1375,7 +1682,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 }
 
 func (s *snapshotSuite) TestExportUnhappy(c *check.C) {
-	se, err := backend.NewSnapshotExport(context.Background(), 5)
+	se, err := backend.NewSnapshotExport(context.Background(), 5, nil)
 	c.Assert(err, check.ErrorMatches, "no snapshot data found for 5")
 	c.Assert(se, check.IsNil)
 }
@@ -1685,16 +1992,16 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		Version: "v1.33",
 	}
 	shID := uint64(12)
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// now export it
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.HasLen, sha256.Size)
 
 	// and check that exporting it again leads to the same content hash
-	export2, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export2, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.DeepEquals, export2.ContentHash())
 
@@ -1707,10 +2014,10 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		},
 		Version: "v1.33",
 	}
-	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
-	export3, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export3, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.Not(check.DeepEquals), export3.ContentHash())
 }
@@ -0,0 +1,415 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Incremental snapshots don't carry their archives' data themselves: the
+// (uncompressed) tar stream of each archive is split into chunks, which are
+// compressed and stored in a content-addressed store shared by all snapshots,
+// and the snapshot file only holds the list of chunks for each archive.
+//
+// Chunk boundaries are chosen from the content, using a gear rolling hash, so
+// that a change to some part of the data only affects the chunks around it.
+
+const chunksDirName = "chunks"
+
+var (
+	chunkMinSize = 512 * 1024
+	chunkMaxSize = 8 * 1024 * 1024
+	// with a 20 bit mask chunks are ~1MiB on average
+	chunkMask uint64 = 1<<20 - 1
+)
+
+// chunkStoreLock serializes garbage collection of the chunk store with the
+// operations that add chunks that are not referenced by a snapshot yet.
+var chunkStoreLock sync.RWMutex
+
+var gearTable = func() (table [256]uint64) {
+	// splitmix64, with a fixed seed: chunk boundaries need to be stable
+	seed := uint64(0x736e617073686f74)
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPath(id string) string {
+	return filepath.Join(chunksDir(), id[:2], id)
+}
+
+func validateChunkID(id string) error {
+	if len(id) != 2*crypto.SHA3_384.Size() {
+		return fmt.Errorf("invalid chunk id %q", id)
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return fmt.Errorf("invalid chunk id %q", id)
+	}
+	return nil
+}
+
+type chunkRef struct {
+	SHA3_384 string `json:"sha3-384"`
+	Size     int64  `json:"size"`
+}
+
+// chunkIndex lists the chunks an archive is made of, in order.
+type chunkIndex struct {
+	Chunks []chunkRef `json:"chunks"`
+}
+
+func (idx *chunkIndex) size() int64 {
+	var size int64
+	for _, ch := range idx.Chunks {
+		size += ch.Size
+	}
+	return size
+}
+
+func readChunkIndex(r io.Reader) (*chunkIndex, error) {
+	var idx chunkIndex
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, err
+	}
+	for _, ch := range idx.Chunks {
+		if err := validateChunkID(ch.SHA3_384); err != nil {
+			return nil, err
+		}
+		if ch.Size <= 0 {
+			return nil, fmt.Errorf("invalid size %d for chunk %.7s…", ch.Size, ch.SHA3_384)
+		}
+	}
+	return &idx, nil
+}
+
+// storeChunk adds the given data to the chunk store, unless it's there
+// already, and returns its id.
+func storeChunk(data []byte) (string, error) {
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	id := fmt.Sprintf("%x", hasher.Sum(nil))
+
+	p := chunkPath(id)
+	if osutil.FileExists(p) {
+		return id, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	aw, err := osutil.NewAtomicFile(p, 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return "", err
+	}
+	// Cancel is a NOP once committed
+	defer aw.Cancel()
+
+	gz := gzip.NewWriter(aw)
+	if _, err := gz.Write(data); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	if err := aw.Commit(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// importChunk adds the compressed chunk read from r to the chunk store,
+// after verifying it has the given id.
+func importChunk(id string, r io.Reader) error {
+	if err := validateChunkID(id); err != nil {
+		return err
+	}
+	p := chunkPath(id)
+	if osutil.FileExists(p) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	aw, err := osutil.NewAtomicFile(p, 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return err
+	}
+	defer aw.Cancel()
+
+	tr := io.TeeReader(r, aw)
+	gz, err := gzip.NewReader(tr)
+	if err != nil {
+		return fmt.Errorf("cannot read chunk %.7s…: %v", id, err)
+	}
+	hasher := crypto.SHA3_384.New()
+	if _, err := io.Copy(hasher, gz); err != nil {
+		return fmt.Errorf("cannot read chunk %.7s…: %v", id, err)
+	}
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != id {
+		return fmt.Errorf("chunk %.7s… does not match its content (%.7s…)", id, actual)
+	}
+	// copy anything left over after the compressed stream
+	if _, err := io.Copy(aw, r); err != nil {
+		return err
+	}
+	return aw.Commit()
+}
+
+// chunkWriter splits the data written to it into chunks, and adds them to
+// the chunk store. Close must be called once all the data is written.
+type chunkWriter struct {
+	buf   []byte
+	hash  uint64
+	index chunkIndex
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n, cut := w.boundary(p)
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if cut {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// boundary returns how much of p belongs to the current chunk, and whether
+// the current chunk ends there.
+func (w *chunkWriter) boundary(p []byte) (int, bool) {
+	size := len(w.buf)
+	for i, b := range p {
+		size++
+		w.hash = (w.hash << 1) + gearTable[b]
+		if size >= chunkMaxSize || (size >= chunkMinSize && w.hash&chunkMask == 0) {
+			return i + 1, true
+		}
+	}
+	return len(p), false
+}
+
+func (w *chunkWriter) flush() error {
+	id, err := storeChunk(w.buf)
+	if err != nil {
+		return fmt.Errorf("cannot store snapshot chunk: %v", err)
+	}
+	w.index.Chunks = append(w.index.Chunks, chunkRef{SHA3_384: id, Size: int64(len(w.buf))})
+	w.buf = w.buf[:0]
+	w.hash = 0
+	return nil
+}
+
+// Close stores the last chunk.
+func (w *chunkWriter) Close() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.flush()
+}
+
+// chunkReader reassembles data from the chunks in the index, verifying
+// each of them as it goes.
+type chunkReader struct {
+	chunks []chunkRef
+	f      *os.File
+	gz     *gzip.Reader
+	hasher hash.Hash
+	read   int64
+}
+
+func newChunkReader(idx *chunkIndex) *chunkReader {
+	return &chunkReader{
+		chunks: idx.Chunks,
+		hasher: crypto.SHA3_384.New(),
+	}
+}
+
+func (r *chunkReader) next() error {
+	ch := r.chunks[0]
+	f, err := os.Open(chunkPath(ch.SHA3_384))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("missing snapshot chunk %.7s…", ch.SHA3_384)
+		}
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot read snapshot chunk %.7s…: %v", ch.SHA3_384, err)
+	}
+	r.f = f
+	r.gz = gz
+	r.hasher.Reset()
+	r.read = 0
+	return nil
+}
+
+func (r *chunkReader) finish() error {
+	ch := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	r.f.Close()
+	r.f = nil
+	if r.read != ch.Size {
+		return fmt.Errorf("snapshot chunk %.7s… size (%d) different from expected (%d)", ch.SHA3_384, r.read, ch.Size)
+	}
+	if actual := fmt.Sprintf("%x", r.hasher.Sum(nil)); actual != ch.SHA3_384 {
+		return fmt.Errorf("snapshot chunk %.7s… does not match its content (%.7s…)", ch.SHA3_384, actual)
+	}
+	return nil
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.f == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.gz.Read(p)
+		r.hasher.Write(p[:n])
+		r.read += int64(n)
+		if err == io.EOF {
+			if err := r.finish(); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// chunkIDs returns the ids of all the chunks used by the snapshot.
+func (r *Reader) chunkIDs() ([]string, error) {
+	if !r.Incremental {
+		return nil, nil
+	}
+	var ids []string
+	for entry := range r.SHA3_384 {
+		body, _, err := zipMember(r.File, entry)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := readChunkIndex(body)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read chunk index of snapshot entry %q: %v", entry, err)
+		}
+		for _, ch := range idx.Chunks {
+			ids = append(ids, ch.SHA3_384)
+		}
+	}
+	return ids, nil
+}
+
+// CollectGarbage removes the chunks of incremental snapshots that are no
+// longer used by any snapshot, and returns how many were removed. As the
+// chunks used by a snapshot which cannot be read are not known, garbage
+// collection is not attempted while any snapshot is broken.
+func CollectGarbage(ctx context.Context) (removed int, err error) {
+	chunkStoreLock.Lock()
+	defer chunkStoreLock.Unlock()
+
+	if !osutil.IsDirectory(chunksDir()) {
+		return 0, nil
+	}
+
+	used := make(map[string]bool)
+	err = iter(ctx, func(r *Reader) error {
+		if r.Broken != "" {
+			return fmt.Errorf("cannot list chunks of snapshot %q: snapshot is broken: %s", r.Name(), r.Broken)
+		}
+		ids, err := r.chunkIDs()
+		if err != nil {
+			return fmt.Errorf("cannot list chunks of snapshot %q: %v", r.Name(), err)
+		}
+		for _, id := range ids {
+			used[id] = true
+		}
+		return nil
+	}, func(name string, err error) error {
+		return fmt.Errorf("cannot list chunks of snapshot %q: %v", name, err)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = filepath.WalkDir(chunksDir(), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || used[d.Name()] {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type chunksSuite struct {
+	testutil.BaseTest
+}
+
+var _ = check.Suite(&chunksSuite{})
+
+func (s *chunksSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	// small chunks, ~4KiB on average
+	s.AddCleanup(backend.MockChunkSizes(1024, 16*1024, 1<<12-1))
+}
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func writeChunks(c *check.C, data []byte) *backend.ChunkWriter {
+	w := &backend.ChunkWriter{}
+	// write in odd sized pieces so boundaries fall across writes
+	for p := data; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		_, err := w.Write(p[:n])
+		c.Assert(err, check.IsNil)
+		p = p[n:]
+	}
+	c.Assert(w.Close(), check.IsNil)
+	return w
+}
+
+func readChunks(c *check.C, w *backend.ChunkWriter) ([]byte, error) {
+	r := w.Reader()
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *chunksSuite) TestRoundtrip(c *check.C) {
+	data := randomData(1, 256*1024)
+
+	w := writeChunks(c, data)
+	ids := w.ChunkIDs()
+	c.Check(len(ids) > 1, check.Equals, true)
+	for _, id := range ids {
+		c.Check(backend.ChunkPath(id), testutil.FilePresent)
+	}
+
+	read, err := readChunks(c, w)
+	c.Assert(err, check.IsNil)
+	c.Check(read, check.DeepEquals, data)
+}
+
+func (s *chunksSuite) TestEmpty(c *check.C) {
+	w := writeChunks(c, nil)
+	c.Check(w.ChunkIDs(), check.HasLen, 0)
+
+	read, err := readChunks(c, w)
+	c.Assert(err, check.IsNil)
+	c.Check(read, check.HasLen, 0)
+}
+
+func (s *chunksSuite) TestDeduplication(c *check.C) {
+	data := randomData(1, 256*1024)
+	w1 := writeChunks(c, data)
+
+	// change a few bytes in the middle
+	changed := append([]byte(nil), data...)
+	copy(changed[128*1024:], "changed")
+	w2 := writeChunks(c, changed)
+
+	ids1 := w1.ChunkIDs()
+	ids2 := w2.ChunkIDs()
+	shared := 0
+	for _, id := range ids2 {
+		for _, other := range ids1 {
+			if id == other {
+				shared++
+				break
+			}
+		}
+	}
+	// only the chunks around the change differ
+	c.Check(shared >= len(ids2)-2, check.Equals, true, check.Commentf("%d of %d chunks shared", shared, len(ids2)))
+	c.Check(shared < len(ids2), check.Equals, true)
+
+	read, err := readChunks(c, w2)
+	c.Assert(err, check.IsNil)
+	c.Check(read, check.DeepEquals, changed)
+}
+
+func (s *chunksSuite) TestReadMissingChunk(c *check.C) {
+	w := writeChunks(c, randomData(1, 64*1024))
+	id := w.ChunkIDs()[0]
+	c.Assert(os.Remove(backend.ChunkPath(id)), check.IsNil)
+
+	_, err := readChunks(c, w)
+	c.Check(err, check.ErrorMatches, `missing snapshot chunk [0-9a-f]{7}…`)
+}
+
+func (s *chunksSuite) TestReadCorruptedChunk(c *check.C) {
+	w1 := writeChunks(c, randomData(1, 64*1024))
+	w2 := writeChunks(c, randomData(2, 64*1024))
+
+	// replace a chunk with another one
+	p := backend.ChunkPath(w1.ChunkIDs()[0])
+	c.Assert(os.Remove(p), check.IsNil)
+	c.Assert(os.Link(backend.ChunkPath(w2.ChunkIDs()[0]), p), check.IsNil)
+
+	_, err := readChunks(c, w1)
+	c.Check(err, check.ErrorMatches, `snapshot chunk [0-9a-f]{7}… (size \(\d+\) different from expected \(\d+\)|does not match its content \([0-9a-f]{7}…\))`)
+}
+
+func (s *chunksSuite) TestCollectGarbageNoChunks(c *check.C) {
+	removed, err := backend.CollectGarbage(context.Background())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+}
+
+func (s *chunksSuite) TestCollectGarbageUnreferenced(c *check.C) {
+	w := writeChunks(c, randomData(1, 64*1024))
+	ids := w.ChunkIDs()
+
+	removed, err := backend.CollectGarbage(context.Background())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, len(ids))
+	for _, id := range ids {
+		c.Check(backend.ChunkPath(id), testutil.FileAbsent)
+	}
+	// the fan-out directories are left alone
+	c.Check(filepath.Dir(backend.ChunkPath(ids[0])), testutil.FilePresent)
+}
@@ -0,0 +1,344 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted snapshots have their archives encrypted with AES-256-GCM, using a
+// key derived with scrypt from a secret given by the user: a passphrase, or
+// the content of a key file.
+//
+// So that archives can be streamed, the data is sealed in fixed size
+// segments, following the STREAM construction: the nonce of each segment is
+// made of a random prefix, written ahead of the data, the number of the
+// segment, and a flag marking the last one, so that segments cannot be
+// reordered, and truncated data does not decrypt.
+//
+// The configuration of the snap is encrypted the same way, and the metadata,
+// which has to be readable without the key, is authenticated with a MAC keyed
+// from the same key, so that it cannot be altered undetected.
+
+const (
+	encryptionName    = "encryption.json"
+	encryptedConfName = "conf.json"
+	metaMACName       = "meta.mac"
+	encryptionCipher  = "aes-256-gcm"
+	encryptionKDF     = "scrypt"
+
+	encryptionKeySize  = 32
+	encryptionSaltSize = 16
+
+	segmentSize     = 64 * 1024
+	noncePrefixSize = 7
+)
+
+// scrypt cost parameters used for new keys; the ones a key was derived
+// with are stored along with the encrypted data.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrWrongKey is returned when the passphrase or key file given for an
+// encrypted snapshot is not the one it was encrypted with.
+var ErrWrongKey = errors.New("wrong passphrase or key file for encrypted snapshot")
+
+// encryptionParams describe how encrypted data was encrypted.
+type encryptionParams struct {
+	Cipher string `json:"cipher"`
+	KDF    string `json:"kdf"`
+	Salt   []byte `json:"salt"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	// KeyCheck tells a wrong secret apart from corrupted data
+	KeyCheck string `json:"key-check"`
+}
+
+// ErrMetadataTampered is returned when the metadata of an encrypted snapshot
+// does not match the MAC computed when it was saved.
+var ErrMetadataTampered = errors.New("snapshot metadata is corrupted or was tampered with")
+
+// snapshotKey is a key derived from the user's secret, ready to encrypt or
+// decrypt data.
+type snapshotKey struct {
+	params *encryptionParams
+	aead   cipher.AEAD
+	// macKey authenticates the snapshot metadata
+	macKey []byte
+}
+
+func metadataMACKey(key []byte) []byte {
+	mac := hmac.New(crypto.SHA3_384.New, key)
+	mac.Write([]byte("snapd snapshot metadata\x00"))
+	return mac.Sum(nil)
+}
+
+// metadataMAC returns the MAC of the given snapshot metadata.
+func (k *snapshotKey) metadataMAC(meta []byte) string {
+	mac := hmac.New(crypto.SHA3_384.New, k.macKey)
+	mac.Write(meta)
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// checkMetadataMAC checks the given MAC is the one of the given snapshot
+// metadata.
+func (k *snapshotKey) checkMetadataMAC(meta []byte, mac string) error {
+	if !hmac.Equal([]byte(k.metadataMAC(meta)), []byte(mac)) {
+		return ErrMetadataTampered
+	}
+	return nil
+}
+
+func keyCheck(key []byte) string {
+	hasher := crypto.SHA3_384.New()
+	hasher.Write([]byte("snapd snapshot key check\x00"))
+	hasher.Write(key)
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+func deriveKey(secret []byte, params *encryptionParams) (key []byte, aead cipher.AEAD, err error) {
+	if len(secret) == 0 {
+		return nil, nil, errors.New("passphrase or key file cannot be empty")
+	}
+	key, err = scrypt.Key(secret, params.Salt, params.N, params.R, params.P, encryptionKeySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot derive snapshot key: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, aead, nil
+}
+
+// newSnapshotKey derives a new key, with a new random salt, from the
+// given secret.
+func newSnapshotKey(secret []byte) (*snapshotKey, error) {
+	params := &encryptionParams{
+		Cipher: encryptionCipher,
+		KDF:    encryptionKDF,
+		Salt:   make([]byte, encryptionSaltSize),
+		N:      scryptN,
+		R:      scryptR,
+		P:      scryptP,
+	}
+	if _, err := io.ReadFull(rand.Reader, params.Salt); err != nil {
+		return nil, err
+	}
+	key, aead, err := deriveKey(secret, params)
+	if err != nil {
+		return nil, err
+	}
+	params.KeyCheck = keyCheck(key)
+	return &snapshotKey{params: params, aead: aead, macKey: metadataMACKey(key)}, nil
+}
+
+// openSnapshotKey derives the key described by params from the given
+// secret, checking it's the expected one.
+func openSnapshotKey(secret []byte, params *encryptionParams) (*snapshotKey, error) {
+	if params.Cipher != encryptionCipher {
+		return nil, fmt.Errorf("unsupported snapshot cipher %q", params.Cipher)
+	}
+	if params.KDF != encryptionKDF {
+		return nil, fmt.Errorf("unsupported snapshot key derivation function %q", params.KDF)
+	}
+	key, aead, err := deriveKey(secret, params)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(keyCheck(key)), []byte(params.KeyCheck)) != 1 {
+		return nil, ErrWrongKey
+	}
+	return &snapshotKey{params: params, aead: aead, macKey: metadataMACKey(key)}, nil
+}
+
+func readEncryptionParams(r io.Reader) (*encryptionParams, error) {
+	var params encryptionParams
+	if err := json.NewDecoder(r).Decode(&params); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot encryption parameters: %v", err)
+	}
+	return &params, nil
+}
+
+func segmentNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], n)
+	if last {
+		nonce[noncePrefixSize+4] = 1
+	}
+	return nonce
+}
+
+// plaintextSize returns the size of the data that encrypts to size bytes.
+func (k *snapshotKey) plaintextSize(size int64) int64 {
+	overhead := int64(k.aead.Overhead())
+	size -= noncePrefixSize
+	segments := (size + segmentSize + overhead - 1) / (segmentSize + overhead)
+	return size - segments*overhead
+}
+
+// encryptWriter encrypts the data written to it; Close must be called to
+// write out the last segment.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+}
+
+func (k *snapshotKey) newEncryptWriter(w io.Writer) (*encryptWriter, error) {
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   k.aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, segmentSize),
+	}, nil
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	if ew.n == ^uint32(0) {
+		return errors.New("too much data to encrypt")
+	}
+	sealed := ew.aead.Seal(nil, segmentNonce(ew.prefix, ew.n, last), ew.buf, nil)
+	if _, err := ew.w.Write(sealed); err != nil {
+		return err
+	}
+	ew.n++
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// only seal a full segment once there's more data, so that
+		// the last one is sealed as such by Close
+		if len(ew.buf) == segmentSize {
+			if err := ew.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(ew.buf[len(ew.buf):segmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes out the last segment. It does not close the underlying writer.
+func (ew *encryptWriter) Close() error {
+	return ew.seal(true)
+}
+
+// decryptReader decrypts and authenticates the data read from it.
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+	plain  []byte
+	done   bool
+}
+
+func (k *snapshotKey) newDecryptReader(r io.Reader) *decryptReader {
+	return &decryptReader{
+		r:    bufio.NewReader(r),
+		aead: k.aead,
+		buf:  make([]byte, segmentSize+k.aead.Overhead()),
+	}
+}
+
+func (dr *decryptReader) next() error {
+	if dr.prefix == nil {
+		dr.prefix = make([]byte, noncePrefixSize)
+		if _, err := io.ReadFull(dr.r, dr.prefix); err != nil {
+			return fmt.Errorf("cannot read encrypted data: %v", err)
+		}
+	}
+	n, err := io.ReadFull(dr.r, dr.buf)
+	last := false
+	switch err {
+	case nil:
+		// a full segment is the last one if nothing follows
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			last = true
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return errors.New("cannot decrypt data: unexpected end of data")
+	default:
+		return err
+	}
+	plain, err := dr.aead.Open(dr.buf[:0], segmentNonce(dr.prefix, dr.n, last), dr.buf[:n], nil)
+	if err != nil {
+		return errors.New("cannot decrypt data: data is corrupted or was tampered with")
+	}
+	dr.n++
+	dr.plain = plain
+	dr.done = last
+	return nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"io"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type cryptSuite struct {
+	testutil.BaseTest
+}
+
+var _ = check.Suite(&cryptSuite{})
+
+func (s *cryptSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	// keep key derivation cheap
+	s.AddCleanup(backend.MockScryptCost(1 << 4))
+}
+
+func encrypt(c *check.C, key *backend.SnapshotKey, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := key.NewEncryptWriter(&buf)
+	c.Assert(err, check.IsNil)
+	// write in odd sized pieces so segments fall across writes
+	for p := data; len(p) > 0; {
+		n := 1000
+		if n > len(p) {
+			n = len(p)
+		}
+		_, err := w.Write(p[:n])
+		c.Assert(err, check.IsNil)
+		p = p[n:]
+	}
+	c.Assert(w.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func (s *cryptSuite) TestRoundtrip(c *check.C) {
+	key, err := backend.NewSnapshotKey([]byte("secret"))
+	c.Assert(err, check.IsNil)
+
+	for _, size := range []int{0, 1, backend.SegmentSize - 1, backend.SegmentSize, backend.SegmentSize + 1, 3*backend.SegmentSize + 42} {
+		comm := check.Commentf("%d", size)
+		data := randomData(int64(size), size)
+		encrypted := encrypt(c, key, data)
+		if size > 16 {
+			c.Check(bytes.Contains(encrypted, data[:16]), check.Equals, false, comm)
+		}
+		c.Check(key.PlaintextSize(int64(len(encrypted))), check.Equals, int64(size), comm)
+
+		reopened, err := key.Reopen([]byte("secret"))
+		c.Assert(err, check.IsNil, comm)
+		decrypted, err := io.ReadAll(reopened.NewDecryptReader(bytes.NewReader(encrypted)))
+		c.Assert(err, check.IsNil, comm)
+		c.Check(decrypted, check.DeepEquals, data, comm)
+	}
+}
+
+func (s *cryptSuite) TestEmptySecret(c *check.C) {
+	_, err := backend.NewSnapshotKey(nil)
+	c.Check(err, check.ErrorMatches, "passphrase or key file cannot be empty")
+}
+
+func (s *cryptSuite) TestWrongSecret(c *check.C) {
+	key, err := backend.NewSnapshotKey([]byte("secret"))
+	c.Assert(err, check.IsNil)
+
+	_, err = key.Reopen([]byte("not the secret"))
+	c.Check(err, check.Equals, backend.ErrWrongKey)
+}
+
+func (s *cryptSuite) TestCorrupted(c *check.C) {
+	key, err := backend.NewSnapshotKey([]byte("secret"))
+	c.Assert(err, check.IsNil)
+	encrypted := encrypt(c, key, randomData(1, 2*backend.SegmentSize))
+
+	encrypted[backend.SegmentSize+100] ^= 1
+	_, err = io.ReadAll(key.NewDecryptReader(bytes.NewReader(encrypted)))
+	c.Check(err, check.ErrorMatches, "cannot decrypt data: data is corrupted or was tampered with")
+}
+
+func (s *cryptSuite) TestTruncated(c *check.C) {
+	key, err := backend.NewSnapshotKey([]byte("secret"))
+	c.Assert(err, check.IsNil)
+	encrypted := encrypt(c, key, randomData(1, 3*backend.SegmentSize))
+
+	// dropping whole segments is detected as well, as the last one
+	// left was not sealed as such
+	for _, size := range []int{len(encrypted) - 1, 2 * (backend.SegmentSize + 16), backend.SegmentSize + 23} {
+		_, err = io.ReadAll(key.NewDecryptReader(bytes.NewReader(encrypted[:size])))
+		c.Check(err, check.ErrorMatches, "cannot decrypt data: data is corrupted or was tampered with", check.Commentf("%d", size))
+	}
+	_, err = io.ReadAll(key.NewDecryptReader(bytes.NewReader(encrypted[:3])))
+	c.Check(err, check.ErrorMatches, "cannot read encrypted data: unexpected EOF")
+}
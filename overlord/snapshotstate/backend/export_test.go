@@ -20,7 +20,9 @@
 package backend
 
 import (
+	"archive/zip"
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"time"
@@ -41,8 +43,6 @@ var (
 
 	NewMultiError = newMultiError
 
-	AddSnapDirToZip = addSnapDirToZip
-
 	IsPathAtOrUnderDir = isPathAtOrUnderDir
 )
 
@@ -158,3 +158,60 @@ func MockReadSnapshotYaml(f func(si *snap.Info) (*snap.SnapshotOptions, error))
 		snapReadSnapshotYaml = oldReadSnapshotYaml
 	}
 }
+
+var ChunkPath = chunkPath
+
+func MockChunkSizes(min, max int, mask uint64) (restore func()) {
+	oldMin, oldMax, oldMask := chunkMinSize, chunkMaxSize, chunkMask
+	chunkMinSize, chunkMaxSize, chunkMask = min, max, mask
+	return func() {
+		chunkMinSize, chunkMaxSize, chunkMask = oldMin, oldMax, oldMask
+	}
+}
+
+type ChunkWriter = chunkWriter
+
+func (w *chunkWriter) ChunkIDs() []string {
+	ids := make([]string, 0, len(w.index.Chunks))
+	for _, ch := range w.index.Chunks {
+		ids = append(ids, ch.SHA3_384)
+	}
+	return ids
+}
+
+func (w *chunkWriter) Reader() io.ReadCloser {
+	idx := w.index
+	return newChunkReader(&idx)
+}
+
+func AddSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+	return addSnapDirToZip(ctx, snapshot, w, username, entry, snapDir, savingUserData, excludePaths, nil)
+}
+
+const SegmentSize = segmentSize
+
+type SnapshotKey = snapshotKey
+
+var NewSnapshotKey = newSnapshotKey
+
+// Reopen derives the key again from the given secret, as done when
+// decrypting.
+func (k *snapshotKey) Reopen(secret []byte) (*snapshotKey, error) {
+	return openSnapshotKey(secret, k.params)
+}
+
+func (k *snapshotKey) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	return k.newEncryptWriter(w)
+}
+
+func (k *snapshotKey) NewDecryptReader(r io.Reader) io.Reader {
+	return k.newDecryptReader(r)
+}
+
+func (k *snapshotKey) PlaintextSize(size int64) int64 {
+	return k.plaintextSize(size)
+}
+
+func MockScryptCost(n int) (restore func()) {
+	return testutil.Mock(&scryptN, n)
+}
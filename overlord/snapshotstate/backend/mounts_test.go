@@ -78,7 +78,7 @@ func saveAndOpenSnapshot(c *C) *backend.Reader {
 		},
 		Version: "v1.33",
 	}
-	shw, err := backend.Save(context.TODO(), 1, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 1, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, IsNil)
 	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
 	c.Assert(err, IsNil)
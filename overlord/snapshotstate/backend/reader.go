@@ -51,6 +51,9 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	// key decrypts the archives of an encrypted snapshot, see Unlock
+	key *snapshotKey
 }
 
 // Open a Snapshot given its full filename.
@@ -143,8 +146,109 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 	return reader, nil
 }
 
+// Unlock derives the key to decrypt an encrypted snapshot from the given
+// passphrase or key file content. It does nothing for snapshots that are
+// not encrypted.
+func (r *Reader) Unlock(secret []byte) error {
+	if !r.Encrypted {
+		return nil
+	}
+	body, _, err := zipMember(r.File, encryptionName)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	params, err := readEncryptionParams(body)
+	if err != nil {
+		return err
+	}
+	key, err := openSnapshotKey(secret, params)
+	if err != nil {
+		return err
+	}
+	if err := r.checkMetadataMAC(key); err != nil {
+		return err
+	}
+	conf, err := r.readEncryptedConf(key)
+	if err != nil {
+		return err
+	}
+	r.Conf = conf
+	r.key = key
+	return nil
+}
+
+func (r *Reader) checkMetadataMAC(key *snapshotKey) error {
+	metaReader, _, err := zipMember(r.File, metadataName)
+	if err != nil {
+		return err
+	}
+	defer metaReader.Close()
+	meta, err := io.ReadAll(metaReader)
+	if err != nil {
+		return err
+	}
+
+	macReader, _, err := zipMember(r.File, metaMACName)
+	if err != nil {
+		return err
+	}
+	defer macReader.Close()
+	mac, err := io.ReadAll(macReader)
+	if err != nil {
+		return err
+	}
+	return key.checkMetadataMAC(meta, string(bytes.TrimSpace(mac)))
+}
+
+func (r *Reader) readEncryptedConf(key *snapshotKey) (map[string]any, error) {
+	body, _, err := zipMember(r.File, encryptedConfName)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var conf map[string]any
+	if err := jsonutil.DecodeWithNumber(key.newDecryptReader(body), &conf); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot configuration: %v", err)
+	}
+	return conf, nil
+}
+
+type decryptReadCloser struct {
+	*decryptReader
+	io.Closer
+}
+
+// entryReader returns a reader for the archive data of the given entry, and
+// its expected size. For incremental snapshots the data is reassembled from
+// the chunk store, and for encrypted ones it's decrypted.
+func (r *Reader) entryReader(entry string) (io.ReadCloser, int64, error) {
+	if r.Encrypted && r.key == nil {
+		return nil, -1, fmt.Errorf("snapshot %q is encrypted, a passphrase or key file is needed", r.Name())
+	}
+	body, size, err := zipMember(r.File, entry)
+	if err != nil {
+		return body, size, err
+	}
+	if r.Encrypted {
+		return decryptReadCloser{r.key.newDecryptReader(body), body}, r.key.plaintextSize(size), nil
+	}
+	if !r.Incremental {
+		return body, size, nil
+	}
+	defer body.Close()
+
+	idx, err := readChunkIndex(body)
+	if err != nil {
+		return nil, -1, fmt.Errorf("cannot read chunk index of snapshot entry %q: %v", entry, err)
+	}
+	return newChunkReader(idx), idx.size(), nil
+}
+
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
-	body, reportedSize, err := zipMember(r.File, entry)
+	body, reportedSize, err := r.entryReader(entry)
 	if err != nil {
 		return err
 	}
@@ -311,10 +415,11 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, err := r.entryReader(entry)
 		if err != nil {
 			return rs, err
 		}
+		defer body.Close()
 
 		expectedHash := r.SHA3_384[entry]
 
@@ -323,10 +428,12 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
 		// special cases we'd need to consider otherwise
-		cmd := tarAsUser(ctx, username,
-			"--extract",
-			"--preserve-permissions", "--preserve-order", "--gunzip",
-			"--directory", tempdir)
+		tarArgs := []string{"--extract", "--preserve-permissions", "--preserve-order"}
+		if !r.Incremental {
+			tarArgs = append(tarArgs, "--gunzip")
+		}
+		tarArgs = append(tarArgs, "--directory", tempdir)
+		cmd := tarAsUser(ctx, username, tarArgs...)
 		cmd.Env = []string{}
 		cmd.Stdin = tr
 		matchCounter := &strutil.MatchCounter{N: 1}
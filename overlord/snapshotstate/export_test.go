@@ -125,7 +125,7 @@ func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirO
 	return testutil.Mock(&backendEstimateSnapshotSize, f)
 }
 
-func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64) (se *SnapshotExport, err error)) (restore func()) {
+func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (se *SnapshotExport, err error)) (restore func()) {
 	return testutil.Mock(&backendNewSnapshotExport, f)
 }
 
@@ -149,3 +149,34 @@ func MockGetSnapDirOptions(f func(*state.State, string) (*dirs.SnapDirOptions, e
 func MockBackendMapSnapDataDirToSnapVar(f func(*snap.Info, *dirs.SnapDirOptions, []string) (map[string]string, error)) (restore func()) {
 	return testutil.Mock(&backendMapSnapDataDirToSnapVar, f)
 }
+
+func MockBackendCollectGarbage(f func(context.Context) (int, error)) (restore func()) {
+	return testutil.Mock(&backendCollectGarbage, f)
+}
+
+func MockBackendUnlock(f func(*backend.Reader, []byte) error) (restore func()) {
+	return testutil.Mock(&backendUnlock, f)
+}
+
+// SnapshotKey returns the key kept in memory for the given snapshot set.
+func SnapshotKey(st *state.State, setID uint64) []byte {
+	return snapshotKey(st, setID)
+}
+
+var (
+	SetSnapshotKey    = setSnapshotKey
+	PruneSnapshotKeys = pruneSnapshotKeys
+)
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
+
+func ScheduledExpiry(keepDaily, keepWeekly int, taken map[uint64]time.Time, now time.Time) map[uint64]time.Time {
+	r := &scheduledRetention{KeepDaily: keepDaily, KeepWeekly: keepWeekly}
+	return r.expiry(taken, now)
+}
+
+func ApplyScheduledRetention(st *state.State, keepDaily, keepWeekly int, now time.Time) error {
+	return applyScheduledRetention(st, &scheduledRetention{KeepDaily: keepDaily, KeepWeekly: keepWeekly}, now)
+}
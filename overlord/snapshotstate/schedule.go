@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var (
+	scheduledSnapshotChangeKind = swfeats.RegisterChangeKind("scheduled-snapshot")
+
+	timeNow = time.Now
+
+	// maximum time between scheduled snapshots, whatever the schedule
+	maxScheduledSnapshotDelay = 14 * 24 * time.Hour
+
+	// default retention of scheduled snapshot sets
+	defaultScheduledKeepDaily  = 7
+	defaultScheduledKeepWeekly = 4
+)
+
+// scheduledRetention is the retention policy of scheduled snapshot sets:
+// the newest set taken on each day is kept for KeepDaily days, and the
+// newest set taken in each week is kept for KeepWeekly weeks. Other sets
+// expire right away.
+type scheduledRetention struct {
+	KeepDaily  int
+	KeepWeekly int
+}
+
+// scheduledSnapshotsConfig returns the schedule of scheduled snapshots (nil
+// if they are disabled), the snaps they are of (all snaps if empty) and
+// their retention policy, as set with the snapshots.schedule.* options.
+func scheduledSnapshotsConfig(st *state.State) (schedule []*timeutil.Schedule, scheduleStr string, snaps []string, retention *scheduledRetention, err error) {
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.schedule.timer", &scheduleStr); err != nil && !config.IsNoOption(err) {
+		return nil, "", nil, nil, err
+	}
+	if scheduleStr != "" {
+		schedule, err = timeutil.ParseSchedule(scheduleStr)
+		if err != nil {
+			return nil, "", nil, nil, fmt.Errorf("cannot parse snapshots.schedule.timer: %v", err)
+		}
+	}
+
+	var snapsStr string
+	if err := tr.Get("core", "snapshots.schedule.snaps", &snapsStr); err != nil && !config.IsNoOption(err) {
+		return nil, "", nil, nil, err
+	}
+	snaps = strutil.CommaSeparatedList(snapsStr)
+
+	retention = &scheduledRetention{}
+	if retention.KeepDaily, err = scheduledKeep(tr, "snapshots.schedule.keep-daily", defaultScheduledKeepDaily); err != nil {
+		return nil, "", nil, nil, err
+	}
+	if retention.KeepWeekly, err = scheduledKeep(tr, "snapshots.schedule.keep-weekly", defaultScheduledKeepWeekly); err != nil {
+		return nil, "", nil, nil, err
+	}
+	return schedule, scheduleStr, snaps, retention, nil
+}
+
+// scheduledKeep returns the value of one of the snapshots.schedule.keep-*
+// options, which can be set either as a number or as a string.
+func scheduledKeep(tr *config.Transaction, option string, def int) (int, error) {
+	var val any
+	if err := tr.Get("core", option, &val); err != nil {
+		if config.IsNoOption(err) {
+			return def, nil
+		}
+		return 0, err
+	}
+	var keep int
+	var err error
+	switch v := val.(type) {
+	case json.Number:
+		keep, err = strconv.Atoi(string(v))
+	case string:
+		keep, err = strconv.Atoi(v)
+	default:
+		err = fmt.Errorf("unexpected type %T", v)
+	}
+	if err != nil || keep < 0 {
+		return 0, fmt.Errorf("%s is not valid: %v", option, val)
+	}
+	return keep, nil
+}
+
+// startOfDay returns the start of the (local) day t is in.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the start of the week t is in, weeks starting on
+// Monday as ISO 8601 ones do.
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// expiry returns when each of the scheduled snapshot sets taken at the
+// given times expires, according to the retention policy.
+func (r *scheduledRetention) expiry(taken map[uint64]time.Time, now time.Time) map[uint64]time.Time {
+	setIDs := make([]uint64, 0, len(taken))
+	for setID := range taken {
+		setIDs = append(setIDs, setID)
+	}
+	// newest first
+	sort.Slice(setIDs, func(i, j int) bool {
+		ti, tj := taken[setIDs[i]], taken[setIDs[j]]
+		if ti.Equal(tj) {
+			return setIDs[i] > setIDs[j]
+		}
+		return ti.After(tj)
+	})
+
+	expiry := make(map[uint64]time.Time, len(taken))
+	seenDays := make(map[time.Time]bool)
+	seenWeeks := make(map[time.Time]bool)
+	for _, setID := range setIDs {
+		t := taken[setID]
+		expiresAt := now
+		day := startOfDay(t)
+		if !seenDays[day] {
+			seenDays[day] = true
+			if dailyExpiry := day.AddDate(0, 0, r.KeepDaily); dailyExpiry.After(expiresAt) {
+				expiresAt = dailyExpiry
+			}
+		}
+		week := startOfWeek(t)
+		if !seenWeeks[week] {
+			seenWeeks[week] = true
+			if weeklyExpiry := week.AddDate(0, 0, 7*r.KeepWeekly); weeklyExpiry.After(expiresAt) {
+				expiresAt = weeklyExpiry
+			}
+		}
+		expiry[setID] = expiresAt
+	}
+	return expiry
+}
+
+// saveScheduled records that the given snapshot set was taken on schedule,
+// for the retention policy to apply to it once it's complete.
+// The state needs to be locked by the caller.
+func saveScheduled(st *state.State, setID uint64, retention *scheduledRetention, takenAt time.Time) error {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if snapshots == nil {
+		snapshots = make(map[uint64]*snapshotState)
+	}
+	if cur := snapshots[setID]; cur != nil && cur.Scheduled != nil {
+		// another snap of the set recorded it already
+		return nil
+	}
+	// until the set is complete it's kept as the newest one is
+	expiry := retention.expiry(map[uint64]time.Time{setID: takenAt}, takenAt)
+	snapshots[setID] = &snapshotState{
+		ExpiryTime: expiry[setID],
+		Scheduled:  &takenAt,
+	}
+	st.Set("snapshots", snapshots)
+	return nil
+}
+
+// applyScheduledRetention updates the expiry time of the complete
+// scheduled snapshot sets according to the retention policy, for the sets
+// it doesn't keep to be forgotten as other expired sets are.
+// The state needs to be locked by the caller.
+func applyScheduledRetention(st *state.State, retention *scheduledRetention, now time.Time) error {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+
+	taken := make(map[uint64]time.Time)
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.Scheduled == nil {
+			continue
+		}
+		// a set that is still being saved could fail, so it must not
+		// make older sets expire yet
+		if err := checkSnapshotConflict(st, setID, "save-snapshot"); err != nil {
+			continue
+		}
+		taken[setID] = *snapshotSet.Scheduled
+	}
+	if len(taken) == 0 {
+		return nil
+	}
+
+	changed := false
+	for setID, expiresAt := range retention.expiry(taken, now) {
+		snapshotSet := snapshots[setID]
+		// sets that expired already stay so
+		if snapshotSet.ExpiryTime.Before(now) || snapshotSet.ExpiryTime.Equal(expiresAt) {
+			continue
+		}
+		snapshotSet.ExpiryTime = expiresAt
+		changed = true
+	}
+	if changed {
+		st.Set("snapshots", snapshots)
+	}
+	return nil
+}
+
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == scheduledSnapshotChangeKind && !chg.IsReady() {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledSnapNames returns the names of the active snaps to take
+// scheduled snapshots of, out of the configured ones.
+func scheduledSnapNames(st *state.State, snaps []string) ([]string, error) {
+	active, err := allActiveSnapNames(st)
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return active, nil
+	}
+	names := make([]string, 0, len(snaps))
+	for _, name := range snaps {
+		if !strutil.SortedListContains(active, name) {
+			logger.Debugf("Skipping scheduled snapshot of snap %q: not installed or not active.", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (snapshotSuite) TestScheduledExpiry(c *check.C) {
+	// Wednesday
+	now := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	taken := map[uint64]time.Time{
+		// Wednesday, twice
+		1: time.Date(2026, 3, 18, 3, 0, 0, 0, time.UTC),
+		2: time.Date(2026, 3, 18, 9, 0, 0, 0, time.UTC),
+		// Tuesday
+		3: time.Date(2026, 3, 17, 3, 0, 0, 0, time.UTC),
+		// Sunday of the previous week
+		4: time.Date(2026, 3, 15, 3, 0, 0, 0, time.UTC),
+		// Saturday of the previous week
+		5: time.Date(2026, 3, 14, 3, 0, 0, 0, time.UTC),
+	}
+
+	expiry := snapshotstate.ScheduledExpiry(2, 0, taken, now)
+	c.Check(expiry, check.DeepEquals, map[uint64]time.Time{
+		1: now,
+		2: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC),
+		3: time.Date(2026, 3, 19, 0, 0, 0, 0, time.UTC),
+		// past their retention already
+		4: now,
+		5: now,
+	})
+
+	expiry = snapshotstate.ScheduledExpiry(0, 2, taken, now)
+	c.Check(expiry, check.DeepEquals, map[uint64]time.Time{
+		1: now,
+		// weeks start on Monday the 16th and the 9th
+		2: time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+		3: now,
+		4: time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC),
+		5: now,
+	})
+
+	expiry = snapshotstate.ScheduledExpiry(1, 1, taken, now)
+	c.Check(expiry, check.DeepEquals, map[uint64]time.Time{
+		1: now,
+		2: time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC),
+		3: now,
+		4: now,
+		5: now,
+	})
+}
+
+func (snapshotSuite) TestApplyScheduledRetention(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	far := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	st.Set("snapshots", map[uint64]any{
+		// not a scheduled set
+		1: map[string]any{"expiry-time": far},
+		// scheduled sets
+		2: map[string]any{"expiry-time": far, "scheduled": time.Date(2026, 3, 17, 3, 0, 0, 0, time.UTC)},
+		3: map[string]any{"expiry-time": far, "scheduled": time.Date(2026, 3, 18, 3, 0, 0, 0, time.UTC)},
+		// still being saved
+		4: map[string]any{"expiry-time": far, "scheduled": time.Date(2026, 3, 18, 9, 0, 0, 0, time.UTC)},
+	})
+	chg := st.NewChange("scheduled-snapshot", "...")
+	t := st.NewTask("save-snapshot", "...")
+	t.Set("snapshot-setup", map[string]any{"set-id": 4, "snap": "a-snap"})
+	chg.AddTask(t)
+
+	c.Assert(snapshotstate.ApplyScheduledRetention(st, 1, 0, now), check.IsNil)
+
+	var snapshots map[uint64]map[string]any
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots[1]["expiry-time"], check.Equals, "2026-04-01T00:00:00Z")
+	// kept for a day only, so it expires right away
+	c.Check(snapshots[2]["expiry-time"], check.Equals, "2026-03-18T12:00:00Z")
+	c.Check(snapshots[3]["expiry-time"], check.Equals, "2026-03-19T00:00:00Z")
+	c.Check(snapshots[4]["expiry-time"], check.Equals, "2026-04-01T00:00:00Z")
+}
+
+func (snapshotSuite) TestDoSaveScheduled(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	takenAt := time.Date(2026, 3, 18, 3, 0, 0, 0, time.UTC)
+	defer snapshotstate.MockTimeNow(func() time.Time { return takenAt })()
+
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"scheduled": true,
+	})
+
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}}, nil
+	})()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.SaveFlags) (*client.Snapshot, error) {
+		return nil, nil
+	})()
+	defer osutil.MockMountInfo("")()
+	st.Unlock()
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+
+	var snapshots map[uint64]map[string]any
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots[42], check.DeepEquals, map[string]any{
+		// kept for the default 4 weeks from Monday the 16th
+		"expiry-time": "2026-04-13T00:00:00Z",
+		"scheduled":   "2026-03-18T03:00:00Z",
+	})
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshots(c *check.C) {
+	now := time.Now()
+	defer snapshotstate.MockTimeNow(func() time.Time { return now })()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	defer st.Unlock()
+
+	for _, name := range []string{"a-snap", "b-snap", "c-snap"} {
+		sideInfo := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active:   name != "c-snap",
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{sideInfo}),
+			Current:  sideInfo.Revision,
+			SnapType: "app",
+		})
+	}
+
+	// no schedule, nothing happens
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "snapshots.schedule.timer", "00:00-24:00/4"), check.IsNil)
+	c.Assert(tr.Set("core", "snapshots.schedule.snaps", "a-snap,c-snap,d-snap"), check.IsNil)
+	tr.Commit()
+
+	// the schedule starts now
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+
+	// past the next scheduled time, whatever it is
+	now = now.Add(15 * 24 * time.Hour)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Save scheduled snapshot set #1 of snaps "a-snap"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var setup map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &setup), check.IsNil)
+	c.Check(setup["snap"], check.Equals, "a-snap")
+	c.Check(setup["scheduled"], check.Equals, true)
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(now), check.Equals, true)
+
+	// no new change while one is in flight
+	now = now.Add(15 * 24 * time.Hour)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+}
@@ -22,6 +22,7 @@ package snapshotstate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -37,7 +38,10 @@ import (
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 var (
@@ -54,6 +58,8 @@ var (
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandonedImports = backend.CleanupAbandonedImports
+	backendCollectGarbage          = backend.CollectGarbage
+	backendUnlock                  = (*backend.Reader).Unlock
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
@@ -62,11 +68,18 @@ var (
 	backendMapSnapDataDirToSnapVar = backend.MapSnapDataDirToSnapVar
 )
 
+func init() {
+	swfeats.RegisterEnsure("SnapshotManager", "ensureScheduledSnapshots")
+}
+
 // SnapshotManager takes snapshots of active snaps
 type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	nextScheduledSnapshot time.Time
+	lastSnapshotSchedule  string
 }
 
 // Manager returns a new SnapshotManager
@@ -89,6 +102,14 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 // Ensure is part of the overlord.StateManager interface.
 func (mgr *SnapshotManager) Ensure() error {
+	mgr.state.Lock()
+	pruneSnapshotKeys(mgr.state)
+	err := mgr.ensureScheduledSnapshots()
+	mgr.state.Unlock()
+	if err != nil {
+		logger.Noticef("cannot take scheduled snapshots: %v", err)
+	}
+
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
 		return mgr.forgetExpiredSnapshots()
@@ -144,6 +165,8 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return fmt.Errorf("cannot process expired snapshots: %v", err)
 	}
 
+	collectGarbage()
+
 	// only reset time if there are no sets left because of conflicts
 	if len(sets) == 0 {
 		mgr.lastForgetExpiredSnapshotTime = time.Now()
@@ -152,6 +175,75 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 	return nil
 }
 
+// ensureScheduledSnapshots applies the retention policy of scheduled
+// snapshots, and takes a scheduled snapshot set when it's time to.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) ensureScheduledSnapshots() error {
+	st := mgr.state
+
+	schedule, scheduleStr, snaps, retention, err := scheduledSnapshotsConfig(st)
+	if err != nil {
+		return err
+	}
+	now := timeNow()
+	if err := applyScheduledRetention(st, retention, now); err != nil {
+		return fmt.Errorf("cannot apply retention of scheduled snapshots: %v", err)
+	}
+
+	if len(schedule) == 0 {
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+	if scheduleStr != mgr.lastSnapshotSchedule {
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = scheduleStr
+	}
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	if mgr.nextScheduledSnapshot.IsZero() {
+		var last time.Time
+		if err := st.Get("last-scheduled-snapshot", &last); err != nil {
+			if !errors.Is(err, state.ErrNoState) {
+				return err
+			}
+			// the schedule only starts now
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule, last, maxScheduledSnapshotDelay))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) {
+		return nil
+	}
+
+	logger.Trace("ensure", "manager", "SnapshotManager", "func", "ensureScheduledSnapshots")
+
+	names, err := scheduledSnapNames(st, snaps)
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		setID, _, ts, err := save(st, names, nil, nil, nil, true)
+		if err != nil {
+			// most likely a conflict with a change of one of
+			// the snaps, try again on the next Ensure
+			logger.Debugf("Cannot take scheduled snapshot yet: %v", err)
+			return nil
+		}
+		msg := fmt.Sprintf("Save scheduled snapshot set #%d of snaps %s", setID, strutil.Quoted(names))
+		chg := st.NewChange(scheduledSnapshotChangeKind, msg)
+		chg.AddAll(ts)
+		chg.Set("api-data", map[string]any{"snap-names": names, "set-id": setID})
+		st.EnsureBefore(0)
+	}
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	return nil
+}
+
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
 	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" {
 		// check and forget don't affect snaps
@@ -174,6 +266,12 @@ type snapshotSetup struct {
 	Filename string                `json:"filename,omitempty"`
 	Current  snap.Revision         `json:"current"`
 	Auto     bool                  `json:"auto,omitempty"`
+	// Encrypted is set if the snapshot is, or is to be, encrypted;
+	// the key is only kept in memory, see setSnapshotKey
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Scheduled is set for snapshots taken on the schedule set with
+	// snapshots.schedule.timer
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -219,6 +317,15 @@ func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg
 			return nil, nil, nil, err
 		}
 	}
+	if snapshot.Scheduled {
+		_, _, _, retention, err := scheduledSnapshotsConfig(st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := saveScheduled(st, snapshot.SetID, retention, timeNow()); err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
 	return snapshot, cur, cfg, nil
 }
@@ -233,15 +340,31 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 	st.Lock()
 	opts, err := getSnapDirOpts(st, snapshot.Snap)
-	st.Unlock()
 	if err != nil {
+		st.Unlock()
+		return err
+	}
+	incremental, err := incrementalSnapshots(st)
+	if err != nil {
+		st.Unlock()
 		return err
 	}
+	var key []byte
+	if snapshot.Encrypted {
+		key = snapshotKey(st, snapshot.SetID)
+		// encrypted snapshots cannot share their data
+		incremental = false
+	}
+	st.Unlock()
+	if snapshot.Encrypted && key == nil {
+		return errSnapshotKeyUnavailable
+	}
 
 	if err := snapshot.excludeMountPoints(cur, opts); err != nil {
 		logger.Noticef("cannot exclude mount points: %v", err)
 	}
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
+	flags := &backend.SaveFlags{Incremental: incremental, Key: key}
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts, flags)
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
@@ -370,6 +493,10 @@ func doRestore(task *state.Task, tomb *tomb.Tomb) error {
 	}
 	defer reader.Close()
 
+	if err := unlockSnapshot(task.State(), snapshot.SetID, reader); err != nil {
+		return err
+	}
+
 	st := task.State()
 	logf := func(format string, args ...any) {
 		st.Lock()
@@ -500,9 +627,30 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	}
 	defer reader.Close()
 
+	if err := unlockSnapshot(st, snapshot.SetID, reader); err != nil {
+		return err
+	}
+
 	return backendCheck(reader, tomb.Context(nil), snapshot.Users)
 }
 
+var errSnapshotKeyUnavailable = errors.New("passphrase or key file of encrypted snapshot is no longer available, retry the operation")
+
+// unlockSnapshot prepares the reader of an encrypted snapshot for reading,
+// with the key given for the operation on its set.
+func unlockSnapshot(st *state.State, setID uint64, reader *backend.Reader) error {
+	if !reader.Encrypted {
+		return nil
+	}
+	st.Lock()
+	key := snapshotKey(st, setID)
+	st.Unlock()
+	if key == nil {
+		return errSnapshotKeyUnavailable
+	}
+	return backendUnlock(reader, key)
+}
+
 func doForget(task *state.Task, _ *tomb.Tomb) error {
 	// note this is also undoSave
 	st := task.State()
@@ -525,7 +673,20 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+
+	collectGarbage()
+	return nil
+}
+
+// collectGarbage removes the data of incremental snapshots that is no longer
+// used after forgetting some snapshots.
+func collectGarbage() {
+	if _, err := backendCollectGarbage(context.TODO()); err != nil {
+		logger.Noticef("cannot remove unused snapshot data: %v", err)
+	}
 }
 
 func delayedCrossMgrInit() {
@@ -535,7 +696,7 @@ func delayedCrossMgrInit() {
 	snapstate.EstimateSnapshotSize = EstimateSnapshotSize
 }
 
-func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error)) (restore func()) {
+func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.SaveFlags) (*client.Snapshot, error)) (restore func()) {
 	old := backendSave
 	backendSave = f
 	return func() {
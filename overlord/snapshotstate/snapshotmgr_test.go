@@ -37,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -285,7 +286,7 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 
 	expectedOptions := &snap.SnapshotOptions{}
 	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string,
-		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		c.Check(id, check.Equals, uint64(42))
 		c.Check(si, check.DeepEquals, &snapInfo)
 		c.Check(cfg, check.DeepEquals, map[string]any{"hello": "there"})
@@ -563,7 +564,7 @@ func (snapshotSuite) TestDoSaveMountExcludes(c *check.C) {
 
 	var gotOptions *snap.SnapshotOptions
 	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string,
-		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		gotOptions = opts
 		return nil, nil
 	})()
@@ -618,7 +619,7 @@ func (snapshotSuite) TestDoSaveMountExcludesPreservesSetupOptions(c *check.C) {
 
 	var gotOptions *snap.SnapshotOptions
 	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string,
-		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		gotOptions = opts
 		return nil, nil
 	})()
@@ -660,7 +661,7 @@ func (snapshotSuite) TestDoSaveMountExcludesLoadMountInfoError(c *check.C) {
 	setupOptions := &snap.SnapshotOptions{Exclude: []string{"$SNAP_DATA/logs"}}
 	var gotOptions *snap.SnapshotOptions
 	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string,
-		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		gotOptions = opts
 		return nil, nil
 	})()
@@ -705,7 +706,7 @@ func (snapshotSuite) TestDoSaveNoMounts(c *check.C) {
 	setupOptions := &snap.SnapshotOptions{Exclude: []string{"$SNAP_DATA/cache"}}
 	var gotOptions *snap.SnapshotOptions
 	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string,
-		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		gotOptions = opts
 		return nil, nil
 	})()
@@ -752,7 +753,7 @@ func (snapshotSuite) TestDoSaveMountExcludesMapDirToVarError(c *check.C) {
 	setupOptions := &snap.SnapshotOptions{Exclude: []string{"$SNAP_DATA/logs"}}
 	var gotOptions *snap.SnapshotOptions
 	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string,
-		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		opts *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		gotOptions = opts
 		return nil, nil
 	})()
@@ -798,7 +799,7 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	defer osutil.MockMountInfo("")()
 
 	var checkOpts bool
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		c.Check(opts.HiddenSnapDataDir, check.Equals, true)
 		checkOpts = true
 		return nil, nil
@@ -817,12 +818,96 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	c.Check(checkOpts, check.Equals, true)
 }
 
+func (snapshotSuite) TestDoSaveIncremental(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer osutil.MockMountInfo("")()
+
+	var gotFlags []*backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, flags *backend.SaveFlags) (*client.Snapshot, error) {
+		gotFlags = append(gotFlags, flags)
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	for _, incremental := range []bool{false, true} {
+		st.Lock()
+		tr := config.NewTransaction(st)
+		tr.Set("core", "snapshots.incremental", incremental)
+		tr.Commit()
+		task := st.NewTask("save-snapshot", "...")
+		task.Set("snapshot-setup", map[string]any{
+			"snap": "a-snap",
+		})
+		st.Unlock()
+
+		err := snapshotstate.DoSave(task, &tomb.Tomb{})
+		c.Assert(err, check.IsNil)
+	}
+	c.Check(gotFlags, check.DeepEquals, []*backend.SaveFlags{{Incremental: false}, {Incremental: true}})
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer osutil.MockMountInfo("")()
+
+	var gotFlags *backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]any, _ []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, flags *backend.SaveFlags) (*client.Snapshot, error) {
+		gotFlags = flags
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	// encrypted snapshots are never incremental
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.incremental", true)
+	tr.Commit()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"encrypted": true,
+	})
+	st.Unlock()
+
+	// the key is gone, e.g. snapd was restarted
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "passphrase or key file of encrypted snapshot is no longer available, retry the operation")
+	c.Check(gotFlags, check.IsNil)
+
+	st.Lock()
+	snapshotstate.SetSnapshotKey(st, 42, []byte("secret"))
+	st.Unlock()
+
+	err = snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(gotFlags, check.DeepEquals, &backend.SaveFlags{Key: []byte("secret")})
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
 	})()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -849,7 +934,7 @@ func (snapshotSuite) TestDoSaveFailsWithNoSnapshot(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -874,7 +959,7 @@ func (snapshotSuite) TestDoSaveFailsBackendError(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
 	defer osutil.MockMountInfo("")()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, errors.New("bzzt")
 	})()
 
@@ -903,7 +988,7 @@ func (snapshotSuite) TestDoSaveFailsConfigError(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
 		return nil, errors.New("bzzt")
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -934,7 +1019,7 @@ func (snapshotSuite) TestDoSaveFailsBadConfig(c *check.C) {
 		buf := json.RawMessage(`"hello-there"`)
 		return &buf, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -968,7 +1053,7 @@ func (snapshotSuite) TestDoSaveFailureRemovesStateEntry(c *check.C) {
 		return nil, nil
 	})()
 	defer osutil.MockMountInfo("")()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		var expirations map[uint64]any
 		st.Lock()
 		defer st.Unlock()
@@ -1300,15 +1385,100 @@ func (rs *readerSuite) TestDoCheck(c *check.C) {
 	c.Check(rs.calls, check.DeepEquals, []string{"open", "check"})
 }
 
+func (rs *readerSuite) TestDoCheckEncrypted(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Encrypted: true},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendUnlock(func(_ *backend.Reader, key []byte) error {
+		rs.calls = append(rs.calls, "unlock")
+		c.Check(key, check.DeepEquals, []byte("secret"))
+		return nil
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	rs.task.Set("snapshot-setup", map[string]any{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"filename":  "/some/42_file.zip",
+		"encrypted": true,
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "passphrase or key file of encrypted snapshot is no longer available, retry the operation")
+	c.Check(rs.calls, check.DeepEquals, []string{"open"})
+
+	st.Lock()
+	snapshotstate.SetSnapshotKey(st, 42, []byte("secret"))
+	st.Unlock()
+
+	rs.calls = nil
+	err = snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"open", "unlock", "check"})
+}
+
+func (rs *readerSuite) TestDoRestoreEncryptedWrongKey(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Encrypted: true},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendUnlock(func(*backend.Reader, []byte) error {
+		rs.calls = append(rs.calls, "unlock")
+		return backend.ErrWrongKey
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	rs.task.Set("snapshot-setup", map[string]any{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"filename":  "/some/42_file.zip",
+		"encrypted": true,
+	})
+	snapshotstate.SetSnapshotKey(st, 42, []byte("not-the-secret"))
+	st.Unlock()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.Equals, backend.ErrWrongKey)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "unlock"})
+}
+
 func (rs *readerSuite) TestDoRemove(c *check.C) {
 	defer snapshotstate.MockOsRemove(func(filename string) error {
 		c.Check(filename, check.Equals, "/some/1_file.zip")
 		rs.calls = append(rs.calls, "remove")
 		return nil
 	})()
+	defer snapshotstate.MockBackendCollectGarbage(func(context.Context) (int, error) {
+		rs.calls = append(rs.calls, "collect-garbage")
+		return 0, nil
+	})()
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "collect-garbage"})
+}
+
+func (rs *readerSuite) TestDoRemoveCollectGarbageError(c *check.C) {
+	defer snapshotstate.MockOsRemove(func(string) error {
+		return nil
+	})()
+	defer snapshotstate.MockBackendCollectGarbage(func(context.Context) (int, error) {
+		return 0, errors.New("bzzt")
+	})()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	// not being able to clean up doesn't fail the forget
 	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
 	c.Assert(err, check.IsNil)
-	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+	c.Check(logbuf.String(), testutil.Contains, "cannot remove unused snapshot data: bzzt")
 }
 
 func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
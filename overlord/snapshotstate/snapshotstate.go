@@ -53,6 +53,9 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// Scheduled is when a scheduled snapshot set was taken, see
+	// scheduledRetention
+	Scheduled *time.Time `json:"scheduled,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -151,6 +154,17 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 	return defaultAutomaticSnapshotExpiration, nil
 }
 
+// incrementalSnapshots returns whether snapshots are to be saved
+// incrementally, as set by snapshots.incremental.
+func incrementalSnapshots(st *state.State) (bool, error) {
+	var incremental bool
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.incremental", &incremental); err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return incremental, nil
+}
+
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
@@ -228,10 +242,20 @@ func (summaries snapshotSnapSummaries) snapNames() []string {
 }
 
 type snapshotSnapSummary struct {
-	snap     string
-	snapID   string
-	filename string
-	epoch    snap.Epoch
+	snap      string
+	snapID    string
+	filename  string
+	epoch     snap.Epoch
+	encrypted bool
+}
+
+func (summaries snapshotSnapSummaries) encrypted() bool {
+	for _, summary := range summaries {
+		if summary.encrypted {
+			return true
+		}
+	}
+	return false
 }
 
 // snapSummariesInSnapshotSet goes looking for the requested snaps in the
@@ -244,10 +268,11 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 			found = true
 			if len(requested) == 0 || strutil.SortedListContains(requested, r.Snap) {
 				summaries = append(summaries, &snapshotSnapSummary{
-					filename: r.Name(),
-					snap:     r.Snap,
-					snapID:   r.SnapID,
-					epoch:    r.Epoch,
+					filename:  r.Name(),
+					snap:      r.Snap,
+					snapID:    r.SnapID,
+					epoch:     r.Epoch,
+					encrypted: r.Encrypted,
 				})
 			}
 		}
@@ -336,7 +361,10 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 }
 
 // Import a given snapshot ID from an exported snapshot
-func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, snapNames []string, err error) {
+//
+// The key is the passphrase or the content of the key file needed for
+// encrypted exports or snapshots, if any.
+func Import(ctx context.Context, st *state.State, r io.Reader, key []byte) (setID uint64, snapNames []string, err error) {
 	st.Lock()
 	setID, err = newSnapshotSetID(st)
 	// note, this is a new set id which is not exposed yet, no need to mark it
@@ -347,7 +375,11 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 		return 0, nil, err
 	}
 
-	snapNames, err = backendImport(ctx, setID, r, nil)
+	var flags *backend.ImportFlags
+	if key != nil {
+		flags = &backend.ImportFlags{Key: key}
+	}
+	snapNames, err = backendImport(ctx, setID, r, flags)
 	if err != nil {
 		if dupErr, ok := err.(backend.DuplicatedSnapshotImportError); ok {
 			st.Lock()
@@ -356,7 +388,7 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 			if err := checkSnapshotConflict(st, dupErr.SetID, "forget-snapshot"); err != nil {
 				// we found an existing snapshot but it's being forgotten, so
 				// retry the import without checking for existing snapshot.
-				flags := &backend.ImportFlags{NoDuplicatedImportCheck: true}
+				flags := &backend.ImportFlags{NoDuplicatedImportCheck: true, Key: key}
 				st.Unlock()
 				snapNames, err = backendImport(ctx, setID, r, flags)
 				st.Lock()
@@ -378,9 +410,15 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 	return setID, snapNames, nil
 }
 
-// Save creates a taskset for taking snapshots of snaps' data.
+// Save creates a taskset for taking snapshots of snaps' data. If key is not
+// nil the snapshots are encrypted with it: it's a passphrase or the content
+// of a key file.
 // Note that the state must be locked by the caller.
-func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, key []byte) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	return save(st, instanceNames, users, options, key, false)
+}
+
+func save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, key []byte, scheduled bool) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -409,6 +447,10 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		return 0, nil, nil, err
 	}
 
+	if key != nil {
+		setSnapshotKey(st, setID, key)
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, name := range instanceNames {
@@ -418,10 +460,12 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		task := st.NewTask("save-snapshot", desc)
 
 		snapshot := snapshotSetup{
-			SetID:   setID,
-			Snap:    name,
-			Users:   users,
-			Options: options[name],
+			SetID:     setID,
+			Snap:      name,
+			Users:     users,
+			Options:   options[name],
+			Encrypted: key != nil,
+			Scheduled: scheduled,
 		}
 
 		task.Set("snapshot-setup", &snapshot)
@@ -476,13 +520,17 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 	return ts, nil
 }
 
-// Restore creates a taskset for restoring a snapshot's data.
+// Restore creates a taskset for restoring a snapshot's data. The key is the
+// passphrase or the content of the key file needed for encrypted snapshots.
 // Note that the state must be locked by the caller.
-func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Restore(st *state.State, setID uint64, snapNames []string, users []string, key []byte) (snapsFound []string, ts *state.TaskSet, err error) {
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := useSnapshotKey(st, setID, summaries, key); err != nil {
+		return nil, nil, err
+	}
 	all, err := snapstateAll(st)
 	if err != nil {
 		return nil, nil, err
@@ -525,11 +573,12 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
 		task := st.NewTask("restore-snapshot", desc)
 		snapshot := snapshotSetup{
-			SetID:    setID,
-			Snap:     summary.snap,
-			Users:    users,
-			Filename: summary.filename,
-			Current:  current,
+			SetID:     setID,
+			Snap:      summary.snap,
+			Users:     users,
+			Filename:  summary.filename,
+			Current:   current,
+			Encrypted: summary.encrypted,
 		}
 		task.Set("snapshot-setup", &snapshot)
 		// see the note about snapshots not using lanes, above.
@@ -551,9 +600,10 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 	return snapsFound, ts, nil
 }
 
-// Check creates a taskset for checking a snapshot's data.
+// Check creates a taskset for checking a snapshot's data. The key is the
+// passphrase or the content of the key file needed for encrypted snapshots.
 // Note that the state must be locked by the caller.
-func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Check(st *state.State, setID uint64, snapNames []string, users []string, key []byte) (snapsFound []string, ts *state.TaskSet, err error) {
 	// check needs to conflict with forget of itself
 	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, nil, err
@@ -563,6 +613,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := useSnapshotKey(st, setID, summaries, key); err != nil {
+		return nil, nil, err
+	}
 
 	ts = state.NewTaskSet()
 
@@ -570,10 +623,11 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 		desc := fmt.Sprintf("Check data of snap %q in snapshot set #%d", summary.snap, setID)
 		task := st.NewTask("check-snapshot", desc)
 		snapshot := snapshotSetup{
-			SetID:    setID,
-			Snap:     summary.snap,
-			Users:    users,
-			Filename: summary.filename,
+			SetID:     setID,
+			Snap:      summary.snap,
+			Users:     users,
+			Filename:  summary.filename,
+			Encrypted: summary.encrypted,
 		}
 		task.Set("snapshot-setup", &snapshot)
 		ts.AddTask(task)
@@ -612,6 +666,74 @@ func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []str
 	return summaries.snapNames(), ts, nil
 }
 
+// setSnapshotKey remembers the passphrase or key file content given for an
+// operation on an encrypted snapshot set, for its tasks to use. Keys are only
+// kept in memory, and forgotten once no task needs them anymore, see
+// pruneSnapshotKeys. The state must be locked by the caller.
+func setSnapshotKey(st *state.State, setID uint64, key []byte) {
+	var keys map[uint64][]byte
+	if val := st.Cached("snapshot-keys"); val != nil {
+		keys, _ = val.(map[uint64][]byte)
+	} else {
+		keys = make(map[uint64][]byte)
+	}
+	keys[setID] = key
+	st.Cache("snapshot-keys", keys)
+}
+
+// snapshotKey returns the key remembered for the given set ID, if any.
+// The state must be locked by the caller.
+func snapshotKey(st *state.State, setID uint64) []byte {
+	keys, _ := st.Cached("snapshot-keys").(map[uint64][]byte)
+	return keys[setID]
+}
+
+// useSnapshotKey checks a key was given if any of the snapshots is
+// encrypted, and remembers it for the tasks operating on them.
+func useSnapshotKey(st *state.State, setID uint64, summaries snapshotSnapSummaries, key []byte) error {
+	if !summaries.encrypted() {
+		return nil
+	}
+	if key == nil {
+		return fmt.Errorf("snapshot set #%d is encrypted, a passphrase or key file is needed", setID)
+	}
+	setSnapshotKey(st, setID, key)
+	return nil
+}
+
+// pruneSnapshotKeys forgets the keys of snapshot sets no pending task
+// operates on anymore. The state must be locked by the caller.
+func pruneSnapshotKeys(st *state.State) {
+	keys, _ := st.Cached("snapshot-keys").(map[uint64][]byte)
+	if len(keys) == 0 {
+		return
+	}
+	needed := make(map[uint64]bool)
+	for _, t := range st.Tasks() {
+		if t.Status().Ready() {
+			continue
+		}
+		switch t.Kind() {
+		case "save-snapshot", "check-snapshot", "restore-snapshot":
+		default:
+			continue
+		}
+		var snapshot snapshotSetup
+		if err := t.Get("snapshot-setup", &snapshot); err != nil {
+			continue
+		}
+		if snapshot.Encrypted {
+			needed[snapshot.SetID] = true
+		}
+	}
+	for setID := range keys {
+		if !needed[setID] {
+			delete(keys, setID)
+		}
+	}
+	st.Cache("snapshot-keys", keys)
+}
+
 // setSnapshotOpInProgress marks the given set ID as being a subject of
 // snapshot op inside state cache. The state must be locked by the caller.
 func setSnapshotOpInProgress(st *state.State, setID uint64, op string) {
@@ -641,15 +763,20 @@ func UnsetSnapshotOpInProgress(st *state.State, setID uint64) string {
 	return op
 }
 
-// Export exports a given snapshot ID
+// Export exports a given snapshot ID. If key is not nil the export is
+// encrypted with it: it's a passphrase or the content of a key file.
 // Note that the state must be locked by the caller.
-func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.SnapshotExport, err error) {
+func Export(ctx context.Context, st *state.State, setID uint64, key []byte) (se *backend.SnapshotExport, err error) {
 	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, err
 	}
 
+	var flags *backend.ExportFlags
+	if key != nil {
+		flags = &backend.ExportFlags{Key: key}
+	}
 	setSnapshotOpInProgress(st, setID, "export-snapshot")
-	se, err = backendNewSnapshotExport(ctx, setID)
+	se, err = backendNewSnapshotExport(ctx, setID, flags)
 	if err != nil {
 		UnsetSnapshotOpInProgress(st, setID)
 	}
@@ -366,7 +366,7 @@ func (snapshotSuite) TestSaveChecksSnapnamesError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -431,7 +431,7 @@ func (s snapshotSuite) TestSaveChecksSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 }
@@ -467,7 +467,7 @@ func (snapshotSuite) TestSaveConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-save", "...")
-	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(saveTasks)
 
@@ -483,7 +483,7 @@ func (snapshotSuite) TestSaveChecksSnapstateConflictError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -494,7 +494,7 @@ func (snapshotSuite) TestSaveChecksSetIDError(c *check.C) {
 
 	st.Set("last-snapshot-set-id", "3/4")
 
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, ".* could not unmarshal .*")
 }
 
@@ -503,7 +503,7 @@ func (snapshotSuite) TestSaveNoSnapsInState(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.HasLen, 0)
@@ -515,7 +515,7 @@ func (snapshotSuite) TestSaveSnapNotInstalled(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `snap "foo" is not installed`)
 	c.Check(setID, check.Equals, uint64(0))
 	c.Check(saved, check.HasLen, 0)
@@ -541,7 +541,7 @@ func (snapshotSuite) TestSaveSomeSnaps(c *check.C) {
 		"a-snap": {Exclude: []string{"$SNAP_COMMON/exclude", "$SNAP_DATA/exclude"}},
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap", "c-snap"})
@@ -569,6 +569,44 @@ func (snapshotSuite) TestSaveSomeSnaps(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestSaveEncrypted(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setID, _, taskset, err := snapshotstate.Save(st, nil, nil, nil, []byte("secret"))
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+
+	var snapshot map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]any{
+		"set-id":    1.,
+		"snap":      "a-snap",
+		"current":   "unset",
+		"encrypted": true,
+	})
+	// the key itself is only kept in memory
+	c.Check(snapshotstate.SnapshotKey(st, setID), check.DeepEquals, []byte("secret"))
+
+	// and is kept only while tasks need it
+	chg := st.NewChange("save-snapshot", "...")
+	chg.AddAll(taskset)
+	snapshotstate.PruneSnapshotKeys(st)
+	c.Check(snapshotstate.SnapshotKey(st, setID), check.DeepEquals, []byte("secret"))
+
+	tasks[0].SetStatus(state.DoneStatus)
+	snapshotstate.PruneSnapshotKeys(st)
+	c.Check(snapshotstate.SnapshotKey(st, setID), check.IsNil)
+}
+
 func (s snapshotSuite) TestSaveOneSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
 		// snapstate.All isn't called when a snap name is passed in
@@ -587,7 +625,7 @@ func (s snapshotSuite) TestSaveOneSnap(c *check.C) {
 		Current: snap.R(1),
 	})
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap"})
@@ -666,7 +704,7 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 		}
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -773,7 +811,7 @@ exec /bin/tar "$@"
 		c.Assert(os.Mkdir(filepath.Join(homedir, "snap", name, "common", "common-"+name), mode), check.IsNil)
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -873,7 +911,7 @@ exec /bin/tar "$@"
 	// these dir permissions (000) make tar unhappy
 	c.Assert(os.Mkdir(filepath.Join(homedir, "snap/tar-fail-snap/common/common-tar-fail-snap"), 00), check.IsNil)
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"tar-fail-snap"})
@@ -936,7 +974,7 @@ func (snapshotSuite) TestRestoreChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -944,7 +982,7 @@ func (s snapshotSuite) TestRestoreChecksSnapstateConflicts(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 
@@ -1001,7 +1039,7 @@ func (snapshotSuite) TestRestoreConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-restore", "...")
-	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(restoreTasks)
 
@@ -1033,7 +1071,7 @@ func (snapshotSuite) TestRestoreChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -1068,7 +1106,7 @@ func (snapshotSuite) TestRestoreChecksChangesToSnapID(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(ID 1234567…\) does not match snapshot \(ID 0987654…\)`)
 }
 
@@ -1109,7 +1147,7 @@ func (snapshotSuite) TestRestoreChecksChangesToEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(epoch 17\) cannot read snapshot data \(epoch 42\)`)
 }
 
@@ -1150,7 +1188,7 @@ func (snapshotSuite) TestRestoreWorksWithCompatibleEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil)
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1187,7 +1225,7 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1269,7 +1307,7 @@ func testRestoreIntegration(c *check.C, snapDataDir string, opts *dirs.SnapDirOp
 			c.Assert(os.MkdirAll(filepath.Join(home, snapDataDir, name, "common", "common-"+name), 0755), check.IsNil)
 		}
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1278,7 +1316,7 @@ func testRestoreIntegration(c *check.C, snapDataDir string, opts *dirs.SnapDirOp
 	// remove b-user's home
 	c.Assert(os.RemoveAll(homedirB), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1348,7 +1386,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, fmt.Sprint(i+1), "canary-"+name), 0755), check.IsNil)
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, "common", "common-"+name), 0755), check.IsNil)
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1358,7 +1396,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap"), 0755), check.IsNil)
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", "too-snap"), 0), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1413,7 +1451,7 @@ func (snapshotSuite) TestCheckChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -1421,7 +1459,7 @@ func (s snapshotSuite) TestCheckDoesNotTriggerSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 }
 
@@ -1449,7 +1487,7 @@ func (snapshotSuite) TestCheckChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Check(st, 42, nil, nil)
+	_, _, err = snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -1472,7 +1510,7 @@ func (snapshotSuite) TestCheck(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -1490,6 +1528,37 @@ func (snapshotSuite) TestCheck(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestCheckEncrypted(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap", Encrypted: true},
+			File:     shotfile,
+		}), check.IsNil)
+		return nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err = snapshotstate.Check(st, 42, nil, nil, nil)
+	c.Assert(err, check.ErrorMatches, `snapshot set #42 is encrypted, a passphrase or key file is needed`)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
+	c.Assert(err, check.ErrorMatches, `snapshot set #42 is encrypted, a passphrase or key file is needed`)
+
+	_, taskset, err := snapshotstate.Check(st, 42, nil, nil, []byte("secret"))
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var snapshot map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["encrypted"], check.Equals, true)
+	c.Check(snapshotstate.SnapshotKey(st, 42), check.DeepEquals, []byte("secret"))
+}
+
 func (snapshotSuite) TestForgetChecksIterError(c *check.C) {
 	defer snapshotstate.MockBackendIter(func(context.Context, func(*backend.Reader) error) error {
 		return errors.New("bzzt")
@@ -1859,7 +1928,7 @@ func (snapshotSuite) TestImportSnapshotHappy(c *check.C) {
 	})
 	defer restore()
 
-	sid, names, err := snapshotstate.Import(context.TODO(), st, buf)
+	sid, names, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(1))
 	c.Check(names, check.DeepEquals, fakeSnapNames)
@@ -1874,7 +1943,7 @@ func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 	defer restore()
 
 	r := bytes.NewBufferString("faked-import-data")
-	sid, _, err := snapshotstate.Import(context.TODO(), st, r)
+	sid, _, err := snapshotstate.Import(context.TODO(), st, r, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "some-error")
 	c.Check(sid, check.Equals, uint64(0))
@@ -1895,7 +1964,7 @@ func (snapshotSuite) TestImportSnapshotDuplicate(c *check.C) {
 	})
 	st.Unlock()
 
-	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""))
+	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""), nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(3))
 	c.Check(snapNames, check.DeepEquals, []string{"foo-snap"})
@@ -2013,7 +2082,7 @@ func (snapshotSuite) TestExportSnapshotConflictsWithForget(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, `cannot operate on snapshot set #42 while change "1" is in progress`)
 }
@@ -2029,7 +2098,7 @@ func (snapshotSuite) TestImportSnapshotDuplicatedNoConflict(c *check.C) {
 	defer restore()
 
 	st := state.New(nil)
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	c.Check(importCalls, check.Equals, 1)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(42))
@@ -2069,7 +2138,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 	chg.AddTask(tsk)
 
 	st.Unlock()
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, nil)
 	st.Lock()
 	c.Check(importCalls, check.Equals, 2)
 	c.Assert(err, check.IsNil)
@@ -2078,7 +2147,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 }
 
 func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
-	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64) (se *backend.SnapshotExport, err error) {
+	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (se *backend.SnapshotExport, err error) {
 		return nil, nil
 	})
 	defer restore()
@@ -2087,7 +2156,7 @@ func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, nil)
 	c.Assert(err, check.IsNil)
 
 	ops := st.Cached("snapshot-ops")
@@ -2126,5 +2195,5 @@ func (snapshotSuite) TestSetSnapshotOpInProgress(c *check.C) {
 }
 
 func (s *snapshotSuite) TestEnsureLoopLogging(c *check.C) {
-	swfeatstest.CheckEnsureLoopLogging("snapshotmgr.go", c, false)
+	swfeatstest.CheckEnsureLoopLogging("snapshotmgr.go", c, true)
 }
@@ -139,6 +139,7 @@ type autoRefresh struct {
 	state *state.State
 
 	lastRefreshSchedule string
+	lastRefreshBlackout string
 	nextRefresh         time.Time
 	lastRefreshAttempt  time.Time
 
@@ -159,6 +160,13 @@ func (m *autoRefresh) RefreshSchedule() (schedule string, legacy bool, err error
 	return schedule, legacy, err
 }
 
+// RefreshBlackout returns the user visible string with the blackouts during
+// which automatic refreshes do not happen.
+func (m *autoRefresh) RefreshBlackout() (string, error) {
+	_, blackout, err := refreshBlackouts(m.state)
+	return blackout, err
+}
+
 // NextRefresh returns when the next automatic refresh will happen.
 func (m *autoRefresh) NextRefresh() time.Time {
 	return m.nextRefresh
@@ -338,15 +346,23 @@ func (m *autoRefresh) Ensure() (err error) {
 		m.nextRefresh = time.Time{}
 		return nil
 	}
+	blackouts, blackoutStr, err := refreshBlackouts(m.state)
+	if err != nil {
+		return err
+	}
 	// we already have a refresh time, check if we got a new config
 	if !m.nextRefresh.IsZero() {
 		if m.lastRefreshSchedule != refreshScheduleStr {
 			// the refresh schedule has changed
 			logger.Debugf("Refresh timer changed.")
 			m.nextRefresh = time.Time{}
+		} else if m.lastRefreshBlackout != blackoutStr {
+			logger.Debugf("Refresh blackout changed.")
+			m.nextRefresh = time.Time{}
 		}
 	}
 	m.lastRefreshSchedule = refreshScheduleStr
+	m.lastRefreshBlackout = blackoutStr
 
 	// ensure nothing is in flight already
 	if autoRefreshInFlight(m.state) {
@@ -370,6 +386,7 @@ func (m *autoRefresh) Ensure() (err error) {
 			// immediate
 			m.nextRefresh = now
 		}
+		m.nextRefresh = nextRefreshOutsideBlackouts(refreshSchedule, blackouts, m.nextRefresh)
 		logger.Debugf("Next refresh scheduled for %s.", m.nextRefresh.Format(time.RFC3339))
 	}
 
@@ -387,7 +404,7 @@ func (m *autoRefresh) Ensure() (err error) {
 				// next refresh is obsolete, compute the next one
 				delta := timeutil.Next(refreshSchedule, holdTime, maxPostponement)
 				now = time.Now()
-				m.nextRefresh = now.Add(delta)
+				m.nextRefresh = nextRefreshOutsideBlackouts(refreshSchedule, blackouts, now.Add(delta))
 			}
 		}
 
@@ -397,6 +414,14 @@ func (m *autoRefresh) Ensure() (err error) {
 		// before now, and the next refresh is equal to now without requiring an
 		// or operation
 		if !m.nextRefresh.After(now) {
+			if timeutil.InBlackout(blackouts, now) {
+				// the refresh time was missed and it's now in a
+				// blackout, postpone the refresh past it
+				m.nextRefresh = nextRefreshOutsideBlackouts(refreshSchedule, blackouts, now)
+				logger.Debugf("Refresh postponed by blackout to %s.", m.nextRefresh.Format(time.RFC3339))
+				return nil
+			}
+
 			var can bool
 			can, err = m.canRefreshRespectingMetered(now, lastRefresh)
 			if err != nil {
@@ -527,6 +552,46 @@ func getRefreshScheduleConf(st *state.State) (confStr string, legacy bool, err e
 	return confStr, legacy, nil
 }
 
+// refreshBlackouts returns the blackouts during which auto-refreshes must not
+// happen, along with their configuration string.
+func refreshBlackouts(st *state.State) (blackouts []*timeutil.Blackout, blackoutConf string, err error) {
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "refresh.blackout", &blackoutConf); err != nil && !config.IsNoOption(err) {
+		return nil, "", err
+	}
+	if blackoutConf == "" {
+		return nil, "", nil
+	}
+
+	blackouts, err = timeutil.ParseBlackouts(blackoutConf)
+	if err != nil {
+		// log instead of fail in order not to prevent auto-refreshes
+		logger.Noticef("cannot use refresh.blackout configuration: %v", err)
+		return nil, "", nil
+	}
+	return blackouts, blackoutConf, nil
+}
+
+// maxBlackoutSkips is how many times the next refresh can be moved past
+// blackouts, in case the schedule keeps hitting them.
+const maxBlackoutSkips = 10
+
+// nextRefreshOutsideBlackouts returns the given next refresh time if it's
+// outside of the blackouts, otherwise the next time according to the schedule
+// once the blackouts have ended.
+func nextRefreshOutsideBlackouts(schedule []*timeutil.Schedule, blackouts []*timeutil.Blackout, next time.Time) time.Time {
+	for i := 0; i < maxBlackoutSkips; i++ {
+		end := timeutil.BlackoutEnd(blackouts, next)
+		if end.IsZero() || end.Equal(next) {
+			// either not in a blackout or in one which is too long to
+			// skip, in which case the refresh is prevented when due
+			return next
+		}
+		next = time.Now().Add(timeutil.Next(schedule, end, maxPostponement))
+	}
+	return next
+}
+
 // refreshScheduleWithDefaultsFallback returns the current refresh schedule
 // and refresh string.
 func (m *autoRefresh) refreshScheduleWithDefaultsFallback() (sched []*timeutil.Schedule, scheduleConf string, legacy bool, err error) {
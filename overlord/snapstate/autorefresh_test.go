@@ -587,6 +587,73 @@ func (s *autoRefreshTestSuite) TestLastRefreshRefreshHoldExpiredReschedule(c *C)
 	c.Check(nextRefresh1.Before(nextRefresh), Equals, false)
 }
 
+func (s *autoRefreshTestSuite) TestRefreshBlackout(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t0 := time.Now()
+	s.state.Set("last-refresh", t0.Add(-12*time.Hour))
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.blackout", t0.Format("2006-01-02"))
+	tr.Commit()
+	tomorrow := time.Date(t0.Year(), t0.Month(), t0.Day()+1, 0, 0, 0, 0, time.Local)
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+
+	// no refresh
+	c.Check(s.store.ops, HasLen, 0)
+	// the next refresh is after the blackout
+	c.Check(af.NextRefresh().Before(tomorrow), Equals, false)
+	blackout, err := af.RefreshBlackout()
+	c.Assert(err, IsNil)
+	c.Check(blackout, Equals, t0.Format("2006-01-02"))
+
+	// a missed refresh is not attempted during the blackout either
+	snapstate.MockNextRefresh(af, t0.Add(-time.Minute))
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+	c.Check(af.NextRefresh().Before(tomorrow), Equals, false)
+
+	// the refresh happens once the blackout is removed
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "refresh.blackout", "")
+	tr.Commit()
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+func (s *autoRefreshTestSuite) TestRefreshBlackoutInvalidIgnored(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.blackout", "invalid")
+	tr.Commit()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+
+	// the blackout does not prevent auto-refreshes
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+	blackout, err := af.RefreshBlackout()
+	c.Assert(err, IsNil)
+	c.Check(blackout, Equals, "")
+}
+
 func (s *autoRefreshTestSuite) TestEnsureRefreshHoldAtLeastZeroTimes(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
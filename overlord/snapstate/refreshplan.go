@@ -0,0 +1,311 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// RefreshPlan describes what an auto-refresh would do if it ran now.
+type RefreshPlan struct {
+	// Snaps are the snaps which have updates, sorted by name.
+	Snaps []*RefreshPlanSnap `json:"snaps"`
+	// DownloadSize is the total size of the snaps and components which
+	// would be downloaded, that is excluding held and conflicting snaps.
+	DownloadSize int64 `json:"download-size"`
+	// Blackout is the refresh.blackout configuration, if any.
+	Blackout string `json:"blackout,omitempty"`
+	// BlackoutUntil is set if auto-refreshes are currently prevented by a
+	// blackout, to the time when it ends.
+	BlackoutUntil *time.Time `json:"blackout-until,omitempty"`
+}
+
+// RefreshPlanSnap describes the update of a snap in a refresh plan.
+type RefreshPlanSnap struct {
+	Name            string        `json:"name"`
+	CurrentRevision snap.Revision `json:"current-revision"`
+	Revision        snap.Revision `json:"revision"`
+	Version         string        `json:"version,omitempty"`
+	Channel         string        `json:"channel,omitempty"`
+	DownloadSize    int64         `json:"download-size"`
+
+	Components []RefreshPlanComponent `json:"components,omitempty"`
+
+	// HeldBy lists the snaps holding the refresh of the snap, "system"
+	// standing for a hold set by the sysadmin.
+	HeldBy []string `json:"held-by,omitempty"`
+	// HeldUntil is the time until which the refresh of the snap is held.
+	HeldUntil *time.Time `json:"held-until,omitempty"`
+	// GatingSnaps lists the snaps whose gate-auto-refresh hook would run,
+	// and which could then hold the refresh of the snap.
+	GatingSnaps []string `json:"gating-snaps,omitempty"`
+	// Inhibited is set if the refresh of the snap would be postponed by its
+	// running apps or hooks.
+	Inhibited *RefreshPlanInhibition `json:"inhibited,omitempty"`
+	// Conflict is set if the snap would not be refreshed because of a
+	// conflicting change in progress.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// RefreshPlanComponent describes the update of a component in a refresh plan.
+type RefreshPlanComponent struct {
+	Name            string        `json:"name"`
+	CurrentRevision snap.Revision `json:"current-revision"`
+	Revision        snap.Revision `json:"revision"`
+	DownloadSize    int64         `json:"download-size"`
+}
+
+// RefreshPlanInhibition describes why and for how long the refresh of a snap
+// would be postponed by its running apps or hooks.
+type RefreshPlanInhibition struct {
+	BusyApps  []string `json:"busy-apps,omitempty"`
+	BusyHooks []string `json:"busy-hooks,omitempty"`
+	// ForcedAfter is the time after which the snap is refreshed regardless
+	// of its running apps or hooks.
+	ForcedAfter time.Time `json:"forced-after"`
+}
+
+// RefreshPlanPreview computes what an auto-refresh would do if it ran now,
+// in the same way as autoRefreshPhase1 but without creating any tasks or
+// otherwise modifying the state: refresh candidates are not recorded, holds
+// are not pruned and no refresh inhibition is started.
+// The state needs to be locked by the caller, it is unlocked while querying
+// the store.
+func RefreshPlanPreview(ctx context.Context, st *state.State) (*RefreshPlan, error) {
+	user, err := userFromUserID(st, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	allSnaps, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshOpts := &store.RefreshOptions{Scheduled: true}
+	plan, err := storeUpdatePlan(ctx, st, allSnaps, nil, user, refreshOpts, Options{})
+	if err != nil {
+		return nil, err
+	}
+	deviceCtx, err := DeviceCtxFromState(st, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hints, err := refreshHintsFromUpdatePlan(st, plan, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := HeldSnaps(st, HoldAutoRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	blackouts, blackoutStr, err := refreshBlackouts(st)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshPlan := &RefreshPlan{
+		Snaps:    []*RefreshPlanSnap{},
+		Blackout: blackoutStr,
+	}
+	now := timeNow()
+	if blackoutEnd := timeutil.BlackoutEnd(blackouts, now); !blackoutEnd.Equal(now) && !blackoutEnd.IsZero() {
+		refreshPlan.BlackoutUntil = &blackoutEnd
+	}
+	byName := make(map[string]*RefreshPlanSnap, len(hints))
+	var updates []string
+	for _, t := range plan.targets {
+		name := t.info.InstanceName()
+		cand, ok := hints[name]
+		if !ok {
+			// filtered out by refreshHintsFromUpdatePlan
+			continue
+		}
+
+		planSnap := &RefreshPlanSnap{
+			Name:            name,
+			CurrentRevision: t.snapst.Current,
+			Revision:        cand.Revision(),
+			Version:         t.info.Version,
+			Channel:         cand.Channel,
+			DownloadSize:    cand.DownloadSize(),
+			Components:      refreshPlanComponents(&t.snapst, cand.Components),
+		}
+
+		if err := checkChangeConflictIgnoringOneChange(st, name, &t.snapst, ConflictOptions{}); err != nil {
+			planSnap.Conflict = err.Error()
+		} else {
+			updates = append(updates, name)
+		}
+
+		if holding := held[name]; len(holding) > 0 {
+			sort.Strings(holding)
+			planSnap.HeldBy = holding
+			heldUntil, err := refreshPlanHeldUntil(st, name, holding)
+			if err != nil {
+				return nil, err
+			}
+			planSnap.HeldUntil = &heldUntil
+		}
+
+		planSnap.Inhibited, err = refreshPlanInhibition(st, &t.snapst, &cand.SnapSetup)
+		if err != nil {
+			return nil, err
+		}
+
+		if planSnap.Conflict == "" && len(planSnap.HeldBy) == 0 {
+			refreshPlan.DownloadSize += planSnap.DownloadSize
+			for _, comp := range planSnap.Components {
+				refreshPlan.DownloadSize += comp.DownloadSize
+			}
+		}
+
+		byName[name] = planSnap
+		refreshPlan.Snaps = append(refreshPlan.Snaps, planSnap)
+	}
+
+	tr := config.NewTransaction(st)
+	gateAutoRefreshHook, err := features.Flag(tr, features.GateAutoRefreshHook)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if gateAutoRefreshHook && len(updates) > 0 {
+		affectedSnaps, err := affectedByRefresh(st, updates)
+		if err != nil {
+			return nil, err
+		}
+		for gatingSnap, affectedInfo := range affectedSnaps {
+			for affectingSnap := range affectedInfo.AffectingSnaps {
+				if planSnap := byName[affectingSnap]; planSnap != nil {
+					planSnap.GatingSnaps = append(planSnap.GatingSnaps, gatingSnap)
+				}
+			}
+		}
+		for _, planSnap := range refreshPlan.Snaps {
+			sort.Strings(planSnap.GatingSnaps)
+		}
+	}
+
+	sort.Slice(refreshPlan.Snaps, func(i, j int) bool {
+		return refreshPlan.Snaps[i].Name < refreshPlan.Snaps[j].Name
+	})
+	return refreshPlan, nil
+}
+
+func refreshPlanComponents(snapst *SnapState, compsups []ComponentSetup) []RefreshPlanComponent {
+	if len(compsups) == 0 {
+		return nil
+	}
+	current := make(map[string]snap.Revision)
+	for _, csi := range snapst.CurrentComponentSideInfos() {
+		current[csi.Component.ComponentName] = csi.Revision
+	}
+	comps := make([]RefreshPlanComponent, 0, len(compsups))
+	for _, compsup := range compsups {
+		name := compsup.CompSideInfo.Component.ComponentName
+		comp := RefreshPlanComponent{
+			Name:            name,
+			CurrentRevision: current[name],
+			Revision:        compsup.CompSideInfo.Revision,
+		}
+		if compsup.DownloadInfo != nil {
+			comp.DownloadSize = compsup.DownloadInfo.Size
+		}
+		comps = append(comps, comp)
+	}
+	return comps
+}
+
+// refreshPlanHeldUntil returns the time until which the refresh of the snap
+// is held by the given holding snaps.
+func refreshPlanHeldUntil(st *state.State, snapName string, holding []string) (time.Time, error) {
+	var heldUntil time.Time
+	for _, holdingSnap := range holding {
+		var until time.Time
+		var err error
+		if holdingSnap == "system" {
+			until, err = SystemHold(st, snapName)
+		} else {
+			until, err = LongestGatingHold(st, snapName)
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if until.After(heldUntil) {
+			heldUntil = until
+		}
+	}
+	return heldUntil, nil
+}
+
+// refreshPlanInhibition checks whether the refresh of the snap would be
+// postponed by its running apps or hooks, like inhibitRefresh but without
+// starting or otherwise recording the inhibition.
+func refreshPlanInhibition(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*RefreshPlanInhibition, error) {
+	if !snapst.IsInstalled() {
+		return nil, nil
+	}
+	if excludeFromRefreshAppAwareness(snapsup.Type) || snapsup.Flags.IgnoreRunning {
+		return nil, nil
+	}
+
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	checkerErr := refreshAppsCheck(info)
+	if checkerErr == nil {
+		return nil, nil
+	}
+	var busyErr *BusySnapError
+	if !errors.As(checkerErr, &busyErr) {
+		return nil, checkerErr
+	}
+
+	now := timeNow()
+	inhibitedSince := now
+	if snapst.RefreshInhibitedTime != nil {
+		inhibitedSince = *snapst.RefreshInhibitedTime
+	}
+	forcedAfter := inhibitedSince.Add(maxInhibitionDuration(st))
+	if !forcedAfter.After(now) {
+		// the inhibition window has ended, the refresh would go ahead
+		return nil, nil
+	}
+
+	return &RefreshPlanInhibition{
+		BusyApps:    busyErr.busyAppNames,
+		BusyHooks:   busyErr.busyHookNames,
+		ForcedAfter: forcedAfter,
+	}, nil
+}
@@ -0,0 +1,274 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *autorefreshGatingSuite) mockRefreshPlanSnaps(c *C) {
+	s.store.refreshedSnaps = []*snap.Info{{
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeApp,
+		Version:       "2",
+		SideInfo: snap.SideInfo{
+			RealName: "snap-a",
+			Revision: snap.R(8),
+		},
+		DownloadInfo: snap.DownloadInfo{Size: 1000},
+	}, {
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeBase,
+		SideInfo: snap.SideInfo{
+			RealName: "base-snap-b",
+			Revision: snap.R(3),
+		},
+		DownloadInfo: snap.DownloadInfo{Size: 200},
+	}, {
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeApp,
+		SideInfo: snap.SideInfo{
+			RealName: "snap-c",
+			Revision: snap.R(5),
+		},
+		DownloadInfo: snap.DownloadInfo{Size: 30},
+	}, {
+		Architectures: []string{"all"},
+		SnapType:      snap.TypeApp,
+		SideInfo: snap.SideInfo{
+			RealName: "snap-f",
+			Revision: snap.R(6),
+		},
+		DownloadInfo: snap.DownloadInfo{Size: 4},
+	}}
+
+	mockInstalledSnap(c, s.state, snapAyaml, useHook)
+	mockInstalledSnap(c, s.state, snapByaml, useHook)
+	mockInstalledSnap(c, s.state, snapCyaml, noHook)
+	mockInstalledSnap(c, s.state, baseSnapByaml, noHook)
+	mockInstalledSnap(c, s.state, snapDyaml, noHook)
+	mockInstalledSnap(c, s.state, snapFyaml, noHook)
+}
+
+func (s *autorefreshGatingSuite) TestRefreshPlanPreview(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.mockRefreshPlanSnaps(c)
+
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.gate-auto-refresh-hook", true)
+	tr.Commit()
+
+	_, err := snapstate.HoldRefresh(st, snapstate.HoldAutoRefresh, "gating-snap", 0, "snap-a", "snap-d")
+	c.Assert(err, IsNil)
+	heldUntil, err := snapstate.LongestGatingHold(st, "snap-a")
+	c.Assert(err, IsNil)
+
+	// snap-c has running apps since a day
+	inhibitedSince := time.Now().Add(-24 * time.Hour)
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "snap-c", &snapst), IsNil)
+	snapst.RefreshInhibitedTime = &inhibitedSince
+	snapstate.Set(st, "snap-c", &snapst)
+	restore = snapstate.MockRefreshAppsCheck(func(info *snap.Info) error {
+		if info.InstanceName() == "snap-c" {
+			return snapstate.NewBusySnapError(info, []int{123}, []string{"app"}, nil)
+		}
+		return nil
+	})
+	defer restore()
+
+	// snap-f is being operated on
+	chg := st.NewChange("refresh-snap", "...")
+	t := st.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "snap-f"}})
+	chg.AddTask(t)
+
+	plan, err := snapstate.RefreshPlanPreview(context.TODO(), st)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 4)
+	c.Check(plan.Snaps[0], DeepEquals, &snapstate.RefreshPlanSnap{
+		Name:            "base-snap-b",
+		CurrentRevision: snap.R(1),
+		Revision:        snap.R(3),
+		DownloadSize:    200,
+		GatingSnaps:     []string{"snap-b"},
+	})
+	c.Check(plan.Snaps[1], DeepEquals, &snapstate.RefreshPlanSnap{
+		Name:            "snap-a",
+		CurrentRevision: snap.R(1),
+		Revision:        snap.R(8),
+		Version:         "2",
+		DownloadSize:    1000,
+		HeldBy:          []string{"gating-snap"},
+		HeldUntil:       &heldUntil,
+		GatingSnaps:     []string{"snap-a"},
+	})
+	inhibited := plan.Snaps[2].Inhibited
+	c.Assert(inhibited, NotNil)
+	c.Check(inhibited.BusyApps, DeepEquals, []string{"app"})
+	c.Check(inhibited.BusyHooks, IsNil)
+	c.Check(inhibited.ForcedAfter.Equal(inhibitedSince.Add(snapstate.MaxInhibitionDuration(st))), Equals, true)
+	plan.Snaps[2].Inhibited = nil
+	c.Check(plan.Snaps[2], DeepEquals, &snapstate.RefreshPlanSnap{
+		Name:            "snap-c",
+		CurrentRevision: snap.R(1),
+		Revision:        snap.R(5),
+		DownloadSize:    30,
+	})
+	c.Check(plan.Snaps[3], DeepEquals, &snapstate.RefreshPlanSnap{
+		Name:            "snap-f",
+		CurrentRevision: snap.R(1),
+		Revision:        snap.R(6),
+		DownloadSize:    4,
+		Conflict:        `snap "snap-f" has "refresh-snap" change in progress`,
+	})
+	// held and conflicting snaps are not downloaded
+	c.Check(plan.DownloadSize, Equals, int64(230))
+
+	// nothing was changed in the state
+	c.Check(st.Changes(), HasLen, 1)
+	var candidates map[string]*snapstate.RefreshCandidate
+	c.Check(st.Get("refresh-candidates", &candidates), testutil.ErrorIs, state.ErrNoState)
+	heldSnaps, err := snapstate.HeldSnaps(st, snapstate.HoldAutoRefresh)
+	c.Assert(err, IsNil)
+	c.Check(heldSnaps, DeepEquals, map[string][]string{
+		"snap-a": {"gating-snap"},
+		"snap-d": {"gating-snap"},
+	})
+	c.Assert(snapstate.Get(st, "snap-c", &snapst), IsNil)
+	c.Check(snapst.RefreshInhibitedTime.Equal(inhibitedSince), Equals, true)
+}
+
+func (s *autorefreshGatingSuite) TestRefreshPlanPreviewNoGating(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.mockRefreshPlanSnaps(c)
+
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	_, err := snapstate.HoldRefresh(st, snapstate.HoldAutoRefresh, "system", 0, "snap-c")
+	c.Assert(err, IsNil)
+	heldUntil, err := snapstate.SystemHold(st, "snap-c")
+	c.Assert(err, IsNil)
+
+	// running apps start no inhibition
+	restore = snapstate.MockRefreshAppsCheck(func(info *snap.Info) error {
+		if info.InstanceName() == "snap-a" {
+			return snapstate.NewBusySnapError(info, []int{123}, nil, []string{"configure"})
+		}
+		return nil
+	})
+	defer restore()
+
+	before := time.Now()
+	plan, err := snapstate.RefreshPlanPreview(context.TODO(), st)
+	c.Assert(err, IsNil)
+	c.Assert(plan.Snaps, HasLen, 4)
+	for _, planSnap := range plan.Snaps {
+		// no gate-auto-refresh hooks without the feature
+		c.Check(planSnap.GatingSnaps, IsNil)
+		c.Check(planSnap.Conflict, Equals, "")
+	}
+	c.Check(plan.Snaps[1].Name, Equals, "snap-a")
+	c.Assert(plan.Snaps[1].Inhibited, NotNil)
+	c.Check(plan.Snaps[1].Inhibited.BusyHooks, DeepEquals, []string{"configure"})
+	c.Check(plan.Snaps[1].Inhibited.ForcedAfter.After(before.Add(snapstate.MaxInhibitionDuration(st))), Equals, true)
+	c.Check(plan.Snaps[2].Name, Equals, "snap-c")
+	c.Check(plan.Snaps[2].HeldBy, DeepEquals, []string{"system"})
+	c.Check(plan.Snaps[2].HeldUntil, DeepEquals, &heldUntil)
+	c.Check(plan.DownloadSize, Equals, int64(1204))
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "snap-a", &snapst), IsNil)
+	c.Check(snapst.RefreshInhibitedTime, IsNil)
+}
+
+func (s *autorefreshGatingSuite) TestRefreshPlanPreviewRefreshCheckError(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.mockRefreshPlanSnaps(c)
+
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	restore = snapstate.MockRefreshAppsCheck(func(info *snap.Info) error {
+		return errors.New("boom")
+	})
+	defer restore()
+
+	_, err := snapstate.RefreshPlanPreview(context.TODO(), st)
+	c.Check(err, ErrorMatches, "boom")
+}
+
+func (s *autorefreshGatingSuite) TestRefreshPlanPreviewBlackout(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.mockRefreshPlanSnaps(c)
+
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	// 2026-10-27 is in the last week of October
+	now := time.Date(2026, 10, 27, 10, 0, 0, 0, time.Local)
+	restore = snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.blackout", "last-week,11-01")
+	tr.Commit()
+
+	plan, err := snapstate.RefreshPlanPreview(context.TODO(), st)
+	c.Assert(err, IsNil)
+	c.Check(plan.Snaps, HasLen, 4)
+	c.Check(plan.Blackout, Equals, "last-week,11-01")
+	c.Assert(plan.BlackoutUntil, NotNil)
+	c.Check(plan.BlackoutUntil.Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, time.Local)), Equals, true)
+
+	// outside of the blackout
+	now = time.Date(2026, 11, 2, 10, 0, 0, 0, time.Local)
+	plan, err = snapstate.RefreshPlanPreview(context.TODO(), st)
+	c.Assert(err, IsNil)
+	c.Check(plan.Blackout, Equals, "last-week,11-01")
+	c.Check(plan.BlackoutUntil, IsNil)
+}
@@ -1084,6 +1084,13 @@ func (m *SnapManager) EffectiveRefreshHold() (time.Time, error) {
 	return m.autoRefresh.EffectiveRefreshHold()
 }
 
+// RefreshBlackout returns the current refresh blackouts as a string suitable
+// for display to a user.
+// The caller should be holding the state lock.
+func (m *SnapManager) RefreshBlackout() (string, error) {
+	return m.autoRefresh.RefreshBlackout()
+}
+
 // LastRefresh returns the time the last snap update.
 // The caller should be holding the state lock.
 func (m *SnapManager) LastRefresh() (time.Time, error) {
@@ -21,6 +21,8 @@ import (
 	"sort"
 	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/osutil"
 )
 
 const (
@@ -307,11 +309,31 @@ const (
 	// expired. The key for interfaces-requests-rule-update notices is the
 	// rule ID.
 	InterfacesRequestsRuleUpdateNotice NoticeType = "interfaces-requests-rule-update"
+
+	// Recorded whenever the health status reported by a snap changes. The
+	// key for snap-health notices is the snap instance name.
+	SnapHealthNotice NoticeType = "snap-health"
+
+	// Recorded whenever the usage of a resource by a quota group stays near
+	// its limit for longer than the configured duration. The key for
+	// quota-near-limit notices is the quota group name.
+	QuotaNearLimitNotice NoticeType = "quota-near-limit"
+
+	// Recorded whenever a committed confdb transaction modifies data visible
+	// through a view. The key for confdb-change notices is the view ID, in the
+	// form "<account>/<confdb-schema>/<view>".
+	ConfdbChangeNotice NoticeType = "confdb-change"
+
+	// Recorded whenever a databag replicated across the devices of a cluster
+	// cannot be sent to or received from another device, or when another
+	// device lags behind for too long. The key for confdb-replication notices
+	// is the confdb-schema, in the form "<account>/<confdb-schema>".
+	ConfdbReplicationNotice NoticeType = "confdb-replication"
 )
 
 func (t NoticeType) Valid() bool {
 	switch t {
-	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice:
+	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice, SnapHealthNotice, QuotaNearLimitNotice, ConfdbChangeNotice, ConfdbReplicationNotice:
 		return true
 	}
 	return false
@@ -453,6 +475,11 @@ type NoticeFilter struct {
 	// Keys, if not empty, includes only notices whose key is one of these.
 	Keys []string
 
+	// TypeKeys, if not nil, includes notices of the types it holds only if
+	// their key is one of those listed for their type. Notices of types not
+	// held are not affected.
+	TypeKeys map[NoticeType][]string
+
 	// After, if set, includes only notices that were last repeated after this time.
 	After time.Time
 
@@ -461,6 +488,12 @@ type NoticeFilter struct {
 	BeforeOrAt time.Time
 }
 
+// Matches reports whether the notice n matches this filter. A nil filter
+// matches every notice.
+func (f *NoticeFilter) Matches(n *Notice) bool {
+	return f.matches(n)
+}
+
 // matches reports whether the notice n matches this filter
 func (f *NoticeFilter) matches(n *Notice) bool {
 	if f == nil {
@@ -476,6 +509,9 @@ func (f *NoticeFilter) matches(n *Notice) bool {
 	if len(f.Keys) > 0 && !sliceContains(f.Keys, n.key) {
 		return false
 	}
+	if keys, ok := f.TypeKeys[n.noticeType]; ok && !sliceContains(keys, n.key) {
+		return false
+	}
 	if !f.After.IsZero() && !n.lastRepeated.After(f.After) {
 		return false
 	}
@@ -614,7 +650,7 @@ func (s *State) filterNotices(filter *NoticeFilter) []*Notice {
 }
 
 // unflattenNotices takes a flat list of notices and replaces the notices map
-// with them, ignoring expired notices in the process.
+// with them, setting aside expired notices in the process.
 //
 // Call with the state lock held. Acquires the notices lock for writing.
 func (s *State) unflattenNotices(flat []*Notice) {
@@ -622,8 +658,12 @@ func (s *State) unflattenNotices(flat []*Notice) {
 	defer s.noticesMu.Unlock()
 	now := time.Now()
 	s.notices = make(map[noticeKey]*Notice)
+	s.expiredNotices = nil
 	for _, n := range flat {
 		if n.Expired(now) {
+			// Keep hold of the expired notice so that it can be handed
+			// to the expired notices handler, if any, on the next prune.
+			s.expiredNotices = append(s.expiredNotices, n)
 			continue
 		}
 		userID, hasUserID := n.UserID()
@@ -634,6 +674,19 @@ func (s *State) unflattenNotices(flat []*Notice) {
 	}
 }
 
+// SetNoticesExpiredHandler sets a function which is called with the notices
+// which are removed from state when they expire, ordered by the last-repeated
+// time. This allows expired notices to be retained elsewhere, for instance by
+// an archiving notice backend. A nil function unsets any existing handler.
+//
+// The handler is called during Prune, with the state lock held, so it must
+// not try to acquire the state lock itself.
+func (s *State) SetNoticesExpiredHandler(f func(expired []*Notice)) {
+	s.noticesMu.Lock()
+	defer s.noticesMu.Unlock()
+	s.noticesExpiredHandler = f
+}
+
 // WaitNotices waits for notices that match the filter to exist or occur,
 // returning the list of matching notices ordered by the last-repeated time.
 //
@@ -671,7 +724,7 @@ func (s *State) WaitNotices(ctx context.Context, filter *NoticeFilter) ([]*Notic
 	// can check their ctx.Err() and return if they're cancelled.
 	//
 	// TODO:GOVERSION: replace this with context.AfterFunc once we're on Go 1.21.
-	stop := contextAfterFunc(ctx, func() {
+	stop := osutil.ContextAfterFunc(ctx, func() {
 		// We need to acquire a lock mutually exclusive with the cond lock here
 		// to be sure that the Broadcast below won't occur before the call to
 		// Wait, which would result in a missed signal (and deadlock). Since
@@ -715,19 +768,3 @@ func (s *State) WaitNotices(ctx context.Context, filter *NoticeFilter) ([]*Notic
 		}
 	}
 }
-
-// TODO:GOVERSION: Remove this and just use context.AfterFunc once we're on Go 1.21.
-func contextAfterFunc(ctx context.Context, f func()) func() {
-	stopCh := make(chan struct{})
-	go func() {
-		select {
-		case <-ctx.Done():
-			f()
-		case <-stopCh:
-		}
-	}()
-	stop := func() {
-		close(stopCh)
-	}
-	return stop
-}
@@ -535,6 +535,38 @@ func (s *noticesSuite) TestNoticesFilterKey(c *C) {
 	c.Check(n["key"], Equals, "foo.com/baz")
 }
 
+func (s *noticesSuite) TestNoticesFilterTypeKeys(c *C) {
+	st := state.New(nil)
+
+	st.Lock()
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/db/foo", nil)
+	time.Sleep(time.Microsecond)
+	addNotice(c, st, nil, state.ConfdbChangeNotice, "acc/db/bar", nil)
+	time.Sleep(time.Microsecond)
+	addNotice(c, st, nil, state.WarningNotice, "acc/db/bar", nil)
+	st.Unlock()
+
+	// Only the listed keys of the restricted type
+	notices := st.Notices(&state.NoticeFilter{TypeKeys: map[state.NoticeType][]string{
+		state.ConfdbChangeNotice: {"acc/db/bar"},
+	}})
+	c.Assert(notices, HasLen, 2)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["type"], Equals, "confdb-change")
+	c.Check(n["key"], Equals, "acc/db/bar")
+	n = noticeToMap(c, notices[1])
+	c.Check(n["type"], Equals, "warning")
+	c.Check(n["key"], Equals, "acc/db/bar")
+
+	// No keys for a restricted type excludes all its notices
+	notices = st.Notices(&state.NoticeFilter{TypeKeys: map[state.NoticeType][]string{
+		state.ConfdbChangeNotice: nil,
+	}})
+	c.Assert(notices, HasLen, 1)
+	n = noticeToMap(c, notices[0])
+	c.Check(n["type"], Equals, "warning")
+}
+
 func (s *noticesSuite) TestNoticesFilterAfter(c *C) {
 	st := state.New(nil)
 
@@ -729,6 +761,87 @@ func (s *noticesSuite) TestDeleteExpired(c *C) {
 	c.Assert(n["key"], Equals, "foo.com/z")
 }
 
+func (s *noticesSuite) TestNoticesExpiredHandler(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	var expired []*state.Notice
+	st.SetNoticesExpiredHandler(func(notices []*state.Notice) {
+		expired = append(expired, notices...)
+	})
+
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	addNotice(c, st, nil, state.WarningNotice, "foo.com/x", &state.AddNoticeOptions{
+		Time: old.Add(time.Second),
+	})
+	addNotice(c, st, nil, state.WarningNotice, "foo.com/w", &state.AddNoticeOptions{
+		Time: old,
+	})
+	addNotice(c, st, nil, state.WarningNotice, "foo.com/y", nil)
+
+	st.Prune(time.Now(), 0, 0, 0)
+	c.Assert(st.NumNotices(), Equals, 1)
+
+	// Expired notices are handed over sorted by last-repeated time
+	c.Assert(expired, HasLen, 2)
+	c.Check(expired[0].Key(), Equals, "foo.com/w")
+	c.Check(expired[1].Key(), Equals, "foo.com/x")
+
+	// Nothing is handed over a second time
+	expired = nil
+	st.Prune(time.Now(), 0, 0, 0)
+	c.Check(expired, HasLen, 0)
+
+	// Unsetting the handler is possible
+	st.SetNoticesExpiredHandler(nil)
+	addNotice(c, st, nil, state.WarningNotice, "foo.com/z", &state.AddNoticeOptions{
+		Time: old,
+	})
+	st.Prune(time.Now(), 0, 0, 0)
+	c.Check(expired, HasLen, 0)
+}
+
+func (s *noticesSuite) TestNoticesExpiredHandlerAfterReadState(c *C) {
+	// Notices may expire while snapd is not running, in which case they are
+	// already expired when state is read.
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	expiredNotice := state.NewNotice("1", nil, state.WarningNotice, "foo.com/old", old, nil, 0, 7*24*time.Hour)
+	liveNotice := state.NewNotice("2", nil, state.WarningNotice, "foo.com/new", time.Now(), nil, 0, 7*24*time.Hour)
+	marshalled, err := json.Marshal(map[string]any{
+		"notices":        []*state.Notice{expiredNotice, liveNotice},
+		"last-notice-id": 2,
+	})
+	c.Assert(err, IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewBuffer(marshalled))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Assert(st2.NumNotices(), Equals, 1)
+
+	var expired []*state.Notice
+	st2.SetNoticesExpiredHandler(func(notices []*state.Notice) {
+		expired = append(expired, notices...)
+	})
+	st2.Prune(time.Now(), 0, 0, 0)
+	c.Assert(expired, HasLen, 1)
+	c.Check(expired[0].Key(), Equals, "foo.com/old")
+}
+
+func (s *noticesSuite) TestNoticeFilterMatches(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	addNotice(c, st, nil, state.WarningNotice, "foo.com/x", nil)
+	n := st.Notices(nil)[0]
+
+	var nilFilter *state.NoticeFilter
+	c.Check(nilFilter.Matches(n), Equals, true)
+	c.Check((&state.NoticeFilter{Keys: []string{"foo.com/x"}}).Matches(n), Equals, true)
+	c.Check((&state.NoticeFilter{Types: []state.NoticeType{state.ChangeUpdateNotice}}).Matches(n), Equals, false)
+}
+
 func (s *noticesSuite) TestWaitNoticesExisting(c *C) {
 	st := state.New(nil)
 
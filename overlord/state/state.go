@@ -119,6 +119,13 @@ type State struct {
 	noticesMu  sync.RWMutex
 	notices    map[noticeKey]*Notice
 	noticeCond *sync.Cond
+	// expiredNotices holds notices which were found to be expired when
+	// loading state, until they can be handed to the expired notices
+	// handler during the next prune. It is protected by noticesMu.
+	expiredNotices []*Notice
+	// noticesExpiredHandler, if set, is called with notices which are
+	// removed from state because they expired. It is protected by noticesMu.
+	noticesExpiredHandler func(expired []*Notice)
 
 	modified bool
 
@@ -581,12 +588,23 @@ func (s *State) pruneWarnings(now time.Time) {
 
 func (s *State) pruneNotices(now time.Time) {
 	s.noticesMu.Lock()
-	defer s.noticesMu.Unlock()
+	expired := s.expiredNotices
+	s.expiredNotices = nil
 	for k, n := range s.notices {
 		if n.Expired(now) {
 			delete(s.notices, k)
+			expired = append(expired, n)
 		}
 	}
+	handler := s.noticesExpiredHandler
+	s.noticesMu.Unlock()
+
+	// Call the handler without holding noticesMu, so that it is free to
+	// query notices from state if it needs to.
+	if handler != nil && len(expired) > 0 {
+		SortNotices(expired)
+		handler(expired)
+	}
 }
 
 // GetMaybeTimings implements timings.GetSaver
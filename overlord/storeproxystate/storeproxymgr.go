@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package storeproxystate implements the manager responsible for serving the
+// downloads cache of snapd to other devices, through a caching proxy of the
+// store.
+package storeproxystate
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storeproxy"
+)
+
+// proxyStore is the subset of the store which is needed to proxy it.
+type proxyStore interface {
+	storeproxy.BlobCache
+	APIBaseURL() *url.URL
+	AssertionsBaseURL() *url.URL
+}
+
+var _ proxyStore = (*store.Store)(nil)
+
+var netListen = net.Listen
+
+// StoreProxyManager serves a caching proxy of the store to other devices when
+// the core.store.cache-server.address option is set. Clients can be restricted
+// with the core.store.cache-server.allowed-clients option.
+type StoreProxyManager struct {
+	state *state.State
+
+	mu             sync.Mutex
+	address        string
+	allowedClients string
+	store          proxyStore
+	server         *http.Server
+	done           chan struct{}
+	// tokenKey signs the download URLs handed to clients, it is kept
+	// across restarts of the server so that those remain valid
+	tokenKey []byte
+}
+
+// Manager returns a new StoreProxyManager.
+func Manager(st *state.State) *StoreProxyManager {
+	return &StoreProxyManager{
+		state: st,
+	}
+}
+
+// Ensure starts, restarts or stops serving the caching proxy of the store
+// according to the configured address and allowed clients.
+func (m *StoreProxyManager) Ensure() error {
+	m.state.Lock()
+	tr := config.NewTransaction(m.state)
+	var address, allowedClients string
+	if err := tr.GetMaybe("core", "store.cache-server.address", &address); err != nil {
+		m.state.Unlock()
+		return err
+	}
+	if err := tr.GetMaybe("core", "store.cache-server.allowed-clients", &allowedClients); err != nil {
+		m.state.Unlock()
+		return err
+	}
+	var sto proxyStore
+	if address != "" {
+		var ok bool
+		// the store can be replaced, for instance when remodeling
+		sto, ok = snapstate.Store(m.state, nil).(proxyStore)
+		if !ok {
+			logger.Debugf("store does not support being proxied")
+			address = ""
+		}
+	}
+	m.state.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if address == m.address && allowedClients == m.allowedClients && sto == m.store {
+		return nil
+	}
+	m.stopLocked()
+	if address == "" {
+		return nil
+	}
+
+	allowed, err := storeproxy.ParseAllowedClients(allowedClients)
+	if err != nil {
+		return err
+	}
+	if m.tokenKey == nil {
+		m.tokenKey = storeproxy.NewTokenKey()
+	}
+	l, err := netListen("tcp", address)
+	if err != nil {
+		return err
+	}
+	client := httputil.NewHTTPClient(&httputil.ClientOptions{
+		Proxy: proxyconf.New(m.state).Conf,
+		ExtraSSLCerts: &httputil.ExtraSSLCertsFromDir{
+			Dir: dirs.SnapdStoreSSLCertsDir,
+		},
+	})
+	proxy := storeproxy.New(storeproxy.Config{
+		StoreURL:      sto.APIBaseURL(),
+		AssertionsURL: sto.AssertionsBaseURL(),
+		Blobs:         sto,
+		Assertions:    &assertionSource{st: m.state},
+		// on the same filesystem as the downloads cache
+		TempDir:        dirs.SnapBlobDir,
+		Transport:      client.Transport,
+		AllowedClients: allowed,
+		TokenKey:       m.tokenKey,
+	})
+	server := &http.Server{
+		Handler:           proxy,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Noticef("cannot serve store cache: %v", err)
+		}
+	}()
+
+	m.address = address
+	m.allowedClients = allowedClients
+	m.store = sto
+	m.server = server
+	m.done = done
+	logger.Noticef("Serving store cache to other devices on %s", l.Addr())
+	return nil
+}
+
+func (m *StoreProxyManager) stopLocked() {
+	if m.server != nil {
+		if err := m.server.Close(); err != nil {
+			logger.Noticef("cannot stop serving store cache: %v", err)
+		}
+		<-m.done
+	}
+	m.address = ""
+	m.allowedClients = ""
+	m.store = nil
+	m.server = nil
+	m.done = nil
+}
+
+// Stop implements overlord.StateStopper. It stops serving the caching proxy of
+// the store.
+func (m *StoreProxyManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopLocked()
+}
+
+// assertionSource provides assertions from the assertion database.
+type assertionSource struct {
+	st *state.State
+}
+
+func (s *assertionSource) FindAssertion(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	return assertstate.DB(s.st).Find(assertType, headers)
+}
@@ -0,0 +1,321 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package storeproxystate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/storeproxystate"
+	"github.com/snapcore/snapd/overlord/swfeats/swfeatstest"
+	"github.com/snapcore/snapd/store/storetest"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+type proxyStore struct {
+	storetest.Store
+
+	upstream string
+	blobs    map[string]string
+}
+
+func (s *proxyStore) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	content, ok := s.blobs[sha3_384]
+	if !ok {
+		return nil, 0, fs.ErrNotExist
+	}
+	return nopSeekCloser{strings.NewReader(content)}, int64(len(content)), nil
+}
+
+func (s *proxyStore) CacheBlob(sha3_384, path string) error {
+	return nil
+}
+
+func (s *proxyStore) APIBaseURL() *url.URL {
+	u, _ := url.Parse(s.upstream)
+	return u
+}
+
+func (s *proxyStore) AssertionsBaseURL() *url.URL {
+	return s.APIBaseURL()
+}
+
+type storeProxyMgrSuite struct {
+	st         *state.State
+	sto        *proxyStore
+	storeStack *assertstest.StoreStack
+	upstream   *httptest.Server
+
+	listeners []net.Listener
+	addresses []string
+}
+
+var _ = Suite(&storeProxyMgrSuite{})
+
+var digest = strings.Repeat("a", 96)
+
+func (s *storeProxyMgrSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.st = state.New(nil)
+	s.upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the download details of snap foo
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"download": {"url": "http://%s/download/foo", "sha3-384": %q}}`, r.Host, digest)
+	}))
+	s.sto = &proxyStore{upstream: s.upstream.URL, blobs: map[string]string{digest: "blob content"}}
+	s.listeners = nil
+	s.addresses = nil
+
+	s.storeStack = assertstest.NewStoreStack("canonical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeStack.Trusted,
+	})
+	c.Assert(err, IsNil)
+
+	s.st.Lock()
+	snapstate.ReplaceStore(s.st, s.sto)
+	assertstate.ReplaceDB(s.st, db)
+	s.st.Unlock()
+}
+
+func (s *storeProxyMgrSuite) TearDownTest(c *C) {
+	s.upstream.Close()
+	dirs.SetRootDir("")
+}
+
+func (s *storeProxyMgrSuite) mockListen(c *C) func() {
+	return storeproxystate.MockNetListen(func(network, address string) (net.Listener, error) {
+		c.Check(network, Equals, "tcp")
+		s.addresses = append(s.addresses, address)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		s.listeners = append(s.listeners, l)
+		return l, nil
+	})
+}
+
+func (s *storeProxyMgrSuite) setAddress(c *C, address string) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "store.cache-server.address", address), IsNil)
+	tr.Commit()
+}
+
+func (s *storeProxyMgrSuite) get(c *C, l net.Listener, path string) (int, string, error) {
+	resp, err := http.Get("http://" + l.Addr().String() + path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	return resp.StatusCode, string(body), nil
+}
+
+func (s *storeProxyMgrSuite) setAllowedClients(c *C, allowed string) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	c.Assert(tr.Set("core", "store.cache-server.allowed-clients", allowed), IsNil)
+	tr.Commit()
+}
+
+// blobPath returns the path at which the proxy serving on the given listener
+// serves the blob of snap foo to this client.
+func (s *storeProxyMgrSuite) blobPath(c *C, l net.Listener) string {
+	status, body, err := s.get(c, l, "/v2/snaps/info/foo")
+	c.Assert(err, IsNil)
+	c.Assert(status, Equals, 200)
+	var info struct {
+		Download struct {
+			URL string `json:"url"`
+		} `json:"download"`
+	}
+	c.Assert(json.Unmarshal([]byte(body), &info), IsNil)
+	return strings.TrimPrefix(info.Download.URL, "http://"+l.Addr().String())
+}
+
+func (s *storeProxyMgrSuite) TestEnsureLoopHasLogging(c *C) {
+	swfeatstest.CheckEnsureLoopLogging("storeproxymgr.go", c, false)
+}
+
+func (s *storeProxyMgrSuite) TestEnsureDisabledByDefault(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	mgr := storeproxystate.Manager(s.st)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.addresses, HasLen, 0)
+}
+
+func (s *storeProxyMgrSuite) TestEnsureServesCache(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	s.setAddress(c, ":8765")
+	mgr := storeproxystate.Manager(s.st)
+	defer mgr.Stop()
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(s.addresses, DeepEquals, []string{":8765"})
+
+	path := s.blobPath(c, s.listeners[0])
+	c.Check(path, Matches, "/blobs/"+digest+`\?token=.*`)
+	status, body, err := s.get(c, s.listeners[0], path)
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+	c.Check(body, Equals, "blob content")
+
+	// blobs are only served with the URL handed out by the proxy
+	status, _, err = s.get(c, s.listeners[0], "/blobs/"+digest)
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 403)
+
+	// nothing changes while the option is unchanged
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.addresses, HasLen, 1)
+
+	// changing the address restarts the server
+	s.setAddress(c, ":8766")
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(s.addresses, DeepEquals, []string{":8765", ":8766"})
+	_, _, err = s.get(c, s.listeners[0], path)
+	c.Check(err, NotNil)
+	// the URLs handed out before remain valid
+	status, _, err = s.get(c, s.listeners[1], path)
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+
+	// and unsetting it stops it
+	s.setAddress(c, "")
+	c.Assert(mgr.Ensure(), IsNil)
+	_, _, err = s.get(c, s.listeners[1], path)
+	c.Check(err, NotNil)
+}
+
+func (s *storeProxyMgrSuite) TestEnsureAllowedClients(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	s.setAddress(c, ":8765")
+	s.setAllowedClients(c, "192.0.2.0/24")
+	mgr := storeproxystate.Manager(s.st)
+	defer mgr.Stop()
+	c.Assert(mgr.Ensure(), IsNil)
+
+	status, body, err := s.get(c, s.listeners[0], "/v2/snaps/info/foo")
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 403)
+	c.Check(body, Equals, "client not allowed\n")
+
+	// changing the allowed clients restarts the server
+	s.setAllowedClients(c, "192.0.2.0/24,127.0.0.1")
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(s.addresses, HasLen, 2)
+	status, _, err = s.get(c, s.listeners[1], "/v2/snaps/info/foo")
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+}
+
+func (s *storeProxyMgrSuite) TestEnsureStoreReplaced(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	s.setAddress(c, ":8765")
+	mgr := storeproxystate.Manager(s.st)
+	defer mgr.Stop()
+	c.Assert(mgr.Ensure(), IsNil)
+
+	newStore := &proxyStore{upstream: s.upstream.URL, blobs: map[string]string{digest: "new content"}}
+	s.st.Lock()
+	snapstate.ReplaceStore(s.st, newStore)
+	s.st.Unlock()
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(s.addresses, HasLen, 2)
+	status, body, err := s.get(c, s.listeners[1], s.blobPath(c, s.listeners[1]))
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+	c.Check(body, Equals, "new content")
+}
+
+func (s *storeProxyMgrSuite) TestServesAssertionsFromDB(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	accKey := s.storeStack.StoreAccountKey("")
+	s.st.Lock()
+	c.Assert(assertstate.Add(s.st, s.storeStack.StoreAccountKey("")), IsNil)
+	s.st.Unlock()
+
+	s.setAddress(c, ":8765")
+	mgr := storeproxystate.Manager(s.st)
+	defer mgr.Stop()
+	c.Assert(mgr.Ensure(), IsNil)
+
+	status, body, err := s.get(c, s.listeners[0], "/v2/assertions/account-key/"+accKey.PublicKeyID())
+	c.Assert(err, IsNil)
+	c.Check(status, Equals, 200)
+	c.Check(bytes.Equal([]byte(body), asserts.Encode(accKey)), Equals, true)
+}
+
+func (s *storeProxyMgrSuite) TestStop(c *C) {
+	restore := s.mockListen(c)
+	defer restore()
+
+	s.setAddress(c, ":8765")
+	mgr := storeproxystate.Manager(s.st)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	mgr.Stop()
+	_, _, err := s.get(c, s.listeners[0], "/v2/snaps/info/foo")
+	c.Check(err, NotNil)
+
+	// stopping again is harmless
+	mgr.Stop()
+}
@@ -268,3 +268,49 @@ func (u SnapdUser) String() string {
 
 	return id + ":" + email + ":" + name
 }
+
+// PromptingRequest describes a request by a snap for access to a resource
+// which is mediated by AppArmor prompting, for PROMPT events.
+type PromptingRequest struct {
+	// UserID is the UID of the user on whose behalf access was requested.
+	UserID uint32 `json:"user_id"`
+	// Snap is the instance name of the snap which requested access.
+	Snap string `json:"snap"`
+	// Interface is the snap interface whose rules mediate the request.
+	Interface string `json:"interface"`
+	// Path is the path of the resource to which access was requested.
+	Path string `json:"path"`
+	// Permissions are the interface-specific permissions which were
+	// requested.
+	Permissions []string `json:"permissions"`
+}
+
+// String returns a colon-separated representation in the form
+// "<UserID>:<Snap>:<Interface>:<Path>". Unset snap, interface, and path use
+// [unknown] as a placeholder.
+func (r PromptingRequest) String() string {
+	snap := unknown
+	if r.Snap != "" {
+		snap = r.Snap
+	}
+
+	iface := unknown
+	if r.Interface != "" {
+		iface = r.Interface
+	}
+
+	path := unknown
+	if r.Path != "" {
+		path = r.Path
+	}
+
+	return fmt.Sprintf("%d", r.UserID) + ":" + snap + ":" + iface + ":" + path
+}
+
+// PromptingOutcome identifies how a prompting request was decided.
+type PromptingOutcome string
+
+const (
+	PromptingOutcomeAllow PromptingOutcome = "allow"
+	PromptingOutcomeDeny  PromptingOutcome = "deny"
+)
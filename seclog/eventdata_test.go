@@ -98,3 +98,14 @@ func (s *SecLogSuite) TestGrantReasonWithInterface(c *C) {
 	c.Check(seclog.GrantRootAuth.WithInterface("", true), Equals, seclog.GrantRootAuth)
 	c.Check(seclog.GrantRootAuth.WithInterface("", false), Equals, seclog.GrantRootAuth)
 }
+
+func (s *SecLogSuite) TestPromptingRequestString(c *C) {
+	c.Check(seclog.PromptingRequest{
+		UserID: 1000, Snap: "firefox", Interface: "home", Path: "/home/test/foo",
+	}.String(), Equals, "1000:firefox:home:/home/test/foo")
+
+	// Zero UID is root, so it is never unknown.
+	c.Check(seclog.PromptingRequest{}.String(), Equals, "0:<unknown>:<unknown>:<unknown>")
+
+	c.Check(seclog.PromptingRequest{UserID: 1000, Snap: "firefox"}.String(), Equals, "1000:firefox:<unknown>:<unknown>")
+}
@@ -21,6 +21,7 @@ package seclog
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/snapcore/snapd/logger"
@@ -260,3 +261,76 @@ func LogUnauthorizedAccess(user SnapdUser, peer Peer, endpoint Endpoint, denialR
 		Attr{Key: "reason_denied", Value: denialReason},
 	)
 }
+
+// LogPromptCreated logs the creation of a prompt for a request which was not
+// decided by existing prompting rules, using the global security logger.
+func LogPromptCreated(request PromptingRequest, promptID string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	globalLogger.LogEvent(
+		Event{Category: "PROMPT", Name: "prompt_created", Level: LevelInfo},
+		fmt.Sprintf("Created prompt %s for request %s", promptID, request.String()),
+		Attr{Key: "request", Value: request},
+		Attr{Key: "prompt_id", Value: promptID},
+	)
+}
+
+// LogPromptReplied logs the resolution of a prompt by an explicit reply from
+// the user, using the global security logger.
+func LogPromptReplied(request PromptingRequest, promptID string, outcome PromptingOutcome) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	globalLogger.LogEvent(
+		Event{Category: "PROMPT", Name: "prompt_replied", Level: LevelInfo},
+		fmt.Sprintf("Prompt %s for request %s replied with outcome %s", promptID, request.String(), outcome),
+		Attr{Key: "request", Value: request},
+		Attr{Key: "prompt_id", Value: promptID},
+		Attr{Key: "outcome", Value: outcome},
+	)
+}
+
+// LogPromptTimedOut logs the expiration of a prompt which received no reply
+// before it timed out, using the global security logger. The request
+// associated with an expired prompt is denied.
+func LogPromptTimedOut(request PromptingRequest, promptID string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	globalLogger.LogEvent(
+		Event{Category: "PROMPT", Name: "prompt_timeout", Level: LevelWarn},
+		fmt.Sprintf("Prompt %s for request %s timed out and was denied", promptID, request.String()),
+		Attr{Key: "request", Value: request},
+		Attr{Key: "prompt_id", Value: promptID},
+		Attr{Key: "outcome", Value: PromptingOutcomeDeny},
+	)
+}
+
+// LogPromptingRuleDecision logs a request which was decided automatically by
+// prompting rules, using the global security logger.
+//
+// promptID is the ID of the prompt which was resolved by a newly-added rule,
+// or empty if the request was decided by existing rules before any prompt was
+// created. ruleIDs are the IDs of the rules which determined the outcome.
+func LogPromptingRuleDecision(request PromptingRequest, promptID string, outcome PromptingOutcome, ruleIDs []string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	subject := "Request " + request.String()
+	if promptID != "" {
+		subject = fmt.Sprintf("Prompt %s for request %s", promptID, request.String())
+	}
+	rules := none
+	if len(ruleIDs) > 0 {
+		rules = strings.Join(ruleIDs, ",")
+	}
+	globalLogger.LogEvent(
+		Event{Category: "PROMPT", Name: "prompt_rule_decision", Level: LevelInfo},
+		fmt.Sprintf("%s decided with outcome %s by rules %s", subject, outcome, rules),
+		Attr{Key: "request", Value: request},
+		Attr{Key: "prompt_id", Value: promptID},
+		Attr{Key: "outcome", Value: outcome},
+		Attr{Key: "rule_ids", Value: ruleIDs},
+	)
+}
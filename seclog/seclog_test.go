@@ -298,3 +298,49 @@ func (s *SecLogSuite) TestLogUnauthorizedAccess(c *C) {
 	c.Check(s.buf.String(), testutil.Contains, "[reason_denied=\"user-auth-denied\"]")
 	c.Check(s.buf.String(), testutil.Contains, "[user=")
 }
+
+func (s *SecLogSuite) TestLogPromptCreated(c *C) {
+	request := seclog.PromptingRequest{
+		UserID:      1000,
+		Snap:        "firefox",
+		Interface:   "home",
+		Path:        "/home/test/foo",
+		Permissions: []string{"read"},
+	}
+	seclog.LogPromptCreated(request, "0000000000000001")
+
+	c.Check(s.buf.String(), testutil.Contains, "prompt_created Created prompt 0000000000000001 for request 1000:firefox:home:/home/test/foo")
+	c.Check(s.buf.String(), testutil.Contains, "[request=")
+	c.Check(s.buf.String(), testutil.Contains, "[prompt_id=\"0000000000000001\"]")
+}
+
+func (s *SecLogSuite) TestLogPromptReplied(c *C) {
+	request := seclog.PromptingRequest{UserID: 1000, Snap: "firefox", Interface: "home", Path: "/home/test/foo"}
+	seclog.LogPromptReplied(request, "0000000000000002", seclog.PromptingOutcomeAllow)
+
+	c.Check(s.buf.String(), testutil.Contains, "prompt_replied Prompt 0000000000000002 for request 1000:firefox:home:/home/test/foo replied with outcome allow")
+	c.Check(s.buf.String(), testutil.Contains, "[prompt_id=\"0000000000000002\"]")
+	c.Check(s.buf.String(), testutil.Contains, "[outcome=\"allow\"]")
+}
+
+func (s *SecLogSuite) TestLogPromptTimedOut(c *C) {
+	request := seclog.PromptingRequest{UserID: 1000, Snap: "firefox", Interface: "home", Path: "/home/test/foo"}
+	seclog.LogPromptTimedOut(request, "0000000000000003")
+
+	c.Check(s.buf.String(), testutil.Contains, "prompt_timeout Prompt 0000000000000003 for request 1000:firefox:home:/home/test/foo timed out and was denied")
+	c.Check(s.buf.String(), testutil.Contains, "[outcome=\"deny\"]")
+}
+
+func (s *SecLogSuite) TestLogPromptingRuleDecision(c *C) {
+	request := seclog.PromptingRequest{UserID: 1000, Snap: "firefox", Interface: "home", Path: "/home/test/foo"}
+	seclog.LogPromptingRuleDecision(request, "", seclog.PromptingOutcomeAllow, []string{"000000000000000A", "000000000000000B"})
+
+	c.Check(s.buf.String(), testutil.Contains, "prompt_rule_decision Request 1000:firefox:home:/home/test/foo decided with outcome allow by rules 000000000000000A,000000000000000B")
+	c.Check(s.buf.String(), testutil.Contains, "[rule_ids=[]string{\"000000000000000A\", \"000000000000000B\"}]")
+
+	s.buf.Reset()
+	seclog.LogPromptingRuleDecision(request, "0000000000000004", seclog.PromptingOutcomeDeny, nil)
+
+	c.Check(s.buf.String(), testutil.Contains, "prompt_rule_decision Prompt 0000000000000004 for request 1000:firefox:home:/home/test/foo decided with outcome deny by rules <none>")
+	c.Check(s.buf.String(), testutil.Contains, "[prompt_id=\"0000000000000004\"]")
+}
@@ -226,6 +226,28 @@ func (p Peer) LogValue() slog.Value {
 	)
 }
 
+// LogValue implements [slog.LogValuer], allowing [PromptingRequest] to be
+// used directly as a structured log attribute value.
+func (r PromptingRequest) LogValue() slog.Value {
+	permissions := r.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return slog.GroupValue(
+		slog.Int64("user_id", int64(r.UserID)),
+		slog.String("snap", fieldOrUnknown(r.Snap)),
+		slog.String("interface", fieldOrUnknown(r.Interface)),
+		slog.String("path", fieldOrUnknown(r.Path)),
+		slog.Any("permissions", permissions),
+	)
+}
+
+// LogValue implements [slog.LogValuer], allowing [PromptingOutcome] to be
+// used directly as a structured log attribute value.
+func (o PromptingOutcome) LogValue() slog.Value {
+	return slog.StringValue(string(o))
+}
+
 // fieldOrUnknown returns [unknown] when value is empty.
 func fieldOrUnknown(value string) string {
 	if value == "" {
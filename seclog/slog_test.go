@@ -454,6 +454,55 @@ func (s *SlogSuite) TestEndpointLogValue(c *C) {
 	}
 }
 
+func (s *SlogSuite) TestPromptingRequestLogValue(c *C) {
+	type record struct {
+		Request struct {
+			UserID      uint32   `json:"user_id"`
+			Snap        string   `json:"snap"`
+			Interface   string   `json:"interface"`
+			Path        string   `json:"path"`
+			Permissions []string `json:"permissions"`
+		} `json:"request"`
+		Outcome string `json:"outcome"`
+	}
+
+	logger := s.newLogger(c)
+	logger.LogEvent(
+		seclog.Event{Category: "TEST", Name: "test_event", Level: seclog.LevelInfo},
+		"test",
+		seclog.Attr{Key: "request", Value: seclog.PromptingRequest{
+			UserID:      1000,
+			Snap:        "firefox",
+			Interface:   "home",
+			Path:        "/home/test/foo",
+			Permissions: []string{"read", "write"},
+		}},
+		seclog.Attr{Key: "outcome", Value: seclog.PromptingOutcomeDeny},
+	)
+
+	var obtained record
+	err := json.Unmarshal(s.buf.Bytes(), &obtained)
+	c.Assert(err, IsNil)
+	c.Check(obtained.Request.UserID, Equals, uint32(1000))
+	c.Check(obtained.Request.Snap, Equals, "firefox")
+	c.Check(obtained.Request.Interface, Equals, "home")
+	c.Check(obtained.Request.Path, Equals, "/home/test/foo")
+	c.Check(obtained.Request.Permissions, DeepEquals, []string{"read", "write"})
+	c.Check(obtained.Outcome, Equals, "deny")
+
+	// Unset fields are logged as unknown, and permissions as an empty list
+	s.buf.Reset()
+	logger.LogEvent(
+		seclog.Event{Category: "TEST", Name: "test_event", Level: seclog.LevelInfo},
+		"test",
+		seclog.Attr{Key: "request", Value: seclog.PromptingRequest{UserID: 1000}},
+	)
+	c.Check(s.buf.String(), testutil.Contains, `"snap":"<unknown>"`)
+	c.Check(s.buf.String(), testutil.Contains, `"interface":"<unknown>"`)
+	c.Check(s.buf.String(), testutil.Contains, `"path":"<unknown>"`)
+	c.Check(s.buf.String(), testutil.Contains, `"permissions":[]`)
+}
+
 func (s *SlogSuite) TestLevelFiltering(c *C) {
 	logger := seclog.NewSlogLogger(s.buf, s.appID, seclog.LevelWarn)
 	c.Assert(logger, NotNil)
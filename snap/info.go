@@ -1450,6 +1450,10 @@ type HookInfo struct {
 	Environment  strutil.OrderedMap
 	CommandChain []string
 
+	// Interval is how often the hook should be run periodically by
+	// snapd, if non-zero. Only supported for the check-health hook.
+	Interval timeout.Timeout
+
 	Explicit bool
 }
 
@@ -117,6 +117,7 @@ type hookYaml struct {
 	SlotNames    []string           `yaml:"slots,omitempty"`
 	Environment  strutil.OrderedMap `yaml:"environment,omitempty"`
 	CommandChain []string           `yaml:"command-chain,omitempty"`
+	Interval     timeout.Timeout    `yaml:"interval,omitempty"`
 }
 
 type componentYaml struct {
@@ -563,6 +564,7 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) {
 			Name:         hookName,
 			Environment:  yHook.Environment,
 			CommandChain: yHook.CommandChain,
+			Interval:     yHook.Interval,
 			Explicit:     true,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
@@ -969,6 +969,21 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalHookWithInterval(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    check-health:
+        interval: 10m
+`))
+	c.Assert(err, IsNil)
+
+	hook, ok := info.Hooks["check-health"]
+	c.Assert(ok, Equals, true)
+	c.Check(hook.Interval, Equals, timeout.Timeout(10*time.Minute))
+}
+
 func (s *YamlSuite) TestUnmarshalUnsupportedHook(c *C) {
 	s.restore()
 	hookType := snap.NewHookType(regexp.MustCompile("not-test-hook"))
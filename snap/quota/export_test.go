@@ -63,6 +63,10 @@ func MockCgroupVerErr(mockErr error) (restore func()) {
 	return r
 }
 
+func MockNftablesAvailable(available bool) (restore func()) {
+	return testutil.Mock(&nftablesAvailable, func() bool { return available })
+}
+
 func MockRuntimeNumCPU(mock func() int) (restore func()) {
 	r := testutil.Backup(&runtimeNumCPU)
 	runtimeNumCPU = mock
@@ -24,9 +24,11 @@ package quota
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	// TODO: move this to snap/quantity? or similar
@@ -83,6 +85,28 @@ type GroupQuotaJournal struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+// GroupQuotaIODevice contains the block IO limits of the group for a single
+// device. The limits map to the io.max settings of the cgroup v2 io
+// controller. A zero value for a limit means that it is not set.
+type GroupQuotaIODevice struct {
+	// Device is the path of the block device the limits apply to.
+	Device string `json:"device"`
+	// ReadBandwidth and WriteBandwidth are expressed in bytes per second.
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	// ReadIOPS and WriteIOPS are expressed in IO operations per second.
+	ReadIOPS  int `json:"read-iops,omitempty"`
+	WriteIOPS int `json:"write-iops,omitempty"`
+}
+
+// GroupQuotaNetwork contains the network limits of the group. The limits are
+// enforced with nftables rules matching the cgroup of the group.
+type GroupQuotaNetwork struct {
+	// EgressRate is the maximum rate of outgoing traffic for the group, in
+	// bytes per second.
+	EgressRate quantity.Size `json:"egress-rate,omitempty"`
+}
+
 // Group is a quota group of snaps, services or sub-groups that are all subject
 // to specific resource quotas. The only quota resource types currently
 // supported is memory, but this can be expanded in the future.
@@ -124,6 +148,15 @@ type Group struct {
 	// journald.
 	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
 
+	// IOLimit is the list of block IO limits for the group, per device. The
+	// limits of a sub-group cannot be higher than those of its parents for the
+	// same device.
+	IOLimit []GroupQuotaIODevice `json:"io-limit,omitempty"`
+
+	// NetworkLimit is the network limits for the group. The traffic of a
+	// sub-group is also subject to the limits of its parents.
+	NetworkLimit *GroupQuotaNetwork `json:"network-limit,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -192,6 +225,12 @@ func (grp *Group) GetQuotaResources() Resources {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
 	}
+	for _, dev := range grp.IOLimit {
+		resourcesBuilder.WithIODeviceLimit(ResourceIODevice(dev))
+	}
+	if grp.NetworkLimit != nil {
+		resourcesBuilder.WithNetworkEgressRate(grp.NetworkLimit.EgressRate)
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -244,6 +283,78 @@ func (grp *Group) CurrentTaskUsage() (int, error) {
 	return int(count), nil
 }
 
+// CurrentCPUUsage returns the total CPU time consumed by the processes of the
+// quota group. For quota groups which do not yet have a backing systemd slice
+// on the system, the CPU usage is reported as 0.
+func (grp *Group) CurrentCPUUsage() (time.Duration, error) {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+
+	// check if this group is actually active, it could not physically exist yet
+	// since it has no snaps in it
+	isActive, err := sysd.IsActive(grp.SliceFileName())
+	if err != nil {
+		return 0, err
+	}
+	if !isActive {
+		return 0, nil
+	}
+
+	return sysd.CurrentCPUUsage(grp.SliceFileName())
+}
+
+// CurrentNetworkUsage returns the number of bytes received and sent by the
+// processes of the quota group, as counted by the IP accounting of the
+// slice. For quota groups which do not yet have a backing systemd slice on
+// the system, the usage is reported as 0. If IP accounting is not available
+// systemd.ErrNetworkAccountingUnavailable is returned.
+func (grp *Group) CurrentNetworkUsage() (ingress, egress quantity.Size, err error) {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+
+	// check if this group is actually active, it could not physically exist yet
+	// since it has no snaps in it
+	isActive, err := sysd.IsActive(grp.SliceFileName())
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isActive {
+		return 0, 0, nil
+	}
+
+	return sysd.CurrentNetworkUsage(grp.SliceFileName())
+}
+
+// CurrentJournalUsage returns the disk space used by the journal namespace of
+// the quota group. Groups without a journal quota, or whose namespace has not
+// logged anything yet, report a usage of 0.
+func (grp *Group) CurrentJournalUsage() (quantity.Size, error) {
+	if !grp.JournalQuotaSet() {
+		return 0, nil
+	}
+
+	// journald keeps the files of a namespace in
+	// /var/log/journal/<machine-id>.<namespace>
+	nsDirs, err := filepath.Glob(filepath.Join(dirs.SnapJournalDir, "*."+grp.JournalNamespaceName()))
+	if err != nil {
+		return 0, err
+	}
+	var usage quantity.Size
+	for _, nsDir := range nsDirs {
+		err := filepath.Walk(nsDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				usage += quantity.Size(info.Size())
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return usage, nil
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -274,6 +385,45 @@ func (grp *Group) SliceFileName() string {
 	return buf.String()
 }
 
+// SliceCgroupPath returns the path of the cgroup of the group slice, relative
+// to the root of the cgroup v2 hierarchy. As an example, a group named "bar"
+// that is a child of the "foo" group will return
+// "snap.foo.slice/snap.foo-bar.slice".
+func (grp *Group) SliceCgroupPath() string {
+	if grp.parentGroup == nil {
+		return grp.SliceFileName()
+	}
+	return grp.parentGroup.SliceCgroupPath() + "/" + grp.SliceFileName()
+}
+
+// NetworkRulesFile returns the full path to the nftables rules file that
+// enforces the network limits of the quota group.
+func (grp *Group) NetworkRulesFile() string {
+	return filepath.Join(dirs.SnapNetworkQuotaDir, fmt.Sprintf("snap.%s.nft", grp.Name))
+}
+
+// NetworkRulesTable returns the name of the nftables table of the inet
+// family holding the rules that enforce the network limits of the quota
+// group. Quota group names can only contain dashes besides lowercase letters
+// and digits, which are not allowed in nftables identifiers.
+func (grp *Group) NetworkRulesTable() string {
+	return "snap_quota_" + strings.Replace(grp.Name, "-", "_", -1)
+}
+
+// NetworkRulesFiles returns the nftables rules files enforcing the network
+// limits that apply to the processes of the group, which include the limits
+// of its parents, starting with the top-most parent.
+func (grp *Group) NetworkRulesFiles() []string {
+	var files []string
+	if grp.parentGroup != nil {
+		files = grp.parentGroup.NetworkRulesFiles()
+	}
+	if grp.NetworkLimit != nil {
+		files = append(files, grp.NetworkRulesFile())
+	}
+	return files
+}
+
 // JournalQuotaSet returns true if the group is subject to
 // a journal quota. This should only be used in cases where the caller
 // is interested in knowing if a quota group is affected by a journal
@@ -720,6 +870,124 @@ func (grp *Group) validateThreadResourceFit(allQuotas map[string]*groupQuotaAllo
 	return nil
 }
 
+// ioLimitKinds are the dimensions of an IO limit of a device, a zero value
+// means that the dimension is not limited.
+var ioLimitKinds = []struct {
+	name   string
+	value  func(dev *GroupQuotaIODevice) uint64
+	format func(v uint64) string
+}{
+	{"read bandwidth", func(dev *GroupQuotaIODevice) uint64 { return uint64(dev.ReadBandwidth) }, formatIOBandwidth},
+	{"write bandwidth", func(dev *GroupQuotaIODevice) uint64 { return uint64(dev.WriteBandwidth) }, formatIOBandwidth},
+	{"read iops", func(dev *GroupQuotaIODevice) uint64 { return uint64(dev.ReadIOPS) }, formatIOPS},
+	{"write iops", func(dev *GroupQuotaIODevice) uint64 { return uint64(dev.WriteIOPS) }, formatIOPS},
+}
+
+func formatIOBandwidth(v uint64) string {
+	return quantity.Size(v).IECString() + "/s"
+}
+
+func formatIOPS(v uint64) string {
+	return fmt.Sprintf("%d iops", v)
+}
+
+// GetLocalIODeviceLimit returns the IO limits set by the group itself for
+// the given device, or nil if there are none.
+func (grp *Group) GetLocalIODeviceLimit(device string) *GroupQuotaIODevice {
+	for i := range grp.IOLimit {
+		if grp.IOLimit[i].Device == device {
+			return &grp.IOLimit[i]
+		}
+	}
+	return nil
+}
+
+// validateIOResourceFit verifies that the resulting IO limits of the group
+// for each device fit with the limits of the rest of the tree. Unlike the
+// memory and thread limits, the IO limits of sub-groups are not reserved
+// from the limit of their parent, as the io controller throttles the
+// sub-groups against the limits of their parents anyway. So for each device
+// and each limit dimension, the limit must not be larger than the one of
+// the nearest parent limiting it, nor smaller than any of the sub-groups
+// limiting it.
+func (grp *Group) validateIOResourceFit(ioLimits *ResourceIO) error {
+	for _, newDev := range ioLimits.Devices {
+		dev := GroupQuotaIODevice(newDev)
+		for _, kind := range ioLimitKinds {
+			limit := kind.value(&dev)
+			if limit == 0 {
+				continue
+			}
+
+			for parent := grp.parentGroup; parent != nil; parent = parent.parentGroup {
+				parentDev := parent.GetLocalIODeviceLimit(dev.Device)
+				if parentDev == nil || kind.value(parentDev) == 0 {
+					continue
+				}
+				if parentLimit := kind.value(parentDev); limit > parentLimit {
+					return fmt.Errorf("sub-group io %s limit of %s for device %q is too large to fit inside group %q limit of %s",
+						kind.name, kind.format(limit), dev.Device, parent.Name, kind.format(parentLimit))
+				}
+				break
+			}
+
+			if err := grp.validateIOSubGroupsFit(dev.Device, kind.name, kind.value, kind.format, limit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (grp *Group) validateIOSubGroupsFit(device, kindName string, value func(*GroupQuotaIODevice) uint64, format func(uint64) string, limit uint64) error {
+	for _, subGroup := range grp.subGroups {
+		// cyclic checks are made by visitTree so we make the assumption here
+		// that no cyclic dependencies exists.
+		if subDev := subGroup.GetLocalIODeviceLimit(device); subDev != nil {
+			if subLimit := value(subDev); subLimit > limit {
+				return fmt.Errorf("group io %s limit of %s for device %q is too small to fit sub-group %q limit of %s",
+					kindName, format(limit), device, subGroup.Name, format(subLimit))
+			}
+		}
+		if err := subGroup.validateIOSubGroupsFit(device, kindName, value, format, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNetworkResourceFit verifies that the new egress rate limit of the
+// group fits with the rest of the tree. As for the IO limits, the traffic of
+// sub-groups is limited by their parents anyway, so the limit must not be
+// larger than the one of the nearest parent limiting it, nor smaller than any
+// of the sub-groups limiting it.
+func (grp *Group) validateNetworkResourceFit(egressRate quantity.Size) error {
+	for parent := grp.parentGroup; parent != nil; parent = parent.parentGroup {
+		if parent.NetworkLimit == nil {
+			continue
+		}
+		if egressRate > parent.NetworkLimit.EgressRate {
+			return fmt.Errorf("sub-group network egress rate limit of %s/s is too large to fit inside group %q limit of %s/s",
+				egressRate.IECString(), parent.Name, parent.NetworkLimit.EgressRate.IECString())
+		}
+		break
+	}
+	return grp.validateNetworkSubGroupsFit(egressRate)
+}
+
+func (grp *Group) validateNetworkSubGroupsFit(egressRate quantity.Size) error {
+	for _, subGroup := range grp.subGroups {
+		if subGroup.NetworkLimit != nil && subGroup.NetworkLimit.EgressRate > egressRate {
+			return fmt.Errorf("group network egress rate limit of %s/s is too small to fit sub-group %q limit of %s/s",
+				egressRate.IECString(), subGroup.Name, subGroup.NetworkLimit.EgressRate.IECString())
+		}
+		if err := subGroup.validateNetworkSubGroupsFit(egressRate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validateQuotasFit verifies that the given group's current limits fits correctly
 // into the group's parent group's limits. This is done in multiple steps, where the first
 // one is to get a statistics for the upper-most parent group, to get a combined overview
@@ -756,6 +1024,16 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 			return err
 		}
 	}
+	if resourceLimits.IO != nil {
+		if err := grp.validateIOResourceFit(resourceLimits.IO); err != nil {
+			return err
+		}
+	}
+	if resourceLimits.Network != nil && resourceLimits.Network.EgressRate != 0 {
+		if err := grp.validateNetworkResourceFit(resourceLimits.Network.EgressRate); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -804,6 +1082,20 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
 	}
+	if resourceLimits.IO != nil {
+		currentIO := currentLimits.IO
+		grp.IOLimit = nil
+		if ioLimits := mergeIOLimits(currentIO, resourceLimits.IO); ioLimits != nil {
+			for _, dev := range ioLimits.Devices {
+				grp.IOLimit = append(grp.IOLimit, GroupQuotaIODevice(dev))
+			}
+		}
+	}
+	if resourceLimits.Network != nil {
+		grp.NetworkLimit = &GroupQuotaNetwork{
+			EgressRate: resourceLimits.Network.EgressRate,
+		}
+	}
 	return nil
 }
 
@@ -22,11 +22,14 @@ package quota_test
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/systemd"
@@ -1101,6 +1104,123 @@ func (ts *quotaTestSuite) TestCurrentTaskUsage(c *C) {
 	c.Check(systemctlCalls, Equals, 5)
 }
 
+func (ts *quotaTestSuite) TestCurrentCPUUsage(c *C) {
+	systemctlCalls := 0
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlCalls++
+		switch systemctlCalls {
+		case 1:
+			// first time pretend the service is inactive
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("inactive"), systemctlInactiveServiceError{}
+		case 2:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("active"), nil
+		case 3:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "CPUUsageNSec", "snap.group.slice"})
+			return []byte("CPUUsageNSec=2000000000"), nil
+		default:
+			c.Errorf("unexpected number of systemctl calls (%d) (current call is %+v)", systemctlCalls, args)
+			return []byte("broken test"), fmt.Errorf("broken test")
+		}
+	})
+	defer r()
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+
+	// group initially is inactive, so it has used no cpu time
+	currentCPU, err := grp1.CurrentCPUUsage()
+	c.Check(err, IsNil)
+	c.Check(currentCPU, Equals, time.Duration(0))
+
+	currentCPU, err = grp1.CurrentCPUUsage()
+	c.Check(err, IsNil)
+	c.Check(currentCPU, Equals, 2*time.Second)
+	c.Check(systemctlCalls, Equals, 3)
+}
+
+func (ts *quotaTestSuite) TestCurrentNetworkUsage(c *C) {
+	systemctlCalls := 0
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlCalls++
+		switch systemctlCalls {
+		case 1:
+			// first time pretend the service is inactive
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("inactive"), systemctlInactiveServiceError{}
+		case 2, 5:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("active"), nil
+		case 3:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "IPIngressBytes", "snap.group.slice"})
+			return []byte("IPIngressBytes=4096"), nil
+		case 4:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "IPEgressBytes", "snap.group.slice"})
+			return []byte("IPEgressBytes=1024"), nil
+		case 6:
+			// ip accounting is not available
+			c.Assert(args, DeepEquals, []string{"show", "--property", "IPIngressBytes", "snap.group.slice"})
+			return []byte("IPIngressBytes=[no data]"), nil
+		default:
+			c.Errorf("unexpected number of systemctl calls (%d) (current call is %+v)", systemctlCalls, args)
+			return []byte("broken test"), fmt.Errorf("broken test")
+		}
+	})
+	defer r()
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithThreadLimit(32).Build())
+	c.Assert(err, IsNil)
+
+	// group initially is inactive, so it has no traffic
+	ingress, egress, err := grp1.CurrentNetworkUsage()
+	c.Check(err, IsNil)
+	c.Check(ingress, Equals, quantity.Size(0))
+	c.Check(egress, Equals, quantity.Size(0))
+
+	ingress, egress, err = grp1.CurrentNetworkUsage()
+	c.Check(err, IsNil)
+	c.Check(ingress, Equals, quantity.Size(4096))
+	c.Check(egress, Equals, quantity.Size(1024))
+
+	_, _, err = grp1.CurrentNetworkUsage()
+	c.Check(err, Equals, systemd.ErrNetworkAccountingUnavailable)
+	c.Check(systemctlCalls, Equals, 6)
+}
+
+func (ts *quotaTestSuite) TestCurrentJournalUsage(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithJournalNamespace().Build())
+	c.Assert(err, IsNil)
+	grp2, err := quota.NewGroup("other", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	// nothing logged yet
+	usage, err := grp1.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(0))
+
+	nsDir := filepath.Join(dirs.SnapJournalDir, "0123456789abcdef.snap-group")
+	c.Assert(os.MkdirAll(nsDir, 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(nsDir, "system.journal"), make([]byte, 4096), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(nsDir, "system@0001.journal~"), make([]byte, 1024), 0644), IsNil)
+	// the namespace of another group is not counted
+	otherDir := filepath.Join(dirs.SnapJournalDir, "0123456789abcdef.snap-group-other")
+	c.Assert(os.MkdirAll(otherDir, 0755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(otherDir, "system.journal"), make([]byte, 4096), 0644), IsNil)
+
+	usage, err = grp1.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, 5*quantity.SizeKiB)
+
+	// groups without a journal quota have no journal namespace
+	usage, err = grp2.CurrentJournalUsage()
+	c.Check(err, IsNil)
+	c.Check(usage, Equals, quantity.Size(0))
+}
+
 func (ts *quotaTestSuite) TestGetGroupQuotaAllocations(c *C) {
 	// Verify we get the correct allocations for a group with a more complex tree-structure
 	// and different quotas split out into different sub-groups.
@@ -1575,6 +1695,123 @@ func (ts *quotaTestSuite) TestJournalQuotasUpdatesCorrectly(c *C) {
 	c.Check(grp1.JournalLimit.RatePeriod, Equals, time.Microsecond*5)
 }
 
+func (ts *quotaTestSuite) TestIOQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB}).
+		Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, []quota.GroupQuotaIODevice{
+		{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB},
+	})
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sdb", WriteIOPS: 100}).
+		Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, []quota.GroupQuotaIODevice{
+		{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB},
+		{Device: "/dev/sdb", WriteIOPS: 100},
+	})
+
+	resources := grp1.GetQuotaResources()
+	c.Check(resources.IO, DeepEquals, &quota.ResourceIO{Devices: []quota.ResourceIODevice{
+		{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB},
+		{Device: "/dev/sdb", WriteIOPS: 100},
+	}})
+}
+
+func (ts *quotaTestSuite) TestNestingOfIOLimits(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: 10 * quantity.SizeMiB, WriteIOPS: 100}).
+		Build())
+	c.Assert(err, IsNil)
+
+	// a sub-group without its own io limit
+	subgrp1, err := grp1.NewSubGroup("middle", quota.NewResourcesBuilder().WithThreadLimit(32).Build())
+	c.Assert(err, IsNil)
+
+	// limits larger than the nearest limiting parent are not allowed
+	_, err = subgrp1.NewSubGroup("io-sub", quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: 20 * quantity.SizeMiB}).
+		Build())
+	c.Check(err, ErrorMatches, `sub-group io read bandwidth limit of 20 MiB/s for device "/dev/sda" is too large to fit inside group "groot" limit of 10 MiB/s`)
+	_, err = subgrp1.NewSubGroup("io-sub", quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", WriteIOPS: 200}).
+		Build())
+	c.Check(err, ErrorMatches, `sub-group io write iops limit of 200 iops for device "/dev/sda" is too large to fit inside group "groot" limit of 100 iops`)
+
+	// dimensions and devices not limited by the parent are fine, and unlike
+	// memory the limits of siblings are not summed up
+	for _, name := range []string{"io-sub1", "io-sub2"} {
+		_, err = subgrp1.NewSubGroup(name, quota.NewResourcesBuilder().
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: 10 * quantity.SizeMiB, ReadIOPS: 1000}).
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sdb", WriteBandwidth: quantity.SizeGiB}).
+			Build())
+		c.Assert(err, IsNil)
+	}
+
+	// the parent limits cannot be lowered below the ones of any sub-group
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: 5 * quantity.SizeMiB}).
+		Build())
+	c.Check(err, ErrorMatches, `group io read bandwidth limit of 5 MiB/s for device "/dev/sda" is too small to fit sub-group "io-sub1" limit of 10 MiB/s`)
+	err = subgrp1.QuotaUpdateCheck(quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sdb", WriteBandwidth: quantity.SizeMiB}).
+		Build())
+	c.Check(err, ErrorMatches, `group io write bandwidth limit of 1 MiB/s for device "/dev/sdb" is too small to fit sub-group "io-sub1" limit of 1 GiB/s`)
+
+	// but they can be raised
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: 50 * quantity.SizeMiB}).
+		Build())
+	c.Check(err, IsNil)
+}
+
+func (ts *quotaTestSuite) TestNestingOfNetworkLimits(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithNetworkEgressRate(10*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	subgrp1, err := grp1.NewSubGroup("middle", quota.NewResourcesBuilder().WithThreadLimit(32).Build())
+	c.Assert(err, IsNil)
+
+	_, err = subgrp1.NewSubGroup("net-sub", quota.NewResourcesBuilder().WithNetworkEgressRate(20*quantity.SizeMiB).Build())
+	c.Check(err, ErrorMatches, `sub-group network egress rate limit of 20 MiB/s is too large to fit inside group "groot" limit of 10 MiB/s`)
+
+	// the rates of siblings are not summed up
+	for _, name := range []string{"net-sub1", "net-sub2"} {
+		_, err = subgrp1.NewSubGroup(name, quota.NewResourcesBuilder().WithNetworkEgressRate(10*quantity.SizeMiB).Build())
+		c.Assert(err, IsNil)
+	}
+
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithNetworkEgressRate(5 * quantity.SizeMiB).Build())
+	c.Check(err, ErrorMatches, `group network egress rate limit of 5 MiB/s is too small to fit sub-group "net-sub1" limit of 10 MiB/s`)
+
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithNetworkEgressRate(50 * quantity.SizeMiB).Build())
+	c.Check(err, IsNil)
+}
+
+func (ts *quotaTestSuite) TestNetworkRulesFiles(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithNetworkEgressRate(10*quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	subgrp1, err := grp1.NewSubGroup("middle", quota.NewResourcesBuilder().WithThreadLimit(32).Build())
+	c.Assert(err, IsNil)
+	subgrp2, err := subgrp1.NewSubGroup("leaf", quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	c.Check(grp1.NetworkLimit, DeepEquals, &quota.GroupQuotaNetwork{EgressRate: 10 * quantity.SizeMiB})
+	c.Check(subgrp2.SliceCgroupPath(), Equals, `snap.groot.slice/snap.groot-middle.slice/snap.groot-middle-leaf.slice`)
+	c.Check(subgrp1.NetworkRulesFiles(), DeepEquals, []string{
+		filepath.Join(dirs.SnapNetworkQuotaDir, "snap.groot.nft"),
+	})
+	c.Check(subgrp2.NetworkRulesFiles(), DeepEquals, []string{
+		filepath.Join(dirs.SnapNetworkQuotaDir, "snap.groot.nft"),
+		filepath.Join(dirs.SnapNetworkQuotaDir, "snap.leaf.nft"),
+	})
+}
+
 func (ts *quotaTestSuite) TestServiceMapEmptyOnEmptyGroup(c *C) {
 	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
 	c.Assert(err, IsNil)
@@ -21,10 +21,12 @@ package quota
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 )
 
@@ -40,6 +42,14 @@ func init() {
 	cgroupVer, cgroupVerErr = cgroup.Version()
 }
 
+// NftablesCommand is the command loading the nftables rules which enforce
+// the network limits of quota groups.
+const NftablesCommand = "/usr/sbin/nft"
+
+var nftablesAvailable = func() bool {
+	return osutil.IsExecutable(NftablesCommand)
+}
+
 func setMemoryCgroupSupport() {
 	cgroupCheckMemoryCgroupErr = cgroup.CheckMemoryCgroup()
 }
@@ -80,6 +90,35 @@ type ResourceJournal struct {
 	Rate *ResourceJournalRate `json:"rate,omitempty"`
 }
 
+// ResourceIODevice represents the block IO limits for a single device. A
+// limit of zero means that the given IO dimension is not limited.
+type ResourceIODevice struct {
+	// Device is the path of the block device, or of a file on the
+	// filesystem backed by the block device, the limits apply to.
+	Device         string        `json:"device"`
+	ReadBandwidth  quantity.Size `json:"read-bandwidth,omitempty"`
+	WriteBandwidth quantity.Size `json:"write-bandwidth,omitempty"`
+	ReadIOPS       int           `json:"read-iops,omitempty"`
+	WriteIOPS      int           `json:"write-iops,omitempty"`
+}
+
+// unset returns true if no IO limit is set for the device.
+func (d *ResourceIODevice) unset() bool {
+	return d.ReadBandwidth == 0 && d.WriteBandwidth == 0 && d.ReadIOPS == 0 && d.WriteIOPS == 0
+}
+
+// ResourceIO represents the block IO quotas, which are set per device.
+type ResourceIO struct {
+	Devices []ResourceIODevice `json:"devices"`
+}
+
+// ResourceNetwork represents the network quotas, currently only the rate of
+// the outgoing traffic can be limited.
+type ResourceNetwork struct {
+	// EgressRate is the maximum rate of outgoing traffic in bytes per second.
+	EgressRate quantity.Size `json:"egress-rate"`
+}
+
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
@@ -89,6 +128,8 @@ type Resources struct {
 	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
 	Threads *ResourceThreads `json:"thread,omitempty"`
 	Journal *ResourceJournal `json:"journal,omitempty"`
+	IO      *ResourceIO      `json:"io,omitempty"`
+	Network *ResourceNetwork `json:"network,omitempty"`
 }
 
 const (
@@ -104,6 +145,10 @@ const (
 	// usage, but we have selected 64kB to protect against ridiculously small values.
 	journalLimitMin = 64 * quantity.SizeKiB
 	journalLimitMax = 4 * quantity.SizeGiB
+
+	// below a few KiB per second most network protocols would just time out,
+	// so protect against ridiculously small values.
+	networkEgressRateMin = 8 * quantity.SizeKiB
 )
 
 func (qr *Resources) validateMemoryQuota() error {
@@ -191,6 +236,42 @@ func (qr *Resources) validateJournalQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateIOQuota() error {
+	if len(qr.IO.Devices) == 0 {
+		return fmt.Errorf("io quota must have at least one device set")
+	}
+
+	seen := make(map[string]bool, len(qr.IO.Devices))
+	for _, dev := range qr.IO.Devices {
+		if !filepath.IsAbs(dev.Device) || filepath.Clean(dev.Device) != dev.Device {
+			return fmt.Errorf("invalid io quota device %q: must be a clean absolute path", dev.Device)
+		}
+		if seen[dev.Device] {
+			return fmt.Errorf("invalid io quota: device %q is set more than once", dev.Device)
+		}
+		seen[dev.Device] = true
+
+		if dev.ReadIOPS < 0 || dev.WriteIOPS < 0 {
+			return fmt.Errorf("invalid io quota for device %q: iops limits must not be negative", dev.Device)
+		}
+		if dev.unset() {
+			return fmt.Errorf("io quota for device %q must have a limit set", dev.Device)
+		}
+	}
+	return nil
+}
+
+func (qr *Resources) validateNetworkQuota() error {
+	if qr.Network.EgressRate == 0 {
+		return fmt.Errorf("network quota must have an egress rate limit set")
+	}
+	if qr.Network.EgressRate < networkEgressRateMin {
+		return fmt.Errorf("network egress rate limit %d is too small: rate must be at least %s per second",
+			qr.Network.EgressRate, networkEgressRateMin.IECString())
+	}
+	return nil
+}
+
 // CheckFeatureRequirements checks if the current system meets the
 // requirements for the given resource request.
 //
@@ -204,6 +285,27 @@ func (qr *Resources) CheckFeatureRequirements() error {
 			return fmt.Errorf("cannot use CPU set with cgroup version %d", cgroupVer)
 		}
 	}
+	if qr.IO != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use io quota with cgroup version %d", cgroupVer)
+		}
+	}
+	// the egress rate is enforced with nftables matching on the cgroup v2
+	// path of the quota group slice
+	if qr.Network != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use network quota with cgroup version %d", cgroupVer)
+		}
+		if !nftablesAvailable() {
+			return fmt.Errorf("cannot use network quota: %s is not available", NftablesCommand)
+		}
+	}
 	if qr.Memory != nil {
 		cgroupCheckMemoryCgroupOnce.Do(setMemoryCgroupSupport)
 
@@ -260,6 +362,18 @@ func (qr *Resources) Validate() error {
 			return err
 		}
 	}
+
+	if qr.IO != nil {
+		if err := qr.validateIOQuota(); err != nil {
+			return err
+		}
+	}
+
+	if qr.Network != nil {
+		if err := qr.validateNetworkQuota(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -356,6 +470,25 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		// rate-limit for the group, overriding the journal default which is 10000/30s
 	}
 
+	// IO limits are changed per device, removing the limits of a device
+	// is done by setting all of them to zero, but at least one device must
+	// remain limited
+	if qr.IO != nil && newLimits.IO != nil {
+		if len(newLimits.IO.Devices) == 0 {
+			return fmt.Errorf("cannot remove io limit from quota group")
+		}
+		merged := qr.clone()
+		merged.changeInternal(Resources{IO: newLimits.IO})
+		if merged.IO == nil {
+			return fmt.Errorf("cannot remove io limit from quota group")
+		}
+	}
+
+	// Check that the network egress rate limit is not being removed
+	if qr.Network != nil && newLimits.Network != nil && newLimits.Network.EgressRate == 0 {
+		return fmt.Errorf("cannot remove network limit from quota group")
+	}
+
 	return nil
 }
 
@@ -383,6 +516,14 @@ func (qr *Resources) clone() Resources {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
 	}
+	if qr.IO != nil {
+		resourcesCopy.IO = &ResourceIO{
+			Devices: append([]ResourceIODevice(nil), qr.IO.Devices...),
+		}
+	}
+	if qr.Network != nil {
+		resourcesCopy.Network = &ResourceNetwork{EgressRate: qr.Network.EgressRate}
+	}
 	return resourcesCopy
 }
 
@@ -411,6 +552,46 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
 	}
+	if newLimits.IO != nil {
+		qr.IO = mergeIOLimits(qr.IO, newLimits.IO)
+	}
+	if newLimits.Network != nil {
+		qr.Network = newLimits.Network
+	}
+}
+
+// mergeIOLimits applies the new per device limits on top of the current
+// ones. Limits of devices which are not mentioned are kept, and devices for
+// which all the limits are set to zero are dropped.
+func mergeIOLimits(current, newLimits *ResourceIO) *ResourceIO {
+	var devices []ResourceIODevice
+	if current != nil {
+		devices = append(devices, current.Devices...)
+	}
+	for _, newDev := range newLimits.Devices {
+		found := false
+		for i := range devices {
+			if devices[i].Device == newDev.Device {
+				devices[i] = newDev
+				found = true
+				break
+			}
+		}
+		if !found {
+			devices = append(devices, newDev)
+		}
+	}
+
+	merged := &ResourceIO{}
+	for _, dev := range devices {
+		if !dev.unset() {
+			merged.Devices = append(merged.Devices, dev)
+		}
+	}
+	if len(merged.Devices) == 0 {
+		return nil
+	}
+	return merged
 }
 
 // Change updates the current quota limits with the new limits. Additional verification
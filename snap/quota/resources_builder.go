@@ -49,6 +49,12 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	IODevices []ResourceIODevice
+	IOSet     bool
+
+	NetworkEgressRate    quantity.Size
+	NetworkEgressRateSet bool
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -99,6 +105,20 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+// WithIODeviceLimit adds the IO limits for a device, it can be called
+// multiple times to limit IO on several devices.
+func (rb *ResourcesBuilder) WithIODeviceLimit(limit ResourceIODevice) *ResourcesBuilder {
+	rb.IODevices = append(rb.IODevices, limit)
+	rb.IOSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithNetworkEgressRate(rate quantity.Size) *ResourcesBuilder {
+	rb.NetworkEgressRate = rate
+	rb.NetworkEgressRateSet = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -136,6 +156,16 @@ func (rb *ResourcesBuilder) Build() Resources {
 			}
 		}
 	}
+	if rb.IOSet {
+		quotaResources.IO = &ResourceIO{
+			Devices: rb.IODevices,
+		}
+	}
+	if rb.NetworkEgressRateSet {
+		quotaResources.Network = &ResourceNetwork{
+			EgressRate: rb.NetworkEgressRate,
+		}
+	}
 	return quotaResources
 }
 
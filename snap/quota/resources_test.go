@@ -50,6 +50,16 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.Resources{IO: &quota.ResourceIO{}}, `io quota must have at least one device set`},
+		{quota.NewResourcesBuilder().WithNetworkEgressRate(0).Build(), `network quota must have an egress rate limit set`},
+		{quota.NewResourcesBuilder().WithNetworkEgressRate(1000).Build(), `network egress rate limit 1000 is too small: rate must be at least 8 KiB per second`},
+		{quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "sda", ReadIOPS: 10}).Build(), `invalid io quota device "sda": must be a clean absolute path`},
+		{quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/../sda", ReadIOPS: 10}).Build(), `invalid io quota device "/dev/../sda": must be a clean absolute path`},
+		{quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda"}).Build(), `io quota for device "/dev/sda" must have a limit set`},
+		{quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", WriteIOPS: -1}).Build(), `invalid io quota for device "/dev/sda": iops limits must not be negative`},
+		{quota.NewResourcesBuilder().
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10}).
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", WriteIOPS: 10}).Build(), `invalid io quota: device "/dev/sda" is set more than once`},
 	}
 
 	for _, t := range tests {
@@ -69,6 +79,28 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1(c *C)
 	// cpu set with cgroup v1 is not supported
 	bad := quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use CPU set with cgroup version 1")
+
+	// as are io limits
+	bad = quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10}).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use io quota with cgroup version 1")
+
+	// and network limits
+	bad = quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use network quota with cgroup version 1")
+}
+
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsNetwork(c *C) {
+	defer quota.MockCgroupVer(2)()
+
+	r := quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build()
+
+	restore := quota.MockNftablesAvailable(true)
+	c.Check(r.CheckFeatureRequirements(), IsNil)
+	restore()
+
+	restore = quota.MockNftablesAvailable(false)
+	defer restore()
+	c.Check(r.CheckFeatureRequirements(), ErrorMatches, "cannot use network quota: /usr/sbin/nft is not available")
 }
 
 func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *C) {
@@ -95,6 +127,11 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
 		{quota.NewResourcesBuilder().WithJournalNamespace().Build()},
+		{quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadBandwidth: quantity.SizeMiB}).Build()},
+		{quota.NewResourcesBuilder().
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10, WriteIOPS: 20}).
+			WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/mmcblk0", WriteBandwidth: quantity.SizeMiB}).Build()},
+		{quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build()},
 	}
 
 	for _, t := range tests {
@@ -129,6 +166,21 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithThreadLimit(0).Build(),
 			`cannot remove thread limit from quota group`,
 		},
+		{
+			quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10}).Build(),
+			quota.Resources{IO: &quota.ResourceIO{}},
+			`cannot remove io limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10}).Build(),
+			quota.NewResourcesBuilder().WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda"}).Build(),
+			`cannot remove io limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithNetworkEgressRate(0).Build(),
+			`cannot remove network limit from quota group`,
+		},
 		{
 			quota.NewResourcesBuilder().WithThreadLimit(64).Build(),
 			quota.NewResourcesBuilder().WithThreadLimit(32).Build(),
@@ -312,6 +364,25 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationPasses(c *C) {
 	}
 }
 
+func (s *resourcesTestSuite) TestQuotaChangeIOLimitsPerDevice(c *C) {
+	limits := quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda", ReadIOPS: 10}).
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sdb", WriteIOPS: 10}).
+		Build()
+
+	// limits of the devices not mentioned are kept, and clearing all the
+	// limits of a device drops it
+	err := limits.Change(quota.NewResourcesBuilder().
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sda"}).
+		WithIODeviceLimit(quota.ResourceIODevice{Device: "/dev/sdc", ReadBandwidth: quantity.SizeMiB}).
+		Build())
+	c.Assert(err, IsNil)
+	c.Check(limits.IO, DeepEquals, &quota.ResourceIO{Devices: []quota.ResourceIODevice{
+		{Device: "/dev/sdb", WriteIOPS: 10},
+		{Device: "/dev/sdc", ReadBandwidth: quantity.SizeMiB},
+	}})
+}
+
 func (s *resourcesTestSuite) TestResourceCloneComplete(c *C) {
 	r := &quota.Resources{}
 	rv := reflect.ValueOf(r).Elem()
@@ -29,6 +29,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/snapcore/snapd/osutil"
@@ -196,6 +197,10 @@ func validateHooks(info *Info) error {
 	return nil
 }
 
+// MinimumHookInterval is the shortest interval at which snapd can be
+// asked to run a hook periodically.
+const MinimumHookInterval = 1 * time.Minute
+
 // ValidateHook validates the content of the given HookInfo
 func ValidateHook(hook *HookInfo) error {
 	if err := naming.ValidateHook(hook.Name); err != nil {
@@ -209,6 +214,15 @@ func ValidateHook(hook *HookInfo) error {
 		}
 	}
 
+	if hook.Interval != 0 {
+		if hook.Name != "check-health" {
+			return fmt.Errorf("cannot specify interval for hook %q: only supported for the check-health hook", hook.Name)
+		}
+		if time.Duration(hook.Interval) < MinimumHookInterval {
+			return fmt.Errorf("cannot specify interval %s for hook %q: must be at least %s", hook.Interval, hook.Name, MinimumHookInterval)
+		}
+	}
+
 	return nil
 }
 
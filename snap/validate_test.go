@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	. "gopkg.in/check.v1"
@@ -34,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	. "github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timeout"
 )
 
 type ValidateSuite struct {
@@ -175,6 +177,20 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateHookInterval(c *C) {
+	err := ValidateHook(&HookInfo{Name: "check-health", Interval: timeout.Timeout(5 * time.Minute)})
+	c.Check(err, IsNil)
+
+	err = ValidateHook(&HookInfo{Name: "check-health", Interval: timeout.Timeout(10 * time.Second)})
+	c.Check(err, ErrorMatches, `cannot specify interval 10s for hook "check-health": must be at least 1m0s`)
+
+	err = ValidateHook(&HookInfo{Name: "check-health", Interval: timeout.Timeout(-time.Hour)})
+	c.Check(err, ErrorMatches, `cannot specify interval -1h0m0s for hook "check-health": must be at least 1m0s`)
+
+	err = ValidateHook(&HookInfo{Name: "configure", Interval: timeout.Timeout(time.Hour)})
+	c.Check(err, ErrorMatches, `cannot specify interval for hook "configure": only supported for the check-health hook`)
+}
+
 // ValidateApp
 
 func (s *ValidateSuite) TestValidateAppSockets(c *C) {
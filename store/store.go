@@ -167,6 +167,10 @@ type Store struct {
 
 	cacher downloadCache
 
+	// peers is the optional source of blobs from the other devices of the
+	// cluster
+	peers PeerSource
+
 	proxy              func(*http.Request) (*url.URL, error)
 	proxyConnectHeader http.Header
 
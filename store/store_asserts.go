@@ -40,12 +40,7 @@ func (s *Store) assertionsEndpointURL(p string, query url.Values) (*url.URL, err
 		return nil, err
 	}
 
-	defBaseURL := s.cfg.StoreBaseURL
-	// can be overridden separately!
-	if s.cfg.AssertionsBaseURL != nil {
-		defBaseURL = s.cfg.AssertionsBaseURL
-	}
-	return endpointURL(s.baseURL(defBaseURL), path.Join(assertionsPath, p), query), nil
+	return endpointURL(s.AssertionsBaseURL(), path.Join(assertionsPath, p), query), nil
 }
 
 type assertionSvcError struct {
@@ -174,6 +174,15 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		logger.Debugf("Cache entry for SHA3_384 …%.5s has unexpected size, re-downloading.", downloadInfo.Sha3_384)
 	}
 
+	if peers := s.peerSource(); peers != nil && downloadInfo.Sha3_384 != "" {
+		err := s.downloadFromPeers(ctx, peers, name, targetPath, downloadInfo, pbar)
+		if err == nil {
+			return s.cacher.Put(downloadInfo.Sha3_384, targetPath)
+		}
+		// We revert to downloading from the store if there is any error.
+		logger.Debugf("Cannot fetch %s from peers: %v", name, err)
+	}
+
 	if len(s.supportedDeltaFormats()) > 0 {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 		if len(downloadInfo.Deltas) > 0 {
@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PeerSource provides snap and component blobs from peers, such as the other
+// devices of a cluster, as an alternative to downloading them from the store.
+type PeerSource interface {
+	// OpenBlob returns a stream with the content of the blob with the given
+	// sha3-384 digest, as provided by some peer which has it, along with the
+	// size of the blob. The content is not trusted, and is verified by the
+	// store against the expected digest.
+	OpenBlob(ctx context.Context, sha3_384 string) (io.ReadCloser, int64, error)
+}
+
+// SetPeerSource configures a source from which snap and component blobs are
+// fetched before falling back to downloading them from the store. A nil
+// source disables fetching blobs from peers.
+func (s *Store) SetPeerSource(src PeerSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = src
+}
+
+func (s *Store) peerSource() PeerSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peers
+}
+
+// OpenCachedBlob opens the blob with the given sha3-384 digest from the
+// downloads cache, so that it can be provided to peers. Returns an error
+// satisfying errors.Is(err, fs.ErrNotExist) if the blob is not in the cache.
+func (s *Store) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	return s.cacher.Open(sha3_384)
+}
+
+// downloadFromPeers fetches the blob described by the given download info
+// from peers into targetPath, verifying its size and sha3-384 digest.
+//
+// On error, nothing is left behind at targetPath, and the caller is expected
+// to fall back to downloading the blob from the store.
+func (s *Store) downloadFromPeers(ctx context.Context, peers PeerSource, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter) (err error) {
+	r, size, err := peers.OpenBlob(ctx, downloadInfo.Sha3_384)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if downloadInfo.Size != 0 && size != downloadInfo.Size {
+		return fmt.Errorf("size mismatch for %q: got %d but expected %d", name, size, downloadInfo.Size)
+	}
+
+	// use a dedicated partial file, so that a partial download from the store
+	// which may be resumed later is not clobbered
+	partialPath := targetPath + ".peer.partial"
+	w, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(partialPath)
+		}
+	}()
+
+	if pbar == nil {
+		pbar = progress.Null
+	}
+	h := crypto.SHA3_384.New()
+	pbar.Start(name, float64(size))
+	// never read more than the advertised size from an untrusted peer
+	n, err := io.Copy(io.MultiWriter(w, h, pbar), io.LimitReader(r, size+1))
+	pbar.Finished()
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("size mismatch for %q: got %d but expected %d", name, n, size)
+	}
+
+	actualSha3 := fmt.Sprintf("%x", h.Sum(nil))
+	if actualSha3 != downloadInfo.Sha3_384 {
+		return HashError{name, actualSha3, downloadInfo.Sha3_384}
+	}
+
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(partialPath, targetPath); err != nil {
+		return err
+	}
+	d, err := os.Open(filepath.Dir(targetPath))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return err
+	}
+
+	logger.Debugf("Fetched %q from peers.", name)
+	return nil
+}
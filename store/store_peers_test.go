@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fakePeerSource struct {
+	content []byte
+	size    int64
+	err     error
+
+	requested []string
+}
+
+func (p *fakePeerSource) OpenBlob(ctx context.Context, sha3_384 string) (io.ReadCloser, int64, error) {
+	p.requested = append(p.requested, sha3_384)
+	if p.err != nil {
+		return nil, 0, p.err
+	}
+	size := p.size
+	if size == 0 {
+		size = int64(len(p.content))
+	}
+	return io.NopCloser(bytes.NewReader(p.content)), size, nil
+}
+
+func sha3_384Hex(data []byte) string {
+	h := crypto.SHA3_384.New()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeers(c *C) {
+	content := []byte("snap blob from a peer")
+	digest := sha3_384Hex(content)
+
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	restore := s.store.MockCacher(obs)
+	defer restore()
+
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatal("unexpected download from the store")
+		return nil
+	})
+	defer restore()
+
+	peers := &fakePeerSource{content: content}
+	s.store.SetPeerSource(peers)
+
+	info := &snap.DownloadInfo{
+		Sha3_384: digest,
+		Size:     int64(len(content)),
+	}
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := s.store.Download(s.ctx, "foo", path, info, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	c.Check(peers.requested, DeepEquals, []string{digest})
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(path+".peer.partial", testutil.FileAbsent)
+	// blobs fetched from peers are cached, so they can be provided to others
+	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("%s:%s", digest, path)})
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeersFallsBackToStore(c *C) {
+	storeContent := []byte("snap blob from the store")
+	digest := sha3_384Hex(storeContent)
+
+	for _, tc := range []struct {
+		peers  *fakePeerSource
+		logMsg string
+	}{{
+		peers:  &fakePeerSource{err: errors.New("no peer has the blob")},
+		logMsg: "Cannot fetch foo from peers: no peer has the blob",
+	}, {
+		peers:  &fakePeerSource{content: []byte("snap blob from the peers")},
+		logMsg: `Cannot fetch foo from peers: sha3-384 mismatch for "foo": .*`,
+	}, {
+		peers:  &fakePeerSource{content: []byte("short"), size: int64(len(storeContent))},
+		logMsg: fmt.Sprintf(`Cannot fetch foo from peers: size mismatch for "foo": got 5 but expected %d`, len(storeContent)),
+	}, {
+		peers:  &fakePeerSource{content: storeContent, size: 3},
+		logMsg: fmt.Sprintf(`Cannot fetch foo from peers: size mismatch for "foo": got 3 but expected %d`, len(storeContent)),
+	}} {
+		s.logbuf.Reset()
+
+		downloadWasCalled := false
+		restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+			downloadWasCalled = true
+			c.Check(resume, Equals, int64(0))
+			_, err := w.Write(storeContent)
+			return err
+		})
+		defer restore()
+
+		s.store.SetPeerSource(tc.peers)
+
+		info := &snap.DownloadInfo{
+			Sha3_384:    digest,
+			Size:        int64(len(storeContent)),
+			DownloadURL: "URL",
+		}
+		path := filepath.Join(c.MkDir(), "downloaded-file")
+		err := s.store.Download(s.ctx, "foo", path, info, nil, nil, nil)
+		c.Assert(err, IsNil)
+
+		c.Check(tc.peers.requested, DeepEquals, []string{digest})
+		c.Check(downloadWasCalled, Equals, true)
+		c.Check(path, testutil.FileEquals, storeContent)
+		c.Check(path+".peer.partial", testutil.FileAbsent)
+		c.Check(s.logbuf.String(), Matches, fmt.Sprintf("(?s).*%s\n.*", tc.logMsg))
+	}
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeersCacheHitFirst(c *C) {
+	obs := &cacheObserver{inCache: map[string]bool{"the-snaps-sha3_384": true}}
+	restore := s.store.MockCacher(obs)
+	defer restore()
+
+	peers := &fakePeerSource{err: errors.New("unexpected")}
+	s.store.SetPeerSource(peers)
+
+	info := &snap.DownloadInfo{Sha3_384: "the-snaps-sha3_384"}
+	obs.data = map[string][]byte{"the-snaps-sha3_384": []byte("cached")}
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := s.store.Download(s.ctx, "foo", path, info, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(peers.requested, HasLen, 0)
+}
+
+func (s *storeDownloadSuite) TestOpenCachedBlob(c *C) {
+	obs := &cacheObserver{inCache: map[string]bool{"the-snaps-sha3_384": true}}
+	restore := s.store.MockCacher(obs)
+	defer restore()
+
+	f, size, err := s.store.OpenCachedBlob("the-snaps-sha3_384")
+	c.Assert(err, IsNil)
+	defer f.Close()
+	c.Check(size, Equals, int64(len("content")))
+	data, err := io.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "content")
+
+	_, _, err = s.store.OpenCachedBlob("other")
+	c.Check(err, ErrorMatches, "not found in cache")
+}
@@ -0,0 +1,48 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"net/url"
+)
+
+// APIBaseURL returns the base URL of the store API used by this store,
+// taking into account any configured proxy store.
+func (s *Store) APIBaseURL() *url.URL {
+	return s.baseURL(s.cfg.StoreBaseURL)
+}
+
+// AssertionsBaseURL returns the base URL of the assertions service used by
+// this store, which may be overridden separately from the store API.
+func (s *Store) AssertionsBaseURL() *url.URL {
+	defBaseURL := s.cfg.StoreBaseURL
+	// can be overridden separately!
+	if s.cfg.AssertionsBaseURL != nil {
+		defBaseURL = s.cfg.AssertionsBaseURL
+	}
+	return s.baseURL(defBaseURL)
+}
+
+// CacheBlob adds the file at the given path, whose content must have the
+// given sha3-384 digest, to the downloads cache, so that it can be served
+// to other devices and used for local downloads.
+func (s *Store) CacheBlob(sha3_384, path string) error {
+	return s.cacher.Put(sha3_384, path)
+}
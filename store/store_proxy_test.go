@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/store"
+)
+
+func (s *storeTestSuite) TestAPIBaseURLs(c *C) {
+	storeURL, err := url.Parse("https://store.example.com/")
+	c.Assert(err, IsNil)
+	sasURL, err := url.Parse("https://sas.example.com/")
+	c.Assert(err, IsNil)
+	proxyURL, err := url.Parse("https://proxy.example.com/")
+	c.Assert(err, IsNil)
+
+	cfg := store.DefaultConfig()
+	cfg.StoreBaseURL = storeURL
+	sto := store.New(cfg, &testDauthContext{c: c, device: s.device})
+	c.Check(sto.APIBaseURL().String(), Equals, "https://store.example.com/")
+	c.Check(sto.AssertionsBaseURL().String(), Equals, "https://store.example.com/")
+
+	cfg.AssertionsBaseURL = sasURL
+	sto = store.New(cfg, &testDauthContext{c: c, device: s.device})
+	c.Check(sto.APIBaseURL().String(), Equals, "https://store.example.com/")
+	c.Check(sto.AssertionsBaseURL().String(), Equals, "https://sas.example.com/")
+
+	// a proxy store overrides both
+	sto = store.New(cfg, &testDauthContext{
+		c:             c,
+		device:        s.device,
+		proxyStoreID:  "foo",
+		proxyStoreURL: proxyURL,
+	})
+	c.Check(sto.APIBaseURL().String(), Equals, "https://proxy.example.com/")
+	c.Check(sto.AssertionsBaseURL().String(), Equals, "https://proxy.example.com/")
+}
+
+func (s *storeDownloadSuite) TestCacheBlob(c *C) {
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	restore := s.store.MockCacher(obs)
+	defer restore()
+
+	path := filepath.Join(c.MkDir(), "blob")
+	c.Assert(os.WriteFile(path, []byte("content"), 0644), IsNil)
+
+	err := s.store.CacheBlob("the-snaps-sha3_384", path)
+	c.Assert(err, IsNil)
+	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("%s:%s", "the-snaps-sha3_384", path)})
+}
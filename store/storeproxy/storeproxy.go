@@ -0,0 +1,587 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package storeproxy implements a read-through caching proxy of the store
+// API, which lets snapd serve the snaps, components and assertions it has
+// already downloaded to other devices.
+//
+// Requests to the store API are forwarded to the upstream store, and the
+// download URLs in its responses are rewritten to point at the proxy. Those
+// URLs carry a token binding them to the client they were handed to, so that
+// a client can only download the blobs the store let it see, and not for
+// instance the private snaps of other clients. Blobs are then served from the
+// downloads cache, or fetched from upstream, verified and added to the cache
+// on first use. Assertions which never
+// change once issued are served from the local assertion database when
+// possible.
+package storeproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+const (
+	blobsPath      = "/blobs/"
+	assertionsPath = "/v2/assertions/"
+
+	assertionMediaType = "application/x.ubuntu.assertion"
+)
+
+// maxKnownDownloads bounds the number of upstream download URLs which are
+// remembered from rewritten responses.
+var maxKnownDownloads = 10000
+
+var validDigest = regexp.MustCompile("^[0-9a-f]{96}$")
+
+// cacheableAssertionTypes are the types of assertions which can be served from
+// the local assertion database, as their content never changes for a given
+// primary key.
+var cacheableAssertionTypes = map[*asserts.AssertionType]bool{
+	asserts.AccountKeyType:           true,
+	asserts.SnapRevisionType:         true,
+	asserts.SnapResourceRevisionType: true,
+}
+
+// BlobCache is the cache of snap and component blobs served by the proxy.
+type BlobCache interface {
+	// OpenCachedBlob opens the blob with the given sha3-384 digest. Returns
+	// an error satisfying errors.Is(err, fs.ErrNotExist) if there is no such
+	// blob.
+	OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error)
+	// CacheBlob adds the verified blob at the given path to the cache.
+	CacheBlob(sha3_384, path string) error
+}
+
+// AssertionSource provides assertions which are already known locally.
+type AssertionSource interface {
+	// FindAssertion finds the assertion of the given type with the given
+	// primary key headers. Returns an error satisfying
+	// errors.Is(err, &asserts.NotFoundError{}) if there is no such
+	// assertion.
+	FindAssertion(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error)
+}
+
+// Config holds the configuration of a Proxy.
+type Config struct {
+	// StoreURL is the base URL of the upstream store API.
+	StoreURL *url.URL
+	// AssertionsURL is the base URL of the upstream assertions service.
+	// Defaults to StoreURL.
+	AssertionsURL *url.URL
+
+	// Blobs is the cache from which blobs are served, and to which blobs
+	// fetched from upstream are added.
+	Blobs BlobCache
+	// Assertions optionally provides assertions which are known locally.
+	Assertions AssertionSource
+
+	// TempDir is the directory in which blobs are downloaded before being
+	// added to the cache. It should be on the same filesystem as the cache.
+	TempDir string
+	// Transport is used for requests to upstream. Defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+
+	// AllowedClients are the networks from which clients can use the
+	// proxy. If empty, any client can use it.
+	AllowedClients []*net.IPNet
+	// TokenKey is the key with which the download URLs handed to clients
+	// are signed. Defaults to a random key, in which case the URLs are
+	// only valid for the lifetime of the proxy.
+	TokenKey []byte
+}
+
+type upstreamBlob struct {
+	url  string
+	size int64
+}
+
+// Proxy is an HTTP handler proxying the store API.
+type Proxy struct {
+	cfg Config
+
+	client          *http.Client
+	storeProxy      *httputil.ReverseProxy
+	assertionsProxy *httputil.ReverseProxy
+
+	mu sync.Mutex
+	// downloads maps the digests of blobs to where they can be fetched from
+	// upstream, as learned from the responses of the store
+	downloads map[string]upstreamBlob
+	// downloadsOrder is the order in which downloads were learned, so that
+	// the oldest are forgotten first
+	downloadsOrder []string
+	// inflight holds channels which are closed when the ongoing fetch of
+	// the blob with the given digest from upstream completes
+	inflight map[string]chan struct{}
+}
+
+// New returns a proxy with the given configuration.
+func New(cfg Config) *Proxy {
+	if cfg.AssertionsURL == nil {
+		cfg.AssertionsURL = cfg.StoreURL
+	}
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	if len(cfg.TokenKey) == 0 {
+		cfg.TokenKey = NewTokenKey()
+	}
+	p := &Proxy{
+		cfg:       cfg,
+		client:    &http.Client{Transport: cfg.Transport},
+		downloads: make(map[string]upstreamBlob),
+		inflight:  make(map[string]chan struct{}),
+	}
+	p.storeProxy = p.reverseProxy(cfg.StoreURL)
+	p.storeProxy.ModifyResponse = p.rewriteResponse
+	p.assertionsProxy = p.reverseProxy(cfg.AssertionsURL)
+	return p
+}
+
+// NewTokenKey returns a new random key for signing download URLs.
+func NewTokenKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("cannot generate token key: %v", err))
+	}
+	return key
+}
+
+// ParseAllowedClients parses a comma-separated list of IP addresses and
+// networks in CIDR notation.
+func ParseAllowedClients(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q", entry)
+			}
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+func (p *Proxy) reverseProxy(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.URL.Path = path.Join("/", target.Path, req.URL.Path)
+			req.URL.RawPath = ""
+			req.Host = target.Host
+			// responses are rewritten, so they must not be compressed
+			req.Header.Del("Accept-Encoding")
+		},
+		Transport: p.cfg.Transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Noticef("cannot proxy %s %s to the store: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "cannot reach the store", http.StatusBadGateway)
+		},
+	}
+}
+
+type proxyRequestKey struct{}
+
+// proxyRequest describes the client request which led to a request to the
+// store.
+type proxyRequest struct {
+	// base is the base URL of the proxy as used by the client
+	base string
+	// client is the address of the client
+	client string
+}
+
+// clientAddress returns the IP address of the client of the request.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (p *Proxy) clientAllowed(client string) bool {
+	if len(p.cfg.AllowedClients) == 0 {
+		return true
+	}
+	ip := net.ParseIP(client)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range p.cfg.AllowedClients {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client := clientAddress(r)
+	if !p.clientAllowed(client) {
+		http.Error(w, "client not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, blobsPath):
+		p.serveBlob(w, r)
+	case strings.HasPrefix(r.URL.Path, assertionsPath):
+		if p.serveLocalAssertion(w, r) {
+			return
+		}
+		p.assertionsProxy.ServeHTTP(w, r)
+	default:
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		preq := proxyRequest{
+			base:   scheme + "://" + r.Host,
+			client: client,
+		}
+		ctx := context.WithValue(r.Context(), proxyRequestKey{}, preq)
+		p.storeProxy.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// rewriteResponse rewrites the download URLs in the JSON responses of the
+// store to point at the proxy.
+func (p *Proxy) rewriteResponse(resp *http.Response) error {
+	preq, ok := resp.Request.Context().Value(proxyRequestKey{}).(proxyRequest)
+	if !ok {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err == nil && p.rewriteDownloads(doc, preq) {
+		if rewritten, err := json.Marshal(doc); err == nil {
+			data = rewritten
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// rewriteDownloads replaces the URL of any download described in the given
+// decoded JSON document, identified by having both "url" and "sha3-384"
+// fields, with the URL at which the proxy serves the blob to the client.
+// Returns whether anything was rewritten.
+func (p *Proxy) rewriteDownloads(doc any, preq proxyRequest) bool {
+	rewritten := false
+	switch v := doc.(type) {
+	case map[string]any:
+		upstreamURL, urlOk := v["url"].(string)
+		digest, digestOk := v["sha3-384"].(string)
+		if urlOk && digestOk && validDigest.MatchString(digest) && upstreamURL != "" {
+			var size int64
+			if n, ok := v["size"].(json.Number); ok {
+				size, _ = n.Int64()
+			}
+			p.rememberDownload(digest, upstreamBlob{url: upstreamURL, size: size})
+			v["url"] = preq.base + blobsPath + digest + "?token=" + p.blobToken(digest, preq.client)
+			rewritten = true
+		}
+		for _, value := range v {
+			if p.rewriteDownloads(value, preq) {
+				rewritten = true
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if p.rewriteDownloads(value, preq) {
+				rewritten = true
+			}
+		}
+	}
+	return rewritten
+}
+
+func (p *Proxy) rememberDownload(digest string, blob upstreamBlob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.downloads[digest]; !ok {
+		p.downloadsOrder = append(p.downloadsOrder, digest)
+	}
+	p.downloads[digest] = blob
+	for len(p.downloadsOrder) > maxKnownDownloads {
+		delete(p.downloads, p.downloadsOrder[0])
+		p.downloadsOrder = p.downloadsOrder[1:]
+	}
+}
+
+// blobToken returns the token authorizing the client with the given address
+// to download the blob with the given digest.
+func (p *Proxy) blobToken(digest, client string) string {
+	mac := hmac.New(sha256.New, p.cfg.TokenKey)
+	mac.Write([]byte(digest))
+	mac.Write([]byte{0})
+	mac.Write([]byte(client))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Proxy) validBlobToken(digest, client, token string) bool {
+	got, err := hex.DecodeString(token)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(p.blobToken(digest, client))
+	return hmac.Equal(got, want)
+}
+
+var errUnknownBlob = errors.New("unknown blob")
+
+func (p *Proxy) serveBlob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	digest := strings.TrimPrefix(r.URL.Path, blobsPath)
+	if !validDigest.MatchString(digest) {
+		http.Error(w, "invalid sha3-384 digest", http.StatusBadRequest)
+		return
+	}
+	// only blobs whose download URL was handed to the client are served,
+	// as the store may have let other clients see blobs it cannot see
+	if !p.validBlobToken(digest, clientAddress(r), r.URL.Query().Get("token")) {
+		http.Error(w, "invalid download token", http.StatusForbidden)
+		return
+	}
+
+	f, err := p.openBlob(r.Context(), digest)
+	if errors.Is(err, errUnknownBlob) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Noticef("cannot provide blob %s to %s: %v", digest, r.RemoteAddr, err)
+		http.Error(w, "cannot fetch blob from the store", http.StatusBadGateway)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	// the content of a blob never changes, so the modification time is
+	// irrelevant
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// openBlob opens the blob with the given digest from the cache, fetching it
+// from upstream first if needed.
+func (p *Proxy) openBlob(ctx context.Context, digest string) (io.ReadSeekCloser, error) {
+	f, _, err := p.cfg.Blobs.OpenCachedBlob(digest)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+
+	p.mu.Lock()
+	ch := p.inflight[digest]
+	p.mu.Unlock()
+	if ch != nil {
+		// another request is already fetching the blob, wait for it to be
+		// added to the cache
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		f, _, err := p.cfg.Blobs.OpenCachedBlob(digest)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+
+	return p.fetchBlob(ctx, digest)
+}
+
+// fetchBlob fetches the blob with the given digest from upstream, verifies
+// it, and adds it to the cache. It returns the fetched blob, which remains
+// readable even if the cache is disabled.
+func (p *Proxy) fetchBlob(ctx context.Context, digest string) (f *os.File, err error) {
+	p.mu.Lock()
+	blob, ok := p.downloads[digest]
+	if !ok {
+		p.mu.Unlock()
+		return nil, errUnknownBlob
+	}
+	if _, ok := p.inflight[digest]; !ok {
+		ch := make(chan struct{})
+		p.inflight[digest] = ch
+		defer func() {
+			p.mu.Lock()
+			delete(p.inflight, digest)
+			p.mu.Unlock()
+			close(ch)
+		}()
+	}
+	p.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", blob.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", snapdenv.UserAgent())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q", resp.Status)
+	}
+
+	f, err = os.CreateTemp(p.cfg.TempDir, ".store-proxy-")
+	if err != nil {
+		return nil, err
+	}
+	// the blob remains readable through the open file once removed
+	defer os.Remove(f.Name())
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+
+	var r io.Reader = resp.Body
+	if blob.size > 0 {
+		r = io.LimitReader(resp.Body, blob.size+1)
+	}
+	h := crypto.SHA3_384.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return nil, err
+	}
+	if blob.size > 0 && n != blob.size {
+		return nil, fmt.Errorf("size mismatch: got %d but expected %d", n, blob.size)
+	}
+	if actual := fmt.Sprintf("%x", h.Sum(nil)); actual != digest {
+		return nil, fmt.Errorf("sha3-384 mismatch: got %s but expected %s", actual, digest)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := p.cfg.Blobs.CacheBlob(digest, f.Name()); err != nil {
+		// the blob can still be served this time
+		logger.Noticef("cannot cache blob %s: %v", digest, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// serveLocalAssertion serves the requested assertion from the local assertion
+// source if possible, returning whether it did so.
+func (p *Proxy) serveLocalAssertion(w http.ResponseWriter, r *http.Request) bool {
+	if p.cfg.Assertions == nil || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, assertionsPath), "/")
+	assertType := asserts.Type(parts[0])
+	if assertType == nil || !cacheableAssertionTypes[assertType] {
+		return false
+	}
+	primaryKey := make([]string, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		keyVal, err := url.PathUnescape(part)
+		if err != nil {
+			return false
+		}
+		primaryKey = append(primaryKey, keyVal)
+	}
+	headers, err := asserts.HeadersFromPrimaryKey(assertType, primaryKey)
+	if err != nil {
+		return false
+	}
+	a, err := p.cfg.Assertions.FindAssertion(assertType, headers)
+	if err != nil {
+		if !errors.Is(err, &asserts.NotFoundError{}) {
+			logger.Noticef("cannot find %s assertion %v: %v", assertType.Name, primaryKey, err)
+		}
+		return false
+	}
+	if maxFormat := r.URL.Query().Get("max-format"); maxFormat != "" {
+		max, err := strconv.Atoi(maxFormat)
+		if err != nil || a.Format() > max {
+			return false
+		}
+	}
+
+	w.Header().Set("Content-Type", assertionMediaType)
+	w.Write(asserts.Encode(a))
+	return true
+}
@@ -0,0 +1,406 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package storeproxy_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/store/storeproxy"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+type fakeCache struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeCache) OpenCachedBlob(sha3_384 string) (io.ReadSeekCloser, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.blobs[sha3_384]
+	if !ok {
+		return nil, 0, fs.ErrNotExist
+	}
+	return nopSeekCloser{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+func (f *fakeCache) CacheBlob(sha3_384, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blobs[sha3_384] = data
+	return nil
+}
+
+type fakeAssertions map[string]asserts.Assertion
+
+func (f fakeAssertions) FindAssertion(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	primaryKey, err := asserts.PrimaryKeyFromHeaders(assertType, headers)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := f[assertType.Name+"/"+strings.Join(primaryKey, "/")]
+	if !ok {
+		return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+	}
+	return a, nil
+}
+
+func sha3_384Hex(data []byte) string {
+	h := crypto.SHA3_384.New()
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+type storeProxySuite struct {
+	upstream *httptest.Server
+	p        *storeproxy.Proxy
+	proxy    *httptest.Server
+
+	cache      *fakeCache
+	assertions fakeAssertions
+
+	blob       []byte
+	blobDigest string
+
+	// served by upstream as the content of the blob
+	upstreamBlob []byte
+
+	downloads  int
+	assertReqs []string
+	headers    http.Header
+}
+
+var _ = check.Suite(&storeProxySuite{})
+
+func (s *storeProxySuite) SetUpTest(c *check.C) {
+	s.blob = []byte("snap blob content")
+	s.blobDigest = sha3_384Hex(s.blob)
+	s.upstreamBlob = s.blob
+	s.downloads = 0
+	s.assertReqs = nil
+	s.headers = nil
+
+	s.upstream = httptest.NewServer(http.HandlerFunc(s.serveUpstream))
+	s.cache = &fakeCache{blobs: make(map[string][]byte)}
+	s.assertions = make(fakeAssertions)
+
+	upstreamURL, err := url.Parse(s.upstream.URL + "/api")
+	c.Assert(err, check.IsNil)
+	s.p = storeproxy.New(storeproxy.Config{
+		StoreURL:   upstreamURL,
+		Blobs:      s.cache,
+		Assertions: s.assertions,
+		TempDir:    c.MkDir(),
+	})
+	s.proxy = httptest.NewServer(s.p)
+}
+
+func (s *storeProxySuite) TearDownTest(c *check.C) {
+	s.proxy.Close()
+	s.upstream.Close()
+}
+
+func (s *storeProxySuite) serveUpstream(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/api/v2/snaps/refresh":
+		s.headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"results": [{"name": "foo", "snap": {"download": {"url": "%s/download/foo_1.snap", "sha3-384": "%s", "size": %d, "deltas": []}}}]}`,
+			s.upstream.URL, s.blobDigest, len(s.blob))
+	case r.URL.Path == "/download/foo_1.snap":
+		s.downloads++
+		w.Write(s.upstreamBlob)
+	case strings.HasPrefix(r.URL.Path, "/api/v2/assertions/"):
+		s.assertReqs = append(s.assertReqs, strings.TrimPrefix(r.URL.Path, "/api/v2/assertions/"))
+		w.Header().Set("Content-Type", "application/x.ubuntu.assertion")
+		io.WriteString(w, "upstream assertion")
+	case r.URL.Path == "/api/v2/snaps/info/foo":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "not json")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *storeProxySuite) get(c *check.C, path string) (int, []byte) {
+	resp, err := http.Get(s.proxy.URL + path)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+	return resp.StatusCode, body
+}
+
+func (s *storeProxySuite) refresh(c *check.C) string {
+	req, err := http.NewRequest("POST", s.proxy.URL+"/v2/snaps/refresh", strings.NewReader(`{}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Snap-Device-Authorization", `Macaroon root="device-macaroon"`)
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, 200)
+
+	var result struct {
+		Results []struct {
+			Snap struct {
+				Download struct {
+					URL      string `json:"url"`
+					Sha3_384 string `json:"sha3-384"`
+					Size     int64  `json:"size"`
+				} `json:"download"`
+			} `json:"snap"`
+		} `json:"results"`
+	}
+	c.Assert(json.NewDecoder(resp.Body).Decode(&result), check.IsNil)
+	c.Assert(result.Results, check.HasLen, 1)
+	download := result.Results[0].Snap.Download
+	c.Check(download.Sha3_384, check.Equals, s.blobDigest)
+	c.Check(download.Size, check.Equals, int64(len(s.blob)))
+	return download.URL
+}
+
+func (s *storeProxySuite) TestRefreshRewritesDownloadURLs(c *check.C) {
+	downloadURL := s.refresh(c)
+	c.Check(downloadURL, check.Matches, s.proxy.URL+"/blobs/"+s.blobDigest+`\?token=[0-9a-f]{64}`)
+	// the request is forwarded with its headers
+	c.Check(s.headers.Get("Snap-Device-Authorization"), check.Equals, `Macaroon root="device-macaroon"`)
+}
+
+func (s *storeProxySuite) TestNonJSONPassthrough(c *check.C) {
+	status, body := s.get(c, "/v2/snaps/info/foo")
+	c.Check(status, check.Equals, 200)
+	c.Check(string(body), check.Equals, "not json")
+
+	status, _ = s.get(c, "/v2/snaps/info/bar")
+	c.Check(status, check.Equals, 404)
+}
+
+func (s *storeProxySuite) TestBlobReadThrough(c *check.C) {
+	downloadURL := s.refresh(c)
+	path := strings.TrimPrefix(downloadURL, s.proxy.URL)
+
+	status, body := s.get(c, path)
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.DeepEquals, s.blob)
+	c.Check(s.downloads, check.Equals, 1)
+	c.Check(s.cache.blobs[s.blobDigest], check.DeepEquals, s.blob)
+
+	// the second time, the blob is served from the cache
+	status, body = s.get(c, path)
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.DeepEquals, s.blob)
+	c.Check(s.downloads, check.Equals, 1)
+}
+
+func (s *storeProxySuite) TestBlobFromCache(c *check.C) {
+	s.cache.blobs[s.blobDigest] = []byte("cached")
+	downloadURL := s.refresh(c)
+
+	status, body := s.get(c, strings.TrimPrefix(downloadURL, s.proxy.URL))
+	c.Check(status, check.Equals, 200)
+	c.Check(string(body), check.Equals, "cached")
+	c.Check(s.downloads, check.Equals, 0)
+}
+
+func (s *storeProxySuite) TestBlobVerified(c *check.C) {
+	s.upstreamBlob = []byte("snap blob CONTENT")
+	downloadURL := s.refresh(c)
+	path := strings.TrimPrefix(downloadURL, s.proxy.URL)
+
+	status, _ := s.get(c, path)
+	c.Check(status, check.Equals, 502)
+	c.Check(s.cache.blobs, check.HasLen, 0)
+
+	s.upstreamBlob = []byte("short")
+	status, _ = s.get(c, path)
+	c.Check(status, check.Equals, 502)
+	c.Check(s.cache.blobs, check.HasLen, 0)
+}
+
+func (s *storeProxySuite) TestBlobErrors(c *check.C) {
+	// blobs are only served to clients which were handed their download URL
+	s.cache.blobs[s.blobDigest] = s.blob
+	status, body := s.get(c, "/blobs/"+s.blobDigest)
+	c.Check(status, check.Equals, 403)
+	c.Check(string(body), check.Equals, "invalid download token\n")
+	status, _ = s.get(c, "/blobs/"+s.blobDigest+"?token="+strings.Repeat("0", 64))
+	c.Check(status, check.Equals, 403)
+	status, _ = s.get(c, "/blobs/"+s.blobDigest+"?token=garbage")
+	c.Check(status, check.Equals, 403)
+	c.Check(s.downloads, check.Equals, 0)
+
+	status, body = s.get(c, "/blobs/../../etc/passwd")
+	c.Check(status, check.Equals, 400)
+	c.Check(string(body), check.Equals, "invalid sha3-384 digest\n")
+
+	resp, err := http.Post(s.proxy.URL+"/blobs/"+s.blobDigest, "text/plain", nil)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, check.Equals, 405)
+}
+
+func (s *storeProxySuite) TestBlobTokenBoundToClient(c *check.C) {
+	downloadURL := s.refresh(c)
+	path := strings.TrimPrefix(downloadURL, s.proxy.URL)
+
+	// the download URL handed to a client cannot be used by another one
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	s.p.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 403)
+	c.Check(s.downloads, check.Equals, 0)
+
+	// nor for another blob
+	otherPath := strings.Replace(path, s.blobDigest, strings.Repeat("a", 96), 1)
+	status, _ := s.get(c, otherPath)
+	c.Check(status, check.Equals, 403)
+
+	status, _ = s.get(c, path)
+	c.Check(status, check.Equals, 200)
+}
+
+func (s *storeProxySuite) TestAllowedClients(c *check.C) {
+	upstreamURL, err := url.Parse(s.upstream.URL + "/api")
+	c.Assert(err, check.IsNil)
+	allowed, err := storeproxy.ParseAllowedClients("10.0.0.0/8, 192.0.2.7")
+	c.Assert(err, check.IsNil)
+	p := storeproxy.New(storeproxy.Config{
+		StoreURL:       upstreamURL,
+		Blobs:          s.cache,
+		TempDir:        c.MkDir(),
+		AllowedClients: allowed,
+	})
+
+	for _, tc := range []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"10.1.2.3:1234", 200},
+		{"192.0.2.7:1234", 200},
+		{"192.0.2.8:1234", 403},
+		{"[2001:db8::1]:1234", 403},
+	} {
+		req := httptest.NewRequest("GET", "/v2/snaps/info/foo", nil)
+		req.RemoteAddr = tc.remoteAddr
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, tc.status, check.Commentf("%s", tc.remoteAddr))
+	}
+}
+
+func (s *storeProxySuite) TestParseAllowedClients(c *check.C) {
+	nets, err := storeproxy.ParseAllowedClients("")
+	c.Assert(err, check.IsNil)
+	c.Check(nets, check.HasLen, 0)
+
+	nets, err = storeproxy.ParseAllowedClients("192.168.1.0/24,10.0.0.1, fd00::/8,::1")
+	c.Assert(err, check.IsNil)
+	var strs []string
+	for _, n := range nets {
+		strs = append(strs, n.String())
+	}
+	c.Check(strs, check.DeepEquals, []string{"192.168.1.0/24", "10.0.0.1/32", "fd00::/8", "::1/128"})
+	c.Check(nets[1].Contains(net.ParseIP("10.0.0.1")), check.Equals, true)
+
+	_, err = storeproxy.ParseAllowedClients("10.0.0.0/33")
+	c.Check(err, check.ErrorMatches, `invalid network "10.0.0.0/33"`)
+	_, err = storeproxy.ParseAllowedClients("10.0.0.0,foo")
+	c.Check(err, check.ErrorMatches, `invalid IP address "foo"`)
+}
+
+func (s *storeProxySuite) TestKnownDownloadsBounded(c *check.C) {
+	restore := storeproxy.MockMaxKnownDownloads(0)
+	defer restore()
+
+	downloadURL := s.refresh(c)
+	path := strings.TrimPrefix(downloadURL, s.proxy.URL)
+	status, _ := s.get(c, path)
+	c.Check(status, check.Equals, 404)
+}
+
+func (s *storeProxySuite) TestAssertions(c *check.C) {
+	storeStack := assertstest.NewStoreStack("canonical", nil)
+	digest := strings.Repeat("b", 64)
+	snapRev, err := storeStack.Sign(asserts.SnapRevisionType, map[string]any{
+		"snap-id":       "foo-id",
+		"snap-sha3-384": digest,
+		"snap-size":     "123",
+		"snap-revision": "1",
+		"developer-id":  "canonical",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	s.assertions["snap-revision/"+digest+"/global-upload"] = snapRev
+
+	// served locally, the default provenance is omitted by clients
+	status, body := s.get(c, "/v2/assertions/snap-revision/"+digest)
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.DeepEquals, asserts.Encode(snapRev))
+	status, body = s.get(c, "/v2/assertions/snap-revision/"+digest+"/global-upload?max-format=0")
+	c.Check(status, check.Equals, 200)
+	c.Check(body, check.DeepEquals, asserts.Encode(snapRev))
+	c.Check(s.assertReqs, check.HasLen, 0)
+
+	// unknown assertions are fetched from upstream
+	other := strings.Repeat("c", 64)
+	status, body = s.get(c, "/v2/assertions/snap-revision/"+other)
+	c.Check(status, check.Equals, 200)
+	c.Check(string(body), check.Equals, "upstream assertion")
+
+	// and so are assertions which can change
+	status, body = s.get(c, "/v2/assertions/snap-declaration/16/foo-id")
+	c.Check(status, check.Equals, 200)
+	c.Check(string(body), check.Equals, "upstream assertion")
+
+	c.Check(s.assertReqs, check.DeepEquals, []string{
+		"snap-revision/" + other,
+		"snap-declaration/16/foo-id",
+	})
+}
@@ -499,6 +499,14 @@ type Systemd interface {
 	// threads if enabled, etc) part of the unit, which can be a service or a
 	// slice.
 	CurrentTasksCount(unit string) (uint64, error)
+	// CurrentCPUUsage returns the total CPU time consumed by the specified
+	// unit since it was started.
+	CurrentCPUUsage(unit string) (time.Duration, error)
+	// CurrentNetworkUsage returns the number of bytes received and sent
+	// over IP by the specified unit since it was started. It returns
+	// ErrNetworkAccountingUnavailable if IP accounting is not enabled for
+	// the unit or not supported by the system.
+	CurrentNetworkUsage(unit string) (ingress, egress quantity.Size, err error)
 	// Run a command
 	Run(command []string, opts *RunOptions) ([]byte, error)
 	// Set log level for the system
@@ -1000,6 +1008,51 @@ func (s *systemd) CurrentMemoryUsage(unit string) (quantity.Size, error) {
 	return quantity.Size(memBytes), nil
 }
 
+func (s *systemd) CurrentCPUUsage(unit string) (time.Duration, error) {
+	cpuNSec, err := s.getPropertyUintValue(unit, "CPUUsageNSec")
+	if err != nil && err != errNotSet {
+		return 0, err
+	}
+
+	if err == errNotSet {
+		return 0, fmt.Errorf("cpu usage unavailable")
+	}
+
+	return time.Duration(cpuNSec), nil
+}
+
+// ErrNetworkAccountingUnavailable is returned by CurrentNetworkUsage when the
+// IP accounting of a unit is not available, IP accounting requires systemd
+// 235 and a kernel with eBPF cgroup support.
+var ErrNetworkAccountingUnavailable = errors.New("network accounting unavailable")
+
+func (s *systemd) CurrentNetworkUsage(unit string) (ingress, egress quantity.Size, err error) {
+	for _, prop := range []struct {
+		key   string
+		value *quantity.Size
+	}{
+		{"IPIngressBytes", &ingress},
+		{"IPEgressBytes", &egress},
+	} {
+		out, err := s.systemctl("show", "--property", prop.key, unit)
+		if err != nil {
+			return 0, 0, osutil.OutputErr(out, err)
+		}
+		// older systemd versions do not know about the property and print
+		// nothing, "[no data]" is reported when the accounting is not enabled
+		valStr := strings.TrimPrefix(strings.TrimSpace(string(out)), prop.key+"=")
+		if valStr == "" || valStr == "[not set]" || valStr == "[no data]" {
+			return 0, 0, ErrNetworkAccountingUnavailable
+		}
+		bytes, err := strconv.ParseUint(valStr, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid property value from systemd for %s: cannot parse %q as an integer", prop.key, valStr)
+		}
+		*prop.value = quantity.Size(bytes)
+	}
+	return ingress, egress, nil
+}
+
 func (s *systemd) InactiveEnterTimestamp(unit string) (time.Time, error) {
 	timeStr, err := s.getPropertyStringValue(unit, "InactiveEnterTimestamp")
 	if err != nil {
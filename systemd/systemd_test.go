@@ -3261,6 +3261,58 @@ func (s *SystemdTestSuite) TestCurrentUsageFamilyHappy(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestCurrentCPUUsage(c *C) {
+	s.outs = [][]byte{
+		[]byte(`CPUUsageNSec=1500000000`),
+		[]byte(`CPUUsageNSec=[not set]`),
+		[]byte(`CPUUsageNSec=blah`),
+	}
+	sysd := New(SystemMode, s.rep)
+	cpuUsage, err := sysd.CurrentCPUUsage("bar.slice")
+	c.Assert(err, IsNil)
+	c.Check(cpuUsage, Equals, 1500*time.Millisecond)
+	_, err = sysd.CurrentCPUUsage("bar.slice")
+	c.Check(err, ErrorMatches, "cpu usage unavailable")
+	_, err = sysd.CurrentCPUUsage("bar.slice")
+	c.Check(err, ErrorMatches, `invalid property value from systemd for CPUUsageNSec: cannot parse "blah" as an integer`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+		{"show", "--property", "CPUUsageNSec", "bar.slice"},
+	})
+}
+
+func (s *SystemdTestSuite) TestCurrentNetworkUsage(c *C) {
+	s.outs = [][]byte{
+		[]byte(`IPIngressBytes=2048`),
+		[]byte(`IPEgressBytes=1024`),
+		[]byte(`IPIngressBytes=[no data]`),
+		[]byte(``),
+		[]byte(`IPIngressBytes=1`),
+		[]byte(`IPEgressBytes=blah`),
+	}
+	sysd := New(SystemMode, s.rep)
+	ingress, egress, err := sysd.CurrentNetworkUsage("bar.slice")
+	c.Assert(err, IsNil)
+	c.Check(ingress, Equals, quantity.Size(2048))
+	c.Check(egress, Equals, quantity.Size(1024))
+	_, _, err = sysd.CurrentNetworkUsage("bar.slice")
+	c.Check(err, Equals, ErrNetworkAccountingUnavailable)
+	// systemd too old to know about ip accounting
+	_, _, err = sysd.CurrentNetworkUsage("bar.slice")
+	c.Check(err, Equals, ErrNetworkAccountingUnavailable)
+	_, _, err = sysd.CurrentNetworkUsage("bar.slice")
+	c.Check(err, ErrorMatches, `invalid property value from systemd for IPEgressBytes: cannot parse "blah" as an integer`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property", "IPIngressBytes", "bar.slice"},
+		{"show", "--property", "IPEgressBytes", "bar.slice"},
+		{"show", "--property", "IPIngressBytes", "bar.slice"},
+		{"show", "--property", "IPIngressBytes", "bar.slice"},
+		{"show", "--property", "IPIngressBytes", "bar.slice"},
+		{"show", "--property", "IPEgressBytes", "bar.slice"},
+	})
+}
+
 func (s *SystemdTestSuite) TestInactiveEnterTimestampZero(c *C) {
 	s.outs = [][]byte{
 		[]byte(`InactiveEnterTimestamp=`),
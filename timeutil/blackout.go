@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateRangeToken = ".."
+	lastWeekToken  = "last-week"
+
+	// maxBlackoutDays is the longest period of consecutive days which can
+	// be blacked out.
+	maxBlackoutDays = 366
+)
+
+type blackoutKind int
+
+const (
+	blackoutDates blackoutKind = iota
+	blackoutYearly
+	blackoutLastWeek
+	blackoutWeekSpan
+)
+
+// date is a day of the calendar, with a zero Year for a day which recurs
+// every year.
+type date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+func dateOf(t time.Time) date {
+	return date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+}
+
+func (d date) before(other date) bool {
+	if d.Year != other.Year {
+		return d.Year < other.Year
+	}
+	if d.Month != other.Month {
+		return d.Month < other.Month
+	}
+	return d.Day < other.Day
+}
+
+func (d date) String() string {
+	if d.Year == 0 {
+		return fmt.Sprintf("%02d-%02d", d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Blackout represents whole days during which an event must not happen. It
+// can be a one-off span of dates, a span of dates recurring every year, the
+// last week of every month or a span of weekdays.
+type Blackout struct {
+	kind blackoutKind
+	// start and end are the inclusive span of dates of the blackout, the
+	// span of a yearly blackout may wrap around the end of the year
+	start, end date
+	weekSpan   WeekSpan
+}
+
+func (b *Blackout) String() string {
+	switch b.kind {
+	case blackoutLastWeek:
+		return lastWeekToken
+	case blackoutWeekSpan:
+		return b.weekSpan.String()
+	}
+	if b.start == b.end {
+		return b.start.String()
+	}
+	return b.start.String() + dateRangeToken + b.end.String()
+}
+
+// Includes returns whether t is on a day of the blackout.
+func (b *Blackout) Includes(t time.Time) bool {
+	switch b.kind {
+	case blackoutDates:
+		d := dateOf(t)
+		return !d.before(b.start) && !b.end.before(d)
+	case blackoutYearly:
+		d := dateOf(t)
+		d.Year = 0
+		if b.end.before(b.start) {
+			// eg. 12-24..01-02
+			return !d.before(b.start) || !b.end.before(d)
+		}
+		return !d.before(b.start) && !b.end.before(d)
+	case blackoutLastWeek:
+		daysInMonth := monthNext(t).AddDate(0, 0, -1).Day()
+		return t.Day() > daysInMonth-7
+	case blackoutWeekSpan:
+		return b.weekSpan.Match(t)
+	}
+	return false
+}
+
+// InBlackout returns whether t is on a day of any of the blackouts.
+func InBlackout(blackouts []*Blackout, t time.Time) bool {
+	for _, b := range blackouts {
+		if b.Includes(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlackoutEnd returns the earliest time, not before t, which is outside of
+// all the blackouts. This is t itself if it's not in any blackout, otherwise
+// the start of the first following day not in any blackout. A zero time is
+// returned if the blackouts last for more than a year.
+func BlackoutEnd(blackouts []*Blackout, t time.Time) time.Time {
+	if !InBlackout(blackouts, t) {
+		return t
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < maxBlackoutDays; i++ {
+		day = day.AddDate(0, 0, 1)
+		if !InBlackout(blackouts, day) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+// ParseBlackouts parses a comma-separated list of blackouts. The format is
+// described as:
+//
+//	blackoutlist = blackout *( "," blackout )
+//	blackout = dates / yearlydates / "last-week" / wdayspan
+//	dates = date [ ".." date ]
+//	date = 4DIGIT "-" 2DIGIT "-" 2DIGIT
+//	yearlydates = yearlydate [ ".." yearlydate ]
+//	yearlydate = 2DIGIT "-" 2DIGIT
+//
+// where wdayspan is a span of weekdays as in the schedules parsed by
+// ParseSchedule.
+//
+// Examples:
+// 2026-12-24 (on December 24th, 2026)
+// 2026-12-24..2027-01-02 (from December 24th, 2026 to January 2nd, 2027)
+// 12-24..01-02 (from December 24th to January 2nd, every year)
+// last-week (during the last 7 days of every month)
+// sat-sun (every weekend)
+// fri5 (on the last Friday of every month)
+//
+// Returns a slice of blackouts or an error if parsing failed.
+func ParseBlackouts(blackoutSpec string) ([]*Blackout, error) {
+	var blackouts []*Blackout
+	for _, s := range strings.Split(blackoutSpec, ",") {
+		b, err := parseBlackout(s)
+		if err != nil {
+			return nil, err
+		}
+		blackouts = append(blackouts, b)
+	}
+	return blackouts, nil
+}
+
+func parseBlackout(s string) (*Blackout, error) {
+	if s == lastWeekToken {
+		return &Blackout{kind: blackoutLastWeek}, nil
+	}
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		span, err := parseWeekSpan(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse blackout %q: not a valid date or weekday span", s)
+		}
+		return &Blackout{kind: blackoutWeekSpan, weekSpan: span}, nil
+	}
+
+	split := strings.Split(s, dateRangeToken)
+	if len(split) > 2 {
+		return nil, fmt.Errorf("cannot parse blackout %q: invalid date span", s)
+	}
+	start, err := parseDate(split[0])
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse blackout %q: %v", s, err)
+	}
+	end := start
+	if len(split) == 2 {
+		end, err = parseDate(split[1])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse blackout %q: %v", s, err)
+		}
+	}
+
+	if (start.Year == 0) != (end.Year == 0) {
+		return nil, fmt.Errorf("cannot parse blackout %q: cannot mix yearly and one-off dates", s)
+	}
+	if start.Year == 0 {
+		return &Blackout{kind: blackoutYearly, start: start, end: end}, nil
+	}
+	if end.before(start) {
+		return nil, fmt.Errorf("cannot parse blackout %q: end date is before start date", s)
+	}
+	return &Blackout{kind: blackoutDates, start: start, end: end}, nil
+}
+
+// parseDate parses a date like "2026-12-24", or a yearly one like "12-24".
+func parseDate(s string) (date, error) {
+	yearly := len(s) == len("01-02")
+	value := s
+	if yearly {
+		// use a leap year, to accept February 29th
+		value = "2000-" + s
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return date{}, fmt.Errorf("%q is not a valid date", s)
+	}
+	d := dateOf(t)
+	if yearly {
+		d.Year = 0
+	}
+	return d, nil
+}
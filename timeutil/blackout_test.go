@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type blackoutSuite struct{}
+
+var _ = Suite(&blackoutSuite{})
+
+func (s *blackoutSuite) TestParseBlackoutsHappy(c *C) {
+	for _, t := range []struct {
+		in  string
+		out string
+	}{
+		{"2026-12-24", "2026-12-24"},
+		{"2026-12-24..2027-01-02", "2026-12-24..2027-01-02"},
+		{"12-24..01-02", "12-24..01-02"},
+		{"02-29", "02-29"},
+		{"last-week", "last-week"},
+		{"sat-sun", "sat-sun"},
+		{"fri5", "fri5"},
+	} {
+		blackouts, err := timeutil.ParseBlackouts(t.in)
+		c.Assert(err, IsNil, Commentf("%q", t.in))
+		c.Assert(blackouts, HasLen, 1)
+		c.Check(blackouts[0].String(), Equals, t.out)
+	}
+
+	blackouts, err := timeutil.ParseBlackouts("last-week,12-25,sat")
+	c.Assert(err, IsNil)
+	c.Check(blackouts, HasLen, 3)
+}
+
+func (s *blackoutSuite) TestParseBlackoutsError(c *C) {
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"", `cannot parse blackout "": not a valid date or weekday span`},
+		{"last-month", `cannot parse blackout "last-month": not a valid date or weekday span`},
+		{"sat,", `cannot parse blackout "": not a valid date or weekday span`},
+		{"2026-13-01", `cannot parse blackout "2026-13-01": "2026-13-01" is not a valid date`},
+		{"02-30", `cannot parse blackout "02-30": "02-30" is not a valid date`},
+		{"2026-12-24..", `cannot parse blackout "2026-12-24..": "" is not a valid date`},
+		{"2026-12-24..12-31", `cannot parse blackout "2026-12-24..12-31": cannot mix yearly and one-off dates`},
+		{"2027-01-02..2026-12-24", `cannot parse blackout "2027-01-02..2026-12-24": end date is before start date`},
+		{"01-01..01-02..01-03", `cannot parse blackout "01-01..01-02..01-03": invalid date span`},
+	} {
+		_, err := timeutil.ParseBlackouts(t.in)
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.in))
+	}
+}
+
+func (s *blackoutSuite) TestIncludes(c *C) {
+	for _, t := range []struct {
+		blackout string
+		t        string
+		included bool
+	}{
+		{"2026-12-24", "2026-12-24 00:00", true},
+		{"2026-12-24", "2026-12-24 23:59", true},
+		{"2026-12-24", "2026-12-25 00:00", false},
+		{"2026-12-24", "2025-12-24 12:00", false},
+		{"2026-12-24..2027-01-02", "2026-12-31 12:00", true},
+		{"2026-12-24..2027-01-02", "2027-01-02 12:00", true},
+		{"2026-12-24..2027-01-02", "2027-01-03 12:00", false},
+		{"12-24..01-02", "2030-12-31 12:00", true},
+		{"12-24..01-02", "2031-01-01 12:00", true},
+		{"12-24..01-02", "2031-01-03 12:00", false},
+		{"12-24..01-02", "2031-12-23 12:00", false},
+		{"07-01..07-31", "2031-07-15 12:00", true},
+		{"07-01..07-31", "2031-08-01 12:00", false},
+		// February has 28 days in 2026
+		{"last-week", "2026-02-21 12:00", false},
+		{"last-week", "2026-02-22 12:00", true},
+		{"last-week", "2026-01-24 12:00", false},
+		{"last-week", "2026-01-25 12:00", true},
+		{"last-week", "2026-01-31 23:59", true},
+		{"last-week", "2026-02-01 00:00", false},
+		// 2026-10-17 is a Saturday
+		{"sat-sun", "2026-10-17 12:00", true},
+		{"sat-sun", "2026-10-18 12:00", true},
+		{"sat-sun", "2026-10-19 12:00", false},
+		// 2026-10-30 is the last Friday of October
+		{"fri5", "2026-10-30 12:00", true},
+		{"fri5", "2026-10-23 12:00", false},
+	} {
+		blackouts, err := timeutil.ParseBlackouts(t.blackout)
+		c.Assert(err, IsNil)
+		tm, err := time.ParseInLocation("2006-01-02 15:04", t.t, time.Local)
+		c.Assert(err, IsNil)
+		c.Check(timeutil.InBlackout(blackouts, tm), Equals, t.included, Commentf("%q at %s", t.blackout, t.t))
+	}
+}
+
+func (s *blackoutSuite) TestBlackoutEnd(c *C) {
+	blackouts, err := timeutil.ParseBlackouts("last-week,sat-sun")
+	c.Assert(err, IsNil)
+
+	// 2026-10-14 is a Wednesday, outside of the blackouts
+	t := time.Date(2026, 10, 14, 10, 30, 0, 0, time.Local)
+	c.Check(timeutil.BlackoutEnd(blackouts, t), Equals, t)
+
+	// the last week of October is followed by a weekend
+	t = time.Date(2026, 10, 27, 10, 30, 0, 0, time.Local)
+	c.Check(timeutil.BlackoutEnd(blackouts, t).Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, time.Local)), Equals, true)
+
+	blackouts, err = timeutil.ParseBlackouts("mon-sun")
+	c.Assert(err, IsNil)
+	c.Check(timeutil.BlackoutEnd(blackouts, t).IsZero(), Equals, true)
+
+	blackouts, err = timeutil.ParseBlackouts("2026-01-01..2027-12-31")
+	c.Assert(err, IsNil)
+	c.Check(timeutil.BlackoutEnd(blackouts, t).IsZero(), Equals, true)
+}
@@ -53,6 +53,14 @@ func MockKillWait(wait time.Duration) (restore func()) {
 	}
 }
 
+func MockNftablesCommand(cmd string) (restore func()) {
+	oldNftablesCommand := nftablesCommand
+	nftablesCommand = cmd
+	return func() {
+		nftablesCommand = oldNftablesCommand
+	}
+}
+
 func MockEnsureDirState(f func(dir string, glob string, content map[string]osutil.FileState) (changed, removed []string, err error)) (restore func()) {
 	oldEnsureDirState := ensureDirState
 	ensureDirState = f
@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/strutil"
@@ -90,6 +91,44 @@ TasksAccounting=true
 	return buf.String()
 }
 
+func formatNetworkGroupSlice(grp *quota.Group) string {
+	// the ip accounting of systemd uses eBPF programs attached to the cgroup
+	// of the slice to count the traffic, which is reported as the network
+	// usage of the group
+	return `
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
+`
+}
+
+func formatIOGroupSlice(grp *quota.Group) string {
+	// only enable io accounting for groups limiting io, as it is not free
+	if len(grp.IOLimit) == 0 {
+		return ""
+	}
+	header := `
+# Enable io accounting, the following io limits map to io.max of the
+# cgroup v2 io controller
+IOAccounting=true
+`
+	buf := bytes.NewBufferString(header)
+	for _, dev := range grp.IOLimit {
+		if dev.ReadBandwidth != 0 {
+			fmt.Fprintf(buf, "IOReadBandwidthMax=%s %d\n", dev.Device, dev.ReadBandwidth)
+		}
+		if dev.WriteBandwidth != 0 {
+			fmt.Fprintf(buf, "IOWriteBandwidthMax=%s %d\n", dev.Device, dev.WriteBandwidth)
+		}
+		if dev.ReadIOPS != 0 {
+			fmt.Fprintf(buf, "IOReadIOPSMax=%s %d\n", dev.Device, dev.ReadIOPS)
+		}
+		if dev.WriteIOPS != 0 {
+			fmt.Fprintf(buf, "IOWriteIOPSMax=%s %d\n", dev.Device, dev.WriteIOPS)
+		}
+	}
+	return buf.String()
+}
+
 // GenerateQuotaSliceUnitFile generates a systemd slice unit definition for the
 // specified quota group.
 func GenerateQuotaSliceUnitFile(grp *quota.Group) []byte {
@@ -98,6 +137,8 @@ func GenerateQuotaSliceUnitFile(grp *quota.Group) []byte {
 	cpuOptions := formatCpuGroupSlice(grp)
 	memoryOptions := formatMemoryGroupSlice(grp)
 	taskOptions := formatTaskGroupSlice(grp)
+	networkOptions := formatNetworkGroupSlice(grp)
+	ioOptions := formatIOGroupSlice(grp)
 	template := `[Unit]
 Description=Slice for snap quota group %[1]s
 Before=slices.target
@@ -107,6 +148,33 @@ X-Snappy=yes
 `
 
 	fmt.Fprintf(&buf, template, grp.Name)
-	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions)
+	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions, networkOptions, ioOptions)
+	return buf.Bytes()
+}
+
+// GenerateQuotaNetworkRulesFile generates the nftables rules enforcing the
+// network limits of the specified quota group. The rules match the sockets
+// of the processes in the cgroup of the group slice, which needs to exist
+// when the rules are loaded, hence they are loaded before starting the
+// services of the group. Loading the rules again replaces the previous ones.
+func GenerateQuotaNetworkRulesFile(grp *quota.Group) []byte {
+	if grp.NetworkLimit == nil {
+		return nil
+	}
+
+	table := grp.NetworkRulesTable()
+	cgroupPath := grp.SliceCgroupPath()
+	template := `# Auto-generated, DO NOT EDIT
+table inet %[1]s
+delete table inet %[1]s
+table inet %[1]s {
+	chain output {
+		type filter hook output priority filter; policy accept;
+		socket cgroupv2 level %[2]d "%[3]s" limit rate over %[4]d bytes/second drop
+	}
+}
+`
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, template, table, strings.Count(cgroupPath, "/")+1, cgroupPath, grp.NetworkLimit.EgressRate)
 	return buf.Bytes()
 }
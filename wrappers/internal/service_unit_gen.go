@@ -161,6 +161,9 @@ EnvironmentFile=-/etc/environment
 {{- if .LogNamespace}}
 Environment=SNAPD_LOG_NAMESPACE={{.LogNamespace}}
 {{- end}}
+{{- range .NetworkRulesFiles}}
+ExecStartPre={{$.NftablesCommand}} -f {{.}}
+{{- end}}
 ExecStart={{.App.LauncherCommand}}
 SyslogIdentifier={{.App.Snap.InstanceName}}.{{.App.Name}}
 Restart={{.Restart}}
@@ -289,6 +292,8 @@ WantedBy={{.ServicesTarget}}
 		InterfaceUnitSnippets    string
 		SliceUnit                string
 		LogNamespace             string
+		NetworkRulesFiles        []string
+		NftablesCommand          string
 
 		Home    string
 		EnvVars string
@@ -345,6 +350,12 @@ WantedBy={{.ServicesTarget}}
 	// check the quota group slice
 	if opts.QuotaGroup != nil {
 		wrapperData.SliceUnit = opts.QuotaGroup.SliceFileName()
+		// the nftables rules enforcing network limits match the cgroup of
+		// the slice, so they can only be loaded once it exists, which is
+		// the case when the service is being started; the service fails to
+		// start if they cannot be loaded, as its traffic would not be limited
+		wrapperData.NetworkRulesFiles = opts.QuotaGroup.NetworkRulesFiles()
+		wrapperData.NftablesCommand = quota.NftablesCommand
 		if opts.QuotaGroup.JournalQuotaSet() {
 			wrapperData.LogNamespace = opts.QuotaGroup.JournalNamespaceName()
 			wrapperData.Requires = append([]string{opts.QuotaGroup.JournalSocketName()}, wrapperData.Requires...)
@@ -20,9 +20,12 @@
 package wrappers
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"time"
@@ -73,6 +76,10 @@ type Interacter interface {
 // wait this time between TERM and KILL
 var killWait = 5 * time.Second
 
+// nftablesCommand is the command used to unload the network rules of quota
+// groups, the same one the services load them with.
+var nftablesCommand = quota.NftablesCommand
+
 // ScopeOptions provides ways to limit the effects of service operations
 // to a certain scope, including which users and service type.
 type ScopeOptions struct {
@@ -801,6 +808,40 @@ func (es *ensureSnapServicesContext) ensureSnapJournaldUnits(quotaGroups *quota.
 	return nil
 }
 
+// ensureQuotaNetworkRules takes care of writing the nftables rules enforcing
+// the network limits of the quota groups. The rules are loaded when starting
+// the services of the groups.
+func (es *ensureSnapServicesContext) ensureQuotaNetworkRules(quotaGroups *quota.QuotaGroupSet) error {
+	for _, grp := range quotaGroups.AllQuotaGroups() {
+		// network limits cannot be removed from a group, so groups without
+		// them never had rules written
+		if grp.NetworkLimit == nil {
+			continue
+		}
+
+		path := grp.NetworkRulesFile()
+		content := internal.GenerateQuotaNetworkRulesFile(grp)
+		old, modifiedFile, err := tryFileUpdate(path, content)
+		if err != nil {
+			return err
+		}
+		if !modifiedFile {
+			continue
+		}
+
+		if es.observeChange != nil {
+			var oldContent []byte
+			if old != nil {
+				oldContent = old.Content
+			}
+			es.observeChange(nil, grp, "nftables", grp.Name, string(oldContent), string(content))
+		}
+
+		es.modifiedUnits[path] = old
+	}
+	return nil
+}
+
 // ensureJournalQuotaServiceUnits takes care of writing service drop-in files for all journal namespaces.
 func (es *ensureSnapServicesContext) ensureJournalQuotaServiceUnits(quotaGroups *quota.QuotaGroupSet) error {
 	handleFileModification := func(grp *quota.Group, path string, content []byte) error {
@@ -896,6 +937,10 @@ func EnsureSnapServices(snaps map[*snap.Info]*SnapServiceOptions, opts *EnsureSn
 		return err
 	}
 
+	if err := context.ensureQuotaNetworkRules(quotaGroups); err != nil {
+		return err
+	}
+
 	return context.reloadModified()
 }
 
@@ -1094,8 +1139,20 @@ func RemoveQuotaGroup(grp *quota.Group, inter Interacter) error {
 
 	systemSysd := systemd.New(systemd.SystemMode, inter)
 
+	// remove the network rules, the rules already loaded stay in the kernel
+	// after the cgroup they match is gone so their table is deleted as well
+	err := os.Remove(grp.NetworkRulesFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := removeQuotaNetworkRulesTable(grp); err != nil {
+			return err
+		}
+	}
+
 	// remove the slice file
-	err := os.Remove(filepath.Join(dirs.SnapServicesDir, grp.SliceFileName()))
+	err = os.Remove(filepath.Join(dirs.SnapServicesDir, grp.SliceFileName()))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -1109,6 +1166,17 @@ func RemoveQuotaGroup(grp *quota.Group, inter Interacter) error {
 	return nil
 }
 
+// removeQuotaNetworkRulesTable deletes the nftables table loaded by the
+// services of the quota group. The table does not exist if none of the
+// services was started since the limits were set.
+func removeQuotaNetworkRulesTable(grp *quota.Group) error {
+	output, err := exec.Command(nftablesCommand, "delete", "table", "inet", grp.NetworkRulesTable()).CombinedOutput()
+	if err == nil || errors.Is(err, exec.ErrNotFound) || bytes.Contains(output, []byte("No such file or directory")) {
+		return nil
+	}
+	return fmt.Errorf("cannot remove network rules of quota group %q: %v", grp.Name, osutil.OutputErr(output, err))
+}
+
 // RemoveSnapServices disables and removes service units for the applications
 // from the snap which are services. The optional flag indicates whether
 // services are removed as part of undoing of first install of a given snap.
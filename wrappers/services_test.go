@@ -60,7 +60,7 @@ type servicesTestSuite struct {
 
 	sysdLog [][]string
 
-	systemctlRestorer, delaysRestorer func()
+	systemctlRestorer, delaysRestorer, nftablesRestorer func()
 
 	perfTimings timings.Measurer
 
@@ -81,6 +81,7 @@ func (s *servicesTestSuite) SetUpTest(c *C) {
 		return []byte("ActiveState=inactive\n"), nil
 	})
 	s.delaysRestorer = systemd.MockStopDelays(2*time.Millisecond, 4*time.Millisecond)
+	s.nftablesRestorer = wrappers.MockNftablesCommand(filepath.Join(s.tempdir, quota.NftablesCommand))
 	s.perfTimings = timings.New(nil)
 
 	xdgRuntimeDir := fmt.Sprintf("%s/%d", dirs.XdgRuntimeDirBase, os.Getuid())
@@ -98,6 +99,7 @@ func (s *servicesTestSuite) TearDownTest(c *C) {
 	}
 	s.systemctlRestorer()
 	s.delaysRestorer()
+	s.nftablesRestorer()
 	dirs.SetRootDir("")
 	s.DBusTest.TearDownTest(c)
 }
@@ -296,6 +298,9 @@ MemoryLimit=%[4]d
 # threads, etc for a slice
 TasksAccounting=true
 TasksMax=%[5]d
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	allowedCpusValue := strutil.IntsToCommaSeparated(resourceLimits.CPUSet.CPUs)
@@ -333,6 +338,271 @@ TasksMax=%[5]d
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithIOQuotas(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+
+	resourceLimits := quota.NewResourcesBuilder().
+		WithThreadLimit(32).
+		WithIODeviceLimit(quota.ResourceIODevice{
+			Device:         "/dev/mmcblk0",
+			ReadBandwidth:  10 * quantity.SizeMiB,
+			WriteBandwidth: 5 * quantity.SizeMiB,
+			WriteIOPS:      100,
+		}).
+		WithIODeviceLimit(quota.ResourceIODevice{
+			Device:   "/dev/sda",
+			ReadIOPS: 500,
+		}).
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	dir := dirs.StripRootDir(filepath.Join(dirs.SnapMountDir, "hello-snap", "12.mount"))
+	svcContent := fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application hello-snap.svc1
+Requires=%[1]s
+Wants=network.target
+After=%[1]s network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run hello-snap.svc1
+SyslogIdentifier=hello-snap.svc1
+Restart=on-failure
+WorkingDirectory=/var/snap/hello-snap/12
+ExecStop=/usr/bin/snap run --command=stop hello-snap.svc1
+ExecStopPost=/usr/bin/snap run --command=post-stop hello-snap.svc1
+TimeoutStopSec=30s
+Type=forking
+Slice=snap.foogroup.slice
+
+[Install]
+WantedBy=multi-user.target
+`,
+		systemd.EscapeUnitNamePath(dir),
+	)
+
+	sliceContent := `[Unit]
+Description=Slice for snap quota group foogroup
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+TasksMax=32
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
+
+# Enable io accounting, the following io limits map to io.max of the
+# cgroup v2 io controller
+IOAccounting=true
+IOReadBandwidthMax=/dev/mmcblk0 10485760
+IOWriteBandwidthMax=/dev/mmcblk0 5242880
+IOWriteIOPSMax=/dev/mmcblk0 100
+IOReadIOPSMax=/dev/sda 500
+`
+
+	exp := []changesObservation{
+		{
+			snapName: "hello-snap",
+			unitType: "service",
+			name:     "svc1",
+			old:      "",
+			new:      svcContent,
+		},
+		{
+			grp:      grp,
+			unitType: "slice",
+			new:      sliceContent,
+			old:      "",
+			name:     "foogroup",
+		},
+	}
+	r, observe := expChangeObserver(c, exp)
+	defer r()
+
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileEquals, svcContent)
+}
+
+func (s *servicesTestSuite) TestEnsureSnapServicesWithNetworkQuotas(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+
+	grp, err := quota.NewGroup("foo-group", quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	dir := dirs.StripRootDir(filepath.Join(dirs.SnapMountDir, "hello-snap", "12.mount"))
+	svcContent := fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application hello-snap.svc1
+Requires=%[1]s
+Wants=network.target
+After=%[1]s network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStartPre=/usr/sbin/nft -f %[2]s
+ExecStart=/usr/bin/snap run hello-snap.svc1
+SyslogIdentifier=hello-snap.svc1
+Restart=on-failure
+WorkingDirectory=/var/snap/hello-snap/12
+ExecStop=/usr/bin/snap run --command=stop hello-snap.svc1
+ExecStopPost=/usr/bin/snap run --command=post-stop hello-snap.svc1
+TimeoutStopSec=30s
+Type=forking
+Slice=snap.foo\x2dgroup.slice
+
+[Install]
+WantedBy=multi-user.target
+`,
+		systemd.EscapeUnitNamePath(dir),
+		grp.NetworkRulesFile(),
+	)
+
+	sliceContent := `[Unit]
+Description=Slice for snap quota group foo-group
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
+`
+
+	rulesContent := `# Auto-generated, DO NOT EDIT
+table inet snap_quota_foo_group
+delete table inet snap_quota_foo_group
+table inet snap_quota_foo_group {
+	chain output {
+		type filter hook output priority filter; policy accept;
+		socket cgroupv2 level 1 "snap.foo\x2dgroup.slice" limit rate over 1048576 bytes/second drop
+	}
+}
+`
+
+	exp := []changesObservation{
+		{
+			grp:      grp,
+			unitType: "nftables",
+			name:     "foo-group",
+			old:      "",
+			new:      rulesContent,
+		},
+		{
+			snapName: "hello-snap",
+			unitType: "service",
+			name:     "svc1",
+			old:      "",
+			new:      svcContent,
+		},
+		{
+			grp:      grp,
+			unitType: "slice",
+			new:      sliceContent,
+			old:      "",
+			name:     "foo-group",
+		},
+	}
+	r, observe := expChangeObserver(c, exp)
+	defer r()
+
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileEquals, svcContent)
+	c.Assert(grp.NetworkRulesFile(), testutil.FileEquals, rulesContent)
+
+	// the rules go away with the group, including the loaded ones
+	nft := s.mockNftables(c, "")
+	defer nft.Restore()
+	err = wrappers.RemoveQuotaGroup(grp, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(grp.NetworkRulesFile(), testutil.FileAbsent)
+	c.Check(nft.Calls(), DeepEquals, [][]string{
+		{"nft", "delete", "table", "inet", "snap_quota_foo_group"},
+	})
+}
+
+// mockNftables mocks the nft command at its absolute path, which is not on
+// PATH, below the test root directory.
+func (s *servicesTestSuite) mockNftables(c *C, script string) *testutil.MockCmd {
+	nft := testutil.MockCommand(c, filepath.Join(s.tempdir, quota.NftablesCommand), script)
+	// the log of an earlier mock at the same path is kept around
+	nft.ForgetCalls()
+	return nft
+}
+
+func (s *servicesTestSuite) TestRemoveQuotaGroupNetworkRules(c *C) {
+	grp, err := quota.NewGroup("foo-group", quota.NewResourcesBuilder().WithNetworkEgressRate(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	for _, tc := range []struct {
+		script string
+		err    string
+	}{
+		// the services were never started, so the rules were never loaded
+		{`echo "Error: Could not process rule: No such file or directory" >&2; exit 1`, ""},
+		{`echo "Error: Could not process rule: Operation not permitted" >&2; exit 1`, `cannot remove network rules of quota group "foo-group": Error: Could not process rule: Operation not permitted`},
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(grp.NetworkRulesFile()), 0755), IsNil)
+		c.Assert(os.WriteFile(grp.NetworkRulesFile(), nil, 0644), IsNil)
+
+		nft := s.mockNftables(c, tc.script)
+		err = wrappers.RemoveQuotaGroup(grp, progress.Null)
+		if tc.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, tc.err)
+		}
+		c.Check(nft.Calls(), HasLen, 1)
+		nft.Restore()
+	}
+
+	// nothing is done for groups which never had rules
+	nft := s.mockNftables(c, "exit 1")
+	defer nft.Restore()
+	c.Check(wrappers.RemoveQuotaGroup(grp, progress.Null), IsNil)
+	c.Check(nft.Calls(), HasLen, 0)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesWithZeroCpuCountQuotas(c *C) {
 	// Kind of a special case, if the cpu count is zero it needs to automatically scale
 	// at the moment of writing the service file to the current number of cpu cores
@@ -392,6 +662,9 @@ MemoryAccounting=true
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 	// The reason we are not mocking the cpu count here is because we are relying
 	// on the real code to produce the slice file content, and it will always use
@@ -491,6 +764,9 @@ MemoryAccounting=true
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	sliceContent := fmt.Sprintf(sliceTempl, grp.Name)
@@ -589,6 +865,9 @@ MemoryAccounting=true
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	jconfContent := fmt.Sprintf(jconfTempl, grp.Name)
@@ -707,6 +986,9 @@ MemoryAccounting=true
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	jconfContent := fmt.Sprintf(jconfTempl, grp.Name)
@@ -822,6 +1104,9 @@ MemoryAccounting=true
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	jconfContent := fmt.Sprintf(jconfTempl, grp.Name)
@@ -989,6 +1274,9 @@ MemoryLimit=1073741824
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	subSliceTempl := `[Unit]
@@ -1009,6 +1297,9 @@ MemoryLimit=536870912
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	jconfTempl := `# Journald configuration for snap quota group %s
@@ -1156,6 +1447,9 @@ MemoryLimit=1073741824
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	subSliceTempl := `[Unit]
@@ -1176,6 +1470,9 @@ MemoryLimit=536870912
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	jconfTempl := `# Journald configuration for snap quota group %s
@@ -1319,6 +1616,9 @@ MemoryLimit=%[2]s
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 	sliceFile := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snap.foogroup.slice")
 
@@ -1420,6 +1720,9 @@ MemoryLimit=%[2]s
 # threads, etc for a slice
 TasksAccounting=true
 TasksMax=%[3]d
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 	sliceFile := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snap.foogroup.slice")
 
@@ -1596,6 +1899,9 @@ MemoryLimit=%[3]d
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	sliceContent := fmt.Sprintf(sliceTempl, "foogroup", resourceLimits.CPU.Count*resourceLimits.CPU.Percentage, resourceLimits.Memory.Limit)
@@ -1772,6 +2078,9 @@ MemoryLimit=%[3]d
 # Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
+
+# Always enable ip accounting, to be able to count the network traffic of the slice
+IPAccounting=true
 `
 
 	allowedCpusValue := strutil.IntsToCommaSeparated(resourceLimits.CPUSet.CPUs)